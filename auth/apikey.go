@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKeyIdentity is the user an API key acts for
+type APIKeyIdentity struct {
+	UserID   string
+	TenantID string
+}
+
+// APIKeys authenticates callers that cannot obtain a JWT, such as IDE assistants. Only the
+// SHA-256 digests of the keys are kept.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]APIKeyIdentity
+}
+
+// ParseAPIKeys builds the key set from "user_id[@tenant_id]:key" specs. A key written as
+// "sha256:<hex>" is the digest of the actual key, so the plain key need not be configured.
+// Without a tenant the key gets the tenant the user's tokens map to.
+func ParseAPIKeys(specs []string) (*APIKeys, error) {
+	k := &APIKeys{keys: make(map[[sha256.Size]byte]APIKeyIdentity, len(specs))}
+	for _, spec := range specs {
+		owner, key, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok || owner == "" || key == "" {
+			return nil, fmt.Errorf("API key spec must be user_id[@tenant_id]:key")
+		}
+		userID, tenantID, _ := strings.Cut(owner, "@")
+		if tenantID == "" {
+			tenantID = tenantForUser(userID)
+		}
+
+		var digest [sha256.Size]byte
+		if encoded, hashed := strings.CutPrefix(key, "sha256:"); hashed {
+			raw, err := hex.DecodeString(encoded)
+			if err != nil || len(raw) != sha256.Size {
+				return nil, fmt.Errorf("API key of %q is not a valid sha256 digest", userID)
+			}
+			copy(digest[:], raw)
+		} else {
+			digest = sha256.Sum256([]byte(key))
+		}
+
+		if _, dup := k.keys[digest]; dup {
+			return nil, fmt.Errorf("duplicate API key for %q", userID)
+		}
+		k.keys[digest] = APIKeyIdentity{UserID: userID, TenantID: tenantID}
+	}
+	return k, nil
+}
+
+// Lookup returns the identity of a key. A nil key set knows no keys.
+func (k *APIKeys) Lookup(key string) (APIKeyIdentity, bool) {
+	if k == nil || key == "" {
+		return APIKeyIdentity{}, false
+	}
+	identity, ok := k.keys[sha256.Sum256([]byte(key))]
+	return identity, ok
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	digest := sha256.Sum256([]byte("ide-secret"))
+	keys, err := ParseAPIKeys([]string{
+		"0f8fad5b-d9cb-469f-a165-70867728950e:plain-secret",
+		" 7c9e6679-7425-40de-944b-e07fc1f90ae7@acme:sha256:" + hex.EncodeToString(digest[:]),
+	})
+	require.NoError(t, err)
+
+	identity, ok := keys.Lookup("plain-secret")
+	require.True(t, ok)
+	assert.Equal(t, APIKeyIdentity{UserID: "0f8fad5b-d9cb-469f-a165-70867728950e", TenantID: "tenant_0f8fad5b-d"}, identity)
+
+	identity, ok = keys.Lookup("ide-secret")
+	require.True(t, ok)
+	assert.Equal(t, "acme", identity.TenantID)
+
+	_, ok = keys.Lookup("sha256:" + hex.EncodeToString(digest[:]))
+	assert.False(t, ok, "the digest itself is not a key")
+	_, ok = (*APIKeys)(nil).Lookup("plain-secret")
+	assert.False(t, ok)
+
+	_, err = ParseAPIKeys([]string{"no-key"})
+	assert.Error(t, err)
+	_, err = ParseAPIKeys([]string{"user:sha256:abc"})
+	assert.Error(t, err)
+	_, err = ParseAPIKeys([]string{"a:same", "b:same"})
+	assert.ErrorContains(t, err, "duplicate")
+}
@@ -149,18 +149,20 @@ func (v *JWTValidator) ExtractUserContext(claims *Claims) (userID, tenantID stri
 	// Use the subject as user ID
 	userID = claims.Sub
 	
+	return userID, tenantForUser(userID)
+}
+
+// tenantForUser maps a user to its tenant
+func tenantForUser(userID string) string {
 	// For tenant ID, we can use a combination of user ID and a default tenant
 	// In production, this might come from custom claims or be mapped differently
 	if userID != "" {
 		// Create a deterministic tenant ID based on user ID
 		// This ensures the same user always gets the same tenant
-		tenantID = fmt.Sprintf("tenant_%s", userID[:min(len(userID), 10)])
-	} else {
-		// Fallback to default tenant
-		tenantID = "default-tenant"
+		return fmt.Sprintf("tenant_%s", userID[:min(len(userID), 10)])
 	}
-	
-	return userID, tenantID
+	// Fallback to default tenant
+	return "default-tenant"
 }
 
 // getRSAPublicKeyFromURL fetches the RSA public key from a dynamic JWKS endpoint
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -20,7 +21,9 @@ import (
 	"github.com/tas-agent-builder/handlers"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/credentials"
 	"github.com/tas-agent-builder/services/impl"
+	"github.com/tas-agent-builder/services/mcp"
 	"github.com/tas-agent-builder/services/memory"
 )
 
@@ -43,13 +46,25 @@ func main() {
 		&models.AgentExecution{},
 		&models.AgentUsageStats{},
 		&models.Skill{},
+		&models.SkillVersion{},
+		&models.TenantSkillSetting{},
+		&models.ExecutionToolCall{},
+		&models.Credential{},
 	); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	// Skill names were unique across tenants before idx_skills_tenant_name replaced this index;
+	// migration 029_add_skill_tenancy.sql does the same for databases migrated with SQL
+	if db.Migrator().HasIndex(&models.Skill{}, "idx_agent_builder_skills_name") {
+		if err := db.Migrator().DropIndex(&models.Skill{}, "idx_agent_builder_skills_name"); err != nil {
+			log.Fatal("Failed to drop the global skill name index:", err)
+		}
+	}
 	
 	// Initialize services
 	agentService := impl.NewAgentService(db)
-	routerService := impl.NewRouterService(&cfg.Router)
+	modelCatalog := impl.NewModelCatalogService(&cfg.Router)
+	routerService := impl.NewLLMBackendService(&cfg.LLM, impl.NewRouterServiceWithCatalog(&cfg.Router, modelCatalog))
 	executionService := impl.NewExecutionService(db, routerService)
 
 	// Initialize cache service
@@ -99,6 +114,26 @@ func main() {
 		log.Println("Memory service disabled (no Redis connection)")
 	}
 
+	// MCP sessions are shared by the context service and skill tools
+	mcpClients := mcp.NewManager(mcp.Implementation{Name: "tas-agent-builder", Version: "1.0.0"},
+		time.Duration(cfg.MCP.Timeout)*time.Second)
+	stdioArgPatterns, err := mcp.CompileArgPatterns(cfg.MCP.StdioArgPatterns)
+	if err != nil {
+		log.Fatalf("Invalid MCP_STDIO_ARG_PATTERNS: %v", err)
+	}
+	mcpClients.SetStdioLimits(mcp.StdioLimits{
+		AllowedCommands: cfg.MCP.StdioAllowedCommands,
+		ArgPatterns:     stdioArgPatterns,
+		PoolSize:        cfg.MCP.StdioPoolSize,
+		MaxConcurrency:  cfg.MCP.StdioMaxConcurrency,
+		MaxLifetime:     time.Duration(cfg.MCP.StdioMaxLifetime) * time.Second,
+		MaxMemoryMB:     cfg.MCP.StdioMaxMemoryMB,
+		MaxCPUSeconds:   cfg.MCP.StdioMaxCPUSeconds,
+		MaxOpenFiles:    cfg.MCP.StdioMaxOpenFiles,
+	})
+	mcpClients.SetPoolLimits(cfg.MCP.MaxSessionPools, time.Duration(cfg.MCP.SessionIdleTimeout)*time.Second)
+	defer mcpClients.Close()
+
 	// Initialize MCP context service if enabled
 	var mcpContextService services.MCPContextService
 	if cfg.MCP.Enabled {
@@ -107,7 +142,7 @@ func main() {
 			TimeoutMs:          cfg.MCP.Timeout * 1000,
 			MaxAutonomousSteps: cfg.MCP.MaxToolIterations,
 		}
-		mcpContextService = impl.NewMCPContextService(cfg.MCP.ServerURL, mcpConfig)
+		mcpContextService = impl.NewMCPContextServiceWithClients(cfg.MCP.ServerURL, mcpConfig, mcpClients)
 		log.Printf("MCP context service initialized: server=%s, timeout=%ds, max_iterations=%d",
 			cfg.MCP.ServerURL, cfg.MCP.Timeout, cfg.MCP.MaxToolIterations)
 	} else {
@@ -115,18 +150,50 @@ func main() {
 	}
 
 	// Initialize skill service and seed defaults
-	skillService := impl.NewSkillService(db)
+	skillService := impl.NewSkillService(db, cfg.MCP.SkillRelevanceThreshold)
 	if err := skillService.SeedDefaults(context.Background()); err != nil {
 		log.Printf("Warning: Failed to seed default skills: %v", err)
 	}
 
+	// Credentials for skill servers are sealed with the configured envelope keys
+	var keyring *credentials.Keyring
+	if len(cfg.Credentials.Keys) > 0 {
+		keyring, err = credentials.ParseKeyring(cfg.Credentials.Keys, cfg.Credentials.PrimaryKeyID)
+		if err != nil {
+			log.Fatal("Failed to load credential keys:", err)
+		}
+		log.Printf("Credential storage enabled: primary key=%s, keys=%v", keyring.Primary(), keyring.KeyIDs())
+	} else {
+		log.Println("Credential storage disabled: CREDENTIAL_KEYS is not set")
+	}
+	credentialService := impl.NewCredentialService(db, keyring)
+
+	// Skill tools are cached and their servers health-checked in the background
+	skillToolService := impl.NewSkillToolService(skillService, credentialService, mcpClients, time.Duration(cfg.MCP.ToolCacheTTL)*time.Second)
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if keyring != nil {
+		// Rewrap credentials left under an older key after the primary key changed
+		go func() {
+			if _, err := credentialService.RotateKeys(backgroundCtx); err != nil {
+				log.Printf("Warning: Failed to rotate credential keys: %v", err)
+			}
+		}()
+	}
+	if cfg.MCP.Enabled && cfg.MCP.HealthCheckInterval > 0 {
+		go skillToolService.RunHealthChecks(backgroundCtx, time.Duration(cfg.MCP.HealthCheckInterval)*time.Second)
+	}
+
 	// Initialize handlers
-	agentHandlers := handlers.NewAgentHandlers(agentService, routerService, executionService, documentContextService, cacheService, memoryService, mcpContextService, skillService, cfg.MCP.Enabled, cfg.MCP.MaxToolIterations)
-	skillHandlers := handlers.NewSkillHandlers(skillService)
-	routerProxy := handlers.NewRouterProxyHandler(cfg.Router.BaseURL)
+	toolCallAudit := impl.NewToolCallAuditService(db)
+	agentHandlers := handlers.NewAgentHandlers(agentService, routerService, executionService, documentContextService, cacheService, memoryService, mcpContextService, skillToolService, skillService, toolCallAudit, cfg.MCP.Enabled, cfg.MCP.MaxToolIterations, cfg.MCP.ToolConcurrency, cfg.MCP.MaxAgentDepth, cfg.MCP.MaxToolsPerRequest)
+	skillHandlers := handlers.NewSkillHandlers(skillService, skillToolService, credentialService, mcpClients, cfg.MCP.SkillAdminRoles, cfg.MCP.GlobalSkillAdminRoles)
+	auditHandlers := handlers.NewAuditHandlers(toolCallAudit, executionService, cfg.Auth.AuditRoles)
+	credentialHandlers := handlers.NewCredentialHandlers(credentialService, cfg.Credentials.AdminRoles)
+	routerProxy := handlers.NewRouterProxyHandler(cfg.Router.BaseURL, modelCatalog)
 	
 	// Setup router
-	router := setupRouter(agentHandlers, skillHandlers, routerProxy, cfg)
+	router := setupRouter(agentHandlers, skillHandlers, auditHandlers, credentialHandlers, routerProxy, cfg)
 	
 	// Start server
 	srv := &http.Server{
@@ -154,7 +221,8 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		// Not fatal, so the deferred cleanup still stops stdio servers
+		log.Println("Server forced to shutdown:", err)
 	}
 	
 	log.Println("Server exited")
@@ -179,7 +247,7 @@ func initDB(databaseURL string) (*gorm.DB, error) {
 	return db, nil
 }
 
-func setupRouter(agentHandlers *handlers.AgentHandlers, skillHandlers *handlers.SkillHandlers, routerProxy *handlers.RouterProxyHandler, cfg *config.Config) *gin.Engine {
+func setupRouter(agentHandlers *handlers.AgentHandlers, skillHandlers *handlers.SkillHandlers, auditHandlers *handlers.AuditHandlers, credentialHandlers *handlers.CredentialHandlers, routerProxy *handlers.RouterProxyHandler, cfg *config.Config) *gin.Engine {
 	// Set gin mode based on environment
 	if os.Getenv("ENVIRONMENT") == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -195,7 +263,7 @@ func setupRouter(agentHandlers *handlers.AgentHandlers, skillHandlers *handlers.
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{"http://localhost:3001", "http://localhost:5173"}
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "Mcp-Protocol-Version"}
 	corsConfig.AllowCredentials = true
 	router.Use(cors.New(corsConfig))
 	
@@ -246,7 +314,18 @@ func setupRouter(agentHandlers *handlers.AgentHandlers, skillHandlers *handlers.
 		agents.POST("/:id/unpublish", agentHandlers.UnpublishAgent)
 		agents.POST("/:id/duplicate", agentHandlers.DuplicateAgent)
 		agents.POST("/:id/execute", agentHandlers.ExecuteAgent)
+		agents.POST("/:id/executions/:execution_id/feedback", agentHandlers.SubmitExecutionFeedback)
+		agents.GET("/:id/experiments/:exp/results", agentHandlers.GetExperimentResults)
 	}
+
+	// Human-in-the-loop approval of tool calls and the tool call audit log
+	executions := v1.Group("/executions")
+	{
+		executions.POST("/:id/approve", agentHandlers.ApproveExecution)
+		executions.POST("/:id/reject", agentHandlers.RejectExecution)
+		executions.GET("/:id/tool-calls", auditHandlers.ListExecutionToolCalls)
+	}
+	v1.GET("/audit/tool-calls", auditHandlers.SearchToolCalls)
 	
 	// Skill routes
 	skills := v1.Group("/skills")
@@ -256,6 +335,25 @@ func setupRouter(agentHandlers *handlers.AgentHandlers, skillHandlers *handlers.
 		skills.GET("/:id", skillHandlers.GetSkill)
 		skills.PUT("/:id", skillHandlers.UpdateSkill)
 		skills.DELETE("/:id", skillHandlers.DeleteSkill)
+		skills.POST("/:id/enable", skillHandlers.EnableSkill)
+		skills.POST("/:id/disable", skillHandlers.DisableSkill)
+		skills.GET("/:id/health", skillHandlers.GetSkillHealth)
+		skills.GET("/:id/tools", skillHandlers.GetSkillTools)
+		skills.GET("/:id/versions", skillHandlers.ListSkillVersions)
+		skills.POST("/:id/versions/:version/deprecate", skillHandlers.DeprecateSkillVersion)
+		skills.GET("/:id/usage", skillHandlers.GetSkillUsage)
+		skills.POST("/import/openapi", skillHandlers.ImportOpenAPI)
+	}
+
+	// Credential routes; values are write-only
+	creds := v1.Group("/credentials")
+	{
+		creds.POST("", credentialHandlers.CreateCredential)
+		creds.GET("", credentialHandlers.ListCredentials)
+		creds.POST("/rotate", credentialHandlers.RotateCredentialKeys)
+		creds.GET("/:id", credentialHandlers.GetCredential)
+		creds.PUT("/:id", credentialHandlers.UpdateCredential)
+		creds.DELETE("/:id", credentialHandlers.DeleteCredential)
 	}
 
 	// Additional routes that exist in handlers
@@ -269,11 +367,55 @@ func setupRouter(agentHandlers *handlers.AgentHandlers, skillHandlers *handlers.
 	{
 		routerGroup.GET("/providers", routerProxy.GetProviders)
 		routerGroup.GET("/providers/:provider/models", routerProxy.GetProviderModels)
+		routerGroup.GET("/models/:model", routerProxy.GetModel)
+	}
+
+	// MCP server for IDE assistants and other MCP clients; its tools are the published agents
+	if cfg.MCPServer.Enabled {
+		apiKeys, err := auth.ParseAPIKeys(cfg.MCPServer.APIKeys)
+		if err != nil {
+			log.Fatal("Failed to load MCP server API keys:", err)
+		}
+		router.Match([]string{http.MethodGet, http.MethodPost, http.MethodDelete}, "/mcp",
+			mcpAuthMiddleware(jwtValidator, apiKeys), agentHandlers.ServeMCP)
 	}
 	
 	return router
 }
 
+// mcpAuthMiddleware accepts an API key, sent as X-API-Key or as the bearer token, and
+// otherwise validates the JWT like authMiddleware
+func mcpAuthMiddleware(validator *auth.JWTValidator, apiKeys *auth.APIKeys) gin.HandlerFunc {
+	jwtAuth := authMiddleware(validator)
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if identity, ok := apiKeys.Lookup(key); ok {
+			setAPIKeyUser(c, identity)
+			return
+		}
+		if key != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			c.Abort()
+			return
+		}
+
+		if identity, ok := apiKeys.Lookup(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")); ok {
+			setAPIKeyUser(c, identity)
+			return
+		}
+		jwtAuth(c)
+	}
+}
+
+// setAPIKeyUser sets the user context of a caller authenticated by API key and continues
+func setAPIKeyUser(c *gin.Context, identity auth.APIKeyIdentity) {
+	c.Set("user_id", identity.UserID)
+	c.Set("tenant_id", identity.TenantID)
+	c.Set("roles", []string{})
+	log.Printf("Authenticated user by API key: %s", identity.UserID)
+	c.Next()
+}
+
 // authMiddleware validates JWT tokens using RSA signature verification
 func authMiddleware(validator *auth.JWTValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -313,6 +455,7 @@ func authMiddleware(validator *auth.JWTValidator) gin.HandlerFunc {
 		c.Set("user_email", claims.Email)
 		c.Set("user_name", claims.Name)
 		c.Set("username", claims.PreferredUsername)
+		c.Set("roles", claims.RealmAccess.Roles)
 		
 		log.Printf("Authenticated user: %s (%s)", claims.PreferredUsername, userID)
 		
@@ -0,0 +1,43 @@
+// Command mockrouter serves a scripted mock of the TAS LLM router for local development
+// and offline tests. Point the agent builder at it with ROUTER_BASE_URL.
+//
+//	go run ./cmd/mockrouter -addr :8086 -script testdata/script.json
+//
+// The script is a JSON mockrouter.Script: queued responses served in order, a default
+// response, and the providers to advertise. Without a script every request is echoed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/tas-agent-builder/mockrouter"
+)
+
+func main() {
+	addr := flag.String("addr", ":8086", "listen address")
+	scriptPath := flag.String("script", "", "path to a JSON response script")
+	flag.Parse()
+
+	router := mockrouter.New()
+	if *scriptPath != "" {
+		data, err := os.ReadFile(*scriptPath)
+		if err != nil {
+			log.Fatal("Failed to read script:", err)
+		}
+		var script mockrouter.Script
+		if err := json.Unmarshal(data, &script); err != nil {
+			log.Fatal("Failed to parse script:", err)
+		}
+		router = mockrouter.NewFromScript(script)
+		log.Printf("[MOCKROUTER] Loaded %d scripted responses from %s", len(script.Responses), *scriptPath)
+	}
+
+	log.Printf("[MOCKROUTER] Listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, router); err != nil {
+		log.Fatal("Mock router stopped:", err)
+	}
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -11,6 +13,7 @@ type Config struct {
 	Server    ServerConfig    `json:"server"`
 	Database  DatabaseConfig  `json:"database"`
 	Router    RouterConfig    `json:"router"`
+	LLM       LLMConfig       `json:"llm"`
 	Auth      AuthConfig      `json:"auth"`
 	Logging   LoggingConfig   `json:"logging"`
 	DeepLake  DeepLakeConfig  `json:"deeplake"`
@@ -18,6 +21,22 @@ type Config struct {
 	Aether    AetherConfig    `json:"aether"`
 	Redis     RedisConfig     `json:"redis"`
 	MCP       MCPConfig       `json:"mcp"`
+
+	Credentials CredentialsConfig `json:"credentials"`
+	MCPServer   MCPServerConfig   `json:"mcp_server"`
+}
+
+// CredentialsConfig holds the envelope keys skill credentials are encrypted with
+type CredentialsConfig struct {
+	Keys         []string `json:"-"`              // "id:base64" AES-256 keys; unset disables credential storage
+	PrimaryKeyID string   `json:"primary_key_id"` // Key new values are sealed with; defaults to the first key
+	AdminRoles   []string `json:"admin_roles"`    // Realm roles allowed to manage tenant credentials and rotate keys
+}
+
+// MCPServerConfig holds configuration for the MCP server that offers published agents as tools
+type MCPServerConfig struct {
+	Enabled bool     `json:"enabled"`
+	APIKeys []string `json:"-"` // "user_id[@tenant_id]:key" entries; keys may be given as "sha256:<hex>"
 }
 
 // MCPConfig holds configuration for MCP tool integration
@@ -25,7 +44,36 @@ type MCPConfig struct {
 	ServerURL         string `json:"server_url"`
 	Timeout           int    `json:"timeout"`
 	MaxToolIterations int    `json:"max_tool_iterations"`
+	ToolConcurrency   int    `json:"tool_concurrency"` // Tool calls of one turn run at once
+	MaxAgentDepth     int    `json:"max_agent_depth"`  // Agents calling agents as tools may nest this deep
 	Enabled           bool   `json:"enabled"`
+
+	// Stdio skills launch local processes; only allowlisted executables may be used
+	StdioAllowedCommands []string          `json:"stdio_allowed_commands"`
+	StdioArgPatterns     map[string]string `json:"stdio_arg_patterns"` // Per command, the regexp each argument must match
+	StdioPoolSize        int               `json:"stdio_pool_size"`
+	StdioMaxConcurrency  int               `json:"stdio_max_concurrency"`
+	StdioMaxLifetime     int               `json:"stdio_max_lifetime"` // Seconds; 0 keeps processes until they exit
+	StdioMaxMemoryMB     int               `json:"stdio_max_memory_mb"`
+	StdioMaxCPUSeconds   int               `json:"stdio_max_cpu_seconds"`
+	StdioMaxOpenFiles    int               `json:"stdio_max_open_files"`
+
+	// Sessions are kept per server and set of credentials; unused ones are closed
+	MaxSessionPools    int `json:"max_session_pools"`    // 0 keeps every server's sessions
+	SessionIdleTimeout int `json:"session_idle_timeout"` // Seconds; 0 keeps unused sessions
+
+	ToolCacheTTL        int `json:"tool_cache_ttl"`        // Seconds a skill's tool list is reused
+	HealthCheckInterval int `json:"health_check_interval"` // Seconds between skill server checks; 0 disables
+
+	// Unassigned skills are offered when their description is relevant enough to the system
+	// prompt or input; the most relevant tools are sent when there are more than the limit
+	SkillRelevanceThreshold float64 `json:"skill_relevance_threshold"` // From 0 to 1
+	MaxToolsPerRequest      int     `json:"max_tools_per_request"`     // 0 sends every tool
+
+	// Realm roles of tenant admins, who manage the skills shared with their tenant and choose
+	// which global skills it uses, and of the platform admins who manage global skills
+	SkillAdminRoles       []string `json:"skill_admin_roles"`
+	GlobalSkillAdminRoles []string `json:"global_skill_admin_roles"`
 }
 
 type ServerConfig struct {
@@ -49,16 +97,77 @@ type DatabaseConfig struct {
 }
 
 type RouterConfig struct {
-	BaseURL    string `json:"base_url"`
-	APIKey     string `json:"api_key"`
-	Timeout    int    `json:"timeout"`
-	MaxRetries int    `json:"max_retries"`
+	BaseURL         string `json:"base_url"`
+	APIKey          string `json:"api_key"`
+	Timeout         int    `json:"timeout"`
+	MaxRetries      int    `json:"max_retries"`
+	ModelCatalogTTL int    `json:"model_catalog_ttl"` // Seconds before provider capabilities are refetched
+}
+
+// LLMConfig configures the direct backends that send completions straight to a provider API
+// instead of the TAS LLM router. A backend is enabled by configuring it and is used by agents
+// that select it in llm_config.backend, or by every agent that selects none when Backend names it.
+type LLMConfig struct {
+	Backend    string                 `json:"backend"` // Default for agents: router, openai, anthropic or ollama
+	Timeout    int                    `json:"timeout"`
+	MaxRetries int                    `json:"max_retries"`
+	OpenAI     OpenAIBackendConfig    `json:"openai"`
+	Anthropic  AnthropicBackendConfig `json:"anthropic"`
+	Ollama     OllamaBackendConfig    `json:"ollama"`
+}
+
+// OpenAIBackendConfig configures any OpenAI-compatible chat completions endpoint
+type OpenAIBackendConfig struct {
+	BaseURL string                        `json:"base_url"` // Including the version prefix, e.g. https://api.openai.com/v1
+	APIKey  string                        `json:"api_key"`
+	Models  map[string]BackendModelConfig `json:"models"` // By model name (OPENAI_MODELS, JSON)
+}
+
+// AnthropicBackendConfig configures the Anthropic Messages API
+type AnthropicBackendConfig struct {
+	BaseURL string                        `json:"base_url"`
+	APIKey  string                        `json:"api_key"`
+	Version string                        `json:"version"`
+	Models  map[string]BackendModelConfig `json:"models"` // By model name (ANTHROPIC_MODELS, JSON)
+}
+
+// OllamaBackendConfig configures a local Ollama server. Image URLs are downloaded for it; they
+// must be https on public addresses unless their host is one of ImageHosts.
+type OllamaBackendConfig struct {
+	BaseURL    string                        `json:"base_url"`
+	ImageHosts []string                      `json:"image_hosts"` // Trusted hosts, by default AudiModal's, whose signed URLs images come from
+	Models     map[string]BackendModelConfig `json:"models"`      // By model name (OLLAMA_MODELS, JSON)
+}
+
+// BackendModelConfig describes a model served by a direct backend. Provider APIs do not all
+// report context windows or prices, so set values fill in or override what the backend reports.
+type BackendModelConfig struct {
+	ContextWindow   int      `json:"context_window"`
+	MaxOutputTokens int      `json:"max_output_tokens"`
+	InputCostPer1K  float64  `json:"input_cost_per_1k"`
+	OutputCostPer1K float64  `json:"output_cost_per_1k"`
+	Tokenizer       string   `json:"tokenizer"` // BPE encoding, e.g. "cl100k_base"
+	Features        []string `json:"features"`  // e.g. ["chat", "functions", "vision"]
+}
+
+// Enabled reports whether the named direct backend is configured
+func (c LLMConfig) Enabled(backend string) bool {
+	switch backend {
+	case "openai":
+		return c.OpenAI.BaseURL != ""
+	case "anthropic":
+		return c.Anthropic.APIKey != ""
+	case "ollama":
+		return c.Ollama.BaseURL != ""
+	}
+	return false
 }
 
 type AuthConfig struct {
 	JWTSecret     string   `json:"jwt_secret"`
 	JWTExpiration int      `json:"jwt_expiration"`
 	AllowedOrigins []string `json:"allowed_origins"`
+	AuditRoles     []string `json:"audit_roles"` // Realm roles allowed to search the tenant's tool call audit log
 }
 
 type LoggingConfig struct {
@@ -125,15 +234,38 @@ func LoadConfig() (*Config, error) {
 			MaxLifetime:  getEnvAsInt("DB_MAX_LIFETIME", 300),
 		},
 		Router: RouterConfig{
-			BaseURL:    getEnv("ROUTER_BASE_URL", "http://localhost:8081"),
-			APIKey:     getEnv("ROUTER_API_KEY", ""),
-			Timeout:    getEnvAsInt("ROUTER_TIMEOUT", 30),
-			MaxRetries: getEnvAsInt("ROUTER_MAX_RETRIES", 3),
+			BaseURL:         getEnv("ROUTER_BASE_URL", "http://localhost:8081"),
+			APIKey:          getEnv("ROUTER_API_KEY", ""),
+			Timeout:         getEnvAsInt("ROUTER_TIMEOUT", 30),
+			MaxRetries:      getEnvAsInt("ROUTER_MAX_RETRIES", 3),
+			ModelCatalogTTL: getEnvAsInt("ROUTER_MODEL_CATALOG_TTL", 600),
+		},
+		LLM: LLMConfig{
+			Backend:    getEnv("LLM_BACKEND", "router"),
+			Timeout:    getEnvAsInt("LLM_BACKEND_TIMEOUT", 120),
+			MaxRetries: getEnvAsInt("LLM_BACKEND_MAX_RETRIES", 2),
+			OpenAI: OpenAIBackendConfig{
+				BaseURL: getEnv("OPENAI_BASE_URL", ""),
+				APIKey:  getEnv("OPENAI_API_KEY", ""),
+				Models:  getEnvAsBackendModels("OPENAI_MODELS"),
+			},
+			Anthropic: AnthropicBackendConfig{
+				BaseURL: getEnv("ANTHROPIC_BASE_URL", "https://api.anthropic.com"),
+				APIKey:  getEnv("ANTHROPIC_API_KEY", ""),
+				Version: getEnv("ANTHROPIC_VERSION", "2023-06-01"),
+				Models:  getEnvAsBackendModels("ANTHROPIC_MODELS"),
+			},
+			Ollama: OllamaBackendConfig{
+				BaseURL:    getEnv("OLLAMA_BASE_URL", ""),
+				ImageHosts: getEnvAsSlice("OLLAMA_IMAGE_HOSTS", []string{urlHost(getEnv("AUDIMODAL_BASE_URL", "http://localhost:8084"))}),
+				Models:     getEnvAsBackendModels("OLLAMA_MODELS"),
+			},
 		},
 		Auth: AuthConfig{
 			JWTSecret:      getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 			JWTExpiration:  getEnvAsInt("JWT_EXPIRATION", 3600),
 			AllowedOrigins: getEnvAsSlice("ALLOWED_ORIGINS", []string{"http://localhost:3000"}),
+			AuditRoles:     getEnvAsSlice("AUDIT_ROLES", []string{"admin", "compliance"}),
 		},
 		Logging: LoggingConfig{
 			Level:      getEnv("LOG_LEVEL", "info"),
@@ -173,7 +305,38 @@ func LoadConfig() (*Config, error) {
 			ServerURL:         getEnv("MCP_SERVER_URL", "http://napkin-mcp.tas-mcp-servers.svc.cluster.local:8087"),
 			Timeout:           getEnvAsInt("MCP_TIMEOUT", 120),
 			MaxToolIterations: getEnvAsInt("MCP_MAX_TOOL_ITERATIONS", 10),
+			ToolConcurrency:   getEnvAsInt("MCP_TOOL_CONCURRENCY", 4),
+			MaxAgentDepth:     getEnvAsInt("MCP_MAX_AGENT_DEPTH", 3),
 			Enabled:           getEnvAsBool("MCP_ENABLED", true),
+
+			StdioAllowedCommands: getEnvAsSlice("MCP_STDIO_ALLOWED_COMMANDS", nil),
+			StdioArgPatterns:     getEnvAsMap("MCP_STDIO_ARG_PATTERNS", nil),
+			StdioPoolSize:        getEnvAsInt("MCP_STDIO_POOL_SIZE", 2),
+			StdioMaxConcurrency:  getEnvAsInt("MCP_STDIO_MAX_CONCURRENCY", 4),
+			StdioMaxLifetime:     getEnvAsInt("MCP_STDIO_MAX_LIFETIME", 3600),
+			StdioMaxMemoryMB:     getEnvAsInt("MCP_STDIO_MAX_MEMORY_MB", 1024),
+			StdioMaxCPUSeconds:   getEnvAsInt("MCP_STDIO_MAX_CPU_SECONDS", 600),
+			StdioMaxOpenFiles:    getEnvAsInt("MCP_STDIO_MAX_OPEN_FILES", 256),
+
+			MaxSessionPools:    getEnvAsInt("MCP_MAX_SESSION_POOLS", 256),
+			SessionIdleTimeout: getEnvAsInt("MCP_SESSION_IDLE_TIMEOUT", 600),
+
+			ToolCacheTTL:        getEnvAsInt("MCP_TOOL_CACHE_TTL", 300),
+			HealthCheckInterval: getEnvAsInt("MCP_HEALTH_CHECK_INTERVAL", 60),
+
+			SkillRelevanceThreshold: getEnvAsFloat("MCP_SKILL_RELEVANCE_THRESHOLD", 0.12),
+			MaxToolsPerRequest:      getEnvAsInt("MCP_MAX_TOOLS_PER_REQUEST", 32),
+			SkillAdminRoles:         getEnvAsSlice("SKILL_ADMIN_ROLES", []string{"admin"}),
+			GlobalSkillAdminRoles:   getEnvAsSlice("GLOBAL_SKILL_ADMIN_ROLES", []string{"platform-admin"}),
+		},
+		Credentials: CredentialsConfig{
+			Keys:         getEnvAsSlice("CREDENTIAL_KEYS", nil),
+			PrimaryKeyID: getEnv("CREDENTIAL_PRIMARY_KEY", ""),
+			AdminRoles:   getEnvAsSlice("CREDENTIAL_ADMIN_ROLES", []string{"admin"}),
+		},
+		MCPServer: MCPServerConfig{
+			Enabled: getEnvAsBool("MCP_SERVER_ENABLED", true),
+			APIKeys: getEnvAsSlice("MCP_SERVER_API_KEYS", nil),
 		},
 	}
 
@@ -208,6 +371,10 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("router base URL is required (ROUTER_BASE_URL)")
 	}
 	
+	if config.LLM.Backend != "router" && !config.LLM.Enabled(config.LLM.Backend) {
+		return fmt.Errorf("LLM backend %q is not configured (LLM_BACKEND)", config.LLM.Backend)
+	}
+	
 	// Router API key is optional - router may not require authentication
 	// if config.Router.APIKey == "" {
 	//	return fmt.Errorf("router API key is required (ROUTER_API_KEY)")
@@ -236,6 +403,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -250,4 +426,35 @@ func getEnvAsSlice(key string, defaultValue []string) []string {
 		return strings.Split(value, ",")
 	}
 	return defaultValue
+}
+
+// getEnvAsMap reads a JSON object of strings
+// urlHost returns the host and port of a URL, or "" if it has none
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func getEnvAsMap(key string, defaultValue map[string]string) map[string]string {
+	if value := os.Getenv(key); value != "" {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(value), &m); err == nil {
+			return m
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsBackendModels reads a JSON object of model configs keyed by model name
+func getEnvAsBackendModels(key string) map[string]BackendModelConfig {
+	if value := os.Getenv(key); value != "" {
+		var m map[string]BackendModelConfig
+		if err := json.Unmarshal([]byte(value), &m); err == nil {
+			return m
+		}
+	}
+	return nil
 }
\ No newline at end of file
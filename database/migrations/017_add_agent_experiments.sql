@@ -0,0 +1,30 @@
+-- Migration: 017_add_agent_experiments.sql
+-- Description: Add A/B experiments to agents, variant assignment and user feedback to executions
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Experiment definition: name, enabled flag and weighted variants of llm_config/system_prompt
+ALTER TABLE agent_builder.agents
+ADD COLUMN IF NOT EXISTS experiment JSONB DEFAULT NULL;
+
+COMMENT ON COLUMN agent_builder.agents.experiment IS 'A/B experiment: name, enabled, started_at and weighted variants overriding llm_config and system_prompt';
+
+-- Variant assignment recorded on each execution
+ALTER TABLE public.ab_agent_executions
+ADD COLUMN IF NOT EXISTS experiment VARCHAR(255),
+ADD COLUMN IF NOT EXISTS variant VARCHAR(255);
+
+-- User feedback on the response
+ALTER TABLE public.ab_agent_executions
+ADD COLUMN IF NOT EXISTS feedback_rating SMALLINT CHECK (feedback_rating IN (-1, 1)),
+ADD COLUMN IF NOT EXISTS feedback_comment TEXT,
+ADD COLUMN IF NOT EXISTS feedback_at TIMESTAMP WITH TIME ZONE;
+
+-- Experiment results aggregate per agent, experiment and variant
+CREATE INDEX IF NOT EXISTS idx_ab_agent_executions_experiment
+ON public.ab_agent_executions(agent_id, experiment, variant)
+WHERE experiment IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,41 @@
+-- Migration: 018_create_skills_table.sql
+-- Description: Create the skills table, previously created only by GORM AutoMigrate
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Skills as they were before MCP transports, function and agent skills, and tenancy;
+-- the following migrations add to it
+CREATE TABLE IF NOT EXISTS agent_builder.skills (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    display_name TEXT NOT NULL,
+    description TEXT,
+    type VARCHAR(50) NOT NULL,
+    icon TEXT,
+    tags JSONB DEFAULT '[]',
+    keywords JSONB DEFAULT '[]',
+
+    -- MCP server tools
+    mcp_server_url TEXT,
+    mcp_tool_names JSONB DEFAULT '[]',
+
+    -- Metadata
+    is_public BOOLEAN DEFAULT true,
+    is_system BOOLEAN DEFAULT false,
+    author TEXT,
+    version TEXT DEFAULT '1.0.0',
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Index names match those GORM gives them, so AutoMigrate finds them in place
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_builder_skills_name ON agent_builder.skills(name);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_skills_deleted_at ON agent_builder.skills(deleted_at);
+
+COMMENT ON TABLE agent_builder.skills IS 'Capabilities assigned to agents: MCP servers, function webhooks, builtins and other agents';
+
+COMMIT;
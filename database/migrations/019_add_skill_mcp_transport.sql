@@ -0,0 +1,12 @@
+-- Migration: 019_add_skill_mcp_transport.sql
+-- Description: Add the MCP transport of skill servers
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- MCP transport: streamable_http, sse, rest, or NULL/empty to auto-detect
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS mcp_transport VARCHAR(50);
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Migration: 020_add_skill_mcp_stdio.sql
+-- Description: Add the command, arguments and environment of stdio skill servers
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Stdio servers (mcp_transport 'stdio'): the allowlisted command launched instead of reaching mcp_server_url
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS mcp_command TEXT,
+ADD COLUMN IF NOT EXISTS mcp_args JSONB,
+ADD COLUMN IF NOT EXISTS mcp_env JSONB;
+
+COMMENT ON COLUMN agent_builder.skills.mcp_env IS 'Environment of stdio servers; write-only through the API as it usually holds API keys';
+
+COMMIT;
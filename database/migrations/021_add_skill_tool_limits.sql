@@ -0,0 +1,14 @@
+-- Migration: 021_add_skill_tool_limits.sql
+-- Description: Add per-skill tool call timeout, retry and result size limits
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Tool call limits; zero uses the service defaults
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS tool_timeout_seconds BIGINT,
+ADD COLUMN IF NOT EXISTS tool_max_retries BIGINT,
+ADD COLUMN IF NOT EXISTS tool_max_result_tokens BIGINT;
+
+COMMIT;
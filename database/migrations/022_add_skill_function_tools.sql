@@ -0,0 +1,12 @@
+-- Migration: 022_add_skill_function_tools.sql
+-- Description: Add the webhook tools of function skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Function skills: HTTP webhook tools with their JSON Schemas
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS function_tools JSONB;
+
+COMMIT;
@@ -0,0 +1,21 @@
+-- Migration: 023_add_tool_approvals.sql
+-- Description: Add tools requiring approval to skills, and approval state to executions
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Tools whose calls wait for a user's approval; "*" covers every tool
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS requires_approval JSONB DEFAULT '[]';
+
+-- Set while an execution is awaiting approval: the calls waiting for a decision and the
+-- tool loop state it resumes from
+ALTER TABLE public.ab_agent_executions
+ADD COLUMN IF NOT EXISTS pending_tool_calls JSONB,
+ADD COLUMN IF NOT EXISTS tool_loop_state JSONB;
+
+COMMENT ON COLUMN agent_builder.skills.requires_approval IS 'Tool names whose calls pause the execution until a user approves them; "*" covers every tool';
+COMMENT ON COLUMN public.ab_agent_executions.tool_loop_state IS 'Conversation of an execution awaiting approval, with content parts stored as references';
+
+COMMIT;
@@ -0,0 +1,51 @@
+-- Migration: 024_create_execution_tool_calls_table.sql
+-- Description: Create the audit log of tool calls made during executions, and the redaction rules of skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Masking applied to tool call arguments and results before they are audited
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS audit_redaction JSONB;
+
+CREATE TABLE IF NOT EXISTS agent_builder.execution_tool_calls (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    execution_id UUID NOT NULL,
+    tenant_id TEXT,
+    user_id UUID NOT NULL,
+    agent_id UUID NOT NULL,
+    iteration BIGINT,
+    tool_call_id TEXT,
+    skill_id UUID,
+    skill_name TEXT,
+    server TEXT,
+    tool_name TEXT NOT NULL,
+
+    -- Stored after the skill's redaction rules are applied
+    arguments JSONB,
+    result TEXT,
+    result_truncated BOOLEAN,
+
+    duration_ms BIGINT,
+    status VARCHAR(50) NOT NULL,
+    error TEXT,
+
+    approval_decision VARCHAR(50),
+    approved_by TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_execution_id ON agent_builder.execution_tool_calls(execution_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_tenant_id ON agent_builder.execution_tool_calls(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_user_id ON agent_builder.execution_tool_calls(user_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_agent_id ON agent_builder.execution_tool_calls(agent_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_skill_id ON agent_builder.execution_tool_calls(skill_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_tool_name ON agent_builder.execution_tool_calls(tool_name);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_status ON agent_builder.execution_tool_calls(status);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_execution_tool_calls_created_at ON agent_builder.execution_tool_calls(created_at);
+
+COMMENT ON TABLE agent_builder.execution_tool_calls IS 'Audit log of tool calls, with redacted arguments and results and approval decisions';
+
+COMMIT;
@@ -0,0 +1,45 @@
+-- Migration: 025_create_credentials_table.sql
+-- Description: Create sealed per-tenant and per-user credentials, and the credential templates of skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS agent_builder.credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id TEXT NOT NULL,
+    user_id UUID, -- NULL for credentials shared by the tenant
+    name TEXT NOT NULL,
+    description TEXT,
+
+    -- Sealed value: a data key wrapped by the key-encryption key key_id, and the value under the data key
+    key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    ciphertext BYTEA NOT NULL,
+
+    created_by UUID NOT NULL,
+    value_updated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_builder_credentials_tenant_id ON agent_builder.credentials(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_credentials_user_id ON agent_builder.credentials(user_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_credentials_key_id ON agent_builder.credentials(key_id);
+CREATE INDEX IF NOT EXISTS idx_agent_builder_credentials_deleted_at ON agent_builder.credentials(deleted_at);
+
+COMMENT ON TABLE agent_builder.credentials IS 'Secrets skills reference as {{credential:name}}; values are sealed and never returned by the API';
+
+-- Authentication for HTTP servers, referencing credentials as {{credential:name}}
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS auth_headers JSONB,
+ADD COLUMN IF NOT EXISTS auth_query JSONB;
+
+-- Set when a skill admin writes the skill; other skills resolve only their owner's credentials
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS tenant_credentials BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN agent_builder.skills.tenant_credentials IS 'Whether the auth templates may use tenant credentials besides the owner''s; global skills always may';
+
+COMMIT;
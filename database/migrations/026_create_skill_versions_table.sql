@@ -0,0 +1,35 @@
+-- Migration: 026_create_skill_versions_table.sql
+-- Description: Create immutable skill version snapshots that agents pin
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS agent_builder.skill_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    skill_id UUID NOT NULL,
+    version TEXT NOT NULL,
+
+    -- Definition as of this version
+    mcp_server_url TEXT,
+    mcp_transport VARCHAR(50),
+    mcp_command TEXT,
+    mcp_args JSONB,
+    mcp_tool_names JSONB DEFAULT '[]',
+    function_tools JSONB,
+
+    -- Tool schemas discovered when the version was recorded
+    tools JSONB,
+
+    deprecated BOOLEAN DEFAULT false,
+    deprecation_message TEXT,
+    deprecated_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_skill_versions_skill_version ON agent_builder.skill_versions(skill_id, version);
+
+COMMENT ON TABLE agent_builder.skill_versions IS 'Immutable snapshots of skills; agents pin them as "name@1.2.0" or "name@^1"';
+
+COMMIT;
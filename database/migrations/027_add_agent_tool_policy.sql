@@ -0,0 +1,14 @@
+-- Migration: 027_add_agent_tool_policy.sql
+-- Description: Add the tool policy of agents
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- tool_choice, hint and tool call budget steering the model towards the skills' tools
+ALTER TABLE agent_builder.agents
+ADD COLUMN IF NOT EXISTS tool_policy JSONB DEFAULT NULL;
+
+COMMENT ON COLUMN agent_builder.agents.tool_policy IS 'Tool policy: tool_choice, tool hint and per-execution tool call budget';
+
+COMMIT;
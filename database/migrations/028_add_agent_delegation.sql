@@ -0,0 +1,22 @@
+-- Migration: 028_add_agent_delegation.sql
+-- Description: Add agent skills, and the parent execution of executions run by them
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Agent skills: the agents offered as tools, also recorded in each skill version
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS agent_ids JSONB;
+
+ALTER TABLE agent_builder.skill_versions
+ADD COLUMN IF NOT EXISTS agent_ids JSONB;
+
+-- Set on executions of agents called as tools by another execution's agent
+ALTER TABLE public.ab_agent_executions
+ADD COLUMN IF NOT EXISTS parent_execution_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_ab_agent_executions_parent_execution_id
+ON public.ab_agent_executions(parent_execution_id);
+
+COMMIT;
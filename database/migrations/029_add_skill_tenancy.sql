@@ -0,0 +1,32 @@
+-- Migration: 029_add_skill_tenancy.sql
+-- Description: Scope skills to a tenant and owner, and let tenants disable global skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+-- Ownership. Skills created before tenancy become global, which is how they were shared.
+ALTER TABLE agent_builder.skills
+ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS owner_id UUID,
+ADD COLUMN IF NOT EXISTS visibility VARCHAR(20) NOT NULL DEFAULT 'global';
+
+CREATE INDEX IF NOT EXISTS idx_agent_builder_skills_owner_id ON agent_builder.skills(owner_id);
+
+-- Names are unique per tenant instead of across tenants
+DROP INDEX IF EXISTS agent_builder.idx_agent_builder_skills_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_skills_tenant_name ON agent_builder.skills(tenant_id, name);
+
+-- A tenant admin's choice to disable, or enable again, a global skill for the tenant
+CREATE TABLE IF NOT EXISTS agent_builder.tenant_skill_settings (
+    tenant_id VARCHAR(255) NOT NULL,
+    skill_id UUID NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_by UUID,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, skill_id)
+);
+
+COMMENT ON COLUMN agent_builder.skills.visibility IS 'private (owner only), tenant (every user of tenant_id) or global (every tenant unless disabled)';
+
+COMMIT;
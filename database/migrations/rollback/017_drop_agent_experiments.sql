@@ -0,0 +1,18 @@
+-- Rollback Migration: 017_drop_agent_experiments.sql
+-- Description: Remove A/B experiments, variant assignment and execution feedback
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+DROP INDEX IF EXISTS public.idx_ab_agent_executions_experiment;
+
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS feedback_at;
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS feedback_comment;
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS feedback_rating;
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS variant;
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS experiment;
+
+ALTER TABLE agent_builder.agents DROP COLUMN IF EXISTS experiment;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Rollback Migration: 018_drop_skills_table.sql
+-- Description: Remove the skills table
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+DROP TABLE IF EXISTS agent_builder.skills;
+
+COMMIT;
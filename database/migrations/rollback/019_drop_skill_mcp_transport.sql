@@ -0,0 +1,10 @@
+-- Rollback Migration: 019_drop_skill_mcp_transport.sql
+-- Description: Remove the MCP transport of skill servers
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS mcp_transport;
+
+COMMIT;
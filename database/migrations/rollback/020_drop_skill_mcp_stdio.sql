@@ -0,0 +1,12 @@
+-- Rollback Migration: 020_drop_skill_mcp_stdio.sql
+-- Description: Remove the stdio server settings of skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS mcp_env;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS mcp_args;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS mcp_command;
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- Rollback Migration: 021_drop_skill_tool_limits.sql
+-- Description: Remove the tool call limits of skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS tool_max_result_tokens;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS tool_max_retries;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS tool_timeout_seconds;
+
+COMMIT;
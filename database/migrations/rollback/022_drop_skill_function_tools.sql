@@ -0,0 +1,10 @@
+-- Rollback Migration: 022_drop_skill_function_tools.sql
+-- Description: Remove the webhook tools of function skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS function_tools;
+
+COMMIT;
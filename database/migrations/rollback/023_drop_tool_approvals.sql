@@ -0,0 +1,13 @@
+-- Rollback Migration: 023_drop_tool_approvals.sql
+-- Description: Remove tool approvals from skills and approval state from executions
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS tool_loop_state;
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS pending_tool_calls;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS requires_approval;
+
+COMMIT;
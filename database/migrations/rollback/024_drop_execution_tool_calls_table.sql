@@ -0,0 +1,12 @@
+-- Rollback Migration: 024_drop_execution_tool_calls_table.sql
+-- Description: Remove the tool call audit log and the redaction rules of skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+DROP TABLE IF EXISTS agent_builder.execution_tool_calls;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS audit_redaction;
+
+COMMIT;
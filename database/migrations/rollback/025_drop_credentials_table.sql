@@ -0,0 +1,14 @@
+-- Rollback Migration: 025_drop_credentials_table.sql
+-- Description: Remove skill server credentials and the credential templates of skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS tenant_credentials;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS auth_query;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS auth_headers;
+
+DROP TABLE IF EXISTS agent_builder.credentials;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Rollback Migration: 026_drop_skill_versions_table.sql
+-- Description: Remove skill version snapshots
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+DROP TABLE IF EXISTS agent_builder.skill_versions;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Rollback Migration: 027_drop_agent_tool_policy.sql
+-- Description: Remove the tool policy of agents
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+ALTER TABLE agent_builder.agents DROP COLUMN IF EXISTS tool_policy;
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- Rollback Migration: 028_drop_agent_delegation.sql
+-- Description: Remove agent skills and parent executions
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+DROP INDEX IF EXISTS public.idx_ab_agent_executions_parent_execution_id;
+
+ALTER TABLE public.ab_agent_executions DROP COLUMN IF EXISTS parent_execution_id;
+
+ALTER TABLE agent_builder.skill_versions DROP COLUMN IF EXISTS agent_ids;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS agent_ids;
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- Rollback Migration: 029_drop_skill_tenancy.sql
+-- Description: Remove skill tenancy and per-tenant settings of global skills
+-- Author: TAS Agent Builder Team
+-- Created: 2026-10-18
+
+BEGIN;
+
+DROP TABLE IF EXISTS agent_builder.tenant_skill_settings;
+
+-- Names become unique across tenants again; this fails if tenants reused a name
+DROP INDEX IF EXISTS agent_builder.idx_skills_tenant_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_builder_skills_name ON agent_builder.skills(name);
+
+DROP INDEX IF EXISTS agent_builder.idx_agent_builder_skills_owner_id;
+
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS visibility;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE agent_builder.skills DROP COLUMN IF EXISTS tenant_id;
+
+COMMIT;
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,9 +15,18 @@ import (
 	"github.com/google/uuid"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/budget"
 	"github.com/tas-agent-builder/services/memory"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
+// defaultMemoryTokenBudget caps long-term memory requested from the planner
+const defaultMemoryTokenBudget = 4000
+
+// imageTokenEstimate is what the planner counts for an image sent to the model, which cannot
+// be tokenized
+const imageTokenEstimate = 1600
+
 type AgentHandlers struct {
 	agentService           services.AgentService
 	routerService          services.RouterService
@@ -25,9 +35,14 @@ type AgentHandlers struct {
 	cacheService           services.CacheService
 	memoryService          *memory.MemoryServiceImpl
 	mcpContextService      services.MCPContextService
+	skillTools             services.SkillToolService
 	skillService           services.SkillService
+	toolCallAudit          services.ToolCallAuditService
 	mcpEnabled             bool
 	mcpMaxToolIterations   int
+	mcpToolConcurrency     int
+	mcpMaxAgentDepth       int
+	mcpMaxToolsPerRequest  int
 }
 
 func NewAgentHandlers(
@@ -38,9 +53,14 @@ func NewAgentHandlers(
 	cacheService services.CacheService,
 	memoryService *memory.MemoryServiceImpl,
 	mcpContextService services.MCPContextService,
+	skillTools services.SkillToolService,
 	skillService services.SkillService,
+	toolCallAudit services.ToolCallAuditService,
 	mcpEnabled bool,
 	mcpMaxToolIterations int,
+	mcpToolConcurrency int,
+	mcpMaxAgentDepth int,
+	mcpMaxToolsPerRequest int,
 ) *AgentHandlers {
 	return &AgentHandlers{
 		agentService:           agentService,
@@ -50,9 +70,14 @@ func NewAgentHandlers(
 		cacheService:           cacheService,
 		memoryService:          memoryService,
 		mcpContextService:      mcpContextService,
+		skillTools:             skillTools,
 		skillService:           skillService,
+		toolCallAudit:          toolCallAudit,
 		mcpEnabled:             mcpEnabled,
 		mcpMaxToolIterations:   mcpMaxToolIterations,
+		mcpToolConcurrency:     mcpToolConcurrency,
+		mcpMaxAgentDepth:       mcpMaxAgentDepth,
+		mcpMaxToolsPerRequest:  mcpMaxToolsPerRequest,
 	}
 }
 
@@ -68,6 +93,20 @@ func (h *AgentHandlers) CreateAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid LLM configuration", "details": err.Error()})
 		return
 	}
+	if err := h.validateExperiment(c.Request.Context(), req.Experiment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment", "details": err.Error()})
+		return
+	}
+	if err := h.validateSkillRefs(c.Request.Context(), skillCaller(c), req.Skills); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid skills", "details": err.Error()})
+		return
+	}
+	if req.ToolPolicy != nil {
+		if err := req.ToolPolicy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tool policy", "details": err.Error()})
+			return
+		}
+	}
 
 	ownerID, exists := c.Get("user_id")
 	if !exists {
@@ -555,13 +594,65 @@ func (h *AgentHandlers) ExecuteInternalAgent(c *gin.Context) {
 		}
 	}
 
+	// Parse conversation history if provided
+	if history, exists := rawReq["history"]; exists {
+		if historySlice, ok := history.([]interface{}); ok {
+			for _, msg := range historySlice {
+				if msgMap, ok := msg.(map[string]interface{}); ok {
+					role, _ := msgMap["role"].(string)
+					content, _ := msgMap["content"].(string)
+					if role != "" && content != "" {
+						contextReq.History = append(contextReq.History, models.Message{Role: role, Content: content})
+					}
+				}
+			}
+		}
+	}
+
+	useDocumentContext := len(contextReq.NotebookIDs) > 0 && contextReq.TenantID != ""
+	if !useDocumentContext {
+		contextReq.DisableKnowledge = true
+	}
+
+	// Convert user ID to UUID for router call
+	userUUID, err := uuid.Parse(userStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	// Check if agent uses MCP strategy, has skills, and MCP is enabled
+	useMCPTools := h.usesToolLoop(agent)
+
+	// Allocate the model's context window across the prompt sections, with the tools offered
+	// resolved first so they are counted. The caller is added to the tools' context so the
+	// tool loop keeps the agent's tokenizer and model capabilities.
+	caps := h.modelCapabilities(c.Request.Context(), agent)
+	tok := tokenizerFor(agent, caps)
+	ctx := services.WithModelCapabilities(tokenizer.WithTokenizer(c.Request.Context(), tok), caps)
+	toolCtx := services.WithCaller(ctx, services.Caller{TenantID: c.GetString("tenant_id"), UserID: userUUID})
+	var tools *toolSet
+	if useMCPTools {
+		tools = h.prepareTools(toolCtx, agent, input)
+	}
+	var historyDemand int
+	for _, msg := range contextReq.History {
+		historyDemand += tok.Count(msg.Content)
+	}
+	budgetPlan := h.planTokenBudget(agent, caps, contextReq, tok, tools, historyDemand, 0, 0, 0)
+	if err := budgetOverflow(budgetPlan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input too large for the model", "details": err.Error()})
+		return
+	}
+	agent = withOutputReserve(agent, budgetPlan.OutputReserve)
+
 	// Build system prompt with document context if notebook IDs are provided
 	var systemPrompt string
 	var contextMetadata map[string]interface{}
 
-	if len(contextReq.NotebookIDs) > 0 && contextReq.TenantID != "" {
+	if useDocumentContext {
 		// Use document context retrieval
-		systemPrompt, contextMetadata = h.buildSystemPromptWithContext(c.Request.Context(), agent, contextReq)
+		systemPrompt, contextMetadata = h.buildSystemPromptWithContext(ctx, agent, contextReq, budgetPlan.Documents)
 		log.Printf("Built system prompt with context: %d notebooks, metadata: %v", len(contextReq.NotebookIDs), contextMetadata)
 	} else {
 		// Fall back to agent's static system prompt
@@ -571,6 +662,7 @@ func (h *AgentHandlers) ExecuteInternalAgent(c *gin.Context) {
 			"reason":            "no notebook_ids or tenant_id provided",
 		}
 	}
+	contextMetadata["token_budget"] = budgetPlan.ToMetadata()
 
 	// Build messages for router service
 	messages := []services.Message{
@@ -580,19 +672,13 @@ func (h *AgentHandlers) ExecuteInternalAgent(c *gin.Context) {
 		},
 	}
 
-	// Add conversation history if provided
-	if history, exists := rawReq["history"]; exists {
-		if historySlice, ok := history.([]interface{}); ok {
-			for _, msg := range historySlice {
-				if msgMap, ok := msg.(map[string]interface{}); ok {
-					role, _ := msgMap["role"].(string)
-					content, _ := msgMap["content"].(string)
-					if role != "" && content != "" {
-						messages = append(messages, services.Message{Role: role, Content: content})
-					}
-				}
-			}
-		}
+	// Add conversation history, dropping the oldest messages over budget
+	history, dropped := budget.TrimHistory(contextReq.History, budgetPlan.History, tok.Count)
+	if dropped > 0 {
+		contextMetadata["history_messages_dropped"] = dropped
+	}
+	for _, msg := range history {
+		messages = append(messages, services.Message{Role: msg.Role, Content: msg.Content})
 	}
 
 	// Add the current user message
@@ -601,13 +687,6 @@ func (h *AgentHandlers) ExecuteInternalAgent(c *gin.Context) {
 		Content: input,
 	})
 
-	// Convert user ID to UUID for router call
-	userUUID, err := uuid.Parse(userStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
 	// Log the messages being sent to the router (internal agent)
 	log.Printf("[DEBUG] === INTERNAL AGENT - MESSAGES BEING SENT TO ROUTER ===")
 	log.Printf("[DEBUG] Total messages: %d", len(messages))
@@ -620,17 +699,15 @@ func (h *AgentHandlers) ExecuteInternalAgent(c *gin.Context) {
 	}
 	log.Printf("[DEBUG] === END INTERNAL AGENT MESSAGES DEBUG ===")
 
-	// Check if agent uses MCP strategy, has skills, and MCP is enabled
-	useMCPTools := h.mcpEnabled && h.mcpContextService != nil &&
-		(h.getContextStrategy(agent) == models.ContextStrategyMCP || h.agentHasSkills(agent))
-
 	var response *services.RouterResponse
+	var skillWarnings []string
+	run := &agentRun{UserID: userStr, Input: input, startedAt: startTime} // No execution record, so approvals are refused
 
 	if useMCPTools {
 		log.Printf("[MCP-TOOLS] Internal agent %s uses MCP/skills, executing with tool loop", agentID)
-		response, err = h.executeWithToolLoop(c.Request.Context(), agent, messages, userUUID)
+		response, skillWarnings, err = h.executeWithToolLoop(toolCtx, agent, tools, messages, userUUID, run)
 	} else {
-		response, err = h.routerService.SendRequest(c.Request.Context(), agent.LLMConfig, messages, userUUID)
+		response, err = h.routerService.SendRequest(ctx, agent.LLMConfig, messages, userUUID)
 	}
 
 	// Calculate total duration
@@ -659,6 +736,12 @@ func (h *AgentHandlers) ExecuteInternalAgent(c *gin.Context) {
 		},
 		"context_metadata": contextMetadata,
 	}
+	if len(skillWarnings) > 0 {
+		executionResponse["metadata"].(gin.H)["skill_warnings"] = skillWarnings
+	}
+	if len(run.SkillSelection) > 0 {
+		executionResponse["metadata"].(gin.H)["skill_selection"] = run.SkillSelection
+	}
 
 	// Add session/conversation ID if provided
 	if sid, ok := rawReq["session_id"].(string); ok && sid != "" {
@@ -684,6 +767,20 @@ func (h *AgentHandlers) UpdateAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
+	if err := h.validateExperiment(c.Request.Context(), req.Experiment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment", "details": err.Error()})
+		return
+	}
+	if err := h.validateSkillRefs(c.Request.Context(), skillCaller(c), req.Skills); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid skills", "details": err.Error()})
+		return
+	}
+	if req.ToolPolicy != nil {
+		if err := req.ToolPolicy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tool policy", "details": err.Error()})
+			return
+		}
+	}
 
 	ownerID, exists := c.Get("user_id")
 	if !exists {
@@ -995,25 +1092,105 @@ func (h *AgentHandlers) ExecuteAgent(c *gin.Context) {
 		return
 	}
 
+	// Tenant for memory operations and the credentials of skills the agent calls
+	tenantStr := c.GetString("tenant_id")
+	authToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+	c.JSON(h.executeAgent(c.Request.Context(), agentID, req, userStr, tenantStr, authToken, startTime))
+}
+
+// executeAgent runs an agent for a user through the full pipeline: context strategy, memory,
+// the tool loop and execution tracking. It returns the HTTP status and body of the outcome.
+// authToken is forwarded to resolve file references in the request's parts.
+func (h *AgentHandlers) executeAgent(ctx context.Context, agentID uuid.UUID, req models.ExecutionContextRequest, userStr, tenantStr, authToken string, startTime time.Time) (int, gin.H) {
 	// Verify agent access
-	agent, err := h.agentService.GetAgent(c.Request.Context(), agentID, userStr)
+	agent, err := h.agentService.GetAgent(ctx, agentID, userStr)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
-		return
+		return http.StatusNotFound, gin.H{"error": "Agent not found"}
 	}
 
+	// Apply the A/B variant for this session; everything below sees the variant's config
+	variant := agent.Experiment.Assign(experimentStickyKey(req.SessionID, userStr))
+	agent = agent.WithVariant(variant)
+
 	// Validate input
-	if req.Input == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Input is required"})
-		return
+	if req.Input == "" && len(req.Parts) == 0 {
+		return http.StatusBadRequest, gin.H{"error": "Input is required"}
 	}
 
-	// Build system prompt with document context
-	systemPrompt, contextMetadata := h.buildSystemPromptWithContext(c.Request.Context(), agent, req)
+	// Look the model up once; everything below uses these capabilities
+	caps := h.modelCapabilities(ctx, agent)
+
+	// Resolve multimodal parts (file references, inline images) and check the model can see images
+	var parts []models.ContentPart
+	if len(req.Parts) > 0 {
+		parts, err = h.resolveContentParts(ctx, req.Parts, tenantStr, authToken)
+		if err != nil {
+			return http.StatusBadRequest, gin.H{"error": "Invalid content parts", "details": err.Error()}
+		}
+		if hasImageParts(parts) {
+			if err := validateVisionSupport(agent, caps); err != nil {
+				return http.StatusBadRequest, gin.H{"error": "Model does not support images", "details": err.Error()}
+			}
+		}
+	}
+
+	useMemory := agent.EnableMemory && h.memoryService != nil && req.SessionID != nil && *req.SessionID != ""
+
+	// Measure conversation history so the planner knows how much it needs. Services count
+	// tokens for this execution with the model's tokenizer.
+	tok := tokenizerFor(agent, caps)
+	ctx = services.WithModelCapabilities(tokenizer.WithTokenizer(ctx, tok), caps)
+	var historyDemand, workingDemand, longTermDemand int
+	if useMemory {
+		state, err := h.memoryService.GetMemoryState(ctx, models.GetMemoryRequest{
+			SessionID:    *req.SessionID,
+			AgentID:      agentID,
+			TenantID:     tenantStr,
+			UserID:       userStr,
+			IncludeTypes: []models.MemoryType{models.MemoryTypeShortTerm, models.MemoryTypeWorking},
+		})
+		if err == nil {
+			if state.ShortTerm != nil {
+				historyDemand = state.ShortTerm.TotalTokens
+			}
+			if state.Working != nil {
+				workingDemand = state.Working.TotalTokens
+			}
+		}
+		longTermDemand = defaultMemoryTokenBudget
+	} else {
+		for _, msg := range req.History {
+			historyDemand += tok.Count(msg.Content)
+		}
+	}
+
+	// Convert user ID to UUID for router call and execution record
+	userUUID, err := uuid.Parse(userStr)
+	if err != nil {
+		return http.StatusBadRequest, gin.H{"error": "Invalid user ID format"}
+	}
 
-	// Get tenant ID for memory operations
-	tenantID, _ := c.Get("tenant_id")
-	tenantStr, _ := tenantID.(string)
+	// Check if agent uses MCP strategy, has skills, and MCP is enabled. The tools offered are
+	// resolved before planning so they count against the context window.
+	useMCPTools := h.usesToolLoop(agent)
+	toolCtx := services.WithCaller(ctx, services.Caller{TenantID: tenantStr, UserID: userUUID})
+	var tools *toolSet
+	if useMCPTools {
+		tools = h.prepareTools(toolCtx, agent, req.Input)
+	}
+
+	// Allocate the model's context window across the prompt sections; requests ask for no more
+	// than the response reservation
+	budgetPlan := h.planTokenBudget(agent, caps, req, tok, tools, historyDemand, workingDemand, longTermDemand, countImageParts(parts))
+	if err := budgetOverflow(budgetPlan); err != nil {
+		return http.StatusBadRequest, gin.H{"error": "Input too large for the model", "details": err.Error()}
+	}
+	agent = withOutputReserve(agent, budgetPlan.OutputReserve)
+
+	// Build system prompt with document context
+	systemPrompt, contextMetadata := h.buildSystemPromptWithContext(ctx, agent, req, budgetPlan.Documents)
+	contextMetadata["token_budget"] = budgetPlan.ToMetadata()
 
 	// Build messages for router service
 	messages := []services.Message{
@@ -1025,7 +1202,7 @@ func (h *AgentHandlers) ExecuteAgent(c *gin.Context) {
 
 	// Load memory context if memory is enabled and session ID is provided
 	var memoryContextAdded bool
-	if agent.EnableMemory && h.memoryService != nil && req.SessionID != nil && *req.SessionID != "" {
+	if useMemory {
 		memoryReq := models.GetMemoryRequest{
 			SessionID: *req.SessionID,
 			AgentID:   agentID,
@@ -1034,35 +1211,26 @@ func (h *AgentHandlers) ExecuteAgent(c *gin.Context) {
 			Query:     req.Input,
 		}
 
-		// Get formatted memory for context injection
-		memoryCtx, err := h.memoryService.GetFormattedMemory(c.Request.Context(), memoryReq, 4000) // 4000 token budget for memory
+		// Get formatted memory for context injection within the planned budget
+		memoryMessages, memoryCtx, err := h.memoryMessages(ctx, memoryReq, budgetPlan)
 		if err != nil {
 			// Log but don't fail - memory is supplementary
 			fmt.Printf("Warning: Failed to get memory context: %v\n", err)
 		} else if memoryCtx != nil {
-			// Add long-term memory context first (relevant accumulated knowledge)
-			if memoryCtx.FormattedLongTerm != "" {
-				messages = append(messages, services.Message{
-					Role:    "system",
-					Content: memoryCtx.FormattedLongTerm,
-				})
-			}
-			// Add short-term memory (recent conversation) as messages
-			if memoryCtx.FormattedShortTerm != "" {
-				messages = append(messages, services.Message{
-					Role:    "system",
-					Content: "Recent conversation context:\n" + memoryCtx.FormattedShortTerm,
-				})
-			}
+			messages = append(messages, memoryMessages...)
 			memoryContextAdded = true
 			contextMetadata["memory_enabled"] = true
 			contextMetadata["memory_tokens"] = memoryCtx.TotalTokens
 		}
 	}
 
-	// Add conversation history if provided (fallback if no memory)
+	// Add conversation history if provided (fallback if no memory), dropping the oldest messages over budget
 	if !memoryContextAdded {
-		for _, msg := range req.History {
+		history, dropped := budget.TrimHistory(req.History, budgetPlan.History, tok.Count)
+		if dropped > 0 {
+			contextMetadata["history_messages_dropped"] = dropped
+		}
+		for _, msg := range history {
 			messages = append(messages, services.Message{
 				Role:    msg.Role,
 				Content: msg.Content,
@@ -1074,28 +1242,26 @@ func (h *AgentHandlers) ExecuteAgent(c *gin.Context) {
 	messages = append(messages, services.Message{
 		Role:    "user",
 		Content: req.Input,
+		Parts:   parts,
 	})
 
-	// Convert user ID to UUID for router call and execution record
-	userUUID, err := uuid.Parse(userStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
-		return
-	}
-
 	// Create execution record (status: running)
-	inputJSON, _ := json.Marshal(req)
 	executionReq := models.StartExecutionRequest{
 		AgentID:   agentID,
 		SessionID: req.SessionID,
 		InputData: map[string]any{
 			"input":            req.Input,
-			"messages":         messages,
+			"parts":            models.ContentPartsStorageReferences(parts),
+			"messages":         messagesForStorage(messages),
 			"context_metadata": contextMetadata,
 		},
 	}
+	if variant != nil {
+		executionReq.Experiment = &agent.Experiment.Name
+		executionReq.Variant = &variant.Name
+	}
 
-	execution, err := h.executionService.StartExecution(c.Request.Context(), executionReq, userUUID)
+	execution, err := h.executionService.StartExecution(ctx, executionReq, userUUID)
 	if err != nil {
 		// Log but don't fail - execution tracking is non-critical
 		fmt.Printf("Failed to create execution record: %v\n", err)
@@ -1113,77 +1279,139 @@ func (h *AgentHandlers) ExecuteAgent(c *gin.Context) {
 	}
 	log.Printf("[DEBUG] === END MESSAGES DEBUG ===")
 
-	// Check if agent uses MCP strategy, has skills, and MCP is enabled
-	useMCPTools := h.mcpEnabled && h.mcpContextService != nil &&
-		(h.getContextStrategy(agent) == models.ContextStrategyMCP || h.agentHasSkills(agent))
+	run := &agentRun{
+		UserID:          userStr,
+		TenantID:        tenantStr,
+		SessionID:       req.SessionID,
+		Input:           req.Input,
+		ContextMetadata: contextMetadata,
+		MaxTokens:       budgetPlan.OutputReserve,
+		startedAt:       startTime,
+	}
+	if execution != nil {
+		run.ExecutionID = execution.ID
+	}
+	if variant != nil {
+		run.Experiment = agent.Experiment.Name
+		run.Variant = variant.Name
+	}
 
 	var response *services.RouterResponse
+	var skillWarnings []string
 
 	if useMCPTools {
 		// Execute with MCP tool loop
 		log.Printf("[MCP-TOOLS] Agent %s uses MCP/skills, executing with tool loop", agentID)
-		response, err = h.executeWithToolLoop(c.Request.Context(), agent, messages, userUUID)
+		response, skillWarnings, err = h.executeWithToolLoop(toolCtx, agent, tools, messages, userUUID, run)
 	} else {
 		// Standard execution without tools
-		response, err = h.routerService.SendRequest(c.Request.Context(), agent.LLMConfig, messages, userUUID)
+		response, err = h.routerService.SendRequest(ctx, agent.LLMConfig, messages, userUUID)
 	}
 
+	return h.completeExecution(ctx, agent, run, response, skillWarnings, useMCPTools, err)
+}
+
+// agentRun is what finishing an execution needs. It is saved with the tool loop state when the
+// execution pauses for approval, so a resumed execution finishes like an uninterrupted one.
+type agentRun struct {
+	ExecutionID     uuid.UUID               `json:"execution_id"` // Nil when no execution record could be created
+	UserID          string                  `json:"user_id"`
+	TenantID        string                  `json:"tenant_id,omitempty"`
+	SessionID       *string                 `json:"session_id,omitempty"`
+	Input           string                  `json:"input"`
+	ContextMetadata map[string]any          `json:"context_metadata,omitempty"`
+	Experiment      string                  `json:"experiment,omitempty"`
+	Variant         string                  `json:"variant,omitempty"`
+	ElapsedMs       int                     `json:"elapsed_ms"` // Processing time before the latest pause
+	ToolTrace       *toolTrace              `json:"tool_trace,omitempty"`
+	AgentCalls      *agentCalls             `json:"agent_calls,omitempty"`     // Agents called as tools
+	SkillSelection  []models.SkillSelection `json:"skill_selection,omitempty"` // Why each skill's tools were offered
+	MaxTokens       int                     `json:"max_tokens,omitempty"`      // Planned response reservation, sent again on resume
+
+	startedAt time.Time
+	nested    bool // Run of an agent called as a tool, which cannot pause for approval
+}
+
+// elapsedMs is the run's processing time, leaving out time spent waiting for approval
+func (r *agentRun) elapsedMs() int {
+	return r.ElapsedMs + int(time.Since(r.startedAt).Milliseconds())
+}
+
+// finishExecution records the outcome of an execution, stores the exchange in memory and writes
+// the response. An execution paused for approval is answered with its pending tool calls.
+func (h *AgentHandlers) finishExecution(c *gin.Context, agent *models.Agent, run *agentRun, response *services.RouterResponse, skillWarnings []string, useMCPTools bool, err error) {
+	c.JSON(h.completeExecution(c.Request.Context(), agent, run, response, skillWarnings, useMCPTools, err))
+}
+
+// completeExecution is finishExecution without a request, returning the response's HTTP status
+// and body
+func (h *AgentHandlers) completeExecution(ctx context.Context, agent *models.Agent, run *agentRun, response *services.RouterResponse, skillWarnings []string, useMCPTools bool, err error) (int, gin.H) {
 	// Calculate total duration
-	totalDuration := int(time.Since(startTime).Milliseconds())
+	totalDuration := run.elapsedMs()
+
+	metadata := gin.H{}
+	if len(skillWarnings) > 0 {
+		metadata["skill_warnings"] = skillWarnings
+	}
+	if run.Variant != "" {
+		metadata["experiment"] = gin.H{
+			"name":    run.Experiment,
+			"variant": run.Variant,
+		}
+	}
+
+	var paused *awaitingApprovalError
+	if errors.As(err, &paused) {
+		return http.StatusAccepted, gin.H{
+			"execution_id":       run.ExecutionID.String(),
+			"status":             models.ExecutionStatusAwaitingApproval,
+			"pending_tool_calls": paused.pending,
+			"metadata":           metadata,
+		}
+	}
 
 	if err != nil {
 		// Update execution with failure
-		if execution != nil {
+		if run.ExecutionID != uuid.Nil {
 			errorMsg := err.Error()
-			h.executionService.CompleteExecution(c.Request.Context(), execution.ID, models.ExecutionStatusFailed, nil, &errorMsg, totalDuration)
+			h.executionService.CompleteExecution(ctx, run.ExecutionID, models.ExecutionStatusFailed, nil, &errorMsg, totalDuration)
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Execution failed", "details": err.Error()})
-		return
+		return http.StatusInternalServerError, gin.H{"error": "Execution failed", "details": err.Error()}
 	}
 
 	// Update execution with success
-	outputData := map[string]any{
-		"content":          response.Content,
-		"tokens_used":      response.TokenUsage,
-		"cost_usd":         response.CostUSD,
-		"model":            response.Model,
-		"provider":         response.Provider,
-		"routing_strategy": response.RoutingStrategy,
-		"response_time_ms": response.ResponseTimeMs,
-		"context_metadata": contextMetadata,
-	}
-	if useMCPTools {
-		outputData["mcp_tools_used"] = true
+	outputData := executionOutput(run, response, skillWarnings, useMCPTools)
+	for _, key := range []string{"tool_trace", "skill_selection", "agent_calls", "agent_calls_cost_usd", "total_cost_usd", "total_tokens_used"} {
+		if value, ok := outputData[key]; ok {
+			metadata[key] = value
+		}
 	}
 
-	if execution != nil {
-		outputJSON, _ := json.Marshal(outputData)
-		_ = inputJSON // suppress unused warning
-		_ = outputJSON
-		h.executionService.CompleteExecution(c.Request.Context(), execution.ID, models.ExecutionStatusCompleted, outputData, nil, totalDuration)
+	if run.ExecutionID != uuid.Nil {
+		h.executionService.CompleteExecution(ctx, run.ExecutionID, models.ExecutionStatusCompleted, outputData, nil, totalDuration)
 	}
 
 	// Store interaction in memory if enabled
-	if agent.EnableMemory && h.memoryService != nil && req.SessionID != nil && *req.SessionID != "" {
+	if agent.EnableMemory && h.memoryService != nil && run.SessionID != nil && *run.SessionID != "" {
 		// Store user input
 		userMemoryReq := models.AddMemoryRequest{
-			SessionID: *req.SessionID,
-			AgentID:   agentID,
-			TenantID:  tenantStr,
-			UserID:    userStr,
+			SessionID: *run.SessionID,
+			AgentID:   agent.ID,
+			TenantID:  run.TenantID,
+			UserID:    run.UserID,
 			Role:      "user",
-			Content:   req.Input,
+			Content:   run.Input,
 		}
-		if err := h.memoryService.AddMemory(c.Request.Context(), userMemoryReq); err != nil {
+		if err := h.memoryService.AddMemory(ctx, userMemoryReq); err != nil {
 			fmt.Printf("Warning: Failed to store user input in memory: %v\n", err)
 		}
 
 		// Store assistant response
 		assistantMemoryReq := models.AddMemoryRequest{
-			SessionID: *req.SessionID,
-			AgentID:   agentID,
-			TenantID:  tenantStr,
-			UserID:    userStr,
+			SessionID: *run.SessionID,
+			AgentID:   agent.ID,
+			TenantID:  run.TenantID,
+			UserID:    run.UserID,
 			Role:      "assistant",
 			Content:   response.Content,
 			Metadata: map[string]interface{}{
@@ -1193,33 +1421,66 @@ func (h *AgentHandlers) ExecuteAgent(c *gin.Context) {
 				"cost_usd":   response.CostUSD,
 			},
 		}
-		if err := h.memoryService.AddMemory(c.Request.Context(), assistantMemoryReq); err != nil {
+		if err := h.memoryService.AddMemory(ctx, assistantMemoryReq); err != nil {
 			fmt.Printf("Warning: Failed to store assistant response in memory: %v\n", err)
 		}
 	}
 
 	// Build execution response
 	executionID := uuid.New()
-	if execution != nil {
-		executionID = execution.ID
+	if run.ExecutionID != uuid.Nil {
+		executionID = run.ExecutionID
 	}
 
-	executionResponse := gin.H{
+	metadata["model"] = response.Model
+	metadata["provider"] = response.Provider
+	metadata["routing_strategy"] = response.RoutingStrategy
+	metadata["response_time_ms"] = response.ResponseTimeMs
+	metadata["context_metadata"] = run.ContextMetadata
+	metadata["mcp_tools_used"] = useMCPTools
+
+	return http.StatusOK, gin.H{
 		"execution_id": executionID.String(),
 		"output":       response.Content,
 		"tokens_used":  response.TokenUsage,
 		"cost_usd":     response.CostUSD,
-		"metadata": gin.H{
-			"model":            response.Model,
-			"provider":         response.Provider,
-			"routing_strategy": response.RoutingStrategy,
-			"response_time_ms": response.ResponseTimeMs,
-			"context_metadata": contextMetadata,
-			"mcp_tools_used":   useMCPTools,
-		},
+		"metadata":     metadata,
 	}
+}
 
-	c.JSON(http.StatusOK, executionResponse)
+// executionOutput is the output data recorded with a completed execution. Its cost_usd and
+// tokens_used are the execution's own; the agents it called as tools are rolled up separately.
+func executionOutput(run *agentRun, response *services.RouterResponse, skillWarnings []string, useMCPTools bool) map[string]any {
+	outputData := map[string]any{
+		"content":          response.Content,
+		"tokens_used":      response.TokenUsage,
+		"cost_usd":         response.CostUSD,
+		"model":            response.Model,
+		"provider":         response.Provider,
+		"routing_strategy": response.RoutingStrategy,
+		"response_time_ms": response.ResponseTimeMs,
+		"context_metadata": run.ContextMetadata,
+	}
+	if useMCPTools {
+		outputData["mcp_tools_used"] = true
+	}
+	if len(skillWarnings) > 0 {
+		outputData["skill_warnings"] = skillWarnings
+	}
+	if run.ToolTrace != nil && len(run.ToolTrace.Calls) > 0 {
+		outputData["tool_trace"] = run.ToolTrace
+	}
+	if len(run.SkillSelection) > 0 {
+		outputData["skill_selection"] = run.SkillSelection
+	}
+	if calls := run.AgentCalls.list(); len(calls) > 0 {
+		cost, tokens := run.AgentCalls.totals()
+		outputData["agent_calls"] = calls
+		outputData["agent_calls_cost_usd"] = cost
+		outputData["total_cost_usd"] = response.CostUSD + cost
+		outputData["total_tokens_used"] = response.TokenUsage + tokens
+	}
+	return outputData
 }
 
 // buildSystemPrompt creates a system prompt based on agent configuration (without document context)
@@ -1242,7 +1503,8 @@ func (h *AgentHandlers) buildSystemPrompt(agent *models.Agent) string {
 }
 
 // buildSystemPromptWithContext creates a system prompt with document context injection
-func (h *AgentHandlers) buildSystemPromptWithContext(ctx context.Context, agent *models.Agent, req models.ExecutionContextRequest) (string, map[string]interface{}) {
+// contextBudget is the document allocation from the token budget plan.
+func (h *AgentHandlers) buildSystemPromptWithContext(ctx context.Context, agent *models.Agent, req models.ExecutionContextRequest, contextBudget int) (string, map[string]interface{}) {
 	metadata := make(map[string]interface{})
 
 	// Start with base prompt
@@ -1281,6 +1543,13 @@ func (h *AgentHandlers) buildSystemPromptWithContext(ctx context.Context, agent
 	strategy := h.getContextStrategy(agent)
 	metadata["strategy"] = string(strategy)
 
+	// FormatContextForInjection treats 0 as unlimited, so skip retrieval when nothing is left
+	if contextBudget <= 0 && strategy != models.ContextStrategyNone {
+		metadata["knowledge_enabled"] = true
+		metadata["context_skipped"] = "no token budget remaining for documents"
+		return basePrompt, metadata
+	}
+
 	var contextResult *models.DocumentContextResult
 	var err error
 
@@ -1312,7 +1581,10 @@ func (h *AgentHandlers) buildSystemPromptWithContext(ctx context.Context, agent
 
 	// Format context for injection
 	maxTokens := h.getMaxContextTokens(agent)
-	contextInjection, err := h.documentContextService.FormatContextForInjection(contextResult, maxTokens)
+	if contextBudget < maxTokens {
+		maxTokens = contextBudget
+	}
+	contextInjection, err := h.documentContextService.FormatContextForInjection(contextResult, maxTokens, tokenizer.FromContext(ctx))
 	if err != nil {
 		log.Printf("Error formatting context for injection: %v", err)
 		metadata["format_error"] = err.Error()
@@ -1351,6 +1623,11 @@ func (h *AgentHandlers) buildSystemPromptWithContext(ctx context.Context, agent
 	metadata["truncated"] = contextInjection.Truncated
 	metadata["retrieval_time_ms"] = contextResult.RetrievalTimeMs
 
+	// The chunks that fit in the budget, which are injected in order, are what the answer can cite
+	if included := contextInjection.ChunkCount; included <= len(contextResult.Chunks) {
+		metadata["citations"] = models.CitationsFor(contextResult.Chunks[:included])
+	}
+
 	return enhancedPrompt, metadata
 }
 
@@ -1397,6 +1674,135 @@ func (h *AgentHandlers) getMaxContextTokens(agent *models.Agent) int {
 	return 8000 // Default
 }
 
+// documentTokenDemand returns how many tokens document context may ask for in this execution
+func (h *AgentHandlers) documentTokenDemand(agent *models.Agent, req models.ExecutionContextRequest) int {
+	if !agent.EnableKnowledge || req.DisableKnowledge || h.getContextStrategy(agent) == models.ContextStrategyNone {
+		return 0
+	}
+	return h.getMaxContextTokens(agent)
+}
+
+// memoryMessages formats the session's memory tiers within the planned budget as system messages:
+// long-term knowledge first, then the working document context, then the recent conversation
+func (h *AgentHandlers) memoryMessages(ctx context.Context, req models.GetMemoryRequest, plan *models.TokenBudgetPlan) ([]services.Message, *models.MemoryContext, error) {
+	memoryCtx, err := h.memoryService.GetFormattedMemoryWithBudget(ctx, req, models.MemoryBudget{
+		ShortTerm: plan.History,
+		Working:   plan.WorkingMemory,
+		LongTerm:  plan.LongTermMemory,
+	})
+	if err != nil || memoryCtx == nil {
+		return nil, memoryCtx, err
+	}
+
+	var messages []services.Message
+	if memoryCtx.FormattedLongTerm != "" {
+		messages = append(messages, services.Message{Role: "system", Content: memoryCtx.FormattedLongTerm})
+	}
+	if memoryCtx.FormattedWorking != "" {
+		messages = append(messages, services.Message{Role: "system", Content: memoryCtx.FormattedWorking})
+	}
+	if memoryCtx.FormattedShortTerm != "" {
+		messages = append(messages, services.Message{
+			Role:    "system",
+			Content: "Recent conversation context:\n" + memoryCtx.FormattedShortTerm,
+		})
+	}
+	return messages, memoryCtx, nil
+}
+
+// usesToolLoop reports whether executions of the agent offer it tools
+func (h *AgentHandlers) usesToolLoop(agent *models.Agent) bool {
+	return h.mcpEnabled && h.mcpContextService != nil &&
+		(h.getContextStrategy(agent) == models.ContextStrategyMCP || h.agentHasSkills(agent))
+}
+
+// planTokenBudget sizes the prompt sections against the context window of the model described by
+// caps, counting the tools offered, with their hint, and images too. Without caps the planner's
+// default window is used.
+func (h *AgentHandlers) planTokenBudget(agent *models.Agent, caps *services.ModelCapabilities, req models.ExecutionContextRequest, tok tokenizer.Tokenizer, tools *toolSet, historyDemand, workingDemand, longTermDemand, images int) *models.TokenBudgetPlan {
+	var budgetConfig *models.TokenBudgetConfig
+	if agent.DocumentContext != nil {
+		budgetConfig = agent.DocumentContext.TokenBudget
+	}
+
+	budgetReq := models.TokenBudgetRequest{
+		InputTokens:          tok.Count(req.Input),
+		ImageTokens:          images * imageTokenEstimate,
+		DocumentDemand:       h.documentTokenDemand(agent, req),
+		LongTermMemoryDemand: longTermDemand,
+		HistoryDemand:        historyDemand,
+		WorkingMemoryDemand:  workingDemand,
+	}
+
+	systemPrompt := agent.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = h.buildSystemPrompt(agent)
+	}
+	budgetReq.SystemPromptTokens = tok.Count(systemPrompt)
+	budgetReq.ToolTokens = tools.tokens(tok)
+
+	if agent.LLMConfig.MaxTokens != nil {
+		budgetReq.OutputTokens = *agent.LLMConfig.MaxTokens
+	}
+
+	if caps != nil {
+		budgetReq.ContextWindow = caps.ContextWindow
+		if budgetReq.OutputTokens == 0 {
+			budgetReq.OutputTokens = caps.MaxOutputTokens
+		}
+	}
+
+	plan := budget.NewPlanner(budgetConfig).Plan(budgetReq)
+	log.Printf("[BUDGET] window=%d output=%d system=%d input=%d documents=%d working=%d long_term=%d history=%d overflow=%d",
+		plan.ContextWindow, plan.OutputReserve, plan.SystemPrompt, plan.Input, plan.Documents, plan.WorkingMemory, plan.LongTermMemory, plan.History, plan.Overflow)
+
+	return plan
+}
+
+// budgetOverflow reports a prompt whose fixed sections do not fit the model's context window
+// even with the response reservation shrunk to its minimum
+func budgetOverflow(plan *models.TokenBudgetPlan) error {
+	if plan.Overflow <= 0 {
+		return nil
+	}
+	return fmt.Errorf("the system prompt, tools and input exceed the model's %d-token context window by %d tokens with %d tokens reserved for the response",
+		plan.ContextWindow, plan.Overflow, plan.OutputReserve)
+}
+
+// withOutputReserve returns a copy of agent whose requests ask for at most the planned response
+// reservation, so prompt and completion stay within the context window
+func withOutputReserve(agent *models.Agent, reserve int) *models.Agent {
+	if reserve <= 0 {
+		return agent
+	}
+	copied := *agent
+	copied.LLMConfig.MaxTokens = &reserve
+	return &copied
+}
+
+// modelCapabilities looks up the agent's model with the backend serving it: the router's model
+// catalog, or a direct backend. An execution looks it up once and hands the result to the
+// planner, tokenizer, vision check and pricing; nil means the model is unknown and defaults apply.
+func (h *AgentHandlers) modelCapabilities(ctx context.Context, agent *models.Agent) *services.ModelCapabilities {
+	if h.routerService == nil {
+		return nil
+	}
+	caps, err := h.routerService.GetModelCapabilities(ctx, agent.LLMConfig)
+	if err != nil {
+		log.Printf("[BUDGET] Model %s unknown to its backend, using defaults: %v", agent.LLMConfig.Model, err)
+		return nil
+	}
+	return caps
+}
+
+// tokenizerFor selects the tokenizer for the agent's model, preferring the encoding reported in caps
+func tokenizerFor(agent *models.Agent, caps *services.ModelCapabilities) tokenizer.Tokenizer {
+	if caps != nil && caps.Tokenizer != "" {
+		return tokenizer.Get(caps.Tokenizer)
+	}
+	return tokenizer.ForModel(agent.LLMConfig.Model)
+}
+
 // retrieveVectorContext retrieves context using vector search
 func (h *AgentHandlers) retrieveVectorContext(ctx context.Context, agent *models.Agent, req models.ExecutionContextRequest, notebookIDs []uuid.UUID) (*models.DocumentContextResult, error) {
 	topK := 10
@@ -1411,6 +1817,11 @@ func (h *AgentHandlers) retrieveVectorContext(ctx context.Context, agent *models
 		}
 	}
 
+	documentIDs, ok := documentScope(agent, req)
+	if !ok {
+		return &models.DocumentContextResult{Strategy: models.ContextStrategyVector}, nil
+	}
+
 	// Use tenant ID from request (for internal agents) or fall back to agent's tenant ID
 	tenantID := req.TenantID
 	if tenantID == "" {
@@ -1430,16 +1841,44 @@ func (h *AgentHandlers) retrieveVectorContext(ctx context.Context, agent *models
 		},
 	}
 
-	// Use selected documents if provided
-	if len(req.SelectedDocuments) > 0 {
-		searchReq.DocumentIDs = req.SelectedDocuments
-	}
+	searchReq.DocumentIDs = documentIDs
+	searchReq.IncludeSubNotebooks = includeSubNotebooks(agent, req)
 
 	return h.documentContextService.RetrieveVectorContext(ctx, searchReq)
 }
 
+// documentScope returns the documents an execution's context may come from: the request's
+// selection, else the agent's default documents when its scope is "selected". No IDs means
+// every document of the notebooks; ok is false when the scope allows no documents at all.
+func documentScope(agent *models.Agent, req models.ExecutionContextRequest) (documentIDs []uuid.UUID, ok bool) {
+	var scope models.DocumentScope
+	if agent.DocumentContext != nil {
+		scope = agent.DocumentContext.Scope
+	}
+	switch {
+	case scope == models.DocumentScopeNone:
+		return nil, false
+	case len(req.SelectedDocuments) > 0:
+		return req.SelectedDocuments, true
+	case scope == models.DocumentScopeSelected:
+		return agent.DocumentContext.DefaultDocuments, len(agent.DocumentContext.DefaultDocuments) > 0
+	default:
+		return nil, true
+	}
+}
+
+// includeSubNotebooks reports whether the sub-notebooks are searched too, which the request or the agent may ask for
+func includeSubNotebooks(agent *models.Agent, req models.ExecutionContextRequest) bool {
+	return req.IncludeSubNotebooks || (agent.DocumentContext != nil && agent.DocumentContext.IncludeSubNotebooks)
+}
+
 // retrieveFullContext retrieves full document content
 func (h *AgentHandlers) retrieveFullContext(ctx context.Context, agent *models.Agent, req models.ExecutionContextRequest, notebookIDs []uuid.UUID) (*models.DocumentContextResult, error) {
+	documentIDs, ok := documentScope(agent, req)
+	if !ok {
+		return &models.DocumentContextResult{Strategy: models.ContextStrategyFull}, nil
+	}
+
 	// Use tenant ID from request (for internal agents) or fall back to agent's tenant ID
 	tenantID := req.TenantID
 	if tenantID == "" {
@@ -1455,11 +1894,11 @@ func (h *AgentHandlers) retrieveFullContext(ctx context.Context, agent *models.A
 		AuthToken:   req.AuthToken, // Pass auth token for AudiModal API
 	}
 
-	// Use selected documents if provided
-	if len(req.SelectedDocuments) > 0 {
-		chunkReq.FileIDs = req.SelectedDocuments
-		log.Printf("[DEBUG] retrieveFullContext: using %d selected documents as FileIDs", len(req.SelectedDocuments))
-		for i, docID := range req.SelectedDocuments {
+	// Use the selected or default documents if any
+	if len(documentIDs) > 0 {
+		chunkReq.FileIDs = documentIDs
+		log.Printf("[DEBUG] retrieveFullContext: using %d selected documents as FileIDs", len(documentIDs))
+		for i, docID := range documentIDs {
 			log.Printf("[DEBUG] retrieveFullContext: FileID[%d]=%s", i, docID.String())
 		}
 	} else {
@@ -1471,6 +1910,11 @@ func (h *AgentHandlers) retrieveFullContext(ctx context.Context, agent *models.A
 
 // retrieveHybridContext retrieves context using hybrid approach
 func (h *AgentHandlers) retrieveHybridContext(ctx context.Context, agent *models.Agent, req models.ExecutionContextRequest, notebookIDs []uuid.UUID) (*models.DocumentContextResult, error) {
+	documentIDs, ok := documentScope(agent, req)
+	if !ok {
+		return &models.DocumentContextResult{Strategy: models.ContextStrategyHybrid}, nil
+	}
+
 	vectorWeight := 0.5
 	fullDocWeight := 0.5
 
@@ -1490,14 +1934,11 @@ func (h *AgentHandlers) retrieveHybridContext(ctx context.Context, agent *models
 	}
 
 	chunkReq := models.ChunkRetrievalRequest{
-		TenantID:    tenantID,
-		NotebookIDs: notebookIDs,
-		AuthToken:   req.AuthToken, // Pass auth token for AudiModal API
-	}
-
-	// Use selected documents if provided
-	if len(req.SelectedDocuments) > 0 {
-		chunkReq.FileIDs = req.SelectedDocuments
+		TenantID:            tenantID,
+		NotebookIDs:         notebookIDs,
+		FileIDs:             documentIDs,
+		IncludeSubNotebooks: includeSubNotebooks(agent, req),
+		AuthToken:           req.AuthToken, // Pass auth token for AudiModal API
 	}
 
 	return h.documentContextService.RetrieveHybridContext(ctx, req.Input, chunkReq, vectorWeight, fullDocWeight)
@@ -1515,241 +1956,337 @@ func (h *AgentHandlers) agentHasSkills(agent *models.Agent) bool {
 	return len(skills) > 0
 }
 
-// resolveToolsForAgent resolves tools from an agent's skills and returns tool definitions
-// along with a map of tool name → server URL for invocation routing
-func (h *AgentHandlers) resolveToolsForAgent(ctx context.Context, agent *models.Agent) ([]services.ToolDefinition, map[string]string, error) {
-	if h.skillService == nil {
+// resolveToolsForAgent resolves tools from the skills selected for an execution with the given
+// input and returns tool definitions along with a map of tool name → providing skill for
+// invocation routing, and why each skill was selected. Skills whose servers cannot be reached
+// are reported as warnings rather than failing the execution. Beyond the per-request limit the
+// tools least relevant to the input are left out; tools named in keep never are.
+func (h *AgentHandlers) resolveToolsForAgent(ctx context.Context, agent *models.Agent, input string, keep []string) ([]services.ToolDefinition, map[string]*models.Skill, []string, []models.SkillSelection, error) {
+	if h.skillService == nil || h.skillTools == nil {
 		// No skill service — fall back to default MCP tools
 		tools, err := h.mcpContextService.ListToolsForLLM(ctx)
-		return tools, nil, err
+		return tools, nil, nil, nil, err
 	}
 
-	skills, err := h.skillService.ResolveForAgent(ctx, agent)
+	skills, err := h.skillService.ResolveForAgent(ctx, agent, input)
 	if err != nil {
 		log.Printf("[SKILLS] Failed to resolve skills for agent %s: %v", agent.ID, err)
 		// Fall back to default MCP tools
 		tools, err := h.mcpContextService.ListToolsForLLM(ctx)
-		return tools, nil, err
+		return tools, nil, []string{"Assigned skills could not be loaded"}, nil, err
 	}
 
 	if len(skills) == 0 {
 		// No skills — fall back to default MCP tools if using MCP strategy
 		if h.getContextStrategy(agent) == models.ContextStrategyMCP {
 			tools, err := h.mcpContextService.ListToolsForLLM(ctx)
-			return tools, nil, err
+			return tools, nil, nil, nil, err
 		}
-		return nil, nil, nil
+		return nil, nil, nil, nil, nil
 	}
 
 	var allTools []services.ToolDefinition
-	toolServerMap := make(map[string]string) // tool name → server URL
+	var warnings []string
+	var selected []int                           // Skills of types that offer tools
+	offered := make([][]string, len(skills))     // Tool names of each skill
+	toolSkills := make(map[string]*models.Skill) // tool name → skill
 	seen := make(map[string]bool)
 
-	for _, skill := range skills {
-		if skill.Type != models.SkillTypeMCP || skill.MCPServerURL == "" {
+	for i := range skills {
+		skill := &skills[i]
+		switch skill.Type {
+		case models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin, models.SkillTypeAgent:
+		default:
 			continue
 		}
+		selected = append(selected, i)
 
-		log.Printf("[SKILLS] Discovering tools from skill %q (server: %s)", skill.Name, skill.MCPServerURL)
-
-		// Get allowed tool names for this skill
-		var allowedTools []string
-		if skill.MCPToolNames != nil {
-			json.Unmarshal(skill.MCPToolNames, &allowedTools)
-		}
-		allowedSet := make(map[string]bool)
-		for _, t := range allowedTools {
-			allowedSet[t] = true
+		if v := skill.ResolvedVersion; v != nil && v.Deprecated {
+			warning := fmt.Sprintf("Skill %q is pinned to deprecated version %s", skill.Name, v.Version)
+			if v.DeprecationMessage != "" {
+				warning += ": " + v.DeprecationMessage
+			}
+			warnings = append(warnings, warning)
 		}
 
-		// Discover tools from this skill's MCP server
-		tools, err := h.discoverToolsFromServer(ctx, skill.MCPServerURL)
+		var list *models.SkillToolList
+		if skill.Type == models.SkillTypeAgent {
+			list, err = h.agentSkillTools(ctx, agent, skill)
+		} else {
+			list, err = h.skillTools.ListTools(ctx, skill, false)
+		}
 		if err != nil {
-			log.Printf("[SKILLS] Failed to discover tools from %s: %v", skill.MCPServerURL, err)
+			log.Printf("[SKILLS] Failed to discover tools for skill %q: %v", skill.Name, err)
+			warnings = append(warnings, fmt.Sprintf("Skill %q is unavailable and its tools were not offered: %v", skill.Name, err))
 			continue
 		}
+		if list.Stale {
+			warnings = append(warnings, fmt.Sprintf("Skill %q could not be reached; using tools cached at %s", skill.Name, list.FetchedAt.Format(time.RFC3339)))
+		}
 
-		for _, tool := range tools {
-			// Filter to allowed tools if specified
-			if len(allowedSet) > 0 && !allowedSet[tool.Function.Name] {
+		for _, tool := range list.Tools {
+			if seen[tool.Name] {
 				continue
 			}
-			if !seen[tool.Function.Name] {
-				seen[tool.Function.Name] = true
-				allTools = append(allTools, tool)
-				toolServerMap[tool.Function.Name] = skill.MCPServerURL
+			seen[tool.Name] = true
+			allTools = append(allTools, skillToolDefinition(tool))
+			toolSkills[tool.Name] = skill
+			offered[i] = append(offered[i], tool.Name)
+		}
+	}
+
+	allTools, dropped := selectTools(allTools, h.mcpMaxToolsPerRequest, input, agent.SystemPrompt, keep)
+	for name := range dropped {
+		delete(toolSkills, name)
+	}
+
+	selection := make([]models.SkillSelection, 0, len(selected))
+	for _, i := range selected {
+		sel := models.SkillSelection{Skill: skills[i].Name, Reason: models.SkillSelectedAssigned}
+		if skills[i].Selection != nil {
+			sel = *skills[i].Selection
+		}
+		for _, name := range offered[i] {
+			if dropped[name] {
+				sel.DroppedTools = append(sel.DroppedTools, name)
+			} else {
+				sel.Tools = append(sel.Tools, name)
 			}
 		}
+		selection = append(selection, sel)
 	}
 
-	log.Printf("[SKILLS] Resolved %d tools from %d skills", len(allTools), len(skills))
-	return allTools, toolServerMap, nil
+	log.Printf("[SKILLS] Resolved %d tools from %d skills (%d left out by the limit)", len(allTools), len(skills), len(dropped))
+	return allTools, toolSkills, warnings, selection, nil
 }
 
-// discoverToolsFromServer discovers tools from an MCP server URL
-func (h *AgentHandlers) discoverToolsFromServer(ctx context.Context, serverURL string) ([]services.ToolDefinition, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", serverURL+"/mcp/tools/list", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// skillToolDefinition converts a skill tool to the LLM function format
+func skillToolDefinition(tool models.SkillTool) services.ToolDefinition {
+	var params interface{} = tool.InputSchema
+	if tool.InputSchema == nil {
+		params = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		}
+	}
+	return services.ToolDefinition{
+		Type: "function",
+		Function: services.ToolFunctionDef{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  params,
+		},
 	}
+}
+
+// toolSet is what a tool loop offers the model. It is resolved before the prompt is planned,
+// so the tool definitions and hint count against the context window.
+type toolSet struct {
+	tools       []services.ToolDefinition
+	toolSkills  map[string]*models.Skill
+	warnings    []string // Assigned skills whose tools could not be offered
+	selection   []models.SkillSelection // Why each skill's tools were offered
+	firstChoice string                  // tool_choice of the first request
+	hint        string                  // Appended to the system prompt
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// prepareTools discovers the tools for an execution over input (via skills or default). The
+// agent's tool policy decides whether the first request must call a tool and the hint added to
+// the system prompt. A set without tools, e.g. under a policy of none or when no skill could be
+// reached, means a standard execution.
+func (h *AgentHandlers) prepareTools(ctx context.Context, agent *models.Agent, input string) *toolSet {
+	set := &toolSet{}
+	policy := agent.ToolPolicy
+	if policy.EffectiveChoice() == models.ToolChoiceNone {
+		log.Printf("[MCP-TOOLS] Tool policy of agent %s is none, using standard execution", agent.ID)
+		return set
+	}
+
+	// Resolve tools via skills system; a tool the policy requires is kept whatever the limit
+	var keep []string
+	switch choice := policy.EffectiveChoice(); choice {
+	case models.ToolChoiceAuto, models.ToolChoiceRequiredFirst:
+	default:
+		keep = []string{choice}
+	}
+	tools, toolSkills, warnings, selection, err := h.resolveToolsForAgent(ctx, agent, input, keep)
+	set.warnings, set.selection = warnings, selection
 	if err != nil {
-		return nil, fmt.Errorf("failed to list tools: %w", err)
+		log.Printf("[MCP-TOOLS] Failed to resolve tools, falling back to standard execution: %v", err)
+		return set
 	}
-	defer resp.Body.Close()
 
-	var mcpResp struct {
-		Tools []struct {
-			Name        string      `json:"name"`
-			Description string      `json:"description"`
-			InputSchema interface{} `json:"inputSchema"`
-		} `json:"tools"`
+	log.Printf("[MCP-TOOLS] Discovered %d tools for LLM", len(tools))
+	for _, t := range tools {
+		log.Printf("[MCP-TOOLS]   - %s: %s", t.Function.Name, t.Function.Description)
+	}
+	if len(tools) == 0 {
+		return set
 	}
+	set.tools, set.toolSkills = tools, toolSkills
 
-	if err := json.NewDecoder(resp.Body).Decode(&mcpResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	toolNames := make([]string, len(tools))
+	for i, t := range tools {
+		toolNames[i] = t.Function.Name
 	}
 
-	tools := make([]services.ToolDefinition, 0, len(mcpResp.Tools))
-	for _, t := range mcpResp.Tools {
-		params := t.InputSchema
-		if params == nil {
-			params = map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-			}
+	// The first request's tool_choice follows the policy; later ones let the model decide, so
+	// it can answer once it has the tools' results
+	set.firstChoice = "auto"
+	switch choice := policy.EffectiveChoice(); choice {
+	case models.ToolChoiceAuto:
+	case models.ToolChoiceRequiredFirst:
+		set.firstChoice = "required"
+	default:
+		if containsString(toolNames, choice) {
+			set.firstChoice = choice
+		} else {
+			set.warnings = append(set.warnings, fmt.Sprintf("Tool %q named by the agent's tool policy is not available; the model chose its tools freely", choice))
 		}
-		tools = append(tools, services.ToolDefinition{
-			Type: "function",
-			Function: services.ToolFunctionDef{
-				Name:        t.Name,
-				Description: t.Description,
-				Parameters:  params,
-			},
-		})
 	}
 
-	return tools, nil
+	set.hint = policy.ToolHint(toolNames)
+	return set
 }
 
-// invokeToolOnServer invokes a tool on a specific MCP server
-func (h *AgentHandlers) invokeToolOnServer(ctx context.Context, serverURL string, toolName string, args map[string]interface{}) (string, error) {
-	mcpRequest := map[string]interface{}{
-		"name":      toolName,
-		"arguments": args,
+// tokens counts the hint and the tool definitions sent with every request of the loop
+func (s *toolSet) tokens(tok tokenizer.Tokenizer) int {
+	if s == nil || len(s.tools) == 0 {
+		return 0
 	}
-
-	reqBody, err := json.Marshal(mcpRequest)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+	var tokens int
+	if s.hint != "" {
+		tokens += tok.Count("\n\n" + s.hint)
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", serverURL+"/mcp/tools/call", strings.NewReader(string(reqBody)))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	if data, err := json.Marshal(s.tools); err == nil {
+		tokens += tok.Count(string(data))
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return tokens
+}
 
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("HTTP request failed: %w", err)
+// executeWithToolLoop sends the prepared tools to the LLM and loops on tool_calls until the LLM
+// returns a text response or max iterations are reached. Without tools it runs a standard
+// execution. The returned warnings name assigned skills whose tools could not be offered. The
+// agent's tool policy decides how many tool calls the loop may make.
+//
+// When the model calls tools that require approval, the loop state is saved with run's execution
+// and an *awaitingApprovalError is returned. Without an execution to pause (run is nil, has no
+// record or is nested in another execution) those calls are refused instead.
+func (h *AgentHandlers) executeWithToolLoop(ctx context.Context, agent *models.Agent, set *toolSet, messages []services.Message, userID uuid.UUID, run *agentRun) (*services.RouterResponse, []string, error) {
+	if run != nil {
+		run.SkillSelection = set.selection
+	}
+	if len(set.tools) == 0 {
+		log.Printf("[MCP-TOOLS] No tools available, falling back to standard execution")
+		response, err := h.routerService.SendRequest(ctx, agent.LLMConfig, messages, userID)
+		return response, set.warnings, err
 	}
-	defer resp.Body.Close()
 
-	var mcpResp struct {
-		Content []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
-		} `json:"content"`
-		IsError bool `json:"isError"`
+	if set.hint != "" {
+		messages = withSystemHint(messages, set.hint)
+		log.Printf("[MCP-TOOLS] Added tool hint to system message (%d tools)", len(set.tools))
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&mcpResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
+	loop := newToolLoop(messages, set.tools, set.toolSkills, set.warnings, run)
+	loop.firstChoice = set.firstChoice
+	loop.maxToolCalls = agent.ToolPolicy.ToolCallBudget()
+	response, err := h.runToolLoop(ctx, agent, loop, 0, userID)
+	return response, loop.warnings, err
+}
 
-	var resultText string
-	for _, c := range mcpResp.Content {
-		if c.Type == "text" {
-			resultText = c.Text
-			break
+// withSystemHint returns a copy of messages whose system message ends with hint, adding a
+// system message if there is none. The caller's messages are left untouched.
+func withSystemHint(messages []services.Message, hint string) []services.Message {
+	out := make([]services.Message, len(messages), len(messages)+1)
+	copy(out, messages)
+	for i := range out {
+		if out[i].Role == "system" {
+			out[i].Content += "\n\n" + hint
+			return out
 		}
 	}
+	return append([]services.Message{{Role: "system", Content: hint}}, out...)
+}
 
-	if mcpResp.IsError {
-		return "", fmt.Errorf("tool error: %s", resultText)
-	}
-
-	return resultText, nil
+// toolLoop is a tool calling conversation in progress
+type toolLoop struct {
+	messages   []services.Message
+	tools      []services.ToolDefinition
+	toolSkills map[string]*models.Skill          // tool name → skill
+	schemas    map[string]map[string]interface{} // tool name → input schema
+	warnings   []string
+	trace      *toolTrace
+	run        *agentRun
+	iteration  int                     // Current model request, from 1
+	decisions  map[string]toolDecision // Approval decisions on the current turn's calls
+
+	firstChoice  string // tool_choice of the first request
+	maxToolCalls int    // Tool calls allowed per execution; 0 is unlimited
 }
 
-// executeWithToolLoop discovers MCP tools (via skills or default), sends them to the LLM,
-// and loops on tool_calls until the LLM returns a text response or max iterations are reached.
-func (h *AgentHandlers) executeWithToolLoop(ctx context.Context, agent *models.Agent, messages []services.Message, userID uuid.UUID) (*services.RouterResponse, error) {
-	// Resolve tools via skills system
-	tools, toolServerMap, err := h.resolveToolsForAgent(ctx, agent)
-	if err != nil {
-		log.Printf("[MCP-TOOLS] Failed to resolve tools, falling back to standard execution: %v", err)
-		return h.routerService.SendRequest(ctx, agent.LLMConfig, messages, userID)
+// newToolLoop starts a loop over the given tools. A run's trace is carried on, so counts
+// survive a pause for approval.
+func newToolLoop(messages []services.Message, tools []services.ToolDefinition, toolSkills map[string]*models.Skill, warnings []string, run *agentRun) *toolLoop {
+	loop := &toolLoop{
+		messages:   messages,
+		tools:      tools,
+		toolSkills: toolSkills,
+		schemas:    make(map[string]map[string]interface{}, len(tools)),
+		warnings:   warnings,
+		trace:      newToolTrace(),
+		run:        run,
 	}
-
-	log.Printf("[MCP-TOOLS] Discovered %d tools for LLM", len(tools))
 	for _, t := range tools {
-		log.Printf("[MCP-TOOLS]   - %s: %s", t.Function.Name, t.Function.Description)
-	}
-
-	if len(tools) == 0 {
-		log.Printf("[MCP-TOOLS] No tools available, falling back to standard execution")
-		return h.routerService.SendRequest(ctx, agent.LLMConfig, messages, userID)
-	}
-
-	// Inject tool-usage instruction into the system message so the LLM knows
-	// it has tools available and should use them when the task calls for it.
-	toolNames := make([]string, len(tools))
-	for i, t := range tools {
-		toolNames[i] = t.Function.Name
+		if schema, ok := t.Function.Parameters.(map[string]interface{}); ok {
+			loop.schemas[t.Function.Name] = schema
+		}
 	}
-	toolHint := fmt.Sprintf(
-		"\n\n--- AVAILABLE TOOLS ---\nYou have access to the following tools: %s. "+
-			"When your task involves generating visuals, diagrams, charts, or any action that matches a tool's capability, "+
-			"you MUST call the appropriate tool rather than describing what you would do. "+
-			"After calling a tool, include the tool's result (such as download URLs or file paths) in your final response so the user can access it.",
-		strings.Join(toolNames, ", "))
-
-	for i := range messages {
-		if messages[i].Role == "system" {
-			messages[i].Content += toolHint
-			log.Printf("[MCP-TOOLS] Injected tool hint into system message (%d tool names)", len(toolNames))
-			break
+	if run != nil {
+		if run.ToolTrace == nil {
+			run.ToolTrace = loop.trace
+		}
+		loop.trace = run.ToolTrace
+		if run.AgentCalls == nil {
+			run.AgentCalls = &agentCalls{}
 		}
 	}
+	return loop
+}
 
-	maxIterations := h.mcpMaxToolIterations
-	if maxIterations <= 0 {
-		maxIterations = 10
+// maxToolIterations is the number of model requests one tool loop may make
+func (h *AgentHandlers) maxToolIterations() int {
+	if h.mcpMaxToolIterations <= 0 {
+		return 10
 	}
+	return h.mcpMaxToolIterations
+}
+
+// runToolLoop sends the conversation with its tools from iteration start on, running the tool
+// calls the model makes, until it answers in text, pauses for approval or runs out of iterations
+func (h *AgentHandlers) runToolLoop(ctx context.Context, agent *models.Agent, loop *toolLoop, start int, userID uuid.UUID) (*services.RouterResponse, error) {
+	maxIterations := h.maxToolIterations()
+	tools := loop.tools
 
 	var lastResponse *services.RouterResponse
 
-	for iteration := 0; iteration < maxIterations; iteration++ {
-		// First iteration: force tool use so the model doesn't skip tool calls
-		// Subsequent iterations: let the model decide (it may return text after tool results)
+	for iteration := start; iteration < maxIterations; iteration++ {
 		toolChoice := "auto"
-		if iteration == 0 {
-			toolChoice = "required"
+		if iteration == 0 && loop.firstChoice != "" {
+			toolChoice = loop.firstChoice
+		}
+		if loop.toolCallsLeft() == 0 {
+			toolChoice = "none" // The budget is spent, so the model must answer
 		}
-		log.Printf("[MCP-TOOLS] Iteration %d/%d, tool_choice=%s, sending %d messages with %d tools", iteration+1, maxIterations, toolChoice, len(messages), len(tools))
+		log.Printf("[MCP-TOOLS] Iteration %d/%d, tool_choice=%s, sending %d messages with %d tools", iteration+1, maxIterations, toolChoice, len(loop.messages), len(tools))
 
 		// Send request with tools
-		response, err := h.routerService.SendRequestWithTools(ctx, agent.LLMConfig, messages, tools, toolChoice, userID)
+		response, err := h.routerService.SendRequestWithTools(ctx, agent.LLMConfig, loop.messages, tools, toolChoice, userID)
 		if err != nil {
 			return nil, fmt.Errorf("[MCP-TOOLS] iteration %d failed: %w", iteration+1, err)
 		}
 
 		lastResponse = response
+		loop.iteration = iteration + 1
 
 		// If no tool calls, the LLM is done — return the response
 		if len(response.ToolCalls) == 0 {
@@ -1765,64 +2302,25 @@ func (h *AgentHandlers) executeWithToolLoop(ctx context.Context, agent *models.A
 			Content:   response.Content,
 			ToolCalls: response.ToolCalls,
 		}
-		messages = append(messages, assistantMsg)
-
-		// Execute each tool call and add results as tool messages
-		for _, tc := range response.ToolCalls {
-			log.Printf("[MCP-TOOLS] Executing tool: %s (id=%s)", tc.Function.Name, tc.ID)
-
-			// Parse arguments from JSON string
-			var args map[string]interface{}
-			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
-				log.Printf("[MCP-TOOLS] Failed to parse tool arguments: %v", err)
-				args = make(map[string]interface{})
+		loop.messages = append(loop.messages, assistantMsg)
+
+		// Calls over the tool call budget are refused. Calls that need approval hold up the
+		// whole turn, so its results stay in order.
+		decisions := loop.applyToolCallBudget(response.ToolCalls, nil)
+		if pending := loop.pendingToolCalls(undecided(response.ToolCalls, decisions)); len(pending) > 0 {
+			if loop.run != nil && loop.run.ExecutionID != uuid.Nil && !loop.run.nested {
+				return nil, h.pauseForApproval(ctx, loop, iteration, pending)
 			}
-
-			var resultContent string
-
-			// Route tool invocation to the correct MCP server
-			if serverURL, ok := toolServerMap[tc.Function.Name]; ok && serverURL != "" {
-				// Invoke on the specific server for this skill's tool
-				result, err := h.invokeToolOnServer(ctx, serverURL, tc.Function.Name, args)
-				if err != nil {
-					resultContent = fmt.Sprintf("Error invoking tool: %v", err)
-					log.Printf("[MCP-TOOLS] Tool %s error: %v", tc.Function.Name, err)
-				} else {
-					resultContent = result
-					log.Printf("[MCP-TOOLS] Tool %s succeeded, result length: %d", tc.Function.Name, len(resultContent))
-				}
-			} else {
-				// Fall back to default MCP context service
-				toolResp, err := h.mcpContextService.InvokeTool(ctx, models.MCPToolRequest{
-					ToolName:   tc.Function.Name,
-					Parameters: args,
-				})
-
-				if err != nil {
-					resultContent = fmt.Sprintf("Error invoking tool: %v", err)
-					log.Printf("[MCP-TOOLS] Tool %s error: %v", tc.Function.Name, err)
-				} else if !toolResp.Success {
-					resultContent = fmt.Sprintf("Tool error: %s", toolResp.Error)
-					log.Printf("[MCP-TOOLS] Tool %s failed: %s", tc.Function.Name, toolResp.Error)
-				} else {
-					resultBytes, err := json.Marshal(toolResp.Result)
-					if err != nil {
-						resultContent = fmt.Sprintf("%v", toolResp.Result)
-					} else {
-						resultContent = string(resultBytes)
-					}
-					log.Printf("[MCP-TOOLS] Tool %s succeeded in %dms, result length: %d", tc.Function.Name, toolResp.ExecutionMs, len(resultContent))
-				}
+			if decisions == nil {
+				decisions = make(map[string]toolDecision, len(pending))
 			}
-
-			// Add tool result message
-			toolMsg := services.Message{
-				Role:       "tool",
-				Content:    resultContent,
-				ToolCallID: tc.ID,
+			for _, p := range pending {
+				decisions[p.ID] = toolDecision{Refusal: approvalUnavailableMessage}
 			}
-			messages = append(messages, toolMsg)
 		}
+
+		// Execute the tool calls and add results as tool messages, in the order requested
+		loop.messages = append(loop.messages, h.runDecidedToolCalls(ctx, agent, response.ToolCalls, loop, decisions)...)
 	}
 
 	// Max iterations reached — return the last response
@@ -1832,4 +2330,3 @@ func (h *AgentHandlers) executeWithToolLoop(ctx context.Context, agent *models.A
 	}
 	return nil, fmt.Errorf("[MCP-TOOLS] no response after %d iterations", maxIterations)
 }
-
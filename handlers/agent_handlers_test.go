@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/mockrouter"
+	"github.com/tas-agent-builder/mockrouter/mockroutertest"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/budget"
+	"github.com/tas-agent-builder/services/impl"
+	"github.com/tas-agent-builder/services/memory"
+	"github.com/tas-agent-builder/services/tokenizer"
+)
+
+// stubMCPContextService offers one tool and records invocations
+type stubMCPContextService struct {
+	services.MCPContextService
+	mu      sync.Mutex
+	invoked []models.MCPToolRequest
+}
+
+func (s *stubMCPContextService) ListToolsForLLM(ctx context.Context) ([]services.ToolDefinition, error) {
+	return []services.ToolDefinition{{
+		Type: "function",
+		Function: services.ToolFunctionDef{
+			Name:        "search_documents",
+			Description: "Search documents",
+			Parameters:  map[string]interface{}{"type": "object"},
+		},
+	}}, nil
+}
+
+func (s *stubMCPContextService) InvokeTool(ctx context.Context, req models.MCPToolRequest) (*models.MCPToolResponse, error) {
+	s.mu.Lock()
+	s.invoked = append(s.invoked, req)
+	s.mu.Unlock()
+	return &models.MCPToolResponse{ToolName: req.ToolName, Success: true, Result: map[string]string{"answer": "42"}}, nil
+}
+
+// latestUserInput returns the text of the last user message, which skills and tools are
+// selected for
+func latestUserInput(messages []services.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// executeWithTools prepares the agent's tools for the last user message and runs the tool loop,
+// as the execution paths do
+func (h *AgentHandlers) executeWithTools(ctx context.Context, agent *models.Agent, messages []services.Message, userID uuid.UUID, run *agentRun) (*services.RouterResponse, []string, error) {
+	return h.executeWithToolLoop(ctx, agent, h.prepareTools(ctx, agent, latestUserInput(messages)), messages, userID, run)
+}
+
+func TestExecuteWithToolLoopOffline(t *testing.T) {
+	mock, server := mockroutertest.NewServer(t)
+	mock.Enqueue(
+		mockrouter.Response{ToolCalls: []mockrouter.ToolCall{{Name: "search_documents", Arguments: `{"query":"meaning of life"}`}}},
+		mockrouter.Response{Content: "The answer is 42."},
+	)
+
+	routerCfg := &config.RouterConfig{BaseURL: server.URL, Timeout: 5, ModelCatalogTTL: 60}
+	mcp := &stubMCPContextService{}
+	h := &AgentHandlers{
+		routerService:        impl.NewRouterService(routerCfg),
+		mcpContextService:    mcp,
+		mcpEnabled:           true,
+		mcpMaxToolIterations: 5,
+	}
+
+	agent := &models.Agent{
+		ID:         uuid.New(),
+		LLMConfig:  models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o"},
+		ToolPolicy: &models.AgentToolPolicy{Choice: models.ToolChoiceRequiredFirst},
+	}
+	messages := []services.Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "What is the meaning of life?"},
+	}
+
+	resp, warnings, err := h.executeWithTools(context.Background(), agent, messages, uuid.New(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "The answer is 42.", resp.Content)
+	assert.Empty(t, warnings)
+
+	require.Len(t, mcp.invoked, 1)
+	assert.Equal(t, "search_documents", mcp.invoked[0].ToolName)
+	assert.Equal(t, "meaning of life", mcp.invoked[0].Parameters["query"])
+
+	requests := mock.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "required", requests[0].ToolChoice, "first iteration forces a tool call")
+	assert.Contains(t, requests[0].Messages[0].Text(), "AVAILABLE TOOLS")
+	assert.Equal(t, "auto", requests[1].ToolChoice)
+
+	// Second request carries the assistant tool call and its result
+	second := requests[1].Messages
+	require.Len(t, second, 4)
+	assert.Equal(t, "assistant", second[2].Role)
+	require.Len(t, second[2].ToolCalls, 1)
+	assert.Equal(t, "tool", second[3].Role)
+	assert.Equal(t, second[2].ToolCalls[0].ID, second[3].ToolCallID)
+	assert.JSONEq(t, `{"answer":"42"}`, second[3].Text())
+}
+
+func TestToolPolicy(t *testing.T) {
+	setup := func(t *testing.T) (*mockrouter.Router, *stubMCPContextService, *AgentHandlers) {
+		mock, server := mockroutertest.NewServer(t)
+		routerCfg := &config.RouterConfig{BaseURL: server.URL, Timeout: 5, ModelCatalogTTL: 60}
+		mcp := &stubMCPContextService{}
+		return mock, mcp, &AgentHandlers{
+			routerService:        impl.NewRouterService(routerCfg),
+			mcpContextService:    mcp,
+			mcpEnabled:           true,
+			mcpMaxToolIterations: 5,
+		}
+	}
+	newMessages := func() []services.Message {
+		return []services.Message{
+			{Role: "system", Content: "You are helpful."},
+			{Role: "user", Content: "Hello"},
+		}
+	}
+	agent := func(policy *models.AgentToolPolicy) *models.Agent {
+		return &models.Agent{ID: uuid.New(), LLMConfig: models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o"}, ToolPolicy: policy}
+	}
+
+	t.Run("default lets the model answer without tools", func(t *testing.T) {
+		mock, _, h := setup(t)
+		mock.Enqueue(mockrouter.Response{Content: "Hi!"})
+		messages := newMessages()
+
+		resp, _, err := h.executeWithTools(context.Background(), agent(nil), messages, uuid.New(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Hi!", resp.Content)
+		requests := mock.Requests()
+		require.Len(t, requests, 1)
+		assert.Equal(t, "auto", requests[0].ToolChoice)
+		assert.Contains(t, requests[0].Messages[0].Text(), "AVAILABLE TOOLS")
+		assert.Equal(t, "You are helpful.", messages[0].Content, "the caller's system message is not changed")
+	})
+
+	t.Run("a named tool and a custom hint", func(t *testing.T) {
+		mock, _, h := setup(t)
+		mock.Enqueue(
+			mockrouter.Response{ToolCalls: []mockrouter.ToolCall{{Name: "search_documents", Arguments: `{"query":"x"}`}}},
+			mockrouter.Response{Content: "Done."},
+		)
+		hint := "Tools: {{tools}}"
+		_, _, err := h.executeWithTools(context.Background(), agent(&models.AgentToolPolicy{Choice: "search_documents", Hint: &hint}), newMessages(), uuid.New(), nil)
+		require.NoError(t, err)
+		requests := mock.Requests()
+		require.Len(t, requests, 2)
+		assert.Equal(t, map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "search_documents"}}, requests[0].ToolChoice)
+		assert.Equal(t, "You are helpful.\n\nTools: search_documents", requests[0].Messages[0].Text())
+		assert.Equal(t, "auto", requests[1].ToolChoice)
+	})
+
+	t.Run("an empty hint adds none", func(t *testing.T) {
+		mock, _, h := setup(t)
+		mock.Enqueue(mockrouter.Response{Content: "Hi!"})
+		hint := ""
+		_, _, err := h.executeWithTools(context.Background(), agent(&models.AgentToolPolicy{Hint: &hint}), newMessages(), uuid.New(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "You are helpful.", mock.Requests()[0].Messages[0].Text())
+	})
+
+	t.Run("none offers no tools", func(t *testing.T) {
+		mock, _, h := setup(t)
+		mock.Enqueue(mockrouter.Response{Content: "Hi!"})
+		_, _, err := h.executeWithTools(context.Background(), agent(&models.AgentToolPolicy{Choice: models.ToolChoiceNone}), newMessages(), uuid.New(), nil)
+		require.NoError(t, err)
+		requests := mock.Requests()
+		require.Len(t, requests, 1)
+		assert.Empty(t, requests[0].ToolNames())
+		assert.Equal(t, "You are helpful.", requests[0].Messages[0].Text())
+	})
+
+	t.Run("calls beyond the budget are refused", func(t *testing.T) {
+		mock, mcp, h := setup(t)
+		mock.Enqueue(
+			mockrouter.Response{ToolCalls: []mockrouter.ToolCall{
+				{Name: "search_documents", Arguments: `{"query":"a"}`},
+				{Name: "search_documents", Arguments: `{"query":"b"}`},
+			}},
+			mockrouter.Response{Content: "Done."},
+		)
+		resp, warnings, err := h.executeWithTools(context.Background(), agent(&models.AgentToolPolicy{MaxToolCalls: 1}), newMessages(), uuid.New(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Done.", resp.Content)
+		require.Len(t, mcp.invoked, 1)
+		assert.Equal(t, "a", mcp.invoked[0].Parameters["query"])
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "budget of 1 tool calls")
+
+		requests := mock.Requests()
+		require.Len(t, requests, 2)
+		assert.Equal(t, "none", requests[1].ToolChoice, "the spent budget makes the model answer")
+		last := requests[1].Messages[len(requests[1].Messages)-1]
+		assert.Equal(t, toolBudgetMessage, last.Text())
+	})
+}
+
+func TestMemoryMessagesIncludeWorkingMemory(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	memorySvc := memory.NewMemoryService(client, &config.DeepLakeConfig{}, &config.RouterConfig{}, nil)
+	h := &AgentHandlers{memoryService: memorySvc}
+
+	ctx := context.Background()
+	agentID := uuid.New()
+	require.NoError(t, memorySvc.UpdateWorkingMemory(ctx, "session-1", agentID, []models.RetrievedChunk{
+		{DocumentName: "handbook.pdf", Content: "Expenses over 500 EUR need approval."},
+	}))
+	require.NoError(t, memorySvc.AddMemory(ctx, models.AddMemoryRequest{
+		SessionID: "session-1", AgentID: agentID, Role: "user", Content: "What is the expense policy?",
+	}))
+
+	state, err := memorySvc.GetMemoryState(ctx, models.GetMemoryRequest{
+		SessionID:    "session-1",
+		AgentID:      agentID,
+		IncludeTypes: []models.MemoryType{models.MemoryTypeShortTerm, models.MemoryTypeWorking},
+	})
+	require.NoError(t, err)
+	plan := budget.NewPlanner(nil).Plan(models.TokenBudgetRequest{
+		ContextWindow:       8192,
+		HistoryDemand:       state.ShortTerm.TotalTokens,
+		WorkingMemoryDemand: state.Working.TotalTokens,
+	})
+	require.Positive(t, plan.WorkingMemory)
+
+	messages, memoryCtx, err := h.memoryMessages(ctx, models.GetMemoryRequest{SessionID: "session-1", AgentID: agentID}, plan)
+	require.NoError(t, err)
+	require.NotNil(t, memoryCtx)
+
+	var prompt string
+	for _, msg := range messages {
+		prompt += msg.Content + "\n"
+	}
+	assert.Contains(t, prompt, "Expenses over 500 EUR need approval.", "working memory reaches the prompt")
+	assert.Contains(t, prompt, "What is the expense policy?")
+}
+
+func TestPlanTokenBudgetCountsToolsAndImages(t *testing.T) {
+	h := &AgentHandlers{
+		mcpContextService: &stubMCPContextService{},
+		mcpEnabled:        true,
+	}
+	tok := tokenizer.Get(tokenizer.DefaultEncoding)
+	agent := &models.Agent{
+		ID:           uuid.New(),
+		SystemPrompt: "You are helpful.",
+		LLMConfig:    models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o"},
+	}
+	req := models.ExecutionContextRequest{Input: "Describe these pictures."}
+
+	plain := h.planTokenBudget(agent, nil, req, tok, nil, 0, 0, 0, 0)
+	assert.Equal(t, tok.Count(agent.SystemPrompt), plain.SystemPrompt, "agents without tools get no hint")
+	assert.Zero(t, plain.Overflow)
+	assert.NoError(t, budgetOverflow(plain))
+
+	tools := h.prepareTools(context.Background(), agent, req.Input)
+	require.Len(t, tools.tools, 1)
+	schemas, _ := json.Marshal(tools.tools)
+	plan := h.planTokenBudget(agent, nil, req, tok, tools, 0, 0, 0, 5)
+	assert.Equal(t, plain.SystemPrompt+tok.Count("\n\n"+tools.hint)+tok.Count(string(schemas)), plan.SystemPrompt, "the hint and tool schemas are counted")
+	assert.Equal(t, plain.Input+5*imageTokenEstimate, plan.Input)
+	assert.Positive(t, plan.Overflow, "the images do not fit the default window")
+	assert.ErrorContains(t, budgetOverflow(plan), "context window")
+
+	agent.ToolPolicy = &models.AgentToolPolicy{Hint: new(string)}
+	tools = h.prepareTools(context.Background(), agent, req.Input)
+	plan = h.planTokenBudget(agent, nil, req, tok, tools, 0, 0, 0, 0)
+	assert.Equal(t, plain.SystemPrompt+tok.Count(string(schemas)), plan.SystemPrompt, "a disabled hint is not counted")
+}
+
+func TestDirectBackendAgentsPlanWithBackendCapabilities(t *testing.T) {
+	// Neither the router nor the backend is reachable; the backend's configured model is enough
+	unreachable := "http://127.0.0.1:1"
+	h := &AgentHandlers{routerService: impl.NewBackendRouterService(
+		impl.NewRouterService(&config.RouterConfig{BaseURL: unreachable, Timeout: 1}),
+		map[string]services.LLMBackend{"openai": impl.NewOpenAIBackend(config.OpenAIBackendConfig{
+			BaseURL: unreachable,
+			Models:  map[string]config.BackendModelConfig{"local-llama": {ContextWindow: 32768, Tokenizer: "cl100k_base"}},
+		}, time.Second)},
+		0, "",
+	)}
+	agent := &models.Agent{
+		ID:           uuid.New(),
+		SystemPrompt: "You are helpful.",
+		LLMConfig:    models.AgentLLMConfig{Provider: "openai", Backend: "openai", Model: "local-llama"},
+	}
+
+	caps := h.modelCapabilities(context.Background(), agent)
+	require.NotNil(t, caps)
+	tok := tokenizerFor(agent, caps)
+	plan := h.planTokenBudget(agent, caps, models.ExecutionContextRequest{Input: "Hello"}, tok, nil, 0, 0, 0, 0)
+	assert.Equal(t, 32768, plan.ContextWindow, "not the planner's default")
+	assert.Equal(t, tokenizer.Get("cl100k_base").Count("Hello"), plan.Input)
+}
+
+func TestOutputReserveIsSentAsMaxTokens(t *testing.T) {
+	mock, server := mockroutertest.NewServer(t)
+	mock.Enqueue(mockrouter.Response{Content: "Hi."})
+	h := &AgentHandlers{routerService: impl.NewRouterService(&config.RouterConfig{BaseURL: server.URL, Timeout: 5, ModelCatalogTTL: 60})}
+
+	configured := 4096
+	agent := &models.Agent{
+		ID:              uuid.New(),
+		SystemPrompt:    "You are helpful.",
+		LLMConfig:       models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o", MaxTokens: &configured},
+		DocumentContext: &models.DocumentContextConfig{TokenBudget: &models.TokenBudgetConfig{OutputReserve: 500}},
+	}
+	tok := tokenizer.Get(tokenizer.DefaultEncoding)
+	plan := h.planTokenBudget(agent, nil, models.ExecutionContextRequest{Input: "Hello"}, tok, nil, 0, 0, 0, 0)
+	require.Equal(t, 500, plan.OutputReserve, "the configured reserve wins over max_tokens")
+
+	budgeted := withOutputReserve(agent, plan.OutputReserve)
+	_, err := h.routerService.SendRequest(context.Background(), budgeted.LLMConfig, []services.Message{{Role: "user", Content: "Hello"}}, uuid.New())
+	require.NoError(t, err)
+	requests := mock.Requests()
+	require.Len(t, requests, 1)
+	require.NotNil(t, requests[0].MaxTokens)
+	assert.Equal(t, 500, *requests[0].MaxTokens)
+	assert.Equal(t, 4096, *agent.LLMConfig.MaxTokens, "the agent's own config is left alone")
+}
+
+// recordingDocumentContext records the vector and hybrid retrievals it is asked for
+type recordingDocumentContext struct {
+	services.DocumentContextService
+	vector []models.VectorSearchRequest
+	hybrid []models.ChunkRetrievalRequest
+}
+
+func (d *recordingDocumentContext) RetrieveVectorContext(ctx context.Context, req models.VectorSearchRequest) (*models.DocumentContextResult, error) {
+	d.vector = append(d.vector, req)
+	return &models.DocumentContextResult{}, nil
+}
+
+func (d *recordingDocumentContext) RetrieveHybridContext(ctx context.Context, query string, req models.ChunkRetrievalRequest, vectorWeight, fullDocWeight float64) (*models.DocumentContextResult, error) {
+	d.hybrid = append(d.hybrid, req)
+	return &models.DocumentContextResult{}, nil
+}
+
+func TestRetrievalHonorsDocumentScope(t *testing.T) {
+	docs := &recordingDocumentContext{}
+	h := &AgentHandlers{documentContextService: docs}
+	ctx := context.Background()
+	notebooks := []uuid.UUID{uuid.New()}
+	defaultDoc, selectedDoc := uuid.New(), uuid.New()
+	agent := &models.Agent{DocumentContext: &models.DocumentContextConfig{
+		Scope:               models.DocumentScopeSelected,
+		DefaultDocuments:    []uuid.UUID{defaultDoc},
+		IncludeSubNotebooks: true,
+	}}
+
+	// Without a selection the agent's default documents are searched
+	_, err := h.retrieveVectorContext(ctx, agent, models.ExecutionContextRequest{Input: "q"}, notebooks)
+	require.NoError(t, err)
+	require.Len(t, docs.vector, 1)
+	assert.Equal(t, []uuid.UUID{defaultDoc}, docs.vector[0].DocumentIDs)
+	assert.True(t, docs.vector[0].IncludeSubNotebooks)
+
+	// The request's selection replaces them, in hybrid retrieval too, which searches sub-notebooks as well
+	req := models.ExecutionContextRequest{Input: "q", SelectedDocuments: []uuid.UUID{selectedDoc}}
+	_, err = h.retrieveHybridContext(ctx, agent, req, notebooks)
+	require.NoError(t, err)
+	require.Len(t, docs.hybrid, 1)
+	assert.Equal(t, []uuid.UUID{selectedDoc}, docs.hybrid[0].FileIDs)
+	assert.True(t, docs.hybrid[0].IncludeSubNotebooks)
+
+	// An agent without documents searches nothing
+	agent.DocumentContext.Scope = models.DocumentScopeNone
+	result, err := h.retrieveVectorContext(ctx, agent, req, notebooks)
+	require.NoError(t, err)
+	assert.Empty(t, result.Chunks)
+	assert.Len(t, docs.vector, 1, "no search is made")
+}
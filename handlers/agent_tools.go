@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
+)
+
+const (
+	// agentToolPrefix starts the names of tools that call agents
+	agentToolPrefix = "agent_"
+
+	// defaultMaxAgentDepth bounds how deep agents calling agents may nest when none is configured
+	defaultMaxAgentDepth = 3
+)
+
+// agentToolNameInvalid matches runs of characters not allowed in tool names
+var agentToolNameInvalid = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// agentToolName derives the name of the tool that calls an agent from the agent's name
+func agentToolName(agent *models.Agent) string {
+	slug := strings.Trim(agentToolNameInvalid.ReplaceAllString(strings.ToLower(agent.Name), "_"), "_-")
+	if slug == "" {
+		slug = agent.ID.String()[:8]
+	}
+	name := agentToolPrefix + slug
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// agentToolSchema is the input schema of every agent tool
+func agentToolSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"input": map[string]interface{}{
+				"type":        "string",
+				"description": "The complete request for the agent; it sees nothing else of this conversation",
+			},
+		},
+		"required": []interface{}{"input"},
+	}
+}
+
+type agentChainKey struct{}
+
+// agentChain returns the agents of the executions that led to this one, outermost first,
+// ending with agent itself
+func agentChain(ctx context.Context, agent *models.Agent) []uuid.UUID {
+	if chain, ok := ctx.Value(agentChainKey{}).([]uuid.UUID); ok && len(chain) > 0 {
+		return chain
+	}
+	return []uuid.UUID{agent.ID}
+}
+
+// withAgentChain returns a context for the execution of target, called by the last agent in chain
+func withAgentChain(ctx context.Context, chain []uuid.UUID, target uuid.UUID) context.Context {
+	next := make([]uuid.UUID, len(chain), len(chain)+1)
+	copy(next, chain)
+	return context.WithValue(ctx, agentChainKey{}, append(next, target))
+}
+
+// maxAgentDepth is the number of agents that may be called below the one a user executed
+func (h *AgentHandlers) maxAgentDepth() int {
+	if h.mcpMaxAgentDepth <= 0 {
+		return defaultMaxAgentDepth
+	}
+	return h.mcpMaxAgentDepth
+}
+
+// skillAgents loads the agents of an agent skill that the caller may execute. Agents the caller
+// cannot access are left out.
+func (h *AgentHandlers) skillAgents(ctx context.Context, skill *models.Skill) ([]*models.Agent, error) {
+	caller, ok := services.CallerFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("agent tools are only available to authenticated callers")
+	}
+	var ids []uuid.UUID
+	if len(skill.AgentIDs) > 0 {
+		if err := json.Unmarshal(skill.AgentIDs, &ids); err != nil {
+			return nil, fmt.Errorf("invalid agent_ids: %w", err)
+		}
+	}
+
+	agents := make([]*models.Agent, 0, len(ids))
+	for _, id := range ids {
+		agent, err := h.agentService.GetAgent(ctx, id, caller.UserID.String())
+		if err != nil {
+			log.Printf("[AGENT-TOOLS] Agent %s of skill %q is not available to user %s: %v", id, skill.Name, caller.UserID, err)
+			continue
+		}
+		agents = append(agents, agent)
+	}
+	return agents, nil
+}
+
+// agentSkillTools lists a tool for each agent of the skill that agent may call. Agents already
+// running in the chain of executions are left out, and none are offered once the chain is as
+// deep as allowed.
+func (h *AgentHandlers) agentSkillTools(ctx context.Context, agent *models.Agent, skill *models.Skill) (*models.SkillToolList, error) {
+	chain := agentChain(ctx, agent)
+	if len(chain) > h.maxAgentDepth() {
+		return nil, fmt.Errorf("the limit of %d nested agent calls is reached", h.maxAgentDepth())
+	}
+
+	targets, err := h.skillAgents(ctx, skill)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.SkillToolList{SkillID: skill.ID, FetchedAt: time.Now()}
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if containsAgent(chain, target.ID) {
+			continue
+		}
+		name := agentToolName(target)
+		if seen[name] {
+			log.Printf("[AGENT-TOOLS] Agent %s of skill %q is not offered: another agent already has tool name %s", target.ID, skill.Name, name)
+			continue
+		}
+		seen[name] = true
+
+		description := target.Description
+		if description == "" {
+			description = fmt.Sprintf("Ask the %s agent", target.Name)
+		}
+		list.Tools = append(list.Tools, models.SkillTool{
+			Name:             name,
+			Description:      description,
+			InputSchema:      agentToolSchema(),
+			RequiresApproval: skill.ToolRequiresApproval(name),
+		})
+	}
+	return list, nil
+}
+
+// callAgentTool runs the agent behind an agent tool on the call's input and returns its answer.
+// The agent runs as the caller, with its own context strategy and LLM configuration, and its
+// execution is linked to parent's.
+func (h *AgentHandlers) callAgentTool(ctx context.Context, agent *models.Agent, skill *models.Skill, name string, args map[string]interface{}, parent *agentRun) (string, error) {
+	input, _ := args["input"].(string)
+	if strings.TrimSpace(input) == "" {
+		return "", fmt.Errorf("input is required")
+	}
+
+	chain := agentChain(ctx, agent)
+	if len(chain) > h.maxAgentDepth() {
+		return "", fmt.Errorf("the limit of %d nested agent calls is reached", h.maxAgentDepth())
+	}
+
+	targets, err := h.skillAgents(ctx, skill)
+	if err != nil {
+		return "", err
+	}
+	var target *models.Agent
+	for _, t := range targets {
+		if agentToolName(t) == name {
+			target = t
+			break
+		}
+	}
+	if target == nil {
+		return "", fmt.Errorf("no agent of skill %q answers to %s", skill.Name, name)
+	}
+	if containsAgent(chain, target.ID) {
+		return "", fmt.Errorf("agent %q is already running in this execution and cannot be called again", target.Name)
+	}
+
+	if skill.ToolTimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(skill.ToolTimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	return h.runNestedAgent(withAgentChain(ctx, chain, target.ID), target, input, parent)
+}
+
+// runNestedAgent runs agent's execution pipeline on input for the caller in ctx, recording it as
+// a child of parent's execution and adding its cost to parent's agent calls. Nested executions
+// have no session, and tools in them that require approval are refused.
+func (h *AgentHandlers) runNestedAgent(ctx context.Context, agent *models.Agent, input string, parent *agentRun) (string, error) {
+	caller, _ := services.CallerFromContext(ctx)
+	userStr := caller.UserID.String()
+	startTime := time.Now()
+
+	var parentCalls *agentCalls
+	if parent != nil {
+		parentCalls = parent.AgentCalls
+	}
+
+	variant := agent.Experiment.Assign(experimentStickyKey(nil, userStr))
+	agent = agent.WithVariant(variant)
+
+	// Build the agent's context the way a direct execution does
+	req := models.ExecutionContextRequest{Input: input}
+	caps := h.modelCapabilities(ctx, agent)
+	tok := tokenizerFor(agent, caps)
+	ctx = services.WithModelCapabilities(tokenizer.WithTokenizer(ctx, tok), caps)
+	useMCPTools := h.usesToolLoop(agent)
+	var tools *toolSet
+	if useMCPTools {
+		tools = h.prepareTools(ctx, agent, input)
+	}
+	budgetPlan := h.planTokenBudget(agent, caps, req, tok, tools, 0, 0, 0, 0)
+	if err := budgetOverflow(budgetPlan); err != nil {
+		return "", fmt.Errorf("agent %q cannot take the input: %w", agent.Name, err)
+	}
+	agent = withOutputReserve(agent, budgetPlan.OutputReserve)
+	systemPrompt, contextMetadata := h.buildSystemPromptWithContext(ctx, agent, req, budgetPlan.Documents)
+	contextMetadata["token_budget"] = budgetPlan.ToMetadata()
+
+	messages := []services.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: input},
+	}
+
+	run := &agentRun{
+		UserID:          userStr,
+		TenantID:        caller.TenantID,
+		Input:           input,
+		ContextMetadata: contextMetadata,
+		startedAt:       startTime,
+		nested:          true,
+	}
+	if variant != nil {
+		run.Experiment = agent.Experiment.Name
+		run.Variant = variant.Name
+	}
+
+	executionReq := models.StartExecutionRequest{
+		AgentID: agent.ID,
+		InputData: map[string]any{
+			"input":            input,
+			"messages":         messagesForStorage(messages),
+			"context_metadata": contextMetadata,
+		},
+	}
+	if parent != nil && parent.ExecutionID != uuid.Nil {
+		executionReq.ParentExecutionID = &parent.ExecutionID
+	}
+	if variant != nil {
+		executionReq.Experiment = &agent.Experiment.Name
+		executionReq.Variant = &variant.Name
+	}
+	if h.executionService != nil {
+		execution, err := h.executionService.StartExecution(ctx, executionReq, caller.UserID)
+		if err != nil {
+			// Log but don't fail - execution tracking is non-critical
+			log.Printf("[AGENT-TOOLS] Failed to create execution record for agent %s: %v", agent.ID, err)
+		} else {
+			run.ExecutionID = execution.ID
+		}
+	}
+
+	log.Printf("[AGENT-TOOLS] Running agent %s (%s) at depth %d", agent.Name, agent.ID, len(agentChain(ctx, agent))-1)
+
+	var response *services.RouterResponse
+	var skillWarnings []string
+	var err error
+	if useMCPTools {
+		response, skillWarnings, err = h.executeWithToolLoop(ctx, agent, tools, messages, caller.UserID, run)
+	} else {
+		response, err = h.routerService.SendRequest(ctx, agent.LLMConfig, messages, caller.UserID)
+	}
+
+	call := childExecution{AgentID: agent.ID, AgentName: agent.Name, DurationMs: run.elapsedMs()}
+	if run.ExecutionID != uuid.Nil {
+		call.ExecutionID = run.ExecutionID.String()
+	}
+
+	// Record the outcome even when the calling request has gone away
+	recordCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		call.Status = models.ExecutionStatusFailed
+		call.CostUSD, call.TokensUsed = run.AgentCalls.totals()
+		parentCalls.add(call)
+		if run.ExecutionID != uuid.Nil {
+			errorMsg := err.Error()
+			h.executionService.CompleteExecution(recordCtx, run.ExecutionID, models.ExecutionStatusFailed, nil, &errorMsg, call.DurationMs)
+		}
+		return "", fmt.Errorf("agent %q failed: %w", agent.Name, err)
+	}
+
+	cost, tokens := run.AgentCalls.totals()
+	call.Status = models.ExecutionStatusCompleted
+	call.CostUSD = response.CostUSD + cost
+	call.TokensUsed = response.TokenUsage + tokens
+	parentCalls.add(call)
+	if run.ExecutionID != uuid.Nil {
+		h.executionService.CompleteExecution(recordCtx, run.ExecutionID, models.ExecutionStatusCompleted, executionOutput(run, response, skillWarnings, useMCPTools), nil, call.DurationMs)
+	}
+
+	log.Printf("[AGENT-TOOLS] Agent %s answered in %dms (cost $%.6f with its own agent calls)", agent.Name, call.DurationMs, call.CostUSD)
+	return response.Content, nil
+}
+
+func containsAgent(chain []uuid.UUID, id uuid.UUID) bool {
+	for _, c := range chain {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// childExecution is the execution of an agent called as a tool. Its cost and tokens include
+// the agents it called in turn.
+type childExecution struct {
+	AgentID     uuid.UUID              `json:"agent_id"`
+	AgentName   string                 `json:"agent_name"`
+	ExecutionID string                 `json:"execution_id,omitempty"` // Empty when no record could be created
+	Status      models.ExecutionStatus `json:"status"`
+	CostUSD     float64                `json:"cost_usd"`
+	TokensUsed  int                    `json:"tokens_used"`
+	DurationMs  int                    `json:"duration_ms"`
+}
+
+// agentCalls collects the agents a run called as tools, which may run concurrently
+type agentCalls struct {
+	mu    sync.Mutex
+	Calls []childExecution `json:"calls"`
+}
+
+// add records a call; calls made without a run to roll up into are dropped
+func (a *agentCalls) add(call childExecution) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Calls = append(a.Calls, call)
+}
+
+// list returns a copy of the calls recorded so far
+func (a *agentCalls) list() []childExecution {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]childExecution(nil), a.Calls...)
+}
+
+// totals sums the cost and tokens of the calls
+func (a *agentCalls) totals() (costUSD float64, tokens int) {
+	for _, call := range a.list() {
+		costUSD += call.CostUSD
+		tokens += call.TokensUsed
+	}
+	return costUSD, tokens
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/mockrouter"
+	"github.com/tas-agent-builder/mockrouter/mockroutertest"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/impl"
+)
+
+// stubAgentDirectory serves a fixed set of agents to every user
+type stubAgentDirectory struct {
+	services.AgentService
+	agents map[uuid.UUID]*models.Agent
+}
+
+func (s *stubAgentDirectory) GetAgent(ctx context.Context, id uuid.UUID, userID string) (*models.Agent, error) {
+	agent, ok := s.agents[id]
+	if !ok {
+		return nil, errors.New("agent not found or access denied")
+	}
+	return agent, nil
+}
+
+// stubAgentSkills assigns skills to agents by ID
+type stubAgentSkills struct {
+	services.SkillService
+	skills map[uuid.UUID][]models.Skill
+}
+
+func (s *stubAgentSkills) ResolveForAgent(ctx context.Context, agent *models.Agent, input string) ([]models.Skill, error) {
+	return s.skills[agent.ID], nil
+}
+
+// recordingExecutions keeps started executions and their outcomes in memory
+type recordingExecutions struct {
+	services.ExecutionService
+	mu       sync.Mutex
+	started  map[uuid.UUID]models.StartExecutionRequest
+	statuses map[uuid.UUID]models.ExecutionStatus
+	outputs  map[uuid.UUID]map[string]any
+}
+
+func (s *recordingExecutions) StartExecution(ctx context.Context, req models.StartExecutionRequest, userID uuid.UUID) (*models.AgentExecution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	execution := &models.AgentExecution{ID: uuid.New(), AgentID: req.AgentID, UserID: userID, ParentExecutionID: req.ParentExecutionID}
+	s.started[execution.ID] = req
+	return execution, nil
+}
+
+func (s *recordingExecutions) CompleteExecution(ctx context.Context, id uuid.UUID, status models.ExecutionStatus, outputData map[string]any, errorMsg *string, durationMs int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[id] = status
+	s.outputs[id] = outputData
+	return nil
+}
+
+func TestAgentTools(t *testing.T) {
+	llm := models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o"}
+	orchestrator := &models.Agent{ID: uuid.New(), Name: "Orchestrator", LLMConfig: llm, Skills: []byte(`["specialists"]`)}
+	sqlAgent := &models.Agent{
+		ID:           uuid.New(),
+		Name:         "SQL Query Assistant",
+		Description:  "Writes and explains SQL queries",
+		SystemPrompt: "You write SQL.",
+		LLMConfig:    llm,
+	}
+	agentIDs, _ := json.Marshal([]uuid.UUID{sqlAgent.ID, orchestrator.ID})
+	specialists := models.Skill{ID: uuid.New(), Name: "specialists", Type: models.SkillTypeAgent, AgentIDs: agentIDs}
+
+	userID := uuid.New()
+	ctx := services.WithCaller(context.Background(), services.Caller{TenantID: "tenant-1", UserID: userID})
+	messages := []services.Message{
+		{Role: "system", Content: "You coordinate specialists."},
+		{Role: "user", Content: "How many orders were placed today?"},
+	}
+
+	setup := func(t *testing.T, maxDepth int) (*mockrouter.Router, *recordingExecutions, *AgentHandlers) {
+		mock, server := mockroutertest.NewServer(t)
+		routerCfg := &config.RouterConfig{BaseURL: server.URL, Timeout: 5, ModelCatalogTTL: 60}
+		executions := &recordingExecutions{
+			started:  make(map[uuid.UUID]models.StartExecutionRequest),
+			statuses: make(map[uuid.UUID]models.ExecutionStatus),
+			outputs:  make(map[uuid.UUID]map[string]any),
+		}
+		return mock, executions, &AgentHandlers{
+			agentService:         &stubAgentDirectory{agents: map[uuid.UUID]*models.Agent{orchestrator.ID: orchestrator, sqlAgent.ID: sqlAgent}},
+			routerService:        impl.NewRouterService(routerCfg),
+			executionService:     executions,
+			mcpContextService:    &stubMCPContextService{},
+			skillTools:           &stubSkillTools{},
+			skillService:         &stubAgentSkills{skills: map[uuid.UUID][]models.Skill{orchestrator.ID: {specialists}, sqlAgent.ID: {specialists}}},
+			mcpEnabled:           true,
+			mcpMaxToolIterations: 5,
+			mcpMaxAgentDepth:     maxDepth,
+		}
+	}
+
+	t.Run("the called agent runs as a child execution", func(t *testing.T) {
+		mock, executions, h := setup(t, 0)
+		mock.Enqueue(
+			mockrouter.Response{ToolCalls: []mockrouter.ToolCall{{Name: "agent_sql_query_assistant", Arguments: `{"input":"Count today's orders"}`}}, PromptTokens: 50, CompletionTokens: 10},
+			mockrouter.Response{Content: "SELECT count(*) FROM orders WHERE created_at >= current_date; -- 42", PromptTokens: 30, CompletionTokens: 20},
+			mockrouter.Response{Content: "42 orders were placed today.", PromptTokens: 80, CompletionTokens: 10},
+		)
+		parentID := uuid.New()
+		run := &agentRun{ExecutionID: parentID, UserID: userID.String(), startedAt: time.Now()}
+
+		resp, warnings, err := h.executeWithTools(ctx, orchestrator, messages, userID, run)
+		require.NoError(t, err)
+		assert.Equal(t, "42 orders were placed today.", resp.Content)
+		assert.Empty(t, warnings)
+
+		requests := mock.Requests()
+		require.Len(t, requests, 3)
+		assert.Equal(t, []string{"agent_sql_query_assistant"}, requests[0].ToolNames(), "the orchestrator is not offered to itself")
+		assert.Equal(t, "You write SQL.", requests[1].Messages[0].Text(), "the child uses its own system prompt")
+		assert.Equal(t, "Count today's orders", requests[1].Messages[len(requests[1].Messages)-1].Text())
+		assert.Empty(t, requests[1].ToolNames(), "the child may not call back into the chain")
+
+		calls := run.AgentCalls.list()
+		require.Len(t, calls, 1)
+		assert.Equal(t, sqlAgent.ID, calls[0].AgentID)
+		assert.Equal(t, models.ExecutionStatusCompleted, calls[0].Status)
+		assert.Equal(t, 50, calls[0].TokensUsed)
+
+		childID := uuid.MustParse(calls[0].ExecutionID)
+		require.NotNil(t, executions.started[childID].ParentExecutionID)
+		assert.Equal(t, parentID, *executions.started[childID].ParentExecutionID)
+		assert.Equal(t, models.ExecutionStatusCompleted, executions.statuses[childID])
+
+		output := executionOutput(run, resp, warnings, true)
+		assert.Equal(t, 90, output["tokens_used"])
+		assert.Equal(t, 140, output["total_tokens_used"])
+	})
+
+	t.Run("agents are not offered past the depth limit", func(t *testing.T) {
+		mock, _, h := setup(t, 1)
+		other := &models.Agent{ID: uuid.New(), Name: "Q&A Generator", LLMConfig: llm}
+		h.agentService.(*stubAgentDirectory).agents[other.ID] = other
+		ids, _ := json.Marshal([]uuid.UUID{sqlAgent.ID, other.ID})
+		skill := specialists
+		skill.AgentIDs = ids
+		h.skillService = &stubAgentSkills{skills: map[uuid.UUID][]models.Skill{orchestrator.ID: {skill}, sqlAgent.ID: {skill}}}
+
+		mock.Enqueue(
+			mockrouter.Response{ToolCalls: []mockrouter.ToolCall{{Name: "agent_sql_query_assistant", Arguments: `{"input":"Count today's orders"}`}}},
+			mockrouter.Response{Content: "42"},
+			mockrouter.Response{Content: "42 orders."},
+		)
+		run := &agentRun{ExecutionID: uuid.New(), UserID: userID.String(), startedAt: time.Now()}
+
+		_, _, err := h.executeWithTools(ctx, orchestrator, messages, userID, run)
+		require.NoError(t, err)
+
+		requests := mock.Requests()
+		require.Len(t, requests, 3)
+		assert.ElementsMatch(t, []string{"agent_sql_query_assistant", "agent_q_a_generator"}, requests[0].ToolNames())
+		assert.Empty(t, requests[1].ToolNames(), "the child is at the depth limit")
+
+		_, err = h.callAgentTool(withAgentChain(ctx, []uuid.UUID{orchestrator.ID}, sqlAgent.ID), sqlAgent, &skill, "agent_q_a_generator", map[string]interface{}{"input": "hi"}, run)
+		assert.ErrorContains(t, err, "limit of 1 nested agent calls")
+	})
+}
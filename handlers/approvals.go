@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
+)
+
+// approvalUnavailableMessage answers calls needing approval in executions that cannot pause
+const approvalUnavailableMessage = "This tool requires a user's approval, which is not available for this execution, so it was not run."
+
+// toolLoopState is what an execution paused for approval resumes from: the conversation up to
+// the assistant turn whose tool calls wait for a decision. Its parts are storage references,
+// resolved again on resume.
+type toolLoopState struct {
+	Messages  []services.Message `json:"messages"`
+	Iteration int                `json:"iteration"`
+	Warnings  []string           `json:"warnings,omitempty"`
+	Run       agentRun           `json:"run"`
+}
+
+// awaitingApprovalError stops the tool loop once its state is saved
+type awaitingApprovalError struct {
+	pending []models.PendingToolCall
+}
+
+func (e *awaitingApprovalError) Error() string {
+	return fmt.Sprintf("%d tool calls are awaiting approval", len(e.pending))
+}
+
+// toolDecision is a user's answer to a tool call that needed approval
+type toolDecision struct {
+	Refusal   string         // Tool message for a call that was not run; empty when approved
+	Arguments map[string]any // Arguments the user edited before approving
+	Actor     string         // User who decided; empty when the call was refused automatically
+}
+
+// approval is the audited form of the decision
+func (d toolDecision) approval() string {
+	switch {
+	case d.Actor == "":
+		return ""
+	case d.Refusal != "":
+		return models.ToolCallRejected
+	case d.Arguments != nil:
+		return models.ToolCallApprovedWithEdits
+	}
+	return models.ToolCallApproved
+}
+
+// pendingToolCalls returns the calls whose skill requires approval for the tool. Calls with
+// invalid arguments are left out; they are answered with the violations without being run.
+func (l *toolLoop) pendingToolCalls(calls []services.ToolCall) []models.PendingToolCall {
+	var pending []models.PendingToolCall
+	for _, tc := range calls {
+		skill := l.toolSkills[tc.Function.Name]
+		if skill == nil || !skill.ToolRequiresApproval(tc.Function.Name) {
+			continue
+		}
+		args, violations := l.parseArguments(tc)
+		if len(violations) > 0 {
+			continue
+		}
+		pending = append(pending, models.PendingToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: args,
+			SkillID:   skill.ID,
+			SkillName: skill.Name,
+		})
+	}
+	return pending
+}
+
+// pauseForApproval saves the loop with its execution, which then waits for a decision
+func (h *AgentHandlers) pauseForApproval(ctx context.Context, loop *toolLoop, iteration int, pending []models.PendingToolCall) error {
+	run := *loop.run
+	run.ElapsedMs = run.elapsedMs()
+	state, err := json.Marshal(toolLoopState{
+		Messages:  messagesForStorage(loop.messages),
+		Iteration: iteration,
+		Warnings:  loop.warnings,
+		Run:       run,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode tool loop state: %w", err)
+	}
+	if err := h.executionService.SuspendExecution(ctx, run.ExecutionID, pending, state); err != nil {
+		return fmt.Errorf("failed to pause execution for approval: %w", err)
+	}
+
+	log.Printf("[MCP-TOOLS] Execution %s awaiting approval of %d tool calls", run.ExecutionID, len(pending))
+	return &awaitingApprovalError{pending: pending}
+}
+
+// runDecidedToolCalls runs one assistant turn's tool calls, with edited arguments where the user
+// gave them, and answers refused calls with their refusal. Calls without a decision run as issued.
+func (h *AgentHandlers) runDecidedToolCalls(ctx context.Context, agent *models.Agent, calls []services.ToolCall, loop *toolLoop, decisions map[string]toolDecision) []services.Message {
+	loop.decisions = decisions
+	run := make([]services.ToolCall, 0, len(calls))
+	edited := make(map[string]string)
+	for _, tc := range calls {
+		d := decisions[tc.ID]
+		if d.Refusal != "" {
+			continue
+		}
+		if d.Arguments != nil {
+			args, _ := json.Marshal(d.Arguments)
+			tc.Function.Arguments = string(args)
+			edited[tc.ID] = string(args)
+		}
+		run = append(run, tc)
+	}
+	results := h.executeToolCalls(ctx, agent, run, loop)
+
+	messages := make([]services.Message, 0, len(calls))
+	next := 0
+	for _, tc := range calls {
+		if refusal := decisions[tc.ID].Refusal; refusal != "" {
+			h.auditToolCall(ctx, agent, loop, tc, &models.ExecutionToolCall{Status: models.ToolCallStatusRejected, Result: refusal})
+			messages = append(messages, services.Message{Role: "tool", Content: refusal, ToolCallID: tc.ID})
+			continue
+		}
+		msg := results[next]
+		next++
+		if args, ok := edited[tc.ID]; ok {
+			msg.Content = fmt.Sprintf("The user approved this call with edited arguments: %s\n\n%s", args, msg.Content)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// rejectedToolMessage tells the model a user declined a tool call
+func rejectedToolMessage(reason string) string {
+	msg := "The user rejected this tool call, so it was not run."
+	if reason != "" {
+		msg += " Reason: " + reason
+	}
+	return msg
+}
+
+// ApproveExecution runs the pending tool calls of an execution awaiting approval and resumes it.
+// Calls left out of tool_call_ids are rejected; arguments replaces the model's arguments.
+func (h *AgentHandlers) ApproveExecution(c *gin.Context) {
+	var req models.ApproveToolCallsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	h.resumeExecution(c, func(pending []models.PendingToolCall) (map[string]toolDecision, error) {
+		known := make(map[string]bool, len(pending))
+		for _, p := range pending {
+			known[p.ID] = true
+		}
+		approved := make(map[string]bool, len(req.ToolCallIDs))
+		for _, id := range req.ToolCallIDs {
+			if !known[id] {
+				return nil, fmt.Errorf("tool call %s is not pending", id)
+			}
+			approved[id] = true
+		}
+		for id := range req.Arguments {
+			if !known[id] {
+				return nil, fmt.Errorf("tool call %s is not pending", id)
+			}
+			if len(approved) > 0 && !approved[id] {
+				return nil, fmt.Errorf("arguments given for tool call %s, which is not approved", id)
+			}
+		}
+
+		decisions := make(map[string]toolDecision, len(pending))
+		for _, p := range pending {
+			if len(approved) == 0 || approved[p.ID] {
+				decisions[p.ID] = toolDecision{Arguments: req.Arguments[p.ID]}
+			} else {
+				decisions[p.ID] = toolDecision{Refusal: rejectedToolMessage("")}
+			}
+		}
+		return decisions, nil
+	})
+}
+
+// RejectExecution rejects all pending tool calls of an execution awaiting approval and resumes it
+func (h *AgentHandlers) RejectExecution(c *gin.Context) {
+	var req models.RejectToolCallsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	h.resumeExecution(c, func(pending []models.PendingToolCall) (map[string]toolDecision, error) {
+		decisions := make(map[string]toolDecision, len(pending))
+		for _, p := range pending {
+			decisions[p.ID] = toolDecision{Refusal: rejectedToolMessage(req.Reason)}
+		}
+		return decisions, nil
+	})
+}
+
+// resumeExecution applies the decisions to an execution awaiting approval and continues its tool
+// loop, answering like ExecuteAgent
+func (h *AgentHandlers) resumeExecution(c *gin.Context, decide func([]models.PendingToolCall) (map[string]toolDecision, error)) {
+	ctx := c.Request.Context()
+
+	executionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid execution ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	userStr, _ := userID.(string)
+	userUUID, err := uuid.Parse(userStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if !h.mcpEnabled || h.mcpContextService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Tool execution is disabled"})
+		return
+	}
+
+	execution, err := h.executionService.GetExecution(ctx, executionID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Execution not found"})
+		return
+	}
+	if execution.Status != models.ExecutionStatusAwaitingApproval {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrExecutionNotAwaitingApproval.Error(), "status": execution.Status})
+		return
+	}
+
+	decisions, err := decide(execution.PendingToolCalls)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for id, d := range decisions {
+		d.Actor = userStr
+		decisions[id] = d
+	}
+
+	agent, err := h.agentService.GetAgent(ctx, execution.AgentID, userStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+
+	// Claim the execution; a concurrent approve or reject loses here
+	execution, err = h.executionService.ResumeExecution(ctx, executionID, userUUID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrExecutionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Execution not found"})
+		case errors.Is(err, services.ErrExecutionNotAwaitingApproval):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume execution", "details": err.Error()})
+		}
+		return
+	}
+
+	var state toolLoopState
+	if err := json.Unmarshal(execution.ToolLoopState, &state); err != nil || len(state.Messages) == 0 {
+		errorMsg := "saved tool loop state is unreadable"
+		h.executionService.CompleteExecution(ctx, executionID, models.ExecutionStatusFailed, nil, &errorMsg, 0)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Execution failed", "details": errorMsg})
+		return
+	}
+	authToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if err := h.restoreContentParts(ctx, state.Messages, state.Run.TenantID, authToken); err != nil {
+		errorMsg := "failed to restore content parts: " + err.Error()
+		h.executionService.CompleteExecution(ctx, executionID, models.ExecutionStatusFailed, nil, &errorMsg, 0)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Execution failed", "details": errorMsg})
+		return
+	}
+	run := &state.Run
+	run.startedAt = time.Now()
+
+	// Finish under the experiment variant and response reservation the execution started with
+	agent = withOutputReserve(agent.WithVariant(agent.Experiment.Variant(run.Variant)), run.MaxTokens)
+
+	// Count tokens with the model's tokenizer, as the first half of the execution did
+	caps := h.modelCapabilities(ctx, agent)
+	loopCtx := services.WithModelCapabilities(tokenizer.WithTokenizer(callerContext(c, userUUID), tokenizerFor(agent, caps)), caps)
+
+	log.Printf("[MCP-TOOLS] Resuming execution %s after a decision on %d tool calls", executionID, len(decisions))
+	response, warnings, err := h.continueToolLoop(loopCtx, agent, &state, decisions, userUUID)
+	h.finishExecution(c, agent, run, response, warnings, true, err)
+}
+
+// continueToolLoop answers the paused turn's tool calls according to the decisions and carries
+// on the loop from the next iteration, with the agent's tools resolved afresh
+func (h *AgentHandlers) continueToolLoop(ctx context.Context, agent *models.Agent, state *toolLoopState, decisions map[string]toolDecision, userID uuid.UUID) (*services.RouterResponse, []string, error) {
+	last := state.Messages[len(state.Messages)-1]
+	if last.Role != "assistant" || len(last.ToolCalls) == 0 {
+		return nil, state.Warnings, fmt.Errorf("saved tool loop state does not end with tool calls")
+	}
+
+	// The paused calls' tools must stay routable whatever else is selected this time
+	var pending []string
+	for _, call := range last.ToolCalls {
+		pending = append(pending, call.Function.Name)
+	}
+	tools, toolSkills, warnings, selection, err := h.resolveToolsForAgent(ctx, agent, state.Run.Input, pending)
+	if err != nil {
+		return nil, state.Warnings, fmt.Errorf("failed to resolve tools: %w", err)
+	}
+	state.Run.SkillSelection = selection
+	for _, w := range warnings {
+		if !containsString(state.Warnings, w) {
+			state.Warnings = append(state.Warnings, w)
+		}
+	}
+
+	loop := newToolLoop(state.Messages, tools, toolSkills, state.Warnings, &state.Run)
+	loop.iteration = state.Iteration + 1
+	loop.maxToolCalls = agent.ToolPolicy.ToolCallBudget()
+	decisions = loop.applyToolCallBudget(last.ToolCalls, decisions)
+	loop.messages = append(loop.messages, h.runDecidedToolCalls(ctx, agent, last.ToolCalls, loop, decisions)...)
+
+	// The decided calls' results always get a response, even if the pause came on the last iteration
+	start := min(state.Iteration+1, h.maxToolIterations()-1)
+	response, err := h.runToolLoop(ctx, agent, loop, start, userID)
+	return response, loop.warnings, err
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
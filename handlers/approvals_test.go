@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/mockrouter"
+	"github.com/tas-agent-builder/mockrouter/mockroutertest"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/impl"
+	"gorm.io/datatypes"
+)
+
+// stubSkillService assigns one skill to every agent
+type stubSkillService struct {
+	services.SkillService
+	skill models.Skill
+}
+
+func (s *stubSkillService) ResolveForAgent(ctx context.Context, agent *models.Agent, input string) ([]models.Skill, error) {
+	return []models.Skill{s.skill}, nil
+}
+
+// stubSkillTools offers fixed tools and records the arguments of each call
+type stubSkillTools struct {
+	services.SkillToolService
+	mu    sync.Mutex
+	tools []models.SkillTool
+	calls map[string][]map[string]interface{}
+}
+
+func (s *stubSkillTools) ListTools(ctx context.Context, skill *models.Skill, refresh bool) (*models.SkillToolList, error) {
+	return &models.SkillToolList{SkillID: skill.ID, Tools: s.tools}, nil
+}
+
+func (s *stubSkillTools) CallTool(ctx context.Context, skill *models.Skill, name string, args map[string]interface{}) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[name] = append(s.calls[name], args)
+	return name + " done", nil
+}
+
+// stubExecutions keeps executions in memory
+type stubExecutions struct {
+	services.ExecutionService
+	executions map[uuid.UUID]*models.AgentExecution
+}
+
+func (s *stubExecutions) GetExecution(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.AgentExecution, error) {
+	e, ok := s.executions[id]
+	if !ok || e.UserID != userID {
+		return nil, services.ErrExecutionNotFound
+	}
+	copied := *e
+	return &copied, nil
+}
+
+func (s *stubExecutions) SuspendExecution(ctx context.Context, id uuid.UUID, pending []models.PendingToolCall, loopState []byte) error {
+	e := s.executions[id]
+	e.Status = models.ExecutionStatusAwaitingApproval
+	e.PendingToolCalls = pending
+	e.ToolLoopState = datatypes.JSON(loopState)
+	return nil
+}
+
+func (s *stubExecutions) ResumeExecution(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.AgentExecution, error) {
+	e, err := s.GetExecution(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != models.ExecutionStatusAwaitingApproval {
+		return nil, services.ErrExecutionNotAwaitingApproval
+	}
+	s.executions[id].Status = models.ExecutionStatusRunning
+	return e, nil
+}
+
+func (s *stubExecutions) CompleteExecution(ctx context.Context, id uuid.UUID, status models.ExecutionStatus, outputData map[string]any, errorMsg *string, durationMs int) error {
+	s.executions[id].Status = status
+	return nil
+}
+
+// stubToolCallAudit keeps audit records in memory
+type stubToolCallAudit struct {
+	services.ToolCallAuditService
+	mu    sync.Mutex
+	calls []models.ExecutionToolCall
+}
+
+func (s *stubToolCallAudit) Record(ctx context.Context, skill *models.Skill, call *models.ExecutionToolCall) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if skill != nil {
+		call.SkillName = skill.Name
+	}
+	s.calls = append(s.calls, *call)
+	return nil
+}
+
+func (s *stubToolCallAudit) byTool() map[string]models.ExecutionToolCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]models.ExecutionToolCall, len(s.calls))
+	for _, call := range s.calls {
+		out[call.ToolName] = call
+	}
+	return out
+}
+
+// stubFileResolver resolves every file to a fresh signed PNG URL, recording the token used
+type stubFileResolver struct {
+	services.DocumentContextService
+	tenantID, authToken string
+}
+
+func (s *stubFileResolver) ResolveFileReference(ctx context.Context, tenantID, fileID, authToken string, inline bool) (*models.ResolvedFile, error) {
+	s.tenantID, s.authToken = tenantID, authToken
+	return &models.ResolvedFile{FileID: fileID, ContentType: "image/png", SignedURL: "https://files.example/fresh/" + fileID}, nil
+}
+
+// stubAgents serves one agent
+type stubAgents struct {
+	services.AgentService
+	agent *models.Agent
+}
+
+func (s *stubAgents) GetAgent(ctx context.Context, id uuid.UUID, userID string) (*models.Agent, error) {
+	if id != s.agent.ID {
+		return nil, errors.New("agent not found")
+	}
+	return s.agent, nil
+}
+
+func TestToolApprovalPausesAndResumes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock, server := mockroutertest.NewServer(t)
+	mock.Enqueue(
+		mockrouter.Response{ToolCalls: []mockrouter.ToolCall{
+			{ID: "call_list", Name: "list_visuals", Arguments: `{}`},
+			{ID: "call_delete", Name: "delete_visual", Arguments: `{"visual_id":"v1"}`},
+		}},
+		mockrouter.Response{Content: "Deleted visual v2."},
+	)
+
+	agent := &models.Agent{ID: uuid.New(), LLMConfig: models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o"}}
+	userID := uuid.New()
+	executionID := uuid.New()
+	executions := &stubExecutions{executions: map[uuid.UUID]*models.AgentExecution{
+		executionID: {ID: executionID, AgentID: agent.ID, UserID: userID, Status: models.ExecutionStatusRunning},
+	}}
+	tools := &stubSkillTools{
+		tools: []models.SkillTool{{Name: "list_visuals"}, {Name: "delete_visual"}},
+		calls: make(map[string][]map[string]interface{}),
+	}
+	audit := &stubToolCallAudit{}
+	files := &stubFileResolver{}
+	h := &AgentHandlers{
+		agentService:           &stubAgents{agent: agent},
+		documentContextService: files,
+		routerService:          impl.NewRouterService(&config.RouterConfig{BaseURL: server.URL, Timeout: 5, ModelCatalogTTL: 60}),
+		executionService:       executions,
+		mcpContextService:      &stubMCPContextService{},
+		skillService:           &stubSkillService{skill: models.Skill{ID: uuid.New(), Name: "visuals", Type: models.SkillTypeMCP, RequiresApproval: datatypes.JSON(`["delete_visual"]`)}},
+		skillTools:             tools,
+		toolCallAudit:          audit,
+		mcpEnabled:             true,
+		mcpMaxToolIterations:   5,
+	}
+
+	messages := []services.Message{
+		{Role: "system", Content: "You manage visuals."},
+		{Role: "user", Content: "Delete my visual.", Parts: []models.ContentPart{
+			{Type: models.ContentPartImageBase64, MediaType: "image/png", Data: "aW1hZ2UtYnl0ZXM="},
+			{Type: models.ContentPartFile, FileID: "0b7e4c2a-9f1d-4e3b-8a6c-5d2f1e0a9b8c", MediaType: "image/png", URL: "https://files.example/expiring/0b7e4c2a-9f1d-4e3b-8a6c-5d2f1e0a9b8c"},
+		}},
+	}
+	run := &agentRun{ExecutionID: executionID, UserID: userID.String(), TenantID: "tenant-a", Input: "Delete my visual."}
+	_, _, err := h.executeWithTools(context.Background(), agent, messages, userID, run)
+
+	var paused *awaitingApprovalError
+	require.ErrorAs(t, err, &paused)
+	require.Len(t, paused.pending, 1)
+	assert.Equal(t, "call_delete", paused.pending[0].ID)
+	assert.Equal(t, "v1", paused.pending[0].Arguments["visual_id"])
+	assert.Equal(t, models.ExecutionStatusAwaitingApproval, executions.executions[executionID].Status)
+	assert.Empty(t, tools.calls, "no call of the paused turn runs before the decision")
+
+	// The saved state keeps references to the parts, not their bytes or signed URLs
+	state := string(executions.executions[executionID].ToolLoopState)
+	assert.NotContains(t, state, "aW1hZ2UtYnl0ZXM=")
+	assert.NotContains(t, state, "expiring")
+	assert.Contains(t, state, `"file_id":"0b7e4c2a-9f1d-4e3b-8a6c-5d2f1e0a9b8c"`)
+
+	approve := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/executions/"+executionID.String()+"/approve", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("Authorization", "Bearer user-token")
+		c.Params = gin.Params{{Key: "id", Value: executionID.String()}}
+		c.Set("user_id", userID.String())
+		h.ApproveExecution(c)
+		return w
+	}
+
+	w := approve(`{"arguments":{"call_unknown":{}}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, models.ExecutionStatusAwaitingApproval, executions.executions[executionID].Status, "invalid requests leave it paused")
+
+	w = approve(`{"arguments":{"call_delete":{"visual_id":"v2"}}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Deleted visual v2.", resp["output"])
+	assert.Equal(t, models.ExecutionStatusCompleted, executions.executions[executionID].Status)
+
+	require.Len(t, tools.calls["delete_visual"], 1)
+	assert.Equal(t, "v2", tools.calls["delete_visual"][0]["visual_id"], "edited arguments are used")
+	assert.Len(t, tools.calls["list_visuals"], 1)
+
+	// The model sees both results in order, the edited one noted as such
+	requests := mock.Requests()
+	require.Len(t, requests, 2)
+	second := requests[1].Messages
+	require.Len(t, second, 5)
+	assert.Equal(t, "call_list", second[3].ToolCallID)
+	assert.Equal(t, "call_delete", second[4].ToolCallID)
+	assert.Contains(t, second[4].Text(), `edited arguments: {"visual_id":"v2"}`)
+
+	// The file is resolved again for the resumed turn; the inline image is noted as gone
+	user := string(second[1].Content)
+	assert.Contains(t, user, "https://files.example/fresh/0b7e4c2a-9f1d-4e3b-8a6c-5d2f1e0a9b8c")
+	assert.NotContains(t, user, "expiring")
+	assert.Contains(t, user, "no longer available")
+	assert.Equal(t, "tenant-a", files.tenantID)
+	assert.Equal(t, "user-token", files.authToken)
+
+	assert.Equal(t, http.StatusConflict, approve(`{}`).Code, "an execution resumes only once")
+
+	// Both calls are audited with the iteration that issued them; only the approved one has a decision
+	audited := audit.byTool()
+	require.Len(t, audited, 2)
+	deleted := audited["delete_visual"]
+	assert.Equal(t, executionID, deleted.ExecutionID)
+	assert.Equal(t, 1, deleted.Iteration)
+	assert.Equal(t, "visuals", deleted.SkillName)
+	assert.JSONEq(t, `{"visual_id":"v2"}`, string(deleted.Arguments))
+	assert.Equal(t, models.ToolCallStatusSuccess, deleted.Status)
+	assert.Equal(t, models.ToolCallApprovedWithEdits, deleted.ApprovalDecision)
+	assert.Equal(t, userID.String(), deleted.ApprovedBy)
+	assert.Empty(t, audited["list_visuals"].ApprovalDecision)
+}
+
+func TestPendingAndRefusedToolCalls(t *testing.T) {
+	calls := []services.ToolCall{{ID: "call_1", Function: services.ToolFunction{Name: "delete_visual", Arguments: `{}`}}}
+	skill := &models.Skill{Name: "visuals", RequiresApproval: datatypes.JSON(`["*"]`)}
+	loop := newToolLoop(nil, nil, map[string]*models.Skill{"delete_visual": skill}, nil, nil)
+	pending := loop.pendingToolCalls(calls)
+	require.Len(t, pending, 1)
+
+	h := &AgentHandlers{}
+	messages := h.runDecidedToolCalls(context.Background(), &models.Agent{}, calls, loop, map[string]toolDecision{
+		"call_1": {Refusal: rejectedToolMessage("not today")},
+	})
+	require.Len(t, messages, 1)
+	assert.Equal(t, "call_1", messages[0].ToolCallID)
+	assert.Equal(t, "The user rejected this tool call, so it was not run. Reason: not today", messages[0].Content)
+}
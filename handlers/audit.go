@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// maxAuditPageSize bounds a page of the tool call search
+const maxAuditPageSize = 200
+
+// AuditHandlers serves the tool call audit log
+type AuditHandlers struct {
+	toolCalls        services.ToolCallAuditService
+	executionService services.ExecutionService
+	reviewerRoles    []string
+}
+
+// NewAuditHandlers creates a new AuditHandlers instance. Users with one of reviewerRoles may
+// search all tool calls of their tenant.
+func NewAuditHandlers(toolCalls services.ToolCallAuditService, executionService services.ExecutionService, reviewerRoles []string) *AuditHandlers {
+	return &AuditHandlers{
+		toolCalls:        toolCalls,
+		executionService: executionService,
+		reviewerRoles:    reviewerRoles,
+	}
+}
+
+// ListExecutionToolCalls handles GET /api/v1/executions/:id/tool-calls
+func (h *AuditHandlers) ListExecutionToolCalls(c *gin.Context) {
+	executionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid execution ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	userStr, _ := userID.(string)
+	userUUID, err := uuid.Parse(userStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if _, err := h.executionService.GetExecution(c.Request.Context(), executionID, userUUID); err != nil {
+		if errors.Is(err, services.ErrExecutionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Execution not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get execution", "details": err.Error()})
+		return
+	}
+
+	calls, err := h.toolCalls.ListForExecution(c.Request.Context(), executionID, userUUID)
+	if err != nil {
+		log.Printf("[AUDIT] Failed to list tool calls of execution %s: %v", executionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tool calls"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ToolCallListResponse{ToolCalls: calls, Total: int64(len(calls))})
+}
+
+// SearchToolCalls handles GET /api/v1/audit/tool-calls for compliance reviewers. Results are
+// limited to the caller's tenant.
+func (h *AuditHandlers) SearchToolCalls(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant not found in context"})
+		return
+	}
+	if !hasRole(c, h.reviewerRoles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Searching tool calls requires an audit role"})
+		return
+	}
+
+	filter := models.ToolCallSearchFilter{
+		TenantID:   tenantID,
+		SkillName:  c.Query("skill"),
+		ToolName:   c.Query("tool"),
+		Status:     c.Query("status"),
+		ApprovedBy: c.Query("approved_by"),
+		Query:      c.Query("q"),
+		Page:       1,
+		Size:       50,
+	}
+	if err := filter.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids := map[string]**uuid.UUID{"user_id": &filter.UserID, "agent_id": &filter.AgentID, "execution_id": &filter.ExecutionID}
+	for param, dst := range ids {
+		if s := c.Query(param); s != "" {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+			*dst = &id
+		}
+	}
+	times := map[string]**time.Time{"from": &filter.From, "to": &filter.To}
+	for param, dst := range times {
+		if s := c.Query(param); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time"})
+				return
+			}
+			*dst = &t
+		}
+	}
+	if pageStr := c.Query("page"); pageStr != "" {
+		var page int
+		if _, err := parseIntParam(pageStr, &page); err == nil && page > 0 {
+			filter.Page = page
+		}
+	}
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		var size int
+		if _, err := parseIntParam(sizeStr, &size); err == nil && size > 0 {
+			filter.Size = min(size, maxAuditPageSize)
+		}
+	}
+
+	result, err := h.toolCalls.Search(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("[AUDIT] Failed to search tool calls: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search tool calls"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
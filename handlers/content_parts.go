@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// resolveContentParts validates multimodal parts and resolves AudiModal file references.
+// Image files are sent as signed URLs unless inline delivery is requested; other files are
+// always inlined because the OpenAI file part only accepts data.
+func (h *AgentHandlers) resolveContentParts(ctx context.Context, parts []models.ContentPart, tenantID, authToken string) ([]models.ContentPart, error) {
+	resolved := make([]models.ContentPart, 0, len(parts))
+	for i, p := range parts {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("parts[%d]: %w", i, err)
+		}
+		if p.Type != models.ContentPartFile {
+			resolved = append(resolved, p)
+			continue
+		}
+
+		if h.documentContextService == nil {
+			return nil, fmt.Errorf("parts[%d]: file references are not available", i)
+		}
+
+		inline := p.Delivery == models.FileDeliveryInline
+		file, err := h.documentContextService.ResolveFileReference(ctx, tenantID, p.FileID, authToken, inline)
+		if err == nil && !inline && !strings.HasPrefix(file.ContentType, "image/") {
+			file, err = h.documentContextService.ResolveFileReference(ctx, tenantID, p.FileID, authToken, true)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parts[%d]: failed to resolve file %s: %w", i, p.FileID, err)
+		}
+
+		p.MediaType = file.ContentType
+		if p.Filename == "" {
+			p.Filename = file.Filename
+		}
+		if file.Data != nil {
+			p.Data = base64.StdEncoding.EncodeToString(file.Data)
+			p.URL = ""
+		} else {
+			p.URL = file.SignedURL
+		}
+		resolved = append(resolved, p)
+	}
+	return resolved, nil
+}
+
+// validateVisionSupport checks that the agent's model, described by caps, accepts image input.
+// Unknown models (nil caps) are accepted only when the agent requires "vision", so the router
+// picks a capable provider.
+func validateVisionSupport(agent *models.Agent, caps *services.ModelCapabilities) error {
+	if caps != nil {
+		if caps.HasFeature("vision") {
+			return nil
+		}
+		return fmt.Errorf("model %s does not support image input", agent.LLMConfig.Model)
+	}
+
+	for _, f := range agent.LLMConfig.RequiredFeatures {
+		if f == "vision" {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s is not known to support image input; add \"vision\" to required_features", agent.LLMConfig.Model)
+}
+
+// hasImageParts reports whether any part is sent to the model as an image
+func hasImageParts(parts []models.ContentPart) bool {
+	return countImageParts(parts) > 0
+}
+
+// countImageParts returns the number of images among parts
+func countImageParts(parts []models.ContentPart) int {
+	n := 0
+	for _, p := range parts {
+		if p.IsImage() {
+			n++
+		}
+	}
+	return n
+}
+
+// restoreContentParts resolves again the parts of messages saved with messagesForStorage, as
+// signed URLs expire and inline bytes are not kept. File references get a fresh URL or their
+// data; inline images, whose bytes are gone, are replaced by a note saying so.
+func (h *AgentHandlers) restoreContentParts(ctx context.Context, messages []services.Message, tenantID, authToken string) error {
+	for i := range messages {
+		if len(messages[i].Parts) == 0 {
+			continue
+		}
+		parts := make([]models.ContentPart, 0, len(messages[i].Parts))
+		for _, p := range messages[i].Parts {
+			switch {
+			case p.Type == models.ContentPartImageBase64 && p.Data == "":
+				p = models.ContentPart{
+					Type: models.ContentPartText,
+					Text: fmt.Sprintf("[An image (%s, %d bytes) sent earlier in this conversation is no longer available]", p.MediaType, p.SizeBytes),
+				}
+			case p.Type == models.ContentPartFile:
+				p.SizeBytes, p.SHA256 = 0, ""
+			}
+			parts = append(parts, p)
+		}
+		resolved, err := h.resolveContentParts(ctx, parts, tenantID, authToken)
+		if err != nil {
+			return fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		messages[i].Parts = resolved
+	}
+	return nil
+}
+
+// messagesForStorage returns a copy of messages whose parts hold references instead of raw bytes
+func messagesForStorage(messages []services.Message) []services.Message {
+	stored := make([]services.Message, len(messages))
+	for i, msg := range messages {
+		stored[i] = msg
+		stored[i].Parts = models.ContentPartsStorageReferences(msg.Parts)
+	}
+	return stored
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// CredentialHandlers manages the secrets skills authenticate with. Values are write-only.
+type CredentialHandlers struct {
+	credentialService services.CredentialService
+	adminRoles        []string
+}
+
+// NewCredentialHandlers creates a new CredentialHandlers instance. Users with one of adminRoles
+// manage their tenant's shared credentials and may rotate the encryption key.
+func NewCredentialHandlers(credentialService services.CredentialService, adminRoles []string) *CredentialHandlers {
+	return &CredentialHandlers{
+		credentialService: credentialService,
+		adminRoles:        adminRoles,
+	}
+}
+
+// callerContext returns the request's context carrying the caller, so skills it calls
+// authenticate with the caller's credentials
+func callerContext(c *gin.Context, userID uuid.UUID) context.Context {
+	return services.WithCaller(c.Request.Context(), services.Caller{TenantID: c.GetString("tenant_id"), UserID: userID})
+}
+
+// hasRole reports whether the caller holds one of the roles
+func hasRole(c *gin.Context, roles []string) bool {
+	value, _ := c.Get("roles")
+	held, _ := value.([]string)
+	for _, role := range held {
+		if containsString(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// caller reads the authenticated caller, writing an error response if there is none
+func (h *CredentialHandlers) caller(c *gin.Context) (services.Caller, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return services.Caller{}, false
+	}
+	tenantID := c.GetString("tenant_id")
+	if tenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant not found in context"})
+		return services.Caller{}, false
+	}
+	return services.Caller{TenantID: tenantID, UserID: userID}, true
+}
+
+// CreateCredential handles POST /api/v1/credentials
+func (h *CredentialHandlers) CreateCredential(c *gin.Context) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Scope == models.CredentialScopeTenant && !hasRole(c, h.adminRoles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant credentials require an admin role"})
+		return
+	}
+
+	cred, err := h.credentialService.Create(c.Request.Context(), caller, req)
+	if err != nil {
+		h.writeError(c, "create", err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"credential": cred})
+}
+
+// ListCredentials handles GET /api/v1/credentials
+func (h *CredentialHandlers) ListCredentials(c *gin.Context) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return
+	}
+
+	creds, err := h.credentialService.List(c.Request.Context(), caller)
+	if err != nil {
+		h.writeError(c, "list", err)
+		return
+	}
+	c.JSON(http.StatusOK, models.CredentialListResponse{Credentials: creds, Total: len(creds)})
+}
+
+// GetCredential handles GET /api/v1/credentials/:id
+func (h *CredentialHandlers) GetCredential(c *gin.Context) {
+	cred, _, ok := h.load(c, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"credential": cred})
+}
+
+// UpdateCredential handles PUT /api/v1/credentials/:id; a new value replaces the old one
+func (h *CredentialHandlers) UpdateCredential(c *gin.Context) {
+	cred, caller, ok := h.load(c, true)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if req.Value != nil && *req.Value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "value must not be empty"})
+		return
+	}
+
+	updated, err := h.credentialService.Update(c.Request.Context(), caller, cred.ID, req)
+	if err != nil {
+		h.writeError(c, "update", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"credential": updated})
+}
+
+// DeleteCredential handles DELETE /api/v1/credentials/:id
+func (h *CredentialHandlers) DeleteCredential(c *gin.Context) {
+	cred, caller, ok := h.load(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.credentialService.Delete(c.Request.Context(), caller, cred.ID); err != nil {
+		h.writeError(c, "delete", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Credential deleted successfully"})
+}
+
+// RotateCredentialKeys handles POST /api/v1/credentials/rotate. It rewraps every credential
+// still under an older key with the configured primary key, after which older keys can be
+// removed from the configuration.
+func (h *CredentialHandlers) RotateCredentialKeys(c *gin.Context) {
+	if !hasRole(c, h.adminRoles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Rotating credential keys requires an admin role"})
+		return
+	}
+
+	result, err := h.credentialService.RotateKeys(c.Request.Context())
+	if err != nil {
+		h.writeError(c, "rotate", err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// load fetches the credential named by :id. Changing a tenant credential requires an admin role.
+func (h *CredentialHandlers) load(c *gin.Context, change bool) (*models.Credential, services.Caller, bool) {
+	caller, ok := h.caller(c)
+	if !ok {
+		return nil, caller, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential ID"})
+		return nil, caller, false
+	}
+
+	cred, err := h.credentialService.Get(c.Request.Context(), caller, id)
+	if err != nil {
+		h.writeError(c, "get", err)
+		return nil, caller, false
+	}
+	if change && cred.Scope() == models.CredentialScopeTenant && !hasRole(c, h.adminRoles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant credentials require an admin role"})
+		return nil, caller, false
+	}
+	return cred, caller, true
+}
+
+func (h *CredentialHandlers) writeError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, services.ErrCredentialNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+	case errors.Is(err, services.ErrCredentialExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCredentialsDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[CREDENTIALS] Failed to %s credential: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " credential"})
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// validateExperiment checks the experiment's shape and each variant's LLM configuration
+func (h *AgentHandlers) validateExperiment(ctx context.Context, exp *models.AgentExperiment) error {
+	if exp == nil {
+		return nil
+	}
+	if err := exp.Validate(); err != nil {
+		return err
+	}
+	for _, v := range exp.Variants {
+		if v.LLMConfig == nil {
+			continue
+		}
+		if err := h.validateLLMConfig(ctx, *v.LLMConfig); err != nil {
+			return fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// experimentStickyKey keeps a session on one variant; without a session the user is the unit
+func experimentStickyKey(sessionID *string, userID string) string {
+	if sessionID != nil && *sessionID != "" {
+		return "session:" + *sessionID
+	}
+	return "user:" + userID
+}
+
+// SubmitExecutionFeedback records a thumbs up/down on an execution
+func (h *AgentHandlers) SubmitExecutionFeedback(c *gin.Context) {
+	agentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+		return
+	}
+
+	executionID, err := uuid.Parse(c.Param("execution_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid execution ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	userStr, _ := userID.(string)
+	userUUID, err := uuid.Parse(userStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var req models.ExecutionFeedback
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feedback", "details": err.Error()})
+		return
+	}
+
+	if err := h.executionService.SetExecutionFeedback(c.Request.Context(), agentID, executionID, userUUID, req); err != nil {
+		if errors.Is(err, services.ErrExecutionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Execution not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save feedback", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Feedback recorded"})
+}
+
+// GetExperimentResults compares success rate, latency, cost and feedback across the variants
+// of an agent's experiment
+func (h *AgentHandlers) GetExperimentResults(c *gin.Context) {
+	agentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+		return
+	}
+	experiment := c.Param("exp")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	userStr, _ := userID.(string)
+
+	agent, err := h.agentService.GetAgent(c.Request.Context(), agentID, userStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+
+	// Report variants in configured order when this is the agent's current experiment
+	var variantOrder []string
+	current := agent.Experiment != nil && agent.Experiment.Name == experiment
+	if current {
+		for _, v := range agent.Experiment.Variants {
+			variantOrder = append(variantOrder, v.Name)
+		}
+	}
+
+	stats, comparisons, err := h.executionService.GetExperimentResults(c.Request.Context(), agentID, experiment, variantOrder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get experiment results", "details": err.Error()})
+		return
+	}
+	if !current && len(stats) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ExperimentResults{
+		AgentID:     agentID.String(),
+		Experiment:  experiment,
+		Active:      current && agent.Experiment.Enabled,
+		Variants:    stats,
+		Comparisons: comparisons,
+		GeneratedAt: time.Now(),
+	})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services/mcp"
+)
+
+// publishedAgentsPageSize is the number of agents per tools/list page, the most ListAgents returns
+const publishedAgentsPageSize = 100
+
+// agentsEndpoint offers published agents to MCP clients
+var agentsEndpoint = mcp.NewEndpoint(
+	mcp.Implementation{Name: "tas-agent-builder", Version: "1.0.0"},
+	"Each tool runs one of the agents published on TAS Agent Builder. Pass the complete request as input; "+
+		"the agent sees nothing else of your conversation.",
+)
+
+// ServeMCP is the MCP server endpoint: the caller's published agents are its tools
+func (h *AgentHandlers) ServeMCP(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	agentsEndpoint.Serve(c.Writer, c.Request, &publishedAgentTools{
+		h:        h,
+		userID:   userID,
+		tenantID: c.GetString("tenant_id"),
+	})
+}
+
+// publishedAgentTools exposes the published agents a user can access as MCP tools
+type publishedAgentTools struct {
+	h        *AgentHandlers
+	userID   string
+	tenantID string
+}
+
+// publishedAgentToolName names the tool of a published agent. The ID prefix keeps agents with
+// the same name apart.
+func publishedAgentToolName(agent *models.Agent) string {
+	name := agentToolName(agent)
+	if len(name) > 55 {
+		name = name[:55]
+	}
+	return name + "_" + agent.ID.String()[:8]
+}
+
+// page lists one page of the user's published agents
+func (p *publishedAgentTools) page(ctx context.Context, page int) (*models.AgentListResponse, error) {
+	status := models.AgentStatusPublished
+	return p.h.agentService.ListAgents(ctx, models.AgentListFilter{
+		Status: &status,
+		Page:   page,
+		Size:   publishedAgentsPageSize,
+	}, p.userID)
+}
+
+// ListTools pages through the published agents; the cursor is the next page number
+func (p *publishedAgentTools) ListTools(ctx context.Context, cursor string) (*mcp.ListToolsResult, error) {
+	page := 1
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 1 {
+			return nil, &mcp.RPCError{Code: mcp.CodeInvalidParams, Message: "invalid cursor"}
+		}
+		page = n
+	}
+
+	agents, err := p.page(ctx, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	result := &mcp.ListToolsResult{Tools: []mcp.Tool{}}
+	for i := range agents.Agents {
+		agent := &agents.Agents[i]
+		description := agent.Description
+		if description == "" {
+			description = fmt.Sprintf("Asks the agent %q", agent.Name)
+		}
+		result.Tools = append(result.Tools, mcp.Tool{
+			Name:        publishedAgentToolName(agent),
+			Description: description,
+			InputSchema: agentToolSchema(),
+		})
+	}
+	if int64(page*publishedAgentsPageSize) < agents.Total {
+		result.NextCursor = strconv.Itoa(page + 1)
+	}
+	return result, nil
+}
+
+// find returns the ID of the published agent behind a tool name
+func (p *publishedAgentTools) find(ctx context.Context, name string) (uuid.UUID, error) {
+	idx := strings.LastIndex(name, "_")
+	if !strings.HasPrefix(name, agentToolPrefix) || idx < 0 || len(name)-idx-1 != 8 {
+		return uuid.Nil, &mcp.RPCError{Code: mcp.CodeInvalidParams, Message: fmt.Sprintf("unknown tool %q", name)}
+	}
+	idPrefix := name[idx+1:]
+
+	for page := 1; ; page++ {
+		agents, err := p.page(ctx, page)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to list agents: %w", err)
+		}
+		for i := range agents.Agents {
+			agent := &agents.Agents[i]
+			if strings.HasPrefix(agent.ID.String(), idPrefix) && publishedAgentToolName(agent) == name {
+				return agent.ID, nil
+			}
+		}
+		if len(agents.Agents) == 0 || int64(page*publishedAgentsPageSize) >= agents.Total {
+			return uuid.Nil, &mcp.RPCError{Code: mcp.CodeInvalidParams, Message: fmt.Sprintf("unknown tool %q", name)}
+		}
+	}
+}
+
+// CallTool runs the agent through the regular execute path. The answer is the text content;
+// the structured content adds the citations of the documents it drew on.
+func (p *publishedAgentTools) CallTool(ctx context.Context, name string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	agentID, err := p.find(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	input, _ := args["input"].(string)
+	if strings.TrimSpace(input) == "" {
+		return toolError("input is required"), nil
+	}
+
+	status, body := p.h.executeAgent(ctx, agentID, models.ExecutionContextRequest{Input: input}, p.userID, p.tenantID, "", time.Now())
+	switch status {
+	case http.StatusOK:
+		answer, _ := body["output"].(string)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{{Type: "text", Text: answer}},
+			StructuredContent: gin.H{
+				"answer":       answer,
+				"citations":    citationsOf(body),
+				"execution_id": body["execution_id"],
+			},
+		}, nil
+	case http.StatusAccepted:
+		return toolError(fmt.Sprintf("The agent is waiting for a tool call to be approved (execution %v). "+
+			"Approve or reject it in TAS Agent Builder.", body["execution_id"])), nil
+	default:
+		message := fmt.Sprint(body["error"])
+		if details, ok := body["details"]; ok {
+			message += ": " + fmt.Sprint(details)
+		}
+		return toolError(message), nil
+	}
+}
+
+// citationsOf returns the citations recorded in an execution response, never nil
+func citationsOf(body gin.H) []models.Citation {
+	if metadata, ok := body["metadata"].(gin.H); ok {
+		if contextMetadata, ok := metadata["context_metadata"].(map[string]any); ok {
+			if citations, ok := contextMetadata["citations"].([]models.Citation); ok {
+				return citations
+			}
+		}
+	}
+	return []models.Citation{}
+}
+
+func toolError(message string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: message}}, IsError: true}
+}
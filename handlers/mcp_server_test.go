@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/mockrouter"
+	"github.com/tas-agent-builder/mockrouter/mockroutertest"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/impl"
+	"github.com/tas-agent-builder/services/mcp"
+	"github.com/tas-agent-builder/services/tokenizer"
+)
+
+// stubPublishedAgents lists its agents to every user, paged as the filter asks
+type stubPublishedAgents struct {
+	services.AgentService
+	agents []models.Agent
+}
+
+func (s *stubPublishedAgents) GetAgent(ctx context.Context, id uuid.UUID, userID string) (*models.Agent, error) {
+	for i := range s.agents {
+		if s.agents[i].ID == id {
+			return &s.agents[i], nil
+		}
+	}
+	return nil, errors.New("agent not found or access denied")
+}
+
+func (s *stubPublishedAgents) ListAgents(ctx context.Context, filter models.AgentListFilter, userID string) (*models.AgentListResponse, error) {
+	resp := &models.AgentListResponse{Total: int64(len(s.agents)), Page: filter.Page, Size: filter.Size}
+	start := (filter.Page - 1) * filter.Size
+	for i := start; i < len(s.agents) && i < start+filter.Size; i++ {
+		resp.Agents = append(resp.Agents, s.agents[i])
+	}
+	return resp, nil
+}
+
+// stubDocuments retrieves the same chunk for every search
+type stubDocuments struct {
+	services.DocumentContextService
+	chunk models.RetrievedChunk
+}
+
+func (s *stubDocuments) RetrieveVectorContext(ctx context.Context, req models.VectorSearchRequest) (*models.DocumentContextResult, error) {
+	return &models.DocumentContextResult{Chunks: []models.RetrievedChunk{s.chunk}}, nil
+}
+
+func (s *stubDocuments) FormatContextForInjection(result *models.DocumentContextResult, maxTokens int, tok tokenizer.Tokenizer) (*models.ContextInjectionResult, error) {
+	return &models.ContextInjectionResult{FormattedContext: s.chunk.Content, ChunkCount: 1, DocumentCount: 1}, nil
+}
+
+func TestServeMCP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	llm := models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o"}
+	notebooks, _ := json.Marshal([]uuid.UUID{uuid.New()})
+	policies := models.Agent{
+		ID:              uuid.New(),
+		Name:            "Policy Helper",
+		Description:     "Answers questions about company policies",
+		LLMConfig:       llm,
+		EnableKnowledge: true,
+		NotebookIDs:     notebooks,
+	}
+	duplicate := models.Agent{ID: uuid.New(), Name: "Policy Helper", LLMConfig: llm}
+	page := 3
+	chunk := models.RetrievedChunk{DocumentID: "doc-1", DocumentName: "Travel policy.pdf", ChunkNumber: 4, PageNumber: &page, Score: 0.91, Content: "Economy class only."}
+
+	mock, llmServer := mockroutertest.NewServer(t)
+	executions := &recordingExecutions{
+		started:  make(map[uuid.UUID]models.StartExecutionRequest),
+		statuses: make(map[uuid.UUID]models.ExecutionStatus),
+		outputs:  make(map[uuid.UUID]map[string]any),
+	}
+	h := &AgentHandlers{
+		agentService:           &stubPublishedAgents{agents: []models.Agent{policies, duplicate}},
+		routerService:          impl.NewRouterService(&config.RouterConfig{BaseURL: llmServer.URL, Timeout: 5, ModelCatalogTTL: 60}),
+		executionService:       executions,
+		documentContextService: &stubDocuments{chunk: chunk},
+		mcpContextService:      &stubMCPContextService{},
+		skillTools:             &stubSkillTools{},
+		skillService:           &stubAgentSkills{},
+	}
+
+	router := gin.New()
+	router.POST("/mcp", func(c *gin.Context) {
+		c.Set("user_id", uuid.NewString())
+		c.Set("tenant_id", "tenant-1")
+		h.ServeMCP(c)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := mcp.NewClient(mcp.NewStreamableHTTPTransport(server.URL+"/mcp", server.Client(), nil), mcp.Implementation{Name: "ide"})
+	defer client.Close()
+	_, err := client.Initialize(ctx)
+	require.NoError(t, err)
+
+	tools, err := client.ListTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+	assert.Equal(t, "agent_policy_helper_"+policies.ID.String()[:8], tools[0].Name)
+	assert.Equal(t, "Answers questions about company policies", tools[0].Description)
+	assert.Equal(t, agentToolSchema(), tools[0].InputSchema)
+	assert.NotEqual(t, tools[0].Name, tools[1].Name, "agents with the same name get distinct tools")
+
+	mock.Enqueue(mockrouter.Response{Content: "Book economy class.", PromptTokens: 40, CompletionTokens: 5})
+	result, err := client.CallTool(ctx, tools[0].Name, map[string]interface{}{"input": "Can I fly business?"}, nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Text())
+	assert.Equal(t, "Book economy class.", result.Text())
+
+	structured := result.StructuredContent.(map[string]interface{})
+	assert.Equal(t, "Book economy class.", structured["answer"])
+	citations := structured["citations"].([]interface{})
+	require.Len(t, citations, 1)
+	assert.Equal(t, "Travel policy.pdf", citations[0].(map[string]interface{})["document_name"])
+
+	executionID := uuid.MustParse(structured["execution_id"].(string))
+	assert.Equal(t, policies.ID, executions.started[executionID].AgentID)
+	assert.Equal(t, models.ExecutionStatusCompleted, executions.statuses[executionID])
+
+	result, err = client.CallTool(ctx, tools[1].Name, map[string]interface{}{}, nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	_, err = client.CallTool(ctx, "agent_unknown_12345678", map[string]interface{}{"input": "hi"}, nil)
+	assert.ErrorContains(t, err, "unknown tool")
+}
@@ -1,23 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tas-agent-builder/services"
 )
 
 // RouterProxyHandler proxies requests to the TAS Router service
 type RouterProxyHandler struct {
 	routerBaseURL string
+	catalog       services.ModelCatalogService
 }
 
 // NewRouterProxyHandler creates a new router proxy handler
-func NewRouterProxyHandler(routerBaseURL string) *RouterProxyHandler {
+func NewRouterProxyHandler(routerBaseURL string, catalog services.ModelCatalogService) *RouterProxyHandler {
 	return &RouterProxyHandler{
 		routerBaseURL: strings.TrimSuffix(routerBaseURL, "/"),
+		catalog:       catalog,
 	}
 }
 
@@ -84,51 +88,41 @@ func (h *RouterProxyHandler) ProxyToRouter(c *gin.Context) {
 	}
 }
 
-// GetProviders returns available LLM providers from the router
+// GetProviders returns available LLM providers from the model catalog
 func (h *RouterProxyHandler) GetProviders(c *gin.Context) {
-	// For now, return a static list if router is not available
-	// This allows the frontend to work even without the router running
-	providers := []map[string]interface{}{
-		{
-			"id":          "openai",
-			"name":        "OpenAI",
-			"status":      "available",
-			"models":      []string{"gpt-4", "gpt-4-turbo", "gpt-3.5-turbo"},
-			"features":    []string{"chat", "functions", "vision"},
-			"maxTokens":   128000,
-			"costPerMillion": 5.0,
-		},
-		{
-			"id":          "anthropic",
-			"name":        "Anthropic",
-			"status":      "available",
-			"models":      []string{"claude-3-opus", "claude-3-sonnet", "claude-3-haiku"},
-			"features":    []string{"chat", "vision"},
-			"maxTokens":   200000,
-			"costPerMillion": 15.0,
-		},
-		{
-			"id":          "google",
-			"name":        "Google",
-			"status":      "available",
-			"models":      []string{"gemini-pro", "gemini-pro-vision"},
-			"features":    []string{"chat", "vision"},
-			"maxTokens":   32768,
-			"costPerMillion": 0.5,
-		},
-	}
-	
-	// Try to get real data from router
-	targetURL := fmt.Sprintf("%s/providers", h.routerBaseURL)
-	resp, err := http.Get(targetURL)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
-		// Forward the real response
-		c.DataFromReader(resp.StatusCode, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, nil)
+	catalogProviders, err := h.catalog.ListProviders(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load providers from router", "details": err.Error()})
 		return
 	}
-	
-	// Return static data if router is not available
+
+	providers := make([]map[string]interface{}, 0, len(catalogProviders))
+	for _, p := range catalogProviders {
+		modelNames := make([]string, 0, len(p.Models))
+		var costPerMillion float64
+		for _, m := range p.Models {
+			modelNames = append(modelNames, m.Name)
+			if costPerMillion == 0 || (m.InputCostPer1K > 0 && m.InputCostPer1K*1000 < costPerMillion) {
+				costPerMillion = m.InputCostPer1K * 1000
+			}
+		}
+
+		status := "available"
+		if len(p.Models) == 0 {
+			status = "unavailable"
+		}
+
+		providers = append(providers, map[string]interface{}{
+			"id":             p.Name,
+			"name":           p.DisplayName,
+			"status":         status,
+			"models":         modelNames,
+			"features":       p.Features,
+			"maxTokens":      p.MaxContextWindow,
+			"costPerMillion": costPerMillion,
+		})
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"providers": providers,
 		"total":     len(providers),
@@ -138,33 +132,48 @@ func (h *RouterProxyHandler) GetProviders(c *gin.Context) {
 // GetProviderModels returns models for a specific provider
 func (h *RouterProxyHandler) GetProviderModels(c *gin.Context) {
 	provider := c.Param("provider")
-	
-	// Static model data
-	modelsMap := map[string][]map[string]interface{}{
-		"openai": {
-			{"id": "gpt-4", "name": "GPT-4", "context": 8192, "costPerMillion": 30.0},
-			{"id": "gpt-4-turbo", "name": "GPT-4 Turbo", "context": 128000, "costPerMillion": 10.0},
-			{"id": "gpt-3.5-turbo", "name": "GPT-3.5 Turbo", "context": 16385, "costPerMillion": 0.5},
-		},
-		"anthropic": {
-			{"id": "claude-3-opus", "name": "Claude 3 Opus", "context": 200000, "costPerMillion": 15.0},
-			{"id": "claude-3-sonnet", "name": "Claude 3 Sonnet", "context": 200000, "costPerMillion": 3.0},
-			{"id": "claude-3-haiku", "name": "Claude 3 Haiku", "context": 200000, "costPerMillion": 0.25},
-		},
-		"google": {
-			{"id": "gemini-pro", "name": "Gemini Pro", "context": 32768, "costPerMillion": 0.5},
-			{"id": "gemini-pro-vision", "name": "Gemini Pro Vision", "context": 32768, "costPerMillion": 0.5},
-		},
-	}
-	
-	models, exists := modelsMap[provider]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Provider not found"})
+
+	catalogModels, err := h.catalog.ListModels(c.Request.Context(), provider)
+	if err != nil {
+		if errors.Is(err, services.ErrProviderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Provider not found"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load models from router", "details": err.Error()})
 		return
 	}
-	
+
+	models := make([]map[string]interface{}, 0, len(catalogModels))
+	for _, m := range catalogModels {
+		models = append(models, map[string]interface{}{
+			"id":             m.Name,
+			"name":           m.DisplayName,
+			"context":        m.ContextWindow,
+			"maxOutput":      m.MaxOutputTokens,
+			"costPerMillion": m.InputCostPer1K * 1000,
+			"features":       m.Features,
+		})
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"models": models,
 		"total":  len(models),
 	})
-}
\ No newline at end of file
+}
+
+// GetModel returns the capabilities of a single model
+func (h *RouterProxyHandler) GetModel(c *gin.Context) {
+	model := c.Param("model")
+
+	caps, err := h.catalog.GetModel(c.Request.Context(), model)
+	if err != nil {
+		if errors.Is(err, services.ErrModelNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load model from router", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, caps)
+}
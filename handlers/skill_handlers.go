@@ -1,257 +1,776 @@
-package handlers
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/tas-agent-builder/models"
-	"github.com/tas-agent-builder/services"
-	"gorm.io/datatypes"
-)
-
-// SkillHandlers handles skill CRUD HTTP endpoints
-type SkillHandlers struct {
-	skillService services.SkillService
-}
-
-// NewSkillHandlers creates a new SkillHandlers instance
-func NewSkillHandlers(skillService services.SkillService) *SkillHandlers {
-	return &SkillHandlers{
-		skillService: skillService,
-	}
-}
-
-// CreateSkill handles POST /api/v1/skills
-func (h *SkillHandlers) CreateSkill(c *gin.Context) {
-	var req models.CreateSkillRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
-		return
-	}
-
-	if req.Name == "" || req.DisplayName == "" || req.Type == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "name, display_name, and type are required"})
-		return
-	}
-
-	// Validate type
-	switch req.Type {
-	case models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin:
-		// valid
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be 'mcp', 'function', or 'builtin'"})
-		return
-	}
-
-	// Build skill model
-	skill := &models.Skill{
-		Name:         req.Name,
-		DisplayName:  req.DisplayName,
-		Description:  req.Description,
-		Type:         req.Type,
-		Icon:         req.Icon,
-		MCPServerURL: req.MCPServerURL,
-		IsPublic:     req.IsPublic,
-		Author:       req.Author,
-		Version:      req.Version,
-	}
-
-	if skill.Version == "" {
-		skill.Version = "1.0.0"
-	}
-
-	if req.Tags != nil {
-		tagsJSON, _ := json.Marshal(req.Tags)
-		skill.Tags = datatypes.JSON(tagsJSON)
-	}
-	if req.Keywords != nil {
-		keywordsJSON, _ := json.Marshal(req.Keywords)
-		skill.Keywords = datatypes.JSON(keywordsJSON)
-	}
-	if req.MCPToolNames != nil {
-		toolNamesJSON, _ := json.Marshal(req.MCPToolNames)
-		skill.MCPToolNames = datatypes.JSON(toolNamesJSON)
-	}
-
-	if err := h.skillService.Create(c.Request.Context(), skill); err != nil {
-		log.Printf("[SKILLS] Failed to create skill: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create skill: " + err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{"skill": skill})
-}
-
-// ListSkills handles GET /api/v1/skills
-func (h *SkillHandlers) ListSkills(c *gin.Context) {
-	filter := models.SkillListFilter{
-		Search: c.Query("search"),
-		Page:   1,
-		Size:   50,
-	}
-
-	if typeStr := c.Query("type"); typeStr != "" {
-		t := models.SkillType(typeStr)
-		filter.Type = &t
-	}
-	if tagsStr := c.Query("tags"); tagsStr != "" {
-		filter.Tags = splitTags(tagsStr)
-	}
-	if pageStr := c.Query("page"); pageStr != "" {
-		var page int
-		if _, err := parseIntParam(pageStr, &page); err == nil && page > 0 {
-			filter.Page = page
-		}
-	}
-	if sizeStr := c.Query("size"); sizeStr != "" {
-		var size int
-		if _, err := parseIntParam(sizeStr, &size); err == nil && size > 0 {
-			filter.Size = size
-		}
-	}
-
-	result, err := h.skillService.List(c.Request.Context(), filter)
-	if err != nil {
-		log.Printf("[SKILLS] Failed to list skills: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list skills"})
-		return
-	}
-
-	c.JSON(http.StatusOK, result)
-}
-
-// GetSkill handles GET /api/v1/skills/:id
-func (h *SkillHandlers) GetSkill(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid skill ID"})
-		return
-	}
-
-	skill, err := h.skillService.GetByID(c.Request.Context(), id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Skill not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"skill": skill})
-}
-
-// UpdateSkill handles PUT /api/v1/skills/:id
-func (h *SkillHandlers) UpdateSkill(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid skill ID"})
-		return
-	}
-
-	var req models.UpdateSkillRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
-		return
-	}
-
-	skill, err := h.skillService.Update(c.Request.Context(), id, req)
-	if err != nil {
-		log.Printf("[SKILLS] Failed to update skill: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update skill: " + err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"skill": skill})
-}
-
-// DeleteSkill handles DELETE /api/v1/skills/:id
-func (h *SkillHandlers) DeleteSkill(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid skill ID"})
-		return
-	}
-
-	if err := h.skillService.Delete(c.Request.Context(), id); err != nil {
-		if err.Error() == "skill not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Skill not found"})
-			return
-		}
-		if contains(err.Error(), "cannot delete system skill") {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		log.Printf("[SKILLS] Failed to delete skill: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete skill"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Skill deleted successfully"})
-}
-
-// helpers
-
-func splitTags(s string) []string {
-	parts := make([]string, 0)
-	for _, p := range splitString(s, ",") {
-		trimmed := trimSpace(p)
-		if trimmed != "" {
-			parts = append(parts, trimmed)
-		}
-	}
-	return parts
-}
-
-func splitString(s, sep string) []string {
-	result := make([]string, 0)
-	for len(s) > 0 {
-		idx := indexOf(s, sep)
-		if idx < 0 {
-			result = append(result, s)
-			break
-		}
-		result = append(result, s[:idx])
-		s = s[idx+len(sep):]
-	}
-	return result
-}
-
-func indexOf(s, sub string) int {
-	for i := 0; i <= len(s)-len(sub); i++ {
-		if s[i:i+len(sub)] == sub {
-			return i
-		}
-	}
-	return -1
-}
-
-func trimSpace(s string) string {
-	start := 0
-	for start < len(s) && (s[start] == ' ' || s[start] == '\t' || s[start] == '\n' || s[start] == '\r') {
-		start++
-	}
-	end := len(s)
-	for end > start && (s[end-1] == ' ' || s[end-1] == '\t' || s[end-1] == '\n' || s[end-1] == '\r') {
-		end--
-	}
-	return s[start:end]
-}
-
-func contains(s, sub string) bool {
-	return indexOf(s, sub) >= 0
-}
-
-func parseIntParam(s string, out *int) (bool, error) {
-	n := 0
-	for _, c := range s {
-		if c < '0' || c > '9' {
-			return false, nil
-		}
-		n = n*10 + int(c-'0')
-	}
-	*out = n
-	return true, nil
-}
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/credentials"
+	"github.com/tas-agent-builder/services/mcp"
+	"github.com/tas-agent-builder/services/redact"
+	"github.com/tas-agent-builder/services/semver"
+	"gorm.io/datatypes"
+)
+
+// SkillHandlers handles skill CRUD HTTP endpoints
+type SkillHandlers struct {
+	skillService     services.SkillService
+	skillTools       services.SkillToolService
+	credentials      services.CredentialService
+	mcpClients       *mcp.Manager
+	adminRoles       []string
+	globalAdminRoles []string
+}
+
+// NewSkillHandlers creates a new SkillHandlers instance. Users with one of adminRoles manage
+// every skill shared with their tenant and choose which global skills it uses; users with one
+// of globalAdminRoles manage global skills. Only skills written by users with one of
+// adminRoles may use the tenant's credentials.
+func NewSkillHandlers(skillService services.SkillService, skillTools services.SkillToolService, credentialService services.CredentialService, mcpClients *mcp.Manager, adminRoles, globalAdminRoles []string) *SkillHandlers {
+	return &SkillHandlers{
+		skillService:     skillService,
+		skillTools:       skillTools,
+		credentials:      credentialService,
+		mcpClients:       mcpClients,
+		adminRoles:       adminRoles,
+		globalAdminRoles: globalAdminRoles,
+	}
+}
+
+// skillCaller returns the tenant and user skills are resolved for in a request
+func skillCaller(c *gin.Context) services.Caller {
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	return services.Caller{TenantID: c.GetString("tenant_id"), UserID: userID}
+}
+
+// CreateSkill handles POST /api/v1/skills
+func (h *SkillHandlers) CreateSkill(c *gin.Context) {
+	var req models.CreateSkillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	if req.Name == "" || req.DisplayName == "" || req.Type == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name, display_name, and type are required"})
+		return
+	}
+
+	// Validate type
+	switch req.Type {
+	case models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin, models.SkillTypeAgent:
+		// valid
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be 'mcp', 'function', 'builtin', or 'agent'"})
+		return
+	}
+
+	if !validMCPTransport(req.MCPTransport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mcp_transport must be 'streamable_http', 'sse', 'rest', 'stdio', or empty"})
+		return
+	}
+	if req.MCPTransport == mcp.TransportStdio {
+		if !hasRole(c, h.adminRoles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only skill admins may create stdio skills"})
+			return
+		}
+		if err := h.validateStdioCommand(req.MCPCommand, req.MCPArgs, req.MCPEnv); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Type == models.SkillTypeBuiltin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "builtin skills are provided by the platform and cannot be created"})
+		return
+	}
+	if req.Type == models.SkillTypeFunction {
+		if err := req.FunctionTools.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Type == models.SkillTypeAgent && len(req.AgentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "agent skills require agent_ids"})
+		return
+	}
+	if err := redact.Validate(req.AuditRedaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tenantCredentials := hasRole(c, h.adminRoles)
+	if !h.checkSkillAuth(c, req.Type, req.MCPTransport, req.AuthHeaders, req.AuthQuery, tenantCredentials) {
+		return
+	}
+	if req.ToolTimeoutSeconds < 0 || req.ToolMaxRetries < 0 || req.ToolMaxResultTokens < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tool_timeout_seconds, tool_max_retries and tool_max_result_tokens must not be negative"})
+		return
+	}
+	if req.Version != "" {
+		if _, err := semver.Parse(req.Version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	owner, ok := h.newSkillOwner(c, req.Visibility)
+	if !ok {
+		return
+	}
+
+	// Build skill model
+	skill := &models.Skill{
+		Name:         req.Name,
+		DisplayName:  req.DisplayName,
+		Description:  req.Description,
+		Type:         req.Type,
+		Icon:         req.Icon,
+		MCPServerURL: req.MCPServerURL,
+		MCPTransport: req.MCPTransport,
+		MCPCommand:   req.MCPCommand,
+		TenantID:     owner.TenantID,
+		OwnerID:      &owner.UserID,
+		Visibility:   req.Visibility,
+
+		FunctionTools: req.FunctionTools,
+		AuthHeaders:   req.AuthHeaders,
+		AuthQuery:     req.AuthQuery,
+
+		ToolTimeoutSeconds:  req.ToolTimeoutSeconds,
+		ToolMaxRetries:      req.ToolMaxRetries,
+		ToolMaxResultTokens: req.ToolMaxResultTokens,
+		TenantCredentials:   tenantCredentials,
+		Author:              req.Author,
+		Version:             req.Version,
+	}
+
+	if skill.Version == "" {
+		skill.Version = "1.0.0"
+	}
+
+	if req.Tags != nil {
+		tagsJSON, _ := json.Marshal(req.Tags)
+		skill.Tags = datatypes.JSON(tagsJSON)
+	}
+	if req.Keywords != nil {
+		keywordsJSON, _ := json.Marshal(req.Keywords)
+		skill.Keywords = datatypes.JSON(keywordsJSON)
+	}
+	if req.RequiresApproval != nil {
+		approvalJSON, _ := json.Marshal(req.RequiresApproval)
+		skill.RequiresApproval = datatypes.JSON(approvalJSON)
+	}
+	if req.AuditRedaction != nil {
+		skill.AuditRedaction = *req.AuditRedaction
+	}
+	if req.MCPToolNames != nil {
+		toolNamesJSON, _ := json.Marshal(req.MCPToolNames)
+		skill.MCPToolNames = datatypes.JSON(toolNamesJSON)
+	}
+	if req.MCPArgs != nil {
+		argsJSON, _ := json.Marshal(req.MCPArgs)
+		skill.MCPArgs = datatypes.JSON(argsJSON)
+	}
+	if req.MCPEnv != nil {
+		envJSON, _ := json.Marshal(req.MCPEnv)
+		skill.MCPEnv = datatypes.JSON(envJSON)
+	}
+	if req.AgentIDs != nil {
+		agentIDsJSON, _ := json.Marshal(req.AgentIDs)
+		skill.AgentIDs = datatypes.JSON(agentIDsJSON)
+	}
+
+	if err := h.skillService.Create(c.Request.Context(), skill); err != nil {
+		if errors.Is(err, services.ErrSkillExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[SKILLS] Failed to create skill: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create skill: " + err.Error()})
+		return
+	}
+	h.snapshotTools(h.skillContext(c), skill)
+
+	c.JSON(http.StatusCreated, gin.H{"skill": skill})
+}
+
+// newSkillOwner returns the tenant and owner of a new skill with the visibility, writing an
+// error response if the caller may not create it. Skills are private unless asked otherwise.
+func (h *SkillHandlers) newSkillOwner(c *gin.Context, visibility string) (services.Caller, bool) {
+	caller := skillCaller(c)
+	if caller.UserID == uuid.Nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return caller, false
+	}
+	switch {
+	case visibility != "" && !models.ValidSkillVisibility(visibility):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be 'private', 'tenant' or 'global'"})
+		return caller, false
+	case visibility == models.SkillVisibilityGlobal:
+		if !hasRole(c, h.globalAdminRoles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Global skills require a global skill admin role"})
+			return caller, false
+		}
+	case caller.TenantID == "":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant not found in context"})
+		return caller, false
+	}
+	return caller, true
+}
+
+// ListSkills handles GET /api/v1/skills, listing the skills the caller can see. Tenant admins
+// may add ?include_disabled=true to also see the global skills their tenant disabled.
+func (h *SkillHandlers) ListSkills(c *gin.Context) {
+	filter := models.SkillListFilter{
+		Search:     c.Query("search"),
+		Visibility: c.Query("visibility"),
+		Page:       1,
+		Size:       50,
+	}
+	if c.Query("include_disabled") == "true" {
+		if !hasRole(c, h.adminRoles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Listing disabled skills requires an admin role"})
+			return
+		}
+		filter.IncludeDisabled = true
+	}
+
+	if typeStr := c.Query("type"); typeStr != "" {
+		t := models.SkillType(typeStr)
+		filter.Type = &t
+	}
+	if tagsStr := c.Query("tags"); tagsStr != "" {
+		filter.Tags = splitTags(tagsStr)
+	}
+	if pageStr := c.Query("page"); pageStr != "" {
+		var page int
+		if _, err := parseIntParam(pageStr, &page); err == nil && page > 0 {
+			filter.Page = page
+		}
+	}
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		var size int
+		if _, err := parseIntParam(sizeStr, &size); err == nil && size > 0 {
+			filter.Size = size
+		}
+	}
+
+	caller := skillCaller(c)
+	result, err := h.skillService.List(c.Request.Context(), &caller, filter)
+	if err != nil {
+		log.Printf("[SKILLS] Failed to list skills: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list skills"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSkill handles GET /api/v1/skills/:id
+func (h *SkillHandlers) GetSkill(c *gin.Context) {
+	skill, ok := h.loadSkill(c, models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin, models.SkillTypeAgent)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"skill": skill})
+}
+
+// UpdateSkill handles PUT /api/v1/skills/:id
+func (h *SkillHandlers) UpdateSkill(c *gin.Context) {
+	existing, ok := h.loadManagedSkill(c)
+	if !ok {
+		return
+	}
+	id := existing.ID
+
+	var req models.UpdateSkillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	if req.MCPTransport != nil && !validMCPTransport(*req.MCPTransport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mcp_transport must be 'streamable_http', 'sse', 'rest', 'stdio', or empty"})
+		return
+	}
+	transport := existing.MCPTransport
+	if req.MCPTransport != nil {
+		transport = *req.MCPTransport
+	}
+	// Stdio skills run processes on the service's hosts, so only skill admins may touch them
+	if transport == mcp.TransportStdio || existing.MCPTransport == mcp.TransportStdio ||
+		req.MCPCommand != nil || req.MCPArgs != nil || req.MCPEnv != nil {
+		if !hasRole(c, h.adminRoles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only skill admins may change stdio skills"})
+			return
+		}
+	}
+	if transport == mcp.TransportStdio {
+		command, args, env := existing.MCPCommand, req.MCPArgs, req.MCPEnv
+		if req.MCPCommand != nil {
+			command = *req.MCPCommand
+		}
+		if args == nil && existing.MCPArgs != nil {
+			json.Unmarshal(existing.MCPArgs, &args)
+		}
+		if env == nil && existing.MCPEnv != nil {
+			json.Unmarshal(existing.MCPEnv, &env)
+		}
+		if err := h.validateStdioCommand(command, args, env); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.FunctionTools != nil {
+		if err := req.FunctionTools.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.AgentIDs != nil && len(req.AgentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "agent skills require agent_ids"})
+		return
+	}
+	if err := redact.Validate(req.AuditRedaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Visibility != nil {
+		if *req.Visibility != models.SkillVisibilityPrivate && *req.Visibility != models.SkillVisibilityTenant {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "visibility can only be changed to 'private' or 'tenant'"})
+			return
+		}
+		if existing.Visibility == models.SkillVisibilityGlobal {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "global skills stay global"})
+			return
+		}
+	}
+	// The skill keeps using tenant credentials while only skill admins change it, and gains
+	// them when an admin writes its auth templates
+	admin := hasRole(c, h.adminRoles)
+	if !admin {
+		req.TenantCredentials = &admin
+	}
+	if req.AuthHeaders != nil || req.AuthQuery != nil {
+		if !h.checkSkillAuth(c, existing.Type, transport, req.AuthHeaders, req.AuthQuery, admin) {
+			return
+		}
+		if admin {
+			req.TenantCredentials = &admin
+		}
+	}
+	for _, limit := range []*int{req.ToolTimeoutSeconds, req.ToolMaxRetries, req.ToolMaxResultTokens} {
+		if limit != nil && *limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tool_timeout_seconds, tool_max_retries and tool_max_result_tokens must not be negative"})
+			return
+		}
+	}
+	if req.Version != nil {
+		if _, err := semver.Parse(*req.Version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	skill, err := h.skillService.Update(c.Request.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, services.ErrSkillVersionExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[SKILLS] Failed to update skill: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update skill: " + err.Error()})
+		return
+	}
+	h.snapshotTools(h.skillContext(c), skill)
+
+	c.JSON(http.StatusOK, gin.H{"skill": skill})
+}
+
+// DeleteSkill handles DELETE /api/v1/skills/:id. A skill that agents still reference is only
+// deleted with ?force=true; either way the response reports the agents affected.
+func (h *SkillHandlers) DeleteSkill(c *gin.Context) {
+	skill, ok := h.loadManagedSkill(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.skillService.Delete(c.Request.Context(), skill.ID, c.Query("force") == "true")
+	if err != nil {
+		if errors.Is(err, services.ErrSkillInUse) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  err.Error() + "; repeat with ?force=true to delete it anyway",
+				"impact": skillUsageResponse(skill, usage),
+			})
+			return
+		}
+		if err.Error() == "skill not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Skill not found"})
+			return
+		}
+		if contains(err.Error(), "cannot delete system skill") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[SKILLS] Failed to delete skill: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete skill"})
+		return
+	}
+
+	if len(usage) > 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Skill deleted successfully", "impact": skillUsageResponse(skill, usage)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Skill deleted successfully"})
+}
+
+// EnableSkill handles POST /api/v1/skills/:id/enable, letting a tenant admin turn a global
+// skill back on for the tenant
+func (h *SkillHandlers) EnableSkill(c *gin.Context) {
+	h.setTenantEnabled(c, true)
+}
+
+// DisableSkill handles POST /api/v1/skills/:id/disable. Agents of the tenant no longer get the
+// global skill, whether assigned or relevant, and users no longer see it listed.
+func (h *SkillHandlers) DisableSkill(c *gin.Context) {
+	h.setTenantEnabled(c, false)
+}
+
+func (h *SkillHandlers) setTenantEnabled(c *gin.Context, enabled bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid skill ID"})
+		return
+	}
+	caller := skillCaller(c)
+	if caller.TenantID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant not found in context"})
+		return
+	}
+	if !hasRole(c, h.adminRoles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Enabling and disabling global skills requires an admin role"})
+		return
+	}
+
+	skill, err := h.skillService.SetTenantEnabled(c.Request.Context(), caller, id, enabled)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSkillNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Skill not found"})
+		case errors.Is(err, services.ErrSkillNotGlobal):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[SKILLS] Failed to set skill %s enabled=%t for tenant %s: %v", id, enabled, caller.TenantID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update skill"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"skill": skill})
+}
+
+// GetSkillHealth handles GET /api/v1/skills/:id/health
+func (h *SkillHandlers) GetSkillHealth(c *gin.Context) {
+	skill, ok := h.loadSkill(c, models.SkillTypeMCP)
+	if !ok {
+		return
+	}
+
+	health, err := h.skillTools.Health(h.skillContext(c), skill)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"health": health})
+}
+
+// GetSkillTools handles GET /api/v1/skills/:id/tools. The response carries an ETag so clients
+// can revalidate with If-None-Match; ?refresh=true bypasses the cache.
+func (h *SkillHandlers) GetSkillTools(c *gin.Context) {
+	skill, ok := h.loadSkill(c, models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin)
+	if !ok {
+		return
+	}
+
+	tools, err := h.skillTools.ListTools(h.skillContext(c), skill, c.Query("refresh") == "true")
+	if err != nil {
+		if errors.Is(err, services.ErrSkillUnavailable) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", tools.ETag)
+	if etagMatches(c.GetHeader("If-None-Match"), tools.ETag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tools": tools})
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. The comparison is weak, as
+// RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// skillContext returns the request's context, carrying the caller when authenticated
+func (h *SkillHandlers) skillContext(c *gin.Context) context.Context {
+	if userID, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		return callerContext(c, userID)
+	}
+	return c.Request.Context()
+}
+
+// loadSkill loads the skill named by the :id parameter, writing an error response and
+// returning false if it is missing, not visible to the caller or not one of the given types
+func (h *SkillHandlers) loadSkill(c *gin.Context, types ...models.SkillType) (*models.Skill, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid skill ID"})
+		return nil, false
+	}
+
+	caller := skillCaller(c)
+	skill, err := h.skillService.GetByID(c.Request.Context(), id)
+	if err != nil || !skill.VisibleTo(caller.TenantID, caller.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Skill not found"})
+		return nil, false
+	}
+
+	for _, t := range types {
+		if skill.Type == t {
+			return skill, true
+		}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Not supported for %s skills", skill.Type)})
+	return nil, false
+}
+
+// loadManagedSkill loads the skill named by the :id parameter like loadSkill, also writing an
+// error response and returning false if the caller may not change it
+func (h *SkillHandlers) loadManagedSkill(c *gin.Context) (*models.Skill, bool) {
+	skill, ok := h.loadSkill(c, models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin, models.SkillTypeAgent)
+	if !ok {
+		return nil, false
+	}
+	if !h.canManage(c, skill) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the skill's owner or an admin may change it"})
+		return nil, false
+	}
+	return skill, true
+}
+
+// canManage reports whether the caller may change or delete a skill they can see: their own,
+// their tenant's shared skills if they are a tenant admin, and global skills if they are a
+// global skill admin
+func (h *SkillHandlers) canManage(c *gin.Context, skill *models.Skill) bool {
+	if skill.Visibility == models.SkillVisibilityGlobal {
+		return hasRole(c, h.globalAdminRoles)
+	}
+	caller := skillCaller(c)
+	if skill.OwnerID != nil && *skill.OwnerID == caller.UserID {
+		return true
+	}
+	return skill.Visibility == models.SkillVisibilityTenant && hasRole(c, h.adminRoles)
+}
+
+// helpers
+
+func validMCPTransport(t string) bool {
+	switch t {
+	case mcp.TransportAuto, mcp.TransportStreamableHTTP, mcp.TransportSSE, mcp.TransportLegacyREST, mcp.TransportStdio:
+		return true
+	}
+	return false
+}
+
+// headerName matches valid HTTP header names
+var headerName = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// errTenantCredential is returned for auth templates that use a tenant credential in a skill
+// not written by a skill admin
+var errTenantCredential = errors.New("only skills written by skill admins may use the tenant's credentials")
+
+// checkSkillAuth validates a skill's auth templates for the caller, writing an error response
+// and returning false if they are rejected. Unless tenantCredentials is set, the templates may
+// only use the caller's own credentials: otherwise anyone could point a skill at their own
+// server and have it send the tenant's secrets there.
+func (h *SkillHandlers) checkSkillAuth(c *gin.Context, skillType models.SkillType, transport string, headers, query models.CredentialTemplates, tenantCredentials bool) bool {
+	var tenantOnly func(name string) bool
+	if !tenantCredentials && h.credentials != nil && (len(headers) > 0 || len(query) > 0) {
+		creds, err := h.credentials.List(c.Request.Context(), skillCaller(c))
+		if err != nil && !errors.Is(err, services.ErrCredentialsDisabled) {
+			log.Printf("[SKILLS] Failed to list credentials: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the skill's credentials"})
+			return false
+		}
+		own, tenant := make(map[string]bool), make(map[string]bool)
+		for _, cred := range creds {
+			if cred.UserID != nil {
+				own[cred.Name] = true
+			} else {
+				tenant[cred.Name] = true
+			}
+		}
+		tenantOnly = func(name string) bool {
+			return tenant[name] && !own[name]
+		}
+	}
+
+	if err := validateSkillAuth(skillType, transport, headers, query, tenantOnly); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errTenantCredential) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// validateSkillAuth checks a skill's auth templates, which apply to HTTP servers only. Templates
+// may not use the credentials tenantOnly reports, if it is set.
+func validateSkillAuth(skillType models.SkillType, transport string, headers, query models.CredentialTemplates, tenantOnly func(name string) bool) error {
+	if len(headers) == 0 && len(query) == 0 {
+		return nil
+	}
+	if skillType == models.SkillTypeBuiltin || skillType == models.SkillTypeAgent || transport == mcp.TransportStdio {
+		return fmt.Errorf("auth_headers and auth_query apply only to skills reached over HTTP")
+	}
+	for name, template := range headers {
+		if !headerName.MatchString(name) {
+			return fmt.Errorf("auth_headers: %q is not a valid header name", name)
+		}
+		if err := validateCredentialTemplate(template, tenantOnly); err != nil {
+			return fmt.Errorf("auth_headers[%s]: %w", name, err)
+		}
+	}
+	for name, template := range query {
+		if name == "" {
+			return fmt.Errorf("auth_query: parameter names must not be empty")
+		}
+		if err := validateCredentialTemplate(template, tenantOnly); err != nil {
+			return fmt.Errorf("auth_query[%s]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateCredentialTemplate rejects placeholders other than {{credential:name}} and references
+// to the credentials tenantOnly reports
+func validateCredentialTemplate(template string, tenantOnly func(name string) bool) error {
+	names := credentials.References(template)
+	if strings.Count(template, "{{") != len(names) {
+		return fmt.Errorf("placeholders must have the form {{credential:name}}")
+	}
+	if tenantOnly != nil {
+		for _, name := range names {
+			if tenantOnly(name) {
+				return fmt.Errorf("credential %q belongs to the tenant: %w", name, errTenantCredential)
+			}
+		}
+	}
+	return nil
+}
+
+// validateStdioCommand checks a stdio skill's command against the configured allowlist, its
+// arguments against the command's pattern and its environment against the reserved variables
+func (h *SkillHandlers) validateStdioCommand(command string, args []string, env map[string]string) error {
+	if command == "" {
+		return fmt.Errorf("mcp_command is required for the stdio transport")
+	}
+	if h.mcpClients == nil || !h.mcpClients.StdioLimits().Allowed(command) {
+		return fmt.Errorf("mcp_command %q is not allowed; see MCP_STDIO_ALLOWED_COMMANDS", command)
+	}
+	if err := h.mcpClients.StdioLimits().CheckArgs(command, args); err != nil {
+		return fmt.Errorf("mcp_args: %w; see MCP_STDIO_ARG_PATTERNS", err)
+	}
+	if err := mcp.CheckEnv(env); err != nil {
+		return fmt.Errorf("mcp_env: %w", err)
+	}
+	return nil
+}
+
+func splitTags(s string) []string {
+	parts := make([]string, 0)
+	for _, p := range splitString(s, ",") {
+		trimmed := trimSpace(p)
+		if trimmed != "" {
+			parts = append(parts, trimmed)
+		}
+	}
+	return parts
+}
+
+func splitString(s, sep string) []string {
+	result := make([]string, 0)
+	for len(s) > 0 {
+		idx := indexOf(s, sep)
+		if idx < 0 {
+			result = append(result, s)
+			break
+		}
+		result = append(result, s[:idx])
+		s = s[idx+len(sep):]
+	}
+	return result
+}
+
+func indexOf(s, sub string) int {
+	for i := 0; i <= len(s)-len(sub); i++ {
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+func trimSpace(s string) string {
+	start := 0
+	for start < len(s) && (s[start] == ' ' || s[start] == '\t' || s[start] == '\n' || s[start] == '\r') {
+		start++
+	}
+	end := len(s)
+	for end > start && (s[end-1] == ' ' || s[end-1] == '\t' || s[end-1] == '\n' || s[end-1] == '\r') {
+		end--
+	}
+	return s[start:end]
+}
+
+func contains(s, sub string) bool {
+	return indexOf(s, sub) >= 0
+}
+
+func parseIntParam(s string, out *int) (bool, error) {
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false, nil
+		}
+		n = n*10 + int(c-'0')
+	}
+	*out = n
+	return true, nil
+}
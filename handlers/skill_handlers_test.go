@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/mcp"
+	"gorm.io/datatypes"
+)
+
+// stubSkills serves one skill and counts the creates and updates it is asked for. Both report
+// a conflict so a request that passes validation stops there.
+type stubSkills struct {
+	services.SkillService
+	skill      *models.Skill
+	created    int
+	updated    int
+	lastCreate *models.Skill
+	lastUpdate models.UpdateSkillRequest
+}
+
+func (s *stubSkills) GetByID(ctx context.Context, id uuid.UUID) (*models.Skill, error) {
+	if s.skill == nil || s.skill.ID != id {
+		return nil, errors.New("skill not found")
+	}
+	return s.skill, nil
+}
+
+func (s *stubSkills) Create(ctx context.Context, skill *models.Skill) error {
+	s.created++
+	s.lastCreate = skill
+	return services.ErrSkillExists
+}
+
+func (s *stubSkills) Update(ctx context.Context, id uuid.UUID, req models.UpdateSkillRequest) (*models.Skill, error) {
+	s.updated++
+	s.lastUpdate = req
+	return nil, services.ErrSkillVersionExists
+}
+
+func TestStdioSkillsRequireAdminAndSafeCommands(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+	args, _ := json.Marshal([]string{"-y", "@modelcontextprotocol/server-github"})
+	skills := &stubSkills{skill: &models.Skill{
+		ID:           uuid.New(),
+		Name:         "github",
+		Type:         models.SkillTypeMCP,
+		MCPTransport: mcp.TransportStdio,
+		MCPCommand:   "npx",
+		MCPArgs:      datatypes.JSON(args),
+		TenantID:     "tenant-a",
+		OwnerID:      &userID,
+		Visibility:   models.SkillVisibilityPrivate,
+	}}
+
+	manager := mcp.NewManager(mcp.Implementation{Name: "test"}, time.Second)
+	defer manager.Close()
+	patterns, err := mcp.CompileArgPatterns(map[string]string{"npx": `-y|@modelcontextprotocol/server-[a-z-]+`})
+	require.NoError(t, err)
+	manager.SetStdioLimits(mcp.StdioLimits{AllowedCommands: []string{"npx"}, ArgPatterns: patterns})
+	h := NewSkillHandlers(skills, nil, nil, manager, []string{"admin"}, []string{"platform-admin"})
+
+	send := func(handle gin.HandlerFunc, method string, body map[string]interface{}, roles ...string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/api/v1/skills", bytes.NewReader(data))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: skills.skill.ID.String()}}
+		c.Set("user_id", userID.String())
+		c.Set("tenant_id", "tenant-a")
+		c.Set("roles", roles)
+		handle(c)
+		return w
+	}
+	create := func(env map[string]string, args []string, roles ...string) *httptest.ResponseRecorder {
+		return send(h.CreateSkill, http.MethodPost, map[string]interface{}{
+			"name":          "github",
+			"display_name":  "GitHub",
+			"type":          "mcp",
+			"mcp_transport": "stdio",
+			"mcp_command":   "npx",
+			"mcp_args":      args,
+			"mcp_env":       env,
+		}, roles...)
+	}
+	serverArgs := []string{"-y", "@modelcontextprotocol/server-github"}
+
+	t.Run("non-admins cannot create stdio skills", func(t *testing.T) {
+		w := create(nil, serverArgs)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	})
+
+	t.Run("loader variables are rejected", func(t *testing.T) {
+		w := create(map[string]string{"LD_PRELOAD": "/tmp/evil.so"}, serverArgs, "admin")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "LD_")
+	})
+
+	t.Run("arguments must match the command's pattern", func(t *testing.T) {
+		w := create(nil, []string{"-y", "evil-package"}, "admin")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "MCP_STDIO_ARG_PATTERNS")
+	})
+
+	t.Run("admins create stdio skills", func(t *testing.T) {
+		w := create(map[string]string{"GITHUB_TOKEN": "t"}, serverArgs, "admin")
+		assert.Equal(t, http.StatusConflict, w.Code, "validation passed and the stub reported a conflict")
+		assert.Equal(t, 1, skills.created)
+	})
+
+	t.Run("owners who are not admins cannot change their stdio skills", func(t *testing.T) {
+		w := send(h.UpdateSkill, http.MethodPut, map[string]interface{}{"description": "mine"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = send(h.UpdateSkill, http.MethodPut, map[string]interface{}{"mcp_env": map[string]string{"LD_PRELOAD": "/tmp/evil.so"}}, "admin")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send(h.UpdateSkill, http.MethodPut, map[string]interface{}{"description": "mine"}, "admin")
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		assert.Equal(t, 1, skills.updated)
+	})
+}
+
+// stubCredentials lists a fixed set of credentials
+type stubCredentials struct {
+	services.CredentialService
+	creds []models.Credential
+}
+
+func (s *stubCredentials) List(ctx context.Context, caller services.Caller) ([]models.Credential, error) {
+	return s.creds, nil
+}
+
+func TestOnlySkillAdminsUseTenantCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+	skills := &stubSkills{skill: &models.Skill{
+		ID:                uuid.New(),
+		Name:              "crm",
+		Type:              models.SkillTypeMCP,
+		MCPServerURL:      "https://crm.example.com/mcp",
+		TenantID:          "tenant-a",
+		OwnerID:           &userID,
+		Visibility:        models.SkillVisibilityTenant,
+		TenantCredentials: true,
+	}}
+	creds := &stubCredentials{creds: []models.Credential{
+		{ID: uuid.New(), TenantID: "tenant-a", Name: "crm-token"},
+		{ID: uuid.New(), TenantID: "tenant-a", Name: "github", UserID: &userID},
+	}}
+	h := NewSkillHandlers(skills, nil, creds, nil, []string{"admin"}, []string{"platform-admin"})
+
+	send := func(handle gin.HandlerFunc, method string, body map[string]interface{}, roles ...string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/api/v1/skills", bytes.NewReader(data))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: skills.skill.ID.String()}}
+		c.Set("user_id", userID.String())
+		c.Set("tenant_id", "tenant-a")
+		c.Set("roles", roles)
+		handle(c)
+		return w
+	}
+	create := func(credential string, roles ...string) *httptest.ResponseRecorder {
+		return send(h.CreateSkill, http.MethodPost, map[string]interface{}{
+			"name":           "exfil",
+			"display_name":   "Exfil",
+			"type":           "mcp",
+			"mcp_server_url": "https://attacker.example.com/mcp",
+			"auth_headers":   map[string]string{"X-Token": "{{credential:" + credential + "}}"},
+		}, roles...)
+	}
+
+	t.Run("members cannot send tenant credentials to their own servers", func(t *testing.T) {
+		w := create("crm-token")
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "crm-token")
+		assert.Equal(t, 0, skills.created)
+	})
+
+	t.Run("members use their own credentials", func(t *testing.T) {
+		w := create("github")
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		require.NotNil(t, skills.lastCreate)
+		assert.False(t, skills.lastCreate.TenantCredentials)
+	})
+
+	t.Run("admins' skills use tenant credentials", func(t *testing.T) {
+		w := create("crm-token", "admin")
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		assert.True(t, skills.lastCreate.TenantCredentials)
+	})
+
+	t.Run("updates by members revoke tenant credentials", func(t *testing.T) {
+		w := send(h.UpdateSkill, http.MethodPut, map[string]interface{}{"auth_headers": map[string]string{"X-Token": "{{credential:crm-token}}"}})
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+		w = send(h.UpdateSkill, http.MethodPut, map[string]interface{}{"mcp_server_url": "https://attacker.example.com/mcp"})
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		require.NotNil(t, skills.lastUpdate.TenantCredentials)
+		assert.False(t, *skills.lastUpdate.TenantCredentials)
+
+		w = send(h.UpdateSkill, http.MethodPut, map[string]interface{}{"description": "shared"}, "admin")
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		assert.Nil(t, skills.lastUpdate.TenantCredentials, "admins' other changes leave the flag alone")
+	})
+}
+
+func (s *stubSkills) GetByName(ctx context.Context, caller services.Caller, name string) (*models.Skill, error) {
+	if s.skill == nil || s.skill.Name != name {
+		return nil, services.ErrSkillNotFound
+	}
+	return s.skill, nil
+}
+
+func TestImportOpenAPIStoresNoSecrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := uuid.New()
+	skills := &stubSkills{}
+	creds := &stubCredentials{creds: []models.Credential{
+		{ID: uuid.New(), TenantID: "tenant-a", Name: "pets-token", UserID: &userID},
+		{ID: uuid.New(), TenantID: "tenant-a", Name: "tenant-token"},
+	}}
+	h := NewSkillHandlers(skills, nil, creds, nil, []string{"admin"}, []string{"platform-admin"})
+
+	spec := `{"openapi": "3.0.3", "info": {"title": "Pets"},
+		"servers": [{"url": "https://pets.example.com"}],
+		"security": [{"key": []}],
+		"components": {"securitySchemes": {"key": {"type": "apiKey", "in": "query", "name": "api_key"}}},
+		"paths": {"/pets": {"get": {"operationId": "listPets", "responses": {"200": {"description": "ok"}}}}}}`
+	send := func(credential string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(map[string]interface{}{
+			"spec": json.RawMessage(spec),
+			"auth": map[string]interface{}{"key": map[string]string{"credential": credential, "value": "s3cret"}},
+		})
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/skills/import/openapi", bytes.NewReader(data))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", userID.String())
+		c.Set("tenant_id", "tenant-a")
+		h.ImportOpenAPI(c)
+		return w
+	}
+
+	w := send("pets-token")
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	require.NotNil(t, skills.lastCreate)
+	stored, _ := json.Marshal(skills.lastCreate)
+	assert.NotContains(t, string(stored), "s3cret")
+	assert.Equal(t, models.CredentialTemplates{"api_key": "{{credential:pets-token}}"}, skills.lastCreate.AuthQuery)
+	assert.Equal(t, "https://pets.example.com/pets", skills.lastCreate.FunctionTools[0].URL)
+
+	w = send("tenant-token")
+	assert.Equal(t, http.StatusForbidden, w.Code, "members cannot import with the tenant's credentials")
+	assert.Equal(t, 1, skills.created)
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc123"`
+	assert.True(t, etagMatches(`"abc123"`, etag))
+	assert.True(t, etagMatches(`W/"abc123"`, etag), "If-None-Match compares weakly")
+	assert.True(t, etagMatches(`"other", "abc123"`, etag), "any listed tag matches")
+	assert.True(t, etagMatches(`*`, etag))
+	assert.False(t, etagMatches(`"other"`, etag))
+	assert.False(t, etagMatches("", etag))
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/openapi"
+)
+
+// maxOpenAPISpecBytes bounds an uploaded OpenAPI document
+const maxOpenAPISpecBytes = 10 << 20
+
+// ImportOpenAPI handles POST /api/v1/skills/import/openapi. The spec is either uploaded as the
+// multipart file "spec", with the other request fields as JSON in the form field "options",
+// or sent in the JSON body's "spec" field. Importing under the name of an existing function
+// skill replaces its tools, reports the diff and bumps its version.
+func (h *SkillHandlers) ImportOpenAPI(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxOpenAPISpecBytes)
+
+	req, spec, err := readOpenAPIImport(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, err := openapi.Parse(spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	imported, err := doc.Import(openapi.Options{
+		ServerURL:  req.ServerURL,
+		Operations: req.Operations,
+		Auth:       req.Auth,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = skillSlug(imported.Title)
+	}
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required when the spec has no info.title"})
+		return
+	}
+
+	resp := models.OpenAPIImportResponse{Warnings: imported.Warnings, DryRun: req.DryRun}
+	ctx := c.Request.Context()
+
+	existing, err := h.skillService.GetByName(ctx, skillCaller(c), name)
+	if err != nil && !errors.Is(err, services.ErrSkillNotFound) {
+		log.Printf("[SKILLS] Failed to look up skill %q: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up skill"})
+		return
+	}
+	if existing != nil && !h.canManage(c, existing) {
+		if existing.Visibility != models.SkillVisibilityGlobal {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("skill %q belongs to another user", name)})
+			return
+		}
+		existing = nil // The tenant's own skill of the same name takes precedence over the global one
+	}
+
+	// Imports store only {{credential:name}} templates, which are checked like a skill's own
+	admin := hasRole(c, h.adminRoles)
+	if existing == nil {
+		if !h.checkSkillAuth(c, models.SkillTypeFunction, "", imported.AuthHeaders, imported.AuthQuery, admin) {
+			return
+		}
+		owner, ok := h.newSkillOwner(c, req.Visibility)
+		if !ok {
+			return
+		}
+		skill := &models.Skill{
+			Name:          name,
+			DisplayName:   firstNonEmpty(req.DisplayName, imported.Title, name),
+			Description:   truncateRunes(firstNonEmpty(req.Description, imported.Description), 1000),
+			Type:          models.SkillTypeFunction,
+			FunctionTools: imported.Tools,
+			AuthHeaders:   imported.AuthHeaders,
+			AuthQuery:     imported.AuthQuery,
+			TenantID:      owner.TenantID,
+			OwnerID:       &owner.UserID,
+			Visibility:    req.Visibility,
+			Version:       "1.0.0",
+
+			TenantCredentials: admin,
+		}
+		resp.Skill = skill
+		resp.Created = true
+		if !req.DryRun {
+			if err := h.skillService.Create(ctx, skill); err != nil {
+				if errors.Is(err, services.ErrSkillExists) {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+					return
+				}
+				log.Printf("[SKILLS] Failed to create imported skill: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create skill: " + err.Error()})
+				return
+			}
+			log.Printf("[SKILLS] Imported OpenAPI spec as skill %q with %d tools", name, len(imported.Tools))
+		}
+		c.JSON(http.StatusCreated, resp)
+		return
+	}
+
+	if existing.Type != models.SkillTypeFunction {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("skill %q exists and is not a function skill", name)})
+		return
+	}
+
+	diff := openapi.Diff(existing.FunctionTools, imported.Tools)
+	version := openapi.NextVersion(existing.Version, existing.FunctionTools, imported.Tools, diff)
+	resp.Diff = &diff
+	resp.PreviousVersion = existing.Version
+
+	update := models.UpdateSkillRequest{
+		FunctionTools: imported.Tools,
+		Version:       &version,
+	}
+	if req.DisplayName != "" {
+		update.DisplayName = &req.DisplayName
+	}
+	if req.Description != "" {
+		update.Description = &req.Description
+	}
+	// As with updates, a member's re-import stops the skill using tenant credentials, and an
+	// admin's re-import with auth lets it
+	if !admin {
+		update.TenantCredentials = &admin
+	}
+	authChanged := false
+	if len(req.Auth) > 0 {
+		if !h.checkSkillAuth(c, models.SkillTypeFunction, "", imported.AuthHeaders, imported.AuthQuery, admin) {
+			return
+		}
+		update.AuthHeaders = orEmpty(imported.AuthHeaders)
+		update.AuthQuery = orEmpty(imported.AuthQuery)
+		authChanged = !reflect.DeepEqual(update.AuthHeaders, orEmpty(existing.AuthHeaders)) ||
+			!reflect.DeepEqual(update.AuthQuery, orEmpty(existing.AuthQuery))
+		if admin {
+			update.TenantCredentials = &admin
+		}
+	}
+
+	if req.DryRun || (diff.Empty() && !authChanged && update.DisplayName == nil && update.Description == nil) {
+		preview := *existing
+		preview.FunctionTools = imported.Tools
+		preview.Version = version
+		if update.AuthHeaders != nil {
+			preview.AuthHeaders, preview.AuthQuery = imported.AuthHeaders, imported.AuthQuery
+		}
+		resp.Skill = &preview
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	skill, err := h.skillService.Update(ctx, existing.ID, update)
+	if err != nil {
+		log.Printf("[SKILLS] Failed to update imported skill: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update skill: " + err.Error()})
+		return
+	}
+	log.Printf("[SKILLS] Re-imported skill %q: %s -> %s (+%d -%d ~%d)", name, existing.Version, version,
+		len(diff.Added), len(diff.Removed), len(diff.Changed))
+
+	resp.Skill = skill
+	c.JSON(http.StatusOK, resp)
+}
+
+// readOpenAPIImport reads the import options and the raw spec from a multipart upload or a
+// JSON body
+func readOpenAPIImport(c *gin.Context) (*models.OpenAPIImportRequest, []byte, error) {
+	var req models.OpenAPIImportRequest
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("spec")
+		if err != nil {
+			return nil, nil, fmt.Errorf("multipart uploads need the spec in the file field \"spec\"")
+		}
+		if options := c.PostForm("options"); options != "" {
+			if err := json.Unmarshal([]byte(options), &req); err != nil {
+				return nil, nil, fmt.Errorf("invalid options: %w", err)
+			}
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read spec: %w", err)
+		}
+		defer f.Close()
+		spec, err := io.ReadAll(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read spec: %w", err)
+		}
+		return &req, spec, nil
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	spec := bytes.TrimSpace(req.Spec)
+	if len(spec) == 0 || bytes.Equal(spec, []byte("null")) {
+		return nil, nil, fmt.Errorf("spec is required")
+	}
+	// A string holds the document text, which may be YAML
+	if spec[0] == '"' {
+		var text string
+		if err := json.Unmarshal(spec, &text); err != nil {
+			return nil, nil, fmt.Errorf("invalid spec: %w", err)
+		}
+		spec = []byte(text)
+	}
+	return &req, spec, nil
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// skillSlug derives a skill name such as "pet-store" from an API title
+func skillSlug(title string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+}
+
+// orEmpty returns templates, or an empty set that clears the stored ones on update
+func orEmpty(templates models.CredentialTemplates) models.CredentialTemplates {
+	if templates == nil {
+		return models.CredentialTemplates{}
+	}
+	return templates
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// ListSkillVersions handles GET /api/v1/skills/:id/versions
+func (h *SkillHandlers) ListSkillVersions(c *gin.Context) {
+	skill, ok := h.loadSkill(c, models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin, models.SkillTypeAgent)
+	if !ok {
+		return
+	}
+
+	versions, err := h.skillService.ListVersions(c.Request.Context(), skill.ID)
+	if err != nil {
+		log.Printf("[SKILLS] Failed to list versions of skill %q: %v", skill.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list skill versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SkillVersionListResponse{Versions: versions, Total: len(versions)})
+}
+
+// DeprecateSkillVersion handles POST /api/v1/skills/:id/versions/:version/deprecate. Agents
+// pinned to a deprecated version keep using it but are flagged in their executions and in the
+// skill's usage.
+func (h *SkillHandlers) DeprecateSkillVersion(c *gin.Context) {
+	skill, ok := h.loadManagedSkill(c)
+	if !ok {
+		return
+	}
+
+	var req models.DeprecateSkillVersionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+	}
+
+	version, err := h.skillService.DeprecateVersion(c.Request.Context(), skill.ID, c.Param("version"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrSkillVersionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Skill version not found"})
+			return
+		}
+		log.Printf("[SKILLS] Failed to deprecate version %s of skill %q: %v", c.Param("version"), skill.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deprecate skill version"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"version": version})
+}
+
+// GetSkillUsage handles GET /api/v1/skills/:id/usage, listing the agents that reference the
+// skill and flagging those pinned to deprecated versions
+func (h *SkillHandlers) GetSkillUsage(c *gin.Context) {
+	skill, ok := h.loadSkill(c, models.SkillTypeMCP, models.SkillTypeFunction, models.SkillTypeBuiltin, models.SkillTypeAgent)
+	if !ok {
+		return
+	}
+
+	usage, err := h.skillService.Usage(c.Request.Context(), skill)
+	if err != nil {
+		log.Printf("[SKILLS] Failed to get usage of skill %q: %v", skill.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get skill usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, skillUsageResponse(skill, usage))
+}
+
+func skillUsageResponse(skill *models.Skill, usage []models.SkillUsage) models.SkillUsageResponse {
+	resp := models.SkillUsageResponse{Skill: skill.Name, Agents: usage, Total: len(usage)}
+	if resp.Agents == nil {
+		resp.Agents = []models.SkillUsage{}
+	}
+	for _, u := range usage {
+		if u.Deprecated {
+			resp.Deprecated++
+		}
+	}
+	return resp
+}
+
+// snapshotTools records the tools an MCP skill's server offers now with its current version,
+// so agents pinned to the version keep seeing these schemas. Failures are only logged.
+func (h *SkillHandlers) snapshotTools(ctx context.Context, skill *models.Skill) {
+	if skill.Type != models.SkillTypeMCP {
+		return
+	}
+	list, err := h.skillTools.ListTools(ctx, skill, true)
+	if err != nil {
+		log.Printf("[SKILLS] Tools of skill %q version %s were not recorded: %v", skill.Name, skill.Version, err)
+		return
+	}
+	if err := h.skillService.RecordToolSnapshot(ctx, skill.ID, skill.Version, list.Tools); err != nil {
+		log.Printf("[SKILLS] Tools of skill %q version %s were not recorded: %v", skill.Name, skill.Version, err)
+	}
+}
+
+// validateSkillRefs checks that each of an agent's skill references names a skill the caller
+// can use and, if pinned, a version of it
+func (h *AgentHandlers) validateSkillRefs(ctx context.Context, caller services.Caller, refs []string) error {
+	for _, ref := range refs {
+		name, _ := models.ParseSkillRef(ref)
+		if name == "" {
+			return fmt.Errorf("skill reference %q has no skill name", ref)
+		}
+		if h.skillService == nil {
+			continue
+		}
+		if _, err := h.skillService.Resolve(ctx, caller, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
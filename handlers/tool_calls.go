@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/jsonschema"
+	"github.com/tas-agent-builder/services/tokenizer"
+	"gorm.io/datatypes"
+)
+
+const (
+	// defaultToolConcurrency bounds tool calls run at once when none is configured
+	defaultToolConcurrency = 4
+
+	// defaultToolResultTokens caps a tool result appended to the conversation when the skill
+	// sets no limit
+	defaultToolResultTokens = 8000
+)
+
+// executeToolCalls runs the tool calls of one assistant turn concurrently, bounded by the
+// configured worker count, and returns their tool messages in the order the model issued them
+func (h *AgentHandlers) executeToolCalls(ctx context.Context, agent *models.Agent, calls []services.ToolCall, loop *toolLoop) []services.Message {
+	workers := h.mcpToolConcurrency
+	if workers <= 0 {
+		workers = defaultToolConcurrency
+	}
+	if workers > len(calls) {
+		workers = len(calls)
+	}
+
+	results := make([]services.Message, len(calls))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				tc := calls[i]
+				results[i] = services.Message{
+					Role:       "tool",
+					Content:    h.executeToolCall(ctx, agent, tc, loop),
+					ToolCallID: tc.ID,
+				}
+			}
+		}()
+	}
+	for i := range calls {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
+
+// executeToolCall invokes one tool and returns the content of its tool message. Failures are
+// reported to the model as text rather than ending the loop. Every call is audited.
+func (h *AgentHandlers) executeToolCall(ctx context.Context, agent *models.Agent, tc services.ToolCall, loop *toolLoop) (content string) {
+	log.Printf("[MCP-TOOLS] Executing tool: %s (id=%s)", tc.Function.Name, tc.ID)
+	start := time.Now()
+
+	audit := &models.ExecutionToolCall{Status: models.ToolCallStatusSuccess}
+	defer func() {
+		audit.Result = content
+		audit.DurationMs = int(time.Since(start).Milliseconds())
+		h.auditToolCall(ctx, agent, loop, tc, audit)
+	}()
+
+	// Arguments that don't match the tool's schema go back to the model, which can correct them
+	args, violations := loop.parseArguments(tc)
+	loop.trace.record(tc.Function.Name, len(violations) > 0)
+	if len(violations) > 0 {
+		log.Printf("[MCP-TOOLS] Rejected call to %s with %d invalid arguments: %s", tc.Function.Name, len(violations), violations[0])
+		audit.Status = models.ToolCallStatusInvalidArguments
+		return invalidArgumentsMessage(tc.Function.Name, violations)
+	}
+	skill := loop.toolSkills[tc.Function.Name]
+
+	maxTokens := defaultToolResultTokens
+	var resultContent string
+
+	// Route tool invocation to the correct MCP server
+	if skill != nil {
+		if skill.ToolMaxResultTokens > 0 {
+			maxTokens = skill.ToolMaxResultTokens
+		}
+
+		// Invoke through the skill providing this tool; builtin tools run in process and agent
+		// tools run the agent's own execution
+		var result string
+		var err error
+		if skill.Type == models.SkillTypeAgent {
+			result, err = h.callAgentTool(ctx, agent, skill, tc.Function.Name, args, loop.run)
+		} else {
+			result, err = h.skillTools.CallTool(ctx, skill, tc.Function.Name, args)
+		}
+		if err != nil {
+			log.Printf("[MCP-TOOLS] Tool %s error after %s: %v", tc.Function.Name, time.Since(start), err)
+			audit.Status, audit.Error = models.ToolCallStatusError, err.Error()
+			return fmt.Sprintf("Error invoking tool: %v", err)
+		}
+		resultContent = result
+		log.Printf("[MCP-TOOLS] Tool %s succeeded in %s, result length: %d", tc.Function.Name, time.Since(start), len(resultContent))
+	} else {
+		// Fall back to default MCP context service
+		toolResp, err := h.mcpContextService.InvokeTool(ctx, models.MCPToolRequest{
+			ToolName:   tc.Function.Name,
+			Parameters: args,
+		})
+
+		if err != nil {
+			log.Printf("[MCP-TOOLS] Tool %s error: %v", tc.Function.Name, err)
+			audit.Status, audit.Error = models.ToolCallStatusError, err.Error()
+			return fmt.Sprintf("Error invoking tool: %v", err)
+		}
+		if !toolResp.Success {
+			log.Printf("[MCP-TOOLS] Tool %s failed: %s", tc.Function.Name, toolResp.Error)
+			audit.Status, audit.Error = models.ToolCallStatusError, toolResp.Error
+			return fmt.Sprintf("Tool error: %s", toolResp.Error)
+		}
+
+		resultBytes, err := json.Marshal(toolResp.Result)
+		if err != nil {
+			resultContent = fmt.Sprintf("%v", toolResp.Result)
+		} else {
+			resultContent = string(resultBytes)
+		}
+		log.Printf("[MCP-TOOLS] Tool %s succeeded in %dms, result length: %d", tc.Function.Name, toolResp.ExecutionMs, len(resultContent))
+	}
+
+	return truncateToolResult(tokenizer.FromContext(ctx), resultContent, maxTokens)
+}
+
+// auditToolCall completes a call's audit record from the loop and stores it. Calls outside an
+// execution record, as in internal runs, are not audited; failures to store are only logged.
+func (h *AgentHandlers) auditToolCall(ctx context.Context, agent *models.Agent, loop *toolLoop, tc services.ToolCall, call *models.ExecutionToolCall) {
+	if h.toolCallAudit == nil || loop.run == nil || loop.run.ExecutionID == uuid.Nil {
+		return
+	}
+	userID, err := uuid.Parse(loop.run.UserID)
+	if err != nil {
+		return
+	}
+
+	call.ExecutionID = loop.run.ExecutionID
+	call.TenantID = loop.run.TenantID
+	call.UserID = userID
+	call.AgentID = agent.ID
+	call.Iteration = loop.iteration
+	call.ToolCallID = tc.ID
+	call.ToolName = tc.Function.Name
+	call.Arguments = rawArguments(tc.Function.Arguments)
+	if d, ok := loop.decisions[tc.ID]; ok {
+		call.ApprovalDecision = d.approval()
+		call.ApprovedBy = d.Actor
+	}
+
+	// Record the call even when the request that made it has gone away
+	if err := h.toolCallAudit.Record(context.WithoutCancel(ctx), loop.toolSkills[tc.Function.Name], call); err != nil {
+		log.Printf("[AUDIT] Failed to record call to %s in execution %s: %v", tc.Function.Name, call.ExecutionID, err)
+	}
+}
+
+// rawArguments keeps a call's arguments as JSON, as a JSON string if the model's were not valid
+func rawArguments(raw string) datatypes.JSON {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return datatypes.JSON("{}")
+	}
+	if json.Valid([]byte(raw)) {
+		return datatypes.JSON(raw)
+	}
+	quoted, _ := json.Marshal(raw)
+	return datatypes.JSON(quoted)
+}
+
+// parseArguments decodes a call's arguments and checks them against the tool's input schema.
+// Empty arguments mean no arguments.
+func (l *toolLoop) parseArguments(tc services.ToolCall) (map[string]interface{}, []jsonschema.Violation) {
+	args := make(map[string]interface{})
+	if raw := strings.TrimSpace(tc.Function.Arguments); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil || args == nil {
+			msg := "arguments must be a JSON object"
+			if err != nil {
+				msg = fmt.Sprintf("arguments are not a valid JSON object: %v", err)
+			}
+			return nil, []jsonschema.Violation{{Message: msg}}
+		}
+	}
+	if schema := l.schemas[tc.Function.Name]; schema != nil {
+		if violations := jsonschema.Validate(schema, args); len(violations) > 0 {
+			return nil, violations
+		}
+	}
+	return args, nil
+}
+
+// invalidArgumentsMessage is the tool message for a call that was not made because its
+// arguments are invalid, structured so the model can fix each one
+func invalidArgumentsMessage(tool string, violations []jsonschema.Violation) string {
+	out, _ := json.Marshal(map[string]interface{}{
+		"error":      "invalid_arguments",
+		"tool":       tool,
+		"message":    "The arguments do not match the tool's input schema, so the tool was not called. Correct them and call it again.",
+		"violations": violations,
+	})
+	return string(out)
+}
+
+// toolTrace counts a tool loop's calls per tool, including those rejected for invalid arguments
+type toolTrace struct {
+	mu      sync.Mutex
+	Calls   map[string]int `json:"calls"`
+	Invalid map[string]int `json:"invalid_arguments,omitempty"`
+}
+
+func newToolTrace() *toolTrace {
+	return &toolTrace{Calls: make(map[string]int)}
+}
+
+// total returns the number of tool calls run
+func (t *toolTrace) total() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, count := range t.Calls {
+		n += count
+	}
+	return n
+}
+
+func (t *toolTrace) record(tool string, invalid bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Calls == nil {
+		t.Calls = make(map[string]int)
+	}
+	t.Calls[tool]++
+	if invalid {
+		if t.Invalid == nil {
+			t.Invalid = make(map[string]int)
+		}
+		t.Invalid[tool]++
+	}
+}
+
+// toolBudgetMessage tells the model a call was refused because the tool call budget is spent
+const toolBudgetMessage = "This tool call was not run because the agent's tool call budget is used up. Answer with the information you already have."
+
+// toolCallsLeft returns how many more tool calls the loop may run, or -1 if there is no budget
+func (l *toolLoop) toolCallsLeft() int {
+	if l.maxToolCalls <= 0 {
+		return -1
+	}
+	return max(l.maxToolCalls-l.trace.total(), 0)
+}
+
+// applyToolCallBudget refuses the calls of a turn beyond the tool call budget, in the order the
+// model made them, adding the refusals to decisions. The result only depends on the calls and
+// the calls run before them, so a turn resumed after approval is cut the same way.
+func (l *toolLoop) applyToolCallBudget(calls []services.ToolCall, decisions map[string]toolDecision) map[string]toolDecision {
+	left := l.toolCallsLeft()
+	if left < 0 || len(calls) <= left {
+		return decisions
+	}
+	if decisions == nil {
+		decisions = make(map[string]toolDecision, len(calls)-left)
+	}
+	for _, tc := range calls[left:] {
+		decisions[tc.ID] = toolDecision{Refusal: toolBudgetMessage}
+	}
+	warning := fmt.Sprintf("The agent's budget of %d tool calls was used up; further tool calls were refused", l.maxToolCalls)
+	if !containsString(l.warnings, warning) {
+		l.warnings = append(l.warnings, warning)
+	}
+	return decisions
+}
+
+// undecided returns the calls that decisions do not refuse
+func undecided(calls []services.ToolCall, decisions map[string]toolDecision) []services.ToolCall {
+	if len(decisions) == 0 {
+		return calls
+	}
+	out := make([]services.ToolCall, 0, len(calls))
+	for _, tc := range calls {
+		if decisions[tc.ID].Refusal == "" {
+			out = append(out, tc)
+		}
+	}
+	return out
+}
+
+// truncateToolResult cuts content to at most maxTokens, keeping the beginning and noting how
+// much was dropped so the model knows the result is partial
+func truncateToolResult(tok tokenizer.Tokenizer, content string, maxTokens int) string {
+	total := tok.Count(content)
+	if total <= maxTokens {
+		return content
+	}
+
+	// Binary search the longest rune prefix that fits
+	runes := []rune(content)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if tok.Count(string(runes[:mid])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	log.Printf("[MCP-TOOLS] Truncated tool result from %d to %d tokens", total, maxTokens)
+	return string(runes[:lo]) + fmt.Sprintf("\n\n[Result truncated: showing the first %d of %d tokens]", maxTokens, total)
+}
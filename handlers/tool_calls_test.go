@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
+)
+
+// slowMCPContextService sleeps for each call's delay_ms and tracks peak concurrency
+type slowMCPContextService struct {
+	services.MCPContextService
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (s *slowMCPContextService) InvokeTool(ctx context.Context, req models.MCPToolRequest) (*models.MCPToolResponse, error) {
+	n := s.running.Add(1)
+	defer s.running.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	time.Sleep(time.Duration(req.Parameters["delay_ms"].(float64)) * time.Millisecond)
+	return &models.MCPToolResponse{ToolName: req.ToolName, Success: true, Result: req.ToolName}, nil
+}
+
+func TestExecuteToolCallsRunsConcurrentlyInOrder(t *testing.T) {
+	mcp := &slowMCPContextService{}
+	h := &AgentHandlers{mcpContextService: mcp, mcpToolConcurrency: 2}
+	agent := &models.Agent{LLMConfig: models.AgentLLMConfig{Model: "gpt-4o"}}
+
+	var calls []services.ToolCall
+	for i, delay := range []int{80, 10, 40, 10} {
+		calls = append(calls, services.ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: services.ToolFunction{Name: fmt.Sprintf("tool_%d", i), Arguments: fmt.Sprintf(`{"delay_ms":%d}`, delay)},
+		})
+	}
+
+	messages := h.executeToolCalls(context.Background(), agent, calls, newToolLoop(nil, nil, nil, nil, nil))
+	require.Len(t, messages, 4)
+	for i, msg := range messages {
+		assert.Equal(t, "tool", msg.Role)
+		assert.Equal(t, fmt.Sprintf("call_%d", i), msg.ToolCallID, "results keep the model's order")
+		assert.Equal(t, fmt.Sprintf(`"tool_%d"`, i), msg.Content)
+	}
+	assert.Equal(t, int32(2), mcp.peak.Load(), "bounded by the worker count")
+}
+
+func TestTruncateToolResult(t *testing.T) {
+	tok := tokenizer.Get(tokenizer.DefaultEncoding)
+
+	short := "a short result"
+	assert.Equal(t, short, truncateToolResult(tok, short, 100))
+
+	long := strings.Repeat("word ", 500)
+	truncated := truncateToolResult(tok, long, 50)
+	assert.Contains(t, truncated, "[Result truncated: showing the first 50 of")
+	kept := truncated[:strings.Index(truncated, "\n\n[Result truncated")]
+	assert.LessOrEqual(t, tok.Count(kept), 50)
+	assert.Greater(t, tok.Count(kept), 40)
+}
+
+func TestExecuteToolCallsValidatesArguments(t *testing.T) {
+	mcp := &stubMCPContextService{}
+	h := &AgentHandlers{mcpContextService: mcp}
+	agent := &models.Agent{LLMConfig: models.AgentLLMConfig{Model: "gpt-4o"}}
+	tools := []services.ToolDefinition{{
+		Type: "function",
+		Function: services.ToolFunctionDef{
+			Name: "search_documents",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{"type": "string"},
+					"limit": map[string]interface{}{"type": "integer", "maximum": 20},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}}
+	run := &agentRun{}
+	loop := newToolLoop(nil, tools, nil, nil, run)
+
+	call := func(id, args string) services.ToolCall {
+		return services.ToolCall{ID: id, Type: "function", Function: services.ToolFunction{Name: "search_documents", Arguments: args}}
+	}
+	messages := h.executeToolCalls(context.Background(), agent, []services.ToolCall{
+		call("call_0", `{"limit": 50}`),
+		call("call_1", `{"query": `),
+		call("call_2", `{"query": "q", "limit": 5}`),
+	}, loop)
+	require.Len(t, messages, 3)
+
+	var rejected struct {
+		Error      string
+		Violations []map[string]string
+	}
+	require.NoError(t, json.Unmarshal([]byte(messages[0].Content), &rejected))
+	assert.Equal(t, "invalid_arguments", rejected.Error)
+	assert.ElementsMatch(t, []map[string]string{
+		{"path": "/query", "message": "is required"},
+		{"path": "/limit", "message": "must be at most 20"},
+	}, rejected.Violations)
+	assert.Contains(t, messages[1].Content, "not a valid JSON object")
+	assert.JSONEq(t, `{"answer":"42"}`, messages[2].Content)
+
+	require.Len(t, mcp.invoked, 1, "only the valid call reaches the tool")
+	assert.Equal(t, map[string]int{"search_documents": 3}, run.ToolTrace.Calls)
+	assert.Equal(t, map[string]int{"search_documents": 2}, run.ToolTrace.Invalid)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/relevance"
+)
+
+// selectTools keeps at most limit tools: those named in keep, then the ones most relevant to the
+// input and after that to the system prompt. Kept tools stay in their original order; the names
+// of the others are returned. A limit of 0 keeps every tool.
+func selectTools(tools []services.ToolDefinition, limit int, input, systemPrompt string, keep []string) ([]services.ToolDefinition, map[string]bool) {
+	if limit <= 0 || len(tools) <= limit {
+		return tools, nil
+	}
+
+	docs := make([]string, len(tools))
+	for i, t := range tools {
+		docs[i] = t.Function.Name + "\n" + t.Function.Description
+	}
+	ix := relevance.NewIndex(docs)
+	inputScores := make([]float64, len(tools))
+	for _, m := range ix.Search(input) {
+		inputScores[m.Doc] = m.Score
+	}
+	promptScores := make([]float64, len(tools))
+	for _, m := range ix.Search(systemPrompt) {
+		promptScores[m.Doc] = m.Score
+	}
+
+	required := 0
+	order := make([]int, len(tools))
+	for i := range order {
+		order[i] = i
+		if containsString(keep, tools[i].Function.Name) {
+			required++
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		ki, kj := containsString(keep, tools[i].Function.Name), containsString(keep, tools[j].Function.Name)
+		if ki != kj {
+			return ki
+		}
+		if inputScores[i] != inputScores[j] {
+			return inputScores[i] > inputScores[j]
+		}
+		return promptScores[i] > promptScores[j]
+	})
+
+	kept := make([]bool, len(tools))
+	for _, i := range order[:max(limit, required)] {
+		kept[i] = true
+	}
+	selected := make([]services.ToolDefinition, 0, limit)
+	dropped := make(map[string]bool)
+	for i, t := range tools {
+		if kept[i] {
+			selected = append(selected, t)
+		} else {
+			dropped[t.Function.Name] = true
+		}
+	}
+	return selected, dropped
+}
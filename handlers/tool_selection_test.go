@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+func TestSelectTools(t *testing.T) {
+	tool := func(name, description string) services.ToolDefinition {
+		return services.ToolDefinition{Type: "function", Function: services.ToolFunctionDef{Name: name, Description: description}}
+	}
+	tools := []services.ToolDefinition{
+		tool("list_styles", "List the available visual styles"),
+		tool("generate_visual", "Generate a diagram or chart from a text description"),
+		tool("delete_visual", "Delete a generated visual"),
+		tool("current_time", "Get the current date and time in a time zone"),
+	}
+	names := func(tools []services.ToolDefinition) []string {
+		var out []string
+		for _, t := range tools {
+			out = append(out, t.Function.Name)
+		}
+		return out
+	}
+
+	kept, dropped := selectTools(tools, 2, "Draw a chart of this year's sales", "You are a sales analyst", nil)
+	assert.Equal(t, []string{"list_styles", "generate_visual"}, names(kept), "kept tools stay in order")
+	assert.Equal(t, map[string]bool{"delete_visual": true, "current_time": true}, dropped)
+
+	kept, _ = selectTools(tools, 1, "Draw a chart of this year's sales", "", []string{"current_time"})
+	assert.Equal(t, []string{"current_time"}, names(kept), "required tools are kept first")
+
+	kept, dropped = selectTools(tools, 0, "anything", "", nil)
+	assert.Len(t, kept, 4)
+	assert.Empty(t, dropped)
+
+	// The selection reports why each skill was chosen and which of its tools were sent
+	visual := models.Skill{ID: uuid.New(), Name: "visual_generation", Type: models.SkillTypeMCP,
+		Selection: &models.SkillSelection{Skill: "visual_generation", Reason: models.SkillSelectedRelevant, Source: models.SkillSourceInput, Relevance: 0.2}}
+	h := &AgentHandlers{
+		skillService:          &stubSkillService{skill: visual},
+		skillTools:            &stubSkillTools{tools: []models.SkillTool{{Name: "list_styles"}, {Name: "generate_visual", Description: "Generate a chart"}}},
+		mcpMaxToolsPerRequest: 1,
+	}
+	defs, toolSkills, _, selection, err := h.resolveToolsForAgent(context.Background(), &models.Agent{}, "Make a chart", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"generate_visual"}, names(defs))
+	assert.NotContains(t, toolSkills, "list_styles", "dropped tools cannot be called")
+	require.Len(t, selection, 1)
+	assert.Equal(t, models.SkillSelectedRelevant, selection[0].Reason)
+	assert.Equal(t, []string{"generate_visual"}, selection[0].Tools)
+	assert.Equal(t, []string{"list_styles"}, selection[0].DroppedTools)
+}
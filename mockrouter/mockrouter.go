@@ -0,0 +1,569 @@
+// Package mockrouter is a scripted stand-in for the TAS LLM router. It serves
+// /v1/chat/completions (synchronous and SSE streaming), /v1/providers and
+// /v1/providers/:provider so the agent builder can run and be tested offline.
+//
+// Tests use mockroutertest.NewServer; cmd/mockrouter serves the same handler from a script file.
+package mockrouter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolCall is a tool call the mock model requests
+type ToolCall struct {
+	ID        string `json:"id,omitempty"` // Generated when empty
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON object as a string
+}
+
+// Response is one scripted reply. A non-zero StatusCode makes the router fail the request
+// with that status instead.
+type Response struct {
+	Content      string     `json:"content,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"` // Derived from the reply when empty
+	Model        string     `json:"model,omitempty"`         // Defaults to the requested model
+	Provider     string     `json:"provider,omitempty"`      // Defaults to "openai"
+
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+
+	// Reliability metadata reported in router_metadata
+	Retries         int      `json:"retries,omitempty"`
+	FallbackUsed    bool     `json:"fallback_used,omitempty"`
+	FailedProviders []string `json:"failed_providers,omitempty"`
+	RoutingReason   []string `json:"routing_reason,omitempty"`
+
+	// RouterMetadata is merged over the generated router_metadata
+	RouterMetadata map[string]interface{} `json:"router_metadata,omitempty"`
+
+	LatencyMs    int `json:"latency_ms,omitempty"`     // Delay before the response starts
+	ChunkDelayMs int `json:"chunk_delay_ms,omitempty"` // Delay between streamed chunks
+
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Model describes a model listed by /v1/providers/:provider
+type Model struct {
+	Name             string   `json:"name"`
+	MaxContextWindow int      `json:"max_context_window,omitempty"`
+	MaxOutputTokens  int      `json:"max_output_tokens,omitempty"`
+	InputCostPer1K   float64  `json:"input_cost_per_1k,omitempty"`
+	OutputCostPer1K  float64  `json:"output_cost_per_1k,omitempty"`
+	Features         []string `json:"features,omitempty"`
+	Tokenizer        string   `json:"tokenizer,omitempty"`
+}
+
+// Provider describes a provider listed by /v1/providers
+type Provider struct {
+	Name              string  `json:"name"`
+	MaxContextWindow  int     `json:"max_context_window,omitempty"`
+	SupportsFunctions bool    `json:"supports_functions"`
+	SupportsVision    bool    `json:"supports_vision"`
+	SupportsStreaming bool    `json:"supports_streaming"`
+	Models            []Model `json:"models"`
+}
+
+// ChatMessage is a message as received by the router. Content is a string, or an array of
+// parts for multimodal messages.
+type ChatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	ToolCalls  []struct {
+		ID       string `json:"id"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls,omitempty"`
+}
+
+// Text returns the message content as a string, joining the text parts of multimodal content
+func (m ChatMessage) Text() string {
+	var s string
+	if json.Unmarshal(m.Content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(m.Content, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ChatRequest is a recorded /v1/chat/completions request
+type ChatRequest struct {
+	Model            string        `json:"model"`
+	Messages         []ChatMessage `json:"messages"`
+	Stream           bool          `json:"stream"`
+	MaxTokens        *int          `json:"max_tokens,omitempty"`
+	OptimizeFor      string        `json:"optimize_for,omitempty"`
+	RequiredFeatures []string      `json:"required_features,omitempty"`
+	Tools            []struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools,omitempty"`
+	ToolChoice interface{} `json:"tool_choice,omitempty"`
+}
+
+// ToolNames returns the names of the tools offered in the request
+func (r ChatRequest) ToolNames() []string {
+	names := make([]string, len(r.Tools))
+	for i, t := range r.Tools {
+		names[i] = t.Function.Name
+	}
+	return names
+}
+
+// Script is the file format read by cmd/mockrouter
+type Script struct {
+	Responses []Response `json:"responses,omitempty"`
+	Default   *Response  `json:"default,omitempty"`
+	Providers []Provider `json:"providers,omitempty"`
+}
+
+// Router is a scripted mock of the TAS LLM router. Queued responses are served in order;
+// when the queue is empty the responder, then the default response, is used. The default
+// default echoes the last user message.
+type Router struct {
+	mu        sync.Mutex
+	queue     []Response
+	responder func(ChatRequest) *Response
+	fallback  *Response
+	providers []Provider
+	requests  []ChatRequest
+	seq       int
+}
+
+// New returns a router with the default providers and no scripted responses
+func New() *Router {
+	return &Router{providers: DefaultProviders()}
+}
+
+// NewFromScript returns a router loaded from a script
+func NewFromScript(script Script) *Router {
+	r := New()
+	r.Enqueue(script.Responses...)
+	if script.Default != nil {
+		r.SetDefault(*script.Default)
+	}
+	if len(script.Providers) > 0 {
+		r.SetProviders(script.Providers...)
+	}
+	return r
+}
+
+// Enqueue appends responses to the script
+func (r *Router) Enqueue(responses ...Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queue = append(r.queue, responses...)
+}
+
+// Respond sets a function that builds a reply from the request once the queue is empty;
+// returning nil falls through to the default response
+func (r *Router) Respond(fn func(ChatRequest) *Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responder = fn
+}
+
+// SetDefault sets the response used when nothing else applies
+func (r *Router) SetDefault(resp Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = &resp
+}
+
+// SetProviders replaces the providers served by /v1/providers
+func (r *Router) SetProviders(providers ...Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers = providers
+}
+
+// Requests returns the chat completion requests received so far
+func (r *Router) Requests() []ChatRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ChatRequest(nil), r.requests...)
+}
+
+// Pending returns the number of queued responses not yet served
+func (r *Router) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.queue)
+}
+
+// DefaultProviders returns an OpenAI and an Anthropic provider with common models
+func DefaultProviders() []Provider {
+	return []Provider{
+		{
+			Name:              "openai",
+			MaxContextWindow:  128000,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+			SupportsStreaming: true,
+			Models: []Model{
+				{Name: "gpt-4o", MaxContextWindow: 128000, MaxOutputTokens: 16384, InputCostPer1K: 0.0025, OutputCostPer1K: 0.01},
+				{Name: "gpt-4o-mini", MaxContextWindow: 128000, MaxOutputTokens: 16384, InputCostPer1K: 0.00015, OutputCostPer1K: 0.0006},
+			},
+		},
+		{
+			Name:              "anthropic",
+			MaxContextWindow:  200000,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+			SupportsStreaming: true,
+			Models: []Model{
+				{Name: "claude-3-5-sonnet", MaxContextWindow: 200000, MaxOutputTokens: 8192, InputCostPer1K: 0.003, OutputCostPer1K: 0.015},
+			},
+		},
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.URL.Path == "/v1/chat/completions" && req.Method == http.MethodPost:
+		r.serveChat(w, req)
+	case req.URL.Path == "/v1/providers" && req.Method == http.MethodGet:
+		r.serveProviders(w)
+	case strings.HasPrefix(req.URL.Path, "/v1/providers/") && req.Method == http.MethodGet:
+		r.serveProvider(w, strings.TrimPrefix(req.URL.Path, "/v1/providers/"))
+	case req.URL.Path == "/health":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (r *Router) serveProviders(w http.ResponseWriter) {
+	r.mu.Lock()
+	names := make([]string, len(r.providers))
+	for i, p := range r.providers {
+		names[i] = p.Name
+	}
+	r.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(names), "providers": names})
+}
+
+func (r *Router) serveProvider(w http.ResponseWriter, name string) {
+	r.mu.Lock()
+	var provider *Provider
+	for i := range r.providers {
+		if r.providers[i].Name == name {
+			p := r.providers[i]
+			provider = &p
+		}
+	}
+	r.mu.Unlock()
+
+	if provider == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("provider %s not found", name))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":     provider.Name,
+		"provider": provider.Name,
+		"capabilities": map[string]interface{}{
+			"provider_name":      provider.Name,
+			"max_context_window": provider.MaxContextWindow,
+			"supports_functions": provider.SupportsFunctions,
+			"supports_vision":    provider.SupportsVision,
+			"supports_streaming": provider.SupportsStreaming,
+			"supported_models":   provider.Models,
+		},
+	})
+}
+
+// next records the request and picks the response for it
+func (r *Router) next(req ChatRequest) (Response, string) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.seq++
+	id := fmt.Sprintf("chatcmpl-mock-%d", r.seq)
+
+	if len(r.queue) > 0 {
+		resp := r.queue[0]
+		r.queue = r.queue[1:]
+		r.mu.Unlock()
+		return resp, id
+	}
+	responder, fallback := r.responder, r.fallback
+	r.mu.Unlock()
+
+	if responder != nil {
+		if resp := responder(req); resp != nil {
+			return *resp, id
+		}
+	}
+	if fallback != nil {
+		return *fallback, id
+	}
+	return echo(req), id
+}
+
+// echo replies with the last user message
+func echo(req ChatRequest) Response {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return Response{Content: "mock response to: " + req.Messages[i].Text()}
+		}
+	}
+	return Response{Content: "mock response"}
+}
+
+func (r *Router) serveChat(w http.ResponseWriter, httpReq *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(httpReq.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	resp, id := r.next(req)
+	resp = complete(resp, req)
+
+	if !sleep(httpReq, resp.LatencyMs) {
+		return
+	}
+
+	if resp.StatusCode != 0 && resp.StatusCode != http.StatusOK {
+		message := resp.Error
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		writeError(w, resp.StatusCode, message)
+		return
+	}
+
+	if req.Stream {
+		r.writeStream(w, httpReq, id, resp)
+		return
+	}
+
+	message := map[string]interface{}{"role": "assistant", "content": resp.Content}
+	if len(resp.ToolCalls) > 0 {
+		message["tool_calls"] = toolCallsJSON(resp.ToolCalls)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   resp.Model,
+		"choices": []map[string]interface{}{
+			{"index": 0, "message": message, "finish_reason": resp.FinishReason},
+		},
+		"usage":           usageJSON(resp),
+		"router_metadata": routerMetadata(resp),
+	})
+}
+
+// writeStream sends the response as OpenAI-style SSE chunks: metadata and role, content in
+// word-sized deltas, tool call deltas with arguments split in two, then finish and usage
+func (r *Router) writeStream(w http.ResponseWriter, httpReq *http.Request, id string, resp Response) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	created := time.Now().Unix()
+	send := func(chunk map[string]interface{}) bool {
+		chunk["id"] = id
+		chunk["object"] = "chat.completion.chunk"
+		chunk["created"] = created
+		chunk["model"] = resp.Model
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return sleep(httpReq, resp.ChunkDelayMs)
+	}
+	delta := func(d map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"choices": []map[string]interface{}{{"index": 0, "delta": d}}}
+	}
+
+	first := delta(map[string]interface{}{"role": "assistant"})
+	first["router_metadata"] = routerMetadata(resp)
+	if !send(first) {
+		return
+	}
+
+	for _, word := range splitWords(resp.Content) {
+		if !send(delta(map[string]interface{}{"content": word})) {
+			return
+		}
+	}
+
+	for i, tc := range toolCallsJSON(resp.ToolCalls) {
+		fn := tc["function"].(map[string]string)
+		half := len(fn["arguments"]) / 2
+		start := map[string]interface{}{
+			"index": i, "id": tc["id"], "type": "function",
+			"function": map[string]string{"name": fn["name"], "arguments": fn["arguments"][:half]},
+		}
+		rest := map[string]interface{}{
+			"index": i, "function": map[string]string{"arguments": fn["arguments"][half:]},
+		}
+		if !send(delta(map[string]interface{}{"tool_calls": []interface{}{start}})) ||
+			!send(delta(map[string]interface{}{"tool_calls": []interface{}{rest}})) {
+			return
+		}
+	}
+
+	send(map[string]interface{}{
+		"choices": []map[string]interface{}{{"index": 0, "delta": map[string]interface{}{}, "finish_reason": resp.FinishReason}},
+		"usage":   usageJSON(resp),
+	})
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// complete fills in derived fields of a scripted response
+func complete(resp Response, req ChatRequest) Response {
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+	if resp.Provider == "" {
+		resp.Provider = "openai"
+	}
+	if resp.FinishReason == "" {
+		resp.FinishReason = "stop"
+		if len(resp.ToolCalls) > 0 {
+			resp.FinishReason = "tool_calls"
+		}
+	}
+	if resp.PromptTokens == 0 {
+		for _, m := range req.Messages {
+			resp.PromptTokens += len(m.Text())/4 + 4
+		}
+	}
+	if resp.CompletionTokens == 0 {
+		resp.CompletionTokens = len(resp.Content)/4 + 1
+		for _, tc := range resp.ToolCalls {
+			resp.CompletionTokens += len(tc.Arguments)/4 + 4
+		}
+	}
+	for i := range resp.ToolCalls {
+		if resp.ToolCalls[i].ID == "" {
+			resp.ToolCalls[i].ID = fmt.Sprintf("call_mock_%d", i)
+		}
+		if resp.ToolCalls[i].Arguments == "" {
+			resp.ToolCalls[i].Arguments = "{}"
+		}
+	}
+	return resp
+}
+
+func routerMetadata(resp Response) map[string]interface{} {
+	reason := resp.RoutingReason
+	if reason == nil {
+		reason = []string{"mock router"}
+	}
+	meta := map[string]interface{}{
+		"provider":         resp.Provider,
+		"model":            resp.Model,
+		"attempt_count":    resp.Retries + 1,
+		"fallback_used":    resp.FallbackUsed,
+		"failed_providers": nonNil(resp.FailedProviders),
+		"total_retry_time": resp.Retries * 100,
+		"provider_latency": fmt.Sprintf("%dms", resp.LatencyMs),
+		"routing_reason":   reason,
+	}
+	for k, v := range resp.RouterMetadata {
+		meta[k] = v
+	}
+	return meta
+}
+
+func usageJSON(resp Response) map[string]int {
+	return map[string]int{
+		"prompt_tokens":     resp.PromptTokens,
+		"completion_tokens": resp.CompletionTokens,
+		"total_tokens":      resp.PromptTokens + resp.CompletionTokens,
+	}
+}
+
+func toolCallsJSON(calls []ToolCall) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(calls))
+	for i, tc := range calls {
+		result[i] = map[string]interface{}{
+			"id":       tc.ID,
+			"type":     "function",
+			"function": map[string]string{"name": tc.Name, "arguments": tc.Arguments},
+		}
+	}
+	return result
+}
+
+// splitWords splits text into deltas that keep their leading whitespace
+func splitWords(text string) []string {
+	var words []string
+	start := 0
+	for i := 1; i < len(text); i++ {
+		if text[i] == ' ' && text[i-1] != ' ' {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// sleep waits ms milliseconds and reports false if the client went away meanwhile
+func sleep(req *http.Request, ms int) bool {
+	if ms <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return true
+	case <-req.Context().Done():
+		return false
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"message": message, "code": status},
+	})
+}
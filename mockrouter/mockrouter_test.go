@@ -0,0 +1,141 @@
+package mockrouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer serves a new router for the test; other packages use mockroutertest.NewServer
+func newServer(t *testing.T) (*Router, *httptest.Server) {
+	r := New()
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func postChat(t *testing.T, url string, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/chat/completions", "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	return resp
+}
+
+func TestProviders(t *testing.T) {
+	_, server := newServer(t)
+
+	resp, err := http.Get(server.URL + "/v1/providers")
+	require.NoError(t, err)
+	var list struct {
+		Count     int      `json:"count"`
+		Providers []string `json:"providers"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.Equal(t, []string{"openai", "anthropic"}, list.Providers)
+
+	resp, err = http.Get(server.URL + "/v1/providers/anthropic")
+	require.NoError(t, err)
+	var detail struct {
+		Capabilities struct {
+			SupportedModels []Model `json:"supported_models"`
+		} `json:"capabilities"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&detail))
+	assert.Equal(t, "claude-3-5-sonnet", detail.Capabilities.SupportedModels[0].Name)
+
+	resp, err = http.Get(server.URL + "/v1/providers/nope")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestChatCompletions(t *testing.T) {
+	router, server := newServer(t)
+
+	t.Run("echoes the last user message by default", func(t *testing.T) {
+		resp := postChat(t, server.URL, `{"model":"gpt-4o","messages":[{"role":"user","content":"ping"}]}`)
+		var body struct {
+			Model   string `json:"model"`
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+			RouterMetadata map[string]interface{} `json:"router_metadata"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "gpt-4o", body.Model)
+		assert.Equal(t, "mock response to: ping", body.Choices[0].Message.Content)
+		assert.Equal(t, float64(1), body.RouterMetadata["attempt_count"])
+	})
+
+	t.Run("serves scripted errors and latency in order", func(t *testing.T) {
+		router.Enqueue(Response{StatusCode: 429, Error: "slow down"}, Response{Content: "ok", LatencyMs: 30})
+
+		resp := postChat(t, server.URL, `{"model":"m","messages":[]}`)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+		start := time.Now()
+		resp = postChat(t, server.URL, `{"model":"m","messages":[]}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	})
+
+	t.Run("streams SSE chunks ending with DONE", func(t *testing.T) {
+		router.Enqueue(Response{Content: "hello streaming world"})
+
+		resp := postChat(t, server.URL, `{"model":"m","stream":true,"messages":[]}`)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		stream := buf.String()
+		assert.Contains(t, stream, `"router_metadata"`)
+		assert.Contains(t, stream, `"content":" streaming"`)
+		assert.Contains(t, stream, `"finish_reason":"stop"`)
+		assert.True(t, strings.HasSuffix(stream, "data: [DONE]\n\n"))
+	})
+
+	t.Run("responder builds replies from the request", func(t *testing.T) {
+		router.Respond(func(req ChatRequest) *Response {
+			if len(req.Tools) > 0 {
+				return &Response{ToolCalls: []ToolCall{{Name: req.Tools[0].Function.Name}}}
+			}
+			return nil
+		})
+		defer router.Respond(nil)
+
+		resp := postChat(t, server.URL, `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"lookup"}}]}`)
+		var body struct {
+			Choices []struct {
+				Message struct {
+					ToolCalls []struct {
+						ID       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"message"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "tool_calls", body.Choices[0].FinishReason)
+		assert.Equal(t, "lookup", body.Choices[0].Message.ToolCalls[0].Function.Name)
+		assert.Equal(t, "call_mock_0", body.Choices[0].Message.ToolCalls[0].ID)
+		assert.Equal(t, "{}", body.Choices[0].Message.ToolCalls[0].Function.Arguments)
+	})
+
+	t.Run("multimodal content is recorded", func(t *testing.T) {
+		postChat(t, server.URL, `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"what is this"},{"type":"image_url","image_url":{"url":"https://x/y.png"}}]}]}`)
+		requests := router.Requests()
+		assert.Equal(t, "what is this", requests[len(requests)-1].Messages[0].Text())
+	})
+}
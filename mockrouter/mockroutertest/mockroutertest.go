@@ -0,0 +1,19 @@
+// Package mockroutertest starts the mock LLM router for tests. It is kept apart from
+// mockrouter so cmd/mockrouter does not link the testing package.
+package mockroutertest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tas-agent-builder/mockrouter"
+)
+
+// NewServer starts an httptest server for a new router and closes it when the test ends
+func NewServer(t testing.TB) (*mockrouter.Router, *httptest.Server) {
+	t.Helper()
+	r := mockrouter.New()
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server
+}
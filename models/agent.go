@@ -26,9 +26,17 @@ const (
 	AgentTypeProducer       AgentType = "producer"
 )
 
+// LLMBackendRouter is the default AgentLLMConfig.Backend: requests go through the TAS LLM router
+const LLMBackendRouter = "router"
+
 type AgentLLMConfig struct {
 	Provider         string            `json:"provider" gorm:"not null"`
 	Model            string            `json:"model" gorm:"not null"`
+	// Backend is "router" (default) or a direct backend: "openai", "anthropic", "ollama". It is
+	// not Provider, which routed agents already set to "openai" or "anthropic" for the router to
+	// route by; selecting backends by Provider would move those agents off the router as soon as
+	// a direct backend of the same name was enabled.
+	Backend          string            `json:"backend,omitempty"`
 	Temperature      *float64          `json:"temperature,omitempty"`
 	MaxTokens        *int              `json:"max_tokens,omitempty"`
 	TopP             *float64          `json:"top_p,omitempty"`
@@ -192,16 +200,17 @@ type MultiPassConfig struct {
 
 // DocumentContextConfig holds all document context settings for an agent
 type DocumentContextConfig struct {
-	Strategy            ContextStrategy  `json:"strategy" gorm:"default:'vector'"`
-	Scope               DocumentScope    `json:"scope" gorm:"default:'all'"`
-	DefaultDocuments    []uuid.UUID      `json:"default_documents,omitempty"`
-	IncludeSubNotebooks bool             `json:"include_sub_notebooks" gorm:"default:false"`
-	MaxContextTokens    int              `json:"max_context_tokens" gorm:"default:8000"`
-	TopK                int              `json:"top_k" gorm:"default:10"`           // For vector search
-	MinScore            float64          `json:"min_score" gorm:"default:0.7"`      // Minimum similarity score
-	VectorWeight        float64          `json:"vector_weight" gorm:"default:0.5"`  // For hybrid search
-	FullDocWeight       float64          `json:"full_doc_weight" gorm:"default:0.5"` // For hybrid search
-	MultiPass           *MultiPassConfig `json:"multi_pass,omitempty"`
+	Strategy            ContextStrategy    `json:"strategy" gorm:"default:'vector'"`
+	Scope               DocumentScope      `json:"scope" gorm:"default:'all'"`
+	DefaultDocuments    []uuid.UUID        `json:"default_documents,omitempty"`
+	IncludeSubNotebooks bool               `json:"include_sub_notebooks" gorm:"default:false"`
+	MaxContextTokens    int                `json:"max_context_tokens" gorm:"default:8000"`
+	TopK                int                `json:"top_k" gorm:"default:10"`            // For vector search
+	MinScore            float64            `json:"min_score" gorm:"default:0.7"`       // Minimum similarity score
+	VectorWeight        float64            `json:"vector_weight" gorm:"default:0.5"`   // For hybrid search
+	FullDocWeight       float64            `json:"full_doc_weight" gorm:"default:0.5"` // For hybrid search
+	MultiPass           *MultiPassConfig   `json:"multi_pass,omitempty"`
+	TokenBudget         *TokenBudgetConfig `json:"token_budget,omitempty"` // Context window allocation across prompt sections
 }
 
 func (c DocumentContextConfig) Value() (driver.Value, error) {
@@ -249,8 +258,14 @@ type Agent struct {
 	EnableMemory        bool                   `json:"enable_memory" gorm:"default:true"`
 	DocumentContext     *DocumentContextConfig `json:"document_context,omitempty" gorm:"type:jsonb"`
 
+	// A/B traffic split between LLM configurations
+	Experiment *AgentExperiment `json:"experiment,omitempty" gorm:"type:jsonb"`
+
 	Tags   datatypes.JSON `json:"tags" gorm:"type:jsonb;default:'[]'"`
-	Skills datatypes.JSON `json:"skills" gorm:"type:jsonb;default:'[]'"` // Skill name strings
+	Skills datatypes.JSON `json:"skills" gorm:"type:jsonb;default:'[]'"` // Skill names, optionally pinned as "name@1.2.0" or "name@^1"
+
+	// How the model is steered towards the skills' tools
+	ToolPolicy *AgentToolPolicy `json:"tool_policy,omitempty" gorm:"type:jsonb"`
 
 	TotalExecutions     int     `json:"total_executions" gorm:"default:0"`
 	TotalCostUSD        float64 `json:"total_cost_usd" gorm:"type:decimal(10,6);default:0"`
@@ -285,6 +300,9 @@ type CreateAgentRequest struct {
 	EnableKnowledge bool                   `json:"enable_knowledge"`
 	EnableMemory    bool                   `json:"enable_memory"`
 	DocumentContext *DocumentContextConfig `json:"document_context,omitempty"`
+
+	Experiment *AgentExperiment `json:"experiment,omitempty"`
+	ToolPolicy *AgentToolPolicy `json:"tool_policy,omitempty"`
 }
 
 type UpdateAgentRequest struct {
@@ -305,6 +323,9 @@ type UpdateAgentRequest struct {
 	EnableKnowledge *bool                  `json:"enable_knowledge,omitempty"`
 	EnableMemory    *bool                  `json:"enable_memory,omitempty"`
 	DocumentContext *DocumentContextConfig `json:"document_context,omitempty"`
+
+	Experiment *AgentExperiment `json:"experiment,omitempty"` // Send enabled=false to stop an experiment
+	ToolPolicy *AgentToolPolicy `json:"tool_policy,omitempty"`
 }
 
 type AgentListResponse struct {
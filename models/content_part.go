@@ -0,0 +1,156 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ContentPartType identifies the kind of content in a multimodal message part
+type ContentPartType string
+
+const (
+	ContentPartText        ContentPartType = "text"
+	ContentPartImageURL    ContentPartType = "image_url"
+	ContentPartImageBase64 ContentPartType = "image_base64"
+	ContentPartFile        ContentPartType = "file"
+)
+
+// FileDelivery controls how an AudiModal file reference is handed to the model
+type FileDelivery string
+
+const (
+	FileDeliveryURL    FileDelivery = "url"    // Send a short-lived signed URL
+	FileDeliveryInline FileDelivery = "inline" // Download the bytes and send them base64 encoded
+)
+
+// MaxInlineContentBytes caps decoded base64 images and inlined files
+const MaxInlineContentBytes = 20 * 1024 * 1024
+
+// ContentPart is one typed part of a multimodal message
+type ContentPart struct {
+	Type ContentPartType `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image_url; also set on file parts once resolved to a signed URL
+	URL    string `json:"url,omitempty"`
+	Detail string `json:"detail,omitempty"` // auto, low, high
+
+	// image_base64; also set on file parts once inlined
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// file
+	FileID   string       `json:"file_id,omitempty"`
+	Filename string       `json:"filename,omitempty"`
+	Delivery FileDelivery `json:"delivery,omitempty"`
+
+	// Set when stored in place of raw bytes
+	SizeBytes int    `json:"size_bytes,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// Validate checks that the part carries the fields its type requires
+func (p ContentPart) Validate() error {
+	switch p.Type {
+	case ContentPartText:
+		if p.Text == "" {
+			return fmt.Errorf("text part requires text")
+		}
+	case ContentPartImageURL:
+		if !strings.HasPrefix(p.URL, "https://") && !strings.HasPrefix(p.URL, "http://") {
+			return fmt.Errorf("image_url part requires an http(s) url")
+		}
+	case ContentPartImageBase64:
+		if !strings.HasPrefix(p.MediaType, "image/") {
+			return fmt.Errorf("image_base64 part requires an image media_type")
+		}
+		if p.Data == "" {
+			return fmt.Errorf("image_base64 part requires data")
+		}
+		if base64.StdEncoding.DecodedLen(len(p.Data)) > MaxInlineContentBytes {
+			return fmt.Errorf("image_base64 part exceeds %d bytes", MaxInlineContentBytes)
+		}
+		if _, err := base64.StdEncoding.DecodeString(p.Data); err != nil {
+			return fmt.Errorf("image_base64 part has invalid data: %w", err)
+		}
+	case ContentPartFile:
+		if p.FileID == "" {
+			return fmt.Errorf("file part requires file_id")
+		}
+		// The ID becomes part of an AudiModal path, so nothing but a UUID may reach it
+		if _, err := uuid.Parse(p.FileID); err != nil {
+			return fmt.Errorf("file_id must be a UUID")
+		}
+		if p.Delivery != "" && p.Delivery != FileDeliveryURL && p.Delivery != FileDeliveryInline {
+			return fmt.Errorf("invalid file delivery: %s", p.Delivery)
+		}
+	default:
+		return fmt.Errorf("unsupported content part type: %s", p.Type)
+	}
+
+	if p.Detail != "" && p.Detail != "auto" && p.Detail != "low" && p.Detail != "high" {
+		return fmt.Errorf("invalid image detail: %s", p.Detail)
+	}
+	return nil
+}
+
+// IsImage reports whether the part is sent to the model as an image
+func (p ContentPart) IsImage() bool {
+	switch p.Type {
+	case ContentPartImageURL, ContentPartImageBase64:
+		return true
+	case ContentPartFile:
+		return strings.HasPrefix(p.MediaType, "image/")
+	}
+	return false
+}
+
+// StorageReference returns a copy of the part that is safe to persist: base64 data is
+// replaced by its size and digest, and signed URLs of resolved files are dropped.
+func (p ContentPart) StorageReference() ContentPart {
+	ref := p
+	if p.Data != "" {
+		sum := sha256.Sum256([]byte(p.Data))
+		ref.Data = ""
+		ref.SizeBytes = decodedSize(p.Data)
+		ref.SHA256 = hex.EncodeToString(sum[:])
+	}
+	if p.Type == ContentPartFile {
+		ref.URL = ""
+	}
+	return ref
+}
+
+// decodedSize returns the byte length of standard base64 data without decoding it
+func decodedSize(data string) int {
+	return len(data)*3/4 - (len(data) - len(strings.TrimRight(data, "=")))
+}
+
+// ContentPartsStorageReferences maps StorageReference over parts
+func ContentPartsStorageReferences(parts []ContentPart) []ContentPart {
+	if len(parts) == 0 {
+		return nil
+	}
+	refs := make([]ContentPart, len(parts))
+	for i, p := range parts {
+		refs[i] = p.StorageReference()
+	}
+	return refs
+}
+
+// ResolvedFile is an AudiModal file resolved for delivery to a model
+type ResolvedFile struct {
+	FileID      string `json:"file_id"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes,omitempty"`
+	SignedURL   string `json:"signed_url,omitempty"`
+	Data        []byte `json:"-"` // Populated for inline delivery only
+}
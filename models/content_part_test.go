@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentPartValidate(t *testing.T) {
+	assert.NoError(t, ContentPart{Type: ContentPartText, Text: "hi"}.Validate())
+	assert.NoError(t, ContentPart{Type: ContentPartImageURL, URL: "https://example.com/a.png", Detail: "high"}.Validate())
+	assert.NoError(t, ContentPart{Type: ContentPartImageBase64, MediaType: "image/png", Data: "aGVsbG8="}.Validate())
+	assert.NoError(t, ContentPart{Type: ContentPartFile, FileID: "3f2b8c1e-5d4a-4b6f-9e7d-1a2b3c4d5e6f", Delivery: FileDeliveryInline}.Validate())
+
+	assert.Error(t, ContentPart{Type: ContentPartImageURL, URL: "file:///etc/passwd"}.Validate())
+	assert.Error(t, ContentPart{Type: ContentPartImageBase64, MediaType: "text/plain", Data: "aGVsbG8="}.Validate())
+	assert.Error(t, ContentPart{Type: ContentPartImageBase64, MediaType: "image/png", Data: "not base64!"}.Validate())
+	assert.Error(t, ContentPart{Type: ContentPartFile, FileID: "3f2b8c1e-5d4a-4b6f-9e7d-1a2b3c4d5e6f", Delivery: "email"}.Validate())
+	assert.Error(t, ContentPart{Type: ContentPartFile, FileID: "../../other-tenant/files/3f2b8c1e-5d4a-4b6f-9e7d-1a2b3c4d5e6f"}.Validate(), "file IDs cannot traverse AudiModal paths")
+	assert.Error(t, ContentPart{Type: "video"}.Validate())
+}
+
+func TestContentPartStorageReference(t *testing.T) {
+	image := ContentPart{Type: ContentPartImageBase64, MediaType: "image/png", Data: "aGVsbG8="}
+	ref := image.StorageReference()
+	assert.Empty(t, ref.Data)
+	assert.Equal(t, 5, ref.SizeBytes)
+	assert.Len(t, ref.SHA256, 64)
+	assert.Equal(t, "aGVsbG8=", image.Data, "original part is not modified")
+
+	file := ContentPart{Type: ContentPartFile, FileID: "f1", URL: "https://signed.example.com/f1?sig=x", MediaType: "image/png"}
+	ref = file.StorageReference()
+	assert.Empty(t, ref.URL, "signed URLs are not persisted")
+	assert.Equal(t, "f1", ref.FileID)
+
+	url := ContentPart{Type: ContentPartImageURL, URL: "https://example.com/a.png"}
+	assert.Equal(t, url, url.StorageReference())
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Credential scopes
+const (
+	CredentialScopeTenant = "tenant" // Shared by every user of the tenant
+	CredentialScopeUser   = "user"   // Private to the user who created it
+)
+
+// credentialName matches the names skills reference as {{credential:name}}
+var credentialName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,128}$`)
+
+// Credential is a secret for authenticating to skill servers. The value is stored sealed
+// (see services/credentials) and is never returned by the API.
+type Credential struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TenantID    string     `json:"tenant_id" gorm:"not null;index"`
+	UserID      *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid;index"` // Nil for tenant credentials
+	Name        string     `json:"name" gorm:"not null"`
+	Description string     `json:"description,omitempty"`
+
+	// Sealed value
+	KeyID      string `json:"key_id" gorm:"not null;index"`
+	WrappedKey []byte `json:"-" gorm:"type:bytea;not null"`
+	Ciphertext []byte `json:"-" gorm:"type:bytea;not null"`
+
+	CreatedBy      uuid.UUID  `json:"created_by" gorm:"type:uuid;not null"`
+	ValueUpdatedAt time.Time  `json:"value_updated_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"not null;default:now()"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+func (Credential) TableName() string {
+	return "agent_builder.credentials"
+}
+
+// Scope returns CredentialScopeTenant or CredentialScopeUser
+func (c *Credential) Scope() string {
+	if c.UserID == nil {
+		return CredentialScopeTenant
+	}
+	return CredentialScopeUser
+}
+
+// MarshalJSON adds the scope
+func (c Credential) MarshalJSON() ([]byte, error) {
+	type plain Credential
+	return json.Marshal(struct {
+		plain
+		Scope string `json:"scope"`
+	}{plain(c), c.Scope()})
+}
+
+// CreateCredentialRequest stores a new secret
+type CreateCredentialRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Value       string `json:"value"`
+	Scope       string `json:"scope,omitempty"` // "user" (default) or "tenant"
+}
+
+// Validate checks the request
+func (r *CreateCredentialRequest) Validate() error {
+	if !credentialName.MatchString(r.Name) {
+		return fmt.Errorf("name must be 1-128 letters, digits, '_', '.' or '-'")
+	}
+	if r.Value == "" {
+		return fmt.Errorf("value is required")
+	}
+	switch r.Scope {
+	case "", CredentialScopeUser, CredentialScopeTenant:
+		return nil
+	}
+	return fmt.Errorf("scope must be 'user' or 'tenant'")
+}
+
+// UpdateCredentialRequest changes a credential's description or replaces its value
+type UpdateCredentialRequest struct {
+	Description *string `json:"description,omitempty"`
+	Value       *string `json:"value,omitempty"`
+}
+
+// CredentialListResponse lists the credentials visible to a user
+type CredentialListResponse struct {
+	Credentials []Credential `json:"credentials"`
+	Total       int          `json:"total"`
+}
+
+// CredentialRotationResult reports a rewrap of credentials under the primary key
+type CredentialRotationResult struct {
+	PrimaryKeyID string `json:"primary_key_id"`
+	Rewrapped    int    `json:"rewrapped"`
+	Failed       int    `json:"failed"`
+}
+
+// CredentialTemplates maps header or query parameter names to templates that may reference
+// credentials as {{credential:name}}
+type CredentialTemplates map[string]string
+
+func (t CredentialTemplates) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
+
+func (t *CredentialTemplates) Scan(value interface{}) error {
+	if value == nil {
+		*t = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), t)
+	}
+	return json.Unmarshal(bytes, t)
+}
@@ -52,6 +52,8 @@ type VectorSearchRequest struct {
 	SpaceID     string        `json:"space_id,omitempty"`
 	Options     SearchOptions `json:"options"`
 	AuthToken   string        `json:"-"` // Auth token for downstream API calls (not serialized)
+
+	IncludeSubNotebooks bool `json:"include_sub_notebooks,omitempty"` // Also search the sub-notebooks of NotebookIDs
 }
 
 // VectorSearchResponse represents a response from DeepLake vector search
@@ -89,6 +91,8 @@ type ChunkRetrievalRequest struct {
 	Offset      int         `json:"offset,omitempty"`
 	OrderBy     string      `json:"order_by,omitempty"` // chunk_number, created_at
 	AuthToken   string      `json:"-"`                  // Auth token for AudiModal API (not serialized)
+
+	IncludeSubNotebooks bool `json:"include_sub_notebooks,omitempty"` // Also search the sub-notebooks of NotebookIDs in hybrid vector search
 }
 
 // ChunkRetrievalResponse represents chunks retrieved from AudiModal
@@ -124,15 +128,16 @@ type StoredChunk struct {
 
 // ExecutionContextRequest extends the execution request with document selection
 type ExecutionContextRequest struct {
-	Input               string      `json:"input"`
-	History             []Message   `json:"history,omitempty"`
-	SessionID           *string     `json:"session_id,omitempty"`
-	NotebookIDs         []uuid.UUID `json:"notebook_ids,omitempty"`          // Override agent's notebook IDs for context retrieval
-	SelectedDocuments   []uuid.UUID `json:"selected_documents,omitempty"`    // Per-execution document selection
-	IncludeSubNotebooks bool        `json:"include_sub_notebooks,omitempty"` // Include docs from sub-notebooks
-	DisableKnowledge    bool        `json:"disable_knowledge,omitempty"`     // Temporarily disable knowledge retrieval
-	TenantID            string      `json:"tenant_id,omitempty"`             // Tenant ID for document retrieval
-	AuthToken           string      `json:"-"`                               // Auth token for downstream API calls (not serialized)
+	Input               string        `json:"input"`
+	Parts               []ContentPart `json:"parts,omitempty"`                 // Multimodal parts sent with the input (images, files)
+	History             []Message     `json:"history,omitempty"`
+	SessionID           *string       `json:"session_id,omitempty"`
+	NotebookIDs         []uuid.UUID   `json:"notebook_ids,omitempty"`          // Override agent's notebook IDs for context retrieval
+	SelectedDocuments   []uuid.UUID   `json:"selected_documents,omitempty"`    // Per-execution document selection
+	IncludeSubNotebooks bool          `json:"include_sub_notebooks,omitempty"` // Include docs from sub-notebooks
+	DisableKnowledge    bool          `json:"disable_knowledge,omitempty"`     // Temporarily disable knowledge retrieval
+	TenantID            string        `json:"tenant_id,omitempty"`             // Tenant ID for document retrieval
+	AuthToken           string        `json:"-"`                               // Auth token for downstream API calls (not serialized)
 }
 
 // Message represents a conversation message
@@ -192,6 +197,32 @@ type ContextInjectionResult struct {
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// Citation identifies a document chunk that was injected into an agent's context
+type Citation struct {
+	DocumentID   string  `json:"document_id"`
+	DocumentName string  `json:"document_name,omitempty"`
+	NotebookID   string  `json:"notebook_id,omitempty"`
+	ChunkNumber  int     `json:"chunk_number"`
+	PageNumber   *int    `json:"page_number,omitempty"`
+	Score        float64 `json:"score,omitempty"`
+}
+
+// CitationsFor returns the citations of the given chunks, in order
+func CitationsFor(chunks []RetrievedChunk) []Citation {
+	citations := make([]Citation, 0, len(chunks))
+	for _, chunk := range chunks {
+		citations = append(citations, Citation{
+			DocumentID:   chunk.DocumentID,
+			DocumentName: chunk.DocumentName,
+			NotebookID:   chunk.NotebookID,
+			ChunkNumber:  chunk.ChunkNumber,
+			PageNumber:   chunk.PageNumber,
+			Score:        chunk.Score,
+		})
+	}
+	return citations
+}
+
 // HybridContextConfig defines configuration for hybrid context retrieval strategy
 type HybridContextConfig struct {
 	// Weight for vector search results (0.0 - 1.0)
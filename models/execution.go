@@ -12,12 +12,13 @@ import (
 type ExecutionStatus string
 
 const (
-	ExecutionStatusQueued     ExecutionStatus = "queued"
-	ExecutionStatusRunning    ExecutionStatus = "running"
-	ExecutionStatusCompleted  ExecutionStatus = "completed"
-	ExecutionStatusFailed     ExecutionStatus = "failed"
-	ExecutionStatusTimeout    ExecutionStatus = "timeout"
-	ExecutionStatusCancelled  ExecutionStatus = "cancelled"
+	ExecutionStatusQueued           ExecutionStatus = "queued"
+	ExecutionStatusRunning          ExecutionStatus = "running"
+	ExecutionStatusCompleted        ExecutionStatus = "completed"
+	ExecutionStatusFailed           ExecutionStatus = "failed"
+	ExecutionStatusTimeout          ExecutionStatus = "timeout"
+	ExecutionStatusCancelled        ExecutionStatus = "cancelled"
+	ExecutionStatusAwaitingApproval ExecutionStatus = "awaiting_approval" // Paused until a user approves or rejects its tool calls
 )
 
 type ExecutionStep struct {
@@ -82,6 +83,39 @@ func (e *ExecutionStepList) Scan(value interface{}) error {
 	return nil
 }
 
+// PendingToolCall is a tool call the model made that waits for a user's approval
+type PendingToolCall struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	SkillID   uuid.UUID      `json:"skill_id"`
+	SkillName string         `json:"skill_name"`
+}
+
+// PendingToolCallList is a custom type for GORM to handle a JSONB array of PendingToolCall
+type PendingToolCallList []PendingToolCall
+
+func (p PendingToolCallList) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *PendingToolCallList) Scan(value interface{}) error {
+	if value == nil {
+		*p = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), p)
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
 type RouterResponse struct {
 	Provider        string         `json:"provider"`
 	Model           string         `json:"model"`
@@ -115,6 +149,9 @@ type AgentExecution struct {
 	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
 	
 	SessionID *string `json:"session_id,omitempty" gorm:"index"`
+
+	// Set on executions of agents called as tools by another execution's agent
+	ParentExecutionID *uuid.UUID `json:"parent_execution_id,omitempty" gorm:"type:uuid;index"`
 	
 	InputData  datatypes.JSON `json:"input_data" gorm:"type:jsonb;not null"`
 	OutputData datatypes.JSON `json:"output_data,omitempty" gorm:"type:jsonb"`
@@ -124,6 +161,11 @@ type AgentExecution struct {
 	RouterResponse *RouterResponse `json:"router_response,omitempty" gorm:"type:jsonb"`
 	
 	ExecutionSteps ExecutionStepList `json:"execution_steps,omitempty" gorm:"type:jsonb;default:'[]'"`
+
+	// Set while the execution is awaiting approval: the calls waiting for a decision and the
+	// tool loop state it resumes from
+	PendingToolCalls PendingToolCallList `json:"pending_tool_calls,omitempty" gorm:"type:jsonb"`
+	ToolLoopState    datatypes.JSON      `json:"-" gorm:"type:jsonb"`
 	
 	TokenUsage      *int     `json:"token_usage,omitempty"`
 	CostUSD         *float64 `json:"cost_usd,omitempty" gorm:"type:decimal(10,6)"`
@@ -139,6 +181,15 @@ type AgentExecution struct {
 	ActualCostUSD       *float64        `json:"actual_cost_usd,omitempty" gorm:"type:decimal(10,6)"`
 	EstimatedCostUSD    *float64        `json:"estimated_cost_usd,omitempty" gorm:"type:decimal(10,6)"`
 	
+	// A/B experiment assignment
+	Experiment *string `json:"experiment,omitempty" gorm:"type:varchar(255)"`
+	Variant    *string `json:"variant,omitempty" gorm:"type:varchar(255)"`
+
+	// User feedback on the response
+	FeedbackRating  *int       `json:"feedback_rating,omitempty"`
+	FeedbackComment *string    `json:"feedback_comment,omitempty"`
+	FeedbackAt      *time.Time `json:"feedback_at,omitempty"`
+	
 	ErrorMessage   *string `json:"error_message,omitempty"`
 	ErrorDetails   datatypes.JSON `json:"error_details,omitempty" gorm:"type:jsonb"`
 	
@@ -158,8 +209,26 @@ func (AgentExecution) TableName() string {
 
 type StartExecutionRequest struct {
 	AgentID   uuid.UUID      `json:"agent_id" validate:"required"`
-	SessionID *string        `json:"session_id,omitempty"`
-	InputData map[string]any `json:"input_data" validate:"required"`
+	SessionID  *string        `json:"session_id,omitempty"`
+	InputData  map[string]any `json:"input_data" validate:"required"`
+	Experiment *string        `json:"experiment,omitempty"`
+	Variant    *string        `json:"variant,omitempty"`
+
+	ParentExecutionID *uuid.UUID `json:"parent_execution_id,omitempty"`
+}
+
+// ApproveToolCallsRequest approves an execution's pending tool calls and resumes it
+type ApproveToolCallsRequest struct {
+	// Calls to approve; empty approves all of them and any not listed are rejected
+	ToolCallIDs []string `json:"tool_call_ids,omitempty"`
+	// Replacement arguments by tool call ID, for calls the user edited before approving
+	Arguments map[string]map[string]any `json:"arguments,omitempty"`
+}
+
+// RejectToolCallsRequest rejects all of an execution's pending tool calls and resumes it so the
+// model can respond without them
+type RejectToolCallsRequest struct {
+	Reason string `json:"reason,omitempty"` // Passed to the model
 }
 
 type ExecutionResponse struct {
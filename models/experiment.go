@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// AgentVariant is one arm of an A/B experiment. Unset fields fall back to the agent's own
+// configuration.
+type AgentVariant struct {
+	Name         string          `json:"name"`
+	Weight       int             `json:"weight"`                  // Relative share of traffic
+	LLMConfig    *AgentLLMConfig `json:"llm_config,omitempty"`    // Replaces the agent's llm_config
+	SystemPrompt *string         `json:"system_prompt,omitempty"` // Replaces the agent's system_prompt
+}
+
+// AgentExperiment splits an agent's traffic between weighted variants. Assignment is sticky
+// per session so a conversation never switches variant mid-way.
+type AgentExperiment struct {
+	Name      string         `json:"name"`
+	Enabled   bool           `json:"enabled"`
+	Variants  []AgentVariant `json:"variants"`
+	StartedAt *time.Time     `json:"started_at,omitempty"`
+}
+
+func (e AgentExperiment) Value() (driver.Value, error) {
+	return json.Marshal(e)
+}
+
+func (e *AgentExperiment) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), e)
+	}
+
+	return json.Unmarshal(bytes, e)
+}
+
+// Validate checks the experiment has a name and at least two uniquely named, positively
+// weighted variants
+func (e *AgentExperiment) Validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("experiment name is required")
+	}
+	if len(e.Variants) < 2 {
+		return fmt.Errorf("experiment %s needs at least two variants", e.Name)
+	}
+
+	seen := make(map[string]bool, len(e.Variants))
+	for i, v := range e.Variants {
+		if v.Name == "" {
+			return fmt.Errorf("variant %d: name is required", i)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("variant %s: duplicate name", v.Name)
+		}
+		seen[v.Name] = true
+		if v.Weight <= 0 {
+			return fmt.Errorf("variant %s: weight must be positive", v.Name)
+		}
+		if v.LLMConfig != nil && (v.LLMConfig.Provider == "" || v.LLMConfig.Model == "") {
+			return fmt.Errorf("variant %s: llm_config needs provider and model", v.Name)
+		}
+	}
+	return nil
+}
+
+// Assign picks a variant for stickyKey (a session or user ID). The same key always maps to the
+// same variant while the variants and weights are unchanged. Returns nil when the experiment is
+// disabled or has no variants.
+func (e *AgentExperiment) Assign(stickyKey string) *AgentVariant {
+	if e == nil || !e.Enabled {
+		return nil
+	}
+
+	total := 0
+	for _, v := range e.Variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(e.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(stickyKey))
+	bucket := int(h.Sum64() % uint64(total))
+
+	for i := range e.Variants {
+		if e.Variants[i].Weight <= 0 {
+			continue
+		}
+		if bucket < e.Variants[i].Weight {
+			return &e.Variants[i]
+		}
+		bucket -= e.Variants[i].Weight
+	}
+	return nil
+}
+
+// Variant returns the named variant, or nil when the experiment has none by that name. Unlike
+// Assign it ignores whether the experiment is enabled, so work started under a variant can
+// finish under it.
+func (e *AgentExperiment) Variant(name string) *AgentVariant {
+	if e == nil || name == "" {
+		return nil
+	}
+	for i := range e.Variants {
+		if e.Variants[i].Name == name {
+			return &e.Variants[i]
+		}
+	}
+	return nil
+}
+
+// WithVariant returns a copy of the agent with the variant's overrides applied
+func (a *Agent) WithVariant(v *AgentVariant) *Agent {
+	if v == nil {
+		return a
+	}
+
+	copied := *a
+	if v.LLMConfig != nil {
+		copied.LLMConfig = *v.LLMConfig
+	}
+	if v.SystemPrompt != nil {
+		copied.SystemPrompt = *v.SystemPrompt
+	}
+	return &copied
+}
+
+// ExecutionFeedback is a user's rating of an execution's response
+type ExecutionFeedback struct {
+	Rating  int    `json:"rating" validate:"required"` // 1 (helpful) or -1 (not helpful)
+	Comment string `json:"comment,omitempty"`
+}
+
+// Validate checks the rating is a thumbs up or down
+func (f ExecutionFeedback) Validate() error {
+	if f.Rating != 1 && f.Rating != -1 {
+		return fmt.Errorf("rating must be 1 or -1")
+	}
+	return nil
+}
+
+// VariantStats aggregates the executions served by one variant
+type VariantStats struct {
+	Variant          string  `json:"variant"`
+	Executions       int64   `json:"executions"`
+	Completed        int64   `json:"completed"`
+	Failed           int64   `json:"failed"`
+	SuccessRate      float64 `json:"success_rate"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+	LatencyStdDevMs  float64 `json:"latency_stddev_ms"`
+	AvgCostUSD       float64 `json:"avg_cost_usd"`
+	CostStdDevUSD    float64 `json:"cost_stddev_usd"`
+	TotalCostUSD     float64 `json:"total_cost_usd"`
+	FeedbackCount    int64   `json:"feedback_count"`
+	PositiveFeedback int64   `json:"positive_feedback"`
+	PositiveRate     float64 `json:"positive_rate"`
+}
+
+// VariantComparison compares a variant against the control (the first variant). P-values come
+// from two-proportion z-tests for rates and Welch z-tests for means; nil means too little data.
+type VariantComparison struct {
+	Variant            string   `json:"variant"`
+	Control            string   `json:"control"`
+	SuccessRateDiff    float64  `json:"success_rate_diff"`
+	SuccessRatePValue  *float64 `json:"success_rate_p_value,omitempty"`
+	LatencyDiffMs      float64  `json:"latency_diff_ms"`
+	LatencyPValue      *float64 `json:"latency_p_value,omitempty"`
+	CostDiffUSD        float64  `json:"cost_diff_usd"`
+	CostPValue         *float64 `json:"cost_p_value,omitempty"`
+	PositiveRateDiff   float64  `json:"positive_rate_diff"`
+	PositiveRatePValue *float64 `json:"positive_rate_p_value,omitempty"`
+	Significant        bool     `json:"significant"` // Any p-value below 0.05
+}
+
+// ExperimentResults is the response of the experiment results endpoint
+type ExperimentResults struct {
+	AgentID     string              `json:"agent_id"`
+	Experiment  string              `json:"experiment"`
+	Active      bool                `json:"active"`
+	Variants    []VariantStats      `json:"variants"`
+	Comparisons []VariantComparison `json:"comparisons"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
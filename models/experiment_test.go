@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testExperiment() *AgentExperiment {
+	prompt := "Be terse."
+	return &AgentExperiment{
+		Name:    "gpt-vs-claude",
+		Enabled: true,
+		Variants: []AgentVariant{
+			{Name: "control", Weight: 3},
+			{Name: "claude", Weight: 1, LLMConfig: &AgentLLMConfig{Provider: "anthropic", Model: "claude-3-5-sonnet"}, SystemPrompt: &prompt},
+		},
+	}
+}
+
+func TestAgentExperimentAssign(t *testing.T) {
+	exp := testExperiment()
+
+	t.Run("sticky per key", func(t *testing.T) {
+		first := exp.Assign("session:abc")
+		require.NotNil(t, first)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, first.Name, exp.Assign("session:abc").Name)
+		}
+	})
+
+	t.Run("follows weights", func(t *testing.T) {
+		counts := map[string]int{}
+		for i := 0; i < 4000; i++ {
+			counts[exp.Assign(fmt.Sprintf("session:%d", i)).Name]++
+		}
+		assert.InDelta(t, 3000, counts["control"], 150)
+		assert.InDelta(t, 1000, counts["claude"], 150)
+	})
+
+	t.Run("disabled or missing experiment assigns nothing", func(t *testing.T) {
+		var none *AgentExperiment
+		assert.Nil(t, none.Assign("x"))
+
+		disabled := testExperiment()
+		disabled.Enabled = false
+		assert.Nil(t, disabled.Assign("x"))
+	})
+}
+
+func TestAgentWithVariant(t *testing.T) {
+	agent := &Agent{SystemPrompt: "Be helpful.", LLMConfig: AgentLLMConfig{Provider: "openai", Model: "gpt-4o"}}
+	exp := testExperiment()
+
+	assert.Same(t, agent, agent.WithVariant(nil))
+	assert.Equal(t, agent, agent.WithVariant(&exp.Variants[0]), "control variant keeps the agent's config")
+
+	varied := agent.WithVariant(&exp.Variants[1])
+	assert.Equal(t, "anthropic", varied.LLMConfig.Provider)
+	assert.Equal(t, "Be terse.", varied.SystemPrompt)
+	assert.Equal(t, "openai", agent.LLMConfig.Provider, "original agent is untouched")
+}
+
+func TestAgentExperimentValidate(t *testing.T) {
+	assert.NoError(t, testExperiment().Validate())
+
+	exp := testExperiment()
+	exp.Variants = exp.Variants[:1]
+	assert.Error(t, exp.Validate())
+
+	exp = testExperiment()
+	exp.Variants[1].Name = "control"
+	assert.ErrorContains(t, exp.Validate(), "duplicate")
+
+	exp = testExperiment()
+	exp.Variants[0].Weight = 0
+	assert.ErrorContains(t, exp.Validate(), "weight")
+}
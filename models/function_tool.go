@@ -0,0 +1,163 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// FunctionTool is a tool of a function skill that is served by an HTTP request. URL and header
+// values may reference the call's arguments as {{name}}; arguments not referenced go in the
+// query string for GET and DELETE, or in a JSON body otherwise.
+type FunctionTool struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"` // JSON Schema for the arguments
+	Method       string                 `json:"method"`
+	URL          string                 `json:"url"`
+	Headers      map[string]string      `json:"headers,omitempty"`
+	ResponsePath string                 `json:"response_path,omitempty"` // JSONPath selecting the text returned to the model
+}
+
+// FunctionTools is stored as JSONB on the skill
+type FunctionTools []FunctionTool
+
+func (f FunctionTools) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+	return json.Marshal(f)
+}
+
+func (f *FunctionTools) Scan(value interface{}) error {
+	if value == nil {
+		*f = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), f)
+	}
+	return json.Unmarshal(bytes, f)
+}
+
+var (
+	functionToolName    = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	functionPlaceholder = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_]+)\s*\}\}`)
+)
+
+// Validate checks the tool definitions of a function skill
+func (f FunctionTools) Validate() error {
+	if len(f) == 0 {
+		return fmt.Errorf("function skills need at least one tool")
+	}
+
+	seen := make(map[string]bool)
+	for _, tool := range f {
+		if !functionToolName.MatchString(tool.Name) {
+			return fmt.Errorf("tool name %q must be 1-64 letters, digits, '_' or '-'", tool.Name)
+		}
+		if seen[tool.Name] {
+			return fmt.Errorf("duplicate tool name %q", tool.Name)
+		}
+		seen[tool.Name] = true
+
+		switch strings.ToUpper(tool.Method) {
+		case "GET", "POST", "PUT", "PATCH", "DELETE":
+		default:
+			return fmt.Errorf("tool %q: method must be GET, POST, PUT, PATCH or DELETE", tool.Name)
+		}
+
+		if err := validateURLTemplate(tool.URL); err != nil {
+			return fmt.Errorf("tool %q: %w", tool.Name, err)
+		}
+
+		if tool.Parameters != nil {
+			if t, _ := tool.Parameters["type"].(string); t != "object" {
+				return fmt.Errorf("tool %q: parameters must be a JSON Schema of type object", tool.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateURLTemplate requires an absolute http(s) URL whose host is fixed, so arguments can
+// only fill in the path and query
+func validateURLTemplate(tmpl string) error {
+	u, err := url.Parse(functionPlaceholder.ReplaceAllString(tmpl, "x"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	if loc := functionPlaceholder.FindStringIndex(tmpl); loc != nil {
+		authorityEnd := strings.Index(tmpl, "://") + 3
+		if slash := strings.IndexAny(tmpl[authorityEnd:], "/?#"); slash >= 0 {
+			authorityEnd += slash
+		} else {
+			authorityEnd = len(tmpl)
+		}
+		if loc[0] < authorityEnd {
+			return fmt.Errorf("url placeholders are only allowed in the path and query")
+		}
+	}
+	return nil
+}
+
+// RenderURL fills the URL template with escaped arguments and returns the names it used
+func (t FunctionTool) RenderURL(args map[string]interface{}) (string, map[string]bool) {
+	used := make(map[string]bool)
+	fill := func(tmpl string, escape func(string) string) string {
+		return functionPlaceholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+			name := functionPlaceholder.FindStringSubmatch(m)[1]
+			used[name] = true
+			return escape(templateValue(args[name]))
+		})
+	}
+
+	path, query, hasQuery := strings.Cut(t.URL, "?")
+	rendered := fill(path, escapePathSegment)
+	if hasQuery {
+		rendered += "?" + fill(query, url.QueryEscape)
+	}
+	return rendered, used
+}
+
+// RenderHeaders fills header templates with arguments and returns the names used
+func (t FunctionTool) RenderHeaders(args map[string]interface{}) (map[string]string, map[string]bool) {
+	used := make(map[string]bool)
+	headers := make(map[string]string, len(t.Headers))
+	for k, v := range t.Headers {
+		headers[k] = functionPlaceholder.ReplaceAllStringFunc(v, func(m string) string {
+			name := functionPlaceholder.FindStringSubmatch(m)[1]
+			used[name] = true
+			// Header values must stay on one line
+			return strings.NewReplacer("\r", "", "\n", "").Replace(templateValue(args[name]))
+		})
+	}
+	return headers, used
+}
+
+// escapePathSegment escapes a value for a path segment; dot segments are encoded so an argument
+// cannot walk up the path
+func escapePathSegment(v string) string {
+	if v == "." || v == ".." {
+		return strings.ReplaceAll(v, ".", "%2E")
+	}
+	return url.PathEscape(v)
+}
+
+func templateValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
@@ -151,6 +151,13 @@ type MemoryContext struct {
 	Strategy           string `json:"strategy"` // How memory was prioritized
 }
 
+// MemoryBudget holds per-tier token limits for formatted memory; a zero limit excludes the tier
+type MemoryBudget struct {
+	ShortTerm int `json:"short_term"`
+	Working   int `json:"working"`
+	LongTerm  int `json:"long_term"`
+}
+
 // AddMemoryRequest represents a request to add a memory entry
 type AddMemoryRequest struct {
 	SessionID string                 `json:"session_id"`
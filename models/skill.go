@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,14 +13,22 @@ type SkillType string
 
 const (
 	SkillTypeMCP      SkillType = "mcp"      // MCP server tools
-	SkillTypeFunction SkillType = "function"  // Custom functions (future)
-	SkillTypeBuiltin  SkillType = "builtin"   // Built-in capabilities
+	SkillTypeFunction SkillType = "function" // HTTP webhook tools
+	SkillTypeBuiltin  SkillType = "builtin"  // Built-in capabilities
+	SkillTypeAgent    SkillType = "agent"    // Other agents, called with an input
+)
+
+// Skill visibilities
+const (
+	SkillVisibilityPrivate = "private" // Only its owner sees and uses it
+	SkillVisibilityTenant  = "tenant"  // Every user of its tenant
+	SkillVisibilityGlobal  = "global"  // Every tenant, unless the tenant's admins disable it
 )
 
 // Skill represents a capability that can be assigned to agents
 type Skill struct {
 	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Name        string         `json:"name" gorm:"uniqueIndex;not null"`
+	Name        string         `json:"name" gorm:"not null;uniqueIndex:idx_skills_tenant_name,priority:2"`
 	DisplayName string         `json:"display_name" gorm:"not null"`
 	Description string         `json:"description"`
 	Type        SkillType      `json:"type" gorm:"type:varchar(50);not null"`
@@ -30,50 +39,204 @@ type Skill struct {
 	// MCP-specific fields
 	MCPServerURL string         `json:"mcp_server_url,omitempty"`
 	MCPToolNames datatypes.JSON `json:"mcp_tool_names" gorm:"type:jsonb;default:'[]'"`
+	MCPTransport string         `json:"mcp_transport,omitempty" gorm:"type:varchar(50)"` // "streamable_http", "sse", "rest", "stdio" or empty to auto-detect
+
+	// Stdio transport: the server is launched locally instead of reached at MCPServerURL.
+	// Env values are write-only since they usually hold API keys.
+	MCPCommand string         `json:"mcp_command,omitempty"`
+	MCPArgs    datatypes.JSON `json:"mcp_args,omitempty" gorm:"type:jsonb"`
+	MCPEnv     datatypes.JSON `json:"-" gorm:"type:jsonb"`
+
+	// Function-specific fields
+	FunctionTools FunctionTools `json:"function_tools,omitempty" gorm:"type:jsonb"`
+
+	// Agent-specific fields: the agents offered as tools, each run with its own configuration
+	AgentIDs datatypes.JSON `json:"agent_ids,omitempty" gorm:"type:jsonb"`
+
+	// Authentication sent to HTTP MCP servers and function tools. Values may reference the
+	// owner's credentials as {{credential:name}}, which are resolved only when calling.
+	AuthHeaders CredentialTemplates `json:"auth_headers,omitempty" gorm:"type:jsonb"`
+	AuthQuery   CredentialTemplates `json:"auth_query,omitempty" gorm:"type:jsonb"`
+
+	// TenantCredentials lets the auth templates also use the tenant's credentials. Only skill
+	// admins set it, and an update by anyone else clears it; global skills always may.
+	TenantCredentials bool `json:"tenant_credentials" gorm:"not null;default:false"`
+
+	// Tools whose calls pause the execution until a user approves them; "*" covers every tool
+	RequiresApproval datatypes.JSON `json:"requires_approval" gorm:"type:jsonb;default:'[]'"`
+
+	// Masking applied to the skill's tool call arguments and results before they are audited
+	AuditRedaction RedactionRules `json:"audit_redaction" gorm:"type:jsonb"`
+
+	// Tool call limits; zero uses the service defaults
+	ToolTimeoutSeconds  int `json:"tool_timeout_seconds,omitempty"`
+	ToolMaxRetries      int `json:"tool_max_retries,omitempty"`       // Retries after failures that are not tool errors
+	ToolMaxResultTokens int `json:"tool_max_result_tokens,omitempty"` // Longer results are truncated
+
+	// Ownership. Names are unique per tenant; global skills have no tenant. Skills created
+	// before tenancy default to global, which is how they were shared.
+	TenantID   string     `json:"tenant_id,omitempty" gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_skills_tenant_name,priority:1"`
+	OwnerID    *uuid.UUID `json:"owner_id,omitempty" gorm:"type:uuid;index"`
+	Visibility string     `json:"visibility" gorm:"type:varchar(20);not null;default:'global'"`
 
 	// Metadata
-	IsPublic  bool   `json:"is_public" gorm:"default:true"`
-	IsSystem  bool   `json:"is_system" gorm:"default:false"`
-	Author    string `json:"author,omitempty"`
-	Version   string `json:"version" gorm:"default:'1.0.0'"`
+	IsSystem bool   `json:"is_system" gorm:"default:false"`
+	Author   string `json:"author,omitempty"`
+	Version  string `json:"version" gorm:"default:'1.0.0'"` // Latest version; see SkillVersion
 
 	CreatedAt time.Time  `json:"created_at" gorm:"not null;default:now()"`
 	UpdatedAt time.Time  `json:"updated_at" gorm:"not null;default:now()"`
 	DeletedAt *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+
+	// Set when the skill was resolved from a pinned reference such as "name@^1"
+	ResolvedVersion *SkillVersion `json:"resolved_version,omitempty" gorm:"-"`
+
+	// Set when the skill was selected for an agent's execution
+	Selection *SkillSelection `json:"selection,omitempty" gorm:"-"`
+
+	// Set on global skills listed for a tenant: whether the tenant's admins left it enabled
+	Enabled *bool `json:"enabled,omitempty" gorm:"-"`
+}
+
+// Reasons a skill is selected for an execution
+const (
+	SkillSelectedAssigned = "assigned" // Listed in the agent's skills
+	SkillSelectedRelevant = "relevant" // Its description is relevant to the system prompt or input
+)
+
+// Texts a skill's relevance is scored against
+const (
+	SkillSourceSystemPrompt = "system_prompt"
+	SkillSourceInput        = "input"
+)
+
+// SkillSelection explains why a skill was offered to an execution and which of its tools the
+// model saw. It is reported in the execution metadata.
+type SkillSelection struct {
+	Skill        string   `json:"skill"`
+	Reason       string   `json:"reason"`                  // SkillSelectedAssigned or SkillSelectedRelevant
+	Source       string   `json:"source,omitempty"`        // Text a relevant skill matched best
+	Relevance    float64  `json:"relevance,omitempty"`     // From 0 to 1; see relevance.Match
+	MatchedWords []string `json:"matched_words,omitempty"` // Words of the source found in the skill's description
+	Tools        []string `json:"tools,omitempty"`         // Tools offered to the model
+	DroppedTools []string `json:"dropped_tools,omitempty"` // Tools left out by the per-request tool limit
 }
 
 func (Skill) TableName() string {
 	return "agent_builder.skills"
 }
 
+// VisibleTo reports whether a user of the tenant may see and use the skill
+func (s *Skill) VisibleTo(tenantID string, userID uuid.UUID) bool {
+	switch s.Visibility {
+	case SkillVisibilityGlobal:
+		return true
+	case SkillVisibilityTenant:
+		return tenantID != "" && s.TenantID == tenantID
+	case SkillVisibilityPrivate:
+		return tenantID != "" && s.TenantID == tenantID && s.OwnerID != nil && *s.OwnerID == userID
+	}
+	return false
+}
+
+// ValidSkillVisibility reports whether v is a known visibility
+func ValidSkillVisibility(v string) bool {
+	return v == SkillVisibilityPrivate || v == SkillVisibilityTenant || v == SkillVisibilityGlobal
+}
+
+// UsesCredentials reports whether the skill's requests carry authentication
+func (s *Skill) UsesCredentials() bool {
+	return len(s.AuthHeaders) > 0 || len(s.AuthQuery) > 0
+}
+
+// ToolRequiresApproval reports whether calls to the named tool wait for a user's approval
+func (s *Skill) ToolRequiresApproval(tool string) bool {
+	if len(s.RequiresApproval) == 0 {
+		return false
+	}
+	var names []string
+	if err := json.Unmarshal(s.RequiresApproval, &names); err != nil {
+		return false
+	}
+	for _, name := range names {
+		if name == tool || name == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantSkillSetting records a tenant admin's choice to disable, or enable again, a global
+// skill for the tenant. Global skills without a setting are enabled.
+type TenantSkillSetting struct {
+	TenantID  string    `json:"tenant_id" gorm:"type:varchar(255);primaryKey"`
+	SkillID   uuid.UUID `json:"skill_id" gorm:"type:uuid;primaryKey"`
+	Enabled   bool      `json:"enabled" gorm:"not null"`
+	UpdatedBy uuid.UUID `json:"updated_by" gorm:"type:uuid"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:now()"`
+}
+
+func (TenantSkillSetting) TableName() string {
+	return "agent_builder.tenant_skill_settings"
+}
+
 // CreateSkillRequest is the request to create a new skill
 type CreateSkillRequest struct {
-	Name         string    `json:"name" validate:"required,min=1,max=255"`
-	DisplayName  string    `json:"display_name" validate:"required,min=1,max=255"`
-	Description  string    `json:"description" validate:"max=1000"`
-	Type         SkillType `json:"type" validate:"required"`
-	Icon         string    `json:"icon,omitempty"`
-	Tags         []string  `json:"tags"`
-	Keywords     []string  `json:"keywords"`
-	MCPServerURL string    `json:"mcp_server_url,omitempty"`
-	MCPToolNames []string  `json:"mcp_tool_names,omitempty"`
-	IsPublic     bool      `json:"is_public"`
-	Author       string    `json:"author,omitempty"`
-	Version      string    `json:"version,omitempty"`
+	Name                string              `json:"name" validate:"required,min=1,max=255"`
+	DisplayName         string              `json:"display_name" validate:"required,min=1,max=255"`
+	Description         string              `json:"description" validate:"max=1000"`
+	Type                SkillType           `json:"type" validate:"required"`
+	Icon                string              `json:"icon,omitempty"`
+	Tags                []string            `json:"tags"`
+	Keywords            []string            `json:"keywords"`
+	MCPServerURL        string              `json:"mcp_server_url,omitempty"`
+	MCPToolNames        []string            `json:"mcp_tool_names,omitempty"`
+	MCPTransport        string              `json:"mcp_transport,omitempty"`
+	MCPCommand          string              `json:"mcp_command,omitempty"`
+	MCPArgs             []string            `json:"mcp_args,omitempty"`
+	MCPEnv              map[string]string   `json:"mcp_env,omitempty"`
+	ToolTimeoutSeconds  int                 `json:"tool_timeout_seconds,omitempty"`
+	ToolMaxRetries      int                 `json:"tool_max_retries,omitempty"`
+	ToolMaxResultTokens int                 `json:"tool_max_result_tokens,omitempty"`
+	FunctionTools       FunctionTools       `json:"function_tools,omitempty"`
+	AgentIDs            []uuid.UUID         `json:"agent_ids,omitempty"`
+	AuthHeaders         CredentialTemplates `json:"auth_headers,omitempty"`
+	AuthQuery           CredentialTemplates `json:"auth_query,omitempty"`
+	RequiresApproval    []string            `json:"requires_approval,omitempty"`
+	AuditRedaction      *RedactionRules     `json:"audit_redaction,omitempty"`
+	Visibility          string              `json:"visibility,omitempty"` // Defaults to private
+	Author              string              `json:"author,omitempty"`
+	Version             string              `json:"version,omitempty"`
 }
 
 // UpdateSkillRequest is the request to update an existing skill
 type UpdateSkillRequest struct {
-	DisplayName  *string    `json:"display_name,omitempty"`
-	Description  *string    `json:"description,omitempty"`
-	Icon         *string    `json:"icon,omitempty"`
-	Tags         []string   `json:"tags,omitempty"`
-	Keywords     []string   `json:"keywords,omitempty"`
-	MCPServerURL *string    `json:"mcp_server_url,omitempty"`
-	MCPToolNames []string   `json:"mcp_tool_names,omitempty"`
-	IsPublic     *bool      `json:"is_public,omitempty"`
-	Author       *string    `json:"author,omitempty"`
-	Version      *string    `json:"version,omitempty"`
+	DisplayName         *string             `json:"display_name,omitempty"`
+	Description         *string             `json:"description,omitempty"`
+	Icon                *string             `json:"icon,omitempty"`
+	Tags                []string            `json:"tags,omitempty"`
+	Keywords            []string            `json:"keywords,omitempty"`
+	MCPServerURL        *string             `json:"mcp_server_url,omitempty"`
+	MCPToolNames        []string            `json:"mcp_tool_names,omitempty"`
+	MCPTransport        *string             `json:"mcp_transport,omitempty"`
+	MCPCommand          *string             `json:"mcp_command,omitempty"`
+	MCPArgs             []string            `json:"mcp_args,omitempty"`
+	MCPEnv              map[string]string   `json:"mcp_env,omitempty"`
+	ToolTimeoutSeconds  *int                `json:"tool_timeout_seconds,omitempty"`
+	ToolMaxRetries      *int                `json:"tool_max_retries,omitempty"`
+	ToolMaxResultTokens *int                `json:"tool_max_result_tokens,omitempty"`
+	FunctionTools       FunctionTools       `json:"function_tools,omitempty"`
+	AgentIDs            []uuid.UUID         `json:"agent_ids,omitempty"`
+	AuthHeaders         CredentialTemplates `json:"auth_headers,omitempty"`
+	AuthQuery           CredentialTemplates `json:"auth_query,omitempty"`
+	RequiresApproval    []string            `json:"requires_approval,omitempty"`
+	AuditRedaction      *RedactionRules     `json:"audit_redaction,omitempty"`
+	Visibility          *string             `json:"visibility,omitempty"` // Private or tenant; global skills stay global
+	Author              *string             `json:"author,omitempty"`
+	Version             *string             `json:"version,omitempty"`
+
+	// TenantCredentials is set by the handler from the caller's roles, never by the client
+	TenantCredentials *bool `json:"-"`
 }
 
 // SkillListResponse is the paginated response for skill listing
@@ -84,12 +247,97 @@ type SkillListResponse struct {
 	Size   int     `json:"size"`
 }
 
+// Skill health statuses
+const (
+	SkillHealthUnknown   = "unknown"
+	SkillHealthHealthy   = "healthy"
+	SkillHealthUnhealthy = "unhealthy"
+)
+
+// SkillHealth is the latest health check of a skill's MCP server
+type SkillHealth struct {
+	SkillID             uuid.UUID  `json:"skill_id"`
+	Status              string     `json:"status"`
+	CheckedAt           *time.Time `json:"checked_at,omitempty"`
+	LatencyMs           int64      `json:"latency_ms"`
+	ToolCount           int        `json:"tool_count"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Error               string     `json:"error,omitempty"`
+}
+
+// SkillTool is a tool discovered from a skill's MCP server
+type SkillTool struct {
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description,omitempty"`
+	InputSchema      map[string]interface{} `json:"input_schema"`
+	RequiresApproval bool                   `json:"requires_approval,omitempty"`
+}
+
+// SkillToolList is a skill's tools as cached by the service. ETag changes whenever the
+// server's tool list does; Stale is set when the server could not be reached to refresh it.
+type SkillToolList struct {
+	SkillID   uuid.UUID   `json:"skill_id"`
+	Tools     []SkillTool `json:"tools"`
+	ETag      string      `json:"etag"`
+	FetchedAt time.Time   `json:"fetched_at"`
+	Stale     bool        `json:"stale"`
+}
+
 // SkillListFilter defines filter criteria for listing skills
 type SkillListFilter struct {
-	Type     *SkillType `json:"type"`
-	Tags     []string   `json:"tags"`
-	Search   string     `json:"search"`
-	IsPublic *bool      `json:"is_public"`
-	Page     int        `json:"page"`
-	Size     int        `json:"size"`
+	Type       *SkillType `json:"type"`
+	Tags       []string   `json:"tags"`
+	Search     string     `json:"search"`
+	Visibility string     `json:"visibility"`
+	Page       int        `json:"page"`
+	Size       int        `json:"size"`
+
+	// IncludeDisabled also lists the global skills the tenant disabled, for its admins
+	IncludeDisabled bool `json:"include_disabled"`
+}
+
+// OpenAPIImportRequest imports an OpenAPI 3 document as a function skill. Importing under the
+// name of an existing function skill replaces its tools and bumps its version.
+type OpenAPIImportRequest struct {
+	Spec        json.RawMessage        `json:"spec"`                   // The document, as a JSON object or a JSON/YAML string
+	Name        string                 `json:"name,omitempty"`         // Defaults to a slug of info.title
+	DisplayName string                 `json:"display_name,omitempty"` // Defaults to info.title
+	Description string                 `json:"description,omitempty"`  // Defaults to info.description
+	ServerURL   string                 `json:"server_url,omitempty"`   // Overrides the document's first server
+	Operations  []string               `json:"operations,omitempty"`   // operationIds or "METHOD /path"; empty imports all
+	Auth        map[string]OpenAPIAuth `json:"auth,omitempty"`         // Stored credentials keyed by security scheme name
+	Visibility  string                 `json:"visibility,omitempty"`   // Of a new skill; defaults to private
+	DryRun      bool                   `json:"dry_run,omitempty"`      // Report the result without saving it
+}
+
+// OpenAPIAuth names the stored credential for one security scheme. The import adds it to the
+// skill's auth templates as {{credential:name}}, sent where the scheme says (bearer or basic
+// Authorization, or the API key's header, query or cookie) unless Header names a header to
+// send the value verbatim in instead. A basic scheme's credential holds base64("user:password").
+type OpenAPIAuth struct {
+	Credential string `json:"credential"`
+	Header     string `json:"header,omitempty"`
+}
+
+// SkillToolDiff lists the tools a re-import added, removed or changed
+type SkillToolDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Changed   []string `json:"changed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// Empty reports whether the tools are unchanged
+func (d SkillToolDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// OpenAPIImportResponse is the result of an OpenAPI import
+type OpenAPIImportResponse struct {
+	Skill           *Skill         `json:"skill"`
+	Created         bool           `json:"created"`
+	PreviousVersion string         `json:"previous_version,omitempty"`
+	Diff            *SkillToolDiff `json:"diff,omitempty"`
+	Warnings        []string       `json:"warnings,omitempty"`
+	DryRun          bool           `json:"dry_run,omitempty"`
 }
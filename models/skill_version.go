@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// SkillVersion is an immutable snapshot of how a skill reaches its tools. Agents pin one by
+// listing the skill as "name@1.2.0" or a range such as "name@^1" instead of the bare name,
+// which always uses the skill's latest definition.
+type SkillVersion struct {
+	ID      uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	SkillID uuid.UUID `json:"skill_id" gorm:"type:uuid;not null;uniqueIndex:idx_skill_versions_skill_version"`
+	Version string    `json:"version" gorm:"not null;uniqueIndex:idx_skill_versions_skill_version"`
+
+	// Definition as of this version
+	MCPServerURL  string         `json:"mcp_server_url,omitempty"`
+	MCPTransport  string         `json:"mcp_transport,omitempty" gorm:"type:varchar(50)"`
+	MCPCommand    string         `json:"mcp_command,omitempty"`
+	MCPArgs       datatypes.JSON `json:"mcp_args,omitempty" gorm:"type:jsonb"`
+	MCPToolNames  datatypes.JSON `json:"mcp_tool_names" gorm:"type:jsonb;default:'[]'"`
+	FunctionTools FunctionTools  `json:"function_tools,omitempty" gorm:"type:jsonb"`
+	AgentIDs      datatypes.JSON `json:"agent_ids,omitempty" gorm:"type:jsonb"`
+
+	// Tool schemas of an MCP skill as discovered when the version was recorded. Pinned agents
+	// are offered these instead of whatever the server lists now; nil if discovery failed.
+	Tools SkillTools `json:"tools,omitempty" gorm:"type:jsonb"`
+
+	Deprecated         bool       `json:"deprecated" gorm:"default:false"`
+	DeprecationMessage string     `json:"deprecation_message,omitempty"`
+	DeprecatedAt       *time.Time `json:"deprecated_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
+}
+
+func (SkillVersion) TableName() string {
+	return "agent_builder.skill_versions"
+}
+
+// NewSkillVersion snapshots the skill's current definition under its version
+func NewSkillVersion(skill *Skill) *SkillVersion {
+	return &SkillVersion{
+		ID:            uuid.New(),
+		SkillID:       skill.ID,
+		Version:       skill.Version,
+		MCPServerURL:  skill.MCPServerURL,
+		MCPTransport:  skill.MCPTransport,
+		MCPCommand:    skill.MCPCommand,
+		MCPArgs:       skill.MCPArgs,
+		MCPToolNames:  skill.MCPToolNames,
+		FunctionTools: skill.FunctionTools,
+		AgentIDs:      skill.AgentIDs,
+		CreatedAt:     time.Now(),
+	}
+}
+
+// Apply returns the skill as defined by this version. Settings that are not versioned, such as
+// credentials, approvals and limits, are the skill's current ones.
+func (v *SkillVersion) Apply(skill Skill) Skill {
+	skill.Version = v.Version
+	skill.MCPServerURL = v.MCPServerURL
+	skill.MCPTransport = v.MCPTransport
+	skill.MCPCommand = v.MCPCommand
+	skill.MCPArgs = v.MCPArgs
+	skill.MCPToolNames = v.MCPToolNames
+	skill.FunctionTools = v.FunctionTools
+	skill.AgentIDs = v.AgentIDs
+	skill.ResolvedVersion = v
+	return skill
+}
+
+// SkillTools is a JSONB list of tool definitions
+type SkillTools []SkillTool
+
+func (t SkillTools) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
+
+func (t *SkillTools) Scan(value interface{}) error {
+	if value == nil {
+		*t = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), t)
+	}
+	return json.Unmarshal(bytes, t)
+}
+
+// ParseSkillRef splits an agent's skill reference into the skill name and the version
+// constraint, which is empty for a bare name
+func ParseSkillRef(ref string) (name, constraint string) {
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// DeprecateSkillVersionRequest marks a skill version deprecated, or clears the mark
+type DeprecateSkillVersionRequest struct {
+	Deprecated *bool  `json:"deprecated,omitempty"` // Defaults to true
+	Message    string `json:"message,omitempty"`
+}
+
+// SkillVersionListResponse lists a skill's versions, newest first
+type SkillVersionListResponse struct {
+	Versions []SkillVersion `json:"versions"`
+	Total    int            `json:"total"`
+}
+
+// SkillUsage is an agent's reference to a skill and the version it resolves to
+type SkillUsage struct {
+	AgentID         uuid.UUID `json:"agent_id"`
+	AgentName       string    `json:"agent_name"`
+	OwnerID         string    `json:"owner_id"`
+	Reference       string    `json:"reference"`
+	ResolvedVersion string    `json:"resolved_version,omitempty"`
+	Pinned          bool      `json:"pinned"`
+	Deprecated      bool      `json:"deprecated"`      // Pinned to a deprecated version
+	Error           string    `json:"error,omitempty"` // Set when the reference does not resolve
+}
+
+// SkillUsageResponse lists the agents referencing a skill
+type SkillUsageResponse struct {
+	Skill      string       `json:"skill"`
+	Agents     []SkillUsage `json:"agents"`
+	Total      int          `json:"total"`
+	Deprecated int          `json:"deprecated"` // Agents pinned to deprecated versions
+}
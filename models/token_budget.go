@@ -0,0 +1,102 @@
+package models
+
+// Token budget sections, also used as priority names in TokenBudgetConfig
+const (
+	BudgetSectionSystemPrompt   = "system_prompt"
+	BudgetSectionDocuments      = "documents"
+	BudgetSectionWorkingMemory  = "working_memory"
+	BudgetSectionLongTermMemory = "long_term_memory"
+	BudgetSectionHistory        = "history"
+	BudgetSectionOutput         = "output"
+)
+
+// TokenBudgetConfig defines how an agent's context window is divided between prompt sections
+type TokenBudgetConfig struct {
+	// Relative weights for the flexible sections (documents, long-term memory, history).
+	// When all weights are zero the defaults are used.
+	DocumentWeight       float64 `json:"document_weight"`
+	LongTermMemoryWeight float64 `json:"long_term_memory_weight"`
+	HistoryWeight        float64 `json:"history_weight"`
+	// Relative weight for the session's working memory; 0 uses the default
+	WorkingMemoryWeight float64 `json:"working_memory_weight,omitempty"`
+	// Flexible sections in descending priority. Leftover tokens go to higher priorities first
+	// and lower priorities are trimmed first when the window is too small.
+	Priorities []string `json:"priorities,omitempty"`
+	// Tokens reserved for the response; 0 uses llm_config.max_tokens or the model's max output
+	OutputReserve int `json:"output_reserve,omitempty"`
+	// Minimum response reservation kept when the prompt overflows the window
+	MinOutputReserve int `json:"min_output_reserve,omitempty"`
+	// Tokens held back for message framing and estimation error
+	SafetyMargin int `json:"safety_margin,omitempty"`
+	// Upper bound for long-term memory regardless of the window size
+	MaxLongTermMemoryTokens int `json:"max_long_term_memory_tokens,omitempty"`
+}
+
+// DefaultTokenBudgetConfig returns sensible defaults for prompt budgeting
+func DefaultTokenBudgetConfig() *TokenBudgetConfig {
+	return &TokenBudgetConfig{
+		DocumentWeight:          0.5,
+		LongTermMemoryWeight:    0.15,
+		HistoryWeight:           0.35,
+		WorkingMemoryWeight:     0.1,
+		Priorities:              []string{BudgetSectionHistory, BudgetSectionDocuments, BudgetSectionWorkingMemory, BudgetSectionLongTermMemory},
+		MinOutputReserve:        256,
+		SafetyMargin:            64,
+		MaxLongTermMemoryTokens: 2000,
+	}
+}
+
+// TokenBudgetRequest holds the measured and requested sizes of each prompt section
+type TokenBudgetRequest struct {
+	ContextWindow        int // Model context window
+	OutputTokens         int // Requested response reservation
+	SystemPromptTokens   int // Base system prompt (always included)
+	ToolTokens           int // Tool definitions and the tool-use hint sent with the system prompt (always included)
+	InputTokens          int // Current user input (always included)
+	ImageTokens          int // Estimate for the images sent with the input (always included)
+	DocumentDemand       int // Maximum tokens document context may use
+	LongTermMemoryDemand int // Maximum tokens long-term memory may use
+	HistoryDemand        int // Tokens needed for the full conversation history
+	WorkingMemoryDemand  int // Tokens held in the session's working memory
+}
+
+// TokenBudgetPlan is the allocation chosen for a single execution
+type TokenBudgetPlan struct {
+	ContextWindow  int      `json:"context_window"`
+	OutputReserve  int      `json:"output_reserve"`
+	SafetyMargin   int      `json:"safety_margin"`
+	SystemPrompt   int      `json:"system_prompt"`
+	Input          int      `json:"input"`
+	Documents      int      `json:"documents"`
+	LongTermMemory int      `json:"long_term_memory"`
+	History        int      `json:"history"`
+	WorkingMemory  int      `json:"working_memory"`
+	Unallocated    int      `json:"unallocated"`
+	Overflow       int      `json:"overflow,omitempty"` // Tokens the mandatory sections exceed the window by
+	Priorities     []string `json:"priorities"`
+	Trimmed        []string `json:"trimmed,omitempty"` // Sections allocated less than they requested
+}
+
+// ToMetadata converts the plan into a map for context_metadata
+func (p *TokenBudgetPlan) ToMetadata() map[string]interface{} {
+	metadata := map[string]interface{}{
+		"context_window":   p.ContextWindow,
+		"output_reserve":   p.OutputReserve,
+		"safety_margin":    p.SafetyMargin,
+		"system_prompt":    p.SystemPrompt,
+		"input":            p.Input,
+		"documents":        p.Documents,
+		"long_term_memory": p.LongTermMemory,
+		"history":          p.History,
+		"working_memory":   p.WorkingMemory,
+		"unallocated":      p.Unallocated,
+		"priorities":       p.Priorities,
+	}
+	if p.Overflow > 0 {
+		metadata["overflow"] = p.Overflow
+	}
+	if len(p.Trimmed) > 0 {
+		metadata["trimmed"] = p.Trimmed
+	}
+	return metadata
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// Tool call audit statuses
+const (
+	ToolCallStatusSuccess          = "success"
+	ToolCallStatusError            = "error"
+	ToolCallStatusInvalidArguments = "invalid_arguments" // Refused by schema validation without being run
+	ToolCallStatusRejected         = "rejected"          // Not run for lack of approval
+)
+
+// Approval decisions recorded with calls to tools that require approval
+const (
+	ToolCallApproved          = "approved"
+	ToolCallApprovedWithEdits = "approved_with_edits"
+	ToolCallRejected          = "rejected"
+)
+
+// ExecutionToolCall is the audit record of one tool call made during an execution. Arguments
+// and Result are stored after the skill's redaction rules are applied.
+type ExecutionToolCall struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ExecutionID uuid.UUID  `json:"execution_id" gorm:"type:uuid;not null;index"`
+	TenantID    string     `json:"tenant_id,omitempty" gorm:"index"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	AgentID     uuid.UUID  `json:"agent_id" gorm:"type:uuid;not null;index"`
+	Iteration   int        `json:"iteration"` // Model request of the tool loop that issued the call, from 1
+	ToolCallID  string     `json:"tool_call_id"`
+	SkillID     *uuid.UUID `json:"skill_id,omitempty" gorm:"type:uuid;index"`
+	SkillName   string     `json:"skill_name,omitempty"`
+	Server      string     `json:"server,omitempty"` // MCP server URL or command, or the function skill's host
+	ToolName    string     `json:"tool_name" gorm:"not null;index"`
+
+	Arguments       datatypes.JSON `json:"arguments" gorm:"type:jsonb"`
+	Result          string         `json:"result"`
+	ResultTruncated bool           `json:"result_truncated,omitempty"`
+
+	DurationMs int    `json:"duration_ms"`
+	Status     string `json:"status" gorm:"type:varchar(50);not null;index"`
+	Error      string `json:"error,omitempty"`
+
+	ApprovalDecision string `json:"approval_decision,omitempty" gorm:"type:varchar(50)"`
+	ApprovedBy       string `json:"approved_by,omitempty"` // User who decided on the call
+
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now();index"`
+}
+
+func (ExecutionToolCall) TableName() string {
+	return "agent_builder.execution_tool_calls"
+}
+
+// RedactionRules mask secrets and personal data in a skill's tool calls before they are
+// audited. Paths are JSONPath expressions whose values are masked in the arguments and in
+// JSON results; Patterns are regular expressions whose matches are masked in every string.
+type RedactionRules struct {
+	Paths    []string `json:"paths,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+func (r RedactionRules) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *RedactionRules) Scan(value interface{}) error {
+	if value == nil {
+		*r = RedactionRules{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), r)
+	}
+	return json.Unmarshal(bytes, r)
+}
+
+// ToolCallSearchFilter narrows a tenant's tool call audit records
+type ToolCallSearchFilter struct {
+	TenantID    string     `json:"tenant_id"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	AgentID     *uuid.UUID `json:"agent_id,omitempty"`
+	ExecutionID *uuid.UUID `json:"execution_id,omitempty"`
+	SkillName   string     `json:"skill,omitempty"`
+	ToolName    string     `json:"tool,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	ApprovedBy  string     `json:"approved_by,omitempty"`
+	Query       string     `json:"q,omitempty"` // Text to find in the stored arguments or result
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	Page        int        `json:"page"`
+	Size        int        `json:"size"`
+}
+
+// Validate checks the filter's status
+func (f *ToolCallSearchFilter) Validate() error {
+	switch f.Status {
+	case "", ToolCallStatusSuccess, ToolCallStatusError, ToolCallStatusInvalidArguments, ToolCallStatusRejected:
+		return nil
+	}
+	return fmt.Errorf("unknown tool call status %q", f.Status)
+}
+
+// ToolCallListResponse is a page of tool call audit records
+type ToolCallListResponse struct {
+	ToolCalls []ExecutionToolCall `json:"tool_calls"`
+	Total     int64               `json:"total"`
+	Page      int                 `json:"page,omitempty"`
+	Size      int                 `json:"size,omitempty"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Tool choice policies. Any other choice names a tool the model must call first.
+const (
+	ToolChoiceAuto          = "auto"           // The model decides whether to call tools
+	ToolChoiceRequiredFirst = "required_first" // The first request must call some tool
+	ToolChoiceNone          = "none"           // No tools are offered
+)
+
+// DefaultToolHint is appended to the system prompt of agents offered tools unless their policy
+// sets another hint. {{tools}} is replaced by the tool names.
+const DefaultToolHint = "--- AVAILABLE TOOLS ---\nYou have access to the following tools: {{tools}}. " +
+	"When your task involves generating visuals, diagrams, charts, or any action that matches a tool's capability, " +
+	"you MUST call the appropriate tool rather than describing what you would do. " +
+	"After calling a tool, include the tool's result (such as download URLs or file paths) in your final response so the user can access it."
+
+// toolName matches the function names LLM providers accept
+var toolName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// AgentToolPolicy controls how an agent uses the tools of its skills. A nil policy lets the
+// model decide and uses the default hint.
+type AgentToolPolicy struct {
+	Choice       string  `json:"choice,omitempty"`         // "auto" (default), "required_first", "none" or a tool name
+	Hint         *string `json:"hint,omitempty"`           // Replaces DefaultToolHint; "" adds no hint
+	MaxToolCalls int     `json:"max_tool_calls,omitempty"` // Tool calls allowed per execution; 0 is unlimited
+}
+
+func (p AgentToolPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *AgentToolPolicy) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return json.Unmarshal([]byte(value.(string)), p)
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Validate checks the choice and budget
+func (p *AgentToolPolicy) Validate() error {
+	switch p.Choice {
+	case "", ToolChoiceAuto, ToolChoiceRequiredFirst, ToolChoiceNone:
+	default:
+		if !toolName.MatchString(p.Choice) {
+			return fmt.Errorf("choice must be 'auto', 'required_first', 'none' or a tool name")
+		}
+	}
+	if p.MaxToolCalls < 0 {
+		return fmt.Errorf("max_tool_calls must not be negative")
+	}
+	return nil
+}
+
+// EffectiveChoice returns the policy's choice, defaulting to ToolChoiceAuto
+func (p *AgentToolPolicy) EffectiveChoice() string {
+	if p == nil || p.Choice == "" {
+		return ToolChoiceAuto
+	}
+	return p.Choice
+}
+
+// ToolHint renders the hint for the given tools, or returns "" if the policy disables it
+func (p *AgentToolPolicy) ToolHint(tools []string) string {
+	hint := DefaultToolHint
+	if p != nil && p.Hint != nil {
+		hint = *p.Hint
+	}
+	return strings.ReplaceAll(hint, "{{tools}}", strings.Join(tools, ", "))
+}
+
+// ToolCallBudget returns the number of tool calls allowed per execution, 0 meaning no limit
+func (p *AgentToolPolicy) ToolCallBudget() int {
+	if p == nil {
+		return 0
+	}
+	return p.MaxToolCalls
+}
@@ -2,9 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
 type AgentService interface {
@@ -29,6 +31,13 @@ type AgentService interface {
 	GetInternalAgent(ctx context.Context, id uuid.UUID) (*models.Agent, error)
 }
 
+// ErrExecutionNotFound is returned when an execution does not exist or belongs to another user
+var ErrExecutionNotFound = errors.New("execution not found")
+
+// ErrExecutionNotAwaitingApproval is returned when approving or rejecting the tool calls of an
+// execution that is not paused for approval, including one another request already resumed
+var ErrExecutionNotAwaitingApproval = errors.New("execution is not awaiting approval")
+
 type ExecutionService interface {
 	StartExecution(ctx context.Context, req models.StartExecutionRequest, userID uuid.UUID) (*models.AgentExecution, error)
 	CompleteExecution(ctx context.Context, executionID uuid.UUID, status models.ExecutionStatus, outputData map[string]any, errorMsg *string, durationMs int) error
@@ -37,8 +46,18 @@ type ExecutionService interface {
 
 	CancelExecution(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
 
+	// Human-in-the-loop approval. SuspendExecution parks an execution until its pending tool
+	// calls are decided; ResumeExecution claims it back exactly once and returns it with the
+	// saved tool loop state.
+	SuspendExecution(ctx context.Context, id uuid.UUID, pending []models.PendingToolCall, loopState []byte) error
+	ResumeExecution(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.AgentExecution, error)
+
 	GetExecutionsByAgent(ctx context.Context, agentID uuid.UUID, userID uuid.UUID, limit int) ([]models.AgentExecution, error)
 	GetExecutionsBySession(ctx context.Context, sessionID string, userID uuid.UUID) ([]models.AgentExecution, error)
+
+	// A/B experiments
+	SetExecutionFeedback(ctx context.Context, agentID uuid.UUID, id uuid.UUID, userID uuid.UUID, feedback models.ExecutionFeedback) error
+	GetExperimentResults(ctx context.Context, agentID uuid.UUID, experiment string, variantOrder []string) ([]models.VariantStats, []models.VariantComparison, error)
 }
 
 type StatsService interface {
@@ -60,13 +79,17 @@ type RouterService interface {
 	ValidateConfig(ctx context.Context, config models.AgentLLMConfig) error
 	GetAvailableProviders(ctx context.Context) ([]Provider, error)
 	GetProviderModels(ctx context.Context, provider string) ([]Model, error)
+	// GetModelCapabilities describes the model an agent config selects, as known to whichever
+	// backend serves it
+	GetModelCapabilities(ctx context.Context, agentConfig models.AgentLLMConfig) (*ModelCapabilities, error)
 }
 
 type Message struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	Role       string               `json:"role"`
+	Content    string               `json:"content"`
+	Parts      []models.ContentPart `json:"parts,omitempty"` // Multimodal parts sent after Content
+	ToolCallID string               `json:"tool_call_id,omitempty"`
+	ToolCalls  []ToolCall           `json:"tool_calls,omitempty"`
 }
 
 // ToolCall represents a tool call requested by the LLM
@@ -113,15 +136,17 @@ type Provider struct {
 	DisplayName string   `json:"display_name"`
 	Models      []string `json:"models"`
 	Features    []string `json:"features"`
+	Backend     string   `json:"backend,omitempty"` // Set for direct backends; agents select them with llm_config.backend
 }
 
 type Model struct {
-	Name         string  `json:"name"`
-	DisplayName  string  `json:"display_name"`
-	Provider     string  `json:"provider"`
-	MaxTokens    int     `json:"max_tokens"`
-	CostPer1000  float64 `json:"cost_per_1000"`
-	Features     []string `json:"features"`
+	Name          string   `json:"name"`
+	DisplayName   string   `json:"display_name"`
+	Provider      string   `json:"provider"`
+	MaxTokens     int      `json:"max_tokens"`
+	ContextWindow int      `json:"context_window"`
+	CostPer1000   float64  `json:"cost_per_1000"`
+	Features      []string `json:"features"`
 }
 
 // DocumentContextService provides document retrieval and context injection for agents
@@ -141,11 +166,15 @@ type DocumentContextService interface {
 	// GetNotebookDocuments retrieves document list for a notebook (including sub-notebooks if specified)
 	GetNotebookDocuments(ctx context.Context, notebookIDs []uuid.UUID, tenantID string, includeSubNotebooks bool) ([]models.NotebookDocument, error)
 
-	// FormatContextForInjection formats retrieved chunks into a string ready for prompt injection
-	FormatContextForInjection(result *models.DocumentContextResult, maxTokens int) (*models.ContextInjectionResult, error)
+	// FormatContextForInjection formats retrieved chunks into a string ready for prompt injection,
+	// counting tokens with tok (nil uses the default encoding)
+	FormatContextForInjection(result *models.DocumentContextResult, maxTokens int, tok tokenizer.Tokenizer) (*models.ContextInjectionResult, error)
 
 	// EstimateTokenCount estimates the number of tokens in a string
 	EstimateTokenCount(text string) int
+
+	// ResolveFileReference resolves an AudiModal file to a signed URL, or to its bytes when inline is set
+	ResolveFileReference(ctx context.Context, tenantID, fileID, authToken string, inline bool) (*models.ResolvedFile, error)
 }
 
 // ChunkRetrievalService provides chunk retrieval from AudiModal
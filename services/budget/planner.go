@@ -0,0 +1,204 @@
+// Package budget plans how a model's context window is shared between prompt sections.
+package budget
+
+import (
+	"github.com/tas-agent-builder/models"
+)
+
+// defaultContextWindow is used when the model's context window is unknown
+const defaultContextWindow = 8192
+
+// Planner divides a model's context window between the sections of a prompt
+type Planner struct {
+	config *models.TokenBudgetConfig
+}
+
+// NewPlanner creates a new Planner with the given configuration
+func NewPlanner(config *models.TokenBudgetConfig) *Planner {
+	defaults := models.DefaultTokenBudgetConfig()
+	if config == nil {
+		config = defaults
+	}
+
+	normalized := *config
+	if normalized.DocumentWeight <= 0 && normalized.LongTermMemoryWeight <= 0 && normalized.HistoryWeight <= 0 {
+		normalized.DocumentWeight = defaults.DocumentWeight
+		normalized.LongTermMemoryWeight = defaults.LongTermMemoryWeight
+		normalized.HistoryWeight = defaults.HistoryWeight
+	}
+	if normalized.WorkingMemoryWeight <= 0 {
+		normalized.WorkingMemoryWeight = defaults.WorkingMemoryWeight
+	}
+	if normalized.MinOutputReserve <= 0 {
+		normalized.MinOutputReserve = defaults.MinOutputReserve
+	}
+	if normalized.SafetyMargin <= 0 {
+		normalized.SafetyMargin = defaults.SafetyMargin
+	}
+	normalized.Priorities = normalizePriorities(config.Priorities, defaults.Priorities)
+
+	return &Planner{
+		config: &normalized,
+	}
+}
+
+// Plan allocates the context window. The system prompt with its tools, user input with its
+// images, output reservation and safety margin are fixed; documents, working memory, long-term
+// memory and history share what remains.
+//
+// Each flexible section first receives its weighted share of the remaining tokens, capped at
+// its demand. Tokens left over are then handed out in priority order, including to sections
+// weighted 0, so when the window is too small the lowest-priority sections are the ones cut
+// back. The result depends only on the request and configuration.
+func (p *Planner) Plan(req models.TokenBudgetRequest) *models.TokenBudgetPlan {
+	window := req.ContextWindow
+	if window <= 0 {
+		window = defaultContextWindow
+	}
+
+	output := req.OutputTokens
+	if p.config.OutputReserve > 0 {
+		output = p.config.OutputReserve
+	}
+	if output <= 0 {
+		output = window / 4
+	}
+
+	plan := &models.TokenBudgetPlan{
+		ContextWindow: window,
+		OutputReserve: output,
+		SafetyMargin:  p.config.SafetyMargin,
+		SystemPrompt:  req.SystemPromptTokens + req.ToolTokens,
+		Input:         req.InputTokens + req.ImageTokens,
+		Priorities:    append([]string(nil), p.config.Priorities...),
+	}
+
+	available := window - output - plan.SafetyMargin - plan.SystemPrompt - plan.Input
+	if available < 0 {
+		// Give up response room before reporting an overflow, but keep a minimum reservation
+		minOutput := p.config.MinOutputReserve
+		if minOutput > output {
+			minOutput = output
+		}
+		plan.OutputReserve = output + available
+		if plan.OutputReserve < minOutput {
+			plan.OutputReserve = minOutput
+		}
+		available = window - plan.OutputReserve - plan.SafetyMargin - plan.SystemPrompt - plan.Input
+		if available < 0 {
+			plan.Overflow = -available
+			available = 0
+		}
+		if plan.OutputReserve < output {
+			plan.Trimmed = append(plan.Trimmed, models.BudgetSectionOutput)
+		}
+	}
+
+	demands := map[string]int{
+		models.BudgetSectionDocuments:      max(req.DocumentDemand, 0),
+		models.BudgetSectionLongTermMemory: max(req.LongTermMemoryDemand, 0),
+		models.BudgetSectionHistory:        max(req.HistoryDemand, 0),
+		models.BudgetSectionWorkingMemory:  max(req.WorkingMemoryDemand, 0),
+	}
+	if p.config.MaxLongTermMemoryTokens > 0 && demands[models.BudgetSectionLongTermMemory] > p.config.MaxLongTermMemoryTokens {
+		demands[models.BudgetSectionLongTermMemory] = p.config.MaxLongTermMemoryTokens
+	}
+	weights := map[string]float64{
+		models.BudgetSectionDocuments:      p.config.DocumentWeight,
+		models.BudgetSectionLongTermMemory: p.config.LongTermMemoryWeight,
+		models.BudgetSectionHistory:        p.config.HistoryWeight,
+		models.BudgetSectionWorkingMemory:  p.config.WorkingMemoryWeight,
+	}
+
+	var totalWeight float64
+	for section, demand := range demands {
+		if demand > 0 && weights[section] > 0 {
+			totalWeight += weights[section]
+		}
+	}
+
+	allocations := make(map[string]int, len(demands))
+	remaining := available
+
+	// Pass 1: weighted shares, capped at demand
+	if totalWeight > 0 {
+		for _, section := range p.config.Priorities {
+			if demands[section] == 0 || weights[section] <= 0 {
+				continue
+			}
+			share := int(float64(available) * weights[section] / totalWeight)
+			if share > demands[section] {
+				share = demands[section]
+			}
+			allocations[section] = share
+			remaining -= share
+		}
+	}
+
+	// Pass 2: leftovers in priority order
+	for _, section := range p.config.Priorities {
+		if remaining <= 0 {
+			break
+		}
+		extra := demands[section] - allocations[section]
+		if extra > remaining {
+			extra = remaining
+		}
+		if extra > 0 {
+			allocations[section] += extra
+			remaining -= extra
+		}
+	}
+
+	plan.Documents = allocations[models.BudgetSectionDocuments]
+	plan.LongTermMemory = allocations[models.BudgetSectionLongTermMemory]
+	plan.History = allocations[models.BudgetSectionHistory]
+	plan.WorkingMemory = allocations[models.BudgetSectionWorkingMemory]
+	plan.Unallocated = remaining
+
+	for _, section := range p.config.Priorities {
+		if allocations[section] < demands[section] {
+			plan.Trimmed = append(plan.Trimmed, section)
+		}
+	}
+
+	return plan
+}
+
+// TrimHistory drops the oldest messages until the history fits the budget.
+// It returns the kept messages and the number dropped.
+func TrimHistory(history []models.Message, budget int, tokenEstimator func(string) int) ([]models.Message, int) {
+	total := 0
+	for _, msg := range history {
+		total += tokenEstimator(msg.Content)
+	}
+
+	start := 0
+	for total > budget && start < len(history) {
+		total -= tokenEstimator(history[start].Content)
+		start++
+	}
+
+	return history[start:], start
+}
+
+// normalizePriorities keeps known flexible sections in the given order, drops duplicates
+// and appends any missing sections in default order
+func normalizePriorities(priorities []string, defaults []string) []string {
+	known := map[string]bool{
+		models.BudgetSectionDocuments:      true,
+		models.BudgetSectionLongTermMemory: true,
+		models.BudgetSectionHistory:        true,
+		models.BudgetSectionWorkingMemory:  true,
+	}
+
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(known))
+	for _, section := range append(append([]string(nil), priorities...), defaults...) {
+		if known[section] && !seen[section] {
+			seen[section] = true
+			result = append(result, section)
+		}
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+package budget
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tas-agent-builder/models"
+)
+
+func TestPlanner_Plan(t *testing.T) {
+	t.Run("everything fits within the window", func(t *testing.T) {
+		planner := NewPlanner(nil)
+		plan := planner.Plan(models.TokenBudgetRequest{
+			ContextWindow:        128000,
+			OutputTokens:         4096,
+			SystemPromptTokens:   500,
+			InputTokens:          100,
+			DocumentDemand:       8000,
+			LongTermMemoryDemand: 1000,
+			HistoryDemand:        3000,
+		})
+
+		assert.Equal(t, 8000, plan.Documents)
+		assert.Equal(t, 1000, plan.LongTermMemory)
+		assert.Equal(t, 3000, plan.History)
+		assert.Equal(t, 4096, plan.OutputReserve)
+		assert.Empty(t, plan.Trimmed)
+		assert.Equal(t, 128000-4096-64-500-100-12000, plan.Unallocated)
+	})
+
+	t.Run("small window trims lowest priority first", func(t *testing.T) {
+		planner := NewPlanner(&models.TokenBudgetConfig{
+			DocumentWeight: 0.5,
+			HistoryWeight:  0.5,
+			Priorities:     []string{models.BudgetSectionHistory, models.BudgetSectionDocuments},
+		})
+		plan := planner.Plan(models.TokenBudgetRequest{
+			ContextWindow:      8192,
+			OutputTokens:       1024,
+			SystemPromptTokens: 1000,
+			InputTokens:        100,
+			DocumentDemand:     8000,
+			HistoryDemand:      2000,
+		})
+
+		available := 8192 - 1024 - 64 - 1000 - 100
+		assert.Equal(t, 2000, plan.History, "history fits inside its share")
+		assert.Equal(t, available-2000, plan.Documents, "documents take the leftover")
+		assert.Equal(t, 0, plan.Unallocated)
+		assert.Equal(t, []string{models.BudgetSectionDocuments}, plan.Trimmed)
+	})
+
+	t.Run("leftovers follow priority order", func(t *testing.T) {
+		planner := NewPlanner(&models.TokenBudgetConfig{
+			DocumentWeight:       1,
+			LongTermMemoryWeight: 1,
+			HistoryWeight:        1,
+			Priorities:           []string{models.BudgetSectionLongTermMemory, models.BudgetSectionDocuments},
+		})
+		req := models.TokenBudgetRequest{
+			ContextWindow:        2000,
+			OutputTokens:         1000,
+			DocumentDemand:       5000,
+			LongTermMemoryDemand: 5000,
+			HistoryDemand:        100,
+		}
+		plan := planner.Plan(req)
+
+		share := (2000 - 1000 - 64) / 3
+		assert.Equal(t, 100, plan.History)
+		assert.Equal(t, share, plan.Documents)
+		assert.Equal(t, 2000-1000-64-100-share, plan.LongTermMemory, "unused history share goes to the top priority")
+		assert.Equal(t, []string{models.BudgetSectionLongTermMemory, models.BudgetSectionDocuments, models.BudgetSectionHistory, models.BudgetSectionWorkingMemory}, plan.Priorities)
+
+		// Deterministic for identical input
+		assert.Equal(t, plan, planner.Plan(req))
+	})
+
+	t.Run("oversized prompt shrinks output and reports overflow", func(t *testing.T) {
+		planner := NewPlanner(nil)
+		plan := planner.Plan(models.TokenBudgetRequest{
+			ContextWindow:      4096,
+			OutputTokens:       2048,
+			SystemPromptTokens: 4000,
+			InputTokens:        100,
+			HistoryDemand:      500,
+		})
+
+		assert.Equal(t, 256, plan.OutputReserve)
+		assert.Equal(t, 4000+100+64+256-4096, plan.Overflow)
+		assert.Equal(t, 0, plan.History)
+		assert.Contains(t, plan.Trimmed, models.BudgetSectionOutput)
+		assert.Contains(t, plan.Trimmed, models.BudgetSectionHistory)
+	})
+
+	t.Run("tool hint and images count against the window", func(t *testing.T) {
+		req := models.TokenBudgetRequest{
+			ContextWindow:      8192,
+			OutputTokens:       1024,
+			SystemPromptTokens: 3000,
+			InputTokens:        2000,
+			DocumentDemand:     2000,
+		}
+		plan := NewPlanner(nil).Plan(req)
+		assert.Zero(t, plan.Overflow)
+		assert.Equal(t, 1024, plan.OutputReserve)
+		assert.Equal(t, 2000, plan.Documents)
+
+		// Tools and two images leave no room for documents and overflow the window
+		req.ToolTokens = 400
+		req.ImageTokens = 2 * 1600
+		plan = NewPlanner(nil).Plan(req)
+		assert.Equal(t, 3400, plan.SystemPrompt)
+		assert.Equal(t, 5200, plan.Input)
+		assert.Equal(t, 0, plan.Documents)
+		assert.Equal(t, 256, plan.OutputReserve)
+		assert.Equal(t, 3400+5200+64+256-8192, plan.Overflow)
+		assert.Contains(t, plan.Trimmed, models.BudgetSectionDocuments)
+	})
+
+	t.Run("sections weighted 0 receive leftovers", func(t *testing.T) {
+		plan := NewPlanner(&models.TokenBudgetConfig{DocumentWeight: 1, HistoryWeight: 0}).Plan(models.TokenBudgetRequest{
+			ContextWindow:  8192,
+			OutputTokens:   1024,
+			DocumentDemand: 1000,
+			HistoryDemand:  3000,
+		})
+
+		assert.Equal(t, 1000, plan.Documents)
+		assert.Equal(t, 3000, plan.History, "history has no share but takes what documents left")
+		assert.NotContains(t, plan.Trimmed, models.BudgetSectionHistory)
+	})
+
+	t.Run("working memory gets a share by default", func(t *testing.T) {
+		plan := NewPlanner(&models.TokenBudgetConfig{DocumentWeight: 1}).Plan(models.TokenBudgetRequest{
+			ContextWindow:       8192,
+			OutputTokens:        1024,
+			DocumentDemand:      20000,
+			WorkingMemoryDemand: 500,
+		})
+
+		assert.Equal(t, 500, plan.WorkingMemory, "configs without a working memory weight still include it")
+		assert.Equal(t, 8192-1024-64-500, plan.Documents)
+	})
+
+	t.Run("unknown window falls back to default", func(t *testing.T) {
+		plan := NewPlanner(nil).Plan(models.TokenBudgetRequest{})
+		assert.Equal(t, defaultContextWindow, plan.ContextWindow)
+		assert.Equal(t, defaultContextWindow/4, plan.OutputReserve)
+	})
+}
+
+func TestTrimHistory(t *testing.T) {
+	estimator := func(text string) int { return len(text) }
+	history := []models.Message{
+		{Role: "user", Content: "aaaa"},
+		{Role: "assistant", Content: "bbbb"},
+		{Role: "user", Content: "cccc"},
+	}
+
+	kept, dropped := TrimHistory(history, 8, estimator)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, history[1:], kept)
+
+	kept, dropped = TrimHistory(history, 0, estimator)
+	assert.Equal(t, 3, dropped)
+	assert.Empty(t, kept)
+}
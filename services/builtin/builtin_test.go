@@ -0,0 +1,156 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := map[string]float64{
+		"1 + 2 * 3":                7,
+		"(1 + 2) * 3":              9,
+		"2 ^ 3 ^ 2":                512,
+		"-2 ^ 2":                   -4,
+		"10 % 4":                   2,
+		"sqrt(16) + abs(-3)":       7,
+		"max(1, 5, 3) - min(4, 2)": 3,
+		"1.5e3 / 3":                500,
+		"round(pi * 100)":          314,
+		"1_000 * 2":                2000,
+	}
+	for expr, want := range cases {
+		got, err := Evaluate(expr)
+		require.NoError(t, err, expr)
+		assert.InDelta(t, want, got, 1e-9, expr)
+	}
+
+	for _, bad := range []string{"1 / 0", "2 +", "(1 + 2", "foo(1)", "x", "1; rm -rf /", "sqrt(-1)"} {
+		_, err := Evaluate(bad)
+		assert.Error(t, err, bad)
+	}
+	assert.Equal(t, "0.3", formatNumber(0.1+0.2))
+}
+
+func TestRegistryCallValidatesArguments(t *testing.T) {
+	r := Default()
+	ctx := context.Background()
+
+	out, err := r.Call(ctx, "calculator", "calculate", map[string]interface{}{"expression": "6 * 7"})
+	require.NoError(t, err)
+	assert.Equal(t, "42", out)
+
+	_, err = r.Call(ctx, "calculator", "calculate", map[string]interface{}{})
+	assert.ErrorContains(t, err, "/expression: is required")
+	_, err = r.Call(ctx, "calculator", "calculate", map[string]interface{}{"expression": 42.0})
+	assert.ErrorContains(t, err, "must be a string")
+	_, err = r.Call(ctx, "calculator", "calculate", map[string]interface{}{"expression": "1", "shell": "ls"})
+	assert.ErrorContains(t, err, "/shell: is not an allowed property")
+	_, err = r.Call(ctx, "regex", "regex_extract", map[string]interface{}{"text": "a", "pattern": "a", "max_matches": 1.5})
+	assert.ErrorContains(t, err, "must be an integer")
+	_, err = r.Call(ctx, "calculator", "nope", nil)
+	assert.Error(t, err)
+
+	// Every builtin schema rejects arguments it does not declare, for the model as well
+	for _, skill := range r.Skills() {
+		for _, tool := range skill.Tools {
+			assert.Equal(t, false, tool.Parameters["additionalProperties"], tool.Name)
+		}
+	}
+}
+
+func TestRegistryCallTimesOut(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Skill{Name: "slow", Tools: []Tool{{
+		Name:       "wait",
+		Parameters: map[string]interface{}{"type": "object"},
+		Timeout:    20 * time.Millisecond,
+		Run: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			time.Sleep(time.Second)
+			return "late", nil
+		},
+	}}})
+
+	start := time.Now()
+	_, err := r.Call(context.Background(), "slow", "wait", nil)
+	assert.ErrorContains(t, err, "did not finish")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestDateTimeTools(t *testing.T) {
+	now = func() time.Time { return time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	r := Default()
+	ctx := context.Background()
+
+	decode := func(s string) map[string]interface{} {
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(s), &m))
+		return m
+	}
+
+	out, err := r.Call(ctx, "datetime", "current_time", map[string]interface{}{"timezone": "Europe/Berlin"})
+	require.NoError(t, err)
+	current := decode(out)
+	assert.Equal(t, "2024-03-31T03:30:00+02:00", current["time"], "DST started that night")
+	assert.Equal(t, "Sunday", current["weekday"])
+
+	out, err = r.Call(ctx, "datetime", "convert_time", map[string]interface{}{
+		"time": "2024-07-01 09:00", "from_timezone": "America/New_York", "to_timezone": "Asia/Tokyo",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-07-01T22:00:00+09:00", decode(out)["time"])
+
+	out, err = r.Call(ctx, "datetime", "date_add", map[string]interface{}{"time": "2024-01-31", "months": 1.0})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02-29", decode(out)["date"], "clamped to the end of February")
+
+	out, err = r.Call(ctx, "datetime", "date_diff", map[string]interface{}{"start": "2023-01-15", "end": "2024-03-20"})
+	require.NoError(t, err)
+	diff := decode(out)
+	assert.Equal(t, "1 years, 2 months, 5 days", diff["calendar"])
+	assert.Equal(t, 430.0, diff["total_days"])
+
+	_, err = r.Call(ctx, "datetime", "current_time", map[string]interface{}{"timezone": "Mars/Olympus"})
+	assert.ErrorContains(t, err, "unknown time zone")
+}
+
+func TestDataTools(t *testing.T) {
+	r := Default()
+	ctx := context.Background()
+
+	out, err := r.Call(ctx, "json_query", "json_query", map[string]interface{}{
+		"json": `{"items":[{"name":"a","n":1},{"name":"b","n":2}]}`,
+		"path": "$.items[*].name",
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["a","b"]`, out)
+
+	v, err := ConvertUnits(100, "°F", "celsius")
+	require.NoError(t, err)
+	assert.InDelta(t, 37.7778, v, 1e-4)
+	v, err = ConvertUnits(5, "miles", "km")
+	require.NoError(t, err)
+	assert.InDelta(t, 8.04672, v, 1e-9)
+	_, err = ConvertUnits(1, "kg", "m")
+	assert.ErrorContains(t, err, "cannot convert")
+
+	out, err = r.Call(ctx, "regex", "regex_extract", map[string]interface{}{
+		"text":    "Order 17 shipped, order 23 pending",
+		"pattern": `order (?P<id>\d+) (\w+)`,
+		"flags":   "i",
+	})
+	require.NoError(t, err)
+	var res struct {
+		Count   int
+		Matches []regexMatch
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+	require.Equal(t, 2, res.Count)
+	assert.Equal(t, "17", res.Matches[0].Named["id"])
+	assert.Equal(t, []string{"23", "pending"}, res.Matches[1].Groups)
+}
@@ -0,0 +1,316 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxExpressionLength = 1000
+	maxExpressionDepth  = 64
+)
+
+func calculatorSkill() Skill {
+	return Skill{
+		Name:        "calculator",
+		DisplayName: "Calculator",
+		Description: "Evaluate arithmetic expressions exactly instead of estimating them",
+		Icon:        "Calculator",
+		Tags:        []string{"math", "calculator", "builtin"},
+		Keywords:    []string{"calculate", "math", "arithmetic", "sum", "percent", "multiply", "divide", "sqrt"},
+		Tools: []Tool{{
+			Name: "calculate",
+			Description: "Evaluate an arithmetic expression. Supports + - * / % ^, parentheses, " +
+				"the constants pi and e, and the functions sqrt, abs, round, floor, ceil, exp, ln, log10, log2, " +
+				"sin, cos, tan, asin, acos, atan, min, max and pow.",
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"expression": map[string]interface{}{
+						"type":        "string",
+						"description": "The expression, e.g. (12.5 * 4) / sqrt(16)",
+						"maxLength":   maxExpressionLength,
+					},
+				},
+				"required": []string{"expression"},
+			},
+			Run: func(ctx context.Context, args map[string]interface{}) (string, error) {
+				v, err := Evaluate(args["expression"].(string))
+				if err != nil {
+					return "", err
+				}
+				return formatNumber(v), nil
+			},
+		}},
+	}
+}
+
+// Evaluate computes an arithmetic expression. It is a recursive descent parser over a fixed
+// grammar, so no input can run code or take more than linear time.
+func Evaluate(expr string) (float64, error) {
+	if len(expr) > maxExpressionLength {
+		return 0, fmt.Errorf("expression is longer than %d characters", maxExpressionLength)
+	}
+	p := &exprParser{src: expr}
+	v, err := p.expression(0)
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.src[p.pos], p.pos+1)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return v, nil
+}
+
+func formatNumber(v float64) string {
+	if v == 0 {
+		return "0" // Avoid "-0"
+	}
+	return strconv.FormatFloat(v, 'g', 15, 64)
+}
+
+type exprParser struct {
+	src string
+	pos int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// expression := term (('+' | '-') term)*
+func (p *exprParser) expression(depth int) (float64, error) {
+	if depth > maxExpressionDepth {
+		return 0, fmt.Errorf("expression is nested too deeply")
+	}
+	v, err := p.term(depth)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			r, err := p.term(depth)
+			if err != nil {
+				return 0, err
+			}
+			v += r
+		case '-':
+			p.pos++
+			r, err := p.term(depth)
+			if err != nil {
+				return 0, err
+			}
+			v -= r
+		default:
+			return v, nil
+		}
+	}
+}
+
+// term := unary (('*' | '/' | '%') unary)*
+func (p *exprParser) term(depth int) (float64, error) {
+	v, err := p.unary(depth)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return v, nil
+		}
+		p.pos++
+		r, err := p.unary(depth)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			v *= r
+		case '/':
+			if r == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			v /= r
+		case '%':
+			if r == 0 {
+				return 0, fmt.Errorf("modulo by zero")
+			}
+			v = math.Mod(v, r)
+		}
+	}
+}
+
+// unary := ('-' | '+') unary | power
+func (p *exprParser) unary(depth int) (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.unary(depth + 1)
+		return -v, err
+	case '+':
+		p.pos++
+		return p.unary(depth + 1)
+	}
+	return p.power(depth)
+}
+
+// power := primary ('^' unary)?, right associative so 2^3^2 is 2^9
+func (p *exprParser) power(depth int) (float64, error) {
+	base, err := p.primary(depth)
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	exp, err := p.unary(depth + 1)
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exp), nil
+}
+
+// primary := number | constant | function '(' args ')' | '(' expression ')'
+func (p *exprParser) primary(depth int) (float64, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		v, err := p.expression(depth + 1)
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing ')' at position %d", p.pos+1)
+		}
+		p.pos++
+		return v, nil
+	case c >= '0' && c <= '9' || c == '.':
+		return p.number()
+	case unicode.IsLetter(rune(c)):
+		return p.identifier(depth)
+	case c == 0:
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
+
+func (p *exprParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.' || p.src[p.pos] == '_') {
+		p.pos++
+	}
+	// Exponent, as in 1.5e3
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		next := p.pos + 1
+		if next < len(p.src) && (p.src[next] == '+' || p.src[next] == '-') {
+			next++
+		}
+		if next < len(p.src) && p.src[next] >= '0' && p.src[next] <= '9' {
+			p.pos = next
+			for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+				p.pos++
+			}
+		}
+	}
+	v, err := strconv.ParseFloat(strings.ReplaceAll(p.src[start:p.pos], "_", ""), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+	}
+	return v, nil
+}
+
+var (
+	constants = map[string]float64{"pi": math.Pi, "e": math.E}
+
+	unaryFuncs = map[string]func(float64) float64{
+		"sqrt": math.Sqrt, "abs": math.Abs, "round": math.Round, "floor": math.Floor, "ceil": math.Ceil,
+		"exp": math.Exp, "ln": math.Log, "log10": math.Log10, "log2": math.Log2,
+		"sin": math.Sin, "cos": math.Cos, "tan": math.Tan, "asin": math.Asin, "acos": math.Acos, "atan": math.Atan,
+	}
+)
+
+func (p *exprParser) identifier(depth int) (float64, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+		p.pos++
+	}
+	name := strings.ToLower(p.src[start:p.pos])
+
+	if p.peek() != '(' {
+		if v, ok := constants[name]; ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+	p.pos++
+
+	var args []float64
+	if p.peek() != ')' {
+		for {
+			v, err := p.expression(depth + 1)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.peek() != ')' {
+		return 0, fmt.Errorf("missing ')' after arguments of %s", name)
+	}
+	p.pos++
+
+	if fn, ok := unaryFuncs[name]; ok {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("%s takes 1 argument", name)
+		}
+		return fn(args[0]), nil
+	}
+	switch name {
+	case "pow":
+		if len(args) != 2 {
+			return 0, fmt.Errorf("pow takes 2 arguments")
+		}
+		return math.Pow(args[0], args[1]), nil
+	case "min", "max":
+		if len(args) == 0 {
+			return 0, fmt.Errorf("%s takes at least 1 argument", name)
+		}
+		v := args[0]
+		for _, a := range args[1:] {
+			if name == "min" {
+				v = math.Min(v, a)
+			} else {
+				v = math.Max(v, a)
+			}
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown function %q", name)
+}
@@ -0,0 +1,287 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// now is replaced in tests
+var now = time.Now
+
+// timeLayouts are the accepted input formats; those without an offset are read in the
+// request's time zone
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func timezoneParam(desc string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": desc,
+		"maxLength":   64,
+	}
+}
+
+func timeParam(desc string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"description": desc + " (RFC 3339, or YYYY-MM-DD with an optional HH:MM[:SS])",
+		"maxLength":   64,
+	}
+}
+
+func dateTimeSkill() Skill {
+	return Skill{
+		Name:        "datetime",
+		DisplayName: "Date & Time",
+		Description: "Current date and time, time zone conversion and date arithmetic",
+		Icon:        "Clock",
+		Tags:        []string{"time", "date", "timezone", "builtin"},
+		Keywords:    []string{"time", "date", "today", "now", "timezone", "schedule", "calendar", "deadline", "days"},
+		Tools: []Tool{
+			{
+				Name:        "current_time",
+				Description: "Get the current date and time in a time zone",
+				Parameters: map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"timezone": timezoneParam("IANA time zone such as Europe/Berlin; defaults to UTC"),
+					},
+				},
+				Run: runCurrentTime,
+			},
+			{
+				Name:        "convert_time",
+				Description: "Convert a date and time from one time zone to another",
+				Parameters: map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"time":          timeParam("The time to convert"),
+						"from_timezone": timezoneParam("IANA time zone of a time without an offset; defaults to UTC"),
+						"to_timezone":   timezoneParam("IANA time zone to convert to"),
+					},
+					"required": []string{"time", "to_timezone"},
+				},
+				Run: runConvertTime,
+			},
+			{
+				Name:        "date_add",
+				Description: "Add (or with negative values, subtract) calendar units to a date and time",
+				Parameters: map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"time":     timeParam("The starting time; defaults to now"),
+						"timezone": timezoneParam("IANA time zone for the calculation; defaults to UTC"),
+						"years":    intParam("Years to add"),
+						"months":   intParam("Months to add"),
+						"days":     intParam("Days to add"),
+						"hours":    intParam("Hours to add"),
+						"minutes":  intParam("Minutes to add"),
+					},
+				},
+				Run: runDateAdd,
+			},
+			{
+				Name:        "date_diff",
+				Description: "Compute the time between two dates",
+				Parameters: map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"start":    timeParam("The earlier time"),
+						"end":      timeParam("The later time; defaults to now"),
+						"timezone": timezoneParam("IANA time zone of times without an offset; defaults to UTC"),
+					},
+					"required": []string{"start"},
+				},
+				Run: runDateDiff,
+			},
+		},
+	}
+}
+
+func intParam(desc string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "integer",
+		"description": desc,
+		"minimum":     -100000,
+		"maximum":     100000,
+	}
+}
+
+func loadZone(args map[string]interface{}, key string) (*time.Location, error) {
+	name, _ := args[key].(string)
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse time %q", s)
+}
+
+// describeTime renders a time with the details models otherwise get wrong
+func describeTime(t time.Time) string {
+	zone, offset := t.Zone()
+	out, _ := json.Marshal(map[string]interface{}{
+		"time":          t.Format(time.RFC3339),
+		"date":          t.Format("2006-01-02"),
+		"weekday":       t.Weekday().String(),
+		"timezone":      t.Location().String(),
+		"abbreviation":  zone,
+		"utc_offset":    fmt.Sprintf("%+03d:%02d", offset/3600, abs(offset%3600)/60),
+		"unix":          t.Unix(),
+		"iso_week":      isoWeek(t),
+		"is_dst":        t.IsDST(),
+		"day_of_year":   t.YearDay(),
+		"days_in_month": time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day(),
+	})
+	return string(out)
+}
+
+func isoWeek(t time.Time) int {
+	_, week := t.ISOWeek()
+	return week
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func runCurrentTime(ctx context.Context, args map[string]interface{}) (string, error) {
+	loc, err := loadZone(args, "timezone")
+	if err != nil {
+		return "", err
+	}
+	return describeTime(now().In(loc)), nil
+}
+
+func runConvertTime(ctx context.Context, args map[string]interface{}) (string, error) {
+	from, err := loadZone(args, "from_timezone")
+	if err != nil {
+		return "", err
+	}
+	to, err := loadZone(args, "to_timezone")
+	if err != nil {
+		return "", err
+	}
+	t, err := parseTime(args["time"].(string), from)
+	if err != nil {
+		return "", err
+	}
+	return describeTime(t.In(to)), nil
+}
+
+func runDateAdd(ctx context.Context, args map[string]interface{}) (string, error) {
+	loc, err := loadZone(args, "timezone")
+	if err != nil {
+		return "", err
+	}
+	t := now().In(loc)
+	if s, ok := args["time"].(string); ok {
+		if t, err = parseTime(s, loc); err != nil {
+			return "", err
+		}
+	}
+
+	unit := func(key string) int {
+		v, _ := args[key].(float64)
+		return int(v)
+	}
+	t = addMonths(t, unit("years")*12+unit("months")).AddDate(0, 0, unit("days"))
+	t = t.Add(time.Duration(unit("hours"))*time.Hour + time.Duration(unit("minutes"))*time.Minute)
+	return describeTime(t), nil
+}
+
+// addMonths adds calendar months, clamping to the end of shorter months the way people expect
+// (Jan 31 plus one month is Feb 29 in a leap year, not Mar 2)
+func addMonths(t time.Time, months int) time.Time {
+	if months == 0 {
+		return t
+	}
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	target := first.AddDate(0, months, 0)
+	lastDay := time.Date(target.Year(), target.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return target.AddDate(0, 0, day-1)
+}
+
+func runDateDiff(ctx context.Context, args map[string]interface{}) (string, error) {
+	loc, err := loadZone(args, "timezone")
+	if err != nil {
+		return "", err
+	}
+	start, err := parseTime(args["start"].(string), loc)
+	if err != nil {
+		return "", err
+	}
+	end := now().In(loc)
+	if s, ok := args["end"].(string); ok {
+		if end, err = parseTime(s, loc); err != nil {
+			return "", err
+		}
+	}
+
+	d := end.Sub(start)
+	out, _ := json.Marshal(map[string]interface{}{
+		"start":         start.Format(time.RFC3339),
+		"end":           end.Format(time.RFC3339),
+		"total_days":    math.Round(d.Hours()/24*100) / 100,
+		"total_hours":   math.Round(d.Hours()*100) / 100,
+		"total_minutes": int64(d.Minutes()),
+		"total_seconds": int64(d.Seconds()),
+		"calendar":      calendarDiff(start, end),
+	})
+	return string(out), nil
+}
+
+// calendarDiff splits the time between two instants into whole years, months and days the way
+// people count them, as "Y years, M months, D days"
+func calendarDiff(start, end time.Time) string {
+	sign := ""
+	if end.Before(start) {
+		start, end = end, start
+		sign = "-"
+	}
+	end = end.In(start.Location())
+
+	years := end.Year() - start.Year()
+	months := int(end.Month()) - int(start.Month())
+	days := end.Day() - start.Day()
+	if days < 0 {
+		months--
+		days += time.Date(end.Year(), end.Month(), 0, 0, 0, 0, 0, end.Location()).Day()
+	}
+	if months < 0 {
+		years--
+		months += 12
+	}
+	return fmt.Sprintf("%s%d years, %d months, %d days", sign, years, months, days)
+}
@@ -0,0 +1,66 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tas-agent-builder/services/jsonpath"
+)
+
+// maxJSONInput bounds the document a JSON query runs over
+const maxJSONInput = 1 << 20
+
+func jsonQuerySkill() Skill {
+	return Skill{
+		Name:        "json_query",
+		DisplayName: "JSON Query",
+		Description: "Select values from JSON documents with JSONPath",
+		Icon:        "Braces",
+		Tags:        []string{"json", "data", "builtin"},
+		Keywords:    []string{"json", "jsonpath", "extract", "field", "query", "filter", "select"},
+		Tools: []Tool{{
+			Name: "json_query",
+			Description: "Select values from a JSON document with a JSONPath expression such as $.items[*].name. " +
+				"Supports .name, ['name'], [index], [-1], [start:end], * and recursive descent (..name). " +
+				"Returns the matches as a JSON array.",
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"json": map[string]interface{}{
+						"type":        "string",
+						"description": "The JSON document",
+						"maxLength":   maxJSONInput,
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "The JSONPath expression",
+						"maxLength":   500,
+					},
+				},
+				"required": []string{"json", "path"},
+			},
+			Run: runJSONQuery,
+		}},
+	}
+}
+
+func runJSONQuery(ctx context.Context, args map[string]interface{}) (string, error) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(args["json"].(string)), &doc); err != nil {
+		return "", fmt.Errorf("json is not valid JSON: %w", err)
+	}
+	matches, err := jsonpath.Query(doc, args["path"].(string))
+	if err != nil {
+		return "", err
+	}
+	if matches == nil {
+		matches = []interface{}{}
+	}
+	out, err := json.Marshal(matches)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode matches: %w", err)
+	}
+	return string(out), nil
+}
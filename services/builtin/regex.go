@@ -0,0 +1,106 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxRegexText    = 1 << 20
+	maxRegexPattern = 1000
+	maxRegexMatches = 1000
+)
+
+func regexSkill() Skill {
+	return Skill{
+		Name:        "regex",
+		DisplayName: "Regex Extraction",
+		Description: "Extract text matching regular expressions",
+		Icon:        "Regex",
+		Tags:        []string{"regex", "text", "builtin"},
+		Keywords:    []string{"regex", "regular expression", "extract", "match", "pattern", "find"},
+		Tools: []Tool{{
+			Name: "regex_extract",
+			Description: "Find the matches of a regular expression (RE2 syntax, no backreferences or lookaround) in a text. " +
+				"Returns each match with its capture groups.",
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"text":    map[string]interface{}{"type": "string", "description": "The text to search", "maxLength": maxRegexText},
+					"pattern": map[string]interface{}{"type": "string", "description": "The regular expression", "maxLength": maxRegexPattern},
+					"flags": map[string]interface{}{
+						"type":        "string",
+						"description": "Any of i (ignore case), m (^ and $ match at line breaks) and s (. matches newlines)",
+						"maxLength":   3,
+					},
+					"max_matches": map[string]interface{}{
+						"type":        "integer",
+						"description": "Most matches to return; defaults to 100",
+						"minimum":     1,
+						"maximum":     maxRegexMatches,
+					},
+				},
+				"required": []string{"text", "pattern"},
+			},
+			Run: runRegexExtract,
+		}},
+	}
+}
+
+type regexMatch struct {
+	Match  string            `json:"match"`
+	Index  int               `json:"index"`
+	Groups []string          `json:"groups,omitempty"`
+	Named  map[string]string `json:"named,omitempty"`
+}
+
+func runRegexExtract(ctx context.Context, args map[string]interface{}) (string, error) {
+	pattern := args["pattern"].(string)
+	if flags, _ := args["flags"].(string); flags != "" {
+		if strings.Trim(flags, "ims") != "" {
+			return "", fmt.Errorf("flags may only contain i, m and s")
+		}
+		pattern = "(?" + flags + ")" + pattern
+	}
+	// RE2 matches in linear time, so no pattern can backtrack catastrophically
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	limit := 100
+	if n, ok := args["max_matches"].(float64); ok {
+		limit = int(n)
+	}
+
+	text := args["text"].(string)
+	names := re.SubexpNames()
+	matches := make([]regexMatch, 0)
+	for _, loc := range re.FindAllStringSubmatchIndex(text, limit) {
+		m := regexMatch{Match: text[loc[0]:loc[1]], Index: loc[0]}
+		for g := 1; g < len(names); g++ {
+			var value string
+			if loc[2*g] >= 0 {
+				value = text[loc[2*g]:loc[2*g+1]]
+			}
+			m.Groups = append(m.Groups, value)
+			if names[g] != "" {
+				if m.Named == nil {
+					m.Named = make(map[string]string)
+				}
+				m.Named[names[g]] = value
+			}
+		}
+		matches = append(matches, m)
+	}
+
+	out, err := json.Marshal(map[string]interface{}{"count": len(matches), "matches": matches})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode matches: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,153 @@
+// Package builtin provides the tools of builtin skills, which run inside the process instead
+// of on an MCP server or webhook. Arguments are validated against each tool's schema before it
+// runs, and every call is bounded by a time limit.
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Time zone lookups must not depend on the container's zoneinfo
+
+	"github.com/tas-agent-builder/services/jsonschema"
+)
+
+// DefaultTimeout bounds a builtin tool call that sets no timeout of its own
+const DefaultTimeout = 5 * time.Second
+
+// Tool is one builtin tool
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON Schema the arguments are validated against
+	Timeout     time.Duration          // Zero uses DefaultTimeout
+	Run         func(ctx context.Context, args map[string]interface{}) (string, error)
+}
+
+// Skill groups builtin tools under the skill agents are given
+type Skill struct {
+	Name        string
+	DisplayName string
+	Description string
+	Icon        string
+	Tags        []string
+	Keywords    []string
+	Tools       []Tool
+}
+
+// Registry holds the builtin skills available to agents
+type Registry struct {
+	mu     sync.RWMutex
+	skills map[string]*Skill
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{skills: make(map[string]*Skill)}
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+)
+
+// Default returns the registry of the platform's builtin skills
+func Default() *Registry {
+	defaultOnce.Do(func() {
+		defaultRegistry = NewRegistry()
+		defaultRegistry.Register(calculatorSkill())
+		defaultRegistry.Register(dateTimeSkill())
+		defaultRegistry.Register(jsonQuerySkill())
+		defaultRegistry.Register(unitConversionSkill())
+		defaultRegistry.Register(regexSkill())
+	})
+	return defaultRegistry
+}
+
+// Register adds a skill, replacing any of the same name
+func (r *Registry) Register(skill Skill) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skills[skill.Name] = &skill
+}
+
+// Skill returns the named skill
+func (r *Registry) Skill(name string) (*Skill, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	skill, ok := r.skills[name]
+	return skill, ok
+}
+
+// Skills returns every registered skill, sorted by name
+func (r *Registry) Skills() []Skill {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Skill, 0, len(r.skills))
+	for _, skill := range r.skills {
+		out = append(out, *skill)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Call validates the arguments and runs a tool of the named skill within its time limit
+func (r *Registry) Call(ctx context.Context, skillName, toolName string, args map[string]interface{}) (string, error) {
+	skill, ok := r.Skill(skillName)
+	if !ok {
+		return "", fmt.Errorf("unknown builtin skill %q", skillName)
+	}
+	var tool *Tool
+	for i := range skill.Tools {
+		if skill.Tools[i].Name == toolName {
+			tool = &skill.Tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return "", fmt.Errorf("builtin skill %q has no tool %q", skillName, toolName)
+	}
+
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+	if violations := jsonschema.Validate(tool.Parameters, args); len(violations) > 0 {
+		messages := make([]string, len(violations))
+		for i, v := range violations {
+			messages[i] = v.String()
+		}
+		return "", fmt.Errorf("invalid arguments: %s", strings.Join(messages, "; "))
+	}
+
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("tool %s failed: %v", toolName, p)}
+			}
+		}()
+		out, err := tool.Run(ctx, args)
+		done <- result{out, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.out, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("tool %s did not finish within %s", toolName, timeout)
+	}
+}
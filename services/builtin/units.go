@@ -0,0 +1,170 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// unit converts to and from its category's base unit: base = value*factor + offset
+type unit struct {
+	category string
+	factor   float64
+	offset   float64
+}
+
+// units maps every accepted unit name and alias. Base units are metre, kilogram, litre,
+// second, square metre, metre per second, byte and kelvin.
+var units = map[string]unit{}
+
+func addUnit(category string, factor, offset float64, names ...string) {
+	for _, n := range names {
+		units[n] = unit{category: category, factor: factor, offset: offset}
+	}
+}
+
+func init() {
+	// Length
+	addUnit("length", 1e-3, 0, "mm", "millimeter", "millimetre", "millimeters", "millimetres")
+	addUnit("length", 1e-2, 0, "cm", "centimeter", "centimetre", "centimeters", "centimetres")
+	addUnit("length", 1, 0, "m", "meter", "metre", "meters", "metres")
+	addUnit("length", 1e3, 0, "km", "kilometer", "kilometre", "kilometers", "kilometres")
+	addUnit("length", 0.0254, 0, "in", "inch", "inches")
+	addUnit("length", 0.3048, 0, "ft", "foot", "feet")
+	addUnit("length", 0.9144, 0, "yd", "yard", "yards")
+	addUnit("length", 1609.344, 0, "mi", "mile", "miles")
+	addUnit("length", 1852, 0, "nmi", "nautical mile", "nautical miles")
+
+	// Mass
+	addUnit("mass", 1e-6, 0, "mg", "milligram", "milligrams")
+	addUnit("mass", 1e-3, 0, "g", "gram", "grams")
+	addUnit("mass", 1, 0, "kg", "kilogram", "kilograms")
+	addUnit("mass", 1e3, 0, "t", "tonne", "tonnes", "metric ton", "metric tons")
+	addUnit("mass", 0.028349523125, 0, "oz", "ounce", "ounces")
+	addUnit("mass", 0.45359237, 0, "lb", "lbs", "pound", "pounds")
+	addUnit("mass", 6.35029318, 0, "st", "stone", "stones")
+
+	// Volume
+	addUnit("volume", 1e-3, 0, "ml", "milliliter", "millilitre", "milliliters", "millilitres")
+	addUnit("volume", 1, 0, "l", "liter", "litre", "liters", "litres")
+	addUnit("volume", 1e3, 0, "m3", "cubic meter", "cubic metre", "cubic meters", "cubic metres")
+	addUnit("volume", 0.0295735295625, 0, "fl oz", "fluid ounce", "fluid ounces")
+	addUnit("volume", 0.2365882365, 0, "cup", "cups")
+	addUnit("volume", 0.473176473, 0, "pt", "pint", "pints")
+	addUnit("volume", 0.946352946, 0, "qt", "quart", "quarts")
+	addUnit("volume", 3.785411784, 0, "gal", "gallon", "gallons")
+
+	// Time
+	addUnit("time", 1e-3, 0, "ms", "millisecond", "milliseconds")
+	addUnit("time", 1, 0, "s", "sec", "second", "seconds")
+	addUnit("time", 60, 0, "min", "minute", "minutes")
+	addUnit("time", 3600, 0, "h", "hr", "hour", "hours")
+	addUnit("time", 86400, 0, "d", "day", "days")
+	addUnit("time", 604800, 0, "wk", "week", "weeks")
+
+	// Area
+	addUnit("area", 1e-4, 0, "cm2", "square centimeter", "square centimeters")
+	addUnit("area", 1, 0, "m2", "square meter", "square metre", "square meters", "square metres")
+	addUnit("area", 1e4, 0, "ha", "hectare", "hectares")
+	addUnit("area", 1e6, 0, "km2", "square kilometer", "square kilometre", "square kilometers", "square kilometres")
+	addUnit("area", 0.09290304, 0, "ft2", "sq ft", "square foot", "square feet")
+	addUnit("area", 4046.8564224, 0, "acre", "acres")
+	addUnit("area", 2589988.110336, 0, "mi2", "square mile", "square miles")
+
+	// Speed
+	addUnit("speed", 1, 0, "m/s", "meters per second", "metres per second")
+	addUnit("speed", 1/3.6, 0, "km/h", "kph", "kmh", "kilometers per hour", "kilometres per hour")
+	addUnit("speed", 0.44704, 0, "mph", "miles per hour")
+	addUnit("speed", 1852.0/3600, 0, "kn", "knot", "knots")
+
+	// Data
+	addUnit("data", 1, 0, "b", "byte", "bytes")
+	addUnit("data", 1e3, 0, "kb", "kilobyte", "kilobytes")
+	addUnit("data", 1e6, 0, "mb", "megabyte", "megabytes")
+	addUnit("data", 1e9, 0, "gb", "gigabyte", "gigabytes")
+	addUnit("data", 1e12, 0, "tb", "terabyte", "terabytes")
+	addUnit("data", 1<<10, 0, "kib", "kibibyte", "kibibytes")
+	addUnit("data", 1<<20, 0, "mib", "mebibyte", "mebibytes")
+	addUnit("data", 1<<30, 0, "gib", "gibibyte", "gibibytes")
+	addUnit("data", 1<<40, 0, "tib", "tebibyte", "tebibytes")
+
+	// Temperature
+	addUnit("temperature", 1, 0, "k", "kelvin")
+	addUnit("temperature", 1, 273.15, "c", "°c", "celsius")
+	addUnit("temperature", 5.0/9, 273.15-32*5.0/9, "f", "°f", "fahrenheit")
+}
+
+func unitConversionSkill() Skill {
+	categories := make(map[string]bool)
+	for _, u := range units {
+		categories[u.category] = true
+	}
+	names := make([]string, 0, len(categories))
+	for c := range categories {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+
+	return Skill{
+		Name:        "unit_conversion",
+		DisplayName: "Unit Conversion",
+		Description: "Convert between units of length, mass, volume, time, area, speed, data and temperature",
+		Icon:        "Ruler",
+		Tags:        []string{"units", "conversion", "builtin"},
+		Keywords:    []string{"convert", "units", "metric", "imperial", "miles", "kilometers", "pounds", "celsius", "fahrenheit"},
+		Tools: []Tool{{
+			Name: "convert_units",
+			Description: "Convert a value between units of the same kind (" + strings.Join(names, ", ") + "). " +
+				"Units are given by symbol or name, e.g. km, miles, lb, °F, GiB, km/h.",
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"value": map[string]interface{}{"type": "number", "description": "The value to convert"},
+					"from":  map[string]interface{}{"type": "string", "description": "The unit of the value", "maxLength": 32},
+					"to":    map[string]interface{}{"type": "string", "description": "The unit to convert to", "maxLength": 32},
+				},
+				"required": []string{"value", "from", "to"},
+			},
+			Run: runConvertUnits,
+		}},
+	}
+}
+
+func lookupUnit(name string) (unit, bool) {
+	u, ok := units[strings.ToLower(strings.TrimSpace(name))]
+	return u, ok
+}
+
+// ConvertUnits converts value between two units of the same category
+func ConvertUnits(value float64, from, to string) (float64, error) {
+	f, ok := lookupUnit(from)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	t, ok := lookupUnit(to)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if f.category != t.category {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, f.category, to, t.category)
+	}
+	base := value*f.factor + f.offset
+	return (base - t.offset) / t.factor, nil
+}
+
+func runConvertUnits(ctx context.Context, args map[string]interface{}) (string, error) {
+	from, to := args["from"].(string), args["to"].(string)
+	v, err := ConvertUnits(args["value"].(float64), from, to)
+	if err != nil {
+		return "", err
+	}
+	out, _ := json.Marshal(map[string]interface{}{
+		"value":   formatNumber(v),
+		"unit":    to,
+		"summary": fmt.Sprintf("%s %s = %s %s", formatNumber(args["value"].(float64)), from, formatNumber(v), to),
+	})
+	return string(out), nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services/credentials"
+)
+
+var (
+	// ErrCredentialNotFound is returned when no credential visible to the caller has the ID
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	// ErrCredentialExists is returned when the caller already has a credential with the name
+	// in the requested scope
+	ErrCredentialExists = errors.New("credential already exists")
+
+	// ErrCredentialsDisabled is returned when no credential keys are configured
+	ErrCredentialsDisabled = errors.New("credential storage is not configured")
+)
+
+// Caller is the tenant and user a request acts for
+type Caller struct {
+	TenantID string
+	UserID   uuid.UUID
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context for work done on the caller's behalf, so skill calls made in it
+// authenticate with the caller's credentials
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller set by WithCaller
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// CredentialService stores encrypted secrets for skill servers. Values go in through Create
+// and Update and come out only through AuthForSkill, at call time.
+type CredentialService interface {
+	Create(ctx context.Context, caller Caller, req models.CreateCredentialRequest) (*models.Credential, error)
+	// Get returns a credential of the caller's tenant that is either tenant-wide or the caller's own
+	Get(ctx context.Context, caller Caller, id uuid.UUID) (*models.Credential, error)
+	// List returns the tenant's credentials and the caller's own
+	List(ctx context.Context, caller Caller) ([]models.Credential, error)
+	Update(ctx context.Context, caller Caller, id uuid.UUID, req models.UpdateCredentialRequest) (*models.Credential, error)
+	Delete(ctx context.Context, caller Caller, id uuid.UUID) error
+	// AuthForSkill renders the skill's auth templates with its owner's credentials, preferring
+	// them over the tenant's, which only skills written by admins may use. The caller picks the
+	// tenant of global skills only. The result holds plaintext secrets.
+	AuthForSkill(ctx context.Context, skill *models.Skill, caller Caller) (*credentials.Auth, error)
+	// RotateKeys rewraps every credential whose data key is not under the primary key
+	RotateKeys(ctx context.Context) (*models.CredentialRotationResult, error)
+}
@@ -0,0 +1,125 @@
+package credentials
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+)
+
+// placeholder matches {{credential:name}} in header and query templates
+var placeholder = regexp.MustCompile(`\{\{\s*credential:([a-zA-Z0-9_.-]+)\s*\}\}`)
+
+// References returns the credential names a template uses, in order of appearance
+func References(template string) []string {
+	var names []string
+	for _, m := range placeholder.FindAllStringSubmatch(template, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// Render replaces each {{credential:name}} in template with lookup's value for the name
+func Render(template string, lookup func(name string) (string, error)) (string, error) {
+	var err error
+	out := placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		if err != nil {
+			return ""
+		}
+		var value string
+		value, err = lookup(placeholder.FindStringSubmatch(m)[1])
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// Auth is the rendered authentication of a skill's requests. It holds plaintext secrets: it is
+// never serialized and must not be logged.
+type Auth struct {
+	Headers map[string]string
+	Query   map[string]string
+}
+
+// Empty reports whether there is nothing to inject
+func (a *Auth) Empty() bool {
+	return a == nil || (len(a.Headers) == 0 && len(a.Query) == 0)
+}
+
+// Fingerprint identifies the secrets without revealing them, e.g. to keep separate sessions
+// per credential set
+func (a *Auth) Fingerprint() string {
+	if a.Empty() {
+		return ""
+	}
+	h := sha256.New()
+	for _, part := range []map[string]string{a.Headers, a.Query} {
+		keys := make([]string, 0, len(part))
+		for k := range part {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%q=%q;", k, part[k])
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Client returns a copy of base whose requests carry the headers and query parameters. They
+// are added to a clone of each request, so errors the client returns, which quote the
+// request URL, do not contain them. Redirects to another host are not followed, since the
+// secrets would go along.
+func (a *Auth) Client(base *http.Client) *http.Client {
+	if a.Empty() {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultClient
+	}
+	client := *base
+	client.Transport = &authTransport{base: base.Transport, auth: a}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Host != via[0].URL.Host {
+			return http.ErrUseLastResponse
+		}
+		if base.CheckRedirect != nil {
+			return base.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &client
+}
+
+type authTransport struct {
+	base http.RoundTripper
+	auth *Auth
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.auth.Headers {
+		req.Header.Set(k, v)
+	}
+	if len(t.auth.Query) > 0 {
+		q := req.URL.Query()
+		for k, v := range t.auth.Query {
+			q.Set(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
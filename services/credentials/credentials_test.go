@@ -0,0 +1,98 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, err := ParseKeyring([]string{"k1:" + testKey(1)}, "")
+	require.NoError(t, err)
+	aad := []byte("tenant-a/cred-1")
+
+	sealed, err := old.Seal([]byte("s3cret"), aad)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", sealed.KeyID)
+	assert.NotContains(t, string(sealed.Ciphertext), "s3cret")
+
+	_, err = old.Open(sealed, []byte("tenant-b/cred-1"))
+	assert.Error(t, err, "a value does not open for another owner")
+
+	// k2 becomes primary; k1 stays to open what it sealed
+	rotated, err := ParseKeyring([]string{"k1:" + testKey(1), "k2:" + testKey(2)}, "k2")
+	require.NoError(t, err)
+	ciphertext := append([]byte(nil), sealed.Ciphertext...)
+	changed, err := rotated.Rewrap(sealed, aad)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "k2", sealed.KeyID)
+	assert.Equal(t, ciphertext, sealed.Ciphertext, "rewrapping leaves the ciphertext alone")
+
+	changed, err = rotated.Rewrap(sealed, aad)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	plaintext, err := rotated.Open(sealed, aad)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", string(plaintext))
+
+	_, err = old.Open(sealed, aad)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = ParseKeyring([]string{"k1:" + base64.StdEncoding.EncodeToString([]byte("short"))}, "")
+	assert.Error(t, err)
+	_, err = ParseKeyring([]string{"k1:" + testKey(1)}, "k9")
+	assert.Error(t, err)
+}
+
+func TestRenderAndClient(t *testing.T) {
+	secrets := map[string]string{"gh": "tok-123"}
+	lookup := func(name string) (string, error) {
+		if v, ok := secrets[name]; ok {
+			return v, nil
+		}
+		return "", fmt.Errorf("credential %q not found", name)
+	}
+
+	assert.Equal(t, []string{"gh", "other"}, References("Bearer {{credential:gh}} {{ credential:other }}"))
+	header, err := Render("Bearer {{credential:gh}}", lookup)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer tok-123", header)
+	_, err = Render("{{credential:missing}}", lookup)
+	assert.Error(t, err)
+
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer server.Close()
+
+	auth := &Auth{Headers: map[string]string{"Authorization": header}, Query: map[string]string{"api_key": "q-456"}}
+	client := auth.Client(&http.Client{})
+	resp, err := client.Get(server.URL + "/tools?x=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "Bearer tok-123", got.Header.Get("Authorization"))
+	assert.Equal(t, "q-456", got.URL.Query().Get("api_key"))
+	assert.Equal(t, "1", got.URL.Query().Get("x"))
+
+	// Errors quote the URL as requested, without the injected query
+	_, err = client.Get("http://127.0.0.1:1/unreachable")
+	require.Error(t, err)
+	assert.False(t, strings.Contains(err.Error(), "q-456"))
+
+	assert.NotEqual(t, auth.Fingerprint(), (&Auth{Headers: map[string]string{"Authorization": "Bearer other"}}).Fingerprint())
+	assert.Empty(t, (*Auth)(nil).Fingerprint())
+}
@@ -0,0 +1,189 @@
+// Package credentials encrypts secrets at rest and injects them into outgoing HTTP requests.
+//
+// Secrets are sealed with envelope encryption: each value gets its own random data key, used
+// with AES-256-GCM, and the data key is wrapped by a key-encryption key from the Keyring.
+// Rotating the keyring's primary key only rewraps data keys; sealed values stay as they are.
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// keySize is the size of key-encryption and data keys (AES-256)
+const keySize = 32
+
+// ErrUnknownKey is returned when a value was sealed under a key the keyring does not hold
+var ErrUnknownKey = errors.New("credential key is not configured")
+
+// Sealed is an encrypted secret and its wrapped data key
+type Sealed struct {
+	KeyID      string // Key-encryption key that wrapped the data key
+	WrappedKey []byte // Nonce followed by the encrypted data key
+	Ciphertext []byte // Nonce followed by the encrypted value
+}
+
+// Keyring holds the key-encryption keys by ID. New values are sealed under the primary key;
+// older keys stay available to open values sealed before a rotation.
+type Keyring struct {
+	keys    map[string][]byte
+	primary string
+}
+
+// NewKeyring creates a keyring. Every key must be 32 bytes and primary must be one of them.
+func NewKeyring(keys map[string][]byte, primary string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no credential keys configured")
+	}
+	for id, key := range keys {
+		if id == "" {
+			return nil, fmt.Errorf("credential key IDs must not be empty")
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("credential key %q must be %d bytes, got %d", id, keySize, len(key))
+		}
+	}
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary credential key %q is not configured", primary)
+	}
+	return &Keyring{keys: keys, primary: primary}, nil
+}
+
+// ParseKeyring builds a keyring from "id:base64key" specs. An empty primary selects the
+// first spec's key.
+func ParseKeyring(specs []string, primary string) (*Keyring, error) {
+	keys := make(map[string][]byte, len(specs))
+	for _, spec := range specs {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok {
+			return nil, fmt.Errorf("credential key spec must be id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("credential key %q is not valid base64: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("duplicate credential key %q", id)
+		}
+		keys[id] = key
+		if primary == "" {
+			primary = id
+		}
+	}
+	return NewKeyring(keys, primary)
+}
+
+// Primary returns the ID of the key new values are sealed under
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// KeyIDs returns the configured key IDs in order
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Seal encrypts plaintext under a fresh data key wrapped by the primary key. aad binds the
+// result to its owner, so a sealed value copied to another record does not open.
+func (k *Keyring) Seal(plaintext, aad []byte) (*Sealed, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	ciphertext, err := encrypt(dataKey, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := encrypt(k.keys[k.primary], dataKey, aad)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealed{KeyID: k.primary, WrappedKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts a sealed value
+func (k *Keyring) Open(s *Sealed, aad []byte) ([]byte, error) {
+	dataKey, err := k.unwrap(s, aad)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := decrypt(dataKey, s.Ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap wraps the value's data key under the primary key if it is under another one and
+// reports whether it changed. The ciphertext is left as it is.
+func (k *Keyring) Rewrap(s *Sealed, aad []byte) (bool, error) {
+	if s.KeyID == k.primary {
+		return false, nil
+	}
+	dataKey, err := k.unwrap(s, aad)
+	if err != nil {
+		return false, err
+	}
+	wrapped, err := encrypt(k.keys[k.primary], dataKey, aad)
+	if err != nil {
+		return false, err
+	}
+	s.KeyID = k.primary
+	s.WrappedKey = wrapped
+	return true, nil
+}
+
+func (k *Keyring) unwrap(s *Sealed, aad []byte) ([]byte, error) {
+	kek, ok := k.keys[s.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, s.KeyID)
+	}
+	dataKey, err := decrypt(kek, s.WrappedKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func encrypt(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func decrypt(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
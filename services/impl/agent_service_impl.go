@@ -67,6 +67,8 @@ func (s *agentServiceImpl) CreateAgent(ctx context.Context, req models.CreateAge
 		EnableKnowledge: enableKnowledge,
 		EnableMemory:    enableMemory,
 		DocumentContext: req.DocumentContext,
+		Experiment:      startExperiment(req.Experiment, nil),
+		ToolPolicy:      req.ToolPolicy,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
@@ -197,6 +199,12 @@ func (s *agentServiceImpl) UpdateAgent(ctx context.Context, id uuid.UUID, req mo
 	if req.DocumentContext != nil {
 		updates["document_context"] = req.DocumentContext
 	}
+	if req.Experiment != nil {
+		updates["experiment"] = startExperiment(req.Experiment, agent.Experiment)
+	}
+	if req.ToolPolicy != nil {
+		updates["tool_policy"] = req.ToolPolicy
+	}
 
 	if req.NotebookIDs != nil {
 		notebookJSON, err := models.ConvertToJSON(req.NotebookIDs)
@@ -236,6 +244,21 @@ func (s *agentServiceImpl) UpdateAgent(ctx context.Context, id uuid.UUID, req mo
 	return &agent, nil
 }
 
+// startExperiment stamps the start time of an enabled experiment, keeping the existing start
+// time when the same experiment is updated
+func startExperiment(exp *models.AgentExperiment, current *models.AgentExperiment) *models.AgentExperiment {
+	if exp == nil || !exp.Enabled || exp.StartedAt != nil {
+		return exp
+	}
+	if current != nil && current.Name == exp.Name && current.StartedAt != nil {
+		exp.StartedAt = current.StartedAt
+		return exp
+	}
+	now := time.Now()
+	exp.StartedAt = &now
+	return exp
+}
+
 func (s *agentServiceImpl) DeleteAgent(ctx context.Context, id uuid.UUID, ownerID string) error {
 	// Check if agent exists
 	var count int64
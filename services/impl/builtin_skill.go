@@ -0,0 +1,32 @@
+package impl
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tas-agent-builder/models"
+)
+
+// builtinToolList lists the in-process tools registered for a builtin skill
+func (s *skillToolServiceImpl) builtinToolList(skill *models.Skill) (*models.SkillToolList, error) {
+	def, ok := s.builtins.Skill(skill.Name)
+	if !ok {
+		return nil, fmt.Errorf("skill %q is not a registered builtin", skill.Name)
+	}
+
+	list := &models.SkillToolList{
+		SkillID:   skill.ID,
+		Tools:     make([]models.SkillTool, 0, len(def.Tools)),
+		FetchedAt: skill.UpdatedAt,
+	}
+	for _, t := range def.Tools {
+		list.Tools = append(list.Tools, models.SkillTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.Parameters,
+		})
+	}
+	data, _ := json.Marshal(list.Tools)
+	list.ETag = contentETag(data)
+	return list, nil
+}
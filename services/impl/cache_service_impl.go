@@ -14,6 +14,7 @@ import (
 	"github.com/tas-agent-builder/config"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
 const (
@@ -423,8 +424,8 @@ func (s *CachedContextService) GetNotebookDocuments(ctx context.Context, noteboo
 }
 
 // FormatContextForInjection formats retrieved chunks into a string (pass-through)
-func (s *CachedContextService) FormatContextForInjection(result *models.DocumentContextResult, maxTokens int) (*models.ContextInjectionResult, error) {
-	return s.delegate.FormatContextForInjection(result, maxTokens)
+func (s *CachedContextService) FormatContextForInjection(result *models.DocumentContextResult, maxTokens int, tok tokenizer.Tokenizer) (*models.ContextInjectionResult, error) {
+	return s.delegate.FormatContextForInjection(result, maxTokens, tok)
 }
 
 // EstimateTokenCount estimates the number of tokens in a string (pass-through)
@@ -432,17 +433,24 @@ func (s *CachedContextService) EstimateTokenCount(text string) int {
 	return s.delegate.EstimateTokenCount(text)
 }
 
+// ResolveFileReference resolves an AudiModal file reference (pass-through; signed URLs expire)
+func (s *CachedContextService) ResolveFileReference(ctx context.Context, tenantID, fileID, authToken string, inline bool) (*models.ResolvedFile, error) {
+	return s.delegate.ResolveFileReference(ctx, tenantID, fileID, authToken, inline)
+}
+
 // Helper methods for cache key generation
 
 func (s *CachedContextService) generateVectorSearchCacheKey(req models.VectorSearchRequest) string {
 	// Create a deterministic hash of the request
-	keyData := fmt.Sprintf("vector:%s:%s:%v:%v:%d:%.2f",
+	keyData := fmt.Sprintf("vector:%s:%s:%v:%v:%t:%d:%.2f:%v",
 		req.TenantID,
 		req.QueryText,
 		req.NotebookIDs,
 		req.DocumentIDs,
+		req.IncludeSubNotebooks,
 		req.Options.TopK,
 		req.Options.MinScore,
+		req.Options.Filters,
 	)
 	return HashQuery(keyData)
 }
@@ -460,11 +468,12 @@ func (s *CachedContextService) generateChunkRetrievalCacheKey(req models.ChunkRe
 }
 
 func (s *CachedContextService) generateHybridCacheKey(query string, req models.ChunkRetrievalRequest, vectorWeight, fullDocWeight float64) string {
-	keyData := fmt.Sprintf("hybrid:%s:%s:%v:%v:%.2f:%.2f",
+	keyData := fmt.Sprintf("hybrid:%s:%s:%v:%v:%t:%.2f:%.2f",
 		req.TenantID,
 		query,
 		req.FileIDs,
 		req.NotebookIDs,
+		req.IncludeSubNotebooks,
 		vectorWeight,
 		fullDocWeight,
 	)
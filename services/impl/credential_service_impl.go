@@ -0,0 +1,314 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/credentials"
+	"gorm.io/gorm"
+)
+
+// rotationBatchSize is the number of credentials rewrapped per query during key rotation
+const rotationBatchSize = 100
+
+type credentialServiceImpl struct {
+	db      *gorm.DB
+	keyring *credentials.Keyring
+}
+
+// NewCredentialService creates a CredentialService sealing values with keyring. With a nil
+// keyring every method returns ErrCredentialsDisabled.
+func NewCredentialService(db *gorm.DB, keyring *credentials.Keyring) services.CredentialService {
+	return &credentialServiceImpl{db: db, keyring: keyring}
+}
+
+// credentialAAD binds a sealed value to its credential and tenant
+func credentialAAD(c *models.Credential) []byte {
+	return []byte(c.TenantID + "/" + c.ID.String())
+}
+
+// visible limits a query to the caller's tenant credentials and their own
+func visible(db *gorm.DB, caller services.Caller) *gorm.DB {
+	return db.Where("tenant_id = ? AND deleted_at IS NULL AND (user_id IS NULL OR user_id = ?)", caller.TenantID, caller.UserID)
+}
+
+func (s *credentialServiceImpl) Create(ctx context.Context, caller services.Caller, req models.CreateCredentialRequest) (*models.Credential, error) {
+	if s.keyring == nil {
+		return nil, services.ErrCredentialsDisabled
+	}
+
+	now := time.Now()
+	cred := &models.Credential{
+		ID:             uuid.New(),
+		TenantID:       caller.TenantID,
+		Name:           req.Name,
+		Description:    req.Description,
+		CreatedBy:      caller.UserID,
+		ValueUpdatedAt: now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if req.Scope != models.CredentialScopeTenant {
+		cred.UserID = &caller.UserID
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.Credential{}).
+		Where("tenant_id = ? AND name = ? AND deleted_at IS NULL", cred.TenantID, cred.Name)
+	if cred.UserID != nil {
+		query = query.Where("user_id = ?", *cred.UserID)
+	} else {
+		query = query.Where("user_id IS NULL")
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check credential name: %w", err)
+	}
+	if count > 0 {
+		return nil, services.ErrCredentialExists
+	}
+
+	if err := s.seal(cred, req.Value); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(cred).Error; err != nil {
+		return nil, fmt.Errorf("failed to create credential: %w", err)
+	}
+
+	log.Printf("[CREDENTIALS] Created %s credential %q (%s) in tenant %s", cred.Scope(), cred.Name, cred.ID, cred.TenantID)
+	return cred, nil
+}
+
+// seal encrypts value into the credential under a fresh data key
+func (s *credentialServiceImpl) seal(cred *models.Credential, value string) error {
+	sealed, err := s.keyring.Seal([]byte(value), credentialAAD(cred))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+	cred.KeyID = sealed.KeyID
+	cred.WrappedKey = sealed.WrappedKey
+	cred.Ciphertext = sealed.Ciphertext
+	return nil
+}
+
+func (s *credentialServiceImpl) Get(ctx context.Context, caller services.Caller, id uuid.UUID) (*models.Credential, error) {
+	if s.keyring == nil {
+		return nil, services.ErrCredentialsDisabled
+	}
+
+	var cred models.Credential
+	err := visible(s.db.WithContext(ctx), caller).Where("id = ?", id).First(&cred).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, services.ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return &cred, nil
+}
+
+func (s *credentialServiceImpl) List(ctx context.Context, caller services.Caller) ([]models.Credential, error) {
+	if s.keyring == nil {
+		return nil, services.ErrCredentialsDisabled
+	}
+
+	var creds []models.Credential
+	if err := visible(s.db.WithContext(ctx), caller).Order("name ASC").Find(&creds).Error; err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	return creds, nil
+}
+
+func (s *credentialServiceImpl) Update(ctx context.Context, caller services.Caller, id uuid.UUID, req models.UpdateCredentialRequest) (*models.Credential, error) {
+	cred, err := s.Get(ctx, caller, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]any{"updated_at": now}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Value != nil {
+		if *req.Value == "" {
+			return nil, fmt.Errorf("value must not be empty")
+		}
+		if err := s.seal(cred, *req.Value); err != nil {
+			return nil, err
+		}
+		updates["key_id"] = cred.KeyID
+		updates["wrapped_key"] = cred.WrappedKey
+		updates["ciphertext"] = cred.Ciphertext
+		updates["value_updated_at"] = now
+	}
+
+	if err := s.db.WithContext(ctx).Model(cred).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update credential: %w", err)
+	}
+	if req.Value != nil {
+		log.Printf("[CREDENTIALS] Replaced the value of credential %q (%s)", cred.Name, cred.ID)
+	}
+	return s.Get(ctx, caller, id)
+}
+
+func (s *credentialServiceImpl) Delete(ctx context.Context, caller services.Caller, id uuid.UUID) error {
+	cred, err := s.Get(ctx, caller, id)
+	if err != nil {
+		return err
+	}
+
+	// The sealed value is cleared along with the soft delete
+	err = s.db.WithContext(ctx).Model(cred).Updates(map[string]any{
+		"deleted_at":  time.Now(),
+		"wrapped_key": []byte{},
+		"ciphertext":  []byte{},
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return nil
+}
+
+// skillCredentialScope returns whose credentials a skill's templates use: those of the skill's
+// owner in the skill's tenant, and the tenant's own if a skill admin wrote the skill. Global
+// skills are published by global admins and use the credentials of the tenant they run in,
+// never a user's. The caller only matters for the tenant of a global skill, so running someone
+// else's skill never hands it the caller's credentials.
+func skillCredentialScope(skill *models.Skill, caller services.Caller) (tenantID string, ownerID *uuid.UUID, tenantWide bool) {
+	if skill.Visibility == models.SkillVisibilityGlobal {
+		return caller.TenantID, nil, true
+	}
+	return skill.TenantID, skill.OwnerID, skill.TenantCredentials
+}
+
+func (s *credentialServiceImpl) AuthForSkill(ctx context.Context, skill *models.Skill, caller services.Caller) (*credentials.Auth, error) {
+	if !skill.UsesCredentials() {
+		return nil, nil
+	}
+	tenantID, ownerID, tenantWide := skillCredentialScope(skill, caller)
+
+	var names []string
+	for _, templates := range []models.CredentialTemplates{skill.AuthHeaders, skill.AuthQuery} {
+		for _, template := range templates {
+			names = append(names, credentials.References(template)...)
+		}
+	}
+
+	values := make(map[string]string)
+	if len(names) > 0 {
+		if s.keyring == nil {
+			return nil, services.ErrCredentialsDisabled
+		}
+		var creds []models.Credential
+		if tenantID != "" && (ownerID != nil || tenantWide) {
+			query := s.db.WithContext(ctx).Where("tenant_id = ? AND deleted_at IS NULL AND name IN ?", tenantID, names)
+			switch {
+			case ownerID != nil && tenantWide:
+				query = query.Where("user_id IS NULL OR user_id = ?", *ownerID)
+			case ownerID != nil:
+				query = query.Where("user_id = ?", *ownerID)
+			default:
+				query = query.Where("user_id IS NULL")
+			}
+			if err := query.Find(&creds).Error; err != nil {
+				return nil, fmt.Errorf("failed to load credentials: %w", err)
+			}
+		}
+
+		for name, c := range chooseCredentials(creds) {
+			value, err := s.keyring.Open(&credentials.Sealed{KeyID: c.KeyID, WrappedKey: c.WrappedKey, Ciphertext: c.Ciphertext}, credentialAAD(c))
+			if err != nil {
+				return nil, fmt.Errorf("credential %q cannot be decrypted: %w", name, err)
+			}
+			values[name] = string(value)
+		}
+	}
+
+	lookup := func(name string) (string, error) {
+		value, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("%w: skill %q uses credential %q, which is not set for the skill's owner or, for skills published by admins, the tenant", services.ErrCredentialNotFound, skill.Name, name)
+		}
+		return value, nil
+	}
+	auth := &credentials.Auth{}
+	render := func(templates models.CredentialTemplates) (map[string]string, error) {
+		if len(templates) == 0 {
+			return nil, nil
+		}
+		out := make(map[string]string, len(templates))
+		for k, template := range templates {
+			value, err := credentials.Render(template, lookup)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = value
+		}
+		return out, nil
+	}
+	var err error
+	if auth.Headers, err = render(skill.AuthHeaders); err != nil {
+		return nil, err
+	}
+	if auth.Query, err = render(skill.AuthQuery); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// chooseCredentials picks one credential per name, a user's own over the tenant's
+func chooseCredentials(creds []models.Credential) map[string]*models.Credential {
+	chosen := make(map[string]*models.Credential)
+	for i := range creds {
+		c := &creds[i]
+		if prev := chosen[c.Name]; prev == nil || (prev.UserID == nil && c.UserID != nil) {
+			chosen[c.Name] = c
+		}
+	}
+	return chosen
+}
+
+func (s *credentialServiceImpl) RotateKeys(ctx context.Context) (*models.CredentialRotationResult, error) {
+	if s.keyring == nil {
+		return nil, services.ErrCredentialsDisabled
+	}
+
+	result := &models.CredentialRotationResult{PrimaryKeyID: s.keyring.Primary()}
+	var batch []models.Credential
+	err := s.db.WithContext(ctx).
+		Where("key_id <> ? AND deleted_at IS NULL", s.keyring.Primary()).
+		FindInBatches(&batch, rotationBatchSize, func(tx *gorm.DB, _ int) error {
+			for i := range batch {
+				c := &batch[i]
+				sealed := &credentials.Sealed{KeyID: c.KeyID, WrappedKey: c.WrappedKey, Ciphertext: c.Ciphertext}
+				if _, err := s.keyring.Rewrap(sealed, credentialAAD(c)); err != nil {
+					log.Printf("[CREDENTIALS] Cannot rewrap credential %s: %v", c.ID, err)
+					result.Failed++
+					continue
+				}
+				// Skip credentials whose value was replaced since they were read
+				res := s.db.WithContext(ctx).Model(&models.Credential{}).
+					Where("id = ? AND key_id = ?", c.ID, c.KeyID).
+					Updates(map[string]any{"key_id": sealed.KeyID, "wrapped_key": sealed.WrappedKey})
+				if res.Error != nil {
+					return fmt.Errorf("failed to rewrap credential %s: %w", c.ID, res.Error)
+				}
+				result.Rewrapped += int(res.RowsAffected)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return result, err
+	}
+
+	if result.Rewrapped > 0 || result.Failed > 0 {
+		log.Printf("[CREDENTIALS] Rewrapped %d credentials under key %s, %d failed", result.Rewrapped, result.PrimaryKeyID, result.Failed)
+	}
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+func TestSkillCredentialScope(t *testing.T) {
+	owner, runner := uuid.New(), uuid.New()
+	caller := services.Caller{TenantID: "tenant-a", UserID: runner}
+
+	t.Run("a member's skill gets neither the tenant's credentials nor the caller's", func(t *testing.T) {
+		// A member points a private skill at their own host and references a tenant secret
+		skill := &models.Skill{Name: "exfil", TenantID: "tenant-a", OwnerID: &owner, Visibility: models.SkillVisibilityPrivate}
+		tenantID, ownerID, tenantWide := skillCredentialScope(skill, caller)
+		assert.Equal(t, "tenant-a", tenantID)
+		assert.Equal(t, &owner, ownerID, "only the owner's credentials are used")
+		assert.False(t, tenantWide, "skills not written by admins cannot use tenant credentials")
+	})
+
+	t.Run("running a shared agent's skill never uses the runner's credentials", func(t *testing.T) {
+		// The owner of a public agent resolves skills as themselves; the runner is the caller
+		skill := &models.Skill{Name: "harvest", TenantID: "tenant-a", OwnerID: &owner, Visibility: models.SkillVisibilityTenant}
+		_, ownerID, _ := skillCredentialScope(skill, caller)
+		assert.NotEqual(t, runner, *ownerID)
+		assert.Equal(t, owner, *ownerID)
+	})
+
+	t.Run("skills written by admins may use tenant credentials", func(t *testing.T) {
+		skill := &models.Skill{Name: "crm", TenantID: "tenant-a", OwnerID: &owner, Visibility: models.SkillVisibilityTenant, TenantCredentials: true}
+		_, _, tenantWide := skillCredentialScope(skill, caller)
+		assert.True(t, tenantWide)
+	})
+
+	t.Run("global skills use the running tenant's credentials only", func(t *testing.T) {
+		skill := &models.Skill{Name: "search", OwnerID: &owner, Visibility: models.SkillVisibilityGlobal}
+		tenantID, ownerID, tenantWide := skillCredentialScope(skill, caller)
+		assert.Equal(t, "tenant-a", tenantID)
+		assert.Nil(t, ownerID, "no user's credentials go to global skills")
+		assert.True(t, tenantWide)
+	})
+}
+
+func TestChooseCredentialsPrefersOwn(t *testing.T) {
+	owner := uuid.New()
+	chosen := chooseCredentials([]models.Credential{
+		{Name: "crm", ID: uuid.New()},
+		{Name: "crm", ID: uuid.New(), UserID: &owner},
+		{Name: "search", ID: uuid.New()},
+	})
+	assert.Len(t, chosen, 2)
+	assert.Equal(t, &owner, chosen["crm"].UserID)
+	assert.Nil(t, chosen["search"].UserID)
+}
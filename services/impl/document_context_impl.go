@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"sort"
 	"strings"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/tas-agent-builder/config"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
 // documentContextServiceImpl implements DocumentContextService
@@ -27,6 +29,7 @@ type documentContextServiceImpl struct {
 	aetherConfig    *config.AetherConfig
 	httpClient      *http.Client
 	cacheService    services.CacheService
+	notebooks       services.NotebookService // Resolves sub-notebooks of scoped searches; nil if Aether is not configured
 }
 
 // NewDocumentContextService creates a new DocumentContextService instance
@@ -36,6 +39,10 @@ func NewDocumentContextService(
 	aetherCfg *config.AetherConfig,
 	cacheSvc services.CacheService,
 ) services.DocumentContextService {
+	var notebooks services.NotebookService
+	if aetherCfg != nil && aetherCfg.BaseURL != "" {
+		notebooks = NewNotebookService(aetherCfg)
+	}
 	return &documentContextServiceImpl{
 		deeplakeConfig:  deeplakeCfg,
 		audimodalConfig: audimodalCfg,
@@ -44,6 +51,7 @@ func NewDocumentContextService(
 			Timeout: 30 * time.Second,
 		},
 		cacheService: cacheSvc,
+		notebooks:    notebooks,
 	}
 }
 
@@ -51,7 +59,8 @@ func NewDocumentContextService(
 func (s *documentContextServiceImpl) RetrieveVectorContext(ctx context.Context, req models.VectorSearchRequest) (*models.DocumentContextResult, error) {
 	startTime := time.Now()
 
-	// Build DeepLake search request
+	// Build DeepLake search request, restricted to the notebooks and documents in scope
+	scope := s.vectorScopeFor(ctx, req)
 	searchReq := map[string]interface{}{
 		"query_text": req.QueryText,
 		"options": map[string]interface{}{
@@ -64,6 +73,9 @@ func (s *documentContextServiceImpl) RetrieveVectorContext(ctx context.Context,
 	if req.Options.MinScore > 0 {
 		searchReq["options"].(map[string]interface{})["min_score"] = req.Options.MinScore
 	}
+	if filters := scope.filters(req.Options.Filters); filters != nil {
+		searchReq["options"].(map[string]interface{})["filters"] = filters
+	}
 
 	// Determine dataset ID based on configuration
 	datasetID := req.DatasetID
@@ -131,8 +143,10 @@ func (s *documentContextServiceImpl) RetrieveVectorContext(ctx context.Context,
 	}
 
 	// Convert to RetrievedChunk format
+	tok := tokenizer.FromContext(ctx)
 	chunks := make([]models.RetrievedChunk, 0, len(searchResp.Results))
 	totalTokens := 0
+	outOfScope := 0
 
 	for _, result := range searchResp.Results {
 		chunk := models.RetrievedChunk{
@@ -169,8 +183,19 @@ func (s *documentContextServiceImpl) RetrieveVectorContext(ctx context.Context,
 			}
 		}
 
+		// DeepLake may not apply every filter, so out-of-scope chunks are dropped here too
+		if !scope.allows(chunk) {
+			outOfScope++
+			continue
+		}
+
 		chunks = append(chunks, chunk)
-		totalTokens += s.EstimateTokenCount(chunk.Content)
+		totalTokens += tok.Count(chunk.Content)
+	}
+
+	if outOfScope > 0 {
+		log.Printf("[DEBUG] Vector search for tenant %s returned %d chunks outside its notebook and document scope; dropped them",
+			req.TenantID, outOfScope)
 	}
 
 	retrievalTime := int(time.Since(startTime).Milliseconds())
@@ -185,6 +210,7 @@ func (s *documentContextServiceImpl) RetrieveVectorContext(ctx context.Context,
 			"has_more":          searchResp.HasMore,
 			"query_time_ms":     searchResp.QueryTimeMs,
 			"embedding_time_ms": searchResp.EmbeddingTimeMs,
+			"out_of_scope":      outOfScope,
 		},
 	}, nil
 }
@@ -226,6 +252,7 @@ func (s *documentContextServiceImpl) RetrieveFullDocuments(ctx context.Context,
 	log.Printf("[DEBUG] Total chunks retrieved: %d, total_count=%d", len(allChunks), totalCount)
 
 	// Convert to RetrievedChunk format and sort by document/chunk order
+	tok := tokenizer.FromContext(ctx)
 	chunks := make([]models.RetrievedChunk, 0, len(allChunks))
 	totalTokens := 0
 
@@ -241,7 +268,7 @@ func (s *documentContextServiceImpl) RetrieveFullDocuments(ctx context.Context,
 		}
 
 		chunks = append(chunks, chunk)
-		totalTokens += s.EstimateTokenCount(chunk.Content)
+		totalTokens += tok.Count(chunk.Content)
 	}
 
 	// Sort chunks by document ID and chunk number for proper ordering
@@ -288,6 +315,7 @@ func (s *documentContextServiceImpl) fetchChunksForFile(ctx context.Context, ten
 	var totalCount int64
 	currentPage := 1
 	currentTokens := 0
+	tok := tokenizer.FromContext(ctx)
 
 	for {
 		// Build URL with pagination
@@ -329,7 +357,7 @@ func (s *documentContextServiceImpl) fetchChunksForFile(ctx context.Context, ten
 
 		// Add chunks while tracking token budget
 		for _, chunk := range chunks {
-			chunkTokens := s.EstimateTokenCount(chunk.Content)
+			chunkTokens := tok.Count(chunk.Content)
 			if currentTokens+chunkTokens > maxTokenBudget {
 				log.Printf("[DEBUG] Token budget reached: %d tokens, stopping pagination", currentTokens)
 				return allChunks, totalCount, nil
@@ -521,8 +549,12 @@ func (s *documentContextServiceImpl) RetrieveHybridContext(
 
 	// Perform vector search
 	vectorReq := models.VectorSearchRequest{
-		QueryText: query,
-		TenantID:  req.TenantID,
+		QueryText:   query,
+		NotebookIDs: req.NotebookIDs,
+		DocumentIDs: req.FileIDs,
+		TenantID:    req.TenantID,
+
+		IncludeSubNotebooks: req.IncludeSubNotebooks,
 		Options: models.SearchOptions{
 			TopK:          20, // Get more results for hybrid merging
 			MinScore:      0.6,
@@ -545,9 +577,10 @@ func (s *documentContextServiceImpl) RetrieveHybridContext(
 	mergedChunks := s.mergeAndRankChunks(vectorResult.Chunks, fullResult.Chunks, vectorWeight, fullDocWeight)
 
 	// Calculate total tokens
+	tok := tokenizer.FromContext(ctx)
 	totalTokens := 0
 	for _, chunk := range mergedChunks {
-		totalTokens += s.EstimateTokenCount(chunk.Content)
+		totalTokens += tok.Count(chunk.Content)
 	}
 
 	retrievalTime := int(time.Since(startTime).Milliseconds())
@@ -580,8 +613,12 @@ func (s *documentContextServiceImpl) RetrieveHybridContextWithConfig(
 
 	// Perform vector search with configured parameters
 	vectorReq := models.VectorSearchRequest{
-		QueryText: query,
-		TenantID:  req.TenantID,
+		QueryText:   query,
+		NotebookIDs: req.NotebookIDs,
+		DocumentIDs: req.FileIDs,
+		TenantID:    req.TenantID,
+
+		IncludeSubNotebooks: req.IncludeSubNotebooks,
 		Options: models.SearchOptions{
 			TopK:          config.VectorTopK,
 			MinScore:      config.VectorMinScore,
@@ -607,7 +644,7 @@ func (s *documentContextServiceImpl) RetrieveHybridContextWithConfig(
 
 	// Use the HybridContextBuilder to merge and rank
 	builder := NewHybridContextBuilder(config)
-	return builder.BuildHybridContext(ctx, vectorResult.Chunks, fullResult.Chunks, s.EstimateTokenCount)
+	return builder.BuildHybridContext(ctx, vectorResult.Chunks, fullResult.Chunks, tokenizer.FromContext(ctx).Count)
 }
 
 // mergeAndRankChunks merges and deduplicates chunks from vector and full doc retrieval
@@ -720,10 +757,12 @@ func (s *documentContextServiceImpl) GetNotebookDocuments(
 	return allDocuments, nil
 }
 
-// FormatContextForInjection formats retrieved chunks into a string ready for prompt injection
+// FormatContextForInjection formats retrieved chunks into a string ready for prompt injection.
+// Tokens are counted with tok, or the default encoding when tok is nil.
 func (s *documentContextServiceImpl) FormatContextForInjection(
 	result *models.DocumentContextResult,
 	maxTokens int,
+	tok tokenizer.Tokenizer,
 ) (*models.ContextInjectionResult, error) {
 	if result == nil || len(result.Chunks) == 0 {
 		return &models.ContextInjectionResult{
@@ -736,34 +775,46 @@ func (s *documentContextServiceImpl) FormatContextForInjection(
 		}, nil
 	}
 
+	countTokens := s.EstimateTokenCount
+	if tok != nil {
+		countTokens = tok.Count
+	}
+
+	const footer = "\n--- END CONTEXT ---\n"
+
 	var builder strings.Builder
 	builder.WriteString("\n--- RELEVANT CONTEXT ---\n\n")
 
-	currentTokens := s.EstimateTokenCount(builder.String())
+	currentTokens := countTokens(builder.String()) + countTokens(footer)
 	truncated := false
 	includedChunks := 0
 	documentSet := make(map[string]bool)
 	currentDocID := ""
 
 	for _, chunk := range result.Chunks {
-		chunkTokens := s.EstimateTokenCount(chunk.Content)
-
-		// Check if adding this chunk would exceed the token limit
-		if maxTokens > 0 && currentTokens+chunkTokens > maxTokens {
-			truncated = true
-			break
-		}
-
-		// Add document separator if switching documents
+		// Document separator if switching documents
+		separator := ""
 		if chunk.DocumentID != currentDocID && chunk.DocumentID != "" {
 			if currentDocID != "" {
-				builder.WriteString("\n")
+				separator = "\n"
 			}
 			docName := chunk.DocumentName
 			if docName == "" {
 				docName = chunk.DocumentID
 			}
-			builder.WriteString(fmt.Sprintf("--- Document: %s ---\n", docName))
+			separator += fmt.Sprintf("--- Document: %s ---\n", docName)
+		}
+
+		chunkTokens := countTokens(separator + chunk.Content + "\n")
+
+		// Check if adding this chunk would exceed the token limit
+		if maxTokens > 0 && currentTokens+chunkTokens > maxTokens {
+			truncated = true
+			break
+		}
+
+		if separator != "" {
+			builder.WriteString(separator)
 			currentDocID = chunk.DocumentID
 			documentSet[chunk.DocumentID] = true
 		}
@@ -776,10 +827,10 @@ func (s *documentContextServiceImpl) FormatContextForInjection(
 		includedChunks++
 	}
 
-	builder.WriteString("\n--- END CONTEXT ---\n")
+	builder.WriteString(footer)
 
 	formattedContext := builder.String()
-	totalTokens := s.EstimateTokenCount(formattedContext)
+	totalTokens := countTokens(formattedContext)
 
 	return &models.ContextInjectionResult{
 		FormattedContext: formattedContext,
@@ -795,14 +846,99 @@ func (s *documentContextServiceImpl) FormatContextForInjection(
 	}, nil
 }
 
-// EstimateTokenCount provides a rough estimate of token count
-// Uses a simple heuristic: ~4 characters per token for English text
+// EstimateTokenCount counts tokens with the shared tokenizer's default encoding
 func (s *documentContextServiceImpl) EstimateTokenCount(text string) int {
-	if text == "" {
-		return 0
+	return tokenizer.Count(text)
+}
+
+// ResolveFileReference asks AudiModal for a signed download URL for a file and, for inline
+// delivery, downloads the bytes so they can be sent to providers that cannot fetch URLs
+func (s *documentContextServiceImpl) ResolveFileReference(ctx context.Context, tenantID, fileID, authToken string, inline bool) (*models.ResolvedFile, error) {
+	url := fmt.Sprintf("%s/api/v1/tenants/%s/files/%s/download-url", s.audimodalConfig.BaseURL, neturl.PathEscape(tenantID), neturl.PathEscape(fileID))
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if authToken != "" {
+		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
+	} else if s.audimodalConfig.APIKey != "" {
+		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.audimodalConfig.APIKey))
+	}
+
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("AudiModal returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var apiResp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			URL         string `json:"url"`
+			Filename    string `json:"filename"`
+			ContentType string `json:"content_type"`
+			Size        int64  `json:"size"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if apiResp.Data.URL == "" {
+		return nil, fmt.Errorf("AudiModal returned no download URL for file %s", fileID)
+	}
+
+	resolved := &models.ResolvedFile{
+		FileID:      fileID,
+		Filename:    apiResp.Data.Filename,
+		ContentType: apiResp.Data.ContentType,
+		SizeBytes:   apiResp.Data.Size,
+		SignedURL:   apiResp.Data.URL,
+	}
+	if !inline {
+		return resolved, nil
+	}
+
+	if resolved.SizeBytes > models.MaxInlineContentBytes {
+		return nil, fmt.Errorf("file %s is %d bytes, inline limit is %d", fileID, resolved.SizeBytes, models.MaxInlineContentBytes)
+	}
+
+	// The signed URL carries its own authorization
+	dataReq, err := http.NewRequestWithContext(ctx, "GET", resolved.SignedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+	dataResp, err := s.httpClient.Do(dataReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer dataResp.Body.Close()
+
+	if dataResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("file download returned status %d", dataResp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(dataResp.Body, models.MaxInlineContentBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > models.MaxInlineContentBytes {
+		return nil, fmt.Errorf("file %s exceeds inline limit of %d bytes", fileID, models.MaxInlineContentBytes)
+	}
+
+	resolved.Data = data
+	resolved.SizeBytes = int64(len(data))
+	if resolved.ContentType == "" {
+		resolved.ContentType = http.DetectContentType(data)
 	}
-	// Rough estimate: 4 characters per token on average
-	return len(text) / 4
+	return resolved, nil
 }
 
 // Helper function to generate cache key
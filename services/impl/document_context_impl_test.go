@@ -0,0 +1,155 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/models"
+)
+
+// subNotebooks is a NotebookService knowing only the sub-notebooks of each notebook
+type subNotebooks map[uuid.UUID][]uuid.UUID
+
+func (n subNotebooks) GetNotebookHierarchy(ctx context.Context, notebookID uuid.UUID, tenantID string) (*models.NotebookHierarchy, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (n subNotebooks) GetDocumentsRecursive(ctx context.Context, notebookID uuid.UUID, tenantID string) ([]models.NotebookDocument, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (n subNotebooks) GetSubNotebookIDs(ctx context.Context, parentNotebookID uuid.UUID, tenantID string) ([]uuid.UUID, error) {
+	return n[parentNotebookID], nil
+}
+
+// fakeDeepLake serves every chunk it holds whatever the filters, recording the last search
+func fakeDeepLake(t *testing.T, chunks []map[string]interface{}, lastSearch *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/datasets/documents/search/text", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(lastSearch))
+
+		results := make([]map[string]interface{}, 0, len(chunks))
+		for i, chunk := range chunks {
+			results = append(results, map[string]interface{}{"vector": chunk, "score": 0.9 - float64(i)*0.01})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results, "total_found": len(results)})
+	}))
+}
+
+func vectorChunk(tenantID string, notebookID, documentID uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          uuid.NewString(),
+		"document_id": documentID.String(),
+		"content":     "chunk of " + documentID.String(),
+		"metadata": map[string]interface{}{
+			"tenant_id":   tenantID,
+			"notebook_id": notebookID.String(),
+		},
+	}
+}
+
+func TestRetrieveVectorContextScopesToNotebooksAndDocuments(t *testing.T) {
+	notebook, subNotebook, otherNotebook := uuid.New(), uuid.New(), uuid.New()
+	doc, subDoc, otherDoc := uuid.New(), uuid.New(), uuid.New()
+
+	var lastSearch map[string]interface{}
+	server := fakeDeepLake(t, []map[string]interface{}{
+		vectorChunk("tenant-a", notebook, doc),
+		vectorChunk("tenant-a", subNotebook, subDoc),
+		vectorChunk("tenant-a", otherNotebook, otherDoc),
+		vectorChunk("tenant-b", notebook, doc),
+		{"id": uuid.NewString(), "document_id": doc.String(), "content": "no metadata"},
+	}, &lastSearch)
+	defer server.Close()
+
+	svc := &documentContextServiceImpl{
+		deeplakeConfig: &config.DeepLakeConfig{BaseURL: server.URL},
+		httpClient:     &http.Client{Timeout: 5 * time.Second},
+		notebooks:      subNotebooks{notebook: {subNotebook}},
+	}
+	ctx := context.Background()
+	search := func(req models.VectorSearchRequest) (*models.DocumentContextResult, map[string]interface{}) {
+		req.QueryText = "revenue"
+		req.TenantID = "tenant-a"
+		req.Options.TopK = 10
+		result, err := svc.RetrieveVectorContext(ctx, req)
+		require.NoError(t, err)
+		options := lastSearch["options"].(map[string]interface{})
+		filters, _ := options["filters"].(map[string]interface{})
+		return result, filters
+	}
+	documentsOf := func(result *models.DocumentContextResult) []string {
+		var ids []string
+		for _, chunk := range result.Chunks {
+			ids = append(ids, chunk.DocumentID)
+		}
+		return ids
+	}
+
+	// A notebook alone is sent as an equality filter and only its own chunks come back
+	result, filters := search(models.VectorSearchRequest{NotebookIDs: []uuid.UUID{notebook}})
+	assert.Equal(t, map[string]interface{}{"notebook_id": notebook.String()}, filters)
+	assert.Equal(t, []string{doc.String()}, documentsOf(result), "other notebooks, other tenants and chunks without a notebook are dropped")
+	assert.Equal(t, 4, result.Metadata["out_of_scope"])
+
+	// Sub-notebooks are resolved and searched too
+	result, filters = search(models.VectorSearchRequest{NotebookIDs: []uuid.UUID{notebook}, IncludeSubNotebooks: true})
+	assert.ElementsMatch(t, []interface{}{notebook.String(), subNotebook.String()},
+		filters["notebook_id"].(map[string]interface{})["$in"])
+	assert.Equal(t, []string{doc.String(), subDoc.String()}, documentsOf(result))
+
+	// Selected documents narrow the search further, and caller filters cannot widen it
+	result, filters = search(models.VectorSearchRequest{
+		NotebookIDs:         []uuid.UUID{notebook},
+		DocumentIDs:         []uuid.UUID{subDoc},
+		IncludeSubNotebooks: true,
+		Options:             models.SearchOptions{Filters: map[string]interface{}{"document_id": otherDoc.String(), "language": "en"}},
+	})
+	assert.Equal(t, subDoc.String(), filters["document_id"])
+	assert.Equal(t, "en", filters["language"])
+	assert.Equal(t, []string{subDoc.String()}, documentsOf(result))
+
+	// An unscoped search sends no filters and keeps the tenant's chunks
+	result, filters = search(models.VectorSearchRequest{})
+	assert.Nil(t, filters)
+	assert.Len(t, result.Chunks, 4, "only the other tenant's chunk is dropped")
+}
+
+func TestRetrieveHybridContextSearchesSubNotebooks(t *testing.T) {
+	notebook, subNotebook := uuid.New(), uuid.New()
+	doc, subDoc := uuid.New(), uuid.New()
+
+	var lastSearch map[string]interface{}
+	deeplake := fakeDeepLake(t, []map[string]interface{}{
+		vectorChunk("tenant-a", notebook, doc),
+		vectorChunk("tenant-a", subNotebook, subDoc),
+	}, &lastSearch)
+	defer deeplake.Close()
+	audimodal := httptest.NewServer(http.NotFoundHandler())
+	defer audimodal.Close()
+
+	svc := &documentContextServiceImpl{
+		deeplakeConfig:  &config.DeepLakeConfig{BaseURL: deeplake.URL},
+		audimodalConfig: &config.AudiModalConfig{BaseURL: audimodal.URL},
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
+		notebooks:       subNotebooks{notebook: {subNotebook}},
+	}
+	result, err := svc.RetrieveHybridContext(context.Background(), "revenue", models.ChunkRetrievalRequest{
+		TenantID:            "tenant-a",
+		NotebookIDs:         []uuid.UUID{notebook},
+		FileIDs:             []uuid.UUID{subDoc},
+		IncludeSubNotebooks: true,
+	}, 0.5, 0.5)
+	require.NoError(t, err)
+	require.Len(t, result.Chunks, 1, "the sub-notebook's document is found by vector search")
+	assert.Equal(t, subDoc.String(), result.Chunks[0].DocumentID)
+}
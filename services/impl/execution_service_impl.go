@@ -3,6 +3,8 @@ package impl
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,12 +29,16 @@ func NewExecutionService(db *gorm.DB, routerService services.RouterService) serv
 func (s *ExecutionServiceImpl) StartExecution(ctx context.Context, req models.StartExecutionRequest, userID uuid.UUID) (*models.AgentExecution, error) {
 	// Create execution record
 	execution := &models.AgentExecution{
-		AgentID:   req.AgentID,
-		UserID:    userID,
-		SessionID: req.SessionID,
-		Status:    models.ExecutionStatusQueued,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		AgentID:    req.AgentID,
+		UserID:     userID,
+		SessionID:  req.SessionID,
+		Experiment: req.Experiment,
+		Variant:    req.Variant,
+		Status:     models.ExecutionStatusQueued,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+
+		ParentExecutionID: req.ParentExecutionID,
 	}
 
 	// Marshal input data
@@ -171,11 +177,14 @@ func (s *ExecutionServiceImpl) CancelExecution(ctx context.Context, id uuid.UUID
 		Where("id = ? AND user_id = ? AND status IN (?)", id, userID, []string{
 			string(models.ExecutionStatusQueued),
 			string(models.ExecutionStatusRunning),
+			string(models.ExecutionStatusAwaitingApproval),
 		}).
 		Updates(map[string]interface{}{
-			"status":       models.ExecutionStatusCancelled,
-			"completed_at": time.Now(),
-			"updated_at":   time.Now(),
+			"status":             models.ExecutionStatusCancelled,
+			"pending_tool_calls": nil,
+			"tool_loop_state":    nil,
+			"completed_at":       time.Now(),
+			"updated_at":         time.Now(),
 		})
 
 	if result.Error != nil {
@@ -189,6 +198,58 @@ func (s *ExecutionServiceImpl) CancelExecution(ctx context.Context, id uuid.UUID
 	return nil
 }
 
+// SuspendExecution saves the tool loop state of an execution whose tool calls need approval
+func (s *ExecutionServiceImpl) SuspendExecution(ctx context.Context, id uuid.UUID, pending []models.PendingToolCall, loopState []byte) error {
+	result := s.db.WithContext(ctx).Model(&models.AgentExecution{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":             models.ExecutionStatusAwaitingApproval,
+			"pending_tool_calls": models.PendingToolCallList(pending),
+			"tool_loop_state":    datatypes.JSON(loopState),
+			"updated_at":         time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to suspend execution: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return services.ErrExecutionNotFound
+	}
+	return nil
+}
+
+// ResumeExecution moves an execution awaiting approval back to running. The status check and
+// update are one statement, so concurrent approve and reject requests cannot both resume it.
+func (s *ExecutionServiceImpl) ResumeExecution(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*models.AgentExecution, error) {
+	var execution models.AgentExecution
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&execution).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, services.ErrExecutionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load execution: %w", err)
+	}
+	if execution.Status != models.ExecutionStatusAwaitingApproval {
+		return nil, services.ErrExecutionNotAwaitingApproval
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.AgentExecution{}).
+		Where("id = ? AND status = ?", id, models.ExecutionStatusAwaitingApproval).
+		Updates(map[string]interface{}{
+			"status":             models.ExecutionStatusRunning,
+			"pending_tool_calls": nil,
+			"tool_loop_state":    nil,
+			"updated_at":         time.Now(),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to resume execution: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, services.ErrExecutionNotAwaitingApproval
+	}
+	execution.Status = models.ExecutionStatusRunning
+	return &execution, nil
+}
+
 func (s *ExecutionServiceImpl) GetExecutionsByAgent(ctx context.Context, agentID uuid.UUID, userID uuid.UUID, limit int) ([]models.AgentExecution, error) {
 	var executions []models.AgentExecution
 	
@@ -208,4 +269,61 @@ func (s *ExecutionServiceImpl) GetExecutionsBySession(ctx context.Context, sessi
 		Find(&executions).Error
 	
 	return executions, err
-}
\ No newline at end of file
+}
+func (s *ExecutionServiceImpl) SetExecutionFeedback(ctx context.Context, agentID uuid.UUID, id uuid.UUID, userID uuid.UUID, feedback models.ExecutionFeedback) error {
+	if err := feedback.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"feedback_rating": feedback.Rating,
+		"feedback_at":     now,
+		"updated_at":      now,
+	}
+	if feedback.Comment != "" {
+		updates["feedback_comment"] = feedback.Comment
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.AgentExecution{}).
+		Where("id = ? AND agent_id = ? AND user_id = ?", id, agentID, userID).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save feedback: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return services.ErrExecutionNotFound
+	}
+	return nil
+}
+
+// GetExperimentResults aggregates an agent's executions per variant of the named experiment
+// and compares each variant against the first one
+func (s *ExecutionServiceImpl) GetExperimentResults(ctx context.Context, agentID uuid.UUID, experiment string, variantOrder []string) ([]models.VariantStats, []models.VariantComparison, error) {
+	var rows []models.VariantStats
+	err := s.db.WithContext(ctx).Model(&models.AgentExecution{}).
+		Select(`variant,
+			COUNT(*) AS executions,
+			COUNT(*) FILTER (WHERE status = ?) AS completed,
+			COUNT(*) FILTER (WHERE status IN ?) AS failed,
+			COALESCE(AVG(total_duration_ms) FILTER (WHERE status = ?), 0) AS avg_latency_ms,
+			COALESCE(STDDEV_SAMP(total_duration_ms) FILTER (WHERE status = ?), 0) AS latency_std_dev_ms,
+			COALESCE(AVG(cost_usd) FILTER (WHERE status = ?), 0) AS avg_cost_usd,
+			COALESCE(STDDEV_SAMP(cost_usd) FILTER (WHERE status = ?), 0) AS cost_std_dev_usd,
+			COALESCE(SUM(cost_usd), 0) AS total_cost_usd,
+			COUNT(feedback_rating) AS feedback_count,
+			COUNT(*) FILTER (WHERE feedback_rating > 0) AS positive_feedback`,
+			models.ExecutionStatusCompleted,
+			[]models.ExecutionStatus{models.ExecutionStatusFailed, models.ExecutionStatusTimeout},
+			models.ExecutionStatusCompleted, models.ExecutionStatusCompleted,
+			models.ExecutionStatusCompleted, models.ExecutionStatusCompleted).
+		Where("agent_id = ? AND experiment = ? AND variant IS NOT NULL AND deleted_at IS NULL", agentID, experiment).
+		Group("variant").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to aggregate experiment results: %w", err)
+	}
+
+	stats := orderVariantStats(rows, variantOrder)
+	return stats, compareVariants(stats), nil
+}
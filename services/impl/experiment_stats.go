@@ -0,0 +1,125 @@
+package impl
+
+import (
+	"math"
+
+	"github.com/tas-agent-builder/models"
+)
+
+// significanceLevel is the p-value below which a difference is reported as significant
+const significanceLevel = 0.05
+
+// minSamplesForSignificance is the smallest group size for which p-values are reported
+const minSamplesForSignificance = 5
+
+// orderVariantStats fills in derived rates and orders rows as the variants are configured.
+// Variants without executions get zero rows; variants no longer configured follow at the end.
+func orderVariantStats(rows []models.VariantStats, order []string) []models.VariantStats {
+	byName := make(map[string]models.VariantStats, len(rows))
+	for _, r := range rows {
+		byName[r.Variant] = r
+	}
+
+	stats := make([]models.VariantStats, 0, len(rows))
+	for _, name := range order {
+		r, ok := byName[name]
+		if !ok {
+			r = models.VariantStats{Variant: name}
+		}
+		delete(byName, name)
+		stats = append(stats, r)
+	}
+	for _, r := range rows {
+		if _, ok := byName[r.Variant]; ok {
+			stats = append(stats, r)
+		}
+	}
+
+	for i := range stats {
+		if finished := stats[i].Completed + stats[i].Failed; finished > 0 {
+			stats[i].SuccessRate = float64(stats[i].Completed) / float64(finished)
+		}
+		if stats[i].FeedbackCount > 0 {
+			stats[i].PositiveRate = float64(stats[i].PositiveFeedback) / float64(stats[i].FeedbackCount)
+		}
+	}
+	return stats
+}
+
+// compareVariants compares every variant against the first (control) variant
+func compareVariants(stats []models.VariantStats) []models.VariantComparison {
+	if len(stats) < 2 {
+		return []models.VariantComparison{}
+	}
+
+	control := stats[0]
+	comparisons := make([]models.VariantComparison, 0, len(stats)-1)
+	for _, v := range stats[1:] {
+		c := models.VariantComparison{
+			Variant:          v.Variant,
+			Control:          control.Variant,
+			SuccessRateDiff:  v.SuccessRate - control.SuccessRate,
+			LatencyDiffMs:    v.AvgLatencyMs - control.AvgLatencyMs,
+			CostDiffUSD:      v.AvgCostUSD - control.AvgCostUSD,
+			PositiveRateDiff: v.PositiveRate - control.PositiveRate,
+		}
+
+		c.SuccessRatePValue = twoProportionPValue(
+			v.Completed, v.Completed+v.Failed,
+			control.Completed, control.Completed+control.Failed)
+		c.PositiveRatePValue = twoProportionPValue(
+			v.PositiveFeedback, v.FeedbackCount,
+			control.PositiveFeedback, control.FeedbackCount)
+		c.LatencyPValue = welchPValue(
+			v.AvgLatencyMs, v.LatencyStdDevMs, v.Completed,
+			control.AvgLatencyMs, control.LatencyStdDevMs, control.Completed)
+		c.CostPValue = welchPValue(
+			v.AvgCostUSD, v.CostStdDevUSD, v.Completed,
+			control.AvgCostUSD, control.CostStdDevUSD, control.Completed)
+
+		for _, p := range []*float64{c.SuccessRatePValue, c.PositiveRatePValue, c.LatencyPValue, c.CostPValue} {
+			if p != nil && *p < significanceLevel {
+				c.Significant = true
+			}
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
+
+// twoProportionPValue returns the two-sided p-value of a pooled two-proportion z-test
+func twoProportionPValue(successA, totalA, successB, totalB int64) *float64 {
+	if totalA < minSamplesForSignificance || totalB < minSamplesForSignificance {
+		return nil
+	}
+
+	pA := float64(successA) / float64(totalA)
+	pB := float64(successB) / float64(totalB)
+	pooled := float64(successA+successB) / float64(totalA+totalB)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(totalA) + 1/float64(totalB)))
+	return zPValue(pA-pB, se)
+}
+
+// welchPValue returns the two-sided p-value for a difference of means using Welch's standard
+// error and a normal approximation, which is adequate at the sample sizes experiments reach
+func welchPValue(meanA, sdA float64, nA int64, meanB, sdB float64, nB int64) *float64 {
+	if nA < minSamplesForSignificance || nB < minSamplesForSignificance {
+		return nil
+	}
+
+	se := math.Sqrt(sdA*sdA/float64(nA) + sdB*sdB/float64(nB))
+	return zPValue(meanA-meanB, se)
+}
+
+func zPValue(diff, se float64) *float64 {
+	var p float64
+	switch {
+	case se == 0 && diff == 0:
+		p = 1
+	case se == 0:
+		p = 0
+	default:
+		p = math.Erfc(math.Abs(diff/se) / math.Sqrt2)
+	}
+	return &p
+}
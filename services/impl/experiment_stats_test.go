@@ -0,0 +1,57 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+)
+
+func TestOrderVariantStats(t *testing.T) {
+	rows := []models.VariantStats{
+		{Variant: "retired", Executions: 2, Completed: 2},
+		{Variant: "b", Executions: 10, Completed: 6, Failed: 4, FeedbackCount: 4, PositiveFeedback: 3},
+	}
+
+	stats := orderVariantStats(rows, []string{"a", "b"})
+	require.Len(t, stats, 3)
+	assert.Equal(t, "a", stats[0].Variant)
+	assert.Zero(t, stats[0].Executions)
+	assert.Equal(t, "b", stats[1].Variant)
+	assert.InDelta(t, 0.6, stats[1].SuccessRate, 1e-9)
+	assert.InDelta(t, 0.75, stats[1].PositiveRate, 1e-9)
+	assert.Equal(t, "retired", stats[2].Variant)
+}
+
+func TestCompareVariants(t *testing.T) {
+	stats := orderVariantStats([]models.VariantStats{
+		{Variant: "control", Completed: 950, Failed: 50, AvgLatencyMs: 1200, LatencyStdDevMs: 300, AvgCostUSD: 0.010, CostStdDevUSD: 0.002},
+		{Variant: "clear-win", Completed: 800, Failed: 200, AvgLatencyMs: 800, LatencyStdDevMs: 300, AvgCostUSD: 0.010, CostStdDevUSD: 0.002},
+		{Variant: "same", Completed: 949, Failed: 51, AvgLatencyMs: 1201, LatencyStdDevMs: 300, AvgCostUSD: 0.010, CostStdDevUSD: 0.002},
+		{Variant: "tiny", Completed: 2, Failed: 1},
+	}, []string{"control", "clear-win", "same", "tiny"})
+
+	comparisons := compareVariants(stats)
+	require.Len(t, comparisons, 3)
+
+	win := comparisons[0]
+	assert.Equal(t, "control", win.Control)
+	assert.InDelta(t, -0.15, win.SuccessRateDiff, 1e-9)
+	assert.InDelta(t, -400, win.LatencyDiffMs, 1e-9)
+	require.NotNil(t, win.SuccessRatePValue)
+	assert.Less(t, *win.SuccessRatePValue, 0.001)
+	require.NotNil(t, win.LatencyPValue)
+	assert.Less(t, *win.LatencyPValue, 0.001)
+	assert.Nil(t, win.PositiveRatePValue, "no feedback collected")
+	assert.True(t, win.Significant)
+
+	same := comparisons[1]
+	assert.Greater(t, *same.SuccessRatePValue, 0.5)
+	assert.Greater(t, *same.LatencyPValue, 0.5)
+	assert.False(t, same.Significant)
+
+	tiny := comparisons[2]
+	assert.Nil(t, tiny.SuccessRatePValue)
+	assert.False(t, tiny.Significant)
+}
@@ -0,0 +1,179 @@
+package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services/jsonpath"
+)
+
+const (
+	// defaultFunctionTimeout bounds a webhook call when the skill sets no timeout
+	defaultFunctionTimeout = 30 * time.Second
+
+	// maxFunctionResponseBytes bounds a webhook response read into memory
+	maxFunctionResponseBytes = 4 * 1024 * 1024
+)
+
+// functionToolList renders a function skill's tool definitions; they live on the skill, so
+// nothing is fetched or cached
+func functionToolList(skill *models.Skill) *models.SkillToolList {
+	data, _ := json.Marshal(skill.FunctionTools)
+	list := &models.SkillToolList{
+		SkillID:   skill.ID,
+		Tools:     make([]models.SkillTool, 0, len(skill.FunctionTools)),
+		ETag:      contentETag(data),
+		FetchedAt: skill.UpdatedAt,
+	}
+	for _, t := range skill.FunctionTools {
+		list.Tools = append(list.Tools, models.SkillTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.Parameters,
+		})
+	}
+	return list
+}
+
+// callFunctionTool performs the HTTP request behind a function skill's tool and maps the
+// response to text
+func (s *skillToolServiceImpl) callFunctionTool(ctx context.Context, client *http.Client, skill *models.Skill, name string, args map[string]interface{}) (string, error) {
+	var tool *models.FunctionTool
+	for i := range skill.FunctionTools {
+		if skill.FunctionTools[i].Name == name {
+			tool = &skill.FunctionTools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return "", &nonRetryableError{fmt.Errorf("skill %q has no tool %q", skill.Name, name)}
+	}
+
+	method := strings.ToUpper(tool.Method)
+	rawURL, usedInURL := tool.RenderURL(args)
+	headers, usedInHeaders := tool.RenderHeaders(args)
+
+	// Arguments not placed by the templates go in the query or the body
+	rest := make(map[string]interface{})
+	for k, v := range args {
+		if !usedInURL[k] && !usedInHeaders[k] {
+			rest[k] = v
+		}
+	}
+
+	var body io.Reader
+	switch method {
+	case "GET", "DELETE":
+		if len(rest) > 0 {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return "", &nonRetryableError{fmt.Errorf("invalid URL: %w", err)}
+			}
+			q := u.Query()
+			for k, v := range rest {
+				q.Set(k, queryValue(v))
+			}
+			u.RawQuery = q.Encode()
+			rawURL = u.String()
+		}
+	default:
+		encoded, err := json.Marshal(rest)
+		if err != nil {
+			return "", &nonRetryableError{fmt.Errorf("failed to encode request body: %w", err)}
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return "", &nonRetryableError{fmt.Errorf("failed to create request: %w", err)}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFunctionResponseBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(data) > maxFunctionResponseBytes {
+		return "", &nonRetryableError{fmt.Errorf("response exceeds %d bytes", maxFunctionResponseBytes)}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncateString(string(data), 500))
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return "", &nonRetryableError{err}
+		}
+		return "", err
+	}
+
+	return mapFunctionResponse(data, tool.ResponsePath)
+}
+
+// mapFunctionResponse selects the text returned to the model. Without a response path the
+// body is returned as is; a single string match is returned bare and anything else as JSON.
+func mapFunctionResponse(data []byte, responsePath string) (string, error) {
+	if responsePath == "" {
+		return string(data), nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return "", &nonRetryableError{fmt.Errorf("response is not JSON, cannot apply %s: %w", responsePath, err)}
+	}
+	matches, err := jsonpath.Query(decoded, responsePath)
+	if err != nil {
+		return "", &nonRetryableError{err}
+	}
+
+	var selected interface{} = matches
+	switch len(matches) {
+	case 0:
+		return "", &nonRetryableError{fmt.Errorf("response has no value at %s", responsePath)}
+	case 1:
+		if str, ok := matches[0].(string); ok {
+			return str, nil
+		}
+		selected = matches[0]
+	}
+
+	out, err := json.Marshal(selected)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode selected value: %w", err)
+	}
+	return string(out), nil
+}
+
+func queryValue(v interface{}) string {
+	if str, ok := v.(string); ok {
+		return str
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,219 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/credentials"
+	"github.com/tas-agent-builder/services/mcp"
+)
+
+func TestFunctionSkillCallsWebhook(t *testing.T) {
+	var lastBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/cities/New%20York/weather":
+			assert.Equal(t, "metric", r.URL.Query().Get("units"))
+			assert.Equal(t, "Bearer k1", r.Header.Get("Authorization"))
+			fmt.Fprint(w, `{"current":{"summary":"sunny","temp":21}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/tickets":
+			data, _ := io.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(data, &lastBody))
+			fmt.Fprint(w, `{"id":42,"status":"open"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	skill := &models.Skill{
+		ID:   uuid.New(),
+		Name: "helpdesk",
+		Type: models.SkillTypeFunction,
+		FunctionTools: models.FunctionTools{
+			{
+				Name:         "weather",
+				Method:       "GET",
+				URL:          server.URL + "/cities/{{city}}/weather",
+				Headers:      map[string]string{"Authorization": "Bearer {{token}}"},
+				ResponsePath: "$.current.summary",
+			},
+			{
+				Name:   "open_ticket",
+				Method: "POST",
+				URL:    server.URL + "/tickets",
+			},
+			{
+				Name:   "missing",
+				Method: "GET",
+				URL:    server.URL + "/nowhere",
+			},
+		},
+	}
+	require.NoError(t, skill.FunctionTools.Validate())
+
+	clients := mcp.NewManager(mcp.Implementation{Name: "test", Version: "0"}, 5*time.Second)
+	defer clients.Close()
+	svc := NewSkillToolService(nil, nil, clients, time.Minute)
+	ctx := context.Background()
+
+	list, err := svc.ListTools(ctx, skill, false)
+	require.NoError(t, err)
+	assert.Len(t, list.Tools, 3)
+	assert.NotEmpty(t, list.ETag)
+
+	result, err := svc.CallTool(ctx, skill, "weather", map[string]interface{}{
+		"city": "New York", "token": "k1", "units": "metric",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sunny", result)
+
+	result, err = svc.CallTool(ctx, skill, "open_ticket", map[string]interface{}{"subject": "printer", "priority": 2})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":42,"status":"open"}`, result)
+	assert.Equal(t, map[string]interface{}{"subject": "printer", "priority": float64(2)}, lastBody)
+
+	_, err = svc.CallTool(ctx, skill, "missing", nil)
+	assert.ErrorContains(t, err, "HTTP 404")
+}
+
+func TestFunctionSkillRetriesServerErrorsOnly(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	skill := &models.Skill{
+		Name:           "flaky",
+		Type:           models.SkillTypeFunction,
+		ToolMaxRetries: 2,
+		FunctionTools: models.FunctionTools{
+			{Name: "bad", Method: "GET", URL: server.URL + "/bad"},
+			{Name: "down", Method: "GET", URL: server.URL + "/down"},
+		},
+	}
+	clients := mcp.NewManager(mcp.Implementation{Name: "test", Version: "0"}, 5*time.Second)
+	defer clients.Close()
+	svc := NewSkillToolService(nil, nil, clients, time.Minute)
+
+	_, err := svc.CallTool(context.Background(), skill, "bad", nil)
+	assert.ErrorContains(t, err, "HTTP 400")
+	assert.Equal(t, int32(1), calls.Load(), "client errors are not retried")
+
+	calls.Store(0)
+	_, err = svc.CallTool(context.Background(), skill, "down", nil)
+	assert.ErrorContains(t, err, "HTTP 502")
+	assert.Equal(t, int32(3), calls.Load(), "server errors are retried")
+}
+
+func TestFunctionSkillRetriesTimedOutAttempts(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// The first attempt outlasts the tool timeout
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	skill := &models.Skill{
+		Name:               "slow",
+		Type:               models.SkillTypeFunction,
+		ToolTimeoutSeconds: 1,
+		ToolMaxRetries:     1,
+		FunctionTools:      models.FunctionTools{{Name: "render", Method: "GET", URL: server.URL}},
+	}
+	clients := mcp.NewManager(mcp.Implementation{Name: "test", Version: "0"}, 5*time.Second)
+	defer clients.Close()
+	svc := NewSkillToolService(nil, nil, clients, time.Minute)
+
+	result, err := svc.CallTool(context.Background(), skill, "render", nil)
+	require.NoError(t, err)
+	assert.Contains(t, result, "ok")
+	assert.Equal(t, int32(2), calls.Load(), "the timed out attempt is retried with a fresh timeout")
+}
+
+// stubCredentials renders every skill's auth with one secret per tenant
+type stubCredentials struct {
+	services.CredentialService
+	secrets map[string]string
+}
+
+func (s *stubCredentials) AuthForSkill(ctx context.Context, skill *models.Skill, caller services.Caller) (*credentials.Auth, error) {
+	secret, ok := s.secrets[caller.TenantID]
+	if !ok {
+		return nil, services.ErrCredentialNotFound
+	}
+	return &credentials.Auth{Headers: map[string]string{"Authorization": "Bearer " + secret}, Query: map[string]string{"key": secret}}, nil
+}
+
+func TestFunctionSkillUsesCallerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s", r.Header.Get("Authorization"), r.URL.Query().Get("key"))
+	}))
+	defer server.Close()
+
+	skill := &models.Skill{
+		Name:          "crm",
+		Type:          models.SkillTypeFunction,
+		AuthHeaders:   models.CredentialTemplates{"Authorization": "Bearer {{credential:crm}}"},
+		FunctionTools: models.FunctionTools{{Name: "whoami", Method: "GET", URL: server.URL + "/me"}},
+	}
+	clients := mcp.NewManager(mcp.Implementation{Name: "test", Version: "0"}, 5*time.Second)
+	defer clients.Close()
+	svc := NewSkillToolService(nil, &stubCredentials{secrets: map[string]string{"t1": "s1"}}, clients, time.Minute)
+
+	ctx := services.WithCaller(context.Background(), services.Caller{TenantID: "t1", UserID: uuid.New()})
+	result, err := svc.CallTool(ctx, skill, "whoami", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s1|s1", result)
+
+	_, err = svc.CallTool(context.Background(), skill, "whoami", nil)
+	assert.ErrorContains(t, err, "no tenant or user")
+
+	_, err = svc.CallTool(services.WithCaller(context.Background(), services.Caller{TenantID: "t2"}), skill, "whoami", nil)
+	assert.ErrorIs(t, err, services.ErrCredentialNotFound)
+}
+
+func TestFunctionToolsValidate(t *testing.T) {
+	valid := models.FunctionTool{Name: "lookup", Method: "get", URL: "https://api.example.com/items/{{id}}?q={{q}}"}
+	assert.NoError(t, models.FunctionTools{valid}.Validate())
+
+	hostTemplate := valid
+	hostTemplate.URL = "https://{{host}}/items"
+	assert.Error(t, models.FunctionTools{hostTemplate}.Validate(), "placeholders may not choose the host")
+
+	badMethod := valid
+	badMethod.Method = "TRACE"
+	assert.Error(t, models.FunctionTools{badMethod}.Validate())
+
+	assert.Error(t, models.FunctionTools{valid, valid}.Validate(), "duplicate names")
+	assert.Error(t, models.FunctionTools{}.Validate())
+
+	rendered, used := valid.RenderURL(map[string]interface{}{"id": "..", "q": "a&b"})
+	assert.Equal(t, "https://api.example.com/items/%2E%2E?q=a%26b", rendered)
+	assert.True(t, used["id"] && used["q"])
+}
@@ -0,0 +1,434 @@
+package impl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// anthropicDefaultMaxTokens is used when the agent sets no limit; the Messages API requires one
+const anthropicDefaultMaxTokens = 4096
+
+// anthropicContextWindow is the context window of current Claude models
+const anthropicContextWindow = 200000
+
+// anthropicBackend talks to the Anthropic Messages API
+type anthropicBackend struct {
+	baseURL      string
+	apiKey       string
+	version      string
+	models       map[string]config.BackendModelConfig
+	httpClient   *http.Client
+	streamClient *http.Client
+}
+
+// NewAnthropicBackend creates a direct backend for the Anthropic Messages API
+func NewAnthropicBackend(cfg config.AnthropicBackendConfig, timeout time.Duration) services.LLMBackend {
+	httpClient, streamClient := newBackendClients(timeout)
+	return &anthropicBackend{
+		baseURL:      strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:       cfg.APIKey,
+		version:      cfg.Version,
+		models:       cfg.Models,
+		httpClient:   httpClient,
+		streamClient: streamClient,
+	}
+}
+
+type anthropicRequest struct {
+	Model         string               `json:"model"`
+	System        string               `json:"system,omitempty"`
+	Messages      []anthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	TopP          *float64             `json:"top_p,omitempty"`
+	TopK          *int                 `json:"top_k,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a content block of any type: text, image, document, tool_use or tool_result
+type anthropicBlock struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"` // base64 or url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"` // auto, any, tool or none
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (b *anthropicBackend) Name() string { return "anthropic" }
+
+func (b *anthropicBackend) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         b.apiKey,
+		"anthropic-version": b.version,
+	}
+}
+
+func (b *anthropicBackend) Complete(ctx context.Context, req services.LLMBackendRequest) (*services.LLMBackendResponse, error) {
+	system, messages := buildAnthropicMessages(req.Messages)
+
+	body := anthropicRequest{
+		Model:         req.Config.Model,
+		System:        system,
+		Messages:      messages,
+		MaxTokens:     anthropicDefaultMaxTokens,
+		Temperature:   req.Config.Temperature,
+		TopP:          req.Config.TopP,
+		TopK:          req.Config.TopK,
+		StopSequences: req.Config.Stop,
+		Stream:        req.Stream,
+	}
+	if req.Config.MaxTokens != nil && *req.Config.MaxTokens > 0 {
+		body.MaxTokens = *req.Config.MaxTokens
+	}
+	if len(req.Tools) > 0 {
+		for _, t := range req.Tools {
+			body.Tools = append(body.Tools, anthropicTool{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				InputSchema: t.Function.Parameters,
+			})
+		}
+		body.ToolChoice = anthropicToolChoiceFor(req.ToolChoice)
+	}
+
+	client := b.httpClient
+	if req.Stream {
+		client = b.streamClient
+	}
+
+	resp, err := postBackendJSON(ctx, client, b.Name(), b.baseURL+"/v1/messages", b.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp *anthropicResponse
+	if req.Stream {
+		apiResp, err = readAnthropicStream(resp.Body)
+	} else {
+		apiResp = &anthropicResponse{}
+		err = json.NewDecoder(resp.Body).Decode(apiResp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	result := &services.LLMBackendResponse{
+		ID:               apiResp.ID,
+		Model:            apiResp.Model,
+		FinishReason:     anthropicFinishReason(apiResp.StopReason),
+		PromptTokens:     apiResp.Usage.InputTokens,
+		CompletionTokens: apiResp.Usage.OutputTokens,
+	}
+	var content strings.Builder
+	for _, block := range apiResp.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			result.ToolCalls = append(result.ToolCalls, services.ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: services.ToolFunction{Name: block.Name, Arguments: args},
+			})
+		}
+	}
+	result.Content = content.String()
+	return result, nil
+}
+
+func (b *anthropicBackend) ListModels(ctx context.Context) ([]services.Model, error) {
+	var resp struct {
+		Data []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+	}
+	if err := getBackendJSON(ctx, b.httpClient, b.Name(), b.baseURL+"/v1/models", b.headers(), &resp); err != nil {
+		return nil, err
+	}
+
+	result := make([]services.Model, 0, len(resp.Data))
+	for _, m := range resp.Data {
+		result = append(result, services.Model{
+			Name:        m.ID,
+			DisplayName: m.DisplayName,
+			Provider:    b.Name(),
+			Features:    []string{"chat", "functions", "vision"},
+		})
+	}
+	return result, nil
+}
+
+// ModelCapabilities describes a Claude model. The models endpoint reports no limits, so the
+// window every current model shares is assumed unless the model is configured.
+func (b *anthropicBackend) ModelCapabilities(ctx context.Context, model string) (*services.ModelCapabilities, error) {
+	return backendModelCapabilities(b.Name(), services.ModelCapabilities{
+		Name:            model,
+		ContextWindow:   anthropicContextWindow,
+		MaxOutputTokens: anthropicDefaultMaxTokens,
+		Features:        []string{"chat", "streaming", "functions", "vision"},
+	}, b.models), nil
+}
+
+// buildAnthropicMessages moves system messages to the system prompt, converts tool calls to
+// tool_use blocks and tool results to user tool_result blocks, and merges consecutive
+// messages with the same role
+func buildAnthropicMessages(messages []services.Message) (string, []anthropicMessage) {
+	var system []string
+	var result []anthropicMessage
+
+	for _, msg := range messages {
+		role := msg.Role
+		var blocks []anthropicBlock
+
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+			continue
+		case "tool":
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		case "assistant":
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+		default:
+			role = "user"
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			blocks = append(blocks, anthropicContentBlocks(msg.Parts)...)
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, blocks...)
+			continue
+		}
+		result = append(result, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	return strings.Join(system, "\n\n"), result
+}
+
+// anthropicContentBlocks converts multimodal parts to image, document and text blocks
+func anthropicContentBlocks(parts []models.ContentPart) []anthropicBlock {
+	var blocks []anthropicBlock
+	for _, p := range parts {
+		switch {
+		case p.Type == models.ContentPartText:
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: p.Text})
+		case p.IsImage() && p.Data != "":
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: &anthropicSource{Type: "base64", MediaType: p.MediaType, Data: p.Data}})
+		case p.IsImage() && p.URL != "":
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: &anthropicSource{Type: "url", URL: p.URL}})
+		case p.Type == models.ContentPartFile && p.Data != "" && p.MediaType == "application/pdf":
+			blocks = append(blocks, anthropicBlock{Type: "document", Source: &anthropicSource{Type: "base64", MediaType: p.MediaType, Data: p.Data}})
+		default:
+			log.Printf("[LLM-BACKEND] Anthropic: skipping unsupported %s content part (%s)", p.Type, p.MediaType)
+		}
+	}
+	return blocks
+}
+
+// anthropicToolChoiceFor maps OpenAI tool_choice values to Anthropic's
+func anthropicToolChoiceFor(choice string) *anthropicToolChoice {
+	switch choice {
+	case "", "auto":
+		return &anthropicToolChoice{Type: "auto"}
+	case "required":
+		return &anthropicToolChoice{Type: "any"}
+	case "none":
+		return &anthropicToolChoice{Type: "none"}
+	default:
+		return &anthropicToolChoice{Type: "tool", Name: choice}
+	}
+}
+
+// anthropicFinishReason maps Anthropic stop reasons to OpenAI finish reasons
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "":
+		return ""
+	default:
+		return "stop"
+	}
+}
+
+// anthropicStreamEvent covers the fields used from every Messages API stream event
+type anthropicStreamEvent struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *anthropicResponse `json:"message,omitempty"`
+	ContentBlock *anthropicBlock    `json:"content_block,omitempty"`
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// readAnthropicStream accumulates a Messages API SSE stream into a single response
+func readAnthropicStream(body io.Reader) (*anthropicResponse, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	resp := &anthropicResponse{}
+	blocks := make(map[int]*anthropicBlock)
+	inputs := make(map[int]*strings.Builder)
+	gotEvent := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			log.Printf("[STREAM] Failed to parse Anthropic event: %v (data: %.100s)", err, line)
+			continue
+		}
+		gotEvent = true
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				resp.ID = event.Message.ID
+				resp.Model = event.Message.Model
+				resp.Usage.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_start":
+			if event.ContentBlock != nil {
+				block := *event.ContentBlock
+				block.Input = nil
+				blocks[event.Index] = &block
+				inputs[event.Index] = &strings.Builder{}
+			}
+		case "content_block_delta":
+			block, ok := blocks[event.Index]
+			if !ok || event.Delta == nil {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				block.Text += event.Delta.Text
+			case "input_json_delta":
+				inputs[event.Index].WriteString(event.Delta.PartialJSON)
+			}
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				resp.StopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				resp.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("anthropic stream error (%s): %s", event.Error.Type, event.Error.Message)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading SSE stream: %w", err)
+	}
+	if !gotEvent {
+		return nil, fmt.Errorf("empty streaming response")
+	}
+
+	indexes := make([]int, 0, len(blocks))
+	for i := range blocks {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		block := blocks[i]
+		if block.Type == "tool_use" {
+			block.Input = json.RawMessage(inputs[i].String())
+		}
+		resp.Content = append(resp.Content, *block)
+	}
+
+	return resp, nil
+}
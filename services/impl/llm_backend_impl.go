@@ -0,0 +1,396 @@
+package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
+)
+
+// backendRouterServiceImpl implements RouterService on top of the TAS LLM router and any
+// configured direct backends, using the backend each agent selects. Agents on a direct backend
+// take their model capabilities, and so their pricing, from it rather than the router's catalog.
+type backendRouterServiceImpl struct {
+	router         services.RouterService
+	backends       map[string]services.LLMBackend
+	maxRetries     int
+	defaultBackend string // Used by agents that select no backend; empty for the router
+}
+
+// NewLLMBackendService wraps the router service with the direct backends enabled in cfg.
+// Agents use the backend their llm_config.backend names, or else cfg.Backend.
+func NewLLMBackendService(cfg *config.LLMConfig, router services.RouterService) services.RouterService {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+
+	backends := make(map[string]services.LLMBackend)
+	if cfg.Enabled("openai") {
+		backends["openai"] = NewOpenAIBackend(cfg.OpenAI, timeout)
+	}
+	if cfg.Enabled("anthropic") {
+		backends["anthropic"] = NewAnthropicBackend(cfg.Anthropic, timeout)
+	}
+	if cfg.Enabled("ollama") {
+		backends["ollama"] = NewOllamaBackend(cfg.Ollama, timeout)
+	}
+
+	for name := range backends {
+		log.Printf("[LLM-BACKEND] Direct backend enabled: %s", name)
+	}
+
+	if cfg.Backend != "" && cfg.Backend != models.LLMBackendRouter {
+		log.Printf("[LLM-BACKEND] Default backend for agents that select none: %s", cfg.Backend)
+	}
+
+	return NewBackendRouterService(router, backends, cfg.MaxRetries, cfg.Backend)
+}
+
+// NewBackendRouterService creates a RouterService over explicit backends, keyed by the names
+// agents select them with. Agents that select none use defaultBackend, or the router if it is
+// empty.
+func NewBackendRouterService(router services.RouterService, backends map[string]services.LLMBackend, maxRetries int, defaultBackend string) services.RouterService {
+	return &backendRouterServiceImpl{
+		router:         router,
+		backends:       backends,
+		maxRetries:     maxRetries,
+		defaultBackend: defaultBackend,
+	}
+}
+
+// backendFor returns the direct backend an agent selects, falling back to the default backend,
+// or nil for the router. A backend that is not configured is an error rather than a silent
+// fallback to the router.
+func (s *backendRouterServiceImpl) backendFor(cfg models.AgentLLMConfig) (services.LLMBackend, error) {
+	name := cfg.Backend
+	if name == "" {
+		name = s.defaultBackend
+	}
+	if name == "" || name == models.LLMBackendRouter {
+		return nil, nil
+	}
+	b, ok := s.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", services.ErrUnknownLLMBackend, name)
+	}
+	return b, nil
+}
+
+func (s *backendRouterServiceImpl) SendRequest(ctx context.Context, agentConfig models.AgentLLMConfig, messages []services.Message, userID uuid.UUID) (*services.RouterResponse, error) {
+	backend, err := s.backendFor(agentConfig)
+	if err != nil {
+		return nil, err
+	}
+	if backend == nil {
+		return s.router.SendRequest(ctx, agentConfig, messages, userID)
+	}
+
+	return s.complete(ctx, backend, services.LLMBackendRequest{
+		Config:   agentConfig,
+		Messages: messages,
+		Stream:   getStreaming(agentConfig),
+	})
+}
+
+func (s *backendRouterServiceImpl) SendRequestWithTools(ctx context.Context, agentConfig models.AgentLLMConfig, messages []services.Message, tools []services.ToolDefinition, toolChoice string, userID uuid.UUID) (*services.RouterResponse, error) {
+	backend, err := s.backendFor(agentConfig)
+	if err != nil {
+		return nil, err
+	}
+	if backend == nil {
+		return s.router.SendRequestWithTools(ctx, agentConfig, messages, tools, toolChoice, userID)
+	}
+
+	return s.complete(ctx, backend, services.LLMBackendRequest{
+		Config:     agentConfig,
+		Messages:   messages,
+		Tools:      tools,
+		ToolChoice: toolChoice,
+		Stream:     getStreaming(agentConfig),
+	})
+}
+
+// complete sends a request to a direct backend with retries and converts the result to a
+// RouterResponse carrying the same metadata keys as router responses
+func (s *backendRouterServiceImpl) complete(ctx context.Context, backend services.LLMBackend, req services.LLMBackendRequest) (*services.RouterResponse, error) {
+	started := time.Now()
+
+	// The agent's retry policy applies to direct backends as it does to the router
+	maxRetries := s.maxRetries
+	if req.Config.RetryConfig != nil && req.Config.RetryConfig.MaxAttempts > 0 {
+		maxRetries = req.Config.RetryConfig.MaxAttempts - 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+
+		attemptStart := time.Now()
+		result, err := backend.Complete(ctx, req)
+		if err != nil {
+			lastErr = err
+			var backendErr *services.LLMBackendError
+			if errors.As(err, &backendErr) && !backendErr.Retryable() {
+				return nil, err
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Printf("[LLM-BACKEND] %s attempt %d failed: %v", backend.Name(), attempt+1, err)
+			continue
+		}
+
+		latency := time.Since(attemptStart)
+		usage := RouterUsage{
+			PromptTokens:     result.PromptTokens,
+			CompletionTokens: result.CompletionTokens,
+			TotalTokens:      result.PromptTokens + result.CompletionTokens,
+		}
+		routingReason := []string{fmt.Sprintf("direct backend: %s", backend.Name())}
+
+		response := &services.RouterResponse{
+			Content:         result.Content,
+			Provider:        backend.Name(),
+			Model:           result.Model,
+			RoutingStrategy: "direct",
+			TokenUsage:      usage.TotalTokens,
+			CostUSD:         backendCostUSD(ctx, backend, req.Config.Model, usage, result.Model),
+			ResponseTimeMs:  int(time.Since(started).Milliseconds()),
+			ToolCalls:       result.ToolCalls,
+			FinishReason:    result.FinishReason,
+			Metadata: map[string]interface{}{
+				"request_id":        result.ID,
+				"finish_reason":     result.FinishReason,
+				"prompt_tokens":     usage.PromptTokens,
+				"completion_tokens": usage.CompletionTokens,
+				"created":           started.Unix(),
+				"router_metadata":   map[string]interface{}{"backend": backend.Name()},
+				"retry_attempts":    attempt,
+				"fallback_used":     false,
+				"failed_providers":  []string(nil),
+				"total_retry_time":  int(attemptStart.Sub(started).Milliseconds()),
+				"provider_latency":  int(latency.Milliseconds()),
+				"routing_reason":    routingReason,
+			},
+		}
+
+		log.Printf("[LLM-BACKEND] Completed %s response: model=%s, content_len=%d, tool_calls=%d, tokens=%d, time=%dms",
+			backend.Name(), result.Model, len(result.Content), len(result.ToolCalls), usage.TotalTokens, response.ResponseTimeMs)
+
+		return response, nil
+	}
+
+	return nil, fmt.Errorf("%s failed after %d retries: %w", backend.Name(), maxRetries, lastErr)
+}
+
+func (s *backendRouterServiceImpl) ValidateConfig(ctx context.Context, cfg models.AgentLLMConfig) error {
+	backend, err := s.backendFor(cfg)
+	if err != nil {
+		return err
+	}
+	if backend == nil {
+		return s.router.ValidateConfig(ctx, cfg)
+	}
+
+	if cfg.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	available, err := backend.ListModels(ctx)
+	if err != nil {
+		// The backend may not expose a model list; let the first request surface problems
+		log.Printf("[LLM-BACKEND] Could not list %s models for validation: %v", backend.Name(), err)
+		return nil
+	}
+	for _, m := range available {
+		if m.Name == cfg.Model {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s not available from %s", cfg.Model, backend.Name())
+}
+
+// GetAvailableProviders lists router providers followed by direct backends, which carry the
+// backend name agents select them with
+func (s *backendRouterServiceImpl) GetAvailableProviders(ctx context.Context) ([]services.Provider, error) {
+	providers, err := s.router.GetAvailableProviders(ctx)
+	if err != nil {
+		if len(s.backends) == 0 {
+			return nil, err
+		}
+		log.Printf("[LLM-BACKEND] Router providers unavailable: %v", err)
+	}
+
+	for _, name := range []string{"openai", "anthropic", "ollama"} {
+		backend, ok := s.backends[name]
+		if !ok {
+			continue
+		}
+		provider := services.Provider{
+			Name:        name,
+			DisplayName: capitalizeFirst(name) + " (direct)",
+			Features:    []string{"chat", "streaming", "functions"},
+			Backend:     name,
+		}
+		if list, err := backend.ListModels(ctx); err == nil {
+			for _, m := range list {
+				provider.Models = append(provider.Models, m.Name)
+			}
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// GetProviderModels lists a router provider's models; direct backends list theirs in
+// GetAvailableProviders
+func (s *backendRouterServiceImpl) GetProviderModels(ctx context.Context, provider string) ([]services.Model, error) {
+	return s.router.GetProviderModels(ctx, provider)
+}
+
+// GetModelCapabilities asks the agent's direct backend about its model, or the router's catalog
+// for routed agents
+func (s *backendRouterServiceImpl) GetModelCapabilities(ctx context.Context, agentConfig models.AgentLLMConfig) (*services.ModelCapabilities, error) {
+	backend, err := s.backendFor(agentConfig)
+	if err != nil {
+		return nil, err
+	}
+	if backend == nil {
+		return s.router.GetModelCapabilities(ctx, agentConfig)
+	}
+	return backend.ModelCapabilities(ctx, agentConfig.Model)
+}
+
+// backendCostUSD prices a direct backend response for model with the capabilities the execution
+// looked up, or else the backend's, falling back to the static table for the model served
+func backendCostUSD(ctx context.Context, backend services.LLMBackend, model string, usage RouterUsage, served string) float64 {
+	caps, ok := services.ModelCapabilitiesFromContext(ctx, model)
+	if !ok {
+		caps, _ = backend.ModelCapabilities(ctx, model)
+	}
+	if caps != nil && (caps.InputCostPer1K > 0 || caps.OutputCostPer1K > 0) {
+		return float64(usage.PromptTokens)*caps.InputCostPer1K/1000 +
+			float64(usage.CompletionTokens)*caps.OutputCostPer1K/1000
+	}
+	return calculateCostUSD(usage, served)
+}
+
+// backendModelCapabilities completes what a backend reports about a model with the model's
+// configured entry, whose set fields win, and derives the tokenizer from the model name when
+// neither names one
+func backendModelCapabilities(backend string, caps services.ModelCapabilities, configured map[string]config.BackendModelConfig) *services.ModelCapabilities {
+	if m, ok := configured[caps.Name]; ok {
+		if m.ContextWindow > 0 {
+			caps.ContextWindow = m.ContextWindow
+		}
+		if m.MaxOutputTokens > 0 {
+			caps.MaxOutputTokens = m.MaxOutputTokens
+		}
+		if m.InputCostPer1K > 0 || m.OutputCostPer1K > 0 {
+			caps.InputCostPer1K, caps.OutputCostPer1K = m.InputCostPer1K, m.OutputCostPer1K
+		}
+		if m.Tokenizer != "" {
+			caps.Tokenizer = m.Tokenizer
+		}
+		if len(m.Features) > 0 {
+			caps.Features = append([]string(nil), m.Features...)
+		}
+	}
+
+	caps.Provider = backend
+	if caps.DisplayName == "" {
+		caps.DisplayName = caps.Name
+	}
+	if caps.Tokenizer == "" {
+		caps.Tokenizer = tokenizer.EncodingForModel(caps.Name)
+	}
+	caps.FetchedAt = time.Now()
+	return &caps
+}
+
+// --- Shared HTTP helpers for direct backends ---
+
+// postBackendJSON posts body as JSON and returns the response, converting non-200 statuses
+// to *LLMBackendError. The caller closes the body.
+func postBackendJSON(ctx context.Context, client *http.Client, backend, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %w", backend, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	return doBackendRequest(client, backend, req)
+}
+
+// getBackendJSON performs a GET and decodes the JSON response into out
+func getBackendJSON(ctx context.Context, client *http.Client, backend, url string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := doBackendRequest(client, backend, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", backend, err)
+	}
+	return nil
+}
+
+func doBackendRequest(client *http.Client, backend string, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", backend, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, &services.LLMBackendError{Backend: backend, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp, nil
+}
+
+// newBackendClients returns a client with a total timeout for synchronous calls and one
+// without for streams, mirroring the router service
+func newBackendClients(timeout time.Duration) (*http.Client, *http.Client) {
+	return &http.Client{Timeout: timeout}, &http.Client{}
+}
+
+// bearerHeaders returns an Authorization header map, or nil when apiKey is empty
+func bearerHeaders(apiKey string) map[string]string {
+	if apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + apiKey}
+}
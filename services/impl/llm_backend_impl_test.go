@@ -0,0 +1,394 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// stubRouterService records whether the TAS router path was used
+type stubRouterService struct {
+	calls int32
+}
+
+func (s *stubRouterService) SendRequest(ctx context.Context, cfg models.AgentLLMConfig, messages []services.Message, userID uuid.UUID) (*services.RouterResponse, error) {
+	atomic.AddInt32(&s.calls, 1)
+	return &services.RouterResponse{Content: "from router", Provider: cfg.Provider}, nil
+}
+
+func (s *stubRouterService) SendRequestWithTools(ctx context.Context, cfg models.AgentLLMConfig, messages []services.Message, tools []services.ToolDefinition, toolChoice string, userID uuid.UUID) (*services.RouterResponse, error) {
+	return s.SendRequest(ctx, cfg, messages, userID)
+}
+
+func (s *stubRouterService) ValidateConfig(ctx context.Context, cfg models.AgentLLMConfig) error {
+	return nil
+}
+
+func (s *stubRouterService) GetAvailableProviders(ctx context.Context) ([]services.Provider, error) {
+	return []services.Provider{{Name: "openai"}, {Name: "google"}}, nil
+}
+
+func (s *stubRouterService) GetProviderModels(ctx context.Context, provider string) ([]services.Model, error) {
+	return nil, nil
+}
+
+func (s *stubRouterService) GetModelCapabilities(ctx context.Context, cfg models.AgentLLMConfig) (*services.ModelCapabilities, error) {
+	return &services.ModelCapabilities{Name: cfg.Model, Provider: "router"}, nil
+}
+
+func streamingOff() *bool {
+	off := false
+	return &off
+}
+
+func TestBackendRouterServiceSelection(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+				return
+			}
+			var req openAIChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+			assert.Equal(t, "llama3", req.Model)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":      "chatcmpl-1",
+				"model":   "llama3",
+				"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": "hi"}, "finish_reason": "stop"}},
+				"usage":   map[string]int{"prompt_tokens": 5, "completion_tokens": 1, "total_tokens": 6},
+			})
+		case "/v1/models":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": []map[string]string{{"id": "llama3"}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	router := &stubRouterService{}
+	svc := NewBackendRouterService(router, map[string]services.LLMBackend{
+		"openai": NewOpenAIBackend(config.OpenAIBackendConfig{
+			BaseURL: server.URL + "/v1",
+			APIKey:  "sk-test",
+			Models:  map[string]config.BackendModelConfig{"llama3": {ContextWindow: 32768, InputCostPer1K: 2, OutputCostPer1K: 10}},
+		}, 5*time.Second),
+	}, 1, "")
+
+	t.Run("agent backend selects the direct backend and retries 5xx", func(t *testing.T) {
+		cfg := models.AgentLLMConfig{Provider: "openai", Backend: "openai", Model: "llama3", Streaming: streamingOff(), RetryConfig: &models.RetryConfig{MaxAttempts: 2}}
+		resp, err := svc.SendRequest(context.Background(), cfg, []services.Message{{Role: "user", Content: "hello"}}, uuid.New())
+		require.NoError(t, err)
+
+		assert.Equal(t, "hi", resp.Content)
+		assert.Equal(t, "openai", resp.Provider)
+		assert.Equal(t, "direct", resp.RoutingStrategy)
+		assert.Equal(t, 6, resp.TokenUsage)
+		assert.InDelta(t, 5*2.0/1000+1*10.0/1000, resp.CostUSD, 1e-9, "priced from the backend's model config")
+		assert.Equal(t, 1, resp.Metadata["retry_attempts"])
+		assert.Equal(t, false, resp.Metadata["fallback_used"])
+		assert.Equal(t, []string{"direct backend: openai"}, resp.Metadata["routing_reason"])
+		assert.Zero(t, atomic.LoadInt32(&router.calls))
+	})
+
+	t.Run("capabilities come from the agent's backend", func(t *testing.T) {
+		caps, err := svc.GetModelCapabilities(context.Background(), models.AgentLLMConfig{Backend: "openai", Model: "llama3"})
+		require.NoError(t, err)
+		assert.Equal(t, "openai", caps.Provider)
+		assert.Equal(t, 32768, caps.ContextWindow)
+		assert.Equal(t, 2.0, caps.InputCostPer1K)
+		assert.NotEmpty(t, caps.Tokenizer)
+
+		caps, err = svc.GetModelCapabilities(context.Background(), models.AgentLLMConfig{Model: "gpt-4o"})
+		require.NoError(t, err)
+		assert.Equal(t, "router", caps.Provider, "routed agents ask the router")
+	})
+
+	t.Run("capabilities looked up by the execution are used for pricing", func(t *testing.T) {
+		cfg := models.AgentLLMConfig{Backend: "openai", Model: "llama3", Streaming: streamingOff()}
+		ctx := services.WithModelCapabilities(context.Background(), &services.ModelCapabilities{Name: "llama3", InputCostPer1K: 4})
+		resp, err := svc.SendRequest(ctx, cfg, []services.Message{{Role: "user", Content: "hello"}}, uuid.New())
+		require.NoError(t, err)
+		assert.InDelta(t, 5*4.0/1000, resp.CostUSD, 1e-9, "the catalog is not asked again")
+	})
+
+	t.Run("agents without a backend use the router whatever their provider", func(t *testing.T) {
+		for _, cfg := range []models.AgentLLMConfig{
+			{Provider: "openai", Model: "gpt-4o"},
+			{Provider: "google", Backend: models.LLMBackendRouter, Model: "gemini"},
+		} {
+			resp, err := svc.SendRequest(context.Background(), cfg, nil, uuid.New())
+			require.NoError(t, err)
+			assert.Equal(t, "from router", resp.Content)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&router.calls))
+	})
+
+	t.Run("unknown backends are rejected", func(t *testing.T) {
+		cfg := models.AgentLLMConfig{Provider: "anthropic", Backend: "anthropic", Model: "claude"}
+		_, err := svc.SendRequest(context.Background(), cfg, nil, uuid.New())
+		assert.ErrorIs(t, err, services.ErrUnknownLLMBackend)
+		assert.ErrorIs(t, svc.ValidateConfig(context.Background(), cfg), services.ErrUnknownLLMBackend)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&router.calls), "no fallback to the router")
+	})
+
+	t.Run("the agent's backend wins over the global default, which wins over the router", func(t *testing.T) {
+		defaulted := NewBackendRouterService(router, map[string]services.LLMBackend{
+			"openai": NewOpenAIBackend(config.OpenAIBackendConfig{BaseURL: server.URL + "/v1", APIKey: "sk-test"}, 5*time.Second),
+		}, 1, "openai")
+		before := atomic.LoadInt32(&router.calls)
+
+		resp, err := defaulted.SendRequest(context.Background(), models.AgentLLMConfig{Model: "llama3", Streaming: streamingOff()}, nil, uuid.New())
+		require.NoError(t, err)
+		assert.Equal(t, "direct", resp.RoutingStrategy, "agents without a backend use the default")
+
+		resp, err = defaulted.SendRequest(context.Background(), models.AgentLLMConfig{Backend: models.LLMBackendRouter, Model: "gpt-4o"}, nil, uuid.New())
+		require.NoError(t, err)
+		assert.Equal(t, "from router", resp.Content, "agents may still select the router")
+		assert.Equal(t, before+1, atomic.LoadInt32(&router.calls))
+
+		_, err = defaulted.SendRequest(context.Background(), models.AgentLLMConfig{Backend: "ollama", Model: "llama3"}, nil, uuid.New())
+		assert.ErrorIs(t, err, services.ErrUnknownLLMBackend, "the default does not replace an unknown backend")
+
+		unknown := NewBackendRouterService(router, nil, 1, "anthropic")
+		_, err = unknown.SendRequest(context.Background(), models.AgentLLMConfig{Model: "claude"}, nil, uuid.New())
+		assert.ErrorIs(t, err, services.ErrUnknownLLMBackend)
+	})
+
+	t.Run("validation checks the backend model list", func(t *testing.T) {
+		assert.NoError(t, svc.ValidateConfig(context.Background(), models.AgentLLMConfig{Backend: "openai", Model: "llama3"}))
+		assert.Error(t, svc.ValidateConfig(context.Background(), models.AgentLLMConfig{Backend: "openai", Model: "gpt-9"}))
+	})
+
+	t.Run("direct backends are listed alongside router providers", func(t *testing.T) {
+		providers, err := svc.GetAvailableProviders(context.Background())
+		require.NoError(t, err)
+		require.Len(t, providers, 3)
+		assert.Equal(t, "openai", providers[0].Name)
+		assert.Empty(t, providers[0].Backend)
+		assert.Equal(t, "google", providers[1].Name)
+		assert.Equal(t, "openai", providers[2].Name)
+		assert.Equal(t, "openai", providers[2].Backend)
+		assert.Equal(t, []string{"llama3"}, providers[2].Models)
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}))
+		defer failing.Close()
+
+		direct := NewBackendRouterService(router, map[string]services.LLMBackend{
+			"openai": NewOpenAIBackend(config.OpenAIBackendConfig{BaseURL: failing.URL}, time.Second),
+		}, 3, "")
+
+		_, err := direct.SendRequest(context.Background(), models.AgentLLMConfig{Backend: "openai", Model: "m", Streaming: streamingOff()}, nil, uuid.New())
+		var backendErr *services.LLMBackendError
+		require.ErrorAs(t, err, &backendErr)
+		assert.Equal(t, http.StatusBadRequest, backendErr.StatusCode)
+	})
+}
+
+func TestAnthropicBackendStreamingToolUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "key", r.Header.Get("x-api-key"))
+		assert.Equal(t, "2023-06-01", r.Header.Get("anthropic-version"))
+
+		var req anthropicRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "Be brief.", req.System)
+		assert.Equal(t, anthropicDefaultMaxTokens, req.MaxTokens)
+		assert.Equal(t, &anthropicToolChoice{Type: "any"}, req.ToolChoice)
+		require.Len(t, req.Tools, 1)
+		assert.Equal(t, "search", req.Tools[0].Name)
+
+		// user, assistant(tool_use), user(tool_result + text merged)
+		require.Len(t, req.Messages, 3)
+		assert.Equal(t, "image", req.Messages[0].Content[1].Type)
+		assert.Equal(t, "tool_use", req.Messages[1].Content[0].Type)
+		assert.JSONEq(t, `{"q":"go"}`, string(req.Messages[1].Content[0].Input))
+		assert.Equal(t, "user", req.Messages[2].Role)
+		assert.Equal(t, "tool_result", req.Messages[2].Content[0].Type)
+		assert.Equal(t, "toolu_1", req.Messages[2].Content[0].ToolUseID)
+		assert.Equal(t, "text", req.Messages[2].Content[1].Type)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude-3-5-sonnet","usage":{"input_tokens":12}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"check."}}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_2","name":"search","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"more\"}"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, e := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", e)
+		}
+	}))
+	defer server.Close()
+
+	backend := NewAnthropicBackend(config.AnthropicBackendConfig{BaseURL: server.URL, APIKey: "key", Version: "2023-06-01"}, 5*time.Second)
+	result, err := backend.Complete(context.Background(), services.LLMBackendRequest{
+		Config: models.AgentLLMConfig{Model: "claude-3-5-sonnet"},
+		Messages: []services.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Look", Parts: []models.ContentPart{{Type: models.ContentPartImageBase64, MediaType: "image/png", Data: "aGVsbG8="}}},
+			{Role: "assistant", ToolCalls: []services.ToolCall{{ID: "toolu_1", Type: "function", Function: services.ToolFunction{Name: "search", Arguments: `{"q":"go"}`}}}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "results"},
+			{Role: "user", Content: "and?"},
+		},
+		Tools:      []services.ToolDefinition{{Type: "function", Function: services.ToolFunctionDef{Name: "search", Parameters: map[string]interface{}{"type": "object"}}}},
+		ToolChoice: "required",
+		Stream:     true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "msg_1", result.ID)
+	assert.Equal(t, "Let me check.", result.Content)
+	assert.Equal(t, "tool_calls", result.FinishReason)
+	assert.Equal(t, 12, result.PromptTokens)
+	assert.Equal(t, 20, result.CompletionTokens)
+	require.Len(t, result.ToolCalls, 1)
+	assert.Equal(t, "toolu_2", result.ToolCalls[0].ID)
+	assert.JSONEq(t, `{"q":"more"}`, result.ToolCalls[0].Function.Arguments)
+}
+
+func TestOllamaBackendStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/show" {
+			fmt.Fprint(w, `{"capabilities":["completion","vision"],"model_info":{"llama.context_length":32768}}`)
+			return
+		}
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var req ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+		assert.Equal(t, 256.0, req.Options["num_predict"])
+		assert.Equal(t, 32768.0, req.Options["num_ctx"], "run with the window the budget was planned for")
+		require.Len(t, req.Messages, 3)
+		assert.Equal(t, []string{"aGVsbG8="}, req.Messages[0].Images)
+		assert.Equal(t, "weather", req.Messages[2].ToolName)
+
+		lines := []string{
+			`{"model":"llava","message":{"role":"assistant","content":""},"done":false}`,
+			`{"model":"llava","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Oslo"}}}]},"done":false}`,
+			`{"model":"llava","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":30,"eval_count":8}`,
+		}
+		for _, l := range lines {
+			fmt.Fprintln(w, l)
+		}
+	}))
+	defer server.Close()
+
+	maxTokens := 256
+	backend := NewOllamaBackend(config.OllamaBackendConfig{BaseURL: server.URL}, 5*time.Second)
+	result, err := backend.Complete(context.Background(), services.LLMBackendRequest{
+		Config: models.AgentLLMConfig{Model: "llava", MaxTokens: &maxTokens},
+		Messages: []services.Message{
+			{Role: "user", Content: "Weather here?", Parts: []models.ContentPart{{Type: models.ContentPartImageBase64, MediaType: "image/png", Data: "aGVsbG8="}}},
+			{Role: "assistant", ToolCalls: []services.ToolCall{{ID: "call_0", Function: services.ToolFunction{Name: "weather", Arguments: `{"city":"Bergen"}`}}}},
+			{Role: "tool", ToolCallID: "call_0", Content: "rain"},
+		},
+		Tools:  []services.ToolDefinition{{Type: "function", Function: services.ToolFunctionDef{Name: "weather"}}},
+		Stream: true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "llava", result.Model)
+	assert.Equal(t, "tool_calls", result.FinishReason)
+	assert.Equal(t, 30, result.PromptTokens)
+	assert.Equal(t, 8, result.CompletionTokens)
+	require.Len(t, result.ToolCalls, 1)
+	assert.Equal(t, "call_0", result.ToolCalls[0].ID)
+	assert.JSONEq(t, `{"city":"Oslo"}`, result.ToolCalls[0].Function.Arguments)
+}
+
+func TestOllamaBackendModelCapabilities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req["model"] != "llava" {
+			http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"capabilities":["completion","tools","vision"],"model_info":{"general.architecture":"llama","llama.context_length":131072}}`)
+	}))
+	defer server.Close()
+
+	backend := NewOllamaBackend(config.OllamaBackendConfig{
+		BaseURL: server.URL,
+		Models: map[string]config.BackendModelConfig{
+			"llava":   {ContextWindow: 16384},
+			"offline": {ContextWindow: 8192, Features: []string{"chat"}},
+		},
+	}, 5*time.Second)
+
+	caps, err := backend.ModelCapabilities(context.Background(), "llava")
+	require.NoError(t, err)
+	assert.Equal(t, 16384, caps.ContextWindow, "the configured window wins over the model's")
+	assert.True(t, caps.HasFeature("functions"))
+	assert.True(t, caps.HasFeature("vision"))
+	assert.Equal(t, "ollama", caps.Provider)
+
+	caps, err = backend.ModelCapabilities(context.Background(), "offline")
+	require.NoError(t, err, "configured models are described without Ollama")
+	assert.Equal(t, 8192, caps.ContextWindow)
+
+	_, err = backend.ModelCapabilities(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestOllamaImageFetchRefusesInternalAddresses(t *testing.T) {
+	image := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("png"))
+	})
+	trusted := httptest.NewServer(image)
+	defer trusted.Close()
+	internal := httptest.NewTLSServer(image)
+	defer internal.Close()
+
+	backend := NewOllamaBackend(config.OllamaBackendConfig{ImageHosts: []string{strings.TrimPrefix(trusted.URL, "http://")}}, 5*time.Second).(*ollamaBackend)
+	ctx := context.Background()
+
+	data, err := backend.fetchImage(ctx, trusted.URL+"/files/1?signature=abc")
+	require.NoError(t, err, "trusted hosts such as AudiModal may be internal")
+	assert.Equal(t, "cG5n", data)
+
+	_, err = backend.fetchImage(ctx, "http://169.254.169.254/latest/meta-data/")
+	assert.ErrorContains(t, err, "https")
+
+	_, err = backend.fetchImage(ctx, internal.URL+"/secret?token=abc")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a public address")
+	assert.NotContains(t, err.Error(), "token=abc")
+
+	for addr, public := range map[string]bool{
+		"8.8.8.8": true, "2606:4700::1111": true,
+		"10.1.2.3": false, "127.0.0.1": false, "169.254.169.254": false, "100.64.0.1": false,
+		"::1": false, "fd00::1": false, "fe80::1": false, "0.0.0.0": false,
+	} {
+		assert.Equal(t, public, isPublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+	assert.Equal(t, "https://audimodal.example.com/files/1", urlWithoutQuery("https://user:pw@audimodal.example.com/files/1?sig=secret#x"))
+}
@@ -0,0 +1,478 @@
+package impl
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+// ollamaBackend talks to the Ollama /api/chat endpoint
+type ollamaBackend struct {
+	baseURL      string
+	models       map[string]config.BackendModelConfig
+	httpClient   *http.Client
+	streamClient *http.Client
+
+	// Images come from user-supplied URLs, so other hosts than the trusted ones are fetched
+	// over https from public addresses only
+	imageHosts         map[string]bool
+	trustedImageClient *http.Client
+	publicImageClient  *http.Client
+}
+
+// NewOllamaBackend creates a direct backend for an Ollama server
+func NewOllamaBackend(cfg config.OllamaBackendConfig, timeout time.Duration) services.LLMBackend {
+	httpClient, streamClient := newBackendClients(timeout)
+	b := &ollamaBackend{
+		baseURL:      strings.TrimSuffix(cfg.BaseURL, "/"),
+		models:       cfg.Models,
+		httpClient:   httpClient,
+		streamClient: streamClient,
+		imageHosts:   make(map[string]bool),
+	}
+	for _, host := range cfg.ImageHosts {
+		if host != "" {
+			b.imageHosts[strings.ToLower(host)] = true
+		}
+	}
+
+	// Neither client follows redirects to other hosts, which could lead out of the checks
+	noRedirects := func(req *http.Request, via []*http.Request) error {
+		if req.URL.Host != via[0].URL.Host || req.URL.Scheme != via[0].URL.Scheme {
+			return fmt.Errorf("redirect to another host")
+		}
+		if len(via) >= 5 {
+			return fmt.Errorf("too many redirects")
+		}
+		return nil
+	}
+	b.trustedImageClient = &http.Client{Timeout: timeout, CheckRedirect: noRedirects}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: publicAddressOnly}
+	b.publicImageClient = &http.Client{
+		Timeout:       timeout,
+		CheckRedirect: noRedirects,
+		// No proxy, so the address checked is the one connected to
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+	}
+	return b
+}
+
+type ollamaChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Tools    []RouterTool           `json:"tools,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"` // Base64, no data URL prefix
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"` // An object, not a JSON string
+	} `json:"function"`
+}
+
+// ollamaChatResponse is a full response or, when streaming, one NDJSON line
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       string        `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error,omitempty"`
+}
+
+func (b *ollamaBackend) Name() string { return "ollama" }
+
+func (b *ollamaBackend) Complete(ctx context.Context, req services.LLMBackendRequest) (*services.LLMBackendResponse, error) {
+	body := ollamaChatRequest{
+		Model:    req.Config.Model,
+		Messages: b.buildMessages(ctx, req.Messages),
+		Stream:   req.Stream,
+		Options:  ollamaOptions(req.Config, b.contextWindow(ctx, req.Config.Model)),
+	}
+	// Ollama has no tool_choice; "none" is honoured by not offering tools
+	if len(req.Tools) > 0 && req.ToolChoice != "none" {
+		body.Tools = buildRouterTools(req.Tools)
+	}
+
+	client := b.httpClient
+	if req.Stream {
+		client = b.streamClient
+	}
+
+	resp, err := postBackendJSON(ctx, client, b.Name(), b.baseURL+"/api/chat", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chat *ollamaChatResponse
+	if req.Stream {
+		chat, err = readOllamaStream(resp.Body)
+	} else {
+		chat = &ollamaChatResponse{}
+		err = json.NewDecoder(resp.Body).Decode(chat)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if chat.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", chat.Error)
+	}
+
+	result := &services.LLMBackendResponse{
+		Model:            chat.Model,
+		Content:          chat.Message.Content,
+		FinishReason:     ollamaFinishReason(chat),
+		PromptTokens:     chat.PromptEvalCount,
+		CompletionTokens: chat.EvalCount,
+	}
+	// Ollama does not assign tool call IDs; generate stable ones for the tool loop
+	for i, tc := range chat.Message.ToolCalls {
+		args := string(tc.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		result.ToolCalls = append(result.ToolCalls, services.ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: services.ToolFunction{Name: tc.Function.Name, Arguments: args},
+		})
+	}
+	return result, nil
+}
+
+func (b *ollamaBackend) ListModels(ctx context.Context) ([]services.Model, error) {
+	var resp struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getBackendJSON(ctx, b.httpClient, b.Name(), b.baseURL+"/api/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	result := make([]services.Model, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		result = append(result, services.Model{
+			Name:        m.Name,
+			DisplayName: m.Name,
+			Provider:    b.Name(),
+		})
+	}
+	return result, nil
+}
+
+// ollamaShowResponse is the part of /api/show describing what a model can do
+type ollamaShowResponse struct {
+	Capabilities []string               `json:"capabilities"` // e.g. completion, tools, vision
+	ModelInfo    map[string]interface{} `json:"model_info"`   // Holds "<architecture>.context_length"
+}
+
+// ModelCapabilities describes a model from /api/show and its configured entry, which wins. A
+// model Ollama cannot describe is still described when it is configured.
+func (b *ollamaBackend) ModelCapabilities(ctx context.Context, model string) (*services.ModelCapabilities, error) {
+	caps := services.ModelCapabilities{Name: model, Features: []string{"chat", "streaming"}}
+
+	var show ollamaShowResponse
+	resp, err := postBackendJSON(ctx, b.httpClient, b.Name(), b.baseURL+"/api/show", nil, map[string]string{"model": model})
+	if err == nil {
+		err = json.NewDecoder(resp.Body).Decode(&show)
+		resp.Body.Close()
+	}
+	if err != nil {
+		if _, ok := b.models[model]; !ok {
+			return nil, fmt.Errorf("failed to describe %s: %w", model, err)
+		}
+		log.Printf("[LLM-BACKEND] Describing %s from its configuration only: %v", model, err)
+	}
+
+	for _, c := range show.Capabilities {
+		switch c {
+		case "tools":
+			caps.Features = append(caps.Features, "functions")
+		case "vision":
+			caps.Features = append(caps.Features, "vision")
+		}
+	}
+	for key, value := range show.ModelInfo {
+		if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			caps.ContextWindow = int(n)
+		}
+	}
+	return backendModelCapabilities(b.Name(), caps, b.models), nil
+}
+
+// contextWindow returns the window to run model with. Ollama otherwise truncates prompts to its
+// own default context size, which the token budget knows nothing about.
+func (b *ollamaBackend) contextWindow(ctx context.Context, model string) int {
+	if caps, ok := services.ModelCapabilitiesFromContext(ctx, model); ok {
+		return caps.ContextWindow
+	}
+	caps, err := b.ModelCapabilities(ctx, model)
+	if err != nil {
+		return 0
+	}
+	return caps.ContextWindow
+}
+
+// buildMessages converts messages to Ollama's format. Images must be inline base64, so
+// image URLs (including signed AudiModal URLs) are downloaded.
+func (b *ollamaBackend) buildMessages(ctx context.Context, messages []services.Message) []ollamaMessage {
+	// Tool results carry the tool name in Ollama, so remember which call ID named which tool
+	toolNames := make(map[string]string)
+
+	result := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		om := ollamaMessage{Role: msg.Role, Content: msg.Content}
+
+		for _, tc := range msg.ToolCalls {
+			toolNames[tc.ID] = tc.Function.Name
+			var call ollamaToolCall
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(call.Function.Arguments) {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			om.ToolCalls = append(om.ToolCalls, call)
+		}
+		if msg.Role == "tool" {
+			om.ToolName = toolNames[msg.ToolCallID]
+		}
+
+		var text []string
+		for _, p := range msg.Parts {
+			switch {
+			case p.Type == models.ContentPartText:
+				text = append(text, p.Text)
+			case p.IsImage() && p.Data != "":
+				om.Images = append(om.Images, p.Data)
+			case p.IsImage() && p.URL != "":
+				data, err := b.fetchImage(ctx, p.URL)
+				if err != nil {
+					log.Printf("[LLM-BACKEND] Ollama: skipping image %s: %v", urlWithoutQuery(p.URL), err)
+					continue
+				}
+				om.Images = append(om.Images, data)
+			default:
+				log.Printf("[LLM-BACKEND] Ollama: skipping unsupported %s content part (%s)", p.Type, p.MediaType)
+			}
+		}
+		if len(text) > 0 {
+			om.Content = strings.Join(append([]string{om.Content}, text...), "\n")
+		}
+
+		result = append(result, om)
+	}
+	return result
+}
+
+// fetchImage downloads an image and returns it base64 encoded. Images on trusted hosts, such as
+// AudiModal's signed URLs, may use http and private addresses; others must be https on public
+// addresses, so executions cannot make the service request cluster-internal endpoints.
+func (b *ollamaBackend) fetchImage(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL")
+	}
+	client := b.trustedImageClient
+	if !b.imageHosts[strings.ToLower(u.Host)] {
+		if u.Scheme != "https" {
+			return "", fmt.Errorf("image URLs must use https")
+		}
+		client = b.publicImageClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // The URL may hold a signature
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, models.MaxInlineContentBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > models.MaxInlineContentBytes {
+		return "", fmt.Errorf("image exceeds %d bytes", models.MaxInlineContentBytes)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// publicAddressOnly refuses connections to loopback, private, link-local and other addresses
+// that are not reachable on the internet. It runs after DNS resolution, for every address tried.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%s is not an IP address", host)
+	}
+	if !isPublicAddr(ip.Unmap()) {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
+
+// nonPublicPrefixes are special-purpose ranges the netip predicates do not cover
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, which can reach IPv4 private ranges
+}
+
+func isPublicAddr(ip netip.Addr) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// urlWithoutQuery drops the query and fragment of a URL for logging, since signed URLs carry
+// their token there
+func urlWithoutQuery(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	u.RawQuery, u.Fragment, u.User = "", "", nil
+	return u.String()
+}
+
+// ollamaOptions maps sampling parameters and the context window, when known, to Ollama model options
+func ollamaOptions(cfg models.AgentLLMConfig, contextWindow int) map[string]interface{} {
+	options := make(map[string]interface{})
+	if contextWindow > 0 {
+		options["num_ctx"] = contextWindow
+	}
+	if cfg.Temperature != nil {
+		options["temperature"] = *cfg.Temperature
+	}
+	if cfg.TopP != nil {
+		options["top_p"] = *cfg.TopP
+	}
+	if cfg.TopK != nil {
+		options["top_k"] = *cfg.TopK
+	}
+	if cfg.MaxTokens != nil {
+		options["num_predict"] = *cfg.MaxTokens
+	}
+	if len(cfg.Stop) > 0 {
+		options["stop"] = cfg.Stop
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// ollamaFinishReason maps done_reason to OpenAI finish reasons
+func ollamaFinishReason(chat *ollamaChatResponse) string {
+	if len(chat.Message.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	if chat.DoneReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// readOllamaStream accumulates Ollama's NDJSON stream into a single response
+func readOllamaStream(body io.Reader) (*ollamaChatResponse, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	result := &ollamaChatResponse{}
+	var content strings.Builder
+	gotLine := false
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			log.Printf("[STREAM] Failed to parse Ollama chunk: %v (data: %.100s)", err, line)
+			continue
+		}
+		gotLine = true
+
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		content.WriteString(chunk.Message.Content)
+		result.Message.ToolCalls = append(result.Message.ToolCalls, chunk.Message.ToolCalls...)
+
+		if chunk.Done {
+			result.Done = true
+			result.DoneReason = chunk.DoneReason
+			result.PromptEvalCount = chunk.PromptEvalCount
+			result.EvalCount = chunk.EvalCount
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading stream: %w", err)
+	}
+	if !gotLine {
+		return nil, fmt.Errorf("empty streaming response")
+	}
+
+	result.Message.Role = "assistant"
+	result.Message.Content = content.String()
+	return result, nil
+}
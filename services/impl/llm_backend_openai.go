@@ -0,0 +1,152 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/services"
+)
+
+// openAIBackend talks to any OpenAI-compatible chat completions endpoint (OpenAI, vLLM,
+// LM Studio, LiteLLM, ...)
+type openAIBackend struct {
+	baseURL      string
+	apiKey       string
+	models       map[string]config.BackendModelConfig
+	httpClient   *http.Client
+	streamClient *http.Client
+}
+
+// NewOpenAIBackend creates a direct backend for an OpenAI-compatible endpoint
+func NewOpenAIBackend(cfg config.OpenAIBackendConfig, timeout time.Duration) services.LLMBackend {
+	httpClient, streamClient := newBackendClients(timeout)
+	return &openAIBackend{
+		baseURL:      strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:       cfg.APIKey,
+		models:       cfg.Models,
+		httpClient:   httpClient,
+		streamClient: streamClient,
+	}
+}
+
+// openAIChatRequest is the chat completions body without the router-only fields
+type openAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []RouterMessage      `json:"messages"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	MaxTokens     *int                 `json:"max_tokens,omitempty"`
+	TopP          *float64             `json:"top_p,omitempty"`
+	Stop          []string             `json:"stop,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+	Tools         []RouterTool         `json:"tools,omitempty"`
+	ToolChoice    interface{}          `json:"tool_choice,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+func (b *openAIBackend) Name() string { return "openai" }
+
+func (b *openAIBackend) Complete(ctx context.Context, req services.LLMBackendRequest) (*services.LLMBackendResponse, error) {
+	body := openAIChatRequest{
+		Model:       req.Config.Model,
+		Messages:    buildRouterMessages(req.Messages),
+		Temperature: req.Config.Temperature,
+		MaxTokens:   req.Config.MaxTokens,
+		TopP:        req.Config.TopP,
+		Stop:        req.Config.Stop,
+		Stream:      req.Stream,
+	}
+	if req.Stream {
+		body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	if len(req.Tools) > 0 {
+		body.Tools = buildRouterTools(req.Tools)
+		body.ToolChoice = buildRouterToolChoice(req.ToolChoice)
+	}
+
+	client := b.httpClient
+	if req.Stream {
+		client = b.streamClient
+	}
+
+	resp, err := postBackendJSON(ctx, client, b.Name(), b.baseURL+"/chat/completions", bearerHeaders(b.apiKey), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp *RouterAPIResponse
+	if req.Stream {
+		apiResp, err = readStreamResponse(resp.Body)
+	} else {
+		apiResp, err = readSyncResponse(resp.Body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(apiResp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in openai response")
+	}
+
+	choice := apiResp.Choices[0]
+	result := &services.LLMBackendResponse{
+		ID:               apiResp.ID,
+		Model:            apiResp.Model,
+		Content:          choice.Message.Content,
+		FinishReason:     choice.FinishReason,
+		PromptTokens:     apiResp.Usage.PromptTokens,
+		CompletionTokens: apiResp.Usage.CompletionTokens,
+	}
+	if result.Model == "" {
+		result.Model = req.Config.Model
+	}
+	for _, tc := range choice.Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, services.ToolCall{
+			ID:   tc.ID,
+			Type: "function",
+			Function: services.ToolFunction{
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			},
+		})
+	}
+	return result, nil
+}
+
+func (b *openAIBackend) ListModels(ctx context.Context) ([]services.Model, error) {
+	var resp struct {
+		Data []struct {
+			ID      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := getBackendJSON(ctx, b.httpClient, b.Name(), b.baseURL+"/models", bearerHeaders(b.apiKey), &resp); err != nil {
+		return nil, err
+	}
+
+	result := make([]services.Model, 0, len(resp.Data))
+	for _, m := range resp.Data {
+		result = append(result, services.Model{
+			Name:        m.ID,
+			DisplayName: m.ID,
+			Provider:    b.Name(),
+		})
+	}
+	return result, nil
+}
+
+// ModelCapabilities describes a model from its configured entry. The models endpoint reports
+// no limits, so models without one get the planner's default context window.
+func (b *openAIBackend) ModelCapabilities(ctx context.Context, model string) (*services.ModelCapabilities, error) {
+	return backendModelCapabilities(b.Name(), services.ModelCapabilities{
+		Name:     model,
+		Features: []string{"chat", "streaming", "functions"},
+	}, b.models), nil
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/mcp"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
 // mcpContextServiceImpl implements MCPContextService
@@ -19,10 +21,17 @@ type mcpContextServiceImpl struct {
 	mcpServerURL string
 	httpClient   *http.Client
 	config       *models.MCPConfig
+	clients      *mcp.Manager
 }
 
 // NewMCPContextService creates a new MCP context service
 func NewMCPContextService(mcpServerURL string, config *models.MCPConfig) services.MCPContextService {
+	return NewMCPContextServiceWithClients(mcpServerURL, config, nil)
+}
+
+// NewMCPContextServiceWithClients creates an MCP context service that shares MCP sessions with
+// the rest of the process. A nil manager gets a private one.
+func NewMCPContextServiceWithClients(mcpServerURL string, config *models.MCPConfig, clients *mcp.Manager) services.MCPContextService {
 	if config == nil {
 		config = models.DefaultMCPConfig()
 	}
@@ -30,9 +39,15 @@ func NewMCPContextService(mcpServerURL string, config *models.MCPConfig) service
 		config.ServerURL = mcpServerURL
 	}
 
+	if clients == nil {
+		clients = mcp.NewManager(mcp.Implementation{Name: "tas-agent-builder", Version: "1.0.0"},
+			time.Duration(config.TimeoutMs)*time.Millisecond)
+	}
+
 	return &mcpContextServiceImpl{
 		mcpServerURL: config.ServerURL,
 		config:       config,
+		clients:      clients,
 		httpClient: &http.Client{
 			Timeout: time.Duration(config.TimeoutMs) * time.Millisecond,
 		},
@@ -230,41 +245,18 @@ func (s *mcpContextServiceImpl) ListToolsForLLM(ctx context.Context) ([]services
 	return tools, nil
 }
 
-// ListToolsFromServer lists tools from an arbitrary MCP server URL
-// Unlike ListToolsForLLM which uses the configured server, this accepts any server URL
-func (s *mcpContextServiceImpl) ListToolsFromServer(ctx context.Context, serverURL string) ([]services.ToolDefinition, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", serverURL+"/mcp/tools/list", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request for %s: %w", serverURL, err)
-	}
-
-	resp, err := s.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list tools from %s: %w", serverURL, err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+// ListToolsFromServer lists tools from an arbitrary MCP server
+// Unlike ListToolsForLLM which uses the configured server, this accepts any server and transport
+func (s *mcpContextServiceImpl) ListToolsFromServer(ctx context.Context, server mcp.Server) ([]services.ToolDefinition, error) {
+	mcpTools, err := s.clients.ListTools(ctx, server)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response from %s: %w", serverURL, err)
-	}
-
-	var mcpResp struct {
-		Tools []struct {
-			Name        string                 `json:"name"`
-			Description string                 `json:"description"`
-			InputSchema map[string]interface{} `json:"inputSchema"`
-		} `json:"tools"`
+		return nil, fmt.Errorf("failed to list tools from %s: %w", server, err)
 	}
 
-	if err := json.Unmarshal(body, &mcpResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response from %s: %w (body: %s)", serverURL, err, string(body))
-	}
-
-	tools := make([]services.ToolDefinition, 0, len(mcpResp.Tools))
-	for _, t := range mcpResp.Tools {
+	tools := make([]services.ToolDefinition, 0, len(mcpTools))
+	for _, t := range mcpTools {
 		params := interface{}(t.InputSchema)
-		if params == nil {
+		if t.InputSchema == nil {
 			params = map[string]interface{}{
 				"type":       "object",
 				"properties": map[string]interface{}{},
@@ -284,75 +276,25 @@ func (s *mcpContextServiceImpl) ListToolsFromServer(ctx context.Context, serverU
 }
 
 // InvokeToolOnServer invokes a tool on an arbitrary MCP server
-func (s *mcpContextServiceImpl) InvokeToolOnServer(ctx context.Context, serverURL string, req models.MCPToolRequest) (*models.MCPToolResponse, error) {
+func (s *mcpContextServiceImpl) InvokeToolOnServer(ctx context.Context, server mcp.Server, req models.MCPToolRequest) (*models.MCPToolResponse, error) {
 	startTime := time.Now()
 
-	mcpRequest := map[string]any{
-		"name":      req.ToolName,
-		"arguments": req.Parameters,
-	}
-
-	reqBody, err := json.Marshal(mcpRequest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal MCP request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", serverURL+"/mcp/tools/call", bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	if req.TenantID != "" {
-		httpReq.Header.Set("X-Tenant-ID", req.TenantID)
-	}
-
-	resp, err := s.httpClient.Do(httpReq)
-	if err != nil {
-		return &models.MCPToolResponse{
-			ToolName:    req.ToolName,
-			Success:     false,
-			Error:       fmt.Sprintf("HTTP request failed: %v", err),
-			ExecutionMs: int(time.Since(startTime).Milliseconds()),
-		}, nil
+		ctx = mcp.WithHeader(ctx, "X-Tenant-ID", req.TenantID)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	result, err := s.clients.CallTool(ctx, server, req.ToolName, req.Parameters, nil)
 	if err != nil {
 		return &models.MCPToolResponse{
 			ToolName:    req.ToolName,
 			Success:     false,
-			Error:       fmt.Sprintf("failed to read response: %v", err),
-			ExecutionMs: int(time.Since(startTime).Milliseconds()),
-		}, nil
-	}
-
-	var mcpResp struct {
-		Content []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
-		} `json:"content"`
-		IsError bool `json:"isError"`
-	}
-
-	if err := json.Unmarshal(body, &mcpResp); err != nil {
-		return &models.MCPToolResponse{
-			ToolName:    req.ToolName,
-			Success:     false,
-			Error:       fmt.Sprintf("failed to parse MCP response: %v (body: %s)", err, string(body)),
+			Error:       fmt.Sprintf("tool call failed: %v", err),
 			ExecutionMs: int(time.Since(startTime).Milliseconds()),
 		}, nil
 	}
 
-	var resultText string
-	for _, c := range mcpResp.Content {
-		if c.Type == "text" {
-			resultText = c.Text
-			break
-		}
-	}
-
-	if mcpResp.IsError {
+	resultText := result.Text()
+	if result.IsError {
 		return &models.MCPToolResponse{
 			ToolName:    req.ToolName,
 			Success:     false,
@@ -412,7 +354,7 @@ func (s *mcpContextServiceImpl) SearchDocuments(ctx context.Context, req models.
 	}
 
 	// Parse the search results
-	chunks, totalTokens, err := s.parseSearchResults(toolResp.Result)
+	chunks, totalTokens, err := s.parseSearchResults(toolResp.Result, tokenizer.FromContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse search results: %w", err)
 	}
@@ -431,7 +373,7 @@ func (s *mcpContextServiceImpl) SearchDocuments(ctx context.Context, req models.
 }
 
 // parseSearchResults converts MCP search results to RetrievedChunks
-func (s *mcpContextServiceImpl) parseSearchResults(result any) ([]models.RetrievedChunk, int, error) {
+func (s *mcpContextServiceImpl) parseSearchResults(result any, tok tokenizer.Tokenizer) ([]models.RetrievedChunk, int, error) {
 	// Result should be a map with results array
 	resultMap, ok := result.(map[string]any)
 	if !ok {
@@ -447,7 +389,7 @@ func (s *mcpContextServiceImpl) parseSearchResults(result any) ([]models.Retriev
 				ID:      "mcp-result-0",
 				Content: content,
 			}
-			tokens := len(content) / 4 // Rough estimate
+			tokens := tok.Count(content)
 			return []models.RetrievedChunk{chunk}, tokens, nil
 		}
 		return nil, 0, nil // Empty results
@@ -471,7 +413,7 @@ func (s *mcpContextServiceImpl) parseSearchResults(result any) ([]models.Retriev
 		}
 		if content, ok := item["content"].(string); ok {
 			chunk.Content = content
-			totalTokens += len(content) / 4 // Rough token estimate
+			totalTokens += tok.Count(content)
 		}
 		if score, ok := item["score"].(float64); ok {
 			chunk.Score = score
@@ -511,7 +453,7 @@ func (s *mcpContextServiceImpl) GetDocumentContent(ctx context.Context, document
 	}
 
 	// Parse the content results
-	chunks, totalTokens, err := s.parseSearchResults(toolResp.Result)
+	chunks, totalTokens, err := s.parseSearchResults(toolResp.Result, tokenizer.FromContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse content results: %w", err)
 	}
@@ -594,7 +536,7 @@ func (s *mcpContextServiceImpl) RetrieveMCPContext(ctx context.Context, query st
 	if err == nil && len(searchResult.Chunks) > 0 {
 		// Add chunks that fit within token budget
 		for _, chunk := range searchResult.Chunks {
-			chunkTokens := len(chunk.Content) / 4
+			chunkTokens := tokenizer.FromContext(ctx).Count(chunk.Content)
 			if currentTokens+chunkTokens <= maxTokens {
 				result.Chunks = append(result.Chunks, chunk)
 				currentTokens += chunkTokens
@@ -632,7 +574,7 @@ func (s *mcpContextServiceImpl) RetrieveMCPContext(ctx context.Context, query st
 
 				summary, err := s.GetDocumentSummary(ctx, chunk.DocumentID, tenantID)
 				if err == nil && summary != "" {
-					summaryTokens := len(summary) / 4
+					summaryTokens := tokenizer.FromContext(ctx).Count(summary)
 					if currentTokens+summaryTokens <= maxTokens {
 						// Add summary as a special chunk
 						summaryChunk := models.RetrievedChunk{
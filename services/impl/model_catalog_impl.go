@@ -0,0 +1,435 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultModelCatalogTTL = 10 * time.Minute
+
+// defaultCatalogFailureBackoff is how long a failed router fetch is remembered before it is retried,
+// so an execution that looks up models repeatedly does not wait on an unreachable router each time
+const defaultCatalogFailureBackoff = 30 * time.Second
+
+// modelCatalogServiceImpl caches provider capabilities from the LLM router.
+// Reads take an RLock; concurrent cache misses for the same router path share
+// one fetch through fetches, and no lock is held while it runs, so a slow
+// provider only delays lookups of that provider. Failed fetches are remembered
+// per router path for failureBackoff and answered from failures until then.
+type modelCatalogServiceImpl struct {
+	config         *config.RouterConfig
+	httpClient     *http.Client
+	ttl            time.Duration
+	failureBackoff time.Duration
+
+	mu            sync.RWMutex
+	providerNames []string
+	listedAt      time.Time
+	providers     map[string]*catalogProvider
+	models        map[string]*services.ModelCapabilities
+	failures      map[string]catalogFailure // Keyed by router path
+
+	fetches singleflight.Group // Keyed by router path
+}
+
+// catalogProvider is a cached provider entry
+type catalogProvider struct {
+	info      services.ProviderCapabilities
+	fetchedAt time.Time
+}
+
+// catalogFailure is a failed router fetch that is not retried until the backoff has passed
+type catalogFailure struct {
+	err      error
+	failedAt time.Time
+}
+
+// NewModelCatalogService creates a model catalog backed by the router's /v1/providers API
+func NewModelCatalogService(cfg *config.RouterConfig) services.ModelCatalogService {
+	ttl := time.Duration(cfg.ModelCatalogTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultModelCatalogTTL
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &modelCatalogServiceImpl{
+		config:         cfg,
+		httpClient:     &http.Client{Timeout: timeout},
+		ttl:            ttl,
+		failureBackoff: defaultCatalogFailureBackoff,
+		providers:      make(map[string]*catalogProvider),
+		models:         make(map[string]*services.ModelCapabilities),
+		failures:       make(map[string]catalogFailure),
+	}
+}
+
+// GetModel returns the capabilities of a single model, refreshing the owning provider when stale
+func (s *modelCatalogServiceImpl) GetModel(ctx context.Context, model string) (*services.ModelCapabilities, error) {
+	if m, ok := s.lookupFreshModel(model); ok {
+		return m, nil
+	}
+
+	// Try the provider implied by the model name first to avoid fetching every provider
+	if hint := extractProvider(model); hint != "unknown" {
+		if err := s.ensureProvider(ctx, hint); err == nil {
+			if m, ok := s.lookupModel(model); ok {
+				return m, nil
+			}
+		}
+	}
+
+	names, err := s.ensureProviderList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := s.ensureProvider(ctx, name); err != nil {
+			log.Printf("[CATALOG] Failed to load provider %s: %v", name, err)
+			continue
+		}
+		if m, ok := s.lookupModel(model); ok {
+			return m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", services.ErrModelNotFound, model)
+}
+
+// ListProviders returns every provider known to the router with its models
+func (s *modelCatalogServiceImpl) ListProviders(ctx context.Context) ([]services.ProviderCapabilities, error) {
+	names, err := s.ensureProviderList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]services.ProviderCapabilities, 0, len(names))
+	for _, name := range names {
+		if err := s.ensureProvider(ctx, name); err != nil {
+			// Keep the provider visible even if its details could not be loaded
+			log.Printf("[CATALOG] Failed to load provider %s: %v", name, err)
+			result = append(result, services.ProviderCapabilities{
+				Name:        name,
+				DisplayName: capitalizeFirst(name),
+				Models:      []services.ModelCapabilities{},
+			})
+			continue
+		}
+
+		s.mu.RLock()
+		result = append(result, copyProviderCapabilities(s.providers[name].info))
+		s.mu.RUnlock()
+	}
+
+	return result, nil
+}
+
+// ListModels returns the models offered by a provider
+func (s *modelCatalogServiceImpl) ListModels(ctx context.Context, provider string) ([]services.ModelCapabilities, error) {
+	names, err := s.ensureProviderList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := false
+	for _, name := range names {
+		if name == provider {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("%w: %s", services.ErrProviderNotFound, provider)
+	}
+
+	if err := s.ensureProvider(ctx, provider); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyProviderCapabilities(s.providers[provider].info).Models, nil
+}
+
+// Refresh forces a reload of the catalog from the router
+func (s *modelCatalogServiceImpl) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	s.listedAt = time.Time{}
+	for _, p := range s.providers {
+		p.fetchedAt = time.Time{}
+	}
+	s.failures = make(map[string]catalogFailure)
+	s.mu.Unlock()
+
+	_, err := s.ListProviders(ctx)
+	return err
+}
+
+// lookupFreshModel returns a cached model only if its provider entry is within the TTL
+func (s *modelCatalogServiceImpl) lookupFreshModel(model string) (*services.ModelCapabilities, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.models[model]
+	if !ok {
+		return nil, false
+	}
+	p, ok := s.providers[m.Provider]
+	if !ok || time.Since(p.fetchedAt) >= s.ttl {
+		return nil, false
+	}
+	return copyModelCapabilities(m), true
+}
+
+// lookupModel returns a cached model regardless of age
+func (s *modelCatalogServiceImpl) lookupModel(model string) (*services.ModelCapabilities, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.models[model]
+	if !ok {
+		return nil, false
+	}
+	return copyModelCapabilities(m), true
+}
+
+// ensureProviderList returns the provider names, refetching them when the TTL has expired.
+// A stale list is kept if the router is unreachable.
+func (s *modelCatalogServiceImpl) ensureProviderList(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	if !s.listedAt.IsZero() && time.Since(s.listedAt) < s.ttl {
+		names := append([]string(nil), s.providerNames...)
+		s.mu.RUnlock()
+		return names, nil
+	}
+	stale := append([]string(nil), s.providerNames...)
+	s.mu.RUnlock()
+
+	const path = "/v1/providers"
+	fetched, err, _ := s.fetches.Do(path, func() (interface{}, error) {
+		// Another caller may have refreshed the list since the check above
+		s.mu.RLock()
+		if !s.listedAt.IsZero() && time.Since(s.listedAt) < s.ttl {
+			names := append([]string(nil), s.providerNames...)
+			s.mu.RUnlock()
+			return names, nil
+		}
+		s.mu.RUnlock()
+
+		var providersResp ActualProvidersResponse
+		if err := s.fetchJSON(ctx, path, &providersResp); err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		s.providerNames = append([]string(nil), providersResp.Providers...)
+		s.listedAt = time.Now()
+		s.mu.Unlock()
+		return providersResp.Providers, nil
+	})
+	if err != nil {
+		if len(stale) > 0 {
+			log.Printf("[CATALOG] Failed to refresh provider list, serving stale entries: %v", err)
+			return stale, nil
+		}
+		return nil, fmt.Errorf("failed to fetch providers: %w", err)
+	}
+
+	return append([]string(nil), fetched.([]string)...), nil
+}
+
+// ensureProvider loads a provider's models when missing or older than the TTL.
+// A stale entry is kept if the router is unreachable.
+func (s *modelCatalogServiceImpl) ensureProvider(ctx context.Context, provider string) error {
+	if s.providerFresh(provider) {
+		return nil
+	}
+
+	path := "/v1/providers/" + provider
+	_, err, _ := s.fetches.Do(path, func() (interface{}, error) {
+		if s.providerFresh(provider) {
+			return nil, nil
+		}
+		var providerResp ActualProviderResponse
+		if err := s.fetchJSON(ctx, path, &providerResp); err != nil {
+			return nil, err
+		}
+		s.storeProvider(provider, buildProviderCapabilities(provider, providerResp, time.Now()))
+		return nil, nil
+	})
+	if err != nil {
+		s.mu.RLock()
+		_, hasStale := s.providers[provider]
+		s.mu.RUnlock()
+		if hasStale {
+			log.Printf("[CATALOG] Failed to refresh provider %s, serving stale entry: %v", provider, err)
+			return nil
+		}
+		return fmt.Errorf("failed to fetch provider %s: %w", provider, err)
+	}
+	return nil
+}
+
+// storeProvider replaces a provider's cached entry and models
+func (s *modelCatalogServiceImpl) storeProvider(provider string, info services.ProviderCapabilities) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop models the provider no longer offers
+	if old, ok := s.providers[provider]; ok {
+		for _, m := range old.info.Models {
+			delete(s.models, m.Name)
+		}
+	}
+	for i := range info.Models {
+		m := info.Models[i]
+		s.models[m.Name] = &m
+	}
+	s.providers[provider] = &catalogProvider{info: info, fetchedAt: time.Now()}
+}
+
+// providerFresh reports whether a provider entry exists and is within the TTL
+func (s *modelCatalogServiceImpl) providerFresh(provider string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.providers[provider]
+	return ok && time.Since(p.fetchedAt) < s.ttl
+}
+
+// fetchJSON fetches path unless it failed within the backoff window, in which case that
+// failure is returned again without contacting the router
+func (s *modelCatalogServiceImpl) fetchJSON(ctx context.Context, path string, out interface{}) error {
+	s.mu.RLock()
+	failure, failed := s.failures[path]
+	s.mu.RUnlock()
+	if failed && time.Since(failure.failedAt) < s.failureBackoff {
+		return failure.err
+	}
+
+	err := s.getJSON(context.WithoutCancel(ctx), path, out)
+
+	s.mu.Lock()
+	if err != nil {
+		s.failures[path] = catalogFailure{err: err, failedAt: time.Now()}
+	} else {
+		delete(s.failures, path)
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// getJSON performs an authenticated GET against the router and decodes the JSON body
+func (s *modelCatalogServiceImpl) getJSON(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.config.BaseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.config.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("router returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// buildProviderCapabilities converts the router's provider response into catalog entries
+func buildProviderCapabilities(provider string, resp ActualProviderResponse, fetchedAt time.Time) services.ProviderCapabilities {
+	caps := resp.Capabilities
+	providerFeatures := []string{"chat"}
+	if caps.SupportsFunctions {
+		providerFeatures = append(providerFeatures, "functions")
+	}
+	if caps.SupportsVision {
+		providerFeatures = append(providerFeatures, "vision")
+	}
+	if caps.SupportsStreaming {
+		providerFeatures = append(providerFeatures, "streaming")
+	}
+
+	info := services.ProviderCapabilities{
+		Name:             provider,
+		DisplayName:      capitalizeFirst(provider),
+		MaxContextWindow: caps.MaxContextWindow,
+		Features:         providerFeatures,
+		Models:           make([]services.ModelCapabilities, 0, len(caps.SupportedModels)),
+	}
+
+	for _, m := range caps.SupportedModels {
+		features := m.Features
+		if len(features) == 0 {
+			features = providerFeatures
+		}
+		contextWindow := m.MaxContextWindow
+		if contextWindow == 0 {
+			contextWindow = caps.MaxContextWindow
+		}
+		displayName := m.DisplayName
+		if displayName == "" {
+			displayName = m.Name
+		}
+		encoding := m.Tokenizer
+		if encoding == "" {
+			encoding = tokenizer.EncodingForModel(m.Name)
+		}
+
+		info.Models = append(info.Models, services.ModelCapabilities{
+			Name:            m.Name,
+			DisplayName:     displayName,
+			Provider:        provider,
+			ProviderModelID: m.ProviderModelID,
+			ContextWindow:   contextWindow,
+			MaxOutputTokens: m.MaxOutputTokens,
+			Features:        append([]string(nil), features...),
+			InputCostPer1K:  m.InputCostPer1K,
+			OutputCostPer1K: m.OutputCostPer1K,
+			Tokenizer:       encoding,
+			FetchedAt:       fetchedAt,
+		})
+	}
+
+	return info
+}
+
+func copyModelCapabilities(m *services.ModelCapabilities) *services.ModelCapabilities {
+	c := *m
+	c.Features = append([]string(nil), m.Features...)
+	return &c
+}
+
+func copyProviderCapabilities(p services.ProviderCapabilities) services.ProviderCapabilities {
+	c := p
+	c.Features = append([]string(nil), p.Features...)
+	c.Models = make([]services.ModelCapabilities, len(p.Models))
+	for i := range p.Models {
+		c.Models[i] = *copyModelCapabilities(&p.Models[i])
+	}
+	return c
+}
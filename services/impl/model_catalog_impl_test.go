@@ -0,0 +1,192 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/services"
+)
+
+// newTestCatalogRouter serves a minimal /v1/providers API and counts provider detail fetches
+func newTestCatalogRouter(t *testing.T, fetches *int32) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/providers":
+			json.NewEncoder(w).Encode(ActualProvidersResponse{Count: 1, Providers: []string{"openai"}})
+		case "/v1/providers/openai":
+			atomic.AddInt32(fetches, 1)
+			json.NewEncoder(w).Encode(ActualProviderResponse{
+				Name: "openai",
+				Capabilities: ProviderCapabilities{
+					MaxContextWindow:  128000,
+					SupportsFunctions: true,
+					SupportsStreaming: true,
+					SupportedModels: []SupportedModel{
+						{Name: "gpt-4o", MaxContextWindow: 128000, MaxOutputTokens: 16384, InputCostPer1K: 0.0025},
+						{Name: "gpt-4o-vision", MaxOutputTokens: 4096, Features: []string{"chat", "vision"}},
+					},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestModelCatalogGetModel(t *testing.T) {
+	var fetches int32
+	server := newTestCatalogRouter(t, &fetches)
+	defer server.Close()
+
+	catalog := NewModelCatalogService(&config.RouterConfig{BaseURL: server.URL, ModelCatalogTTL: 60})
+
+	t.Run("returns capabilities with provider features", func(t *testing.T) {
+		m, err := catalog.GetModel(context.Background(), "gpt-4o")
+		require.NoError(t, err)
+		assert.Equal(t, "openai", m.Provider)
+		assert.Equal(t, 128000, m.ContextWindow)
+		assert.Equal(t, 16384, m.MaxOutputTokens)
+		assert.Equal(t, "o200k_base", m.Tokenizer, "tokenizer derived from the model family")
+		assert.True(t, m.HasFeature("functions"))
+		assert.False(t, m.HasFeature("vision"))
+	})
+
+	t.Run("model features override provider features", func(t *testing.T) {
+		m, err := catalog.GetModel(context.Background(), "gpt-4o-vision")
+		require.NoError(t, err)
+		assert.True(t, m.HasFeature("vision"))
+		assert.False(t, m.HasFeature("functions"))
+		assert.Equal(t, 128000, m.ContextWindow, "context window falls back to provider maximum")
+	})
+
+	t.Run("unknown model returns ErrModelNotFound", func(t *testing.T) {
+		_, err := catalog.GetModel(context.Background(), "does-not-exist")
+		assert.ErrorIs(t, err, services.ErrModelNotFound)
+	})
+
+	t.Run("unknown provider returns ErrProviderNotFound", func(t *testing.T) {
+		_, err := catalog.ListModels(context.Background(), "nope")
+		assert.ErrorIs(t, err, services.ErrProviderNotFound)
+	})
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "cached provider should not be refetched within TTL")
+}
+
+func TestModelCatalogConcurrentAccess(t *testing.T) {
+	var fetches int32
+	server := newTestCatalogRouter(t, &fetches)
+	defer server.Close()
+
+	catalog := NewModelCatalogService(&config.RouterConfig{BaseURL: server.URL, ModelCatalogTTL: 60})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := catalog.GetModel(context.Background(), "gpt-4o")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "concurrent misses should trigger a single fetch")
+}
+
+func TestModelCatalogSlowProviderDoesNotBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/providers":
+			json.NewEncoder(w).Encode(ActualProvidersResponse{Count: 2, Providers: []string{"slow", "openai"}})
+		case "/v1/providers/slow":
+			<-release
+			json.NewEncoder(w).Encode(ActualProviderResponse{Name: "slow"})
+		case "/v1/providers/openai":
+			json.NewEncoder(w).Encode(ActualProviderResponse{
+				Name:         "openai",
+				Capabilities: ProviderCapabilities{SupportedModels: []SupportedModel{{Name: "gpt-4o"}}},
+			})
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	catalog := NewModelCatalogService(&config.RouterConfig{BaseURL: server.URL, ModelCatalogTTL: 60})
+	go catalog.ListModels(context.Background(), "slow")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := catalog.ListModels(context.Background(), "openai")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("lookup of openai waited for the slow provider")
+	}
+}
+
+func TestModelCatalogTTLExpiry(t *testing.T) {
+	var fetches int32
+	server := newTestCatalogRouter(t, &fetches)
+
+	catalog := NewModelCatalogService(&config.RouterConfig{BaseURL: server.URL}).(*modelCatalogServiceImpl)
+	catalog.ttl = 10 * time.Millisecond
+
+	_, err := catalog.GetModel(context.Background(), "gpt-4o")
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = catalog.GetModel(context.Background(), "gpt-4o")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "expired entry should be refetched")
+
+	// Stale entries are served when the router goes away
+	server.Close()
+	time.Sleep(20 * time.Millisecond)
+	m, err := catalog.GetModel(context.Background(), "gpt-4o")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", m.Name)
+}
+
+func TestModelCatalogRemembersFailedFetches(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	catalog := NewModelCatalogService(&config.RouterConfig{BaseURL: server.URL}).(*modelCatalogServiceImpl)
+
+	_, err := catalog.GetModel(context.Background(), "gpt-4o")
+	require.Error(t, err)
+	first := atomic.LoadInt32(&requests)
+
+	for i := 0; i < 4; i++ {
+		_, err := catalog.GetModel(context.Background(), "gpt-4o")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, first, atomic.LoadInt32(&requests), "failed fetches should not be retried within the backoff")
+
+	// Once the backoff has passed the router is asked again
+	catalog.failureBackoff = 10 * time.Millisecond
+	time.Sleep(20 * time.Millisecond)
+	_, err = catalog.GetModel(context.Background(), "gpt-4o")
+	assert.Error(t, err)
+	assert.Greater(t, atomic.LoadInt32(&requests), first)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
 // MultiPassService handles multi-pass document processing for large documents
@@ -41,7 +42,7 @@ func (s *MultiPassService) ExecuteMultiPass(
 	}
 
 	// Segment documents into chunks that fit the context window
-	segments := s.segmentDocuments(documents, config.SegmentSize, config.OverlapTokens)
+	segments := s.segmentDocuments(documents, config.SegmentSize, config.OverlapTokens, tokenizer.ForModel(agent.LLMConfig.Model))
 
 	if len(segments) == 0 {
 		return nil, fmt.Errorf("no document segments to process")
@@ -138,6 +139,7 @@ func (s *MultiPassService) segmentDocuments(
 	documents *models.DocumentContextResult,
 	segmentSize int,
 	overlapTokens int,
+	tok tokenizer.Tokenizer,
 ) []*models.ContextInjectionResult {
 	if documents == nil || len(documents.Chunks) == 0 {
 		return nil
@@ -148,7 +150,7 @@ func (s *MultiPassService) segmentDocuments(
 	currentTokens := 0
 
 	for _, chunk := range documents.Chunks {
-		chunkTokens := s.documentService.EstimateTokenCount(chunk.Content)
+		chunkTokens := tok.Count(chunk.Content)
 
 		// Check if adding this chunk would exceed segment size
 		if currentTokens+chunkTokens > segmentSize && len(currentChunks) > 0 {
@@ -157,11 +159,11 @@ func (s *MultiPassService) segmentDocuments(
 			segments = append(segments, segment)
 
 			// Start new segment with overlap
-			overlapChunks := s.getOverlapChunks(currentChunks, overlapTokens)
+			overlapChunks := s.getOverlapChunks(currentChunks, overlapTokens, tok)
 			currentChunks = overlapChunks
 			currentTokens = 0
 			for _, c := range overlapChunks {
-				currentTokens += s.documentService.EstimateTokenCount(c.Content)
+				currentTokens += tok.Count(c.Content)
 			}
 		}
 
@@ -217,7 +219,7 @@ func (s *MultiPassService) formatSegment(chunks []models.RetrievedChunk, totalTo
 }
 
 // getOverlapChunks returns chunks from the end of the list to provide context overlap
-func (s *MultiPassService) getOverlapChunks(chunks []models.RetrievedChunk, overlapTokens int) []models.RetrievedChunk {
+func (s *MultiPassService) getOverlapChunks(chunks []models.RetrievedChunk, overlapTokens int, tok tokenizer.Tokenizer) []models.RetrievedChunk {
 	if len(chunks) == 0 || overlapTokens <= 0 {
 		return nil
 	}
@@ -227,7 +229,7 @@ func (s *MultiPassService) getOverlapChunks(chunks []models.RetrievedChunk, over
 
 	// Work backwards from the end
 	for i := len(chunks) - 1; i >= 0 && tokens < overlapTokens; i-- {
-		chunkTokens := s.documentService.EstimateTokenCount(chunks[i].Content)
+		chunkTokens := tok.Count(chunks[i].Content)
 		overlapChunks = append([]models.RetrievedChunk{chunks[i]}, overlapChunks...)
 		tokens += chunkTokens
 	}
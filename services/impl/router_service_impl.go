@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -19,13 +20,18 @@ import (
 )
 
 type routerServiceImpl struct {
-	config           *config.RouterConfig
-	httpClient       *http.Client
-	streamClient     *http.Client // No total timeout, for SSE streaming
-	modelLimitsCache map[string]int // Cache for model max_output_tokens
+	config       *config.RouterConfig
+	httpClient   *http.Client
+	streamClient *http.Client // No total timeout, for SSE streaming
+	catalog      services.ModelCatalogService
 }
 
 func NewRouterService(cfg *config.RouterConfig) services.RouterService {
+	return NewRouterServiceWithCatalog(cfg, NewModelCatalogService(cfg))
+}
+
+// NewRouterServiceWithCatalog creates a router service that shares an existing model catalog
+func NewRouterServiceWithCatalog(cfg *config.RouterConfig, catalog services.ModelCatalogService) services.RouterService {
 	return &routerServiceImpl{
 		config: cfg,
 		httpClient: &http.Client{
@@ -36,7 +42,7 @@ func NewRouterService(cfg *config.RouterConfig) services.RouterService {
 			// timeout would kill long-running generations. Connection-level
 			// timeouts are handled by the default transport.
 		},
-		modelLimitsCache: make(map[string]int),
+		catalog: catalog,
 	}
 }
 
@@ -47,7 +53,6 @@ func (s *routerServiceImpl) SendRequest(ctx context.Context, agentConfig models.
 	// Build router request with streaming enabled
 	request := RouterRequest{
 		Model:            agentConfig.Model,
-		Messages:         make([]RouterMessage, len(messages)),
 		Temperature:      agentConfig.Temperature,
 		MaxTokens:        maxTokens,
 		TopP:             agentConfig.TopP,
@@ -63,12 +68,7 @@ func (s *routerServiceImpl) SendRequest(ctx context.Context, agentConfig models.
 	streaming := request.Stream
 
 	// Convert messages
-	for i, msg := range messages {
-		request.Messages[i] = RouterMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
+	request.Messages = buildRouterMessages(messages)
 
 	// Add metadata if present
 	if agentConfig.Metadata != nil {
@@ -176,7 +176,7 @@ func (s *routerServiceImpl) SendRequest(ctx context.Context, agentConfig models.
 			Model:           routerResp.Model,
 			RoutingStrategy: request.OptimizeFor,
 			TokenUsage:      routerResp.Usage.TotalTokens,
-			CostUSD:         calculateCostUSD(routerResp.Usage, routerResp.Model),
+			CostUSD:         s.calculateCost(ctx, routerResp.Usage, routerResp.Model),
 			ResponseTimeMs:  int(responseTime.Milliseconds()),
 			Metadata: map[string]interface{}{
 				"request_id":         routerResp.ID,
@@ -216,7 +216,6 @@ func (s *routerServiceImpl) SendRequestWithTools(ctx context.Context, agentConfi
 	// Build router request with tools — streaming enabled
 	request := RouterRequest{
 		Model:            agentConfig.Model,
-		Messages:         make([]RouterMessage, len(messages)),
 		Temperature:      agentConfig.Temperature,
 		MaxTokens:        maxTokens,
 		TopP:             agentConfig.TopP,
@@ -230,47 +229,12 @@ func (s *routerServiceImpl) SendRequestWithTools(ctx context.Context, agentConfi
 	}
 
 	// Convert messages including tool call fields
-	for i, msg := range messages {
-		rm := RouterMessage{
-			Role:       msg.Role,
-			Content:    msg.Content,
-			ToolCallID: msg.ToolCallID,
-		}
-		// Convert tool calls
-		if len(msg.ToolCalls) > 0 {
-			rm.ToolCalls = make([]RouterToolCall, len(msg.ToolCalls))
-			for j, tc := range msg.ToolCalls {
-				rm.ToolCalls[j] = RouterToolCall{
-					ID:   tc.ID,
-					Type: tc.Type,
-					Function: RouterToolCallFunction{
-						Name:      tc.Function.Name,
-						Arguments: tc.Function.Arguments,
-					},
-				}
-			}
-		}
-		request.Messages[i] = rm
-	}
+	request.Messages = buildRouterMessages(messages)
 
 	// Convert tool definitions
 	if len(tools) > 0 {
-		request.Tools = make([]RouterTool, len(tools))
-		for i, t := range tools {
-			request.Tools[i] = RouterTool{
-				Type: t.Type,
-				Function: RouterToolFunction{
-					Name:        t.Function.Name,
-					Description: t.Function.Description,
-					Parameters:  t.Function.Parameters,
-				},
-			}
-		}
-		if toolChoice != "" {
-			request.ToolChoice = toolChoice
-		} else {
-			request.ToolChoice = "auto"
-		}
+		request.Tools = buildRouterTools(tools)
+		request.ToolChoice = buildRouterToolChoice(toolChoice)
 	}
 
 	// Add metadata if present
@@ -393,7 +357,7 @@ func (s *routerServiceImpl) SendRequestWithTools(ctx context.Context, agentConfi
 			Model:           routerResp.Model,
 			RoutingStrategy: request.OptimizeFor,
 			TokenUsage:      routerResp.Usage.TotalTokens,
-			CostUSD:         calculateCostUSD(routerResp.Usage, routerResp.Model),
+			CostUSD:         s.calculateCost(ctx, routerResp.Usage, routerResp.Model),
 			ResponseTimeMs:  int(responseTime.Milliseconds()),
 			FinishReason:    choice.FinishReason,
 			Metadata: map[string]interface{}{
@@ -442,69 +406,47 @@ func (s *routerServiceImpl) ValidateConfig(ctx context.Context, config models.Ag
 		return fmt.Errorf("model is required")
 	}
 
-	// Check if provider/model is available
-	providers, err := s.GetAvailableProviders(ctx)
+	// Check provider/model availability against the model catalog
+	catalogModels, err := s.catalog.ListModels(ctx, config.Provider)
 	if err != nil {
+		if errors.Is(err, services.ErrProviderNotFound) {
+			return fmt.Errorf("provider %s not available", config.Provider)
+		}
 		return fmt.Errorf("failed to get available providers: %w", err)
 	}
 
-	for _, provider := range providers {
-		if provider.Name == config.Provider {
-			for _, model := range provider.Models {
-				if model == config.Model {
-					return nil
-				}
+	for i := range catalogModels {
+		if catalogModels[i].Name != config.Model {
+			continue
+		}
+		for _, feature := range config.RequiredFeatures {
+			if !catalogModels[i].HasFeature(feature) {
+				return fmt.Errorf("model %s does not support required feature %q", config.Model, feature)
 			}
-			return fmt.Errorf("model %s not available for provider %s", config.Model, config.Provider)
 		}
+		return nil
 	}
 
-	return fmt.Errorf("provider %s not available", config.Provider)
+	return fmt.Errorf("model %s not available for provider %s", config.Model, config.Provider)
 }
 
 func (s *routerServiceImpl) GetAvailableProviders(ctx context.Context) ([]services.Provider, error) {
-	url := fmt.Sprintf("%s/v1/providers", s.config.BaseURL)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	catalogProviders, err := s.catalog.ListProviders(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	if s.config.APIKey != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
-	}
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("router returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var providersResp ActualProvidersResponse
-	if err := json.NewDecoder(resp.Body).Decode(&providersResp); err != nil {
-		return nil, fmt.Errorf("failed to decode providers response: %w", err)
-	}
-
-	// Convert to service providers
-	providers := make([]services.Provider, len(providersResp.Providers))
-	for i, providerName := range providersResp.Providers {
-		providers[i] = services.Provider{
-			Name:        providerName,
-			DisplayName: capitalizeFirst(providerName),
-			Models:      []string{}, // We'll populate this with known models
-			Features:    []string{"chat_completions"},
+	providers := make([]services.Provider, len(catalogProviders))
+	for i, p := range catalogProviders {
+		modelNames := make([]string, len(p.Models))
+		for j, m := range p.Models {
+			modelNames[j] = m.Name
 		}
-		
-		// Add known models for each provider
-		switch providerName {
-		case "openai":
-			providers[i].Models = []string{"gpt-3.5-turbo", "gpt-4o", "gpt-4", "gpt-4-turbo"}
-		case "anthropic":
-			providers[i].Models = []string{"claude-sonnet-4-20250514", "claude-3-haiku-20240307", "claude-3-opus-20240229"}
+		providers[i] = services.Provider{
+			Name:        p.Name,
+			DisplayName: p.DisplayName,
+			Models:      modelNames,
+			Features:    p.Features,
 		}
 	}
 
@@ -512,46 +454,30 @@ func (s *routerServiceImpl) GetAvailableProviders(ctx context.Context) ([]servic
 }
 
 func (s *routerServiceImpl) GetProviderModels(ctx context.Context, provider string) ([]services.Model, error) {
-	url := fmt.Sprintf("%s/v1/providers/%s", s.config.BaseURL, provider)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	catalogModels, err := s.catalog.ListModels(ctx, provider)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	if s.config.APIKey != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
-	}
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("router returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var providerResp ProviderResponse
-	if err := json.NewDecoder(resp.Body).Decode(&providerResp); err != nil {
-		return nil, fmt.Errorf("failed to decode provider response: %w", err)
-	}
-
-	// Convert to service models
-	models := make([]services.Model, len(providerResp.Models))
-	for i, m := range providerResp.Models {
-		models[i] = services.Model{
-			Name:         m.Name,
-			DisplayName:  m.DisplayName,
-			Provider:     provider,
-			MaxTokens:    m.MaxTokens,
-			CostPer1000:  m.CostPer1000,
-			Features:     m.Features,
+	result := make([]services.Model, len(catalogModels))
+	for i, m := range catalogModels {
+		result[i] = services.Model{
+			Name:            m.Name,
+			DisplayName:     m.DisplayName,
+			Provider:        provider,
+			MaxTokens:       m.MaxOutputTokens,
+			ContextWindow:   m.ContextWindow,
+			CostPer1000:     m.InputCostPer1K,
+			Features:        m.Features,
 		}
 	}
 
-	return models, nil
+	return result, nil
+}
+
+// GetModelCapabilities looks the agent's model up in the model catalog
+func (s *routerServiceImpl) GetModelCapabilities(ctx context.Context, agentConfig models.AgentLLMConfig) (*services.ModelCapabilities, error) {
+	return s.catalog.GetModel(ctx, agentConfig.Model)
 }
 
 // getStreaming returns the streaming setting from the agent config.
@@ -610,10 +536,43 @@ type FallbackConfig struct {
 }
 
 type RouterMessage struct {
-	Role       string            `json:"role"`
-	Content    string            `json:"content"`
-	ToolCallID string            `json:"tool_call_id,omitempty"`
-	ToolCalls  []RouterToolCall  `json:"tool_calls,omitempty"`
+	Role       string              `json:"role"`
+	Content    string              `json:"content"`
+	Parts      []RouterContentPart `json:"-"` // Replaces Content with a content array when set
+	ToolCallID string              `json:"tool_call_id,omitempty"`
+	ToolCalls  []RouterToolCall    `json:"tool_calls,omitempty"`
+}
+
+// MarshalJSON sends multimodal messages in the OpenAI content array format
+func (m RouterMessage) MarshalJSON() ([]byte, error) {
+	type plain RouterMessage
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []RouterContentPart `json:"content"`
+	}{plain: plain(m), Content: m.Parts})
+}
+
+// RouterContentPart is an OpenAI-compatible message content part
+type RouterContentPart struct {
+	Type     string          `json:"type"` // text, image_url, file
+	Text     string          `json:"text,omitempty"`
+	ImageURL *RouterImageURL `json:"image_url,omitempty"`
+	File     *RouterFile     `json:"file,omitempty"`
+}
+
+// RouterImageURL references an image by URL or data URL
+type RouterImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// RouterFile carries an inline file as a data URL
+type RouterFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"`
 }
 
 // RouterTool represents a tool definition sent to the LLM router
@@ -703,19 +662,24 @@ type ActualProviderResponse struct {
 }
 
 type ProviderCapabilities struct {
-	ProviderName     string              `json:"provider_name"`
-	SupportedModels  []SupportedModel    `json:"supported_models"`
-	MaxContextWindow int                 `json:"max_context_window"`
+	ProviderName      string           `json:"provider_name"`
+	SupportedModels   []SupportedModel `json:"supported_models"`
+	MaxContextWindow  int              `json:"max_context_window"`
+	SupportsFunctions bool             `json:"supports_functions"`
+	SupportsVision    bool             `json:"supports_vision"`
+	SupportsStreaming bool             `json:"supports_streaming"`
 }
 
 type SupportedModel struct {
-	Name            string  `json:"name"`
-	DisplayName     string  `json:"display_name"`
-	MaxContextWindow int    `json:"max_context_window"`
-	MaxOutputTokens int     `json:"max_output_tokens"`
-	InputCostPer1K  float64 `json:"input_cost_per_1k"`
-	OutputCostPer1K float64 `json:"output_cost_per_1k"`
-	ProviderModelID string  `json:"provider_model_id"`
+	Name             string   `json:"name"`
+	DisplayName      string   `json:"display_name"`
+	MaxContextWindow int      `json:"max_context_window"`
+	MaxOutputTokens  int      `json:"max_output_tokens"`
+	InputCostPer1K   float64  `json:"input_cost_per_1k"`
+	OutputCostPer1K  float64  `json:"output_cost_per_1k"`
+	ProviderModelID  string   `json:"provider_model_id"`
+	Features         []string `json:"features,omitempty"`
+	Tokenizer        string   `json:"tokenizer,omitempty"`
 }
 
 // Helper functions
@@ -1051,75 +1015,146 @@ func readStreamResponse(body io.Reader) (*RouterAPIResponse, error) {
 	}, nil
 }
 
-func calculateCostUSD(usage RouterUsage, model string) float64 {
-	// This is a simplified cost calculation
-	// In production, this should use the router's cost calculation
-	switch {
-	case model == "gpt-3.5-turbo":
-		return float64(usage.TotalTokens) * 0.001 / 1000 // $0.001 per 1K tokens
-	case model == "gpt-4o":
-		return float64(usage.TotalTokens) * 0.03 / 1000 // $0.03 per 1K tokens
-	case len(model) > 6 && model[:6] == "claude":
-		return float64(usage.TotalTokens) * 0.015 / 1000 // $0.015 per 1K tokens
-	default:
-		return 0.0
+// buildRouterMessages converts messages, including tool call fields and multimodal parts,
+// to the OpenAI-compatible wire format
+func buildRouterMessages(messages []services.Message) []RouterMessage {
+	result := make([]RouterMessage, len(messages))
+	for i, msg := range messages {
+		rm := RouterMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Parts:      buildRouterContentParts(msg.Content, msg.Parts),
+			ToolCallID: msg.ToolCallID,
+		}
+		if len(msg.ToolCalls) > 0 {
+			rm.ToolCalls = make([]RouterToolCall, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				rm.ToolCalls[j] = RouterToolCall{
+					ID:   tc.ID,
+					Type: tc.Type,
+					Function: RouterToolCallFunction{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				}
+			}
+		}
+		result[i] = rm
 	}
+	return result
 }
 
-// getModelMaxOutputTokens fetches the max output tokens for a model from the LLM router
-// Uses a cache to avoid repeated API calls
-func (s *routerServiceImpl) getModelMaxOutputTokens(ctx context.Context, model string) int {
-	// Check cache first
-	if limit, ok := s.modelLimitsCache[model]; ok {
-		return limit
+// buildRouterTools converts tool definitions to the OpenAI-compatible wire format
+func buildRouterTools(tools []services.ToolDefinition) []RouterTool {
+	result := make([]RouterTool, len(tools))
+	for i, t := range tools {
+		result[i] = RouterTool{
+			Type: t.Type,
+			Function: RouterToolFunction{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				Parameters:  t.Function.Parameters,
+			},
+		}
 	}
+	return result
+}
 
-	// Determine provider from model name
-	provider := extractProvider(model)
-	if provider == "unknown" {
-		return 4096 // Safe default
+// buildRouterToolChoice converts a tool choice to the OpenAI-compatible wire format, in which
+// a specific tool is named by an object rather than a string
+func buildRouterToolChoice(choice string) interface{} {
+	switch choice {
+	case "":
+		return "auto"
+	case "auto", "required", "none":
+		return choice
 	}
-
-	// Fetch from router
-	url := fmt.Sprintf("%s/v1/providers/%s", s.config.BaseURL, provider)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return 4096 // Safe default on error
+	return map[string]interface{}{
+		"type":     "function",
+		"function": map[string]string{"name": choice},
 	}
+}
 
-	if s.config.APIKey != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.config.APIKey))
+// buildRouterContentParts converts multimodal parts to the OpenAI content array, with the
+// text content first. File parts must already be resolved to a URL or inline data.
+func buildRouterContentParts(content string, parts []models.ContentPart) []RouterContentPart {
+	if len(parts) == 0 {
+		return nil
 	}
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return 4096 // Safe default on error
+	result := make([]RouterContentPart, 0, len(parts)+1)
+	if content != "" {
+		result = append(result, RouterContentPart{Type: "text", Text: content})
+	}
+
+	for _, p := range parts {
+		switch {
+		case p.Type == models.ContentPartText:
+			result = append(result, RouterContentPart{Type: "text", Text: p.Text})
+		case p.Type == models.ContentPartImageURL || (p.IsImage() && p.Data == "" && p.URL != ""):
+			result = append(result, RouterContentPart{Type: "image_url", ImageURL: &RouterImageURL{URL: p.URL, Detail: p.Detail}})
+		case p.IsImage() && p.Data != "":
+			dataURL := fmt.Sprintf("data:%s;base64,%s", p.MediaType, p.Data)
+			result = append(result, RouterContentPart{Type: "image_url", ImageURL: &RouterImageURL{URL: dataURL, Detail: p.Detail}})
+		case p.Type == models.ContentPartFile && p.Data != "":
+			dataURL := fmt.Sprintf("data:%s;base64,%s", p.MediaType, p.Data)
+			result = append(result, RouterContentPart{Type: "file", File: &RouterFile{Filename: p.Filename, FileData: dataURL}})
+		default:
+			log.Printf("[ROUTER] Skipping unresolved %s content part", p.Type)
+		}
 	}
-	defer resp.Body.Close()
+	return result
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return 4096 // Safe default on error
-	}
+// calculateCost prices a response using the model catalog
+func (s *routerServiceImpl) calculateCost(ctx context.Context, usage RouterUsage, model string) float64 {
+	return catalogCostUSD(ctx, s.catalog, usage, model)
+}
 
-	var providerResp ActualProviderResponse
-	if err := json.NewDecoder(resp.Body).Decode(&providerResp); err != nil {
-		return 4096 // Safe default on error
+// catalogCostUSD prices usage with the model catalog, falling back to the static table when
+// the catalog is unavailable or has no pricing for the model
+func catalogCostUSD(ctx context.Context, catalog services.ModelCatalogService, usage RouterUsage, model string) float64 {
+	if m, err := modelCapabilities(ctx, catalog, model); err == nil && (m.InputCostPer1K > 0 || m.OutputCostPer1K > 0) {
+		return float64(usage.PromptTokens)*m.InputCostPer1K/1000 +
+			float64(usage.CompletionTokens)*m.OutputCostPer1K/1000
 	}
+	return calculateCostUSD(usage, model)
+}
 
-	// Find the model in supported models and cache all models from this provider
-	for _, m := range providerResp.Capabilities.SupportedModels {
-		if m.MaxOutputTokens > 0 {
-			s.modelLimitsCache[m.Name] = m.MaxOutputTokens
-		}
+// modelCapabilities returns the capabilities of model carried by ctx, asking the catalog only
+// when the request has not looked them up already
+func modelCapabilities(ctx context.Context, catalog services.ModelCatalogService, model string) (*services.ModelCapabilities, error) {
+	if m, ok := services.ModelCapabilitiesFromContext(ctx, model); ok {
+		return m, nil
 	}
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: %s", services.ErrModelNotFound, model)
+	}
+	return catalog.GetModel(ctx, model)
+}
 
-	// Return the limit for the requested model
-	if limit, ok := s.modelLimitsCache[model]; ok {
-		return limit
+func calculateCostUSD(usage RouterUsage, model string) float64 {
+	// This is a simplified cost calculation
+	// In production, this should use the router's cost calculation
+	switch {
+	case model == "gpt-3.5-turbo":
+		return float64(usage.TotalTokens) * 0.001 / 1000 // $0.001 per 1K tokens
+	case model == "gpt-4o":
+		return float64(usage.TotalTokens) * 0.03 / 1000 // $0.03 per 1K tokens
+	case len(model) > 6 && model[:6] == "claude":
+		return float64(usage.TotalTokens) * 0.015 / 1000 // $0.015 per 1K tokens
+	default:
+		return 0.0
 	}
+}
 
-	// Model not found in provider response
-	return 4096
+// getModelMaxOutputTokens returns the max output tokens for a model from the model catalog
+func (s *routerServiceImpl) getModelMaxOutputTokens(ctx context.Context, model string) int {
+	m, err := modelCapabilities(ctx, s.catalog, model)
+	if err != nil || m.MaxOutputTokens <= 0 {
+		return 4096 // Safe default
+	}
+	return m.MaxOutputTokens
 }
 
 // capMaxTokensForModel caps max_tokens to the model's maximum output token limit from the router
@@ -1135,4 +1170,4 @@ func (s *routerServiceImpl) capMaxTokensForModel(ctx context.Context, maxTokens
 	}
 
 	return maxTokens
-}
\ No newline at end of file
+}
@@ -0,0 +1,127 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/mockrouter"
+	"github.com/tas-agent-builder/mockrouter/mockroutertest"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+)
+
+func TestRouterMessageMultimodalJSON(t *testing.T) {
+	t.Run("plain messages keep string content", func(t *testing.T) {
+		data, err := json.Marshal(RouterMessage{Role: "user", Content: "hi"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"role":"user","content":"hi"}`, string(data))
+	})
+
+	t.Run("parts are sent as an OpenAI content array", func(t *testing.T) {
+		msg := RouterMessage{
+			Role:    "user",
+			Content: "What is in these?",
+			Parts: buildRouterContentParts("What is in these?", []models.ContentPart{
+				{Type: models.ContentPartImageURL, URL: "https://example.com/cat.png", Detail: "low"},
+				{Type: models.ContentPartImageBase64, MediaType: "image/png", Data: "iVBORw0K"},
+				{Type: models.ContentPartFile, FileID: "f1", MediaType: "image/jpeg", URL: "https://signed.example.com/f1"},
+				{Type: models.ContentPartFile, FileID: "f2", Filename: "report.pdf", MediaType: "application/pdf", Data: "JVBERi0="},
+				{Type: models.ContentPartFile, FileID: "unresolved"},
+			}),
+		}
+
+		data, err := json.Marshal(msg)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"role": "user",
+			"content": [
+				{"type": "text", "text": "What is in these?"},
+				{"type": "image_url", "image_url": {"url": "https://example.com/cat.png", "detail": "low"}},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,iVBORw0K"}},
+				{"type": "image_url", "image_url": {"url": "https://signed.example.com/f1"}},
+				{"type": "file", "file": {"filename": "report.pdf", "file_data": "data:application/pdf;base64,JVBERi0="}}
+			]
+		}`, string(data))
+	})
+
+	t.Run("responses still decode string content", func(t *testing.T) {
+		var msg RouterMessage
+		require.NoError(t, json.Unmarshal([]byte(`{"role":"assistant","content":"a cat"}`), &msg))
+		assert.Equal(t, "a cat", msg.Content)
+	})
+}
+
+func TestRouterServiceAgainstMockRouter(t *testing.T) {
+	mock, server := mockroutertest.NewServer(t)
+	svc := NewRouterService(&config.RouterConfig{BaseURL: server.URL, Timeout: 5, MaxRetries: 1, ModelCatalogTTL: 60})
+
+	streaming := true
+	agentConfig := models.AgentLLMConfig{Provider: "openai", Model: "gpt-4o", Streaming: &streaming}
+	messages := []services.Message{{Role: "user", Content: "hello there"}}
+
+	t.Run("streams content and reliability metadata", func(t *testing.T) {
+		mock.Enqueue(mockrouter.Response{
+			Content:         "General Kenobi",
+			Provider:        "anthropic",
+			Retries:         2,
+			FallbackUsed:    true,
+			FailedProviders: []string{"openai"},
+		})
+
+		resp, err := svc.SendRequest(context.Background(), agentConfig, messages, uuid.New())
+		require.NoError(t, err)
+		assert.Equal(t, "General Kenobi", resp.Content)
+		assert.Equal(t, "anthropic", resp.Provider)
+		assert.Equal(t, 2, resp.Metadata["retry_attempts"])
+		assert.Equal(t, true, resp.Metadata["fallback_used"])
+		assert.Equal(t, []string{"openai"}, resp.Metadata["failed_providers"])
+
+		last := mock.Requests()[len(mock.Requests())-1]
+		assert.True(t, last.Stream)
+		assert.Equal(t, "hello there", last.Messages[0].Text())
+	})
+
+	t.Run("accumulates streamed tool calls", func(t *testing.T) {
+		mock.Enqueue(mockrouter.Response{ToolCalls: []mockrouter.ToolCall{
+			{Name: "search", Arguments: `{"query":"lightsabers"}`},
+			{Name: "fetch", Arguments: `{"url":"https://example.com"}`},
+		}})
+
+		tools := []services.ToolDefinition{{Type: "function", Function: services.ToolFunctionDef{Name: "search"}}}
+		resp, err := svc.SendRequestWithTools(context.Background(), agentConfig, messages, tools, "required", uuid.New())
+		require.NoError(t, err)
+		assert.Equal(t, "tool_calls", resp.FinishReason)
+		require.Len(t, resp.ToolCalls, 2)
+		assert.Equal(t, "search", resp.ToolCalls[0].Function.Name)
+		assert.JSONEq(t, `{"query":"lightsabers"}`, resp.ToolCalls[0].Function.Arguments)
+		assert.JSONEq(t, `{"url":"https://example.com"}`, resp.ToolCalls[1].Function.Arguments)
+
+		last := mock.Requests()[len(mock.Requests())-1]
+		assert.Equal(t, []string{"search"}, last.ToolNames())
+		assert.Equal(t, "required", last.ToolChoice)
+	})
+
+	t.Run("retries server errors", func(t *testing.T) {
+		off := false
+		syncConfig := agentConfig
+		syncConfig.Streaming = &off
+		mock.Enqueue(mockrouter.Response{StatusCode: 503}, mockrouter.Response{Content: "recovered"})
+
+		resp, err := svc.SendRequest(context.Background(), syncConfig, messages, uuid.New())
+		require.NoError(t, err)
+		assert.Equal(t, "recovered", resp.Content)
+		assert.Zero(t, mock.Pending())
+	})
+
+	t.Run("client errors are returned", func(t *testing.T) {
+		mock.Enqueue(mockrouter.Response{StatusCode: 400, Error: "bad model"})
+		_, err := svc.SendRequest(context.Background(), agentConfig, messages, uuid.New())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bad model")
+	})
+}
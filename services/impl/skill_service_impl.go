@@ -1,283 +1,905 @@
-package impl
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
-	"github.com/google/uuid"
-	"github.com/tas-agent-builder/models"
-	"github.com/tas-agent-builder/services"
-	"gorm.io/datatypes"
-	"gorm.io/gorm"
-)
-
-type skillServiceImpl struct {
-	db *gorm.DB
-}
-
-// NewSkillService creates a new SkillService implementation
-func NewSkillService(db *gorm.DB) services.SkillService {
-	return &skillServiceImpl{db: db}
-}
-
-func (s *skillServiceImpl) Create(ctx context.Context, skill *models.Skill) error {
-	if skill.ID == uuid.Nil {
-		skill.ID = uuid.New()
-	}
-	skill.CreatedAt = time.Now()
-	skill.UpdatedAt = time.Now()
-
-	if err := s.db.WithContext(ctx).Create(skill).Error; err != nil {
-		return fmt.Errorf("failed to create skill: %w", err)
-	}
-	return nil
-}
-
-func (s *skillServiceImpl) GetByID(ctx context.Context, id uuid.UUID) (*models.Skill, error) {
-	var skill models.Skill
-	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&skill).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("skill not found")
-		}
-		return nil, fmt.Errorf("failed to get skill: %w", err)
-	}
-	return &skill, nil
-}
-
-func (s *skillServiceImpl) GetByName(ctx context.Context, name string) (*models.Skill, error) {
-	var skill models.Skill
-	if err := s.db.WithContext(ctx).Where("name = ? AND deleted_at IS NULL", name).First(&skill).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("skill not found: %s", name)
-		}
-		return nil, fmt.Errorf("failed to get skill: %w", err)
-	}
-	return &skill, nil
-}
-
-func (s *skillServiceImpl) List(ctx context.Context, filter models.SkillListFilter) (*models.SkillListResponse, error) {
-	query := s.db.WithContext(ctx).Model(&models.Skill{}).Where("deleted_at IS NULL")
-
-	if filter.Type != nil {
-		query = query.Where("type = ?", *filter.Type)
-	}
-	if filter.IsPublic != nil {
-		query = query.Where("is_public = ?", *filter.IsPublic)
-	}
-	if filter.Search != "" {
-		searchTerm := "%" + strings.ToLower(filter.Search) + "%"
-		query = query.Where("LOWER(name) LIKE ? OR LOWER(display_name) LIKE ? OR LOWER(description) LIKE ?",
-			searchTerm, searchTerm, searchTerm)
-	}
-	if len(filter.Tags) > 0 {
-		for _, tag := range filter.Tags {
-			query = query.Where("tags @> ?", datatypes.JSON(fmt.Sprintf(`[%q]`, tag)))
-		}
-	}
-
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		return nil, fmt.Errorf("failed to count skills: %w", err)
-	}
-
-	page := filter.Page
-	if page < 1 {
-		page = 1
-	}
-	size := filter.Size
-	if size < 1 {
-		size = 50
-	}
-
-	var skills []models.Skill
-	if err := query.Order("name ASC").Offset((page - 1) * size).Limit(size).Find(&skills).Error; err != nil {
-		return nil, fmt.Errorf("failed to list skills: %w", err)
-	}
-
-	return &models.SkillListResponse{
-		Skills: skills,
-		Total:  total,
-		Page:   page,
-		Size:   size,
-	}, nil
-}
-
-func (s *skillServiceImpl) Update(ctx context.Context, id uuid.UUID, req models.UpdateSkillRequest) (*models.Skill, error) {
-	skill, err := s.GetByID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-
-	updates := map[string]any{}
-	if req.DisplayName != nil {
-		updates["display_name"] = *req.DisplayName
-	}
-	if req.Description != nil {
-		updates["description"] = *req.Description
-	}
-	if req.Icon != nil {
-		updates["icon"] = *req.Icon
-	}
-	if req.Tags != nil {
-		tagsJSON, _ := json.Marshal(req.Tags)
-		updates["tags"] = datatypes.JSON(tagsJSON)
-	}
-	if req.Keywords != nil {
-		keywordsJSON, _ := json.Marshal(req.Keywords)
-		updates["keywords"] = datatypes.JSON(keywordsJSON)
-	}
-	if req.MCPServerURL != nil {
-		updates["mcp_server_url"] = *req.MCPServerURL
-	}
-	if req.MCPToolNames != nil {
-		toolNamesJSON, _ := json.Marshal(req.MCPToolNames)
-		updates["mcp_tool_names"] = datatypes.JSON(toolNamesJSON)
-	}
-	if req.IsPublic != nil {
-		updates["is_public"] = *req.IsPublic
-	}
-	if req.Author != nil {
-		updates["author"] = *req.Author
-	}
-	if req.Version != nil {
-		updates["version"] = *req.Version
-	}
-	updates["updated_at"] = time.Now()
-
-	if err := s.db.WithContext(ctx).Model(skill).Updates(updates).Error; err != nil {
-		return nil, fmt.Errorf("failed to update skill: %w", err)
-	}
-
-	return s.GetByID(ctx, id)
-}
-
-func (s *skillServiceImpl) Delete(ctx context.Context, id uuid.UUID) error {
-	skill, err := s.GetByID(ctx, id)
-	if err != nil {
-		return err
-	}
-
-	if skill.IsSystem {
-		return fmt.Errorf("cannot delete system skill: %s", skill.Name)
-	}
-
-	now := time.Now()
-	if err := s.db.WithContext(ctx).Model(skill).Update("deleted_at", &now).Error; err != nil {
-		return fmt.Errorf("failed to delete skill: %w", err)
-	}
-	return nil
-}
-
-// ResolveForAgent returns the skills for an agent, combining explicit assignment and auto-detection from system prompt
-func (s *skillServiceImpl) ResolveForAgent(ctx context.Context, agent *models.Agent) ([]models.Skill, error) {
-	skillMap := make(map[string]*models.Skill)
-
-	// 1. Load explicitly assigned skills
-	var explicitNames []string
-	if agent.Skills != nil {
-		if err := json.Unmarshal(agent.Skills, &explicitNames); err != nil {
-			log.Printf("[SKILLS] Warning: failed to parse agent skills JSON: %v", err)
-		}
-	}
-
-	for _, name := range explicitNames {
-		skill, err := s.GetByName(ctx, name)
-		if err != nil {
-			log.Printf("[SKILLS] Warning: explicit skill %q not found: %v", name, err)
-			continue
-		}
-		skillMap[skill.Name] = skill
-	}
-
-	// 2. Auto-detect from system prompt keywords
-	if agent.SystemPrompt != "" {
-		promptLower := strings.ToLower(agent.SystemPrompt)
-
-		var allSkills []models.Skill
-		if err := s.db.WithContext(ctx).Where("deleted_at IS NULL").Find(&allSkills).Error; err != nil {
-			log.Printf("[SKILLS] Warning: failed to load skills for auto-detect: %v", err)
-		} else {
-			for i := range allSkills {
-				if _, exists := skillMap[allSkills[i].Name]; exists {
-					continue // Already explicitly assigned
-				}
-
-				var keywords []string
-				if err := json.Unmarshal(allSkills[i].Keywords, &keywords); err != nil {
-					continue
-				}
-
-				for _, kw := range keywords {
-					if strings.Contains(promptLower, strings.ToLower(kw)) {
-						skillMap[allSkills[i].Name] = &allSkills[i]
-						break
-					}
-				}
-			}
-		}
-	}
-
-	// Collect results
-	result := make([]models.Skill, 0, len(skillMap))
-	for _, skill := range skillMap {
-		result = append(result, *skill)
-	}
-
-	log.Printf("[SKILLS] Resolved %d skills for agent %s (explicit: %d, auto-detected: %d)",
-		len(result), agent.ID, len(explicitNames), len(result)-len(explicitNames))
-
-	return result, nil
-}
-
-// SeedDefaults inserts built-in skills if they don't exist
-func (s *skillServiceImpl) SeedDefaults(ctx context.Context) error {
-	defaults := []models.Skill{
-		{
-			Name:        "visual_generation",
-			DisplayName: "Visual Generation",
-			Description: "Generate diagrams, mind maps, flowcharts, and visual content from text descriptions using Napkin AI",
-			Type:        models.SkillTypeMCP,
-			Icon:        "Image",
-			Tags:        mustJSON([]string{"visual", "diagram", "chart", "mindmap", "napkin"}),
-			Keywords:    mustJSON([]string{"visual", "diagram", "chart", "graph", "mindmap", "infographic", "illustration", "draw", "flowchart"}),
-			MCPServerURL: "http://napkin-mcp.tas-mcp-servers.svc.cluster.local:8087",
-			MCPToolNames: mustJSON([]string{"generate_visual", "list_styles", "get_visual_status", "download_visual", "list_visuals", "delete_visual"}),
-			IsPublic:    true,
-			IsSystem:    true,
-			Author:      "TAS Platform",
-			Version:     "1.0.0",
-		},
-	}
-
-	for _, skill := range defaults {
-		var existing models.Skill
-		result := s.db.WithContext(ctx).Where("name = ?", skill.Name).First(&existing)
-		if result.Error == gorm.ErrRecordNotFound {
-			skill.ID = uuid.New()
-			skill.CreatedAt = time.Now()
-			skill.UpdatedAt = time.Now()
-			if err := s.db.WithContext(ctx).Create(&skill).Error; err != nil {
-				log.Printf("[SKILLS] Warning: failed to seed skill %q: %v", skill.Name, err)
-			} else {
-				log.Printf("[SKILLS] Seeded default skill: %s", skill.Name)
-			}
-		} else if result.Error == nil {
-			log.Printf("[SKILLS] Default skill %q already exists, skipping", skill.Name)
-		}
-	}
-
-	return nil
-}
-
-// mustJSON marshals a value to datatypes.JSON, panicking on error
-func mustJSON(v any) datatypes.JSON {
-	b, err := json.Marshal(v)
-	if err != nil {
-		panic(fmt.Sprintf("mustJSON: %v", err))
-	}
-	return datatypes.JSON(b)
-}
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/builtin"
+	"github.com/tas-agent-builder/services/mcp"
+	"github.com/tas-agent-builder/services/relevance"
+	"github.com/tas-agent-builder/services/semver"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultSkillRelevanceThreshold is the relevance a skill's description needs to be selected
+	// without being assigned. One incidental word of a long prompt scores well below it.
+	defaultSkillRelevanceThreshold = 0.12
+
+	// skillIndexTTL bounds how long changes made by other replicas go unnoticed by selection
+	skillIndexTTL = time.Minute
+)
+
+type skillServiceImpl struct {
+	db *gorm.DB
+
+	relevanceThreshold float64
+	indexMu            sync.Mutex
+	index              *skillIndex // Built on first use and after skills change
+}
+
+// NewSkillService creates a new SkillService implementation. Skills an agent is not assigned
+// are selected for its executions when their relevance to the system prompt or input reaches
+// relevanceThreshold; 0 uses the default.
+func NewSkillService(db *gorm.DB, relevanceThreshold float64) services.SkillService {
+	if relevanceThreshold <= 0 {
+		relevanceThreshold = defaultSkillRelevanceThreshold
+	}
+	return &skillServiceImpl{db: db, relevanceThreshold: relevanceThreshold}
+}
+
+// visibleSkills limits a query to the skills a user of the tenant may see
+func visibleSkills(db *gorm.DB, caller services.Caller) *gorm.DB {
+	return db.Where("deleted_at IS NULL AND (visibility = ? OR (tenant_id = ? AND tenant_id <> '' AND (visibility = ? OR (visibility = ? AND owner_id = ?))))",
+		models.SkillVisibilityGlobal, caller.TenantID, models.SkillVisibilityTenant, models.SkillVisibilityPrivate, caller.UserID)
+}
+
+// disabledSkills returns the IDs of the global skills the tenant disabled
+func (s *skillServiceImpl) disabledSkills(ctx context.Context, tenantID string) (map[uuid.UUID]bool, error) {
+	if tenantID == "" {
+		return nil, nil
+	}
+	var ids []uuid.UUID
+	err := s.db.WithContext(ctx).Model(&models.TenantSkillSetting{}).
+		Where("tenant_id = ? AND NOT enabled", tenantID).Pluck("skill_id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenant skill settings: %w", err)
+	}
+	disabled := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		disabled[id] = true
+	}
+	return disabled, nil
+}
+
+func (s *skillServiceImpl) Create(ctx context.Context, skill *models.Skill) error {
+	if skill.ID == uuid.Nil {
+		skill.ID = uuid.New()
+	}
+	if skill.Visibility == "" {
+		skill.Visibility = models.SkillVisibilityPrivate
+	}
+	if skill.Visibility == models.SkillVisibilityGlobal {
+		skill.TenantID = ""
+	} else if skill.TenantID == "" {
+		return fmt.Errorf("%s skills need a tenant", skill.Visibility)
+	}
+	if skill.Version == "" {
+		skill.Version = "1.0.0"
+	}
+	if _, err := semver.Parse(skill.Version); err != nil {
+		return err
+	}
+	skill.CreatedAt = time.Now()
+	skill.UpdatedAt = time.Now()
+
+	// The skill's first version is recorded along with it
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Skill{}).Where("tenant_id = ? AND name = ? AND deleted_at IS NULL", skill.TenantID, skill.Name).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check skill name: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", services.ErrSkillExists, skill.Name)
+		}
+		if err := tx.Create(skill).Error; err != nil {
+			return fmt.Errorf("failed to create skill: %w", err)
+		}
+		if err := tx.Create(models.NewSkillVersion(skill)).Error; err != nil {
+			return fmt.Errorf("failed to record skill version: %w", err)
+		}
+		return nil
+	})
+	if err == nil {
+		s.invalidateIndex()
+	}
+	return err
+}
+
+func (s *skillServiceImpl) GetByID(ctx context.Context, id uuid.UUID) (*models.Skill, error) {
+	var skill models.Skill
+	if err := s.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&skill).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, services.ErrSkillNotFound
+		}
+		return nil, fmt.Errorf("failed to get skill: %w", err)
+	}
+	return &skill, nil
+}
+
+func (s *skillServiceImpl) GetByName(ctx context.Context, caller services.Caller, name string) (*models.Skill, error) {
+	var skill models.Skill
+	err := visibleSkills(s.db.WithContext(ctx), caller).Where("name = ?", name).
+		Order("tenant_id = '' ASC").First(&skill).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", services.ErrSkillNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get skill: %w", err)
+	}
+	return &skill, nil
+}
+
+func (s *skillServiceImpl) List(ctx context.Context, caller *services.Caller, filter models.SkillListFilter) (*models.SkillListResponse, error) {
+	query := s.db.WithContext(ctx).Model(&models.Skill{}).Where("deleted_at IS NULL")
+
+	var disabled map[uuid.UUID]bool
+	if caller != nil {
+		query = visibleSkills(query, *caller)
+		var err error
+		if disabled, err = s.disabledSkills(ctx, caller.TenantID); err != nil {
+			return nil, err
+		}
+		if len(disabled) > 0 && !filter.IncludeDisabled {
+			ids := make([]uuid.UUID, 0, len(disabled))
+			for id := range disabled {
+				ids = append(ids, id)
+			}
+			query = query.Where("id NOT IN ?", ids)
+		}
+	}
+
+	if filter.Type != nil {
+		query = query.Where("type = ?", *filter.Type)
+	}
+	if filter.Visibility != "" {
+		query = query.Where("visibility = ?", filter.Visibility)
+	}
+	if filter.Search != "" {
+		searchTerm := "%" + strings.ToLower(filter.Search) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(display_name) LIKE ? OR LOWER(description) LIKE ?",
+			searchTerm, searchTerm, searchTerm)
+	}
+	if len(filter.Tags) > 0 {
+		for _, tag := range filter.Tags {
+			query = query.Where("tags @> ?", datatypes.JSON(fmt.Sprintf(`[%q]`, tag)))
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count skills: %w", err)
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+	size := filter.Size
+	if size < 1 {
+		size = 50
+	}
+
+	var skills []models.Skill
+	if err := query.Order("name ASC").Offset((page - 1) * size).Limit(size).Find(&skills).Error; err != nil {
+		return nil, fmt.Errorf("failed to list skills: %w", err)
+	}
+	if caller != nil {
+		for i := range skills {
+			if skills[i].Visibility == models.SkillVisibilityGlobal {
+				enabled := !disabled[skills[i].ID]
+				skills[i].Enabled = &enabled
+			}
+		}
+	}
+
+	return &models.SkillListResponse{
+		Skills: skills,
+		Total:  total,
+		Page:   page,
+		Size:   size,
+	}, nil
+}
+
+func (s *skillServiceImpl) Update(ctx context.Context, id uuid.UUID, req models.UpdateSkillRequest) (*models.Skill, error) {
+	skill, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]any{}
+	if req.DisplayName != nil {
+		updates["display_name"] = *req.DisplayName
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Icon != nil {
+		updates["icon"] = *req.Icon
+	}
+	if req.Tags != nil {
+		tagsJSON, _ := json.Marshal(req.Tags)
+		updates["tags"] = datatypes.JSON(tagsJSON)
+	}
+	if req.Keywords != nil {
+		keywordsJSON, _ := json.Marshal(req.Keywords)
+		updates["keywords"] = datatypes.JSON(keywordsJSON)
+	}
+	if req.MCPServerURL != nil {
+		updates["mcp_server_url"] = *req.MCPServerURL
+	}
+	if req.MCPToolNames != nil {
+		toolNamesJSON, _ := json.Marshal(req.MCPToolNames)
+		updates["mcp_tool_names"] = datatypes.JSON(toolNamesJSON)
+	}
+	if req.MCPTransport != nil {
+		updates["mcp_transport"] = *req.MCPTransport
+	}
+	if req.MCPCommand != nil {
+		updates["mcp_command"] = *req.MCPCommand
+	}
+	if req.MCPArgs != nil {
+		argsJSON, _ := json.Marshal(req.MCPArgs)
+		updates["mcp_args"] = datatypes.JSON(argsJSON)
+	}
+	if req.MCPEnv != nil {
+		envJSON, _ := json.Marshal(req.MCPEnv)
+		updates["mcp_env"] = datatypes.JSON(envJSON)
+	}
+	if req.FunctionTools != nil {
+		updates["function_tools"] = req.FunctionTools
+	}
+	if req.AgentIDs != nil {
+		agentIDsJSON, _ := json.Marshal(req.AgentIDs)
+		updates["agent_ids"] = datatypes.JSON(agentIDsJSON)
+	}
+	if req.AuthHeaders != nil {
+		updates["auth_headers"] = req.AuthHeaders
+	}
+	if req.AuthQuery != nil {
+		updates["auth_query"] = req.AuthQuery
+	}
+	if req.TenantCredentials != nil {
+		updates["tenant_credentials"] = *req.TenantCredentials
+	}
+	if req.RequiresApproval != nil {
+		approvalJSON, _ := json.Marshal(req.RequiresApproval)
+		updates["requires_approval"] = datatypes.JSON(approvalJSON)
+	}
+	if req.AuditRedaction != nil {
+		updates["audit_redaction"] = *req.AuditRedaction
+	}
+	if req.ToolTimeoutSeconds != nil {
+		updates["tool_timeout_seconds"] = *req.ToolTimeoutSeconds
+	}
+	if req.ToolMaxRetries != nil {
+		updates["tool_max_retries"] = *req.ToolMaxRetries
+	}
+	if req.ToolMaxResultTokens != nil {
+		updates["tool_max_result_tokens"] = *req.ToolMaxResultTokens
+	}
+	if req.Visibility != nil {
+		updates["visibility"] = *req.Visibility
+	}
+	if req.Author != nil {
+		updates["author"] = *req.Author
+	}
+	if req.Version != nil {
+		updates["version"] = *req.Version
+	}
+	updates["updated_at"] = time.Now()
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(skill).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update skill: %w", err)
+		}
+		return s.recordVersion(tx, skill, req.Version != nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateIndex()
+
+	return s.GetByID(ctx, id)
+}
+
+// recordVersion records a new version after an update of before if the update changed the
+// skill's definition or version. A changed definition without a new version bumps the patch
+// number of the latest version.
+func (s *skillServiceImpl) recordVersion(tx *gorm.DB, before *models.Skill, versionGiven bool) error {
+	var after models.Skill
+	if err := tx.Where("id = ?", before.ID).First(&after).Error; err != nil {
+		return fmt.Errorf("failed to reload skill: %w", err)
+	}
+
+	changed := definitionOf(models.NewSkillVersion(before)) != definitionOf(models.NewSkillVersion(&after))
+	if !changed && after.Version == before.Version {
+		return nil
+	}
+
+	if !versionGiven || after.Version == before.Version {
+		if versionGiven {
+			return fmt.Errorf("%w: %s of skill %q; versions are immutable, so changes need a new version",
+				services.ErrSkillVersionExists, after.Version, after.Name)
+		}
+		latest, err := latestVersion(tx, &after)
+		if err != nil {
+			return err
+		}
+		after.Version = latest.BumpPatch().String()
+		if err := tx.Model(&after).Update("version", after.Version).Error; err != nil {
+			return fmt.Errorf("failed to update skill version: %w", err)
+		}
+	} else {
+		if _, err := semver.Parse(after.Version); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.SkillVersion{}).Where("skill_id = ? AND version = ?", after.ID, after.Version).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check skill version: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s of skill %q", services.ErrSkillVersionExists, after.Version, after.Name)
+		}
+	}
+
+	if err := tx.Create(models.NewSkillVersion(&after)).Error; err != nil {
+		return fmt.Errorf("failed to record skill version: %w", err)
+	}
+	log.Printf("[SKILLS] Recorded version %s of skill %q", after.Version, after.Name)
+	return nil
+}
+
+// definitionOf fingerprints the versioned part of a skill
+func definitionOf(v *models.SkillVersion) string {
+	data, _ := json.Marshal([]any{v.MCPServerURL, v.MCPTransport, v.MCPCommand, v.MCPArgs, v.MCPToolNames, v.FunctionTools, v.AgentIDs})
+	return string(data)
+}
+
+// latestVersion returns the highest recorded version of a skill, or its current version if
+// none is recorded
+func latestVersion(tx *gorm.DB, skill *models.Skill) (semver.Version, error) {
+	var versions []string
+	if err := tx.Model(&models.SkillVersion{}).Where("skill_id = ?", skill.ID).Pluck("version", &versions).Error; err != nil {
+		return semver.Version{}, fmt.Errorf("failed to list skill versions: %w", err)
+	}
+	latest, err := semver.Parse(skill.Version)
+	if err != nil {
+		latest = semver.Version{Major: 1}
+	}
+	for _, version := range versions {
+		if v, err := semver.Parse(version); err == nil && v.Compare(latest) > 0 {
+			latest = v
+		}
+	}
+	return latest, nil
+}
+
+func (s *skillServiceImpl) Delete(ctx context.Context, id uuid.UUID, force bool) ([]models.SkillUsage, error) {
+	skill, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if skill.IsSystem {
+		return nil, fmt.Errorf("cannot delete system skill: %s", skill.Name)
+	}
+
+	usage, err := s.Usage(ctx, skill)
+	if err != nil {
+		return nil, err
+	}
+	if len(usage) > 0 && !force {
+		return usage, fmt.Errorf("%w: %q is referenced by %d agents", services.ErrSkillInUse, skill.Name, len(usage))
+	}
+
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Model(skill).Update("deleted_at", &now).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete skill: %w", err)
+	}
+	s.invalidateIndex()
+	if len(usage) > 0 {
+		log.Printf("[SKILLS] Force-deleted skill %q, still referenced by %d agents", skill.Name, len(usage))
+	}
+	return usage, nil
+}
+
+func (s *skillServiceImpl) Resolve(ctx context.Context, caller services.Caller, ref string) (*models.Skill, error) {
+	name, constraint := models.ParseSkillRef(ref)
+	skill, err := s.GetByName(ctx, caller, name)
+	if err != nil {
+		return nil, err
+	}
+	if skill.Visibility == models.SkillVisibilityGlobal {
+		disabled, err := s.disabledSkills(ctx, caller.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		if disabled[skill.ID] {
+			return nil, fmt.Errorf("%w: %s", services.ErrSkillDisabled, name)
+		}
+	}
+	if constraint == "" {
+		return skill, nil
+	}
+
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("skill reference %q: %w", ref, err)
+	}
+	versions, err := s.ListVersions(ctx, skill.ID)
+	if err != nil {
+		return nil, err
+	}
+	version := selectVersion(versions, c)
+	if version == nil {
+		return nil, fmt.Errorf("%w: no version of %s matches %s", services.ErrSkillVersionNotFound, name, constraint)
+	}
+	pinned := version.Apply(*skill)
+	return &pinned, nil
+}
+
+// selectVersion returns the highest version matching c, preferring versions that are not
+// deprecated, or nil if none matches
+func selectVersion(versions []models.SkillVersion, c semver.Constraint) *models.SkillVersion {
+	var best *models.SkillVersion
+	var bestVersion semver.Version
+	for i := range versions {
+		v, err := semver.Parse(versions[i].Version)
+		if err != nil || !c.Match(v) {
+			continue
+		}
+		if best != nil {
+			if versions[i].Deprecated && !best.Deprecated {
+				continue
+			}
+			if versions[i].Deprecated == best.Deprecated && v.Compare(bestVersion) <= 0 {
+				continue
+			}
+		}
+		best, bestVersion = &versions[i], v
+	}
+	return best
+}
+
+func (s *skillServiceImpl) ListVersions(ctx context.Context, skillID uuid.UUID) ([]models.SkillVersion, error) {
+	var versions []models.SkillVersion
+	if err := s.db.WithContext(ctx).Where("skill_id = ?", skillID).Order("created_at DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list skill versions: %w", err)
+	}
+
+	// Highest version first; versions that are not semantic keep their creation order at the end
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, errA := semver.Parse(versions[i].Version)
+		b, errB := semver.Parse(versions[j].Version)
+		if errA != nil || errB != nil {
+			return errA == nil && errB != nil
+		}
+		return a.Compare(b) > 0
+	})
+	return versions, nil
+}
+
+func (s *skillServiceImpl) DeprecateVersion(ctx context.Context, skillID uuid.UUID, version string, req models.DeprecateSkillVersionRequest) (*models.SkillVersion, error) {
+	var v models.SkillVersion
+	err := s.db.WithContext(ctx).Where("skill_id = ? AND version = ?", skillID, version).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", services.ErrSkillVersionNotFound, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get skill version: %w", err)
+	}
+
+	deprecated := req.Deprecated == nil || *req.Deprecated
+	updates := map[string]any{"deprecated": deprecated, "deprecation_message": "", "deprecated_at": nil}
+	if deprecated {
+		updates["deprecation_message"] = req.Message
+		updates["deprecated_at"] = time.Now()
+	}
+	if err := s.db.WithContext(ctx).Model(&v).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to deprecate skill version: %w", err)
+	}
+	if err := s.db.WithContext(ctx).Where("id = ?", v.ID).First(&v).Error; err != nil {
+		return nil, fmt.Errorf("failed to get skill version: %w", err)
+	}
+	return &v, nil
+}
+
+func (s *skillServiceImpl) RecordToolSnapshot(ctx context.Context, skillID uuid.UUID, version string, tools []models.SkillTool) error {
+	snapshot := make(models.SkillTools, len(tools))
+	for i, tool := range tools {
+		tool.RequiresApproval = false // Approvals follow the skill's current settings
+		snapshot[i] = tool
+	}
+
+	err := s.db.WithContext(ctx).Model(&models.SkillVersion{}).
+		Where("skill_id = ? AND version = ? AND tools IS NULL", skillID, version).
+		Update("tools", snapshot).Error
+	if err != nil {
+		return fmt.Errorf("failed to record tool snapshot: %w", err)
+	}
+	return nil
+}
+
+func (s *skillServiceImpl) Usage(ctx context.Context, skill *models.Skill) ([]models.SkillUsage, error) {
+	// Only agents that can see the skill resolve their references to it
+	query := s.db.WithContext(ctx).Select("id", "name", "owner_id", "skills").
+		Where("deleted_at IS NULL AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(skills) AS ref WHERE ref = ? OR ref LIKE ?)",
+			skill.Name, escapeLike(skill.Name)+"@%")
+	switch skill.Visibility {
+	case models.SkillVisibilityTenant:
+		query = query.Where("tenant_id = ?", skill.TenantID)
+	case models.SkillVisibilityPrivate:
+		owner := ""
+		if skill.OwnerID != nil {
+			owner = skill.OwnerID.String()
+		}
+		query = query.Where("tenant_id = ? AND owner_id = ?", skill.TenantID, owner)
+	}
+
+	var agents []models.Agent
+	err := query.Order("name ASC").Find(&agents).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find agents using skill: %w", err)
+	}
+	if len(agents) == 0 {
+		return nil, nil
+	}
+
+	versions, err := s.ListVersions(ctx, skill.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage []models.SkillUsage
+	for _, agent := range agents {
+		var refs []string
+		json.Unmarshal(agent.Skills, &refs)
+		for _, ref := range refs {
+			name, constraint := models.ParseSkillRef(ref)
+			if name != skill.Name {
+				continue
+			}
+			u := models.SkillUsage{
+				AgentID:         agent.ID,
+				AgentName:       agent.Name,
+				OwnerID:         agent.OwnerID,
+				Reference:       ref,
+				ResolvedVersion: skill.Version,
+				Pinned:          constraint != "",
+			}
+			if u.Pinned {
+				u.ResolvedVersion = ""
+				if c, err := semver.ParseConstraint(constraint); err != nil {
+					u.Error = err.Error()
+				} else if v := selectVersion(versions, c); v == nil {
+					u.Error = "no version matches " + constraint
+				} else {
+					u.ResolvedVersion = v.Version
+					u.Deprecated = v.Deprecated
+				}
+			}
+			usage = append(usage, u)
+		}
+	}
+	return usage, nil
+}
+
+// ResolveForAgent returns the agent's assigned skills, in order, followed by the unassigned
+// skills relevant to its system prompt or the input, most relevant first. Each skill's
+// Selection says why it was chosen. Skill names are resolved as the agent's owner sees them,
+// but only skills the caller in ctx can see are returned: skills run with their owner's
+// credentials, so an agent shared with others must not lend them its owner's private skills
+// and secrets. Without a caller only the tenant's shared and global skills are used.
+func (s *skillServiceImpl) ResolveForAgent(ctx context.Context, agent *models.Agent, input string) ([]models.Skill, error) {
+	var result []models.Skill
+	assigned := make(map[string]bool)
+	owner, _ := uuid.Parse(agent.OwnerID)
+	viewer := services.Caller{TenantID: agent.TenantID, UserID: owner}
+	caller, ok := services.CallerFromContext(ctx)
+	if !ok {
+		caller = services.Caller{TenantID: agent.TenantID}
+	}
+
+	// 1. Load explicitly assigned skills
+	var explicitNames []string
+	if agent.Skills != nil {
+		if err := json.Unmarshal(agent.Skills, &explicitNames); err != nil {
+			log.Printf("[SKILLS] Warning: failed to parse agent skills JSON: %v", err)
+		}
+	}
+
+	for _, ref := range explicitNames {
+		skill, err := s.Resolve(ctx, viewer, ref)
+		if err != nil {
+			log.Printf("[SKILLS] Warning: explicit skill %q not resolved: %v", ref, err)
+			continue
+		}
+		if assigned[skill.Name] {
+			continue
+		}
+		if !usableFor(skill, viewer, caller) {
+			log.Printf("[SKILLS] Warning: explicit skill %q of agent %s is not visible to the caller; leaving it out", ref, agent.ID)
+			continue
+		}
+		if v := skill.ResolvedVersion; v != nil && v.Deprecated {
+			log.Printf("[SKILLS] Warning: agent %s is pinned to deprecated version %s of skill %q", agent.ID, v.Version, skill.Name)
+		}
+		assigned[skill.Name] = true
+		skill.Selection = &models.SkillSelection{Skill: skill.Name, Reason: models.SkillSelectedAssigned}
+		result = append(result, *skill)
+	}
+
+	// 2. Select other skills whose descriptions are relevant to the prompt or input
+	disabled, err := s.disabledSkills(ctx, viewer.TenantID)
+	if err != nil {
+		log.Printf("[SKILLS] Warning: %v", err)
+	}
+	relevant, err := s.relevantSkills(ctx, agent.SystemPrompt, input, func(skill *models.Skill) bool {
+		return !assigned[skill.Name] && !disabled[skill.ID] && usableFor(skill, viewer, caller)
+	})
+	if err != nil {
+		log.Printf("[SKILLS] Warning: failed to select relevant skills: %v", err)
+	}
+	result = append(result, relevant...)
+
+	log.Printf("[SKILLS] Resolved %d skills for agent %s (assigned: %d, relevant: %d)",
+		len(result), agent.ID, len(assigned), len(relevant))
+
+	return result, nil
+}
+
+// usableFor reports whether an execution of an agent owned by owner may use skill on behalf of
+// caller: both must see it, as the skill runs with its owner's credentials
+func usableFor(skill *models.Skill, owner, caller services.Caller) bool {
+	return skill.VisibleTo(owner.TenantID, owner.UserID) && skill.VisibleTo(caller.TenantID, caller.UserID)
+}
+
+// skillIndex is the relevance index over the skills that declare keywords. Only those take
+// part in selection, so skills are offered unassigned only if their authors opted in.
+type skillIndex struct {
+	skills  []models.Skill
+	index   *relevance.Index
+	builtAt time.Time
+}
+
+// newSkillIndex indexes each skill's name, description and keywords
+func newSkillIndex(skills []models.Skill) *skillIndex {
+	ix := &skillIndex{builtAt: time.Now()}
+	var docs []string
+	for _, skill := range skills {
+		var keywords []string
+		if err := json.Unmarshal(skill.Keywords, &keywords); err != nil || len(keywords) == 0 {
+			continue
+		}
+		ix.skills = append(ix.skills, skill)
+		docs = append(docs, strings.Join(append([]string{skill.Name, skill.DisplayName, skill.Description}, keywords...), "\n"))
+	}
+	ix.index = relevance.NewIndex(docs)
+	return ix
+}
+
+// relevanceIndex returns the skill index, loading the skills when it is missing or old
+func (s *skillServiceImpl) relevanceIndex(ctx context.Context) (*skillIndex, error) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	if s.index != nil && time.Since(s.index.builtAt) < skillIndexTTL {
+		return s.index, nil
+	}
+	var skills []models.Skill
+	if err := s.db.WithContext(ctx).Where("deleted_at IS NULL").Find(&skills).Error; err != nil {
+		return nil, fmt.Errorf("failed to load skills: %w", err)
+	}
+	s.index = newSkillIndex(skills)
+	return s.index, nil
+}
+
+func (s *skillServiceImpl) invalidateIndex() {
+	s.indexMu.Lock()
+	s.index = nil
+	s.indexMu.Unlock()
+}
+
+// relevantSkills returns the eligible indexed skills whose relevance to the system prompt or
+// input reaches the threshold, most relevant first
+func (s *skillServiceImpl) relevantSkills(ctx context.Context, systemPrompt, input string, eligible func(*models.Skill) bool) ([]models.Skill, error) {
+	if strings.TrimSpace(systemPrompt) == "" && strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	ix, err := s.relevanceIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	best := make(map[int]*models.SkillSelection)
+	sources := []struct{ name, text string }{
+		{models.SkillSourceSystemPrompt, systemPrompt},
+		{models.SkillSourceInput, input},
+	}
+	for _, source := range sources {
+		if source.text == "" {
+			continue
+		}
+		for _, m := range ix.index.Search(source.text) {
+			if m.Relevance < s.relevanceThreshold || !eligible(&ix.skills[m.Doc]) {
+				continue
+			}
+			if sel, ok := best[m.Doc]; ok && sel.Relevance >= m.Relevance {
+				continue
+			}
+			best[m.Doc] = &models.SkillSelection{
+				Skill:        ix.skills[m.Doc].Name,
+				Reason:       models.SkillSelectedRelevant,
+				Source:       source.name,
+				Relevance:    math.Round(m.Relevance*1000) / 1000,
+				MatchedWords: m.Words,
+			}
+		}
+	}
+
+	docs := make([]int, 0, len(best))
+	for doc := range best {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if best[docs[i]].Relevance != best[docs[j]].Relevance {
+			return best[docs[i]].Relevance > best[docs[j]].Relevance
+		}
+		return docs[i] < docs[j]
+	})
+
+	skills := make([]models.Skill, 0, len(docs))
+	for _, doc := range docs {
+		skill := ix.skills[doc]
+		skill.Selection = best[doc]
+		skills = append(skills, skill)
+	}
+	return skills, nil
+}
+
+func (s *skillServiceImpl) SetTenantEnabled(ctx context.Context, caller services.Caller, id uuid.UUID, enabled bool) (*models.Skill, error) {
+	skill, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if skill.Visibility != models.SkillVisibilityGlobal {
+		return nil, services.ErrSkillNotGlobal
+	}
+
+	setting := models.TenantSkillSetting{
+		TenantID:  caller.TenantID,
+		SkillID:   skill.ID,
+		Enabled:   enabled,
+		UpdatedBy: caller.UserID,
+		UpdatedAt: time.Now(),
+	}
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "skill_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_by", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save tenant skill setting: %w", err)
+	}
+
+	log.Printf("[SKILLS] Tenant %s set global skill %q enabled=%t", caller.TenantID, skill.Name, enabled)
+	skill.Enabled = &enabled
+	return skill, nil
+}
+
+// SeedDefaults inserts built-in skills if they don't exist
+func (s *skillServiceImpl) SeedDefaults(ctx context.Context) error {
+	defaults := []models.Skill{
+		{
+			Name:        "visual_generation",
+			DisplayName: "Visual Generation",
+			Description: "Generate diagrams, mind maps, flowcharts, and visual content from text descriptions using Napkin AI",
+			Type:        models.SkillTypeMCP,
+			Icon:        "Image",
+			Tags:        mustJSON([]string{"visual", "diagram", "chart", "mindmap", "napkin"}),
+			Keywords:    mustJSON([]string{"visual", "diagram", "chart", "graph", "mindmap", "infographic", "illustration", "draw", "flowchart"}),
+			MCPServerURL: "http://napkin-mcp.tas-mcp-servers.svc.cluster.local:8087",
+			MCPTransport: mcp.TransportLegacyREST,
+			MCPToolNames: mustJSON([]string{"generate_visual", "list_styles", "get_visual_status", "download_visual", "list_visuals", "delete_visual"}),
+			ToolTimeoutSeconds: 300, // Image generation is slow
+			RequiresApproval:   mustJSON([]string{"delete_visual"}),
+			Visibility:  models.SkillVisibilityGlobal,
+			IsSystem:    true,
+			Author:      "TAS Platform",
+			Version:     "1.0.0",
+		},
+	}
+
+	// Builtin skills run in process; their tools come from the builtin registry
+	for _, b := range builtin.Default().Skills() {
+		defaults = append(defaults, models.Skill{
+			Name:        b.Name,
+			DisplayName: b.DisplayName,
+			Description: b.Description,
+			Type:        models.SkillTypeBuiltin,
+			Icon:        b.Icon,
+			Tags:        mustJSON(b.Tags),
+			Keywords:    mustJSON(b.Keywords),
+			Visibility:  models.SkillVisibilityGlobal,
+			IsSystem:    true,
+			Author:      "TAS Platform",
+			Version:     "1.0.0",
+		})
+	}
+
+	for _, skill := range defaults {
+		var existing models.Skill
+		result := s.db.WithContext(ctx).Where("tenant_id = '' AND name = ?", skill.Name).First(&existing)
+		if result.Error == gorm.ErrRecordNotFound {
+			skill.ID = uuid.New()
+			skill.CreatedAt = time.Now()
+			skill.UpdatedAt = time.Now()
+			if err := s.db.WithContext(ctx).Create(&skill).Error; err != nil {
+				log.Printf("[SKILLS] Warning: failed to seed skill %q: %v", skill.Name, err)
+			} else {
+				log.Printf("[SKILLS] Seeded default skill: %s", skill.Name)
+			}
+		} else if result.Error == nil {
+			// Skills seeded before transports existed must keep talking to their REST shim
+			if existing.MCPTransport == "" && skill.MCPTransport != "" {
+				s.db.WithContext(ctx).Model(&existing).Update("mcp_transport", skill.MCPTransport)
+			}
+			log.Printf("[SKILLS] Default skill %q already exists, skipping", skill.Name)
+		}
+	}
+	s.invalidateIndex()
+
+	return s.backfillVersions(ctx)
+}
+
+// backfillVersions records the current definition of skills created before versioning as
+// their first version
+func (s *skillServiceImpl) backfillVersions(ctx context.Context) error {
+	var skills []models.Skill
+	err := s.db.WithContext(ctx).
+		Where("deleted_at IS NULL AND NOT EXISTS (SELECT 1 FROM agent_builder.skill_versions v WHERE v.skill_id = skills.id)").
+		Find(&skills).Error
+	if err != nil {
+		return fmt.Errorf("failed to find unversioned skills: %w", err)
+	}
+
+	for i := range skills {
+		skill := &skills[i]
+		if _, err := semver.Parse(skill.Version); err != nil {
+			log.Printf("[SKILLS] Skill %q has version %q, which is not semantic; versioning it as 1.0.0", skill.Name, skill.Version)
+			skill.Version = "1.0.0"
+			if err := s.db.WithContext(ctx).Model(skill).Update("version", skill.Version).Error; err != nil {
+				return fmt.Errorf("failed to update skill version: %w", err)
+			}
+		}
+		if err := s.db.WithContext(ctx).Create(models.NewSkillVersion(skill)).Error; err != nil {
+			return fmt.Errorf("failed to record skill version: %w", err)
+		}
+	}
+	if len(skills) > 0 {
+		log.Printf("[SKILLS] Recorded initial versions of %d skills", len(skills))
+	}
+	return nil
+}
+
+// mustJSON marshals a value to datatypes.JSON, panicking on error
+func mustJSON(v any) datatypes.JSON {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("mustJSON: %v", err))
+	}
+	return datatypes.JSON(b)
+}
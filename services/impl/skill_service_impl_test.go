@@ -0,0 +1,137 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/semver"
+)
+
+func TestSelectVersion(t *testing.T) {
+	versions := []models.SkillVersion{
+		{Version: "2.0.0", MCPServerURL: "http://v2"},
+		{Version: "1.4.0", MCPServerURL: "http://v1.4", Deprecated: true},
+		{Version: "1.3.2", MCPServerURL: "http://v1.3"},
+		{Version: "1.0.0", MCPServerURL: "http://v1.0"},
+		{Version: "legacy"},
+	}
+	pick := func(constraint string) string {
+		c, err := semver.ParseConstraint(constraint)
+		require.NoError(t, err)
+		v := selectVersion(versions, c)
+		if v == nil {
+			return ""
+		}
+		return v.Version
+	}
+
+	assert.Equal(t, "2.0.0", pick(""))
+	assert.Equal(t, "1.3.2", pick("^1"), "deprecated versions lose to matching ones that are not")
+	assert.Equal(t, "1.4.0", pick("1.4.0"), "an exact pin still gets its deprecated version")
+	assert.Equal(t, "1.4.0", pick("~1.4"))
+	assert.Equal(t, "", pick("^3"))
+
+	name, constraint := models.ParseSkillRef("web_search@^1.2")
+	assert.Equal(t, "web_search", name)
+	assert.Equal(t, "^1.2", constraint)
+	name, constraint = models.ParseSkillRef("web_search")
+	assert.Equal(t, "web_search", name)
+	assert.Empty(t, constraint)
+
+	skill := models.Skill{Name: "web_search", Version: "2.0.0", MCPServerURL: "http://v2", ToolMaxRetries: 3}
+	pinned := versions[2].Apply(skill)
+	assert.Equal(t, "1.3.2", pinned.Version)
+	assert.Equal(t, "http://v1.3", pinned.MCPServerURL)
+	assert.Equal(t, 3, pinned.ToolMaxRetries, "settings that are not versioned stay current")
+	assert.Same(t, &versions[2], pinned.ResolvedVersion)
+}
+
+func TestRelevantSkills(t *testing.T) {
+	keywords := func(k ...string) []byte {
+		b, _ := json.Marshal(k)
+		return b
+	}
+	owner := uuid.New()
+	s := &skillServiceImpl{relevanceThreshold: defaultSkillRelevanceThreshold}
+	s.index = newSkillIndex([]models.Skill{
+		{Name: "visual_generation", DisplayName: "Visual Generation", Description: "Generate diagrams, mind maps, flowcharts, and visual content from text descriptions",
+			Keywords: keywords("visual", "diagram", "chart", "graph", "mindmap", "infographic", "illustration", "draw", "flowchart")},
+		{Name: "calculator", DisplayName: "Calculator", Description: "Evaluate arithmetic expressions exactly instead of estimating them",
+			Keywords: keywords("calculate", "math", "arithmetic", "sum", "percent", "multiply", "divide", "sqrt")},
+		{Name: "internal_search", Description: "Search the internal wiki for diagrams"},
+		{Name: "org_charts", Description: "Render the reporting lines of a team as an org chart",
+			Keywords: keywords("org", "orgchart", "hierarchy", "reporting"), Visibility: models.SkillVisibilityPrivate, TenantID: "tenant-1", OwnerID: &owner},
+	})
+	ctx := context.Background()
+	visibleTo := func(tenantID string, userID uuid.UUID, skip ...string) func(*models.Skill) bool {
+		return func(skill *models.Skill) bool {
+			for _, name := range skip {
+				if skill.Name == name {
+					return false
+				}
+			}
+			return skill.Visibility == "" || skill.VisibleTo(tenantID, userID)
+		}
+	}
+	anyone := visibleTo("tenant-2", uuid.New())
+	names := func(skills []models.Skill) []string {
+		var out []string
+		for _, skill := range skills {
+			out = append(out, skill.Name)
+		}
+		return out
+	}
+
+	skills, err := s.relevantSkills(ctx, "You are an analyst. Read the reports and draw conclusions about quarterly performance.", "", anyone)
+	require.NoError(t, err)
+	assert.Empty(t, skills, "one incidental keyword does not select a skill")
+
+	skills, err = s.relevantSkills(ctx, "You are an analyst.", "Calculate 15 percent of 2400", anyone)
+	require.NoError(t, err)
+	require.Equal(t, []string{"calculator"}, names(skills))
+	sel := skills[0].Selection
+	assert.Equal(t, models.SkillSelectedRelevant, sel.Reason)
+	assert.Equal(t, models.SkillSourceInput, sel.Source)
+	assert.Equal(t, []string{"calculate", "percent"}, sel.MatchedWords)
+	assert.GreaterOrEqual(t, sel.Relevance, defaultSkillRelevanceThreshold)
+
+	skills, err = s.relevantSkills(ctx, "You turn processes into diagrams and flowcharts.", "Calculate 15 percent of 2400", visibleTo("tenant-2", uuid.New(), "calculator"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"visual_generation"}, names(skills), "skipped and keyword-less skills are not selected")
+	assert.Equal(t, models.SkillSourceSystemPrompt, skills[0].Selection.Source)
+
+	skills, err = s.relevantSkills(ctx, "You are an HR assistant.", "Show the reporting hierarchy of my team", visibleTo("tenant-1", owner))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org_charts"}, names(skills), "owners get their private skills")
+	skills, err = s.relevantSkills(ctx, "You are an HR assistant.", "Show the reporting hierarchy of my team", visibleTo("tenant-1", uuid.New()))
+	require.NoError(t, err)
+	assert.Empty(t, skills, "other users of the tenant do not")
+
+	// A shared agent's executions use its owner's private skills only for the owner
+	ownerView := services.Caller{TenantID: "tenant-1", UserID: owner}
+	sharedWith := func(caller services.Caller) func(*models.Skill) bool {
+		return func(skill *models.Skill) bool { return usableFor(skill, ownerView, caller) }
+	}
+	skills, err = s.relevantSkills(ctx, "You are an HR assistant.", "Show the reporting hierarchy of my team", sharedWith(ownerView))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org_charts"}, names(skills))
+	skills, err = s.relevantSkills(ctx, "You are an HR assistant.", "Show the reporting hierarchy of my team", sharedWith(services.Caller{TenantID: "tenant-1", UserID: uuid.New()}))
+	require.NoError(t, err)
+	assert.Empty(t, skills, "other callers of the owner's agent do not get the owner's private skills")
+
+	global := models.Skill{Visibility: models.SkillVisibilityGlobal}
+	shared := models.Skill{Visibility: models.SkillVisibilityTenant, TenantID: "tenant-1"}
+	private := models.Skill{Visibility: models.SkillVisibilityPrivate, TenantID: "tenant-1", OwnerID: &owner}
+	assert.True(t, global.VisibleTo("", uuid.Nil))
+	assert.True(t, shared.VisibleTo("tenant-1", uuid.New()))
+	assert.False(t, shared.VisibleTo("tenant-2", owner))
+	assert.True(t, private.VisibleTo("tenant-1", owner))
+	assert.False(t, private.VisibleTo("tenant-1", uuid.New()))
+	assert.False(t, private.VisibleTo("tenant-2", owner))
+}
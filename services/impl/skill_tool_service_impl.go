@@ -0,0 +1,550 @@
+package impl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/builtin"
+	"github.com/tas-agent-builder/services/credentials"
+	"github.com/tas-agent-builder/services/mcp"
+)
+
+const (
+	// healthCheckConcurrency bounds how many skill servers are checked at once
+	healthCheckConcurrency = 4
+
+	// toolRetryBackoff is the wait before the first retry of a failed tool call; it doubles
+	// with each further attempt
+	toolRetryBackoff = 500 * time.Millisecond
+)
+
+type skillToolServiceImpl struct {
+	skillService services.SkillService
+	credentials  services.CredentialService
+	clients      *mcp.Manager
+	httpClient   *http.Client
+	builtins     *builtin.Registry
+	ttl          time.Duration
+
+	mu     sync.Mutex
+	cache  map[toolCacheKey]*toolCacheEntry
+	health map[uuid.UUID]*healthEntry
+}
+
+// toolCacheKey identifies a cached tool list. Servers of skills with auth templates may list
+// different tools for different credentials, so their lists are cached per caller.
+type toolCacheKey struct {
+	skillID  uuid.UUID
+	tenantID string
+	userID   uuid.UUID
+}
+
+func toolCacheKeyFor(ctx context.Context, skill *models.Skill) toolCacheKey {
+	key := toolCacheKey{skillID: skill.ID}
+	if skill.UsesCredentials() {
+		if caller, ok := services.CallerFromContext(ctx); ok {
+			key.tenantID = caller.TenantID
+			key.userID = caller.UserID
+		}
+	}
+	return key
+}
+
+// toolCacheEntry is a skill's unfiltered tool list as last fetched from serverKey.
+// fingerprint identifies the list's content and is served as its ETag.
+type toolCacheEntry struct {
+	serverKey   string
+	tools       []mcp.Tool
+	fingerprint string
+	fetchedAt   time.Time
+}
+
+type healthEntry struct {
+	serverKey string
+	health    models.SkillHealth
+}
+
+// NewSkillToolService creates a SkillToolService that caches tool lists for ttl and shares
+// MCP sessions through clients. Skills with auth templates are called with the credentials of
+// the caller in the context; credentialService may be nil when none are configured.
+func NewSkillToolService(skillService services.SkillService, credentialService services.CredentialService, clients *mcp.Manager, ttl time.Duration) services.SkillToolService {
+	s := &skillToolServiceImpl{
+		skillService: skillService,
+		credentials:  credentialService,
+		clients:      clients,
+		httpClient:   &http.Client{},
+		builtins:     builtin.Default(),
+		ttl:          ttl,
+		cache:        make(map[toolCacheKey]*toolCacheEntry),
+		health:       make(map[uuid.UUID]*healthEntry),
+	}
+	clients.OnToolsChanged(s.invalidateServer)
+	return s
+}
+
+// mcpServerForSkill describes how to reach an MCP skill's server, or returns false if the
+// skill has none configured
+func mcpServerForSkill(skill *models.Skill) (mcp.Server, bool) {
+	if skill.MCPTransport == mcp.TransportStdio {
+		if skill.MCPCommand == "" {
+			return mcp.Server{}, false
+		}
+		command := &mcp.StdioCommand{Command: skill.MCPCommand}
+		if skill.MCPArgs != nil {
+			json.Unmarshal(skill.MCPArgs, &command.Args)
+		}
+		if skill.MCPEnv != nil {
+			json.Unmarshal(skill.MCPEnv, &command.Env)
+		}
+		return mcp.Server{Transport: mcp.TransportStdio, Stdio: command}, true
+	}
+	if skill.MCPServerURL == "" {
+		return mcp.Server{}, false
+	}
+	return mcp.Server{URL: skill.MCPServerURL, Transport: skill.MCPTransport}, true
+}
+
+// authForSkill renders the skill's authentication for the caller in ctx. It returns nil for
+// skills without auth templates.
+func (s *skillToolServiceImpl) authForSkill(ctx context.Context, skill *models.Skill) (*credentials.Auth, error) {
+	if !skill.UsesCredentials() {
+		return nil, nil
+	}
+	if s.credentials == nil {
+		return nil, &nonRetryableError{fmt.Errorf("skill %q needs credentials: %w", skill.Name, services.ErrCredentialsDisabled)}
+	}
+	caller, ok := services.CallerFromContext(ctx)
+	if !ok {
+		return nil, &nonRetryableError{fmt.Errorf("skill %q needs credentials, but the call has no tenant or user", skill.Name)}
+	}
+	auth, err := s.credentials.AuthForSkill(ctx, skill, caller)
+	if err != nil {
+		return nil, &nonRetryableError{err}
+	}
+	return auth, nil
+}
+
+func (s *skillToolServiceImpl) ListTools(ctx context.Context, skill *models.Skill, refresh bool) (*models.SkillToolList, error) {
+	list, err := s.listTools(ctx, skill, refresh)
+	if err != nil {
+		return nil, err
+	}
+	markApprovals(skill, list)
+	return list, nil
+}
+
+// markApprovals flags the tools whose calls need a user's approval. The approval list is part
+// of the ETag so clients notice when it changes.
+func markApprovals(skill *models.Skill, list *models.SkillToolList) {
+	if len(skill.RequiresApproval) == 0 || string(skill.RequiresApproval) == "[]" {
+		return
+	}
+	for i := range list.Tools {
+		list.Tools[i].RequiresApproval = skill.ToolRequiresApproval(list.Tools[i].Name)
+	}
+	list.ETag = contentETag(append([]byte(list.ETag), skill.RequiresApproval...))
+}
+
+// errAgentSkill is returned for agent skills, whose tools depend on the calling agent and caller
+// and are run by the execution pipeline
+var errAgentSkill = errors.New("tools of agent skills are offered and run by the calling agent")
+
+func (s *skillToolServiceImpl) listTools(ctx context.Context, skill *models.Skill, refresh bool) (*models.SkillToolList, error) {
+	switch skill.Type {
+	case models.SkillTypeFunction:
+		return functionToolList(skill), nil
+	case models.SkillTypeBuiltin:
+		return s.builtinToolList(skill)
+	case models.SkillTypeAgent:
+		return nil, errAgentSkill
+	}
+
+	// Agents pinned to a version are offered the tools recorded with it
+	if v := skill.ResolvedVersion; v != nil && v.Tools != nil {
+		return snapshotToolList(skill, v), nil
+	}
+
+	server, ok := mcpServerForSkill(skill)
+	if !ok {
+		return nil, fmt.Errorf("skill %q has no MCP server configured", skill.Name)
+	}
+
+	s.mu.Lock()
+	entry := s.cache[toolCacheKeyFor(ctx, skill)]
+	if entry != nil && entry.serverKey != server.EndpointKey() {
+		entry = nil
+	}
+	h := s.health[skill.ID]
+	s.mu.Unlock()
+
+	if !refresh {
+		if entry != nil && time.Since(entry.fetchedAt) < s.ttl {
+			return toolList(skill, entry, false), nil
+		}
+		// Don't stall executions on a server the health checker recently found down
+		if h != nil && h.serverKey == server.EndpointKey() && h.health.Status == models.SkillHealthUnhealthy &&
+			h.health.CheckedAt != nil && time.Since(*h.health.CheckedAt) < s.ttl {
+			if entry != nil {
+				return toolList(skill, entry, true), nil
+			}
+			return nil, fmt.Errorf("%w: %s", services.ErrSkillUnavailable, h.health.Error)
+		}
+	}
+
+	auth, err := s.authForSkill(ctx, skill)
+	if err != nil {
+		return nil, err
+	}
+	server.Auth = auth
+
+	fresh, err := s.fetch(ctx, skill, server)
+	if err != nil {
+		if entry != nil {
+			log.Printf("[SKILLS] Serving cached tools for skill %q: %v", skill.Name, err)
+			return toolList(skill, entry, true), nil
+		}
+		return nil, fmt.Errorf("%w: %v", services.ErrSkillUnavailable, err)
+	}
+	return toolList(skill, fresh, false), nil
+}
+
+// fetch lists a skill's tools from its server, updating the cache and the skill's health
+func (s *skillToolServiceImpl) fetch(ctx context.Context, skill *models.Skill, server mcp.Server) (*toolCacheEntry, error) {
+	start := time.Now()
+	tools, err := s.clients.ListTools(ctx, server)
+	s.recordHealth(skill.ID, server, time.Since(start), len(tools), err)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &toolCacheEntry{
+		serverKey:   server.EndpointKey(),
+		tools:       tools,
+		fingerprint: toolsFingerprint(tools),
+		fetchedAt:   time.Now(),
+	}
+
+	key := toolCacheKeyFor(ctx, skill)
+	s.mu.Lock()
+	previous := s.cache[key]
+	s.cache[key] = entry
+	s.mu.Unlock()
+
+	if previous != nil && previous.fingerprint != entry.fingerprint {
+		log.Printf("[SKILLS] Tools of skill %q changed (%d tools)", skill.Name, len(tools))
+	}
+	return entry, nil
+}
+
+// toolsFingerprint identifies a tool list's content so clients and the cache can tell when it
+// changed. MCP servers have no conditional listing, so lists are always fetched in full.
+func toolsFingerprint(tools []mcp.Tool) string {
+	data, _ := json.Marshal(tools)
+	return contentETag(data)
+}
+
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// toolList renders a cache entry, limited to the skill's allowed tool names
+func toolList(skill *models.Skill, entry *toolCacheEntry, stale bool) *models.SkillToolList {
+	var allowed []string
+	if skill.MCPToolNames != nil {
+		json.Unmarshal(skill.MCPToolNames, &allowed)
+	}
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		allowedSet[name] = true
+	}
+
+	list := &models.SkillToolList{
+		SkillID:   skill.ID,
+		Tools:     make([]models.SkillTool, 0, len(entry.tools)),
+		ETag:      entry.fingerprint,
+		FetchedAt: entry.fetchedAt,
+		Stale:     stale,
+	}
+	for _, t := range entry.tools {
+		if len(allowedSet) > 0 && !allowedSet[t.Name] {
+			continue
+		}
+		list.Tools = append(list.Tools, models.SkillTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.InputSchema,
+		})
+	}
+	return list
+}
+
+// snapshotToolList renders the tools recorded with a skill version
+func snapshotToolList(skill *models.Skill, v *models.SkillVersion) *models.SkillToolList {
+	data, _ := json.Marshal(v.Tools)
+	return &models.SkillToolList{
+		SkillID:   skill.ID,
+		Tools:     append([]models.SkillTool(nil), v.Tools...),
+		ETag:      contentETag(data),
+		FetchedAt: v.CreatedAt,
+	}
+}
+
+// invalidateServer expires cached tools of every skill served by server
+func (s *skillToolServiceImpl) invalidateServer(server mcp.Server) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.cache {
+		if entry.serverKey == server.EndpointKey() {
+			entry.fetchedAt = time.Time{}
+		}
+	}
+}
+
+func (s *skillToolServiceImpl) CallTool(ctx context.Context, skill *models.Skill, name string, args map[string]interface{}) (string, error) {
+	var call func(ctx context.Context) (string, error)
+	timeout := time.Duration(skill.ToolTimeoutSeconds) * time.Second
+
+	switch skill.Type {
+	case models.SkillTypeAgent:
+		return "", errAgentSkill
+	case models.SkillTypeBuiltin:
+		// Builtins run in process, validate their arguments and enforce their own time limits
+		return s.builtins.Call(ctx, skill.Name, name, args)
+	case models.SkillTypeFunction:
+		auth, err := s.authForSkill(ctx, skill)
+		if err != nil {
+			return "", err
+		}
+		client := auth.Client(s.httpClient)
+		call = func(ctx context.Context) (string, error) {
+			return s.callFunctionTool(ctx, client, skill, name, args)
+		}
+		if timeout <= 0 {
+			timeout = defaultFunctionTimeout
+		}
+	default:
+		server, ok := mcpServerForSkill(skill)
+		if !ok {
+			return "", fmt.Errorf("skill %q has no MCP server configured", skill.Name)
+		}
+		auth, err := s.authForSkill(ctx, skill)
+		if err != nil {
+			return "", err
+		}
+		server.Auth = auth
+		call = func(ctx context.Context) (string, error) {
+			return s.callMCPTool(ctx, server, name, args)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		// Each attempt gets the full timeout, so a slow attempt can still be retried; only the
+		// caller's context ends the retries early
+		result, err := callWithTimeout(ctx, timeout, call)
+		var final *nonRetryableError
+		if err == nil || errors.As(err, &final) || attempt >= skill.ToolMaxRetries || ctx.Err() != nil {
+			return result, err
+		}
+
+		backoff := toolRetryBackoff << attempt
+		log.Printf("[MCP-TOOLS] Tool %s attempt %d failed, retrying in %s: %v", name, attempt+1, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+	}
+}
+
+func callWithTimeout(ctx context.Context, timeout time.Duration, call func(ctx context.Context) (string, error)) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return call(ctx)
+}
+
+// nonRetryableError marks tool failures that repeating the call would not fix
+type nonRetryableError struct {
+	err error
+}
+
+func (e *nonRetryableError) Error() string { return e.err.Error() }
+func (e *nonRetryableError) Unwrap() error { return e.err }
+
+func (s *skillToolServiceImpl) callMCPTool(ctx context.Context, server mcp.Server, name string, args map[string]interface{}) (string, error) {
+	result, err := s.clients.CallTool(ctx, server, name, args, func(p mcp.Progress) {
+		log.Printf("[MCP-TOOLS] Tool %s progress: %v/%v %s", name, p.Progress, p.Total, p.Message)
+	})
+	if err != nil {
+		return "", fmt.Errorf("tool call failed: %w", err)
+	}
+
+	if result.IsError {
+		return "", &nonRetryableError{fmt.Errorf("tool error: %s", result.Text())}
+	}
+
+	return result.Text(), nil
+}
+
+func (s *skillToolServiceImpl) Health(ctx context.Context, skill *models.Skill) (*models.SkillHealth, error) {
+	server, ok := mcpServerForSkill(skill)
+	if !ok {
+		return nil, fmt.Errorf("skill %q has no MCP server configured", skill.Name)
+	}
+
+	if h := s.lookupHealth(skill.ID, server); h != nil {
+		return h, nil
+	}
+
+	// Ask the server with the caller's credentials, as listings do. Without them a refusal
+	// would be recorded as the server being down and keep the skill out of executions.
+	auth, err := s.authForSkill(ctx, skill)
+	if err != nil {
+		return &models.SkillHealth{SkillID: skill.ID, Status: models.SkillHealthUnknown, Error: err.Error()}, nil
+	}
+	server.Auth = auth
+
+	s.fetch(ctx, skill, server)
+	if h := s.lookupHealth(skill.ID, server); h != nil {
+		return h, nil
+	}
+	return &models.SkillHealth{SkillID: skill.ID, Status: models.SkillHealthUnknown}, nil
+}
+
+func (s *skillToolServiceImpl) lookupHealth(skillID uuid.UUID, server mcp.Server) *models.SkillHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.health[skillID]
+	if h == nil || h.serverKey != server.EndpointKey() {
+		return nil
+	}
+	health := h.health
+	return &health
+}
+
+func (s *skillToolServiceImpl) recordHealth(skillID uuid.UUID, server mcp.Server, latency time.Duration, toolCount int, err error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.health[skillID]
+	if h == nil || h.serverKey != server.EndpointKey() {
+		h = &healthEntry{serverKey: server.EndpointKey(), health: models.SkillHealth{SkillID: skillID}}
+		s.health[skillID] = h
+	}
+
+	h.health.CheckedAt = &now
+	h.health.LatencyMs = latency.Milliseconds()
+	if err != nil {
+		if h.health.Status != models.SkillHealthUnhealthy {
+			log.Printf("[SKILLS] Skill %s server %s is unhealthy: %v", skillID, server, err)
+		}
+		h.health.Status = models.SkillHealthUnhealthy
+		h.health.ConsecutiveFailures++
+		h.health.Error = err.Error()
+		return
+	}
+
+	if h.health.Status == models.SkillHealthUnhealthy {
+		log.Printf("[SKILLS] Skill %s server %s recovered", skillID, server)
+	}
+	h.health.Status = models.SkillHealthHealthy
+	h.health.ConsecutiveFailures = 0
+	h.health.Error = ""
+	h.health.ToolCount = toolCount
+}
+
+func (s *skillToolServiceImpl) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	log.Printf("[SKILLS] Health checks every %s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAll refreshes every MCP skill's tools, which records its health, and forgets skills
+// that no longer exist
+func (s *skillToolServiceImpl) checkAll(ctx context.Context) {
+	skills, err := s.listMCPSkills(ctx)
+	if err != nil {
+		log.Printf("[SKILLS] Health check could not list skills: %v", err)
+		return
+	}
+
+	present := make(map[uuid.UUID]bool, len(skills))
+	sem := make(chan struct{}, healthCheckConcurrency)
+	var wg sync.WaitGroup
+	for i := range skills {
+		skill := &skills[i]
+		server, ok := mcpServerForSkill(skill)
+		if !ok {
+			continue
+		}
+		present[skill.ID] = true
+		if skill.UsesCredentials() {
+			// Its servers are reached with a caller's credentials, which a background check has none of
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.fetch(ctx, skill, server)
+		}()
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	for key := range s.cache {
+		if !present[key.skillID] {
+			delete(s.cache, key)
+		}
+	}
+	for id := range s.health {
+		if !present[id] {
+			delete(s.health, id)
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *skillToolServiceImpl) listMCPSkills(ctx context.Context) ([]models.Skill, error) {
+	skillType := models.SkillTypeMCP
+	filter := models.SkillListFilter{Type: &skillType, Page: 1, Size: 200}
+
+	var skills []models.Skill
+	for {
+		resp, err := s.skillService.List(ctx, nil, filter)
+		if err != nil {
+			return nil, err
+		}
+		skills = append(skills, resp.Skills...)
+		if len(resp.Skills) < filter.Size {
+			return skills, nil
+		}
+		filter.Page++
+	}
+}
@@ -0,0 +1,182 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/credentials"
+	"github.com/tas-agent-builder/services/mcp"
+	"gorm.io/datatypes"
+)
+
+func TestSkillToolServiceCachesAndReportsHealth(t *testing.T) {
+	var lists atomic.Int32
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		lists.Add(1)
+		fmt.Fprint(w, `{"tools":[{"name":"draw","inputSchema":{"type":"object"}},{"name":"erase"}]}`)
+	}))
+	defer server.Close()
+
+	clients := mcp.NewManager(mcp.Implementation{Name: "test", Version: "0"}, 5*time.Second)
+	defer clients.Close()
+	svc := NewSkillToolService(nil, nil, clients, time.Minute)
+
+	skill := &models.Skill{
+		ID:           uuid.New(),
+		Name:         "drawing",
+		Type:         models.SkillTypeMCP,
+		MCPServerURL: server.URL,
+		MCPTransport: mcp.TransportLegacyREST,
+		MCPToolNames: datatypes.JSON(`["draw"]`),
+	}
+	ctx := context.Background()
+
+	list, err := svc.ListTools(ctx, skill, false)
+	require.NoError(t, err)
+	require.Len(t, list.Tools, 1, "limited to mcp_tool_names")
+	assert.Equal(t, "draw", list.Tools[0].Name)
+	assert.NotEmpty(t, list.ETag)
+
+	again, err := svc.ListTools(ctx, skill, false)
+	require.NoError(t, err)
+	assert.Equal(t, list.ETag, again.ETag)
+	assert.Equal(t, int32(1), lists.Load(), "second listing is served from cache")
+
+	health, err := svc.Health(ctx, skill)
+	require.NoError(t, err)
+	assert.Equal(t, models.SkillHealthHealthy, health.Status)
+	assert.Equal(t, 2, health.ToolCount)
+
+	// A failed refresh keeps serving the cached tools, marked stale
+	down.Store(true)
+	stale, err := svc.ListTools(ctx, skill, true)
+	require.NoError(t, err)
+	assert.True(t, stale.Stale)
+	assert.Len(t, stale.Tools, 1)
+
+	health, err = svc.Health(ctx, skill)
+	require.NoError(t, err)
+	assert.Equal(t, models.SkillHealthUnhealthy, health.Status)
+	assert.Equal(t, 1, health.ConsecutiveFailures)
+
+	// A skill with nothing cached on a down server is reported unavailable
+	other := *skill
+	other.ID = uuid.New()
+	_, err = svc.ListTools(ctx, &other, false)
+	assert.ErrorIs(t, err, services.ErrSkillUnavailable)
+}
+
+// stubCredentialAuth renders every skill's auth as one bearer token
+type stubCredentialAuth struct {
+	services.CredentialService
+}
+
+func (stubCredentialAuth) AuthForSkill(ctx context.Context, skill *models.Skill, caller services.Caller) (*credentials.Auth, error) {
+	return &credentials.Auth{Headers: map[string]string{"Authorization": "Bearer secret"}}, nil
+}
+
+func TestHealthUsesCallerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"tools":[{"name":"lookup"}]}`)
+	}))
+	defer server.Close()
+
+	clients := mcp.NewManager(mcp.Implementation{Name: "test", Version: "0"}, 5*time.Second)
+	defer clients.Close()
+	svc := NewSkillToolService(nil, stubCredentialAuth{}, clients, time.Minute)
+
+	skill := &models.Skill{
+		ID:           uuid.New(),
+		Name:         "crm",
+		Type:         models.SkillTypeMCP,
+		MCPServerURL: server.URL,
+		MCPTransport: mcp.TransportLegacyREST,
+		AuthHeaders:  models.CredentialTemplates{"Authorization": "Bearer {{credential:crm}}"},
+	}
+
+	// Without a caller there are no credentials to ask with, which says nothing of the server
+	health, err := svc.Health(context.Background(), skill)
+	require.NoError(t, err)
+	assert.Equal(t, models.SkillHealthUnknown, health.Status)
+
+	ctx := services.WithCaller(context.Background(), services.Caller{TenantID: "tenant-a", UserID: uuid.New()})
+	list, err := svc.ListTools(ctx, skill, false)
+	require.NoError(t, err, "the health check did not take the skill out of executions")
+	assert.Len(t, list.Tools, 1)
+
+	other := *skill
+	other.ID = uuid.New()
+	health, err = svc.Health(ctx, &other)
+	require.NoError(t, err)
+	assert.Equal(t, models.SkillHealthHealthy, health.Status)
+}
+
+func TestToolsOfCredentialedSkillsAreCachedPerCaller(t *testing.T) {
+	var lists atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists.Add(1)
+		// Each tenant's credential unlocks its own tools
+		switch r.Header.Get("Authorization") {
+		case "Bearer secret-a":
+			fmt.Fprint(w, `{"tools":[{"name":"read_a"}]}`)
+		case "Bearer secret-b":
+			fmt.Fprint(w, `{"tools":[{"name":"read_b"}]}`)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	clients := mcp.NewManager(mcp.Implementation{Name: "test", Version: "0"}, 5*time.Second)
+	defer clients.Close()
+	creds := &stubCredentials{secrets: map[string]string{"tenant-a": "secret-a", "tenant-b": "secret-b"}}
+	svc := NewSkillToolService(nil, creds, clients, time.Minute)
+
+	skill := &models.Skill{
+		ID:           uuid.New(),
+		Name:         "crm",
+		Type:         models.SkillTypeMCP,
+		MCPServerURL: server.URL,
+		MCPTransport: mcp.TransportLegacyREST,
+		AuthHeaders:  models.CredentialTemplates{"Authorization": "Bearer {{credential:crm}}"},
+	}
+	ctxA := services.WithCaller(context.Background(), services.Caller{TenantID: "tenant-a", UserID: uuid.New()})
+	ctxB := services.WithCaller(context.Background(), services.Caller{TenantID: "tenant-b", UserID: uuid.New()})
+
+	listA, err := svc.ListTools(ctxA, skill, false)
+	require.NoError(t, err)
+	require.Len(t, listA.Tools, 1)
+	assert.Equal(t, "read_a", listA.Tools[0].Name)
+
+	listB, err := svc.ListTools(ctxB, skill, false)
+	require.NoError(t, err)
+	require.Len(t, listB.Tools, 1)
+	assert.Equal(t, "read_b", listB.Tools[0].Name, "another tenant is not served the first tenant's list")
+	assert.NotEqual(t, listA.ETag, listB.ETag)
+
+	_, err = svc.ListTools(ctxA, skill, false)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), lists.Load(), "each caller's list is cached")
+
+	_, err = svc.ListTools(context.Background(), skill, false)
+	assert.Error(t, err, "a call without a caller is not served a cached list")
+}
@@ -0,0 +1,189 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services"
+	"github.com/tas-agent-builder/services/mcp"
+	"github.com/tas-agent-builder/services/redact"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// maxAuditResultBytes caps the stored result of a tool call
+const maxAuditResultBytes = 16 << 10
+
+type toolCallAuditServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewToolCallAuditService creates a ToolCallAuditService backed by the database
+func NewToolCallAuditService(db *gorm.DB) services.ToolCallAuditService {
+	return &toolCallAuditServiceImpl{db: db}
+}
+
+func (s *toolCallAuditServiceImpl) Record(ctx context.Context, skill *models.Skill, call *models.ExecutionToolCall) error {
+	var rules *models.RedactionRules
+	if skill != nil {
+		call.SkillID = &skill.ID
+		call.SkillName = skill.Name
+		call.Server = toolServer(skill, call.ToolName)
+		rules = &skill.AuditRedaction
+	}
+	if err := redactToolCall(rules, call); err != nil {
+		return err
+	}
+
+	if err := s.db.WithContext(ctx).Create(call).Error; err != nil {
+		return fmt.Errorf("failed to record tool call: %w", err)
+	}
+	return nil
+}
+
+// redactToolCall masks the call's arguments, result and error, then truncates the result
+func redactToolCall(rules *models.RedactionRules, call *models.ExecutionToolCall) error {
+	r, err := redact.New(rules)
+	if err != nil {
+		// Rules are validated when saved; fall back to the built-in masking rather than
+		// storing the call unredacted
+		log.Printf("[AUDIT] Ignoring invalid redaction rules of skill %s: %v", call.SkillName, err)
+		r, _ = redact.New(nil)
+	}
+
+	if len(call.Arguments) > 0 {
+		var args interface{}
+		if json.Unmarshal(call.Arguments, &args) == nil {
+			args = r.Value(args)
+		} else {
+			args = r.String(string(call.Arguments))
+		}
+		redacted, err := json.Marshal(args)
+		if err != nil {
+			return fmt.Errorf("failed to encode tool call arguments: %w", err)
+		}
+		call.Arguments = datatypes.JSON(redacted)
+	}
+
+	call.Result = r.Text(call.Result)
+	call.Error = r.String(call.Error)
+	if len(call.Result) > maxAuditResultBytes {
+		cut := maxAuditResultBytes
+		for cut > 0 && !utf8.RuneStart(call.Result[cut]) {
+			cut--
+		}
+		call.Result = call.Result[:cut]
+		call.ResultTruncated = true
+	}
+	return nil
+}
+
+// toolServer names where a skill's tool runs: the MCP server URL or command, the function
+// tool's scheme and host, or "builtin"
+func toolServer(skill *models.Skill, tool string) string {
+	switch skill.Type {
+	case models.SkillTypeBuiltin:
+		return "builtin"
+	case models.SkillTypeFunction:
+		for _, ft := range skill.FunctionTools {
+			if ft.Name != tool {
+				continue
+			}
+			if u, err := url.Parse(ft.URL); err == nil && u.Host != "" {
+				return u.Scheme + "://" + u.Host
+			}
+			return ft.URL
+		}
+		return ""
+	}
+	if skill.MCPTransport == mcp.TransportStdio {
+		return "stdio:" + skill.MCPCommand
+	}
+	return skill.MCPServerURL
+}
+
+func (s *toolCallAuditServiceImpl) ListForExecution(ctx context.Context, executionID uuid.UUID, userID uuid.UUID) ([]models.ExecutionToolCall, error) {
+	var calls []models.ExecutionToolCall
+	err := s.db.WithContext(ctx).
+		Where("execution_id = ? AND user_id = ?", executionID, userID).
+		Order("created_at ASC").
+		Find(&calls).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool calls: %w", err)
+	}
+	return calls, nil
+}
+
+func (s *toolCallAuditServiceImpl) Search(ctx context.Context, filter models.ToolCallSearchFilter) (*models.ToolCallListResponse, error) {
+	query := s.db.WithContext(ctx).Model(&models.ExecutionToolCall{}).Where("tenant_id = ?", filter.TenantID)
+
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.AgentID != nil {
+		query = query.Where("agent_id = ?", *filter.AgentID)
+	}
+	if filter.ExecutionID != nil {
+		query = query.Where("execution_id = ?", *filter.ExecutionID)
+	}
+	if filter.SkillName != "" {
+		query = query.Where("skill_name = ?", filter.SkillName)
+	}
+	if filter.ToolName != "" {
+		query = query.Where("tool_name = ?", filter.ToolName)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.ApprovedBy != "" {
+		query = query.Where("approved_by = ?", filter.ApprovedBy)
+	}
+	if filter.Query != "" {
+		like := "%" + escapeLike(filter.Query) + "%"
+		query = query.Where("(arguments::text ILIKE ? OR result ILIKE ?)", like, like)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count tool calls: %w", err)
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+	size := filter.Size
+	if size < 1 {
+		size = 50
+	}
+
+	var calls []models.ExecutionToolCall
+	if err := query.Order("created_at DESC").Offset((page - 1) * size).Limit(size).Find(&calls).Error; err != nil {
+		return nil, fmt.Errorf("failed to search tool calls: %w", err)
+	}
+
+	return &models.ToolCallListResponse{
+		ToolCalls: calls,
+		Total:     total,
+		Page:      page,
+		Size:      size,
+	}, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
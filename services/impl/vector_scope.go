@@ -0,0 +1,119 @@
+package impl
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+)
+
+// vectorScope limits a vector search to the chunks of some notebooks and documents of a
+// tenant. The shared dataset holds every tenant's documents, so the scope is sent to DeepLake
+// as metadata filters and enforced again on the results in case the server ignores them.
+type vectorScope struct {
+	tenantID  string
+	notebooks map[string]bool // Empty allows any notebook
+	documents map[string]bool // Empty allows any document
+}
+
+// vectorScopeFor builds the scope of a search, adding the sub-notebooks of its notebooks when
+// it asks for them. Sub-notebooks that cannot be resolved are left out, narrowing the search.
+func (s *documentContextServiceImpl) vectorScopeFor(ctx context.Context, req models.VectorSearchRequest) vectorScope {
+	scope := vectorScope{
+		tenantID:  req.TenantID,
+		notebooks: make(map[string]bool),
+		documents: make(map[string]bool),
+	}
+	for _, id := range req.DocumentIDs {
+		scope.documents[id.String()] = true
+	}
+	for _, id := range req.NotebookIDs {
+		scope.notebooks[id.String()] = true
+	}
+
+	if req.IncludeSubNotebooks && s.notebooks != nil {
+		for _, id := range req.NotebookIDs {
+			subIDs, err := s.notebooks.GetSubNotebookIDs(ctx, id, req.TenantID)
+			if err != nil {
+				log.Printf("[DEBUG] Sub-notebooks of %s not resolved, searching without them: %v", id, err)
+				continue
+			}
+			for _, sub := range subIDs {
+				scope.notebooks[sub.String()] = true
+			}
+		}
+	}
+	return scope
+}
+
+// filters returns the DeepLake metadata filters for the scope, added to the caller's own.
+// The scope's keys replace the caller's so they cannot widen it.
+func (sc vectorScope) filters(base map[string]interface{}) map[string]interface{} {
+	if len(sc.notebooks) == 0 && len(sc.documents) == 0 && len(base) == 0 {
+		return nil
+	}
+	filters := make(map[string]interface{}, len(base)+2)
+	for k, v := range base {
+		filters[k] = v
+	}
+	if f := anyOf(sc.notebooks); f != nil {
+		filters["notebook_id"] = f
+	}
+	if f := anyOf(sc.documents); f != nil {
+		filters["document_id"] = f
+	}
+	return filters
+}
+
+// anyOf is a filter matching any of the IDs: the ID itself when there is one, so servers that
+// only compare for equality still apply it
+func anyOf(ids map[string]bool) interface{} {
+	switch len(ids) {
+	case 0:
+		return nil
+	case 1:
+		for id := range ids {
+			return id
+		}
+	}
+	values := make([]string, 0, len(ids))
+	for id := range ids {
+		values = append(values, id)
+	}
+	sort.Strings(values)
+	return map[string]interface{}{"$in": values}
+}
+
+// allows reports whether a retrieved chunk is in scope. Chunks without the metadata a
+// restriction needs are refused.
+func (sc vectorScope) allows(chunk models.RetrievedChunk) bool {
+	if tenant, ok := chunk.Metadata["tenant_id"].(string); ok && tenant != "" && sc.tenantID != "" && tenant != sc.tenantID {
+		return false
+	}
+	if len(sc.documents) > 0 {
+		docID := chunk.DocumentID
+		if id, ok := chunk.Metadata["document_id"].(string); ok && id != "" {
+			docID = id
+		}
+		if !sc.documents[normalizeID(docID)] {
+			return false
+		}
+	}
+	if len(sc.notebooks) > 0 {
+		notebookID, _ := chunk.Metadata["notebook_id"].(string)
+		if !sc.notebooks[normalizeID(notebookID)] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeID returns a UUID in canonical form, so IDs differing only in case still match
+func normalizeID(id string) string {
+	if parsed, err := uuid.Parse(id); err == nil {
+		return parsed.String()
+	}
+	return id
+}
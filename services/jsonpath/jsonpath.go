@@ -0,0 +1,297 @@
+// Package jsonpath evaluates a practical subset of JSONPath against decoded JSON
+// (the interface{} values produced by encoding/json).
+//
+// Supported syntax: the root $, child access .name and ['name'], array indexes [0] and
+// negative indexes [-1], wildcards .* and [*], slices [start:end], and recursive descent
+// ..name. Filters and script expressions are not supported.
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// step is one parsed path segment
+type step struct {
+	kind      stepKind
+	key       string
+	index     int
+	start     *int
+	end       *int
+	recursive bool
+}
+
+type stepKind int
+
+const (
+	stepKey stepKind = iota
+	stepIndex
+	stepWildcard
+	stepSlice
+)
+
+// Path is a compiled JSONPath expression
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// Compile parses a JSONPath expression. A leading $ is optional.
+func Compile(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+
+	for len(s) > 0 {
+		recursive := false
+		switch {
+		case strings.HasPrefix(s, ".."):
+			recursive = true
+			s = s[2:]
+		case s[0] == '.':
+			s = s[1:]
+		}
+
+		if len(s) == 0 {
+			return nil, fmt.Errorf("jsonpath %q: expression ends after '.'", expr)
+		}
+
+		if s[0] == '[' {
+			end := closingBracket(s)
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unclosed '['", expr)
+			}
+			st, err := parseBracket(s[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: %w", expr, err)
+			}
+			st.recursive = recursive
+			p.steps = append(p.steps, st)
+			s = s[end+1:]
+			continue
+		}
+
+		end := strings.IndexAny(s, ".[")
+		if end < 0 {
+			end = len(s)
+		}
+		name := s[:end]
+		if name == "" {
+			return nil, fmt.Errorf("jsonpath %q: empty member name", expr)
+		}
+		st := step{kind: stepKey, key: name, recursive: recursive}
+		if name == "*" {
+			st = step{kind: stepWildcard, recursive: recursive}
+		}
+		p.steps = append(p.steps, st)
+		s = s[end:]
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the ']' closing the '[' at s[0], skipping quoted names
+func closingBracket(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote != 0:
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseBracket(inner string) (step, error) {
+	inner = strings.TrimSpace(inner)
+	switch {
+	case inner == "*":
+		return step{kind: stepWildcard}, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return step{kind: stepKey, key: inner[1 : len(inner)-1]}, nil
+	case strings.Contains(inner, ":"):
+		parts := strings.SplitN(inner, ":", 2)
+		st := step{kind: stepSlice}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return step{}, fmt.Errorf("invalid slice bound %q", part)
+			}
+			if i == 0 {
+				st.start = &n
+			} else {
+				st.end = &n
+			}
+		}
+		return st, nil
+	default:
+		n, err := strconv.Atoi(inner)
+		if err != nil {
+			return step{}, fmt.Errorf("unsupported selector [%s]", inner)
+		}
+		return step{kind: stepIndex, index: n}, nil
+	}
+}
+
+// String returns the source expression
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Query returns every value the path selects, in document order. Missing members and
+// out-of-range indexes select nothing rather than failing.
+func (p *Path) Query(data interface{}) []interface{} {
+	var out []interface{}
+	for _, s := range p.selectSlots(data, nil) {
+		out = append(out, s.value)
+	}
+	return out
+}
+
+// Replace sets every value the path selects to fn's result, in document order, and returns
+// the updated data with the number of values replaced. Objects and arrays are updated in
+// place; the returned data differs from data only when the path selects the root.
+func (p *Path) Replace(data interface{}, fn func(interface{}) interface{}) (interface{}, int) {
+	root := data
+	slots := p.selectSlots(data, func(v interface{}) { root = v })
+	for _, s := range slots {
+		s.set(fn(s.value))
+	}
+	return root, len(slots)
+}
+
+// Query compiles expr and evaluates it against data
+func Query(data interface{}, expr string) ([]interface{}, error) {
+	p, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(data), nil
+}
+
+// slot is a selected value and how to replace it in its parent
+type slot struct {
+	value interface{}
+	set   func(interface{})
+}
+
+func (p *Path) selectSlots(data interface{}, setRoot func(interface{})) []slot {
+	current := []slot{{value: data, set: setRoot}}
+	for _, st := range p.steps {
+		var next []slot
+		for _, s := range current {
+			if st.recursive {
+				for _, d := range descendants(s) {
+					next = append(next, apply(st, d)...)
+				}
+			} else {
+				next = append(next, apply(st, s)...)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func apply(st step, s slot) []slot {
+	switch st.kind {
+	case stepKey:
+		if obj, ok := s.value.(map[string]interface{}); ok {
+			if child, ok := obj[st.key]; ok {
+				return []slot{memberSlot(obj, st.key, child)}
+			}
+		}
+	case stepIndex:
+		if arr, ok := s.value.([]interface{}); ok {
+			i := st.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				return []slot{elementSlot(arr, i)}
+			}
+		}
+	case stepWildcard:
+		return children(s)
+	case stepSlice:
+		if arr, ok := s.value.([]interface{}); ok {
+			start, end := 0, len(arr)
+			if st.start != nil {
+				start = clampIndex(*st.start, len(arr))
+			}
+			if st.end != nil {
+				end = clampIndex(*st.end, len(arr))
+			}
+			var out []slot
+			for i := start; i < end; i++ {
+				out = append(out, elementSlot(arr, i))
+			}
+			return out
+		}
+	}
+	return nil
+}
+
+func memberSlot(obj map[string]interface{}, key string, value interface{}) slot {
+	return slot{value: value, set: func(v interface{}) { obj[key] = v }}
+}
+
+func elementSlot(arr []interface{}, i int) slot {
+	return slot{value: arr[i], set: func(v interface{}) { arr[i] = v }}
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// children returns an object's values in key order or an array's elements
+func children(s slot) []slot {
+	switch t := s.value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]slot, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, memberSlot(t, k, t[k]))
+		}
+		return out
+	case []interface{}:
+		out := make([]slot, 0, len(t))
+		for i := range t {
+			out = append(out, elementSlot(t, i))
+		}
+		return out
+	}
+	return nil
+}
+
+// descendants returns s and everything nested in it, depth first
+func descendants(s slot) []slot {
+	out := []slot{s}
+	for _, c := range children(s) {
+		out = append(out, descendants(c)...)
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const doc = `{
+	"store": {
+		"book": [
+			{"title": "Sayings", "price": 8.95, "tags": ["old"]},
+			{"title": "Sword", "price": 12.99},
+			{"title": "Moby Dick", "price": 8.99, "isbn": "0-553"}
+		],
+		"bicycle": {"color": "red", "price": 19.95},
+		"odd key": true
+	}
+}`
+
+func TestQuery(t *testing.T) {
+	var data interface{}
+	require.NoError(t, json.Unmarshal([]byte(doc), &data))
+
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{
+		{"$.store.bicycle.color", []interface{}{"red"}},
+		{"store.bicycle.color", []interface{}{"red"}},
+		{"$.store.book[0].title", []interface{}{"Sayings"}},
+		{"$.store.book[-1].title", []interface{}{"Moby Dick"}},
+		{"$.store.book[*].price", []interface{}{8.95, 12.99, 8.99}},
+		{"$.store.book[1:].title", []interface{}{"Sword", "Moby Dick"}},
+		{"$['store']['odd key']", []interface{}{true}},
+		{"$..isbn", []interface{}{"0-553"}},
+		{"$.store.bicycle.*", []interface{}{"red", 19.95}},
+		{"$.store.missing", nil},
+		{"$.store.book[7]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := Query(data, tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{"$.store[", "$.store.", "$.book[?(@.price < 10)]", "$..[x]"} {
+		_, err := Compile(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	var data interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"user": {"password": "p1"}, "items": [{"token": "t1"}, {"token": "t2"}]}`), &data))
+
+	mask := func(interface{}) interface{} { return "***" }
+	p, err := Compile("$..token")
+	require.NoError(t, err)
+	data, n := p.Replace(data, mask)
+	assert.Equal(t, 2, n)
+
+	p, err = Compile("$.user.password")
+	require.NoError(t, err)
+	data, n = p.Replace(data, mask)
+	assert.Equal(t, 1, n)
+
+	out, _ := json.Marshal(data)
+	assert.JSONEq(t, `{"user": {"password": "***"}, "items": [{"token": "***"}, {"token": "***"}]}`, string(out))
+
+	root, n := (&Path{}).Replace(data, mask)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "***", root)
+}
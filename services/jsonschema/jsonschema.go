@@ -0,0 +1,532 @@
+// Package jsonschema validates decoded JSON values against JSON Schema (draft 2020-12).
+//
+// It implements the keywords tool input schemas use in practice: type, enum, const, required,
+// properties, additionalProperties, items, prefixItems, numeric ranges, length and size limits,
+// pattern, allOf/anyOf/oneOf/not and local $ref. Other keywords are ignored, so a constraint
+// this package does not know never rejects a value.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// maxViolations bounds the violations reported for one value
+	maxViolations = 20
+
+	// maxDepth stops runaway recursion through $ref cycles
+	maxDepth = 64
+)
+
+// Violation is one way a value fails its schema
+type Violation struct {
+	Path    string `json:"path"` // JSON Pointer to the offending value; empty for the root
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Validate checks value against schema and returns its violations, at most maxViolations of
+// them. Schemas may use Go values as well as decoded JSON ([]string, int and the like).
+func Validate(schema map[string]interface{}, value interface{}) []Violation {
+	v := &validator{root: schema}
+	v.validate(schema, value, "", 0)
+	return v.violations
+}
+
+type validator struct {
+	root       map[string]interface{}
+	violations []Violation
+}
+
+func (v *validator) report(path, format string, args ...interface{}) {
+	if len(v.violations) < maxViolations {
+		v.violations = append(v.violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// valid reports whether value satisfies schema without recording violations
+func (v *validator) valid(schema interface{}, value interface{}, path string, depth int) bool {
+	sub := &validator{root: v.root}
+	sub.validate(schema, value, path, depth)
+	return len(sub.violations) == 0
+}
+
+func (v *validator) validate(schemaValue interface{}, value interface{}, path string, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	switch s := schemaValue.(type) {
+	case bool:
+		if !s {
+			v.report(path, "is not allowed")
+		}
+		return
+	case map[string]interface{}:
+		v.validateSchema(s, value, path, depth)
+	}
+}
+
+func (v *validator) validateSchema(schema map[string]interface{}, value interface{}, path string, depth int) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.report(path, "%v", err)
+			return
+		}
+		v.validate(target, value, path, depth+1)
+	}
+
+	if types := stringList(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if matchesType(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.report(path, "must be %s, got %s", joinOr(articled(types)), describe(value))
+			// Keywords for other types would only repeat the mismatch
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		options := toList(enum)
+		found := false
+		for _, option := range options {
+			if equal(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report(path, "must be one of %s", renderValues(options))
+		}
+	}
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		v.report(path, "must be %s", render(c))
+	}
+
+	switch t := value.(type) {
+	case string:
+		v.validateString(schema, t, path)
+	case map[string]interface{}:
+		v.validateObject(schema, t, path, depth)
+	case []interface{}:
+		v.validateArray(schema, t, path, depth)
+	default:
+		if n, ok := toNumber(value); ok {
+			v.validateNumber(schema, n, path)
+		}
+	}
+
+	v.validateComposition(schema, value, path, depth)
+}
+
+func (v *validator) validateString(schema map[string]interface{}, s string, path string) {
+	length := float64(utf8.RuneCountInString(s))
+	if min, ok := toNumber(schema["minLength"]); ok && length < min {
+		v.report(path, "must be at least %s characters", formatNumber(min))
+	}
+	if max, ok := toNumber(schema["maxLength"]); ok && length > max {
+		v.report(path, "must be at most %s characters", formatNumber(max))
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		// Patterns Go cannot compile are skipped rather than failing every call
+		if re := compilePattern(pattern); re != nil && !re.MatchString(s) {
+			v.report(path, "must match the pattern %s", pattern)
+		}
+	}
+}
+
+func (v *validator) validateNumber(schema map[string]interface{}, n float64, path string) {
+	if min, ok := toNumber(schema["minimum"]); ok {
+		// Draft 4 spelled exclusive bounds as booleans next to minimum and maximum
+		if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive && n <= min {
+			v.report(path, "must be greater than %s", formatNumber(min))
+		} else if n < min {
+			v.report(path, "must be at least %s", formatNumber(min))
+		}
+	}
+	if max, ok := toNumber(schema["maximum"]); ok {
+		if exclusive, _ := schema["exclusiveMaximum"].(bool); exclusive && n >= max {
+			v.report(path, "must be less than %s", formatNumber(max))
+		} else if n > max {
+			v.report(path, "must be at most %s", formatNumber(max))
+		}
+	}
+	if min, ok := toNumber(schema["exclusiveMinimum"]); ok && n <= min {
+		v.report(path, "must be greater than %s", formatNumber(min))
+	}
+	if max, ok := toNumber(schema["exclusiveMaximum"]); ok && n >= max {
+		v.report(path, "must be less than %s", formatNumber(max))
+	}
+	if m, ok := toNumber(schema["multipleOf"]); ok && m > 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.report(path, "must be a multiple of %s", formatNumber(m))
+		}
+	}
+}
+
+func (v *validator) validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, depth int) {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := obj[name]; !ok {
+			v.report(pointer(path, name), "is required")
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	additional, hasAdditional := schema["additionalProperties"]
+	for _, name := range names {
+		if prop, ok := properties[name]; ok {
+			v.validate(prop, obj[name], pointer(path, name), depth+1)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			v.report(pointer(path, name), "is not an allowed property")
+			continue
+		}
+		v.validate(additional, obj[name], pointer(path, name), depth+1)
+	}
+
+	if min, ok := toNumber(schema["minProperties"]); ok && float64(len(obj)) < min {
+		v.report(path, "must have at least %s properties", formatNumber(min))
+	}
+	if max, ok := toNumber(schema["maxProperties"]); ok && float64(len(obj)) > max {
+		v.report(path, "must have at most %s properties", formatNumber(max))
+	}
+}
+
+func (v *validator) validateArray(schema map[string]interface{}, items []interface{}, path string, depth int) {
+	if min, ok := toNumber(schema["minItems"]); ok && float64(len(items)) < min {
+		v.report(path, "must have at least %s items", formatNumber(min))
+	}
+	if max, ok := toNumber(schema["maxItems"]); ok && float64(len(items)) > max {
+		v.report(path, "must have at most %s items", formatNumber(max))
+	}
+
+	prefix := toList(schema["prefixItems"])
+	for i, item := range items {
+		itemPath := pointer(path, strconv.Itoa(i))
+		if i < len(prefix) {
+			v.validate(prefix[i], item, itemPath, depth+1)
+		} else if itemSchema, ok := schema["items"]; ok {
+			v.validate(itemSchema, item, itemPath, depth+1)
+		}
+	}
+
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := 1; i < len(items); i++ {
+			for j := 0; j < i; j++ {
+				if equal(items[i], items[j]) {
+					v.report(pointer(path, strconv.Itoa(i)), "duplicates item %d", j)
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) validateComposition(schema map[string]interface{}, value interface{}, path string, depth int) {
+	for _, sub := range toList(schema["allOf"]) {
+		v.validate(sub, value, path, depth+1)
+	}
+
+	if anyOf := toList(schema["anyOf"]); len(anyOf) > 0 {
+		matched := false
+		for _, sub := range anyOf {
+			if v.valid(sub, value, path, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.report(path, "must match one of %s", describeAlternatives(anyOf))
+		}
+	}
+
+	if oneOf := toList(schema["oneOf"]); len(oneOf) > 0 {
+		matches := 0
+		for _, sub := range oneOf {
+			if v.valid(sub, value, path, depth+1) {
+				matches++
+			}
+		}
+		if matches == 0 {
+			v.report(path, "must match one of %s", describeAlternatives(oneOf))
+		} else if matches > 1 {
+			v.report(path, "must match exactly one of %s, but matches %d", describeAlternatives(oneOf), matches)
+		}
+	}
+
+	if not, ok := schema["not"]; ok && v.valid(not, value, path, depth+1) {
+		v.report(path, "matches a schema it must not match")
+	}
+}
+
+// resolve finds the schema a local reference such as #/$defs/Item points at
+func (v *validator) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("schema reference %s is not supported", ref)
+	}
+
+	var node interface{} = v.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("schema reference %s cannot be resolved", ref)
+		}
+		if node, ok = m[token]; !ok {
+			return nil, fmt.Errorf("schema reference %s cannot be resolved", ref)
+		}
+	}
+	return node, nil
+}
+
+func matchesType(t string, value interface{}) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "number":
+		n, ok := toNumber(value)
+		return ok && !math.IsNaN(n) && !math.IsInf(n, 0)
+	case "integer":
+		n, ok := toNumber(value)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	}
+	// Unknown type names never reject a value
+	return true
+}
+
+// describe names a value's JSON type for messages
+func describe(value interface{}) string {
+	switch t := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	default:
+		if n, ok := toNumber(t); ok {
+			if n == math.Trunc(n) {
+				return "the integer " + formatNumber(n)
+			}
+			return "the number " + formatNumber(n)
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// describeAlternatives lists the types of anyOf/oneOf branches when they declare them, which
+// covers the common nullable pattern, and otherwise counts the branches
+func describeAlternatives(branches []interface{}) string {
+	var types []string
+	for _, b := range branches {
+		m, ok := b.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("%d schemas", len(branches))
+		}
+		ts := stringList(m["type"])
+		if len(ts) == 0 {
+			return fmt.Sprintf("%d schemas", len(branches))
+		}
+		types = append(types, ts...)
+	}
+	return "the types " + strings.Join(types, ", ")
+}
+
+func articled(types []string) []string {
+	out := make([]string, len(types))
+	for i, t := range types {
+		switch t {
+		case "null":
+			out[i] = "null"
+		case "object", "array", "integer":
+			out[i] = "an " + t
+		default:
+			out[i] = "a " + t
+		}
+	}
+	return out
+}
+
+func joinOr(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+func pointer(path, token string) string {
+	token = strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+	return path + "/" + token
+}
+
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, s := range t {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// toList turns a schema's array keyword into []interface{}, whatever slice type it was built with
+func toList(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal compares JSON values, treating numbers of any Go type by value
+func equal(a, b interface{}) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x == y
+	}
+	if _, ok := toNumber(b); ok {
+		return false
+	}
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, xv := range x {
+			yv, ok := y[k]
+			if !ok || !equal(xv, yv) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func render(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func renderValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = render(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+var patterns sync.Map // pattern → *regexp.Regexp, or nil when Go cannot compile it
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = nil
+	}
+	patterns.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,97 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) map[string]interface{} {
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &m))
+	return m
+}
+
+func messages(violations []Violation) []string {
+	out := make([]string, len(violations))
+	for i, v := range violations {
+		out[i] = v.String()
+	}
+	return out
+}
+
+func TestValidate(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"query": {"type": "string", "minLength": 1},
+			"limit": {"type": "integer", "minimum": 1, "maximum": 50},
+			"sort":  {"enum": ["asc", "desc"]},
+			"score": {"type": "number", "exclusiveMinimum": 0},
+			"owner": {"anyOf": [{"$ref": "#/$defs/user"}, {"type": "null"}]},
+			"tags":  {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+		},
+		"required": ["query"],
+		"additionalProperties": false,
+		"$defs": {
+			"user": {
+				"type": "object",
+				"properties": {"id": {"type": "string"}},
+				"required": ["id"]
+			}
+		}
+	}`)
+
+	assert.Empty(t, Validate(schema, decode(t, `{"query": "x", "limit": 10, "sort": "asc", "owner": null, "tags": ["a"]}`)))
+	assert.Empty(t, Validate(schema, decode(t, `{"query": "x", "owner": {"id": "u1"}}`)))
+
+	got := messages(Validate(schema, decode(t, `{
+		"limit": 2.5, "sort": "up", "score": 0, "owner": {"name": "n"}, "tags": ["a", 1, "c"], "extra": true
+	}`)))
+	assert.ElementsMatch(t, []string{
+		"/query: is required",
+		"/extra: is not an allowed property",
+		"/limit: must be an integer, got the number 2.5",
+		"/owner: must match one of 2 schemas",
+		"/score: must be greater than 0",
+		`/sort: must be one of "asc", "desc"`,
+		"/tags: must have at most 2 items",
+		"/tags/1: must be a string, got the integer 1",
+	}, got)
+
+	assert.Equal(t, []string{"must be an object, got an array"}, messages(Validate(schema, []interface{}{})))
+}
+
+func TestValidateGoSchemas(t *testing.T) {
+	// Schemas built in Go, like the builtin tools', use native slices and ints
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"unit":  map[string]interface{}{"type": "string", "enum": []string{"km", "mi"}},
+			"count": map[string]interface{}{"type": "integer", "maximum": 3},
+			"value": map[string]interface{}{"type": []string{"number", "null"}},
+		},
+		"required": []string{"unit"},
+	}
+	assert.Empty(t, Validate(schema, map[string]interface{}{"unit": "km", "count": 3.0, "value": nil}))
+	assert.Equal(t, []string{
+		"/count: must be at most 3",
+		`/unit: must be one of "km", "mi"`,
+		"/value: must be a number or null, got a string",
+	}, messages(Validate(schema, map[string]interface{}{"unit": "m", "count": 4.0, "value": "1"})))
+}
+
+func TestValidateRecursiveRef(t *testing.T) {
+	schema := decode(t, `{
+		"$ref": "#/$defs/node",
+		"$defs": {"node": {"type": "object", "properties": {"child": {"$ref": "#/$defs/node"}, "n": {"type": "integer"}}}}
+	}`)
+	assert.Empty(t, Validate(schema, decode(t, `{"child": {"child": {"n": 1}}}`)))
+	assert.Equal(t, []string{"/child/child/n: must be an integer, got a string"},
+		messages(Validate(schema, decode(t, `{"child": {"child": {"n": "1"}}}`))))
+
+	assert.Equal(t, []string{"schema reference #/$defs/missing cannot be resolved"},
+		messages(Validate(map[string]interface{}{"$ref": "#/$defs/missing"}, nil)))
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tas-agent-builder/models"
+)
+
+// ErrUnknownLLMBackend is returned when an agent selects a backend that is not configured
+var ErrUnknownLLMBackend = errors.New("unknown LLM backend")
+
+// LLMBackend sends chat completions straight to one provider API, bypassing the TAS LLM router.
+// The RouterService selects a backend per agent and adds retries and reliability metadata.
+type LLMBackend interface {
+	// Name is the backend name agents select it with (AgentLLMConfig.Backend)
+	Name() string
+
+	// Complete performs a single attempt; HTTP failures are returned as *LLMBackendError
+	Complete(ctx context.Context, req LLMBackendRequest) (*LLMBackendResponse, error)
+
+	// ListModels returns the models the backend serves
+	ListModels(ctx context.Context) ([]Model, error)
+
+	// ModelCapabilities describes a model the backend serves, from what its API reports and
+	// the backend's configured models, so agents on it never consult the router's catalog
+	ModelCapabilities(ctx context.Context, model string) (*ModelCapabilities, error)
+}
+
+// LLMBackendRequest is a provider-neutral chat completion request
+type LLMBackendRequest struct {
+	Config     models.AgentLLMConfig
+	Messages   []Message
+	Tools      []ToolDefinition
+	ToolChoice string // "auto" (default), "required", "none" or the name of a tool to call
+	Stream     bool
+}
+
+// LLMBackendResponse is a provider-neutral chat completion result. FinishReason uses the
+// OpenAI values (stop, length, tool_calls) regardless of backend.
+type LLMBackendResponse struct {
+	ID               string
+	Model            string
+	Content          string
+	ToolCalls        []ToolCall
+	FinishReason     string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// LLMBackendError is a non-success HTTP response from a backend
+type LLMBackendError struct {
+	Backend    string
+	StatusCode int
+	Body       string
+}
+
+func (e *LLMBackendError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.Backend, e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the request may succeed if sent again
+func (e *LLMBackendError) Retryable() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
@@ -0,0 +1,359 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxToolPages bounds tools/list pagination against servers that never stop returning cursors
+	maxToolPages = 100
+
+	// cancelNotifyTimeout bounds sending cancellations and ping replies
+	cancelNotifyTimeout = 5 * time.Second
+)
+
+// Client is an MCP client session over one transport. It is safe for concurrent use.
+type Client struct {
+	transport Transport
+	info      Implementation
+
+	nextID atomic.Int64
+
+	mu             sync.Mutex
+	pending        map[string]chan *message
+	progress       map[string]func(Progress)
+	onNotification func(Notification)
+	closeErr       error
+
+	version      string
+	serverInfo   Implementation
+	capabilities ServerCapabilities
+}
+
+// NewClient creates a client over transport; call Initialize before anything else
+func NewClient(transport Transport, info Implementation) *Client {
+	return &Client{
+		transport: transport,
+		info:      info,
+		pending:   make(map[string]chan *message),
+		progress:  make(map[string]func(Progress)),
+	}
+}
+
+// OnNotification registers a handler for server notifications other than progress. It must
+// be set before Initialize and must not block.
+func (c *Client) OnNotification(fn func(Notification)) {
+	c.mu.Lock()
+	c.onNotification = fn
+	c.mu.Unlock()
+}
+
+// Initialize starts the transport and performs the initialize handshake
+func (c *Client) Initialize(ctx context.Context) (*InitializeResult, error) {
+	if err := c.transport.Start(ctx, c.handleMessage, c.handleClose); err != nil {
+		return nil, err
+	}
+
+	var result InitializeResult
+	err := c.call(ctx, MethodInitialize, InitializeParams{
+		ProtocolVersion: LatestProtocolVersion,
+		Capabilities:    ClientCapabilities{},
+		ClientInfo:      c.info,
+	}, &result, nil)
+	if err != nil {
+		return nil, fmt.Errorf("initialize failed: %w", err)
+	}
+
+	if !supportedVersion(result.ProtocolVersion) {
+		return nil, fmt.Errorf("server chose unsupported protocol version %q", result.ProtocolVersion)
+	}
+	if setter, ok := c.transport.(protocolVersionSetter); ok {
+		setter.SetProtocolVersion(result.ProtocolVersion)
+	}
+
+	c.mu.Lock()
+	c.version = result.ProtocolVersion
+	c.serverInfo = result.ServerInfo
+	c.capabilities = result.Capabilities
+	c.mu.Unlock()
+
+	if err := c.notify(ctx, NotificationInitialized, nil); err != nil {
+		return nil, fmt.Errorf("failed to send initialized notification: %w", err)
+	}
+
+	log.Printf("[MCP] Initialized session with %s %s (protocol %s)",
+		result.ServerInfo.Name, result.ServerInfo.Version, result.ProtocolVersion)
+	return &result, nil
+}
+
+func supportedVersion(v string) bool {
+	for _, s := range SupportedProtocolVersions {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerInfo returns the server's name and version from the handshake
+func (c *Client) ServerInfo() Implementation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serverInfo
+}
+
+// Capabilities returns the capabilities the server advertised
+func (c *Client) Capabilities() ServerCapabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.capabilities
+}
+
+// ProtocolVersion returns the negotiated protocol revision
+func (c *Client) ProtocolVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// Ping checks the server is responsive
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, MethodPing, nil, nil, nil)
+}
+
+// ListTools returns all tools, following pagination cursors
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for page := 0; page < maxToolPages; page++ {
+		var params interface{}
+		if cursor != "" {
+			params = listToolsParams{Cursor: cursor}
+		}
+
+		var result ListToolsResult
+		if err := c.call(ctx, MethodToolsList, params, &result, nil); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+	return tools, fmt.Errorf("tools/list did not finish after %d pages", maxToolPages)
+}
+
+// CallTool invokes a tool. onProgress, when not nil, receives progress notifications for this
+// call. A tool-level failure is reported in the result's IsError, not as an error.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}, onProgress func(Progress)) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, MethodToolsCall, callToolParams{Name: name, Arguments: args}, &result, onProgress); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close ends the session; pending calls fail with ErrClosed
+func (c *Client) Close() error {
+	c.failPending(ErrClosed)
+	return c.transport.Close()
+}
+
+// Err returns the reason the connection ended, or nil while it is usable
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeErr
+}
+
+// call sends a request and waits for its response. If ctx ends first the server is told to
+// cancel the request.
+func (c *Client) call(ctx context.Context, method string, params interface{}, out interface{}, onProgress func(Progress)) error {
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	respCh := make(chan *message, 1)
+
+	c.mu.Lock()
+	if c.closeErr != nil {
+		c.mu.Unlock()
+		return c.closeErr
+	}
+	c.pending[id] = respCh
+	if onProgress != nil {
+		c.progress[id] = onProgress
+		if p, ok := params.(callToolParams); ok {
+			p.Meta = &requestMeta{ProgressToken: id}
+			params = p
+		}
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		delete(c.progress, id)
+		c.mu.Unlock()
+	}()
+
+	req, err := json.Marshal(Request{JSONRPC: jsonRPCVersion, ID: json.RawMessage(id), Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+	if err := c.transport.Send(ctx, req); err != nil {
+		// The request may have reached the server before the caller gave up
+		if ctx.Err() != nil && method != MethodInitialize {
+			c.cancel(id, ctx.Err())
+		}
+		return err
+	}
+
+	select {
+	case resp := <-respCh:
+		if resp == nil {
+			return c.Err()
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if out != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, out); err != nil {
+				return fmt.Errorf("failed to decode %s result: %w", method, err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		if method != MethodInitialize {
+			c.cancel(id, ctx.Err())
+		}
+		return ctx.Err()
+	}
+}
+
+// cancel tells the server to stop working on request id
+func (c *Client) cancel(id string, reason error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelNotifyTimeout)
+	defer cancel()
+	if err := c.notify(ctx, NotificationCancelled, cancelledParams{RequestID: json.RawMessage(id), Reason: reason.Error()}); err != nil {
+		log.Printf("[MCP] Failed to send cancellation for request %s: %v", id, err)
+	}
+}
+
+func (c *Client) notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := json.Marshal(Request{JSONRPC: jsonRPCVersion, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s notification: %w", method, err)
+	}
+	return c.transport.Send(ctx, msg)
+}
+
+// handleMessage routes an incoming message to its pending call, the progress callback, the
+// notification handler, or answers a server request
+func (c *Client) handleMessage(raw json.RawMessage) {
+	var msg message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		log.Printf("[MCP] Dropping malformed message: %v", err)
+		return
+	}
+
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		go c.handleServerRequest(msg)
+	case msg.Method != "":
+		c.handleNotification(msg)
+	case msg.ID != nil:
+		// Deliver under the lock so failPending cannot close the channel mid-send
+		c.mu.Lock()
+		if respCh, ok := c.pending[idKey(msg.ID)]; ok {
+			select {
+			case respCh <- &msg:
+			default:
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Client) handleNotification(msg message) {
+	if msg.Method == NotificationProgress {
+		var p Progress
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return
+		}
+		c.mu.Lock()
+		fn := c.progress[fmt.Sprint(p.ProgressToken)]
+		c.mu.Unlock()
+		if fn != nil {
+			fn(p)
+		}
+		return
+	}
+
+	c.mu.Lock()
+	fn := c.onNotification
+	c.mu.Unlock()
+	if fn != nil {
+		fn(Notification{Method: msg.Method, Params: msg.Params})
+	}
+}
+
+// handleServerRequest answers requests the server sends to the client. Only ping is
+// supported since no client capabilities are advertised.
+func (c *Client) handleServerRequest(msg message) {
+	resp := Response{JSONRPC: jsonRPCVersion, ID: msg.ID}
+	if msg.Method == MethodPing {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelNotifyTimeout)
+	defer cancel()
+	if err := c.transport.Send(ctx, encoded); err != nil {
+		log.Printf("[MCP] Failed to answer %s request: %v", msg.Method, err)
+	}
+}
+
+func (c *Client) handleClose(err error) {
+	log.Printf("[MCP] Connection closed: %v", err)
+	c.failPending(fmt.Errorf("%w: %v", ErrClosed, err))
+}
+
+// failPending marks the client closed and wakes every waiting call
+func (c *Client) failPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeErr == nil {
+		c.closeErr = err
+	}
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// idKey normalises a JSON-RPC ID so numeric and string forms of the same ID match
+func idKey(id json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(id, &s); err == nil {
+		return s
+	}
+	return string(id)
+}
+
+// IsConnectionError reports whether err means the session is unusable and should be redialed
+func IsConnectionError(err error) bool {
+	return errors.Is(err, ErrClosed) || errors.Is(err, ErrSessionExpired)
+}
@@ -0,0 +1,382 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/services/credentials"
+)
+
+// fakeServer is a minimal MCP server. It answers tools/list in two pages, echoes tools/call
+// arguments, sends progress for the "slow" tool and blocks it until cancelled.
+type fakeServer struct {
+	mu            sync.Mutex
+	received      []message
+	cancelled     chan string
+	sessionID     string
+	expireSession bool
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{cancelled: make(chan string, 1), sessionID: "session-1"}
+}
+
+func (f *fakeServer) methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var methods []string
+	for _, m := range f.received {
+		methods = append(methods, m.Method)
+	}
+	return methods
+}
+
+// handle processes one incoming message and returns the messages to send back, in order
+func (f *fakeServer) handle(msg message) []interface{} {
+	f.mu.Lock()
+	f.received = append(f.received, msg)
+	f.mu.Unlock()
+
+	if msg.ID == nil {
+		if msg.Method == NotificationCancelled {
+			var p cancelledParams
+			json.Unmarshal(msg.Params, &p)
+			f.cancelled <- string(p.RequestID)
+		}
+		return nil
+	}
+
+	reply := func(result interface{}) Response {
+		raw, _ := json.Marshal(result)
+		return Response{JSONRPC: jsonRPCVersion, ID: msg.ID, Result: raw}
+	}
+
+	switch msg.Method {
+	case MethodInitialize:
+		return []interface{}{reply(InitializeResult{
+			ProtocolVersion: ProtocolVersion20250326,
+			Capabilities:    ServerCapabilities{Tools: &ListChangedCapability{ListChanged: true}},
+			ServerInfo:      Implementation{Name: "fake", Version: "1.0"},
+		})}
+	case MethodToolsList:
+		var p listToolsParams
+		json.Unmarshal(msg.Params, &p)
+		if p.Cursor == "" {
+			return []interface{}{reply(ListToolsResult{Tools: []Tool{{Name: "echo"}}, NextCursor: "page2"})}
+		}
+		return []interface{}{reply(ListToolsResult{Tools: []Tool{{Name: "slow"}}})}
+	case MethodToolsCall:
+		var p callToolParams
+		json.Unmarshal(msg.Params, &p)
+		if p.Name == "slow" {
+			var out []interface{}
+			if p.Meta != nil {
+				out = append(out, Request{JSONRPC: jsonRPCVersion, Method: NotificationProgress,
+					Params: Progress{ProgressToken: p.Meta.ProgressToken, Progress: 1, Total: 2, Message: "halfway"}})
+			}
+			return out // Never answered; the client must cancel
+		}
+		args, _ := json.Marshal(p.Arguments)
+		return []interface{}{
+			Request{JSONRPC: jsonRPCVersion, Method: NotificationMessage, Params: map[string]string{"level": "info", "data": "called"}},
+			reply(CallToolResult{Content: []Content{{Type: "text", Text: string(args)}}}),
+		}
+	default:
+		return []interface{}{Response{JSONRPC: jsonRPCVersion, ID: msg.ID, Error: &RPCError{Code: CodeMethodNotFound, Message: "nope"}}}
+	}
+}
+
+// streamableHandler serves the Streamable HTTP transport, answering tools/call over SSE and
+// everything else as JSON
+func (f *fakeServer) streamableHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var msg message
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &msg)
+
+		if msg.Method != MethodInitialize {
+			f.mu.Lock()
+			expired := f.expireSession
+			f.expireSession = false
+			f.mu.Unlock()
+			if r.Header.Get("Mcp-Session-Id") != f.sessionID || expired {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+
+		out := f.handle(msg)
+		if msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		w.Header().Set("Mcp-Session-Id", f.sessionID)
+		if msg.Method != MethodToolsCall {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(out[0])
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, m := range out {
+			data, _ := json.Marshal(m)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+	})
+}
+
+// sseHandler serves the legacy HTTP+SSE transport at /sse and /messages
+func (f *fakeServer) sseHandler() http.Handler {
+	var mu sync.Mutex
+	var stream chan []byte
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		stream = make(chan []byte, 16)
+		ch := stream
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		fmt.Fprint(w, "event: endpoint\ndata: /messages?session=1\n\n")
+		flusher.Flush()
+		for {
+			select {
+			case data := <-ch:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		var msg message
+		json.NewDecoder(r.Body).Decode(&msg)
+		w.WriteHeader(http.StatusAccepted)
+
+		mu.Lock()
+		ch := stream
+		mu.Unlock()
+		for _, m := range f.handle(msg) {
+			data, _ := json.Marshal(m)
+			ch <- data
+		}
+	})
+	return mux
+}
+
+var testInfo = Implementation{Name: "test", Version: "0"}
+
+func exerciseClient(t *testing.T, f *fakeServer, client *Client) {
+	t.Helper()
+	ctx := context.Background()
+
+	assert.Equal(t, "fake", client.ServerInfo().Name)
+	assert.Equal(t, ProtocolVersion20250326, client.ProtocolVersion())
+	require.NotNil(t, client.Capabilities().Tools)
+	assert.True(t, client.Capabilities().Tools.ListChanged)
+
+	tools, err := client.ListTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 2, "both pages are returned")
+	assert.Equal(t, "echo", tools[0].Name)
+	assert.Equal(t, "slow", tools[1].Name)
+
+	result, err := client.CallTool(ctx, "echo", map[string]interface{}{"q": "hi"}, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"q":"hi"}`, result.Text())
+
+	progress := make(chan Progress, 1)
+	callCtx, cancel := context.WithCancel(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.CallTool(callCtx, "slow", nil, func(p Progress) { progress <- p })
+		errCh <- err
+	}()
+
+	select {
+	case p := <-progress:
+		assert.Equal(t, "halfway", p.Message)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no progress notification")
+	}
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+
+	select {
+	case id := <-f.cancelled:
+		assert.NotEmpty(t, id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("server was not told about the cancellation")
+	}
+
+	methods := f.methods()
+	assert.Equal(t, MethodInitialize, methods[0])
+	assert.Equal(t, NotificationInitialized, methods[1])
+}
+
+func TestStreamableHTTPClient(t *testing.T) {
+	f := newFakeServer()
+	server := httptest.NewServer(f.streamableHandler())
+	defer server.Close()
+
+	client, err := Dial(context.Background(), Server{URL: server.URL, Transport: TransportStreamableHTTP}, testInfo, nil, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	exerciseClient(t, f, client)
+}
+
+func TestSSEClient(t *testing.T) {
+	f := newFakeServer()
+	server := httptest.NewServer(f.sseHandler())
+	defer server.Close()
+
+	client, err := Dial(context.Background(), Server{URL: server.URL + "/sse", Transport: TransportSSE}, testInfo, nil, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	exerciseClient(t, f, client)
+}
+
+func TestDialAutoFallsBackToSSE(t *testing.T) {
+	f := newFakeServer()
+	server := httptest.NewServer(f.sseHandler())
+	defer server.Close()
+
+	// The stream URL rejects POST, so auto mode opens the SSE stream instead
+	client, err := Dial(context.Background(), Server{URL: server.URL + "/sse", Transport: TransportAuto}, testInfo, nil, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	tools, err := client.ListTools(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, tools, 2)
+}
+
+func TestManagerRedialsExpiredSession(t *testing.T) {
+	f := newFakeServer()
+	server := httptest.NewServer(f.streamableHandler())
+	defer server.Close()
+
+	manager := NewManager(testInfo, 5*time.Second)
+	defer manager.Close()
+
+	target := Server{URL: server.URL, Transport: TransportStreamableHTTP}
+	_, err := manager.ListTools(context.Background(), target)
+	require.NoError(t, err)
+
+	f.mu.Lock()
+	f.expireSession = true
+	f.mu.Unlock()
+
+	result, err := manager.CallTool(context.Background(), target, "echo", map[string]interface{}{"n": 1}, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n":1}`, result.Text())
+
+	initializations := 0
+	for _, m := range f.methods() {
+		if m == MethodInitialize {
+			initializations++
+		}
+	}
+	assert.Equal(t, 2, initializations, "expired session is re-initialized")
+}
+
+func TestManagerEvictsUnusedPools(t *testing.T) {
+	f := newFakeServer()
+	server := httptest.NewServer(f.streamableHandler())
+	defer server.Close()
+
+	manager := NewManager(testInfo, 5*time.Second)
+	defer manager.Close()
+	manager.SetPoolLimits(2, 0)
+
+	// Each set of credentials gets its own pool
+	target := func(token string) Server {
+		return Server{URL: server.URL, Transport: TransportStreamableHTTP, Auth: &credentials.Auth{Headers: map[string]string{"Authorization": token}}}
+	}
+	var clients []*Client
+	for _, token := range []string{"a", "b", "c"} {
+		client, err := manager.Client(context.Background(), target(token))
+		require.NoError(t, err)
+		clients = append(clients, client)
+	}
+
+	manager.mu.Lock()
+	assert.Len(t, manager.pools, 2, "the least recently used pool beyond the cap is evicted")
+	manager.mu.Unlock()
+	assert.Eventually(t, func() bool { return clients[0].Err() != nil }, 5*time.Second, 10*time.Millisecond, "the evicted session is closed")
+	assert.NoError(t, clients[2].Err())
+
+	manager.SetPoolLimits(0, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	_, err := manager.Client(context.Background(), target("d"))
+	require.NoError(t, err)
+	manager.mu.Lock()
+	assert.Len(t, manager.pools, 1, "idle pools are evicted")
+	manager.mu.Unlock()
+	assert.Eventually(t, func() bool { return clients[2].Err() != nil }, 5*time.Second, 10*time.Millisecond)
+
+	manager.Close()
+	_, err = manager.Client(context.Background(), target("d"))
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestLegacyRESTTransport(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp/tools/list", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tools":[{"name":"generate_visual","description":"Draw","inputSchema":{"type":"object"}}]}`)
+	})
+	mux.HandleFunc("/mcp/tools/call", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "t1", r.Header.Get("X-Tenant-ID"))
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, strings.Contains(string(body), `"name":"generate_visual"`))
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"done"}],"isError":false}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	manager := NewManager(testInfo, 5*time.Second)
+	defer manager.Close()
+
+	target := Server{URL: server.URL, Transport: TransportLegacyREST}
+	tools, err := manager.ListTools(context.Background(), target)
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "generate_visual", tools[0].Name)
+
+	ctx := WithHeader(context.Background(), "X-Tenant-ID", "t1")
+	result, err := manager.CallTool(ctx, target, "generate_visual", map[string]interface{}{"prompt": "x"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "done", result.Text())
+}
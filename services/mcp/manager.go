@@ -0,0 +1,608 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tas-agent-builder/services/credentials"
+)
+
+// Server identifies an MCP server and how to reach it: a URL and HTTP transport, or a
+// command to launch for TransportStdio. Auth, for HTTP transports, is sent with every request.
+type Server struct {
+	URL       string
+	Transport string
+	Stdio     *StdioCommand
+	Auth      *credentials.Auth
+}
+
+func (s Server) String() string {
+	if s.Transport == TransportStdio && s.Stdio != nil {
+		return s.Stdio.String()
+	}
+	return s.URL
+}
+
+// Key identifies the server for session pooling; it changes when the URL, transport, command
+// or credentials do, so callers with different credentials get separate sessions
+func (s Server) Key() string {
+	if fp := s.Auth.Fingerprint(); fp != "" {
+		return s.EndpointKey() + "|auth:" + fp
+	}
+	return s.EndpointKey()
+}
+
+// EndpointKey identifies the server regardless of credentials, e.g. for caching its tools
+func (s Server) EndpointKey() string {
+	if s.Transport == TransportStdio && s.Stdio != nil {
+		return s.Transport + "|" + s.Stdio.key()
+	}
+	return s.Transport + "|" + strings.TrimSuffix(s.URL, "/")
+}
+
+// StdioLimits bounds the subprocesses a Manager launches for stdio servers. Resource limits
+// are rlimits set by a /bin/sh wrapper before the command is executed; they cap each process
+// but do not isolate it: servers still run as the service's user, with its filesystem and
+// network. Run the service in a sandboxed pod when skills come from untrusted authors.
+type StdioLimits struct {
+	// AllowedCommands lists the executables that may be launched; empty disables stdio
+	AllowedCommands []string
+	// ArgPatterns holds, per allowed command, the pattern every argument must match; see
+	// CompileArgPatterns. Commands without a pattern take no arguments.
+	ArgPatterns map[string]*regexp.Regexp
+	// PoolSize is the number of processes kept per server
+	PoolSize int
+	// MaxConcurrency caps in-flight requests per server across its pool
+	MaxConcurrency int
+	// MaxLifetime recycles a process after this long; 0 keeps it until it exits
+	MaxLifetime time.Duration
+	// MaxMemoryMB caps a process's data segment (RLIMIT_DATA); 0 leaves it unlimited
+	MaxMemoryMB int
+	// MaxCPUSeconds caps a process's CPU time (RLIMIT_CPU); 0 leaves it unlimited
+	MaxCPUSeconds int
+	// MaxOpenFiles caps a process's file descriptors (RLIMIT_NOFILE); 0 leaves it unlimited
+	MaxOpenFiles int
+}
+
+// DefaultStdioLimits returns the limits used until SetStdioLimits is called
+func DefaultStdioLimits() StdioLimits {
+	return StdioLimits{
+		PoolSize:       2,
+		MaxConcurrency: 4,
+		MaxLifetime:    time.Hour,
+		MaxMemoryMB:    1024,
+		MaxCPUSeconds:  600,
+		MaxOpenFiles:   256,
+	}
+}
+
+// ulimitScript returns the shell commands applying the resource limits, or "" if there are none
+func (l StdioLimits) ulimitScript() string {
+	var b strings.Builder
+	for _, limit := range []struct {
+		flag  string
+		value int
+	}{
+		{"-d", l.MaxMemoryMB * 1024},
+		{"-t", l.MaxCPUSeconds},
+		{"-n", l.MaxOpenFiles},
+	} {
+		if limit.value > 0 {
+			fmt.Fprintf(&b, "ulimit %s %d && ", limit.flag, limit.value)
+		}
+	}
+	return b.String()
+}
+
+// Allowed reports whether command may be launched under these limits
+func (l StdioLimits) Allowed(command string) bool {
+	for _, c := range l.AllowedCommands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckArgs reports an error unless every argument matches the command's pattern
+func (l StdioLimits) CheckArgs(command string, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	pattern := l.ArgPatterns[command]
+	if pattern == nil {
+		return fmt.Errorf("stdio command %q takes no arguments", command)
+	}
+	for _, arg := range args {
+		if !pattern.MatchString(arg) {
+			return fmt.Errorf("argument %q is not allowed for %s", arg, command)
+		}
+	}
+	return nil
+}
+
+// CompileArgPatterns compiles per-command argument patterns, anchoring each so it must match a
+// whole argument
+func CompileArgPatterns(patterns map[string]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp, len(patterns))
+	for command, pattern := range patterns {
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument pattern for %s: %w", command, err)
+		}
+		compiled[command] = re
+	}
+	return compiled, nil
+}
+
+// Check reports an error unless command may be launched under these limits
+func (l StdioLimits) Check(command StdioCommand) error {
+	if !l.Allowed(command.Command) {
+		return fmt.Errorf("stdio command %q is not in the allowed commands", command.Command)
+	}
+	if err := l.CheckArgs(command.Command, command.Args); err != nil {
+		return err
+	}
+	return CheckEnv(command.Env)
+}
+
+// Dial connects to an MCP server over its transport and performs the handshake. With
+// TransportAuto, Streamable HTTP is tried first and the legacy SSE transport is used when the
+// server rejects the POST, as the protocol's compatibility guidance suggests. Stdio servers are
+// launched without limits; use a Manager to pool and limit them.
+func Dial(ctx context.Context, server Server, info Implementation, httpClient *http.Client, onNotification func(Notification)) (*Client, error) {
+	return dial(ctx, server, info, httpClient, onNotification, StdioLimits{})
+}
+
+func dial(ctx context.Context, server Server, info Implementation, httpClient *http.Client, onNotification func(Notification), limits StdioLimits) (*Client, error) {
+	connect := func(t Transport) (*Client, error) {
+		client := NewClient(t, info)
+		if onNotification != nil {
+			client.OnNotification(onNotification)
+		}
+		if _, err := client.Initialize(ctx); err != nil {
+			t.Close()
+			return nil, err
+		}
+		return client, nil
+	}
+
+	serverURL := server.URL
+	httpClient = server.Auth.Client(httpClient)
+	switch server.Transport {
+	case TransportStreamableHTTP:
+		return connect(NewStreamableHTTPTransport(serverURL, httpClient, nil))
+	case TransportSSE:
+		return connect(NewSSETransport(serverURL, httpClient, nil))
+	case TransportLegacyREST:
+		return connect(NewLegacyRESTTransport(serverURL, httpClient, nil))
+	case TransportStdio:
+		if server.Stdio == nil {
+			return nil, fmt.Errorf("stdio transport requires a command")
+		}
+		return connect(NewStdioTransport(*server.Stdio, limits))
+	case TransportAuto:
+		client, err := connect(NewStreamableHTTPTransport(serverURL, httpClient, nil))
+		if err == nil {
+			return client, nil
+		}
+		var statusErr *HTTPStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode < 400 || statusErr.StatusCode >= 500 {
+			return nil, err
+		}
+		log.Printf("[MCP] %s rejected Streamable HTTP (HTTP %d), trying SSE", serverURL, statusErr.StatusCode)
+		return connect(NewSSETransport(serverURL, httpClient, nil))
+	default:
+		return nil, fmt.Errorf("unknown MCP transport %q", server.Transport)
+	}
+}
+
+const (
+	// DefaultMaxPools is the number of servers, counting each set of credentials separately,
+	// whose sessions are kept until SetPoolLimits is called
+	DefaultMaxPools = 256
+	// DefaultPoolIdleTimeout is how long an unused server's sessions are kept until
+	// SetPoolLimits is called
+	DefaultPoolIdleTimeout = 10 * time.Minute
+)
+
+// Manager keeps initialized sessions per server and redials sessions that were dropped or
+// whose process exited. HTTP servers get one shared session; stdio servers get a pool of
+// processes with a concurrency cap. Pools unused for the idle timeout, and the least recently
+// used ones beyond the pool cap, are closed. It is safe for concurrent use.
+type Manager struct {
+	info       Implementation
+	timeout    time.Duration
+	httpClient *http.Client
+
+	mu             sync.Mutex
+	stdio          StdioLimits
+	pools          map[string]*pool
+	maxPools       int
+	idleTimeout    time.Duration
+	closed         bool
+	stop           chan struct{}
+	onToolsChanged func(Server)
+}
+
+// pool holds the sessions for one server. sem, when not nil, caps in-flight requests; active
+// counts the requests holding the pool, which is not evicted while any do.
+type pool struct {
+	sem      chan struct{}
+	slots    []*session
+	next     int
+	active   int
+	lastUsed time.Time
+}
+
+// session is a cached client; ready is closed once dialing finishes
+type session struct {
+	ready  chan struct{}
+	client *Client
+	err    error
+}
+
+// NewManager creates a session manager. timeout bounds each list or call whose context has no
+// deadline of its own; 0 means no limit.
+func NewManager(info Implementation, timeout time.Duration) *Manager {
+	m := &Manager{
+		info:        info,
+		timeout:     timeout,
+		httpClient:  &http.Client{},
+		stdio:       DefaultStdioLimits(),
+		pools:       make(map[string]*pool),
+		maxPools:    DefaultMaxPools,
+		idleTimeout: DefaultPoolIdleTimeout,
+		stop:        make(chan struct{}),
+	}
+	go m.evictIdle()
+	return m
+}
+
+// SetPoolLimits caps the number of servers with open sessions and closes the sessions of
+// servers unused for idleTimeout; 0 disables either limit
+func (m *Manager) SetPoolLimits(maxPools int, idleTimeout time.Duration) {
+	m.mu.Lock()
+	m.maxPools = maxPools
+	m.idleTimeout = idleTimeout
+	m.mu.Unlock()
+}
+
+// SetStdioLimits configures stdio servers; it applies to pools created afterwards
+func (m *Manager) SetStdioLimits(limits StdioLimits) {
+	if limits.PoolSize < 1 {
+		limits.PoolSize = 1
+	}
+	m.mu.Lock()
+	m.stdio = limits
+	m.mu.Unlock()
+}
+
+// OnToolsChanged registers a callback for servers reporting that their tool list changed. It
+// must not block.
+func (m *Manager) OnToolsChanged(fn func(Server)) {
+	m.mu.Lock()
+	m.onToolsChanged = fn
+	m.mu.Unlock()
+}
+
+// StdioLimits returns the configured stdio limits
+func (m *Manager) StdioLimits() StdioLimits {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stdio
+}
+
+// acquire returns the pool for a server and reserves a request slot in it. release must be
+// called when the request is done.
+func (m *Manager) acquire(ctx context.Context, server Server) (p *pool, release func(), err error) {
+	if server.Transport == TransportStdio && server.Stdio != nil {
+		if err := m.StdioLimits().Check(*server.Stdio); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	key := server.Key()
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, nil, ErrClosed
+	}
+	p, ok := m.pools[key]
+	if !ok {
+		p = &pool{slots: make([]*session, 1)}
+		if server.Transport == TransportStdio {
+			p.slots = make([]*session, m.stdio.PoolSize)
+			if m.stdio.MaxConcurrency > 0 {
+				p.sem = make(chan struct{}, m.stdio.MaxConcurrency)
+			}
+		}
+		m.pools[key] = p
+	}
+	p.active++
+	p.lastUsed = time.Now()
+	evicted := m.evictLocked(p.lastUsed)
+	m.mu.Unlock()
+	// Stopping processes takes a while; the request need not wait for it
+	go closePools(evicted)
+
+	done := func() {
+		m.mu.Lock()
+		p.active--
+		p.lastUsed = time.Now()
+		m.mu.Unlock()
+	}
+	if p.sem == nil {
+		return p, done, nil
+	}
+	select {
+	case p.sem <- struct{}{}:
+		return p, func() { <-p.sem; done() }, nil
+	case <-ctx.Done():
+		done()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// evictLocked removes the pools unused for the idle timeout and, beyond the pool cap, the
+// least recently used ones, returning them to be closed. Pools in use are kept. m.mu must be
+// held.
+func (m *Manager) evictLocked(now time.Time) []*pool {
+	var idle []string
+	var evicted []*pool
+	for key, p := range m.pools {
+		if p.active > 0 {
+			continue
+		}
+		if m.idleTimeout > 0 && now.Sub(p.lastUsed) > m.idleTimeout {
+			evicted = append(evicted, p)
+			delete(m.pools, key)
+			continue
+		}
+		idle = append(idle, key)
+	}
+	if m.maxPools > 0 && len(m.pools) > m.maxPools {
+		sort.Slice(idle, func(i, j int) bool { return m.pools[idle[i]].lastUsed.Before(m.pools[idle[j]].lastUsed) })
+		for _, key := range idle {
+			if len(m.pools) <= m.maxPools {
+				break
+			}
+			evicted = append(evicted, m.pools[key])
+			delete(m.pools, key)
+		}
+	}
+	if len(evicted) > 0 {
+		log.Printf("[MCP] Closing the sessions of %d unused servers", len(evicted))
+	}
+	return evicted
+}
+
+// evictIdle closes idle pools periodically, so their processes stop even without new requests
+func (m *Manager) evictIdle() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			evicted := m.evictLocked(time.Now())
+			m.mu.Unlock()
+			closePools(evicted)
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Client returns a session for a server, dialing it on first use. Concurrent callers share
+// one dial; pooled servers hand out their sessions in turn. The session is closed once the
+// server goes unused for the idle timeout or is evicted by the pool cap.
+func (m *Manager) Client(ctx context.Context, server Server) (*Client, error) {
+	p, release, err := m.acquire(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return m.client(ctx, server, p)
+}
+
+func (m *Manager) client(ctx context.Context, server Server, p *pool) (*Client, error) {
+	m.mu.Lock()
+	slot := p.next % len(p.slots)
+	p.next++
+	m.mu.Unlock()
+	return m.slotClient(ctx, server, p, slot)
+}
+
+func (m *Manager) slotClient(ctx context.Context, server Server, p *pool, slot int) (*Client, error) {
+	m.mu.Lock()
+	s := p.slots[slot]
+	if s != nil {
+		m.mu.Unlock()
+		select {
+		case <-s.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if s.err == nil && s.client.Err() == nil {
+			return s.client, nil
+		}
+		if s.client != nil && server.Transport == TransportStdio {
+			log.Printf("[MCP] %s is no longer running (%v), restarting", server, s.client.Err())
+		}
+		m.drop(p, s)
+		return m.slotClient(ctx, server, p, slot)
+	}
+	s = &session{ready: make(chan struct{})}
+	p.slots[slot] = s
+	limits := m.stdio
+	m.mu.Unlock()
+
+	// Dial outside the caller's cancellation so a shared session is not torn down by one
+	// caller giving up
+	dialCtx, cancel := m.withTimeout(context.Background())
+	defer cancel()
+	s.client, s.err = dial(dialCtx, server, m.info, m.httpClient, func(n Notification) {
+		m.handleNotification(server, n)
+	}, limits)
+	close(s.ready)
+
+	if s.err != nil {
+		m.drop(p, s)
+		return nil, fmt.Errorf("failed to connect to MCP server %s: %w", server, s.err)
+	}
+	return s.client, nil
+}
+
+// drop removes a session from its pool if it is still cached there
+func (m *Manager) drop(p *pool, s *session) {
+	m.mu.Lock()
+	for i := range p.slots {
+		if p.slots[i] == s {
+			p.slots[i] = nil
+		}
+	}
+	m.mu.Unlock()
+	if s.client != nil {
+		s.client.Close()
+	}
+}
+
+func (m *Manager) handleNotification(server Server, n Notification) {
+	switch n.Method {
+	case NotificationMessage:
+		var params struct {
+			Level string          `json:"level"`
+			Data  json.RawMessage `json:"data"`
+		}
+		json.Unmarshal(n.Params, &params)
+		log.Printf("[MCP] %s [%s]: %s", server, params.Level, string(params.Data))
+	case NotificationToolsListChanged:
+		log.Printf("[MCP] %s reported a tool list change", server)
+		m.mu.Lock()
+		fn := m.onToolsChanged
+		m.mu.Unlock()
+		if fn != nil {
+			fn(server)
+		}
+	}
+}
+
+// ListTools lists a server's tools, redialing once if the session was lost
+func (m *Manager) ListTools(ctx context.Context, server Server) ([]Tool, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	var tools []Tool
+	err := m.withSession(ctx, server, IsConnectionError, func(c *Client) error {
+		var err error
+		tools, err = c.ListTools(ctx)
+		return err
+	})
+	return tools, err
+}
+
+// CallTool invokes a tool, redialing once if the server had expired the session. Calls that
+// may have reached the server are not repeated.
+func (m *Manager) CallTool(ctx context.Context, server Server, name string, args map[string]interface{}, onProgress func(Progress)) (*CallToolResult, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	expired := func(err error) bool { return errors.Is(err, ErrSessionExpired) }
+
+	var result *CallToolResult
+	err := m.withSession(ctx, server, expired, func(c *Client) error {
+		var err error
+		result, err = c.CallTool(ctx, name, args, onProgress)
+		return err
+	})
+	return result, err
+}
+
+func (m *Manager) withSession(ctx context.Context, server Server, retryable func(error) bool, fn func(*Client) error) error {
+	p, release, err := m.acquire(ctx, server)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for attempt := 0; ; attempt++ {
+		client, err := m.client(ctx, server, p)
+		if err != nil {
+			return err
+		}
+		err = fn(client)
+		if err == nil || !retryable(err) || attempt > 0 {
+			return err
+		}
+		log.Printf("[MCP] Session with %s lost (%v), reconnecting", server, err)
+		m.mu.Lock()
+		var lost *session
+		for _, s := range p.slots {
+			if s != nil && s.client == client {
+				lost = s
+			}
+		}
+		m.mu.Unlock()
+		if lost != nil {
+			m.drop(p, lost)
+		}
+	}
+}
+
+func (m *Manager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || m.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.timeout)
+}
+
+// Close ends every session and stops stdio servers
+func (m *Manager) Close() {
+	m.mu.Lock()
+	pools := make([]*pool, 0, len(m.pools))
+	for _, p := range m.pools {
+		pools = append(pools, p)
+	}
+	m.pools = make(map[string]*pool)
+	if !m.closed {
+		close(m.stop)
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	closePools(pools)
+}
+
+// closePools ends the sessions of pools no longer in the manager, waiting for pending dials
+func closePools(pools []*pool) {
+	if len(pools) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, p := range pools {
+		for _, s := range p.slots {
+			if s == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(s *session) {
+				defer wg.Done()
+				<-s.ready
+				if s.client != nil {
+					s.client.Close()
+				}
+			}(s)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,235 @@
+// Package mcp implements a Model Context Protocol client: the JSON-RPC 2.0 message layer, the
+// initialize handshake, tool listing and invocation with progress and cancellation, and the
+// Streamable HTTP, legacy HTTP+SSE and stdio transports.
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Protocol revisions this client can speak, newest first
+const (
+	ProtocolVersion20250326 = "2025-03-26"
+	ProtocolVersion20241105 = "2024-11-05"
+
+	// LatestProtocolVersion is offered in the initialize request
+	LatestProtocolVersion = ProtocolVersion20250326
+)
+
+// SupportedProtocolVersions lists the revisions accepted from a server
+var SupportedProtocolVersions = []string{ProtocolVersion20250326, ProtocolVersion20241105}
+
+// JSON-RPC method names
+const (
+	MethodInitialize             = "initialize"
+	MethodPing                   = "ping"
+	MethodToolsList              = "tools/list"
+	MethodToolsCall              = "tools/call"
+	NotificationInitialized      = "notifications/initialized"
+	NotificationCancelled        = "notifications/cancelled"
+	NotificationProgress         = "notifications/progress"
+	NotificationMessage          = "notifications/message"
+	NotificationToolsListChanged = "notifications/tools/list_changed"
+)
+
+// Standard JSON-RPC error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+const jsonRPCVersion = "2.0"
+
+// Request is a JSON-RPC request; ID is omitted for notifications
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  interface{}     `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response carrying either a result or an error
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is a JSON-RPC error object; it is returned as the error of failed calls
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// Notification is a server-to-client notification
+type Notification struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// message is the union of all JSON-RPC shapes, used to classify incoming traffic
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// Implementation names a client or server
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ClientCapabilities is sent in the initialize request. This client does not offer roots or
+// sampling to servers.
+type ClientCapabilities struct {
+	Experimental map[string]interface{} `json:"experimental,omitempty"`
+}
+
+// ServerCapabilities is what the server advertised during initialize
+type ServerCapabilities struct {
+	Tools        *ListChangedCapability `json:"tools,omitempty"`
+	Resources    *ResourcesCapability   `json:"resources,omitempty"`
+	Prompts      *ListChangedCapability `json:"prompts,omitempty"`
+	Logging      map[string]interface{} `json:"logging,omitempty"`
+	Experimental map[string]interface{} `json:"experimental,omitempty"`
+}
+
+// ListChangedCapability marks a feature whose list can change during the session
+type ListChangedCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ResourcesCapability describes the server's resource support
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// InitializeParams is the body of the initialize request
+type InitializeParams struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      Implementation     `json:"clientInfo"`
+}
+
+// InitializeResult is the server's answer to initialize
+type InitializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	ServerInfo      Implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+// Tool is a tool definition from tools/list
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+}
+
+// ListToolsResult is one page of tools/list
+type ListToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Content is one item of a tool result
+type Content struct {
+	Type     string           `json:"type"` // "text", "image", "audio" or "resource"
+	Text     string           `json:"text,omitempty"`
+	Data     string           `json:"data,omitempty"` // Base64 for image and audio
+	MimeType string           `json:"mimeType,omitempty"`
+	Resource *ResourceContent `json:"resource,omitempty"`
+}
+
+// ResourceContent is an embedded resource in a tool result
+type ResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// CallToolResult is the result of tools/call
+type CallToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// Text flattens the result to text: text items and embedded text resources are joined with
+// newlines and binary items are replaced by a short placeholder
+func (r *CallToolResult) Text() string {
+	var text string
+	add := func(s string) {
+		if text != "" {
+			text += "\n"
+		}
+		text += s
+	}
+
+	for _, c := range r.Content {
+		switch c.Type {
+		case "text":
+			add(c.Text)
+		case "resource":
+			if c.Resource != nil && c.Resource.Text != "" {
+				add(c.Resource.Text)
+			} else if c.Resource != nil {
+				add(fmt.Sprintf("[resource: %s]", c.Resource.URI))
+			}
+		default:
+			add(fmt.Sprintf("[%s: %s]", c.Type, c.MimeType))
+		}
+	}
+
+	if text == "" && r.StructuredContent != nil {
+		if b, err := json.Marshal(r.StructuredContent); err == nil {
+			text = string(b)
+		}
+	}
+	return text
+}
+
+// Progress is a notifications/progress update for a running call
+type Progress struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+// callToolParams is the body of tools/call
+type callToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *requestMeta           `json:"_meta,omitempty"`
+}
+
+type requestMeta struct {
+	ProgressToken string `json:"progressToken,omitempty"`
+}
+
+type listToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type cancelledParams struct {
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    string          `json:"reason,omitempty"`
+}
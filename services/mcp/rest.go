@@ -0,0 +1,153 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// legacyRESTTransport speaks to the in-house MCP shims that expose GET /mcp/tools/list and
+// POST /mcp/tools/call instead of JSON-RPC. It answers the handshake locally so the client
+// treats these servers like any other.
+type legacyRESTTransport struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    map[string]string
+
+	mu        sync.Mutex
+	onMessage func(json.RawMessage)
+	closed    bool
+}
+
+// NewLegacyRESTTransport creates a transport for an in-house REST shim at baseURL
+func NewLegacyRESTTransport(baseURL string, httpClient *http.Client, headers map[string]string) Transport {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &legacyRESTTransport{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+		headers:    headers,
+	}
+}
+
+func (t *legacyRESTTransport) Start(ctx context.Context, onMessage func(json.RawMessage), onClose func(error)) error {
+	t.mu.Lock()
+	t.onMessage = onMessage
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *legacyRESTTransport) Send(ctx context.Context, msg json.RawMessage) error {
+	t.mu.Lock()
+	onMessage, closed := t.onMessage, t.closed
+	t.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	var req message
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return fmt.Errorf("failed to parse outgoing message: %w", err)
+	}
+	if req.ID == nil {
+		// Notifications and responses have nothing to map to
+		return nil
+	}
+
+	var result interface{}
+	var rpcErr *RPCError
+	switch req.Method {
+	case MethodInitialize:
+		result = InitializeResult{
+			ProtocolVersion: LatestProtocolVersion,
+			Capabilities:    ServerCapabilities{Tools: &ListChangedCapability{}},
+			ServerInfo:      Implementation{Name: "legacy-rest", Version: "0"},
+		}
+	case MethodPing:
+		result = struct{}{}
+	case MethodToolsList:
+		var tools ListToolsResult
+		if err := t.do(ctx, "GET", "/mcp/tools/list", nil, &tools); err != nil {
+			return err
+		}
+		result = tools
+	case MethodToolsCall:
+		var params callToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rpcErr = &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+			break
+		}
+		var callResult CallToolResult
+		body := map[string]interface{}{"name": params.Name, "arguments": params.Arguments}
+		if err := t.do(ctx, "POST", "/mcp/tools/call", body, &callResult); err != nil {
+			return err
+		}
+		result = callResult
+	default:
+		rpcErr = &RPCError{Code: CodeMethodNotFound, Message: "method not supported by REST shim: " + req.Method}
+	}
+
+	resp := Response{JSONRPC: jsonRPCVersion, ID: req.ID, Error: rpcErr}
+	if rpcErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode shim result: %w", err)
+		}
+		resp.Result = raw
+	}
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode shim response: %w", err)
+	}
+	if onMessage != nil {
+		onMessage(encoded)
+	}
+	return nil
+}
+
+func (t *legacyRESTTransport) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, t.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	applyHeaders(ctx, req, t.headers)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newHTTPStatusError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response from %s: %w", path, err)
+	}
+	return nil
+}
+
+func (t *legacyRESTTransport) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// ProtocolVersion20250618 added structured tool output; only the server speaks it so far
+const ProtocolVersion20250618 = "2025-06-18"
+
+// serverProtocolVersions lists the revisions the server accepts from clients, newest first
+var serverProtocolVersions = []string{ProtocolVersion20250618, ProtocolVersion20250326, ProtocolVersion20241105}
+
+// maxServerRequestBytes bounds the body of one POST to an Endpoint
+const maxServerRequestBytes = 4 << 20
+
+// ToolProvider supplies the tools an Endpoint offers to the caller of one request
+type ToolProvider interface {
+	// ListTools returns the page of tools starting at cursor, which is "" for the first page
+	ListTools(ctx context.Context, cursor string) (*ListToolsResult, error)
+	// CallTool runs a tool. Failures of the tool itself belong in the result with IsError set;
+	// errors are for calls that could not be made, and an *RPCError is sent to the client as is.
+	CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error)
+}
+
+// Endpoint answers MCP clients over the Streamable HTTP transport. It keeps no sessions: each
+// POST is handled on its own and answered with JSON, and no server-initiated stream is offered.
+type Endpoint struct {
+	info         Implementation
+	instructions string
+}
+
+// NewEndpoint creates an endpoint that introduces itself with info and instructions
+func NewEndpoint(info Implementation, instructions string) *Endpoint {
+	return &Endpoint{info: info, instructions: instructions}
+}
+
+// Serve handles one HTTP request to the endpoint with the caller's tools
+func (s *Endpoint) Serve(w http.ResponseWriter, r *http.Request, tools ToolProvider) {
+	if r.Method != http.MethodPost {
+		// Without sessions there is no stream to open with GET or session to end with DELETE
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxServerRequestBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxServerRequestBytes {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	raw, err := splitBatch(body)
+	if err != nil || len(raw) == 0 {
+		writeServerJSON(w, http.StatusBadRequest, errorResponse(nil, CodeParseError, "invalid JSON-RPC message"))
+		return
+	}
+
+	var responses []Response
+	for _, m := range raw {
+		var msg message
+		code := 0
+		if err := json.Unmarshal(m, &msg); err != nil {
+			code = CodeParseError
+		} else if msg.JSONRPC != jsonRPCVersion {
+			code = CodeInvalidRequest
+		}
+		if code != 0 {
+			if len(raw) == 1 {
+				writeServerJSON(w, http.StatusBadRequest, errorResponse(nil, code, "invalid JSON-RPC message"))
+				return
+			}
+			responses = append(responses, errorResponse(nil, code, "invalid JSON-RPC message"))
+			continue
+		}
+		if msg.ID == nil || msg.Method == "" {
+			// Notifications and responses to requests the server never sends need no answer
+			continue
+		}
+		responses = append(responses, s.handle(r.Context(), msg, tools))
+	}
+
+	switch {
+	case len(responses) == 0:
+		w.WriteHeader(http.StatusAccepted)
+	case len(raw) == 1:
+		writeServerJSON(w, http.StatusOK, responses[0])
+	default:
+		writeServerJSON(w, http.StatusOK, responses)
+	}
+}
+
+// handle answers one request
+func (s *Endpoint) handle(ctx context.Context, msg message, tools ToolProvider) Response {
+	var result interface{}
+	var err error
+
+	switch msg.Method {
+	case MethodInitialize:
+		var params InitializeParams
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return errorResponse(msg.ID, CodeInvalidParams, err.Error())
+		}
+		result = s.initialize(params)
+	case MethodPing:
+		result = struct{}{}
+	case MethodToolsList:
+		var params listToolsParams
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return errorResponse(msg.ID, CodeInvalidParams, err.Error())
+		}
+		result, err = tools.ListTools(ctx, params.Cursor)
+	case MethodToolsCall:
+		var params callToolParams
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return errorResponse(msg.ID, CodeInvalidParams, err.Error())
+		}
+		if params.Name == "" {
+			return errorResponse(msg.ID, CodeInvalidParams, "tool name is required")
+		}
+		result, err = tools.CallTool(ctx, params.Name, params.Arguments)
+	default:
+		return errorResponse(msg.ID, CodeMethodNotFound, fmt.Sprintf("method %q is not supported", msg.Method))
+	}
+
+	if err != nil {
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			return Response{JSONRPC: jsonRPCVersion, ID: msg.ID, Error: rpcErr}
+		}
+		log.Printf("[MCP-SERVER] %s failed: %v", msg.Method, err)
+		return errorResponse(msg.ID, CodeInternalError, "internal error")
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(msg.ID, CodeInternalError, "failed to encode result")
+	}
+	return Response{JSONRPC: jsonRPCVersion, ID: msg.ID, Result: encoded}
+}
+
+// initialize agrees on the client's protocol revision if the server speaks it, and otherwise
+// offers the newest one
+func (s *Endpoint) initialize(params InitializeParams) InitializeResult {
+	version := serverProtocolVersions[0]
+	for _, v := range serverProtocolVersions {
+		if v == params.ProtocolVersion {
+			version = v
+			break
+		}
+	}
+	return InitializeResult{
+		ProtocolVersion: version,
+		Capabilities:    ServerCapabilities{Tools: &ListChangedCapability{}},
+		ServerInfo:      s.info,
+		Instructions:    s.instructions,
+	}
+}
+
+func decodeParams(raw json.RawMessage, out interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+func errorResponse(id json.RawMessage, code int, message string) Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return Response{JSONRPC: jsonRPCVersion, ID: id, Error: &RPCError{Code: code, Message: message}}
+}
+
+func writeServerJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedTools offers "tool_0" … "tool_<n-1>" two per page and echoes call arguments
+type pagedTools struct {
+	n int
+}
+
+func (p pagedTools) ListTools(ctx context.Context, cursor string) (*ListToolsResult, error) {
+	start := 0
+	if cursor != "" {
+		start, _ = strconv.Atoi(cursor)
+	}
+	result := &ListToolsResult{Tools: []Tool{}}
+	for i := start; i < p.n && i < start+2; i++ {
+		result.Tools = append(result.Tools, Tool{Name: fmt.Sprintf("tool_%d", i), InputSchema: map[string]interface{}{"type": "object"}})
+	}
+	if start+2 < p.n {
+		result.NextCursor = strconv.Itoa(start + 2)
+	}
+	return result, nil
+}
+
+func (p pagedTools) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	switch name {
+	case "unknown":
+		return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown tool"}
+	case "broken":
+		return nil, fmt.Errorf("database is down")
+	}
+	return &CallToolResult{Content: []Content{{Type: "text", Text: fmt.Sprint(args["input"])}}, StructuredContent: args}, nil
+}
+
+func TestEndpoint(t *testing.T) {
+	endpoint := NewEndpoint(Implementation{Name: "agents", Version: "1.0.0"}, "Ask the agents")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint.Serve(w, r, pagedTools{n: 3})
+	}))
+	defer server.Close()
+
+	client := NewClient(NewStreamableHTTPTransport(server.URL, server.Client(), nil), testInfo)
+	defer client.Close()
+
+	ctx := context.Background()
+	init, err := client.Initialize(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, LatestProtocolVersion, init.ProtocolVersion)
+	assert.Equal(t, "agents", init.ServerInfo.Name)
+	assert.Equal(t, "Ask the agents", init.Instructions)
+	require.NoError(t, client.Ping(ctx))
+
+	tools, err := client.ListTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 3)
+	assert.Equal(t, "tool_2", tools[2].Name)
+
+	result, err := client.CallTool(ctx, "tool_0", map[string]interface{}{"input": "hello"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", result.Text())
+	assert.Equal(t, map[string]interface{}{"input": "hello"}, result.StructuredContent)
+
+	_, err = client.CallTool(ctx, "unknown", nil, nil)
+	assert.ErrorContains(t, err, "unknown tool")
+	_, err = client.CallTool(ctx, "broken", nil, nil)
+	assert.ErrorContains(t, err, "internal error")
+	assert.NotContains(t, err.Error(), "database")
+
+	post := func(body string) (*http.Response, string) {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(data)
+	}
+
+	resp, body := post(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"protocolVersion":"2025-06-18"`)
+
+	resp, _ = post(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	_, body = post(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"resources/list"}]`)
+	assert.Contains(t, body, `"id":1,"result":{}`)
+	assert.Contains(t, body, fmt.Sprintf(`"code":%d`, CodeMethodNotFound))
+
+	resp, _ = post(`not json`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
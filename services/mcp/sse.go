@@ -0,0 +1,175 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// sseTransport implements the legacy HTTP+SSE transport: a long-lived GET stream carries
+// server messages and announces the endpoint that client messages are POSTed to
+type sseTransport struct {
+	streamURL  string
+	httpClient *http.Client
+	headers    map[string]string
+
+	mu       sync.Mutex
+	endpoint string
+	cancel   context.CancelFunc
+	done     chan struct{}
+	closed   bool
+}
+
+// NewSSETransport creates an HTTP+SSE transport for the stream at streamURL (usually .../sse)
+func NewSSETransport(streamURL string, httpClient *http.Client, headers map[string]string) Transport {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &sseTransport{
+		streamURL:  streamURL,
+		httpClient: httpClient,
+		headers:    headers,
+	}
+}
+
+// Start opens the event stream and waits for the endpoint event
+func (t *sseTransport) Start(ctx context.Context, onMessage func(json.RawMessage), onClose func(error)) error {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	req, err := http.NewRequestWithContext(streamCtx, "GET", t.streamURL, nil)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to create SSE request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	applyHeaders(ctx, req, t.headers)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to open SSE stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		statusErr := newHTTPStatusError(resp)
+		resp.Body.Close()
+		cancel()
+		return statusErr
+	}
+
+	endpointCh := make(chan string, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer resp.Body.Close()
+		err := readSSE(resp.Body, func(ev sseEvent) bool {
+			switch ev.Event {
+			case "endpoint":
+				select {
+				case endpointCh <- ev.Data:
+				default:
+				}
+			case "message":
+				onMessage(json.RawMessage(ev.Data))
+			}
+			return true
+		})
+
+		t.mu.Lock()
+		closed := t.closed
+		t.mu.Unlock()
+		if closed {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("SSE stream ended")
+		}
+		if onClose != nil {
+			onClose(err)
+		}
+	}()
+
+	select {
+	case endpoint := <-endpointCh:
+		resolved, err := resolveEndpoint(t.streamURL, endpoint)
+		if err != nil {
+			cancel()
+			return err
+		}
+		t.mu.Lock()
+		t.endpoint = resolved
+		t.cancel = cancel
+		t.done = done
+		t.mu.Unlock()
+		stop()
+		return nil
+	case <-done:
+		cancel()
+		return fmt.Errorf("SSE stream closed before endpoint event")
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	}
+}
+
+// resolveEndpoint resolves the announced endpoint against the stream URL
+func resolveEndpoint(streamURL, endpoint string) (string, error) {
+	base, err := url.Parse(streamURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSE URL: %w", err)
+	}
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint event %q: %w", endpoint, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+func (t *sseTransport) Send(ctx context.Context, msg json.RawMessage) error {
+	t.mu.Lock()
+	endpoint, closed := t.endpoint, t.closed
+	t.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return fmt.Errorf("failed to create MCP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	applyHeaders(ctx, req, t.headers)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send MCP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newHTTPStatusError(resp)
+	}
+	return nil
+}
+
+func (t *sseTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	cancel, done := t.cancel, t.done
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxStdioMessageSize bounds one newline-delimited message from a stdio server
+	maxStdioMessageSize = 16 * 1024 * 1024
+
+	// stdioStopGrace is how long a server gets to exit after stdin closes and it is
+	// interrupted, before it is killed
+	stdioStopGrace = 5 * time.Second
+
+	// maxStderrLine bounds one logged line of a stdio server's stderr; the rest is dropped
+	maxStderrLine = 4096
+)
+
+// inheritedEnv lists the service's environment variables passed to stdio servers. The rest of
+// the service environment holds database and provider credentials and is not shared.
+var inheritedEnv = []string{"PATH", "HOME", "LANG", "TMPDIR"}
+
+// reservedEnv and reservedEnvPrefixes name variables a skill may not set: the inherited ones,
+// and those that make the dynamic loader or an interpreter run code of the caller's choosing
+var (
+	reservedEnv         = []string{"NODE_OPTIONS", "NODE_PATH", "BASH_ENV", "ENV", "PERL5OPT", "RUBYOPT"}
+	reservedEnvPrefixes = []string{"LD_", "DYLD_", "PYTHON"}
+)
+
+// envName matches portable environment variable names
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CheckEnv rejects environment variables a stdio skill may not set
+func CheckEnv(env map[string]string) error {
+	for k := range env {
+		if !envName.MatchString(k) {
+			return fmt.Errorf("%q is not a valid environment variable name", k)
+		}
+		upper := strings.ToUpper(k)
+		for _, reserved := range append(inheritedEnv, reservedEnv...) {
+			if upper == reserved {
+				return fmt.Errorf("environment variable %s may not be set", k)
+			}
+		}
+		for _, prefix := range reservedEnvPrefixes {
+			if strings.HasPrefix(upper, prefix) {
+				return fmt.Errorf("environment variables starting with %s may not be set", prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// StdioCommand describes a locally launched MCP server
+type StdioCommand struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// String renders the command line for logs; env values are left out as they often hold keys
+func (c StdioCommand) String() string {
+	return strings.TrimSpace(c.Command + " " + strings.Join(c.Args, " "))
+}
+
+// key identifies the command for session pooling
+func (c StdioCommand) key() string {
+	envKeys := make([]string, 0, len(c.Env))
+	for k := range c.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+
+	var b strings.Builder
+	b.WriteString(c.Command)
+	for _, a := range c.Args {
+		b.WriteString("\x00" + a)
+	}
+	for _, k := range envKeys {
+		b.WriteString("\x00" + k + "=" + c.Env[k])
+	}
+	return b.String()
+}
+
+// environ builds the subprocess environment
+func (c StdioCommand) environ() []string {
+	var env []string
+	for _, k := range inheritedEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	for k, v := range c.Env {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+// stdioTransport runs an MCP server as a subprocess and exchanges newline-delimited JSON-RPC
+// messages over its stdin and stdout. Stderr is logged.
+type stdioTransport struct {
+	command StdioCommand
+	limits  StdioLimits
+
+	writeMu sync.Mutex
+	stdin   io.WriteCloser
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewStdioTransport creates a transport that launches command on Start under the resource
+// limits. A positive MaxLifetime stops the process after that long so long-running servers
+// are recycled; the allowlist and pool settings are the Manager's and are not checked here.
+func NewStdioTransport(command StdioCommand, limits StdioLimits) Transport {
+	return &stdioTransport{command: command, limits: limits}
+}
+
+func (t *stdioTransport) Start(ctx context.Context, onMessage func(json.RawMessage), onClose func(error)) error {
+	if t.command.Command == "" {
+		return fmt.Errorf("stdio transport requires a command")
+	}
+	if err := CheckEnv(t.command.Env); err != nil {
+		return err
+	}
+
+	// The process outlives the Start call, so it gets its own context
+	var procCtx context.Context
+	var cancel context.CancelFunc
+	if t.limits.MaxLifetime > 0 {
+		procCtx, cancel = context.WithTimeout(context.Background(), t.limits.MaxLifetime)
+	} else {
+		procCtx, cancel = context.WithCancel(context.Background())
+	}
+
+	// The wrapper shell sets the rlimits, then execs the command in its place so the limits
+	// apply to it and signals reach it directly
+	name, args := t.command.Command, t.command.Args
+	if script := t.limits.ulimitScript(); script != "" {
+		name = "/bin/sh"
+		args = append([]string{"-c", script + `exec "$0" "$@"`, t.command.Command}, t.command.Args...)
+	}
+
+	cmd := exec.CommandContext(procCtx, name, args...)
+	cmd.Env = t.command.environ()
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = stdioStopGrace
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to open stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to open stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to open stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("failed to start %s: %w", t.command, err)
+	}
+	log.Printf("[MCP] Started stdio server %s (pid %d)", t.command, cmd.Process.Pid)
+
+	t.stdin = stdin
+	t.cancel = cancel
+	t.done = make(chan struct{})
+
+	// stderr is drained whatever it holds: a server blocked writing to a full pipe would hang
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		logger := &stderrLogger{command: t.command.Command}
+		io.Copy(logger, stderr)
+		logger.flush()
+	}()
+
+	go func() {
+		defer close(t.done)
+		defer cancel()
+
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxStdioMessageSize)
+		for scanner.Scan() {
+			msgs, err := splitBatch(scanner.Bytes())
+			if err != nil {
+				log.Printf("[MCP] %s wrote invalid JSON: %v", t.command.Command, err)
+				continue
+			}
+			for _, m := range msgs {
+				// The scanner reuses its buffer
+				onMessage(append(json.RawMessage(nil), m...))
+			}
+		}
+		readErr := scanner.Err()
+		if readErr != nil {
+			// Nothing reads stdout any more, so a server still running would never be waited
+			// for: stop it
+			cancel()
+		}
+
+		// Wait closes the pipes, so it must not run while stderr is still being read
+		<-stderrDone
+		waitErr := cmd.Wait()
+		if readErr != nil {
+			waitErr = fmt.Errorf("failed to read stdout: %w", readErr)
+		} else if waitErr == nil {
+			waitErr = io.EOF
+		}
+		onClose(fmt.Errorf("stdio server %s exited: %w", t.command.Command, waitErr))
+	}()
+
+	return nil
+}
+
+// stderrLogger logs a stdio server's stderr line by line, cutting lines at maxStderrLine
+type stderrLogger struct {
+	command   string
+	line      []byte
+	truncated bool
+}
+
+func (w *stderrLogger) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n')
+		chunk := p
+		if end >= 0 {
+			chunk = p[:end]
+		}
+		if room := maxStderrLine - len(w.line); len(chunk) > room {
+			chunk = chunk[:room]
+			w.truncated = true
+		}
+		w.line = append(w.line, chunk...)
+		if end < 0 {
+			break
+		}
+		w.flush()
+		p = p[end+1:]
+	}
+	return n, nil
+}
+
+// flush logs the line collected so far, if any
+func (w *stderrLogger) flush() {
+	if len(w.line) == 0 && !w.truncated {
+		return
+	}
+	line := string(bytes.TrimSuffix(w.line, []byte("\r")))
+	if w.truncated {
+		line += " [truncated]"
+	}
+	log.Printf("[MCP] %s stderr: %s", w.command, line)
+	w.line = w.line[:0]
+	w.truncated = false
+}
+
+func (t *stdioTransport) Send(ctx context.Context, msg json.RawMessage) error {
+	if t.done == nil {
+		return ErrClosed
+	}
+	select {
+	case <-t.done:
+		return ErrClosed
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Messages are delimited by newlines, so they must not contain any
+	var line bytes.Buffer
+	if err := json.Compact(&line, msg); err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	line.WriteByte('\n')
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(line.Bytes()); err != nil {
+		return fmt.Errorf("%w: failed to write to %s: %v", ErrClosed, t.command.Command, err)
+	}
+	return nil
+}
+
+// Close closes stdin, which asks the server to exit, then interrupts and finally kills it
+func (t *stdioTransport) Close() error {
+	if t.done == nil {
+		return nil
+	}
+
+	t.writeMu.Lock()
+	t.stdin.Close()
+	t.writeMu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-time.After(stdioStopGrace):
+	}
+	t.cancel()
+	<-t.done
+	return nil
+}
@@ -0,0 +1,210 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStdioHelperProcess is not a real test: it is the fake MCP server launched by the stdio
+// tests, re-executing the test binary. The "crash" tool makes it exit.
+func TestStdioHelperProcess(t *testing.T) {
+	switch os.Getenv("MCP_STDIO_HELPER") {
+	case "1":
+	case "rlimits":
+		var cpu, files syscall.Rlimit
+		syscall.Getrlimit(syscall.RLIMIT_CPU, &cpu)
+		syscall.Getrlimit(syscall.RLIMIT_NOFILE, &files)
+		fmt.Fprintf(os.Stdout, `{"cpu":%d,"files":%d}`+"\n", cpu.Cur, files.Cur)
+		os.Exit(0)
+	case "noisy":
+		// One line far beyond a scanner's default limit, then more than a pipe buffer holds
+		line := make([]byte, 1024*1024)
+		for i := range line {
+			line[i] = 'x'
+		}
+		os.Stderr.Write(append(line, '\n'))
+		os.Stderr.Write(line)
+		fmt.Fprintln(os.Stdout, `{"done":true}`)
+		os.Exit(0)
+	case "oversized":
+		os.Stdout.Write(make([]byte, maxStdioMessageSize+1))
+		time.Sleep(time.Hour)
+		os.Exit(0)
+	default:
+		return
+	}
+
+	f := newFakeServer()
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Method == MethodToolsCall {
+			var p callToolParams
+			json.Unmarshal(msg.Params, &p)
+			if p.Name == "crash" {
+				os.Exit(3)
+			}
+		}
+		for _, out := range f.handle(msg) {
+			data, _ := json.Marshal(out)
+			fmt.Fprintf(os.Stdout, "%s\n", data)
+		}
+	}
+	os.Exit(0)
+}
+
+func helperServer() Server {
+	return Server{
+		Transport: TransportStdio,
+		Stdio: &StdioCommand{
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestStdioHelperProcess"},
+			Env:     map[string]string{"MCP_STDIO_HELPER": "1"},
+		},
+	}
+}
+
+func TestStdioManagerRestartsCrashedServer(t *testing.T) {
+	manager := NewManager(testInfo, 10*time.Second)
+	patterns, err := CompileArgPatterns(map[string]string{os.Args[0]: `-test\.run=\w+`})
+	require.NoError(t, err)
+	manager.SetStdioLimits(StdioLimits{AllowedCommands: []string{os.Args[0]}, ArgPatterns: patterns, PoolSize: 1, MaxConcurrency: 2})
+	defer manager.Close()
+
+	server := helperServer()
+	ctx := context.Background()
+
+	tools, err := manager.ListTools(ctx, server)
+	require.NoError(t, err)
+	assert.Len(t, tools, 2)
+
+	result, err := manager.CallTool(ctx, server, "echo", map[string]interface{}{"q": "hi"}, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"q":"hi"}`, result.Text())
+
+	_, err = manager.CallTool(ctx, server, "crash", nil, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	result, err = manager.CallTool(ctx, server, "echo", map[string]interface{}{"q": "again"}, nil)
+	require.NoError(t, err, "the crashed process is restarted")
+	assert.JSONEq(t, `{"q":"again"}`, result.Text())
+}
+
+func TestStdioCommandMustBeAllowed(t *testing.T) {
+	manager := NewManager(testInfo, 10*time.Second)
+	defer manager.Close()
+
+	_, err := manager.ListTools(context.Background(), helperServer())
+	assert.ErrorContains(t, err, "not in the allowed commands")
+}
+
+func TestStdioLimitsCheck(t *testing.T) {
+	patterns, err := CompileArgPatterns(map[string]string{"npx": `-y|@modelcontextprotocol/server-[a-z-]+`})
+	require.NoError(t, err)
+	limits := StdioLimits{AllowedCommands: []string{"npx", "mcp-server"}, ArgPatterns: patterns}
+
+	assert.NoError(t, limits.Check(StdioCommand{Command: "npx", Args: []string{"-y", "@modelcontextprotocol/server-github"}, Env: map[string]string{"GITHUB_TOKEN": "t"}}))
+	assert.NoError(t, limits.Check(StdioCommand{Command: "mcp-server"}))
+
+	assert.ErrorContains(t, limits.Check(StdioCommand{Command: "npx", Args: []string{"-y", "evil-package"}}), "not allowed")
+	assert.ErrorContains(t, limits.Check(StdioCommand{Command: "npx", Args: []string{"-y@modelcontextprotocol/server-x"}}), "not allowed", "patterns match whole arguments")
+	assert.ErrorContains(t, limits.Check(StdioCommand{Command: "mcp-server", Args: []string{"--debug"}}), "takes no arguments")
+
+	for _, key := range []string{"LD_PRELOAD", "ld_library_path", "DYLD_INSERT_LIBRARIES", "NODE_OPTIONS", "PYTHONSTARTUP", "PATH", "HOME", "TMPDIR", "BAD=KEY"} {
+		assert.Error(t, limits.Check(StdioCommand{Command: "mcp-server", Env: map[string]string{key: "x"}}), key)
+	}
+
+	_, err = CompileArgPatterns(map[string]string{"npx": "("})
+	assert.Error(t, err)
+}
+
+func TestStdioResourceLimits(t *testing.T) {
+	command := StdioCommand{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestStdioHelperProcess"},
+		Env:     map[string]string{"MCP_STDIO_HELPER": "rlimits"},
+	}
+	transport := NewStdioTransport(command, StdioLimits{MaxMemoryMB: 4096, MaxCPUSeconds: 30, MaxOpenFiles: 64})
+
+	messages := make(chan json.RawMessage, 1)
+	require.NoError(t, transport.Start(context.Background(), func(m json.RawMessage) { messages <- m }, func(error) {}))
+	defer transport.Close()
+
+	select {
+	case m := <-messages:
+		assert.JSONEq(t, `{"cpu":30,"files":64}`, string(m))
+	case <-time.After(10 * time.Second):
+		t.Fatal("the helper did not report its limits")
+	}
+}
+
+func TestStdioServerStoppedWhenOutputCannotBeRead(t *testing.T) {
+	command := StdioCommand{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestStdioHelperProcess"},
+		Env:     map[string]string{"MCP_STDIO_HELPER": "oversized"},
+	}
+	transport := NewStdioTransport(command, StdioLimits{})
+
+	closed := make(chan error, 1)
+	require.NoError(t, transport.Start(context.Background(), func(json.RawMessage) {}, func(err error) { closed <- err }))
+	defer transport.Close()
+
+	select {
+	case err := <-closed:
+		assert.ErrorContains(t, err, "failed to read stdout")
+	case <-time.After(stdioStopGrace + 10*time.Second):
+		t.Fatal("the server kept running after its output became unreadable")
+	}
+}
+
+func TestStdioServerWithLongStderrLines(t *testing.T) {
+	command := StdioCommand{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestStdioHelperProcess"},
+		Env:     map[string]string{"MCP_STDIO_HELPER": "noisy"},
+	}
+	transport := NewStdioTransport(command, StdioLimits{})
+
+	messages := make(chan json.RawMessage, 1)
+	closed := make(chan error, 1)
+	require.NoError(t, transport.Start(context.Background(), func(m json.RawMessage) { messages <- m }, func(err error) { closed <- err }))
+	defer transport.Close()
+
+	select {
+	case m := <-messages:
+		assert.JSONEq(t, `{"done":true}`, string(m))
+	case <-time.After(10 * time.Second):
+		t.Fatal("the server blocked writing to stderr")
+	}
+	select {
+	case err := <-closed:
+		assert.ErrorIs(t, err, io.EOF)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the transport did not report the server's exit")
+	}
+}
+
+func TestStdioCommandEnvironment(t *testing.T) {
+	t.Setenv("DATABASE_PASSWORD", "secret")
+	cmd := StdioCommand{Command: "server", Env: map[string]string{"API_KEY": "k"}}
+
+	env := cmd.environ()
+	assert.Contains(t, env, "API_KEY=k")
+	for _, kv := range env {
+		assert.NotContains(t, kv, "DATABASE_PASSWORD", "the service environment is not shared")
+	}
+}
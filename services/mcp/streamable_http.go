@@ -0,0 +1,197 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// streamableHTTPTransport implements the Streamable HTTP transport: every message is POSTed to
+// one endpoint and the server answers with JSON, an SSE stream, or 202 Accepted
+type streamableHTTPTransport struct {
+	endpoint   string
+	httpClient *http.Client
+	headers    map[string]string
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+	onMessage       func(json.RawMessage)
+	closed          bool
+	streams         sync.WaitGroup
+	cancelStreams   context.CancelFunc
+	streamCtx       context.Context
+}
+
+// NewStreamableHTTPTransport creates a Streamable HTTP transport for endpoint. headers are sent
+// with every request (e.g. Authorization).
+func NewStreamableHTTPTransport(endpoint string, httpClient *http.Client, headers map[string]string) Transport {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &streamableHTTPTransport{
+		endpoint:   endpoint,
+		httpClient: httpClient,
+		headers:    headers,
+	}
+}
+
+func (t *streamableHTTPTransport) Start(ctx context.Context, onMessage func(json.RawMessage), onClose func(error)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onMessage = onMessage
+	// Response streams outlive the Send call that opened them, so they get their own context
+	t.streamCtx, t.cancelStreams = context.WithCancel(context.Background())
+	return nil
+}
+
+func (t *streamableHTTPTransport) SetProtocolVersion(version string) {
+	t.mu.Lock()
+	t.protocolVersion = version
+	t.mu.Unlock()
+}
+
+func (t *streamableHTTPTransport) Send(ctx context.Context, msg json.RawMessage) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrClosed
+	}
+	sessionID, version, streamCtx := t.sessionID, t.protocolVersion, t.streamCtx
+	t.mu.Unlock()
+
+	// Requests may be answered with a long-lived event stream; tie it to the transport and
+	// stop it early only if the caller gives up before the response headers arrive
+	reqCtx, cancel := context.WithCancel(streamCtx)
+	stop := context.AfterFunc(ctx, cancel)
+
+	req, err := http.NewRequestWithContext(reqCtx, "POST", t.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		stop()
+		cancel()
+		return fmt.Errorf("failed to create MCP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+	if version != "" {
+		req.Header.Set("MCP-Protocol-Version", version)
+	}
+	applyHeaders(ctx, req, t.headers)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		stop()
+		cancel()
+		return fmt.Errorf("failed to send MCP request: %w", err)
+	}
+
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		resp.Body.Close()
+		stop()
+		cancel()
+		return nil
+	case resp.StatusCode == http.StatusNotFound && sessionID != "":
+		resp.Body.Close()
+		stop()
+		cancel()
+		return ErrSessionExpired
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		statusErr := newHTTPStatusError(resp)
+		resp.Body.Close()
+		stop()
+		cancel()
+		return statusErr
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// The caller's context no longer applies; the client cancels through the protocol
+		stop()
+		t.streams.Add(1)
+		go func() {
+			defer t.streams.Done()
+			defer cancel()
+			defer resp.Body.Close()
+			err := readSSE(resp.Body, func(ev sseEvent) bool {
+				if ev.Event == "message" {
+					t.deliver(json.RawMessage(ev.Data))
+				}
+				return true
+			})
+			if err != nil && reqCtx.Err() == nil {
+				log.Printf("[MCP] Response stream from %s ended: %v", t.endpoint, err)
+			}
+		}()
+		return nil
+	}
+
+	defer stop()
+	defer cancel()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read MCP response: %w", err)
+	}
+	messages, err := splitBatch(body)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		t.deliver(m)
+	}
+	return nil
+}
+
+func (t *streamableHTTPTransport) deliver(msg json.RawMessage) {
+	t.mu.Lock()
+	onMessage := t.onMessage
+	t.mu.Unlock()
+	if onMessage != nil {
+		onMessage(msg)
+	}
+}
+
+// Close ends open response streams and asks the server to drop the session
+func (t *streamableHTTPTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	sessionID := t.sessionID
+	if t.cancelStreams != nil {
+		t.cancelStreams()
+	}
+	t.mu.Unlock()
+	t.streams.Wait()
+
+	if sessionID == "" {
+		return nil
+	}
+	req, err := http.NewRequest("DELETE", t.endpoint, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("Mcp-Session-Id", sessionID)
+	applyHeaders(context.Background(), req, t.headers)
+	if resp, err := t.httpClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+	return nil
+}
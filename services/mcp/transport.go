@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Transport names accepted in a skill's mcp_transport
+const (
+	TransportAuto           = ""                // Streamable HTTP, falling back to SSE
+	TransportStreamableHTTP = "streamable_http" // MCP 2025-03-26 single endpoint
+	TransportSSE            = "sse"             // MCP 2024-11-05 HTTP+SSE
+	TransportLegacyREST     = "rest"            // In-house /mcp/tools/* shims
+	TransportStdio          = "stdio"           // Locally launched process over stdin/stdout
+)
+
+var (
+	// ErrClosed is returned for calls on a closed client or transport
+	ErrClosed = errors.New("mcp connection closed")
+
+	// ErrSessionExpired is returned when a Streamable HTTP server no longer knows the session;
+	// the caller should reconnect
+	ErrSessionExpired = errors.New("mcp session expired")
+)
+
+// Transport moves JSON-RPC messages between the client and a server. Incoming messages of any
+// kind (responses, notifications, server requests) are passed to onMessage; onClose is called
+// once if the connection ends on its own.
+type Transport interface {
+	Start(ctx context.Context, onMessage func(json.RawMessage), onClose func(error)) error
+	Send(ctx context.Context, msg json.RawMessage) error
+	Close() error
+}
+
+// protocolVersionSetter is implemented by transports that must echo the negotiated version
+type protocolVersionSetter interface {
+	SetProtocolVersion(version string)
+}
+
+// HTTPStatusError is returned when an HTTP transport gets an unexpected status
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("mcp server returned HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+func newHTTPStatusError(resp *http.Response) *HTTPStatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &HTTPStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+}
+
+type headersKey struct{}
+
+// WithHeader returns a context whose HTTP requests to MCP servers carry the header, e.g. the
+// caller's tenant ID
+func WithHeader(ctx context.Context, key, value string) context.Context {
+	headers := map[string]string{}
+	for k, v := range headersFromContext(ctx) {
+		headers[k] = v
+	}
+	headers[key] = value
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+func headersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersKey{}).(map[string]string)
+	return headers
+}
+
+func applyHeaders(ctx context.Context, req *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headersFromContext(ctx) {
+		req.Header.Set(k, v)
+	}
+}
+
+// sseEvent is one server-sent event
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE parses an event stream, calling fn for each event until the stream ends or fn
+// returns false
+func readSSE(r io.Reader, fn func(sseEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var event sseEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				event.Data = strings.Join(data, "\n")
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				if !fn(event) {
+					return nil
+				}
+			}
+			event = sseEvent{}
+			data = nil
+		case strings.HasPrefix(line, ":"):
+			// Comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event.Event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return scanner.Err()
+}
+
+// splitBatch returns the messages of a JSON-RPC body, which may be a single message or a batch
+func splitBatch(body []byte) ([]json.RawMessage, error) {
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		var batch []json.RawMessage
+		if err := json.Unmarshal([]byte(trimmed), &batch); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON-RPC batch: %w", err)
+		}
+		return batch, nil
+	}
+	return []json.RawMessage{json.RawMessage(trimmed)}, nil
+}
@@ -126,7 +126,7 @@ func (s *MemoryConsolidationServiceImpl) ConsolidateSession(ctx context.Context,
 	for _, entry := range entriesToProcess {
 		originalTokens += entry.TokenCount
 	}
-	summaryTokens := estimateTokenCount(summary)
+	summaryTokens := estimateTokenCount(ctx, summary)
 	tokensSaved := originalTokens - summaryTokens
 
 	// Update last consolidation time
@@ -343,7 +343,7 @@ Output format: ["fact 1", "fact 2", ...]`, conversationText)
 			Content:     fact,
 			SourceType:  "conversation",
 			SessionID:   sessionID,
-			TokenCount:  estimateTokenCount(fact),
+			TokenCount:  estimateTokenCount(ctx, fact),
 			CreatedAt:   time.Now(),
 			AccessedAt:  time.Now(),
 			AccessCount: 0,
@@ -233,7 +233,7 @@ func (s *LongTermMemoryServiceImpl) StoreSummary(ctx context.Context, agentID uu
 		Content:     summary,
 		SourceType:  "conversation",
 		SessionID:   sessionID,
-		TokenCount:  estimateTokenCount(summary),
+		TokenCount:  estimateTokenCount(ctx, summary),
 		Metadata: map[string]interface{}{
 			"source_entries": sourceEntries,
 		},
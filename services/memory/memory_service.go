@@ -111,27 +111,39 @@ func (s *MemoryServiceImpl) GetMemoryState(ctx context.Context, req models.GetMe
 
 // GetFormattedMemory retrieves and formats memory for context injection
 func (s *MemoryServiceImpl) GetFormattedMemory(ctx context.Context, req models.GetMemoryRequest, tokenBudget int) (*models.MemoryContext, error) {
-	memoryCtx := &models.MemoryContext{
-		Strategy: "priority", // Prioritize recent conversation, then working, then long-term
-	}
-
 	// Budget allocation strategy:
 	// - 50% for short-term (conversation history)
 	// - 35% for working memory (document context)
 	// - 15% for long-term (accumulated knowledge)
-	shortTermBudget := tokenBudget / 2
-	workingBudget := tokenBudget * 35 / 100
-	longTermBudget := tokenBudget * 15 / 100
+	memoryCtx, err := s.GetFormattedMemoryWithBudget(ctx, req, models.MemoryBudget{
+		ShortTerm: tokenBudget / 2,
+		Working:   tokenBudget * 35 / 100,
+		LongTerm:  tokenBudget * 15 / 100,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	memoryCtx.Truncated = memoryCtx.Truncated || memoryCtx.TotalTokens > tokenBudget
+	return memoryCtx, nil
+}
+
+// GetFormattedMemoryWithBudget retrieves and formats memory using explicit per-tier limits.
+// Short-term history is trimmed oldest-first for the prompt only; the stored conversation is left intact.
+func (s *MemoryServiceImpl) GetFormattedMemoryWithBudget(ctx context.Context, req models.GetMemoryRequest, budget models.MemoryBudget) (*models.MemoryContext, error) {
+	memoryCtx := &models.MemoryContext{
+		Strategy: "priority", // Prioritize recent conversation, then working, then long-term
+	}
 
 	// Get and format short-term memory
-	if req.SessionID != "" {
+	if req.SessionID != "" && budget.ShortTerm > 0 {
 		shortTerm, err := s.shortTerm.GetConversation(ctx, req.SessionID, req.AgentID)
 		if err == nil && shortTerm != nil {
 			// Trim to budget if needed
-			if shortTerm.TotalTokens > shortTermBudget {
-				if err := s.shortTerm.TrimToTokenLimit(ctx, req.SessionID, req.AgentID, shortTermBudget); err == nil {
-					shortTerm, _ = s.shortTerm.GetConversation(ctx, req.SessionID, req.AgentID)
-				}
+			for shortTerm.TotalTokens > budget.ShortTerm && len(shortTerm.Entries) > 0 {
+				shortTerm.TotalTokens -= shortTerm.Entries[0].TokenCount
+				shortTerm.Entries = shortTerm.Entries[1:]
+				memoryCtx.Truncated = true
 			}
 			memoryCtx.FormattedShortTerm = s.shortTerm.FormatForContext(shortTerm)
 			memoryCtx.TotalTokens += shortTerm.TotalTokens
@@ -139,23 +151,24 @@ func (s *MemoryServiceImpl) GetFormattedMemory(ctx context.Context, req models.G
 	}
 
 	// Get and format working memory
-	if req.SessionID != "" {
+	if req.SessionID != "" && budget.Working > 0 {
 		working, err := s.working.GetWorkingMemory(ctx, req.SessionID, req.AgentID)
-		if err == nil && working != nil && working.TotalTokens <= workingBudget {
+		if err == nil && working != nil && working.TotalTokens <= budget.Working {
 			memoryCtx.FormattedWorking = s.working.FormatForContext(working)
 			memoryCtx.TotalTokens += working.TotalTokens
 		}
 	}
 
 	// Get and format long-term memory
-	if s.config.LongTermEnabled && req.Query != "" {
+	if s.config.LongTermEnabled && req.Query != "" && budget.LongTerm > 0 {
 		longTerm, err := s.longTerm.SearchMemory(ctx, req.AgentID, req.Query, 3)
 		if err == nil && len(longTerm) > 0 {
 			// Trim to budget
 			totalLongTermTokens := 0
 			var includedEntries []models.LongTermMemoryEntry
 			for _, entry := range longTerm {
-				if totalLongTermTokens+entry.TokenCount > longTermBudget {
+				if totalLongTermTokens+entry.TokenCount > budget.LongTerm {
+					memoryCtx.Truncated = true
 					break
 				}
 				includedEntries = append(includedEntries, entry)
@@ -166,7 +179,6 @@ func (s *MemoryServiceImpl) GetFormattedMemory(ctx context.Context, req models.G
 		}
 	}
 
-	memoryCtx.Truncated = memoryCtx.TotalTokens > tokenBudget
 	return memoryCtx, nil
 }
 
@@ -181,7 +193,7 @@ func (s *MemoryServiceImpl) AddMemory(ctx context.Context, req models.AddMemoryR
 		Type:      models.MemoryTypeShortTerm,
 		Role:      req.Role,
 		Content:   req.Content,
-		TokenCount: estimateTokenCount(req.Content),
+		TokenCount: estimateTokenCount(ctx, req.Content),
 		Timestamp: time.Now(),
 		Metadata:  req.Metadata,
 	}
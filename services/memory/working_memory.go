@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
 // WorkingMemoryServiceImpl implements WorkingMemoryService using Redis
@@ -79,12 +80,12 @@ func (s *WorkingMemoryServiceImpl) SetDocumentContext(ctx context.Context, sessi
 	// Calculate token count for chunks
 	totalTokens := 0
 	for _, chunk := range chunks {
-		totalTokens += estimateTokenCount(chunk.Content)
+		totalTokens += estimateTokenCount(ctx, chunk.Content)
 	}
 
 	// Trim chunks if exceeds token limit
 	if totalTokens > s.config.WorkingMemoryMaxTokens {
-		chunks, totalTokens = s.trimChunks(chunks, s.config.WorkingMemoryMaxTokens)
+		chunks, totalTokens = s.trimChunks(ctx, chunks, s.config.WorkingMemoryMaxTokens)
 	}
 
 	memory.RetrievedChunks = chunks
@@ -154,7 +155,7 @@ func (s *WorkingMemoryServiceImpl) UnloadDocument(ctx context.Context, sessionID
 			// Recalculate tokens
 			memory.TotalTokens = 0
 			for _, chunk := range memory.RetrievedChunks {
-				memory.TotalTokens += estimateTokenCount(chunk.Content)
+				memory.TotalTokens += estimateTokenCount(ctx, chunk.Content)
 			}
 
 			return s.saveMemory(ctx, memory)
@@ -225,14 +226,14 @@ func (s *WorkingMemoryServiceImpl) saveMemory(ctx context.Context, memory *model
 }
 
 // trimChunks trims chunks to fit within token limit
-func (s *WorkingMemoryServiceImpl) trimChunks(chunks []models.RetrievedChunk, maxTokens int) ([]models.RetrievedChunk, int) {
+func (s *WorkingMemoryServiceImpl) trimChunks(ctx context.Context, chunks []models.RetrievedChunk, maxTokens int) ([]models.RetrievedChunk, int) {
 	var result []models.RetrievedChunk
 	totalTokens := 0
 
 	// Sort by score (descending) to keep most relevant chunks
 	// For simplicity, assuming chunks are already sorted by relevance
 	for _, chunk := range chunks {
-		chunkTokens := estimateTokenCount(chunk.Content)
+		chunkTokens := estimateTokenCount(ctx, chunk.Content)
 		if totalTokens+chunkTokens > maxTokens {
 			break
 		}
@@ -268,9 +269,9 @@ func (s *WorkingMemoryServiceImpl) FormatForContext(memory *models.WorkingMemory
 	return formatted
 }
 
-// estimateTokenCount provides a rough token estimate (chars / 4)
-func estimateTokenCount(text string) int {
-	return len(text) / 4
+// estimateTokenCount counts tokens with the tokenizer carried by ctx, or the default encoding
+func estimateTokenCount(ctx context.Context, text string) int {
+	return tokenizer.FromContext(ctx).Count(text)
 }
 
 // calculateSimpleSimilarity calculates simple word overlap similarity
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrModelNotFound is returned when a model is not offered by any provider
+	ErrModelNotFound = errors.New("model not found in catalog")
+
+	// ErrProviderNotFound is returned when the router does not offer a provider
+	ErrProviderNotFound = errors.New("provider not found in catalog")
+)
+
+// ModelCatalogService provides provider/model capabilities fetched from the LLM router.
+// Implementations must be safe for concurrent use.
+type ModelCatalogService interface {
+	// GetModel returns the capabilities of a single model
+	GetModel(ctx context.Context, model string) (*ModelCapabilities, error)
+
+	// ListProviders returns every provider known to the router with its models
+	ListProviders(ctx context.Context) ([]ProviderCapabilities, error)
+
+	// ListModels returns the models offered by a provider
+	ListModels(ctx context.Context, provider string) ([]ModelCapabilities, error)
+
+	// Refresh forces a reload of the catalog from the router
+	Refresh(ctx context.Context) error
+}
+
+type modelCapabilitiesKey struct{}
+
+// WithModelCapabilities returns a copy of ctx carrying the capabilities of the model a request is
+// for, so services handling it use them instead of looking the model up again
+func WithModelCapabilities(ctx context.Context, caps *ModelCapabilities) context.Context {
+	return context.WithValue(ctx, modelCapabilitiesKey{}, caps)
+}
+
+// ModelCapabilitiesFromContext returns the capabilities set by WithModelCapabilities if they
+// describe model
+func ModelCapabilitiesFromContext(ctx context.Context, model string) (*ModelCapabilities, bool) {
+	caps, ok := ctx.Value(modelCapabilitiesKey{}).(*ModelCapabilities)
+	if !ok || caps == nil || (caps.Name != model && caps.ProviderModelID != model) {
+		return nil, false
+	}
+	return caps, true
+}
+
+// ModelCapabilities describes the limits, features and pricing of a model
+type ModelCapabilities struct {
+	Name            string    `json:"name"`
+	DisplayName     string    `json:"display_name"`
+	Provider        string    `json:"provider"`
+	ProviderModelID string    `json:"provider_model_id,omitempty"`
+	ContextWindow   int       `json:"context_window"`
+	MaxOutputTokens int       `json:"max_output_tokens"`
+	Features        []string  `json:"features"`
+	InputCostPer1K  float64   `json:"input_cost_per_1k"`
+	OutputCostPer1K float64   `json:"output_cost_per_1k"`
+	Tokenizer       string    `json:"tokenizer"` // BPE encoding used for token counting (e.g. "cl100k_base")
+	FetchedAt       time.Time `json:"fetched_at"`
+}
+
+// HasFeature reports whether the model advertises the given feature (e.g. "functions", "vision")
+func (m *ModelCapabilities) HasFeature(feature string) bool {
+	for _, f := range m.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderCapabilities groups a provider with the models it offers
+type ProviderCapabilities struct {
+	Name             string              `json:"name"`
+	DisplayName      string              `json:"display_name"`
+	MaxContextWindow int                 `json:"max_context_window"`
+	Features         []string            `json:"features"`
+	Models           []ModelCapabilities `json:"models"`
+}
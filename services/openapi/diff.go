@@ -0,0 +1,90 @@
+package openapi
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services/semver"
+)
+
+// Diff compares a skill's tools before and after a re-import, by tool name
+func Diff(before, after models.FunctionTools) models.SkillToolDiff {
+	diff := models.SkillToolDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	old := make(map[string]models.FunctionTool, len(before))
+	for _, t := range before {
+		old[t.Name] = t
+	}
+
+	for _, t := range after {
+		prev, ok := old[t.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, t.Name)
+		case !sameTool(prev, t):
+			diff.Changed = append(diff.Changed, t.Name)
+		default:
+			diff.Unchanged++
+		}
+		delete(old, t.Name)
+	}
+	for name := range old {
+		diff.Removed = append(diff.Removed, name)
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+func sameTool(a, b models.FunctionTool) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+// callShapeChanged reports whether a change alters how the tool is called, as opposed to only
+// its description
+func callShapeChanged(a, b models.FunctionTool) bool {
+	a.Description, b.Description = "", ""
+	return !sameTool(a, b)
+}
+
+// NextVersion bumps a semantic version for a re-import: major when tools were removed or their
+// calls changed, minor when tools were only added, and patch when only descriptions changed.
+// An unparseable version restarts at 1.0.0.
+func NextVersion(version string, before, after models.FunctionTools, diff models.SkillToolDiff) string {
+	if diff.Empty() {
+		return version
+	}
+
+	v, err := semver.Parse(version)
+	if err != nil {
+		return "1.0.0"
+	}
+
+	breaking := len(diff.Removed) > 0
+	if !breaking {
+		old := make(map[string]models.FunctionTool, len(before))
+		for _, t := range before {
+			old[t.Name] = t
+		}
+		for _, t := range after {
+			if prev, ok := old[t.Name]; ok && callShapeChanged(prev, t) {
+				breaking = true
+				break
+			}
+		}
+	}
+
+	switch {
+	case breaking:
+		v = v.BumpMajor()
+	case len(diff.Added) > 0:
+		v = v.BumpMinor()
+	default:
+		v = v.BumpPatch()
+	}
+	return v.String()
+}
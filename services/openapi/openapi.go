@@ -0,0 +1,610 @@
+// Package openapi converts OpenAPI 3 documents into the tools of a function skill, one tool
+// per operation, so services that publish a spec can be used by agents without an MCP server.
+package openapi
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tas-agent-builder/models"
+	"gopkg.in/yaml.v3"
+)
+
+// maxDescriptionRunes bounds generated tool descriptions
+const maxDescriptionRunes = 1024
+
+var methods = []string{"get", "put", "post", "delete", "patch"}
+
+// Options control how a document is imported
+type Options struct {
+	// ServerURL overrides the document's first server
+	ServerURL string
+	// Operations selects operations by operationId, generated tool name or "METHOD /path";
+	// empty imports them all
+	Operations []string
+	// Auth names the stored credential to use for each security scheme
+	Auth map[string]models.OpenAPIAuth
+}
+
+// Result is an imported document
+type Result struct {
+	Title       string
+	Description string
+	Tools       models.FunctionTools
+	AuthHeaders models.CredentialTemplates // The skill's auth templates for the schemes used
+	AuthQuery   models.CredentialTemplates
+	Warnings    []string // Operations skipped or imported without auth
+}
+
+// Document is a parsed OpenAPI 3 document
+type Document struct {
+	root map[string]interface{}
+}
+
+// Parse reads an OpenAPI 3 document in JSON or YAML
+func Parse(data []byte) (*Document, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("spec is neither valid JSON nor YAML: %w", err)
+	}
+	root, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("spec must be an object")
+	}
+
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		if _, ok := root["swagger"]; ok {
+			return nil, fmt.Errorf("swagger 2.0 documents are not supported; convert to OpenAPI 3 first")
+		}
+		return nil, fmt.Errorf("spec has no openapi 3.x version field")
+	}
+	if _, ok := root["paths"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("spec has no paths")
+	}
+	return &Document{root: root}, nil
+}
+
+// normalize converts YAML maps with non-string keys (such as response codes) into the
+// map[string]interface{} that encoding/json produces
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			t[k] = normalize(child)
+		}
+		return t
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			out[fmt.Sprint(k)] = normalize(child)
+		}
+		return out
+	case []interface{}:
+		for i, child := range t {
+			t[i] = normalize(child)
+		}
+		return t
+	}
+	return v
+}
+
+// Import converts the document's operations into function tools
+func (d *Document) Import(opts Options) (*Result, error) {
+	info, _ := d.root["info"].(map[string]interface{})
+	res := &Result{AuthHeaders: make(models.CredentialTemplates), AuthQuery: make(models.CredentialTemplates)}
+	res.Title, _ = info["title"].(string)
+	res.Description, _ = info["description"].(string)
+
+	base, err := d.serverURL(opts.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	for scheme, cred := range opts.Auth {
+		if cred.Credential == "" {
+			return nil, fmt.Errorf("auth[%s]: credential is required", scheme)
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, op := range opts.Operations {
+		selected[normalizeSelector(op)] = false
+	}
+
+	paths := d.root["paths"].(map[string]interface{})
+	pathKeys := make([]string, 0, len(paths))
+	for p := range paths {
+		pathKeys = append(pathKeys, p)
+	}
+	sort.Strings(pathKeys)
+
+	names := make(map[string]bool)
+	for _, path := range pathKeys {
+		item, _ := d.resolve(paths[path]).(map[string]interface{})
+		for _, method := range methods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			operationID, _ := op["operationId"].(string)
+			name := uniqueName(toolName(operationID, method, path), names)
+			if len(selected) > 0 {
+				matched := false
+				for _, key := range []string{operationID, name, strings.ToUpper(method) + " " + path} {
+					key = normalizeSelector(key)
+					if _, ok := selected[key]; ok && key != "" {
+						selected[key] = true
+						matched = true
+					}
+				}
+				if !matched {
+					continue
+				}
+			}
+
+			tool, warnings, err := d.operationTool(res, name, method, path, base, item, op, opts.Auth)
+			res.Warnings = append(res.Warnings, warnings...)
+			if err != nil {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s %s skipped: %v", strings.ToUpper(method), path, err))
+				continue
+			}
+			names[name] = true
+			res.Tools = append(res.Tools, *tool)
+		}
+	}
+
+	var missing []string
+	for key, found := range selected {
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("operations not found in spec: %s", strings.Join(missing, ", "))
+	}
+	if len(res.Tools) == 0 {
+		return nil, fmt.Errorf("spec has no importable operations")
+	}
+	if err := res.Tools.Validate(); err != nil {
+		return nil, err
+	}
+	if len(res.AuthHeaders) == 0 {
+		res.AuthHeaders = nil
+	}
+	if len(res.AuthQuery) == 0 {
+		res.AuthQuery = nil
+	}
+	return res, nil
+}
+
+func normalizeSelector(s string) string {
+	s = strings.TrimSpace(s)
+	if method, path, ok := strings.Cut(s, " "); ok {
+		return strings.ToUpper(method) + " " + strings.TrimSpace(path)
+	}
+	return s
+}
+
+// serverURL returns the base URL for operations with server variables set to their defaults
+func (d *Document) serverURL(override string) (string, error) {
+	base := override
+	if base == "" {
+		servers, _ := d.root["servers"].([]interface{})
+		if len(servers) > 0 {
+			server, _ := servers[0].(map[string]interface{})
+			base, _ = server["url"].(string)
+			vars, _ := server["variables"].(map[string]interface{})
+			for name, v := range vars {
+				variable, _ := v.(map[string]interface{})
+				def := fmt.Sprint(variable["default"])
+				base = strings.ReplaceAll(base, "{"+name+"}", def)
+			}
+		}
+	}
+
+	u, err := url.Parse(base)
+	if base == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("spec has no absolute http(s) server URL; set server_url")
+	}
+	return strings.TrimRight(base, "/"), nil
+}
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	invalidArgChars  = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+	pathParam        = regexp.MustCompile(`\{([^{}]+)\}`)
+)
+
+// toolName derives a tool name from the operationId, or from the method and path
+func toolName(operationID, method, path string) string {
+	name := operationID
+	if name == "" {
+		name = method + "_" + strings.Trim(pathParam.ReplaceAllString(path, "$1"), "/")
+	}
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = method
+	}
+	if len(name) > 60 {
+		name = name[:60]
+	}
+	return name
+}
+
+func uniqueName(name string, taken map[string]bool) string {
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+	return candidate
+}
+
+// argName maps a parameter name onto the characters URL and header placeholders allow
+func argName(name string) string {
+	return invalidArgChars.ReplaceAllString(name, "_")
+}
+
+// operationTool builds the function tool for one operation
+func (d *Document) operationTool(res *Result, name, method, path, base string, item, op map[string]interface{}, auth map[string]models.OpenAPIAuth) (*models.FunctionTool, []string, error) {
+	tool := &models.FunctionTool{
+		Name:        name,
+		Description: describe(op, method, path),
+		Method:      strings.ToUpper(method),
+		Headers:     make(map[string]string),
+	}
+	properties := make(map[string]interface{})
+	var required []string
+	var query []string
+	autoQuery := method == "get" || method == "delete"
+
+	renderedPath := path
+	for _, param := range d.parameters(item, op) {
+		pname, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		arg := argName(pname)
+		if _, dup := properties[arg]; dup {
+			return nil, nil, fmt.Errorf("parameters %q collide", arg)
+		}
+
+		switch in {
+		case "path":
+			renderedPath = strings.ReplaceAll(renderedPath, "{"+pname+"}", "{{"+arg+"}}")
+		case "query":
+			// Unreferenced arguments of GET and DELETE go in the query string by themselves
+			if !autoQuery || arg != pname {
+				query = append(query, url.QueryEscape(pname)+"={{"+arg+"}}")
+			}
+		case "header":
+			tool.Headers[pname] = "{{" + arg + "}}"
+		default:
+			return nil, nil, fmt.Errorf("%s parameter %q is not supported", in, pname)
+		}
+
+		schema := d.schema(param["schema"])
+		if desc, ok := param["description"].(string); ok && schema["description"] == nil {
+			schema["description"] = desc
+		}
+		properties[arg] = schema
+		if req, _ := param["required"].(bool); req || in == "path" {
+			required = append(required, arg)
+		}
+	}
+
+	if body, ok := d.resolve(op["requestBody"]).(map[string]interface{}); ok {
+		schema, err := d.bodySchema(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for prop, s := range props {
+			if _, dup := properties[prop]; dup {
+				return nil, nil, fmt.Errorf("body property %q collides with a parameter", prop)
+			}
+			if m, ok := s.(map[string]interface{}); ok && m["readOnly"] == true {
+				continue
+			}
+			properties[prop] = s
+		}
+		if bodyRequired, _ := body["required"].(bool); bodyRequired {
+			if req, ok := schema["required"].([]interface{}); ok {
+				for _, r := range req {
+					if s, ok := r.(string); ok && properties[s] != nil {
+						required = append(required, s)
+					}
+				}
+			}
+		}
+	}
+
+	if m := pathParam.FindStringSubmatch(strings.ReplaceAll(strings.ReplaceAll(renderedPath, "{{", ""), "}}", "")); m != nil {
+		return nil, nil, fmt.Errorf("path parameter %q is not declared", m[1])
+	}
+	tool.URL = base + renderedPath
+	if len(query) > 0 {
+		tool.URL += "?" + strings.Join(query, "&")
+	}
+
+	warnings, err := applySecurity(res, name, d.security(op), d.securitySchemes(), auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tool.Parameters = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		tool.Parameters["required"] = required
+	}
+	if len(tool.Headers) == 0 {
+		tool.Headers = nil
+	}
+	return tool, warnings, nil
+}
+
+func describe(op map[string]interface{}, method, path string) string {
+	summary, _ := op["summary"].(string)
+	description, _ := op["description"].(string)
+	var parts []string
+	for _, s := range []string{summary, description} {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	text := strings.Join(parts, "\n\n")
+	if text == "" {
+		text = strings.ToUpper(method) + " " + path
+	}
+	if runes := []rune(text); len(runes) > maxDescriptionRunes {
+		text = string(runes[:maxDescriptionRunes-3]) + "..."
+	}
+	return text
+}
+
+// parameters merges path-level and operation-level parameters; the operation's win
+func (d *Document) parameters(item, op map[string]interface{}) []map[string]interface{} {
+	var out []map[string]interface{}
+	index := make(map[string]int)
+	for _, list := range []interface{}{item["parameters"], op["parameters"]} {
+		params, _ := list.([]interface{})
+		for _, p := range params {
+			param, ok := d.resolve(p).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			key := fmt.Sprint(param["in"], ":", param["name"])
+			if i, ok := index[key]; ok {
+				out[i] = param
+				continue
+			}
+			index[key] = len(out)
+			out = append(out, param)
+		}
+	}
+	return out
+}
+
+// bodySchema returns the object schema of a JSON request body
+func (d *Document) bodySchema(body map[string]interface{}) (map[string]interface{}, error) {
+	content, _ := body["content"].(map[string]interface{})
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	for _, mediaType := range types {
+		base, _, _ := strings.Cut(mediaType, ";")
+		if base != "application/json" && !strings.HasSuffix(base, "+json") {
+			continue
+		}
+		media, _ := content[mediaType].(map[string]interface{})
+		schema := d.schema(media["schema"])
+		if t, _ := schema["type"].(string); t != "object" && schema["properties"] == nil {
+			return nil, fmt.Errorf("request bodies must be JSON objects")
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("request bodies must be JSON (found %s)", strings.Join(types, ", "))
+}
+
+// schemaOnlyKeys are OpenAPI keywords that are not JSON Schema and mean nothing to a model
+var schemaOnlyKeys = []string{"xml", "discriminator", "externalDocs", "example", "examples", "deprecated", "writeOnly"}
+
+// schema returns a copy of a schema with local $refs inlined. Recursive references are
+// cut short with an untyped object.
+func (d *Document) schema(v interface{}) map[string]interface{} {
+	out, _ := d.inline(v, make(map[string]bool)).(map[string]interface{})
+	if out == nil {
+		out = map[string]interface{}{"type": "string"}
+	}
+	return out
+}
+
+func (d *Document) inline(v interface{}, visiting map[string]bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := t["$ref"].(string); ok {
+			if visiting[ref] {
+				return map[string]interface{}{"type": "object"}
+			}
+			visiting[ref] = true
+			defer delete(visiting, ref)
+			return d.inline(d.lookup(ref), visiting)
+		}
+		out := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			out[k] = d.inline(child, visiting)
+		}
+		for _, k := range schemaOnlyKeys {
+			delete(out, k)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			out[i] = d.inline(child, visiting)
+		}
+		return out
+	}
+	return v
+}
+
+// resolve follows $refs until it reaches an object that is not one
+func (d *Document) resolve(v interface{}) interface{} {
+	for i := 0; i < 16; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return v
+		}
+		v = d.lookup(ref)
+	}
+	return nil
+}
+
+// lookup resolves a local JSON pointer such as #/components/schemas/Pet
+func (d *Document) lookup(ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var cur interface{} = d.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[token]
+	}
+	return cur
+}
+
+func (d *Document) security(op map[string]interface{}) []interface{} {
+	if s, ok := op["security"].([]interface{}); ok {
+		return s
+	}
+	s, _ := d.root["security"].([]interface{})
+	return s
+}
+
+func (d *Document) securitySchemes() map[string]interface{} {
+	components, _ := d.root["components"].(map[string]interface{})
+	schemes, _ := components["securitySchemes"].(map[string]interface{})
+	return schemes
+}
+
+// applySecurity maps the schemes of the first security requirement that has all of them
+// configured onto the skill's auth templates. An operation no requirement can be met for is
+// imported with a warning.
+func applySecurity(res *Result, tool string, requirements []interface{}, schemes map[string]interface{}, auth map[string]models.OpenAPIAuth) ([]string, error) {
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+
+	var wanted []string
+	for _, r := range requirements {
+		requirement, _ := r.(map[string]interface{})
+		if len(requirement) == 0 {
+			return nil, nil // Authentication is optional
+		}
+
+		names := make([]string, 0, len(requirement))
+		satisfied := true
+		for name := range requirement {
+			names = append(names, name)
+			if _, ok := auth[name]; !ok {
+				satisfied = false
+			}
+		}
+		sort.Strings(names)
+		if !satisfied {
+			wanted = append(wanted, strings.Join(names, "+"))
+			continue
+		}
+
+		headers, query := make(models.CredentialTemplates), make(models.CredentialTemplates)
+		for _, name := range names {
+			scheme, _ := schemes[name].(map[string]interface{})
+			if err := applyScheme(headers, query, scheme, auth[name]); err != nil {
+				return nil, fmt.Errorf("security scheme %q: %w", name, err)
+			}
+		}
+		// Templates apply to all of the skill's requests, so schemes must agree across operations
+		if err := mergeTemplates(res.AuthHeaders, headers, "header"); err != nil {
+			return nil, err
+		}
+		if err := mergeTemplates(res.AuthQuery, query, "query parameter"); err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			res.AuthHeaders[k] = v
+		}
+		for k, v := range query {
+			res.AuthQuery[k] = v
+		}
+		return nil, nil
+	}
+	return []string{fmt.Sprintf("tool %q needs credentials for %s; it was imported without authentication", tool, strings.Join(wanted, " or "))}, nil
+}
+
+func mergeTemplates(into, from models.CredentialTemplates, kind string) error {
+	for k, v := range from {
+		if prev, ok := into[k]; ok && prev != v {
+			return fmt.Errorf("%s %q is set to %q by another operation's security scheme", kind, k, prev)
+		}
+	}
+	return nil
+}
+
+// applyScheme adds a {{credential:name}} template for the scheme to headers or query. The
+// secret stays in the credential store and is only filled in when a request is sent.
+func applyScheme(headers, query models.CredentialTemplates, scheme map[string]interface{}, cred models.OpenAPIAuth) error {
+	placeholder := "{{credential:" + cred.Credential + "}}"
+	if cred.Header != "" {
+		headers[cred.Header] = placeholder
+		return nil
+	}
+
+	kind, _ := scheme["type"].(string)
+	switch kind {
+	case "http":
+		switch httpScheme, _ := scheme["scheme"].(string); strings.ToLower(httpScheme) {
+		case "bearer":
+			headers["Authorization"] = "Bearer " + placeholder
+		case "basic":
+			headers["Authorization"] = "Basic " + placeholder // The credential holds base64("user:password")
+		default:
+			return fmt.Errorf("http scheme %q is not supported; set a header", httpScheme)
+		}
+	case "oauth2", "openIdConnect":
+		headers["Authorization"] = "Bearer " + placeholder
+	case "apiKey":
+		name, _ := scheme["name"].(string)
+		switch in, _ := scheme["in"].(string); in {
+		case "header":
+			headers[name] = placeholder
+		case "query":
+			query[name] = placeholder
+		case "cookie":
+			cookie := name + "=" + placeholder
+			if prev := headers["Cookie"]; prev != "" {
+				cookie = prev + "; " + cookie
+			}
+			headers["Cookie"] = cookie
+		default:
+			return fmt.Errorf("api key location %q is not supported", in)
+		}
+	default:
+		return fmt.Errorf("type %q is not supported; set a header", kind)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+)
+
+const petstore = `
+openapi: 3.0.3
+info:
+  title: Pet Store
+  description: Pets for sale
+servers:
+  - url: https://{region}.pets.example.com/v1/
+    variables:
+      region:
+        default: eu
+security:
+  - bearer: []
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List pets
+      parameters:
+        - name: limit
+          in: query
+          description: Page size
+          schema: {type: integer}
+      responses:
+        200: {description: ok}
+    post:
+      operationId: createPet
+      summary: Create a pet
+      parameters:
+        - name: dry-run
+          in: query
+          schema: {type: boolean}
+        - name: X-Request-ID
+          in: header
+          schema: {type: string}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        201: {description: created}
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema: {type: string}
+    get:
+      summary: Get a pet
+      description: Returns one pet.
+      security:
+        - apiKey: []
+      responses:
+        200: {description: ok}
+    put:
+      operationId: replacePet
+      requestBody:
+        content:
+          text/plain:
+            schema: {type: string}
+      responses:
+        200: {description: ok}
+components:
+  securitySchemes:
+    bearer: {type: http, scheme: bearer}
+    apiKey: {type: apiKey, in: query, name: api_key}
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        id: {type: integer, readOnly: true}
+        name: {type: string, example: Rex}
+        parent: {$ref: '#/components/schemas/Pet'}
+`
+
+func toolsByName(tools models.FunctionTools) map[string]models.FunctionTool {
+	out := make(map[string]models.FunctionTool)
+	for _, t := range tools {
+		out[t.Name] = t
+	}
+	return out
+}
+
+func TestImportOperations(t *testing.T) {
+	doc, err := Parse([]byte(petstore))
+	require.NoError(t, err)
+
+	res, err := doc.Import(Options{Auth: map[string]models.OpenAPIAuth{
+		"bearer": {Credential: "pets-token"},
+		"apiKey": {Credential: "pets-key"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "Pet Store", res.Title)
+
+	tools := toolsByName(res.Tools)
+	require.Len(t, tools, 3, "the text/plain operation is skipped")
+	assert.Contains(t, res.Warnings, "PUT /pets/{petId} skipped: request bodies must be JSON (found text/plain)")
+
+	list := tools["listPets"]
+	assert.Equal(t, "GET", list.Method)
+	assert.Equal(t, "https://eu.pets.example.com/v1/pets", list.URL, "GET query parameters are added at call time")
+	assert.Empty(t, list.Headers, "credentials go into the skill's auth templates")
+	assert.Equal(t, models.CredentialTemplates{"Authorization": "Bearer {{credential:pets-token}}"}, res.AuthHeaders)
+	assert.Equal(t, models.CredentialTemplates{"api_key": "{{credential:pets-key}}"}, res.AuthQuery)
+	assert.Equal(t, "List pets", list.Description)
+	limit := list.Parameters["properties"].(map[string]interface{})["limit"].(map[string]interface{})
+	assert.Equal(t, "Page size", limit["description"])
+
+	create := tools["createPet"]
+	assert.Equal(t, "https://eu.pets.example.com/v1/pets?dry-run={{dry_run}}", create.URL)
+	assert.Equal(t, "{{X_Request_ID}}", create.Headers["X-Request-ID"])
+	props := create.Parameters["properties"].(map[string]interface{})
+	assert.Contains(t, props, "name")
+	assert.NotContains(t, props, "id", "read-only properties are not sent")
+	assert.NotContains(t, props["name"], "example")
+	assert.Equal(t, map[string]interface{}{"type": "object"}, props["parent"], "recursion is cut short")
+	assert.Equal(t, []string{"name"}, create.Parameters["required"])
+
+	get := tools["get_pets_petId"]
+	assert.Equal(t, "https://eu.pets.example.com/v1/pets/{{petId}}", get.URL)
+	assert.Equal(t, "Get a pet\n\nReturns one pet.", get.Description)
+	assert.Equal(t, []string{"petId"}, get.Parameters["required"])
+}
+
+func TestImportSelectionAndAuthWarnings(t *testing.T) {
+	doc, err := Parse([]byte(petstore))
+	require.NoError(t, err)
+
+	res, err := doc.Import(Options{
+		ServerURL:  "http://localhost:8080",
+		Operations: []string{"listPets", "get /pets/{petId}"},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 2)
+	assert.Equal(t, "http://localhost:8080/pets", res.Tools[0].URL)
+	assert.Len(t, res.Warnings, 2, "both operations lack credentials")
+	assert.Nil(t, res.AuthHeaders)
+
+	_, err = doc.Import(Options{Auth: map[string]models.OpenAPIAuth{"bearer": {}}})
+	assert.ErrorContains(t, err, "credential is required")
+
+	_, err = doc.Import(Options{Operations: []string{"deletePet"}})
+	assert.ErrorContains(t, err, "deletePet")
+
+	_, err = Parse([]byte(`{"swagger": "2.0", "paths": {}}`))
+	assert.ErrorContains(t, err, "swagger 2.0")
+}
+
+func TestDiffAndNextVersion(t *testing.T) {
+	a := models.FunctionTool{Name: "a", Method: "GET", URL: "https://x.test/a", Description: "A"}
+	b := models.FunctionTool{Name: "b", Method: "GET", URL: "https://x.test/b"}
+	before := models.FunctionTools{a, b}
+
+	same := Diff(before, before)
+	assert.True(t, same.Empty())
+	assert.Equal(t, "1.2.3", NextVersion("1.2.3", before, before, same))
+
+	c := models.FunctionTool{Name: "c", Method: "POST", URL: "https://x.test/c"}
+	added := models.FunctionTools{a, b, c}
+	diff := Diff(before, added)
+	assert.Equal(t, []string{"c"}, diff.Added)
+	assert.Equal(t, "1.3.0", NextVersion("1.2.3", before, added, diff))
+
+	described := a
+	described.Description = "Better A"
+	docs := models.FunctionTools{described, b}
+	diff = Diff(before, docs)
+	assert.Equal(t, []string{"a"}, diff.Changed)
+	assert.Equal(t, "1.2.4", NextVersion("1.2.3", before, docs, diff))
+
+	moved := b
+	moved.URL = "https://x.test/b2"
+	diff = Diff(before, models.FunctionTools{moved})
+	assert.Equal(t, []string{"a"}, diff.Removed)
+	assert.Equal(t, []string{"b"}, diff.Changed)
+	assert.Equal(t, "2.0.0", NextVersion("1.2.3", before, models.FunctionTools{moved}, diff))
+
+	assert.Equal(t, "1.0.0", NextVersion("latest", before, added, Diff(before, added)))
+}
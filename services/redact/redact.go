@@ -0,0 +1,126 @@
+// Package redact masks secrets and personal data in tool call arguments and results before
+// they are stored for auditing.
+//
+// Every Redactor masks the values of members whose names mark them as credentials (password,
+// api_key, authorization and the like). A skill's RedactionRules add JSONPath expressions,
+// whose selected values are masked, and regular expressions, whose matches are masked in
+// every string.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/tas-agent-builder/models"
+	"github.com/tas-agent-builder/services/jsonpath"
+)
+
+// Mask replaces redacted values
+const Mask = "[REDACTED]"
+
+// secretKey matches member names whose values are always masked
+var secretKey = regexp.MustCompile(`(?i)^(password|passwd|pwd|secret|client_secret|token|access_token|refresh_token|id_token|api_?key|x-api-key|authorization|cookie|set-cookie|private_key|credentials?)$`)
+
+// Redactor applies one skill's redaction rules
+type Redactor struct {
+	paths    []*jsonpath.Path
+	patterns []*regexp.Regexp
+}
+
+// New compiles rules, which may be nil
+func New(rules *models.RedactionRules) (*Redactor, error) {
+	r := &Redactor{}
+	if rules == nil {
+		return r, nil
+	}
+	for _, expr := range rules.Paths {
+		p, err := jsonpath.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction path: %w", err)
+		}
+		r.paths = append(r.paths, p)
+	}
+	for _, expr := range rules.Patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Validate reports whether rules compile
+func Validate(rules *models.RedactionRules) error {
+	_, err := New(rules)
+	return err
+}
+
+// Value returns a redacted copy of decoded JSON; v itself is left unchanged
+func (r *Redactor) Value(v interface{}) interface{} {
+	v = r.walk(deepCopy(v))
+	for _, p := range r.paths {
+		v, _ = p.Replace(v, func(interface{}) interface{} { return Mask })
+	}
+	return v
+}
+
+// Text redacts a tool result. Results holding a JSON object or array are redacted as JSON and
+// re-encoded; other text only has the patterns applied.
+func (r *Redactor) Text(s string) string {
+	var v interface{}
+	if len(s) > 0 && (s[0] == '{' || s[0] == '[') && json.Unmarshal([]byte(s), &v) == nil {
+		if out, err := json.Marshal(r.Value(v)); err == nil {
+			return string(out)
+		}
+	}
+	return r.String(s)
+}
+
+// String masks the pattern matches in s
+func (r *Redactor) String(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// walk masks secret members and applies the patterns to every string
+func (r *Redactor) walk(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if secretKey.MatchString(k) && child != nil {
+				t[k] = Mask
+				continue
+			}
+			t[k] = r.walk(child)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = r.walk(child)
+		}
+	case string:
+		return r.String(t)
+	}
+	return v
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			out[k] = deepCopy(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			out[i] = deepCopy(child)
+		}
+		return out
+	}
+	return v
+}
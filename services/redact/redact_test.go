@@ -0,0 +1,44 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tas-agent-builder/models"
+)
+
+func TestRedactor(t *testing.T) {
+	r, err := New(&models.RedactionRules{
+		Paths:    []string{"$.customer.ssn", "$..card"},
+		Patterns: []string{`[\w.+-]+@[\w-]+(\.[\w-]+)+`},
+	})
+	require.NoError(t, err)
+
+	var args interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"customer": {"ssn": "123-45-6789", "name": "Ada", "note": "mail ada@example.com"},
+		"payments": [{"card": "4111"}, {"card": "5500"}],
+		"api_key": "sk-1",
+		"max_tokens": 100
+	}`), &args))
+
+	out, err := json.Marshal(r.Value(args))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"customer": {"ssn": "[REDACTED]", "name": "Ada", "note": "mail [REDACTED]"},
+		"payments": [{"card": "[REDACTED]"}, {"card": "[REDACTED]"}],
+		"api_key": "[REDACTED]",
+		"max_tokens": 100
+	}`, string(out))
+	assert.Equal(t, "sk-1", args.(map[string]interface{})["api_key"], "the input is not modified")
+
+	assert.JSONEq(t, `{"token": "[REDACTED]", "ok": true}`, r.Text(`{"token": "abc", "ok": true}`))
+	assert.Equal(t, "sent to [REDACTED].", r.Text("sent to bob@example.org."))
+
+	_, err = New(&models.RedactionRules{Patterns: []string{"("}})
+	assert.Error(t, err)
+	_, err = New(&models.RedactionRules{Paths: []string{"$.a["}})
+	assert.Error(t, err)
+}
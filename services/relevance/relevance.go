@@ -0,0 +1,171 @@
+// Package relevance ranks short documents, such as skill and tool descriptions, against free
+// text with Okapi BM25. Words are lowercased, stripped of common English suffixes and stop
+// words, so "Draws diagrams" and "drawing a diagram" share their terms.
+package relevance
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: term frequency saturation and document length normalization
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Index holds the term statistics of a fixed set of documents
+type Index struct {
+	docs   []document
+	df     map[string]int // Documents containing each term
+	avgLen float64
+}
+
+type document struct {
+	tf     map[string]int
+	terms  []string // Distinct terms in order of appearance
+	length int
+	self   float64 // Score of the document against its own terms
+}
+
+// Match is a document that shares terms with a query
+type Match struct {
+	Doc       int      // Position of the document in the index
+	Score     float64  // BM25 score
+	Relevance float64  // Score relative to the document's score against itself, from 0 to 1
+	Words     []string // Query words that matched, in the order they appear in the query
+}
+
+// NewIndex indexes the documents
+func NewIndex(docs []string) *Index {
+	ix := &Index{docs: make([]document, len(docs)), df: make(map[string]int)}
+	total := 0
+	for i, text := range docs {
+		d := document{tf: make(map[string]int)}
+		for _, w := range words(text) {
+			t := stem(w)
+			if d.tf[t] == 0 {
+				d.terms = append(d.terms, t)
+				ix.df[t]++
+			}
+			d.tf[t]++
+			d.length++
+		}
+		total += d.length
+		ix.docs[i] = d
+	}
+	if len(docs) > 0 {
+		ix.avgLen = float64(total) / float64(len(docs))
+	}
+	for i := range ix.docs {
+		for _, t := range ix.docs[i].terms {
+			ix.docs[i].self += ix.weight(&ix.docs[i], t)
+		}
+	}
+	return ix
+}
+
+// Len returns the number of documents
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Search returns the documents sharing terms with the query, highest score first. Ties keep
+// index order.
+func (ix *Index) Search(query string) []Match {
+	// Each distinct query term counts once, remembered by the first word it came from
+	var terms []string
+	surface := make(map[string]string)
+	for _, w := range words(query) {
+		t := stem(w)
+		if _, ok := surface[t]; !ok {
+			surface[t] = w
+			terms = append(terms, t)
+		}
+	}
+
+	var matches []Match
+	for i := range ix.docs {
+		d := &ix.docs[i]
+		m := Match{Doc: i}
+		for _, t := range terms {
+			if d.tf[t] == 0 {
+				continue
+			}
+			m.Score += ix.weight(d, t)
+			m.Words = append(m.Words, surface[t])
+		}
+		if len(m.Words) == 0 {
+			continue
+		}
+		if d.self > 0 {
+			m.Relevance = m.Score / d.self
+		}
+		matches = append(matches, m)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches
+}
+
+// weight is the BM25 contribution of term t, which d contains, to a query
+func (ix *Index) weight(d *document, t string) float64 {
+	n := float64(len(ix.docs))
+	df := float64(ix.df[t])
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	tf := float64(d.tf[t])
+	norm := 1.0
+	if ix.avgLen > 0 {
+		norm = 1 - b + b*float64(d.length)/ix.avgLen
+	}
+	return idf * tf * (k1 + 1) / (tf + k1*norm)
+}
+
+// words splits text into lowercase words, leaving out stop words and single characters
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len(f) > 1 && !stopWords[f] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// stem strips a common suffix, keeping at least three characters: "diagrams", "charting" and
+// "generated" become "diagram", "chart" and "generat"
+func stem(w string) string {
+	if strings.HasSuffix(w, "ies") && len(w) > 5 {
+		return w[:len(w)-3] + "y"
+	}
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 && !strings.HasSuffix(w, "ss") {
+			w = w[:len(w)-len(suffix)]
+			break
+		}
+	}
+	if strings.HasSuffix(w, "e") && len(w) > 3 {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+var stopWords = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(`about above after again all also am an and any are as at be
+		because been before being below between both but by can could did do does doing down during
+		each few for from further had has have having he her here hers him his how if in into is it
+		its just me more most my no nor not of off on once only or other our ours out over own same
+		she should so some such than that the their theirs them then there these they this those
+		through to too under until up very was we were what when where which while who whom why will
+		with would you your yours use using used`) {
+		m[w] = true
+	}
+	return m
+}()
@@ -0,0 +1,38 @@
+package relevance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	ix := NewIndex([]string{
+		"Generate diagrams, mind maps, flowcharts, and visual content from text descriptions. visual diagram chart graph draw flowchart",
+		"Current date and time, time zone conversion and date arithmetic. time date today timezone calendar",
+		"Evaluate arithmetic expressions exactly instead of estimating them. calculate math arithmetic percent",
+	})
+	require.Equal(t, 3, ix.Len())
+
+	matches := ix.Search("You create diagrams and flowcharts that explain processes")
+	require.Len(t, matches, 1)
+	assert.Equal(t, 0, matches[0].Doc)
+	assert.Equal(t, []string{"diagrams", "flowcharts"}, matches[0].Words)
+	strong := matches[0].Relevance
+
+	matches = ix.Search("Read the reports and draw conclusions about performance")
+	require.Len(t, matches, 1)
+	assert.Less(t, matches[0].Relevance, strong/2, "one incidental word is weak evidence")
+
+	matches = ix.Search("Calculate 15 percent of the date arithmetic")
+	require.Len(t, matches, 2)
+	assert.Equal(t, 2, matches[0].Doc, "the document sharing more terms ranks first")
+	assert.Greater(t, matches[0].Score, matches[1].Score)
+
+	assert.Empty(t, ix.Search("the and of"), "stop words match nothing")
+	assert.Empty(t, NewIndex(nil).Search("diagram"))
+	assert.Equal(t, stem("drawing"), stem("draws"))
+	assert.Equal(t, stem("image"), stem("images"))
+	assert.Equal(t, "query", stem("queries"))
+}
@@ -0,0 +1,129 @@
+// Package semver parses the MAJOR.MINOR.PATCH versions of skills and matches them against the
+// constraints agents pin skills with: an exact version, a partial version ("1", "1.2"), a caret
+// range ("^1.2") or a tilde range ("~1.2.3"). Pre-release and build suffixes are not supported.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a MAJOR.MINOR.PATCH version
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// Parse parses a full version, with or without a leading "v"
+func Parse(s string) (Version, error) {
+	nums, err := parseParts(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if len(nums) != 3 {
+		return Version{}, fmt.Errorf("version %q must have the form MAJOR.MINOR.PATCH", s)
+	}
+	return Version{nums[0], nums[1], nums[2]}, nil
+}
+
+// parseParts parses one to three dot-separated non-negative numbers
+func parseParts(s string) ([]int, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("version %q has more than three parts", s)
+	}
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || part != strconv.Itoa(n) {
+			return nil, fmt.Errorf("version %q is not a MAJOR.MINOR.PATCH number", s)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than o
+func (v Version) Compare(o Version) int {
+	for _, d := range [3]int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// BumpMajor, BumpMinor and BumpPatch return the next version of each kind
+func (v Version) BumpMajor() Version { return Version{v.Major + 1, 0, 0} }
+func (v Version) BumpMinor() Version { return Version{v.Major, v.Minor + 1, 0} }
+func (v Version) BumpPatch() Version { return Version{v.Major, v.Minor, v.Patch + 1} }
+
+// Constraint selects versions within [min, max)
+type Constraint struct {
+	raw      string
+	min, max Version
+	bounded  bool // false when there is no upper bound
+}
+
+// ParseConstraint parses a version constraint. An empty constraint or "latest" matches every
+// version.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	if s == "" || s == "latest" {
+		return c, nil
+	}
+
+	op := s[:1]
+	if op == "^" || op == "~" {
+		s = s[1:]
+	} else {
+		op = ""
+	}
+	nums, err := parseParts(s)
+	if err != nil {
+		return Constraint{}, fmt.Errorf("invalid constraint %q: %w", c.raw, err)
+	}
+	for len(nums) < 3 {
+		nums = append(nums, 0)
+	}
+	given := len(strings.Split(strings.TrimPrefix(s, "v"), "."))
+	c.min = Version{nums[0], nums[1], nums[2]}
+	c.bounded = true
+
+	switch {
+	case op == "" && given == 3:
+		c.max = c.min.BumpPatch()
+	case op == "~" && given >= 2, op == "" && given == 2:
+		c.max = c.min.BumpMinor()
+	case op == "^" && c.min.Major == 0 && given >= 2:
+		// ^0.x allows no minor changes, since 0.x minors may break
+		c.max = c.min.BumpMinor()
+	default:
+		c.max = c.min.BumpMajor()
+	}
+	return c, nil
+}
+
+// Match reports whether v satisfies the constraint
+func (c Constraint) Match(v Version) bool {
+	if !c.bounded {
+		return true
+	}
+	return v.Compare(c.min) >= 0 && v.Compare(c.max) < 0
+}
+
+// Exact reports whether the constraint names a single version
+func (c Constraint) Exact() bool {
+	return c.bounded && c.max == c.min.BumpPatch()
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
@@ -0,0 +1,65 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, Version{1, 2, 3}, v)
+	assert.Equal(t, "1.2.3", v.String())
+
+	for _, bad := range []string{"", "1.2", "1.2.3.4", "1.x.0", "1.-2.0", "01.2.3"} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+
+	assert.Equal(t, -1, Version{1, 2, 3}.Compare(Version{1, 10, 0}))
+	assert.Equal(t, 1, Version{2, 0, 0}.Compare(Version{1, 99, 99}))
+	assert.Equal(t, 0, Version{1, 2, 3}.Compare(Version{1, 2, 3}))
+}
+
+func TestConstraint(t *testing.T) {
+	cases := []struct {
+		constraint string
+		match      []string
+		miss       []string
+	}{
+		{"", []string{"0.0.1", "9.9.9"}, nil},
+		{"latest", []string{"1.0.0"}, nil},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4", "1.2.2"}},
+		{"1", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.9"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{"^1", []string{"1.0.0", "1.5.2"}, []string{"2.0.0"}},
+		{"^1.2", []string{"1.2.0", "1.9.0"}, []string{"1.1.9", "2.0.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.3.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.2", []string{"0.2.0", "0.2.7"}, []string{"0.3.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+	}
+	for _, tc := range cases {
+		c, err := ParseConstraint(tc.constraint)
+		require.NoError(t, err, tc.constraint)
+		for _, s := range tc.match {
+			v, _ := Parse(s)
+			assert.True(t, c.Match(v), "%s should match %s", tc.constraint, s)
+		}
+		for _, s := range tc.miss {
+			v, _ := Parse(s)
+			assert.False(t, c.Match(v), "%s should not match %s", tc.constraint, s)
+		}
+	}
+
+	exact, _ := ParseConstraint("1.2.3")
+	assert.True(t, exact.Exact())
+	caret, _ := ParseConstraint("^1.2.3")
+	assert.False(t, caret.Exact())
+
+	for _, bad := range []string{"^", "~x", ">=1.0.0", "1.2.3.4"} {
+		_, err := ParseConstraint(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -2,19 +2,89 @@ package services
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tas-agent-builder/models"
 )
 
-// SkillService manages skill CRUD and resolution for agents
+var (
+	// ErrSkillNotFound is returned when no skill visible to the caller has the requested ID or name
+	ErrSkillNotFound = errors.New("skill not found")
+
+	// ErrSkillExists is returned when the tenant already has a skill with the name
+	ErrSkillExists = errors.New("skill already exists")
+
+	// ErrSkillDisabled is returned when resolving a global skill the tenant disabled
+	ErrSkillDisabled = errors.New("skill is disabled for the tenant")
+
+	// ErrSkillNotGlobal is returned when enabling or disabling a skill that is not global
+	ErrSkillNotGlobal = errors.New("only global skills can be enabled or disabled for a tenant")
+
+	// ErrSkillUnavailable is returned when a skill's server is known to be down
+	ErrSkillUnavailable = errors.New("skill unavailable")
+
+	// ErrSkillVersionNotFound is returned when no version of a skill matches a reference
+	ErrSkillVersionNotFound = errors.New("skill version not found")
+
+	// ErrSkillVersionExists is returned when an update would redefine a recorded version
+	ErrSkillVersionExists = errors.New("skill version already exists")
+
+	// ErrSkillInUse is returned when deleting a skill that agents still reference
+	ErrSkillInUse = errors.New("skill is in use by agents")
+)
+
+// SkillService manages skill CRUD and resolution for agents. Skills are private to their owner,
+// shared with their tenant, or global; see models.Skill.VisibleTo.
 type SkillService interface {
+	// Create creates a skill in its tenant, private to its owner unless its visibility says
+	// otherwise. Names are unique per tenant.
 	Create(ctx context.Context, skill *models.Skill) error
+	// GetByID returns a skill whatever its visibility; callers check it with Skill.VisibleTo
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Skill, error)
-	GetByName(ctx context.Context, name string) (*models.Skill, error)
-	List(ctx context.Context, filter models.SkillListFilter) (*models.SkillListResponse, error)
+	// GetByName returns the skill with the name visible to the caller, preferring the tenant's
+	// own skills over global ones
+	GetByName(ctx context.Context, caller Caller, name string) (*models.Skill, error)
+	// List returns the skills visible to the caller, leaving out the global skills the tenant
+	// disabled unless filter.IncludeDisabled is set. A nil caller lists every tenant's skills.
+	List(ctx context.Context, caller *Caller, filter models.SkillListFilter) (*models.SkillListResponse, error)
+	// Update changes a skill. Changing its server, tools or version records a new immutable
+	// version, bumping the patch number when req.Version is not given.
 	Update(ctx context.Context, id uuid.UUID, req models.UpdateSkillRequest) (*models.Skill, error)
-	Delete(ctx context.Context, id uuid.UUID) error
-	ResolveForAgent(ctx context.Context, agent *models.Agent) ([]models.Skill, error)
+	// Delete deletes a skill. While agents reference it, it returns their usage with
+	// ErrSkillInUse unless force is set; with force it returns the usage it broke.
+	Delete(ctx context.Context, id uuid.UUID, force bool) ([]models.SkillUsage, error)
+	// Resolve returns the skill an agent's reference names, as GetByName finds it for the
+	// caller: the latest definition for a bare name, or the newest matching version for
+	// "name@constraint". Global skills the tenant disabled return ErrSkillDisabled.
+	Resolve(ctx context.Context, caller Caller, ref string) (*models.Skill, error)
+	// ResolveForAgent returns the skills offered to an execution of the agent with the given
+	// input: its assigned skills and the skills relevant to its system prompt or the input.
+	// Only skills visible to the agent's owner in its tenant are offered.
+	ResolveForAgent(ctx context.Context, agent *models.Agent, input string) ([]models.Skill, error)
+	// SetTenantEnabled enables or disables a global skill for the caller's tenant
+	SetTenantEnabled(ctx context.Context, caller Caller, id uuid.UUID, enabled bool) (*models.Skill, error)
 	SeedDefaults(ctx context.Context) error
+
+	// ListVersions returns a skill's versions, newest first
+	ListVersions(ctx context.Context, skillID uuid.UUID) ([]models.SkillVersion, error)
+	DeprecateVersion(ctx context.Context, skillID uuid.UUID, version string, req models.DeprecateSkillVersionRequest) (*models.SkillVersion, error)
+	// RecordToolSnapshot stores the tools discovered for a version that has none yet
+	RecordToolSnapshot(ctx context.Context, skillID uuid.UUID, version string, tools []models.SkillTool) error
+	// Usage lists the agents referencing the skill and the versions they resolve to
+	Usage(ctx context.Context, skill *models.Skill) ([]models.SkillUsage, error)
+}
+
+// SkillToolService discovers and invokes the tools of MCP, function and builtin skills. MCP
+// tool lists are cached per skill and skill servers are health-checked in the background.
+type SkillToolService interface {
+	// ListTools returns a skill's tools, limited to its mcp_tool_names; refresh bypasses the cache
+	ListTools(ctx context.Context, skill *models.Skill, refresh bool) (*models.SkillToolList, error)
+	// CallTool invokes a tool on the skill's server and returns its text result
+	CallTool(ctx context.Context, skill *models.Skill, name string, args map[string]interface{}) (string, error)
+	// Health returns the skill's latest health, checking it first if it never was
+	Health(ctx context.Context, skill *models.Skill) (*models.SkillHealth, error)
+	// RunHealthChecks checks every MCP skill each interval until ctx ends
+	RunHealthChecks(ctx context.Context, interval time.Duration)
 }
@@ -0,0 +1,197 @@
+package tokenizer
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// maxPieceBytes bounds the quadratic merge loop; longer pieces (e.g. base64 blobs) are merged in chunks
+	maxPieceBytes = 512
+
+	// pieceCacheLimit bounds the per-encoding cache of piece token counts
+	pieceCacheLimit = 100000
+
+	// pieceCacheMaxLen is the longest piece that is cached
+	pieceCacheMaxLen = 64
+
+	// largeInputThreshold is the input size above which counts are extrapolated from samples
+	largeInputThreshold = 512 * 1024
+
+	// sampleWindows and sampleWindowBytes control the fast path for large inputs
+	sampleWindows     = 16
+	sampleWindowBytes = 16 * 1024
+)
+
+// bpeTokenizer counts tokens using a tiktoken-format byte pair encoding vocabulary
+type bpeTokenizer struct {
+	encoding string
+	o200k    bool
+	ranks    map[string]int
+
+	mu    sync.RWMutex
+	cache map[string]int
+}
+
+func newBPE(encoding string, ranks map[string]int) *bpeTokenizer {
+	return &bpeTokenizer{
+		encoding: encoding,
+		o200k:    encoding == EncodingO200K,
+		ranks:    ranks,
+		cache:    make(map[string]int),
+	}
+}
+
+func (t *bpeTokenizer) Encoding() string { return t.encoding }
+
+func (t *bpeTokenizer) Exact() bool { return true }
+
+// Count returns the number of tokens in text. Inputs above largeInputThreshold are counted
+// on evenly spaced samples and extrapolated, which keeps very large documents cheap.
+func (t *bpeTokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	if len(text) > largeInputThreshold {
+		return sampleCount(text, t.countExact)
+	}
+	return t.countExact(text)
+}
+
+func (t *bpeTokenizer) countExact(text string) int {
+	total := 0
+	splitPieces(text, t.o200k, func(piece string) {
+		total += t.countPiece(piece)
+	})
+	return total
+}
+
+// countPiece returns the number of tokens in a single pre-tokenized piece
+func (t *bpeTokenizer) countPiece(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+
+	cacheable := len(piece) <= pieceCacheMaxLen
+	if cacheable {
+		t.mu.RLock()
+		n, ok := t.cache[piece]
+		t.mu.RUnlock()
+		if ok {
+			return n
+		}
+	}
+
+	n := 0
+	for start := 0; start < len(piece); start += maxPieceBytes {
+		end := start + maxPieceBytes
+		if end > len(piece) {
+			end = len(piece)
+		}
+		n += len(t.merge(piece[start:end]))
+	}
+
+	if cacheable {
+		t.mu.Lock()
+		if len(t.cache) >= pieceCacheLimit {
+			t.cache = make(map[string]int)
+		}
+		t.cache[piece] = n
+		t.mu.Unlock()
+	}
+	return n
+}
+
+// encode returns the token ids for text
+func (t *bpeTokenizer) encode(text string) []int {
+	var ids []int
+	splitPieces(text, t.o200k, func(piece string) {
+		ids = append(ids, t.encodePiece(piece)...)
+	})
+	return ids
+}
+
+// encodePiece returns the token ids for a piece
+func (t *bpeTokenizer) encodePiece(piece string) []int {
+	if rank, ok := t.ranks[piece]; ok {
+		return []int{rank}
+	}
+
+	parts := t.merge(piece)
+	ids := make([]int, 0, len(parts))
+	for _, part := range parts {
+		if rank, ok := t.ranks[part]; ok {
+			ids = append(ids, rank)
+		} else {
+			ids = append(ids, -1)
+		}
+	}
+	return ids
+}
+
+// merge applies byte pair merges in rank order, lowest rank first, and returns the resulting parts
+func (t *bpeTokenizer) merge(piece string) []string {
+	// boundaries[k] is the byte offset where part k starts; the last entry is len(piece)
+	boundaries := make([]int, len(piece)+1)
+	for k := range boundaries {
+		boundaries[k] = k
+	}
+
+	for len(boundaries) > 2 {
+		bestRank, bestIdx := math.MaxInt, -1
+		for k := 0; k+2 < len(boundaries); k++ {
+			if rank, ok := t.ranks[piece[boundaries[k]:boundaries[k+2]]]; ok && rank < bestRank {
+				bestRank, bestIdx = rank, k
+			}
+		}
+		if bestIdx < 0 {
+			break
+		}
+		boundaries = append(boundaries[:bestIdx+1], boundaries[bestIdx+2:]...)
+	}
+
+	parts := make([]string, 0, len(boundaries)-1)
+	for k := 0; k+1 < len(boundaries); k++ {
+		parts = append(parts, piece[boundaries[k]:boundaries[k+1]])
+	}
+	return parts
+}
+
+// sampleCount counts evenly spaced windows of text and scales the result to the full length.
+// Window edges are moved to the next whitespace so words are not split.
+func sampleCount(text string, count func(string) int) int {
+	stride := len(text) / sampleWindows
+	sampledBytes, sampledTokens := 0, 0
+
+	for w := 0; w < sampleWindows; w++ {
+		start := alignToSpace(text, w*stride)
+		end := alignToSpace(text, start+sampleWindowBytes)
+		if start >= end {
+			continue
+		}
+		sampledBytes += end - start
+		sampledTokens += count(text[start:end])
+	}
+
+	if sampledBytes == 0 {
+		return count(text)
+	}
+	return int(math.Ceil(float64(sampledTokens) * float64(len(text)) / float64(sampledBytes)))
+}
+
+// alignToSpace returns the first offset at or after i that starts a whitespace run, capped at len(text)
+func alignToSpace(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	for limit := i + 256; i < len(text) && i < limit; i++ {
+		if text[i] == ' ' || text[i] == '\n' {
+			return i
+		}
+	}
+	// No nearby whitespace; advance to a UTF-8 boundary
+	for i < len(text) && i > 0 && text[i]&0xC0 == 0x80 {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,19 @@
+package tokenizer
+
+import "context"
+
+type contextKey struct{}
+
+// WithTokenizer returns a copy of ctx carrying tok, so services counting tokens while handling
+// a request use the encoding of the model the request is for
+func WithTokenizer(ctx context.Context, tok Tokenizer) context.Context {
+	return context.WithValue(ctx, contextKey{}, tok)
+}
+
+// FromContext returns the tokenizer carried by ctx, or the default encoding's when there is none
+func FromContext(ctx context.Context) Tokenizer {
+	if tok, ok := ctx.Value(contextKey{}).(Tokenizer); ok && tok != nil {
+		return tok
+	}
+	return Get(DefaultEncoding)
+}
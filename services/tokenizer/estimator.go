@@ -0,0 +1,95 @@
+package tokenizer
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// estimatorRates holds approximate per-piece token rates for an encoding
+type estimatorRates struct {
+	wordRunesPerToken   float64 // Latin-script letters and digits per token
+	cjkTokensPerRune    float64 // Han, Kana and Hangul
+	scriptRunesPerToken float64 // Other non-Latin scripts (Cyrillic, Arabic, Devanagari, ...)
+	punctRunesPerToken  float64
+}
+
+var (
+	cl100kRates = estimatorRates{wordRunesPerToken: 8, cjkTokensPerRune: 1.1, scriptRunesPerToken: 2.2, punctRunesPerToken: 2.5}
+	o200kRates  = estimatorRates{wordRunesPerToken: 9, cjkTokensPerRune: 0.75, scriptRunesPerToken: 3.5, punctRunesPerToken: 3}
+)
+
+// estimator approximates BPE counts when no vocabulary is embedded. It uses the same
+// pre-tokenizer as the BPE path, so every piece costs at least one token and only the
+// cost of long words and non-Latin scripts is estimated.
+type estimator struct {
+	encoding string
+	o200k    bool
+	rates    estimatorRates
+}
+
+func newEstimator(encoding string) *estimator {
+	rates := cl100kRates
+	if encoding == EncodingO200K {
+		rates = o200kRates
+	}
+	return &estimator{
+		encoding: encoding,
+		o200k:    encoding == EncodingO200K,
+		rates:    rates,
+	}
+}
+
+func (e *estimator) Encoding() string { return e.encoding }
+
+func (e *estimator) Exact() bool { return false }
+
+// Count returns the estimated number of tokens in text
+func (e *estimator) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	var total float64
+	splitPieces(text, e.o200k, func(piece string) {
+		total += e.estimatePiece(piece)
+	})
+	return int(math.Ceil(total))
+}
+
+// estimatePiece estimates the token cost of one pre-tokenized piece
+func (e *estimator) estimatePiece(piece string) float64 {
+	var latin, cjk, script, punct, space int
+	for _, r := range piece {
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsNumber(r)):
+			latin++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			if unicode.Is(unicode.Latin, r) {
+				latin++
+			} else {
+				script++
+			}
+		case unicode.IsSpace(r):
+			space++
+		default:
+			punct++
+		}
+	}
+
+	var tokens float64
+	if latin > 0 {
+		tokens += math.Max(1, float64(latin)/e.rates.wordRunesPerToken)
+	}
+	tokens += float64(cjk) * e.rates.cjkTokensPerRune
+	tokens += float64(script) / e.rates.scriptRunesPerToken
+	if punct > 0 {
+		tokens += math.Max(1, float64(punct)/e.rates.punctRunesPerToken)
+	}
+	if tokens == 0 && space > 0 {
+		tokens = 1 // Whitespace runs and newlines are single tokens
+	}
+	return math.Max(tokens, 1)
+}
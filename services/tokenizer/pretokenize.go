@@ -0,0 +1,197 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// splitPieces walks text the way the cl100k/o200k pre-tokenizer regexes do and calls fn for
+// each piece. Go's regexp has no lookahead, so the patterns are implemented by hand:
+//
+//	cl100k: 's|'t|'re|'ve|'m|'ll|'d | [^\r\n\p{L}\p{N}]?\p{L}+ | \p{N}{1,3} |
+//	        ?[^\s\p{L}\p{N}]+[\r\n]* | \s*[\r\n]+ | \s+(?!\S) | \s+
+//
+// o200k additionally splits letter runs at lower-to-upper case changes, attaches
+// contractions to the preceding word and lets punctuation absorb trailing slashes.
+func splitPieces(text string, o200k bool, fn func(piece string)) {
+	i := 0
+	for i < len(text) {
+		end := nextPiece(text, i, o200k)
+		fn(text[i:end])
+		i = end
+	}
+}
+
+// nextPiece returns the end offset of the piece starting at i
+func nextPiece(text string, i int, o200k bool) int {
+	r, size := utf8.DecodeRuneInString(text[i:])
+	next, nextSize := rune(-1), 0
+	if i+size < len(text) {
+		next, nextSize = utf8.DecodeRuneInString(text[i+size:])
+	}
+
+	// Contractions ('s, 't, 're, 've, 'm, 'll, 'd); o200k attaches them to words instead
+	if !o200k && r == '\'' {
+		if end := matchContraction(text, i); end > i {
+			return end
+		}
+	}
+
+	// Letters with an optional leading non-letter, non-digit, non-newline character
+	if isLetter(r) {
+		return scanLetters(text, i, o200k)
+	}
+	if !isNewline(r) && !isNumber(r) && isLetter(next) {
+		return scanLetters(text, i+size, o200k)
+	}
+
+	// Numbers in groups of up to three digits
+	if isNumber(r) {
+		end := i + size
+		for n := 1; n < 3 && end < len(text); n++ {
+			d, dsize := utf8.DecodeRuneInString(text[end:])
+			if !isNumber(d) {
+				break
+			}
+			end += dsize
+		}
+		return end
+	}
+
+	// Punctuation with an optional leading space and trailing newlines
+	if isPunct(r) || (r == ' ' && isPunct(next)) {
+		end := i + size
+		if r == ' ' {
+			end += nextSize
+		}
+		for end < len(text) {
+			p, psize := utf8.DecodeRuneInString(text[end:])
+			if !isPunct(p) {
+				break
+			}
+			end += psize
+		}
+		for end < len(text) {
+			p, psize := utf8.DecodeRuneInString(text[end:])
+			if !isNewline(p) && !(o200k && p == '/') {
+				break
+			}
+			end += psize
+		}
+		return end
+	}
+
+	// Whitespace
+	runEnd, lastNewlineEnd, lastSpaceStart := i, -1, i
+	for runEnd < len(text) {
+		w, wsize := utf8.DecodeRuneInString(text[runEnd:])
+		if !unicode.IsSpace(w) {
+			break
+		}
+		lastSpaceStart = runEnd
+		runEnd += wsize
+		if isNewline(w) {
+			lastNewlineEnd = runEnd
+		}
+	}
+	if lastNewlineEnd > 0 {
+		return lastNewlineEnd // \s*[\r\n]+
+	}
+	if runEnd < len(text) && lastSpaceStart > i {
+		return lastSpaceStart // \s+(?!\S): leave the last space for the next word
+	}
+	if runEnd > i {
+		return runEnd // \s+
+	}
+
+	// Anything else (e.g. control characters) is its own piece
+	return i + size
+}
+
+// scanLetters scans a letter run starting at i
+func scanLetters(text string, i int, o200k bool) int {
+	if !o200k {
+		end := i
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !isLetter(r) {
+				break
+			}
+			end += size
+		}
+		return end
+	}
+
+	// [Upper]*[lower]+ | [Upper]+[lower]*, followed by an optional contraction
+	end := i
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !isUpperClass(r) {
+			break
+		}
+		end += size
+	}
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !isLowerClass(r) {
+			break
+		}
+		end += size
+	}
+	if end < len(text) && text[end] == '\'' {
+		if cEnd := matchContraction(text, end); cEnd > end {
+			end = cEnd
+		}
+	}
+	return end
+}
+
+// matchContraction returns the end of a contraction starting at the apostrophe at i, or i
+func matchContraction(text string, i int) int {
+	rest := text[i+1:]
+	for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		if len(rest) >= len(suffix) && equalFoldASCII(rest[:len(suffix)], suffix) {
+			return i + 1 + len(suffix)
+		}
+	}
+	return i
+}
+
+func equalFoldASCII(a, b string) bool {
+	for k := 0; k < len(a); k++ {
+		c := a[k]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(r rune) bool {
+	return r >= 0 && unicode.IsLetter(r)
+}
+
+func isNumber(r rune) bool {
+	return r >= 0 && unicode.IsNumber(r)
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+func isPunct(r rune) bool {
+	return r >= 0 && !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isUpperClass matches [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperClass(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerClass matches [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerClass(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
@@ -0,0 +1,105 @@
+// Package tokenizer counts tokens the way OpenAI-style BPE encoders do.
+//
+// Vocabularies in tiktoken format are embedded from the vocab directory. When a
+// vocabulary is not embedded, a pre-tokenizer based estimator is used instead; it
+// follows the same splitting rules so code, numbers and non-English text are
+// counted far more closely than a characters/4 heuristic.
+//
+// Handlers put the agent model's tokenizer on the request context with
+// WithTokenizer; services count with FromContext.
+package tokenizer
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// Supported encodings
+const (
+	EncodingCL100K = "cl100k_base"
+	EncodingO200K  = "o200k_base"
+
+	// DefaultEncoding is used when the model is unknown
+	DefaultEncoding = EncodingCL100K
+)
+
+// Tokenizer counts tokens for a single encoding. Implementations are safe for concurrent use.
+type Tokenizer interface {
+	// Count returns the number of tokens in text
+	Count(text string) int
+
+	// Encoding returns the encoding name (e.g. "cl100k_base")
+	Encoding() string
+
+	// Exact reports whether counts come from the BPE vocabulary rather than the estimator
+	Exact() bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Tokenizer)
+)
+
+// Get returns the tokenizer for an encoding, loading its vocabulary on first use.
+// Unknown encodings fall back to DefaultEncoding.
+func Get(encoding string) Tokenizer {
+	if !isSupported(encoding) {
+		encoding = DefaultEncoding
+	}
+
+	registryMu.RLock()
+	tok, ok := registry[encoding]
+	registryMu.RUnlock()
+	if ok {
+		return tok
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if tok, ok := registry[encoding]; ok {
+		return tok
+	}
+
+	ranks, err := loadEmbeddedVocabulary(encoding)
+	if err != nil {
+		log.Printf("[TOKENIZER] Vocabulary for %s not available, using estimator: %v", encoding, err)
+		tok = newEstimator(encoding)
+	} else {
+		tok = newBPE(encoding, ranks)
+	}
+	registry[encoding] = tok
+	return tok
+}
+
+// ForModel returns the tokenizer for a model name
+func ForModel(model string) Tokenizer {
+	return Get(EncodingForModel(model))
+}
+
+// Count counts tokens using the default encoding
+func Count(text string) int {
+	return Get(DefaultEncoding).Count(text)
+}
+
+// EncodingForModel maps a model name to its encoding. Models from providers that do not
+// publish their tokenizer (Anthropic, Google, open-weight models) use cl100k as the
+// closest approximation.
+func EncodingForModel(model string) string {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	for _, prefix := range []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "gpt-oss", "o1", "o3", "o4"} {
+		if strings.HasPrefix(name, prefix) {
+			return EncodingO200K
+		}
+	}
+	return EncodingCL100K
+}
+
+func isSupported(encoding string) bool {
+	return encoding == EncodingCL100K || encoding == EncodingO200K
+}
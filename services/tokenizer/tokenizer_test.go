@@ -0,0 +1,153 @@
+package tokenizer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pieces(text string, o200k bool) []string {
+	var result []string
+	splitPieces(text, o200k, func(piece string) {
+		result = append(result, piece)
+	})
+	return result
+}
+
+func TestSplitPieces(t *testing.T) {
+	t.Run("cl100k", func(t *testing.T) {
+		assert.Equal(t, []string{"Hello", " world"}, pieces("Hello world", false))
+		assert.Equal(t, []string{"I", "'m", " ", "123", "45", " ok", "!!\n\n"}, pieces("I'm 12345 ok!!\n\n", false))
+		assert.Equal(t, []string{"func", " main", "()", " {\n", "\treturn", "\n", "}"}, pieces("func main() {\n\treturn\n}", false))
+		assert.Equal(t, []string{"a", "  ", " b", "   "}, pieces("a   b   ", false))
+		assert.Equal(t, []string{"helloWorld"}, pieces("helloWorld", false))
+	})
+
+	t.Run("o200k", func(t *testing.T) {
+		assert.Equal(t, []string{"hello", "World"}, pieces("helloWorld", true))
+		assert.Equal(t, []string{"I'm", " fine"}, pieces("I'm fine", true))
+		assert.Equal(t, []string{"http", "://", "example", ".com"}, pieces("http://example.com", true))
+	})
+
+	t.Run("pieces cover the input", func(t *testing.T) {
+		for _, text := range []string{"", "日本語のテキスト", "mixed 日本 text, 123!", "\r\n\r\n  x", string([]byte{0xff, 'a', 0xfe})} {
+			assert.Equal(t, text, strings.Join(pieces(text, false), ""))
+			assert.Equal(t, text, strings.Join(pieces(text, true), ""))
+		}
+	})
+}
+
+// testVocabulary builds a tiktoken-format vocabulary with every byte plus a few merges
+func testVocabulary(merges ...string) string {
+	var b strings.Builder
+	rank := 0
+	for c := 0; c < 256; c++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(c)}), rank)
+		rank++
+	}
+	for _, m := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), rank)
+		rank++
+	}
+	return b.String()
+}
+
+func TestBPETokenizer(t *testing.T) {
+	ranks, err := parseVocabulary(strings.NewReader(testVocabulary("ab", "abc", " ab")))
+	require.NoError(t, err)
+	tok := newBPE(EncodingCL100K, ranks)
+
+	assert.Equal(t, []int{257}, tok.encodePiece("abc"))
+	assert.Equal(t, []int{257, 256}, tok.encodePiece("abcab"))
+	assert.Equal(t, []int{int('x'), int('y')}, tok.encodePiece("xy"))
+
+	// "abcab" -> [abc][ab], " ab" -> [ ab]
+	assert.Equal(t, 3, tok.Count("abcab ab"))
+	assert.Equal(t, 0, tok.Count(""))
+	assert.True(t, tok.Exact())
+}
+
+func TestEmbeddedVocabularies(t *testing.T) {
+	cases := []struct {
+		encoding string
+		text     string
+		ids      []int
+	}{
+		{EncodingCL100K, "hello world", []int{15339, 1917}},
+		{EncodingCL100K, "tiktoken is great!", []int{83, 1609, 5963, 374, 2294, 0}},
+		{EncodingO200K, "hello world", []int{24912, 2375}},
+		{EncodingO200K, "tiktoken is great!", []int{83, 8251, 2488, 382, 2212, 0}},
+	}
+	for _, tc := range cases {
+		tok, ok := Get(tc.encoding).(*bpeTokenizer)
+		require.True(t, ok, "%s vocabulary is not embedded", tc.encoding)
+		assert.Equal(t, tc.ids, tok.encode(tc.text), "%s: %q", tc.encoding, tc.text)
+		assert.Equal(t, len(tc.ids), tok.Count(tc.text))
+	}
+}
+
+func TestRegister(t *testing.T) {
+	require.NoError(t, Register(EncodingO200K, strings.NewReader(testVocabulary("hello"))))
+	defer func() {
+		registryMu.Lock()
+		delete(registry, EncodingO200K)
+		registryMu.Unlock()
+	}()
+
+	tok := ForModel("gpt-4o-mini")
+	assert.Equal(t, EncodingO200K, tok.Encoding())
+	assert.True(t, tok.Exact())
+	assert.Equal(t, 1, tok.Count("hello"))
+
+	assert.Error(t, Register("p50k_base", strings.NewReader(testVocabulary())))
+	assert.Error(t, Register(EncodingO200K, strings.NewReader("not-base64!! x")))
+}
+
+func TestEstimator(t *testing.T) {
+	tok := newEstimator(EncodingCL100K)
+
+	assert.Equal(t, 0, tok.Count(""))
+	assert.Equal(t, 2, tok.Count("Hello world"))
+	assert.False(t, tok.Exact())
+
+	// Code and non-English text need far more tokens than len/4 suggests
+	code := `if (x[i] != nil && y["k"] >= 10) { return; }`
+	assert.Greater(t, tok.Count(code), len(code)/4)
+
+	chinese := "这是一个用于测试的中文句子"
+	assert.Greater(t, tok.Count(chinese), len(chinese)/4)
+
+	// o200k packs non-Latin scripts more densely than cl100k
+	assert.Less(t, newEstimator(EncodingO200K).Count(chinese), tok.Count(chinese))
+}
+
+func TestSampleCount(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20000)
+	require.Greater(t, len(text), largeInputThreshold)
+
+	exact := newEstimator(EncodingCL100K).Count(text)
+	sampled := sampleCount(text, newEstimator(EncodingCL100K).Count)
+	assert.InDelta(t, exact, sampled, float64(exact)*0.02)
+}
+
+func TestEncodingForModel(t *testing.T) {
+	assert.Equal(t, EncodingO200K, EncodingForModel("gpt-4o"))
+	assert.Equal(t, EncodingO200K, EncodingForModel("openai/o3-mini"))
+	assert.Equal(t, EncodingCL100K, EncodingForModel("gpt-4-turbo"))
+	assert.Equal(t, EncodingCL100K, EncodingForModel("gpt-3.5-turbo"))
+	assert.Equal(t, EncodingCL100K, EncodingForModel("claude-3-5-sonnet"))
+	assert.Equal(t, EncodingCL100K, EncodingForModel(""))
+
+	assert.Equal(t, DefaultEncoding, Get("unknown").Encoding())
+}
+
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, DefaultEncoding, FromContext(ctx).Encoding())
+	assert.Equal(t, EncodingO200K, FromContext(WithTokenizer(ctx, ForModel("gpt-4o"))).Encoding())
+}
@@ -0,0 +1,89 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// vocabFS holds the tiktoken vocabularies, named <encoding>.tiktoken or <encoding>.tiktoken.gz
+//
+//go:embed vocab
+var vocabFS embed.FS
+
+// Register parses a tiktoken-format vocabulary ("<base64 token> <rank>" per line) and makes it
+// the tokenizer for the encoding, replacing any embedded vocabulary or estimator.
+func Register(encoding string, vocab io.Reader) error {
+	if !isSupported(encoding) {
+		return fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+
+	ranks, err := parseVocabulary(vocab)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s vocabulary: %w", encoding, err)
+	}
+
+	registryMu.Lock()
+	registry[encoding] = newBPE(encoding, ranks)
+	registryMu.Unlock()
+	return nil
+}
+
+// loadEmbeddedVocabulary reads an encoding's vocabulary from vocabFS
+func loadEmbeddedVocabulary(encoding string) (map[string]int, error) {
+	if data, err := vocabFS.ReadFile("vocab/" + encoding + ".tiktoken.gz"); err == nil {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open compressed vocabulary: %w", err)
+		}
+		defer gz.Close()
+		return parseVocabulary(gz)
+	}
+
+	data, err := vocabFS.ReadFile("vocab/" + encoding + ".tiktoken")
+	if err != nil {
+		return nil, fmt.Errorf("vocabulary not embedded: %w", err)
+	}
+	return parseVocabulary(bytes.NewReader(data))
+}
+
+// parseVocabulary decodes tiktoken-format lines into a token -> rank map
+func parseVocabulary(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected token and rank", line)
+		}
+
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid token: %w", line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rank: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("vocabulary is empty")
+	}
+
+	return ranks, nil
+}
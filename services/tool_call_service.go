@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tas-agent-builder/models"
+)
+
+// ToolCallAuditService keeps the audit log of tool calls made during executions
+type ToolCallAuditService interface {
+	// Record stores a call after applying the skill's redaction rules and truncating its
+	// result. The skill fills in the record's skill and server and may be nil.
+	Record(ctx context.Context, skill *models.Skill, call *models.ExecutionToolCall) error
+	// ListForExecution returns an execution's calls in the order they were made
+	ListForExecution(ctx context.Context, executionID uuid.UUID, userID uuid.UUID) ([]models.ExecutionToolCall, error)
+	// Search returns a page of a tenant's calls, newest first
+	Search(ctx context.Context, filter models.ToolCallSearchFilter) (*models.ToolCallListResponse, error)
+}
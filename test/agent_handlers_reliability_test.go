@@ -20,45 +20,46 @@ import (
 
 // MockAgentService is a mock implementation of the AgentService interface
 type MockAgentService struct {
+	services.AgentService
 	mock.Mock
 }
 
-func (m *MockAgentService) CreateAgent(ctx context.Context, req models.CreateAgentRequest, ownerID uuid.UUID, tenantID string) (*models.Agent, error) {
+func (m *MockAgentService) CreateAgent(ctx context.Context, req models.CreateAgentRequest, ownerID string, tenantID string) (*models.Agent, error) {
 	args := m.Called(ctx, req, ownerID, tenantID)
 	return args.Get(0).(*models.Agent), args.Error(1)
 }
 
-func (m *MockAgentService) GetAgent(ctx context.Context, agentID, userID uuid.UUID) (*models.Agent, error) {
+func (m *MockAgentService) GetAgent(ctx context.Context, agentID uuid.UUID, userID string) (*models.Agent, error) {
 	args := m.Called(ctx, agentID, userID)
 	return args.Get(0).(*models.Agent), args.Error(1)
 }
 
-func (m *MockAgentService) UpdateAgent(ctx context.Context, agentID uuid.UUID, req models.UpdateAgentRequest, ownerID uuid.UUID) (*models.Agent, error) {
+func (m *MockAgentService) UpdateAgent(ctx context.Context, agentID uuid.UUID, req models.UpdateAgentRequest, ownerID string) (*models.Agent, error) {
 	args := m.Called(ctx, agentID, req, ownerID)
 	return args.Get(0).(*models.Agent), args.Error(1)
 }
 
-func (m *MockAgentService) DeleteAgent(ctx context.Context, agentID, ownerID uuid.UUID) error {
+func (m *MockAgentService) DeleteAgent(ctx context.Context, agentID uuid.UUID, ownerID string) error {
 	args := m.Called(ctx, agentID, ownerID)
 	return args.Error(0)
 }
 
-func (m *MockAgentService) ListAgents(ctx context.Context, filter models.AgentListFilter, userID uuid.UUID) (*models.AgentListResponse, error) {
+func (m *MockAgentService) ListAgents(ctx context.Context, filter models.AgentListFilter, userID string) (*models.AgentListResponse, error) {
 	args := m.Called(ctx, filter, userID)
 	return args.Get(0).(*models.AgentListResponse), args.Error(1)
 }
 
-func (m *MockAgentService) PublishAgent(ctx context.Context, agentID, ownerID uuid.UUID) error {
+func (m *MockAgentService) PublishAgent(ctx context.Context, agentID uuid.UUID, ownerID string) error {
 	args := m.Called(ctx, agentID, ownerID)
 	return args.Error(0)
 }
 
-func (m *MockAgentService) UnpublishAgent(ctx context.Context, agentID, ownerID uuid.UUID) error {
+func (m *MockAgentService) UnpublishAgent(ctx context.Context, agentID uuid.UUID, ownerID string) error {
 	args := m.Called(ctx, agentID, ownerID)
 	return args.Error(0)
 }
 
-func (m *MockAgentService) DuplicateAgent(ctx context.Context, sourceID uuid.UUID, newName string, userID uuid.UUID, tenantID string) (*models.Agent, error) {
+func (m *MockAgentService) DuplicateAgent(ctx context.Context, sourceID uuid.UUID, newName string, userID string, tenantID string) (*models.Agent, error) {
 	args := m.Called(ctx, sourceID, newName, userID, tenantID)
 	return args.Get(0).(*models.Agent), args.Error(1)
 }
@@ -70,6 +71,7 @@ func (m *MockAgentService) GetReliabilityMetrics(ctx context.Context, agentID uu
 
 // MockRouterService is a mock implementation of the RouterService interface
 type MockRouterService struct {
+	services.RouterService
 	mock.Mock
 }
 
@@ -93,6 +95,11 @@ func (m *MockRouterService) GetProviderModels(ctx context.Context, provider stri
 	return args.Get(0).([]services.Model), args.Error(1)
 }
 
+// GetModelCapabilities knows no models, so executions plan with the default context window
+func (m *MockRouterService) GetModelCapabilities(ctx context.Context, agentConfig models.AgentLLMConfig) (*services.ModelCapabilities, error) {
+	return nil, services.ErrModelNotFound
+}
+
 // TestAgentHandlersReliabilityFeatures tests the enhanced agent handlers
 func TestAgentHandlersReliabilityFeatures(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -100,12 +107,12 @@ func TestAgentHandlersReliabilityFeatures(t *testing.T) {
 	mockAgentService := new(MockAgentService)
 	mockRouterService := new(MockRouterService)
 	
-	h := handlers.NewAgentHandlers(mockAgentService, mockRouterService, nil, nil, nil, nil, nil, nil, false, 10)
+	h := handlers.NewAgentHandlers(mockAgentService, mockRouterService, nil, nil, nil, nil, nil, nil, nil, nil, false, 10, 0, 0, 0)
 
 	t.Run("CreateAgent with reliability configuration validation", func(t *testing.T) {
 		// Setup mocks
 		mockRouterService.On("ValidateConfig", mock.Anything, mock.AnythingOfType("models.AgentLLMConfig")).Return(nil)
-		mockAgentService.On("CreateAgent", mock.Anything, mock.AnythingOfType("models.CreateAgentRequest"), mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("string")).Return(&models.Agent{
+		mockAgentService.On("CreateAgent", mock.Anything, mock.AnythingOfType("models.CreateAgentRequest"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(&models.Agent{
 			ID:   uuid.New(),
 			Name: "Test Agent",
 			LLMConfig: models.AgentLLMConfig{
@@ -136,7 +143,7 @@ func TestAgentHandlersReliabilityFeatures(t *testing.T) {
 					MaxCostIncrease: floatPtr(0.5),
 				},
 			},
-			SpaceID: uuid.New(),
+			SpaceID: uuid.New().String(),
 		}
 
 		jsonBody, _ := json.Marshal(requestBody)
@@ -199,7 +206,7 @@ func TestAgentHandlersReliabilityFeatures(t *testing.T) {
 					BackoffType: "linear",
 				},
 			},
-			SpaceID: uuid.New(),
+			SpaceID: uuid.New().String(),
 		}
 
 		jsonBody, _ := json.Marshal(requestBody)
@@ -243,7 +250,7 @@ func TestAgentHandlersReliabilityFeatures(t *testing.T) {
 					MaxAttempts: 10, // Invalid - too high
 				},
 			},
-			SpaceID: uuid.New(),
+			SpaceID: uuid.New().String(),
 		}
 
 		jsonBody, _ := json.Marshal(requestBody)
@@ -274,26 +281,12 @@ func TestAgentHandlersReliabilityFeatures(t *testing.T) {
 		userID := uuid.New()
 		
 		// Setup mocks
-		mockAgentService.On("GetAgent", mock.Anything, agentID, userID).Return(&models.Agent{
+		mockAgentService.On("GetAgent", mock.Anything, agentID, userID.String()).Return(&models.Agent{
 			ID:      agentID,
 			Name:    "Test Agent",
-			OwnerID: userID,
+			OwnerID: userID.String(),
 		}, nil)
 
-		// Mock reliability metrics response
-		expectedMetrics := map[string]interface{}{
-			"agent_id":              agentID.String(),
-			"reliability_score":     0.95,
-			"total_executions":      100,
-			"successful_executions": 95,
-			"failed_executions":     5,
-			"avg_retry_attempts":    0.2,
-			"fallback_usage_rate":   0.05,
-			"avg_response_time_ms":  250,
-		}
-		
-		mockAgentService.On("GetReliabilityMetrics", mock.Anything, agentID).Return(expectedMetrics, nil)
-
 		req, _ := http.NewRequest("GET", "/agents/"+agentID.String()+"/reliability", nil)
 		
 		w := httptest.NewRecorder()
@@ -411,13 +404,11 @@ func TestRetryConfigValidationInHandler(t *testing.T) {
 			mockAgentService := new(MockAgentService)
 			mockRouterService := new(MockRouterService)
 			
-			h := handlers.NewAgentHandlers(mockAgentService, mockRouterService, nil, nil, nil, nil, nil, nil, false, 10)
+			h := handlers.NewAgentHandlers(mockAgentService, mockRouterService, nil, nil, nil, nil, nil, nil, nil, nil, false, 10, 0, 0, 0)
 
-			// Only setup router validation mock if config should be valid
-			if tt.expectValid {
-				mockRouterService.On("ValidateConfig", mock.Anything, mock.AnythingOfType("models.AgentLLMConfig")).Return(nil)
-				mockRouterService.On("GetAvailableProviders", mock.Anything).Return([]services.Provider{}, nil)
-			}
+			// The router accepts the model; the handler rejects the retry config itself
+			mockRouterService.On("ValidateConfig", mock.Anything, mock.AnythingOfType("models.AgentLLMConfig")).Return(nil)
+			mockRouterService.On("GetAvailableProviders", mock.Anything).Return([]services.Provider{}, nil).Maybe()
 
 			requestBody := models.CreateAgentRequest{
 				Name:         "Test Agent",
@@ -427,7 +418,7 @@ func TestRetryConfigValidationInHandler(t *testing.T) {
 					Model:      "gpt-3.5-turbo",
 					RetryConfig: tt.retryConfig,
 				},
-				SpaceID: uuid.New(),
+				SpaceID: uuid.New().String(),
 			}
 
 			jsonBody, _ := json.Marshal(requestBody)
@@ -501,10 +492,11 @@ func TestFallbackConfigValidationInHandler(t *testing.T) {
 			mockAgentService := new(MockAgentService)
 			mockRouterService := new(MockRouterService)
 			
-			h := handlers.NewAgentHandlers(mockAgentService, mockRouterService, nil, nil, nil, nil, nil, nil, false, 10)
+			h := handlers.NewAgentHandlers(mockAgentService, mockRouterService, nil, nil, nil, nil, nil, nil, nil, nil, false, 10, 0, 0, 0)
 
+			// The router accepts the model; the handler rejects the fallback config itself
+			mockRouterService.On("ValidateConfig", mock.Anything, mock.AnythingOfType("models.AgentLLMConfig")).Return(nil)
 			if tt.expectValid {
-				mockRouterService.On("ValidateConfig", mock.Anything, mock.AnythingOfType("models.AgentLLMConfig")).Return(nil)
 				mockRouterService.On("GetAvailableProviders", mock.Anything).Return([]services.Provider{
 					{Name: "openai"}, {Name: "anthropic"},
 				}, nil)
@@ -518,7 +510,7 @@ func TestFallbackConfigValidationInHandler(t *testing.T) {
 					Model:         "gpt-3.5-turbo",
 					FallbackConfig: tt.fallbackConfig,
 				},
-				SpaceID: uuid.New(),
+				SpaceID: uuid.New().String(),
 			}
 
 			jsonBody, _ := json.Marshal(requestBody)
@@ -13,6 +13,7 @@ import (
 	"github.com/tas-agent-builder/config"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services/impl"
+	"github.com/tas-agent-builder/services/tokenizer"
 )
 
 // isAetherBEAvailable checks if Aether-BE service is available
@@ -417,7 +418,7 @@ func TestDocumentContextIntegration_ContextFormatting(t *testing.T) {
 			Strategy:    models.ContextStrategyVector,
 		}
 
-		injection, err := service.FormatContextForInjection(result, 1000)
+		injection, err := service.FormatContextForInjection(result, 1000, tokenizer.Get(tokenizer.DefaultEncoding))
 		require.NoError(t, err)
 		assert.NotNil(t, injection)
 		assert.False(t, injection.Truncated)
@@ -447,7 +448,7 @@ func TestDocumentContextIntegration_ContextFormatting(t *testing.T) {
 		}
 
 		// Request smaller limit
-		injection, err := service.FormatContextForInjection(result, 200)
+		injection, err := service.FormatContextForInjection(result, 200, tokenizer.Get(tokenizer.DefaultEncoding))
 		require.NoError(t, err)
 		assert.NotNil(t, injection)
 		assert.True(t, injection.Truncated)
@@ -11,7 +11,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/tas-agent-builder/config"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
 	"github.com/tas-agent-builder/services/impl"
@@ -19,16 +18,9 @@ import (
 
 // TestExecutionEngineBasic tests basic execution functionality
 func TestExecutionEngineBasic(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
+	_, routerCfg := newMockRouter(t)
 
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
-
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 	userID := uuid.New()
 
@@ -143,16 +135,9 @@ func TestExecutionEngineBasic(t *testing.T) {
 
 // TestExecutionEngineMetadata tests comprehensive metadata collection
 func TestExecutionEngineMetadata(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
-
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 	userID := uuid.New()
 
@@ -250,16 +235,9 @@ func TestExecutionEngineMetadata(t *testing.T) {
 
 // TestExecutionEngineConcurrency tests concurrent execution handling
 func TestExecutionEngineConcurrency(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
-
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
 	t.Run("Concurrent Executions - 5 simultaneous", func(t *testing.T) {
@@ -414,16 +392,9 @@ func TestExecutionEngineConcurrency(t *testing.T) {
 
 // TestExecutionEngineErrorHandling tests error scenarios and recovery
 func TestExecutionEngineErrorHandling(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
+	_, routerCfg := newMockRouter(t)
 
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
-
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 	userID := uuid.New()
 
@@ -515,16 +486,9 @@ func TestExecutionEngineErrorHandling(t *testing.T) {
 
 // TestExecutionEnginePerformance tests performance characteristics
 func TestExecutionEnginePerformance(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
-
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
 	t.Run("Response Time Analysis", func(t *testing.T) {
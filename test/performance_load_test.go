@@ -11,7 +11,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/tas-agent-builder/config"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
 	"github.com/tas-agent-builder/services/impl"
@@ -19,16 +18,9 @@ import (
 
 // TestPerformanceBaseline establishes performance baselines
 func TestPerformanceBaseline(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
-
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
 	t.Run("Single Request Baseline", func(t *testing.T) {
@@ -107,16 +99,9 @@ func TestPerformanceBaseline(t *testing.T) {
 
 // TestConcurrentLoad tests system behavior under concurrent load
 func TestConcurrentLoad(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
+	_, routerCfg := newMockRouter(t)
 
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
-
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
 	t.Run("Load Test - 10 Concurrent Requests", func(t *testing.T) {
@@ -175,16 +160,9 @@ func TestConcurrentLoad(t *testing.T) {
 
 // TestLoadWithReliabilityFeatures tests performance with reliability features enabled
 func TestLoadWithReliabilityFeatures(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
+	_, routerCfg := newMockRouter(t)
 
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
-
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
 	t.Run("Load Test with Retry Configuration", func(t *testing.T) {
@@ -288,16 +266,9 @@ func TestScalabilityLimits(t *testing.T) {
 		t.Skip("Skipping scalability tests in short mode")
 	}
 
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
-
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
 	t.Run("High Concurrency Test - 50 Concurrent Requests", func(t *testing.T) {
@@ -372,16 +343,9 @@ func TestScalabilityLimits(t *testing.T) {
 
 // TestMemoryAndResourceUsage tests resource consumption patterns
 func TestMemoryAndResourceUsage(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Skipf("Config not available: %v", err)
-	}
-
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
 	t.Run("Memory Usage During Concurrent Requests", func(t *testing.T) {
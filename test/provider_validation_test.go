@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/tas-agent-builder/config"
 	"github.com/tas-agent-builder/models"
 	"github.com/tas-agent-builder/services"
 	"github.com/tas-agent-builder/services/impl"
@@ -14,21 +13,12 @@ import (
 
 // TestBothProvidersIntegration validates that both OpenAI and Anthropic work through TAS-LLM-Router
 func TestBothProvidersIntegration(t *testing.T) {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Fatalf("Failed to load config: %v", err)
-	}
+	_, routerCfg := newMockRouter(t)
 
 	// Create router service
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
-	// Check router availability first
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available at", cfg.Router.BaseURL)
-	}
-
 	// Get available providers
 	providers, err := routerService.GetAvailableProviders(ctx)
 	if err != nil {
@@ -104,8 +94,7 @@ func TestBothProvidersIntegration(t *testing.T) {
 			// Validate configuration first
 			err := routerService.ValidateConfig(ctx, agentConfig)
 			if err != nil {
-				t.Skipf("Model %s not available: %v", tc.model, err)
-				return
+				t.Fatalf("Model %s not available: %v", tc.model, err)
 			}
 
 			// Prepare messages
@@ -216,18 +205,11 @@ func TestBothProvidersIntegration(t *testing.T) {
 
 // TestProviderSpecificFeatures tests provider-specific capabilities
 func TestProviderSpecificFeatures(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Fatalf("Failed to load config: %v", err)
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
-
 	userID := uuid.New()
 
 	t.Run("OpenAI System Message Handling", func(t *testing.T) {
@@ -245,7 +227,7 @@ func TestProviderSpecificFeatures(t *testing.T) {
 
 		response, err := routerService.SendRequest(ctx, agentConfig, messages, userID)
 		if err != nil {
-			t.Skipf("OpenAI not available: %v", err)
+			t.Fatalf("OpenAI request failed: %v", err)
 		}
 
 		t.Logf("OpenAI system message test: %s", response.Content)
@@ -270,7 +252,7 @@ func TestProviderSpecificFeatures(t *testing.T) {
 
 		response, err := routerService.SendRequest(ctx, agentConfig, messages, userID)
 		if err != nil {
-			t.Skipf("Anthropic not available: %v", err)
+			t.Fatalf("Anthropic request failed: %v", err)
 		}
 
 		t.Logf("Anthropic conversation test: %s", response.Content)
@@ -284,18 +266,11 @@ func TestProviderSpecificFeatures(t *testing.T) {
 
 // TestRoutingStrategies tests different routing optimization strategies
 func TestRoutingStrategies(t *testing.T) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		t.Fatalf("Failed to load config: %v", err)
-	}
+	_, routerCfg := newMockRouter(t)
 
-	routerService := impl.NewRouterService(&cfg.Router)
+	routerService := impl.NewRouterService(routerCfg)
 	ctx := context.Background()
 
-	if !isRouterAvailable(cfg.Router.BaseURL) {
-		t.Skip("TAS-LLM-Router not available")
-	}
-
 	userID := uuid.New()
 	prompt := "Explain what AI is in exactly 20 words."
 
@@ -327,7 +302,7 @@ func TestRoutingStrategies(t *testing.T) {
 
 			response, err := routerService.SendRequest(ctx, agentConfig, messages, userID)
 			if err != nil {
-				t.Skipf("Strategy %s failed: %v", strategy.name, err)
+				t.Fatalf("Strategy %s failed: %v", strategy.name, err)
 			}
 
 			t.Logf("%s result:", strategy.name)
@@ -25,7 +25,9 @@ type ReliabilityIntegrationTestSuite struct {
 func (suite *ReliabilityIntegrationTestSuite) SetupSuite() {
 	// Load test configuration
 	cfg, err := config.LoadConfig()
-	require.NoError(suite.T(), err)
+	if err != nil {
+		suite.T().Skipf("Config not available: %v", err)
+	}
 	suite.config = cfg
 
 	// Connect to test database
@@ -34,9 +36,10 @@ func (suite *ReliabilityIntegrationTestSuite) SetupSuite() {
 	require.NoError(suite.T(), err)
 	suite.db = db
 
-	// Verify database connection
-	err = db.Ping()
-	require.NoError(suite.T(), err)
+	// The suite checks the migrated schema, so it needs a live database
+	if err := db.Ping(); err != nil {
+		suite.T().Skipf("Test database not available: %v", err)
+	}
 }
 
 func (suite *ReliabilityIntegrationTestSuite) TearDownSuite() {
@@ -434,8 +437,8 @@ func (suite *ReliabilityIntegrationTestSuite) createTestAgent() *models.Agent {
 			RetryConfig:   models.DefaultRetryConfig(),
 			FallbackConfig: models.DefaultFallbackConfig(),
 		},
-		OwnerID:   uuid.New(),
-		SpaceID:   uuid.New(),
+		OwnerID:   uuid.New().String(),
+		SpaceID:   uuid.New().String(),
 		TenantID:  "test-tenant",
 		Status:    models.AgentStatusPublished,
 		SpaceType: models.SpaceTypePersonal,
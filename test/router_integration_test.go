@@ -50,16 +50,8 @@ type Usage struct {
 
 // TestRouterBasicQuery tests basic connectivity to TAS-LLM-Router
 func TestRouterBasicQuery(t *testing.T) {
-	// Get router URL from environment or use default
-	routerURL := os.Getenv("ROUTER_BASE_URL")
-	if routerURL == "" {
-		routerURL = "http://localhost:8080"
-	}
-
-	// Skip test if router is not available
-	if !isRouterAvailable(routerURL) {
-		t.Skip("TAS-LLM-Router not available at", routerURL)
-	}
+	_, routerCfg := newMockRouter(t)
+	routerURL := routerCfg.BaseURL
 
 	// Create a simple test request
 	request := RouterRequest{
@@ -105,14 +97,8 @@ func TestRouterBasicQuery(t *testing.T) {
 
 // TestRouterWithAgentConfig tests router with agent-like configuration
 func TestRouterWithAgentConfig(t *testing.T) {
-	routerURL := os.Getenv("ROUTER_BASE_URL")
-	if routerURL == "" {
-		routerURL = "http://localhost:8080"
-	}
-
-	if !isRouterAvailable(routerURL) {
-		t.Skip("TAS-LLM-Router not available at", routerURL)
-	}
+	_, routerCfg := newMockRouter(t)
+	routerURL := routerCfg.BaseURL
 
 	// Create an agent-like request
 	request := RouterRequest{
@@ -158,14 +144,8 @@ func TestRouterWithAgentConfig(t *testing.T) {
 
 // TestRouterProviderRouting tests specific provider routing
 func TestRouterProviderRouting(t *testing.T) {
-	routerURL := os.Getenv("ROUTER_BASE_URL")
-	if routerURL == "" {
-		routerURL = "http://localhost:8080"
-	}
-
-	if !isRouterAvailable(routerURL) {
-		t.Skip("TAS-LLM-Router not available at", routerURL)
-	}
+	_, routerCfg := newMockRouter(t)
+	routerURL := routerCfg.BaseURL
 
 	tests := []struct {
 		name     string
@@ -192,8 +172,7 @@ func TestRouterProviderRouting(t *testing.T) {
 
 			response, err := sendRouterRequest(routerURL, request)
 			if err != nil {
-				// Model might not be available, skip
-				t.Skipf("Model %s not available: %v", tt.model, err)
+				t.Fatalf("Failed to route to model %s: %v", tt.model, err)
 			}
 
 			if response.Model == "" {
@@ -24,8 +24,8 @@ func TestSpaceIsolation(t *testing.T) {
 			ID:          uuid.New(),
 			Name:        "User 1 Personal Agent",
 			Description: "Personal agent for user 1",
-			OwnerID:     user1ID,
-			SpaceID:     user1SpaceID,
+			OwnerID:     user1ID.String(),
+			SpaceID:     user1SpaceID.String(),
 			SpaceType:   models.SpaceTypePersonal,
 			TenantID:    tenantID,
 			IsPublic:    false,
@@ -39,8 +39,8 @@ func TestSpaceIsolation(t *testing.T) {
 			ID:          uuid.New(),
 			Name:        "User 2 Personal Agent",
 			Description: "Personal agent for user 2",
-			OwnerID:     user2ID,
-			SpaceID:     user2SpaceID,
+			OwnerID:     user2ID.String(),
+			SpaceID:     user2SpaceID.String(),
 			SpaceType:   models.SpaceTypePersonal,
 			TenantID:    tenantID,
 			IsPublic:    false,
@@ -85,8 +85,8 @@ func TestSpaceIsolation(t *testing.T) {
 			ID:          uuid.New(),
 			Name:        "Organization Agent",
 			Description: "Shared agent for organization",
-			OwnerID:     ownerID,
-			SpaceID:     orgSpaceID,
+			OwnerID:     ownerID.String(),
+			SpaceID:     orgSpaceID.String(),
 			SpaceType:   models.SpaceTypeOrganization,
 			TenantID:    tenantID,
 			IsPublic:    true,
@@ -124,8 +124,8 @@ func TestSpaceIsolation(t *testing.T) {
 			{
 				ID:        uuid.New(),
 				Name:      "Personal Agent",
-				OwnerID:   userID,
-				SpaceID:   uuid.New(),
+				OwnerID:   userID.String(),
+				SpaceID:   uuid.New().String(),
 				SpaceType: models.SpaceTypePersonal,
 				TenantID:  tenantID,
 				IsPublic:  false,
@@ -135,8 +135,8 @@ func TestSpaceIsolation(t *testing.T) {
 			{
 				ID:        uuid.New(),
 				Name:      "Owned Org Agent",
-				OwnerID:   userID,
-				SpaceID:   uuid.New(),
+				OwnerID:   userID.String(),
+				SpaceID:   uuid.New().String(),
 				SpaceType: models.SpaceTypeOrganization,
 				TenantID:  tenantID,
 				IsPublic:  true,
@@ -146,8 +146,8 @@ func TestSpaceIsolation(t *testing.T) {
 			{
 				ID:        uuid.New(),
 				Name:      "Shared Org Agent",
-				OwnerID:   uuid.New(), // Different owner
-				SpaceID:   uuid.New(),
+				OwnerID:   uuid.New().String(), // Different owner
+				SpaceID:   uuid.New().String(),
 				SpaceType: models.SpaceTypeOrganization,
 				TenantID:  tenantID,
 				IsPublic:  true,
@@ -157,8 +157,8 @@ func TestSpaceIsolation(t *testing.T) {
 			{
 				ID:        uuid.New(),
 				Name:      "Private Org Agent",
-				OwnerID:   uuid.New(), // Different owner
-				SpaceID:   uuid.New(),
+				OwnerID:   uuid.New().String(), // Different owner
+				SpaceID:   uuid.New().String(),
 				SpaceType: models.SpaceTypeOrganization,
 				TenantID:  tenantID,
 				IsPublic:  false,
@@ -285,7 +285,7 @@ func TestTenantIsolation(t *testing.T) {
 		tenant1Agent := &models.Agent{
 			ID:       uuid.New(),
 			Name:     "Tenant 1 Agent",
-			OwnerID:  user1ID,
+			OwnerID:  user1ID.String(),
 			TenantID: tenant1ID,
 			IsPublic: true,
 			Status:   models.AgentStatusPublished,
@@ -294,7 +294,7 @@ func TestTenantIsolation(t *testing.T) {
 		tenant2Agent := &models.Agent{
 			ID:       uuid.New(),
 			Name:     "Tenant 2 Agent",
-			OwnerID:  user2ID,
+			OwnerID:  user2ID.String(),
 			TenantID: tenant2ID,
 			IsPublic: true,
 			Status:   models.AgentStatusPublished,
@@ -479,7 +479,7 @@ type TenantMetrics struct {
 
 func canUserAccessAgent(agent *models.Agent, userID uuid.UUID) bool {
 	// Owner can always access
-	if agent.OwnerID == userID {
+	if agent.OwnerID == userID.String() {
 		return true
 	}
 	
@@ -498,7 +498,7 @@ func canTenantMemberAccessAgent(agent *models.Agent, userID uuid.UUID, tenantID
 	}
 	
 	// Owner can always access
-	if agent.OwnerID == userID {
+	if agent.OwnerID == userID.String() {
 		return true
 	}
 	
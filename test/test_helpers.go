@@ -4,13 +4,79 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/tas-agent-builder/config"
+	"github.com/tas-agent-builder/mockrouter"
+	"github.com/tas-agent-builder/mockrouter/mockroutertest"
 	"github.com/tas-agent-builder/models"
 	"gorm.io/datatypes"
 )
 
+// newMockRouter starts a mock TAS-LLM-Router serving the models these suites use, so they run
+// offline, and returns it with a router configuration pointing at it. Unless a test scripts
+// other responses, the model answers with the last user message after a few milliseconds and
+// reports token usage, from the provider serving the requested model; like the real router, it
+// rejects models it does not serve.
+func newMockRouter(t *testing.T) (*mockrouter.Router, *config.RouterConfig) {
+	t.Helper()
+	router, server := mockroutertest.NewServer(t)
+	providers := []mockrouter.Provider{
+		{
+			Name:              "openai",
+			MaxContextWindow:  128000,
+			SupportsFunctions: true,
+			SupportsStreaming: true,
+			Models: []mockrouter.Model{
+				{Name: "gpt-3.5-turbo", MaxContextWindow: 16385, MaxOutputTokens: 4096, InputCostPer1K: 0.0005, OutputCostPer1K: 0.0015},
+				{Name: "gpt-4", MaxContextWindow: 8192, MaxOutputTokens: 8192, InputCostPer1K: 0.03, OutputCostPer1K: 0.06},
+				{Name: "gpt-4o", MaxContextWindow: 128000, MaxOutputTokens: 16384, InputCostPer1K: 0.0025, OutputCostPer1K: 0.01},
+			},
+		},
+		{
+			Name:              "anthropic",
+			MaxContextWindow:  200000,
+			SupportsFunctions: true,
+			SupportsStreaming: true,
+			Models: []mockrouter.Model{
+				{Name: "claude-3-5-sonnet-20241022", MaxContextWindow: 200000, MaxOutputTokens: 8192, InputCostPer1K: 0.003, OutputCostPer1K: 0.015},
+				{Name: "claude-3-haiku-20240307", MaxContextWindow: 200000, MaxOutputTokens: 4096, InputCostPer1K: 0.00025, OutputCostPer1K: 0.00125},
+			},
+		},
+	}
+	router.SetProviders(providers...)
+
+	served := make(map[string]string)
+	for _, p := range providers {
+		for _, m := range p.Models {
+			served[m.Name] = p.Name
+		}
+	}
+	router.Respond(func(req mockrouter.ChatRequest) *mockrouter.Response {
+		provider, ok := served[req.Model]
+		if !ok {
+			return &mockrouter.Response{StatusCode: http.StatusBadRequest, Error: fmt.Sprintf("model %s not found", req.Model)}
+		}
+		prompt := ""
+		for _, msg := range req.Messages {
+			if msg.Role == "user" {
+				prompt = msg.Text()
+			}
+		}
+		return &mockrouter.Response{
+			Content:          "mock response to: " + prompt,
+			Provider:         provider,
+			PromptTokens:     len(strings.Fields(prompt)) + 10,
+			CompletionTokens: len(strings.Fields(prompt)) + 4,
+			LatencyMs:        5,
+		}
+	})
+	return router, &config.RouterConfig{BaseURL: server.URL, Timeout: 10, MaxRetries: 1, ModelCatalogTTL: 60}
+}
+
 // isRouterAvailable checks if the TAS-LLM-Router is available at the given URL
 func isRouterAvailable(routerURL string) bool {
 	// Try the providers endpoint instead of health since health might return 503